@@ -0,0 +1,106 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RightsizingPolicySpec defines per-workload overrides of the global
+// rightsizer settings. Zero-valued fields inherit the global setting.
+type RightsizingPolicySpec struct {
+	// Namespaces limits the policy to workloads in these namespaces. Empty matches every namespace.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Selector limits the policy to workloads whose pod template labels match. Nil matches every workload.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Priority orders overlapping policies: the highest wins, ties go to the alphabetically first name.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// Mode is how recommendations for matching workloads are applied.
+	// +kubebuilder:validation:Enum=off;recommend;auto
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Percentile is the usage percentile requests are sized from (100 is the observed maximum).
+	// +kubebuilder:validation:Enum=50;95;99;100
+	// +optional
+	Percentile int32 `json:"percentile,omitempty"`
+
+	// CPUTargetUtilPct is the target CPU utilization of the request.
+	// +optional
+	CPUTargetUtilPct float64 `json:"cpuTargetUtilPct,omitempty"`
+
+	// MemoryTargetUtilPct is the target memory utilization of the request.
+	// +optional
+	MemoryTargetUtilPct float64 `json:"memoryTargetUtilPct,omitempty"`
+
+	// MinKeepRatio is the minimum fraction of a request kept per rightsizing cycle.
+	// +optional
+	MinKeepRatio float64 `json:"minKeepRatio,omitempty"`
+
+	// OOMBumpMultiplier is the memory multiplier applied after an OOM kill.
+	// +optional
+	OOMBumpMultiplier float64 `json:"oomBumpMultiplier,omitempty"`
+
+	// MinCPU is the lowest CPU request a recommendation may set (e.g. "500m").
+	// +optional
+	MinCPU string `json:"minCPU,omitempty"`
+
+	// MaxCPU is the highest CPU request a recommendation may set.
+	// +optional
+	MaxCPU string `json:"maxCPU,omitempty"`
+
+	// MinMemory is the lowest memory request a recommendation may set (e.g. "2Gi").
+	// +optional
+	MinMemory string `json:"minMemory,omitempty"`
+
+	// MaxMemory is the highest memory request a recommendation may set.
+	// +optional
+	MaxMemory string `json:"maxMemory,omitempty"`
+
+	// CPULimitPolicy is what to recommend for the CPU limit of a throttled container.
+	// +kubebuilder:validation:Enum=raise;remove;off
+	// +optional
+	CPULimitPolicy string `json:"cpuLimitPolicy,omitempty"`
+
+	// DownsizeCPULimit is what a downsize does to CPU limits: scale them with the request, set them to a multiple of it, remove them or leave them alone.
+	// +kubebuilder:validation:Enum=keepRatio;multiplier;remove;off
+	// +optional
+	DownsizeCPULimit string `json:"downsizeCPULimit,omitempty"`
+
+	// CPULimitMultiplier is the CPU limit as a multiple of the new request under the "multiplier" downsize policy.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	CPULimitMultiplier float64 `json:"cpuLimitMultiplier,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+// +kubebuilder:printcolumn:name="Percentile",type=integer,JSONPath=`.spec.percentile`
+// +kubebuilder:printcolumn:name="Priority",type=integer,JSONPath=`.spec.priority`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// RightsizingPolicy is the Schema for the rightsizingpolicies API.
+type RightsizingPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RightsizingPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RightsizingPolicyList contains a list of RightsizingPolicy.
+type RightsizingPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RightsizingPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RightsizingPolicy{}, &RightsizingPolicyList{})
+}
@@ -0,0 +1,248 @@
+// Command koptimizer-aigate inspects the AI Gate decision history of a
+// KOptimizer instance and replays recorded decisions against another model,
+// validator chain or system prompt through the REST API.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+)
+
+const usage = `Usage: koptimizer-aigate [flags] <command> [command flags]
+
+Commands:
+  decisions   List recorded decisions
+  show <id>   Show a decision with its prompt and raw model output
+  replay      Replay recorded decisions and diff the new decisions
+
+Flags:
+`
+
+type client struct {
+	apiURL string
+	token  string
+	http   *http.Client
+}
+
+func main() {
+	apiURL := flag.String("api-url", "http://localhost:8080", "Base URL of the KOptimizer REST API")
+	token := flag.String("token", os.Getenv("KOPTIMIZER_API_TOKEN"), "Bearer token for the API (default $KOPTIMIZER_API_TOKEN)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &client{apiURL: strings.TrimSuffix(*apiURL, "/"), token: *token, http: &http.Client{Timeout: 20 * time.Minute}}
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "decisions":
+		err = c.decisions(args)
+	case "show":
+		err = c.show(args)
+	case "replay":
+		err = c.replay(args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (c *client) decisions(args []string) error {
+	fs := flag.NewFlagSet("decisions", flag.ExitOnError)
+	since := fs.String("since", "24h", "Only decisions made within this duration")
+	validator := fs.String("validator", "", "Only decisions made by this validator")
+	outcome := fs.String("outcome", "", "Only decisions with this outcome: success, rolled-back or oom")
+	limit := fs.Int("limit", 100, "Maximum number of decisions")
+	_ = fs.Parse(args)
+
+	q := url.Values{"since": {*since}, "limit": {strconv.Itoa(*limit)}, "pageSize": {strconv.Itoa(*limit)}}
+	if *validator != "" {
+		q.Set("validator", *validator)
+	}
+	if *outcome != "" {
+		q.Set("outcome", *outcome)
+	}
+	var page struct {
+		Data []aigate.Decision `json:"data"`
+	}
+	if err := c.do(http.MethodGet, "/api/v1/aigate/decisions?"+q.Encode(), nil, &page); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tVALIDATOR\tDECISION\tCONFIDENCE\tLATENCY\tOUTCOME\tSUMMARY")
+	for _, d := range page.Data {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%.2f\t%dms\t%s\t%s\n",
+			d.ID, d.Timestamp.Format(time.RFC3339), validatorName(d.Response), verdict(d.Response.Approved),
+			d.Response.Confidence, d.LatencyMs, orDash(d.Outcome), d.Request.Recommendation.Summary)
+	}
+	return tw.Flush()
+}
+
+func (c *client) show(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("show needs a decision id")
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		return fmt.Errorf("invalid decision id %q", args[0])
+	}
+	var d aigate.Decision
+	if err := c.do(http.MethodGet, "/api/v1/aigate/decisions/"+args[0], nil, &d); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+func (c *client) replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	ids := fs.String("ids", "", "Comma-separated decision ids to replay")
+	since := fs.String("since", "", "Replay decisions made within this duration")
+	validator := fs.String("validator", "", "Only replay decisions made by this validator")
+	outcome := fs.String("outcome", "", "Only replay decisions with this outcome")
+	limit := fs.Int("limit", 20, "Maximum number of decisions to replay")
+	validators := fs.String("validators", "", "Comma-separated validator chain to replay against (default: configured)")
+	model := fs.String("model", "", "Anthropic model to replay against")
+	openaiURL := fs.String("openai-base-url", "", "OpenAI-compatible base URL to replay against")
+	openaiModel := fs.String("openai-model", "", "Model of the OpenAI-compatible endpoint")
+	promptFile := fs.String("system-prompt-file", "", "File with a candidate system prompt")
+	asJSON := fs.Bool("json", false, "Print the full replay report as JSON")
+	_ = fs.Parse(args)
+
+	body := map[string]any{"since": *since, "validator": *validator, "outcome": *outcome, "limit": *limit, "model": *model}
+	if *ids != "" {
+		var list []int64
+		for _, s := range strings.Split(*ids, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid decision id %q", s)
+			}
+			list = append(list, id)
+		}
+		body["ids"] = list
+	}
+	if *validators != "" {
+		body["validators"] = strings.Split(*validators, ",")
+	}
+	if *openaiURL != "" || *openaiModel != "" {
+		body["openai"] = map[string]string{"baseURL": *openaiURL, "model": *openaiModel}
+	}
+	if *promptFile != "" {
+		prompt, err := os.ReadFile(*promptFile)
+		if err != nil {
+			return fmt.Errorf("reading system prompt: %w", err)
+		}
+		body["systemPrompt"] = string(prompt)
+	}
+
+	var report aigate.ReplayReport
+	if err := c.do(http.MethodPost, "/api/v1/aigate/replay", body, &report); err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tORIGINAL\tREPLAYED\tOUTCOME\tSUMMARY")
+	for _, res := range report.Results {
+		if !res.Changed {
+			continue
+		}
+		fmt.Fprintf(tw, "%d\t%s (%s)\t%s (%s)\t%s\t%s\n", res.DecisionID,
+			verdict(res.Original.Approved), validatorName(res.Original),
+			verdict(res.Replayed.Approved), validatorName(res.Replayed),
+			orDash(res.Outcome), res.Summary)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nPrompt version: %s\n", report.PromptVersion)
+	fmt.Printf("Replayed %d decisions: %d changed (%d newly approved, %d newly rejected)\n",
+		report.Total, report.Changed, report.NewlyApproved, report.NewlyRejected)
+	fmt.Printf("Approved changes that later failed: %d recorded, %d replayed\n",
+		report.OriginalApprovedFailures, report.ReplayedApprovedFailures)
+	fmt.Printf("Rejected changes that later succeeded: %d recorded, %d replayed\n",
+		report.OriginalRejectedSuccesses, report.ReplayedRejectedSuccesses)
+	return nil
+}
+
+// do sends a request to the API and decodes the JSON response into out.
+func (c *client) do(method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.apiURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
+
+func verdict(approved bool) string {
+	if approved {
+		return "approved"
+	}
+	return "rejected"
+}
+
+func validatorName(r aigate.ValidationResponse) string {
+	name := orDash(r.Validator)
+	if r.Rule != "" {
+		name += ":" + r.Rule
+	} else if r.Model != "" {
+		name += ":" + r.Model
+	}
+	return name
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -1,5 +1,5 @@
 import { api } from '../api.js';
-import { $, toArray, fmt$, fmtPct, errorMsg, esc } from '../utils.js';
+import { $, toArray, fmt$, fmtPct, errorMsg, esc, timeAgo } from '../utils.js';
 import { makeChart } from '../charts.js';
 import { skeleton, badge, toast, cardHeader } from '../components.js';
 import { addCleanup } from '../router.js';
@@ -9,12 +9,16 @@ export async function renderMultiCluster(targetEl) {
   const container = () => targetEl || $('#page-container');
   container().innerHTML = skeleton(5);
   try {
-    const data = await api('/clusters').catch(() => null);
+    const [data, rollup] = await Promise.all([
+      api('/clusters').catch(() => null),
+      api('/clusters/rollup').catch(() => null),
+    ]);
     const clusters = toArray(data, 'clusters');
 
-    const totalCost = clusters.reduce((s, c) => s + (c.monthlyCostUSD || 0), 0);
-    const totalNodes = clusters.reduce((s, c) => s + (c.nodeCount || 0), 0);
-    const totalSavings = clusters.reduce((s, c) => s + (c.potentialSavings || 0), 0);
+    const totalCost = rollup ? rollup.monthlyCostUSD : clusters.reduce((s, c) => s + (c.monthlyCostUSD || 0), 0);
+    const totalNodes = rollup ? rollup.nodeCount : clusters.reduce((s, c) => s + (c.nodeCount || 0), 0);
+    const totalSavings = rollup ? rollup.potentialSavings : clusters.reduce((s, c) => s + (c.potentialSavings || 0), 0);
+    const unhealthy = clusters.filter(c => c.status && c.status !== 'healthy').length;
 
     container().innerHTML = `
       ${!targetEl ? '<div class="page-header"><h1>Multi-Cluster Overview</h1><p>Organization-wide Kubernetes cost and efficiency</p></div>' : ''}
@@ -23,6 +27,8 @@ export async function renderMultiCluster(targetEl) {
         <div class="kpi-card"><div class="label">Total Monthly Cost</div><div class="value">${fmt$(totalCost)}</div></div>
         <div class="kpi-card"><div class="label">Total Nodes</div><div class="value">${totalNodes}</div></div>
         <div class="kpi-card"><div class="label">Total Savings</div><div class="value green">${fmt$(totalSavings)}</div></div>
+        <div class="kpi-card"><div class="label">Fleet Efficiency</div><div class="value">${fmtPct(rollup?.efficiencyScore)}</div></div>
+        <div class="kpi-card"><div class="label">Stale / Unreachable</div><div class="value ${unhealthy ? 'amber' : ''}">${unhealthy}</div></div>
       </div>
       <div class="card">
         <h2>Cost by Cluster</h2>
@@ -31,7 +37,8 @@ export async function renderMultiCluster(targetEl) {
       <div class="card">
         ${cardHeader('Clusters')}
         <div class="cluster-grid" id="cluster-grid"></div>
-      </div>`;
+      </div>
+      <div class="card" id="cluster-detail" style="display:none"></div>`;
 
     // Chart
     if (clusters.length) {
@@ -54,11 +61,13 @@ export async function renderMultiCluster(targetEl) {
       return badge(p?.toUpperCase() || 'K8S', cls);
     };
     const effColor = s => s >= 80 ? 'green' : s >= 50 ? 'amber' : 'red';
+    const statusBadge = s => badge(s || 'healthy', s === 'healthy' || !s ? 'green' : s === 'degraded' ? 'amber' : s === 'pending' ? 'gray' : 'red');
     $('#cluster-grid').innerHTML = clusters.length ? clusters.map(c => `
       <div class="cluster-card" data-action-key="switchCluster" data-cluster-id="${encodeURIComponent(c.id || c.name)}">
         <div class="cluster-card-header">
           <span class="cluster-name">${esc(c.name || '')}</span>
           ${providerBadge(c.provider)}
+          ${statusBadge(c.status)}
         </div>
         <div class="cluster-card-stats">
           <div class="cluster-stat"><span class="cluster-stat-label">Nodes</span><span class="cluster-stat-val">${c.nodeCount || 0}</span></div>
@@ -69,11 +78,46 @@ export async function renderMultiCluster(targetEl) {
         <div class="cluster-card-savings">
           Potential savings: <strong class="green">${fmt$(c.potentialSavings)}</strong>
         </div>
+        ${c.source === 'remote' ? `<div class="cluster-card-updated" style="color:var(--text-muted);font-size:12px" title="${esc(c.lastError || '')}">
+          Updated ${c.lastUpdated ? timeAgo(c.lastUpdated) : 'never'}${c.lastError ? ' &middot; last poll failed' : ''}
+        </div>` : ''}
       </div>
     `).join('') : '<div style="color:var(--text-muted);padding:24px;text-align:center">No clusters registered</div>';
 
-    registerAction('switchCluster', (el) => {
-      toast('Switched to cluster: ' + decodeURIComponent(el.dataset.clusterId), 'info');
+    registerAction('switchCluster', async (el) => {
+      const id = decodeURIComponent(el.dataset.clusterId);
+      const detail = $('#cluster-detail');
+      try {
+        const d = await api('/clusters/' + encodeURIComponent(id));
+        const c = d.cluster || {};
+        const history = toArray(d, 'history');
+        detail.style.display = '';
+        detail.innerHTML = `
+          <h2>${esc(c.name || id)} ${statusBadge(c.status)}</h2>
+          <div class="kpi-grid">
+            <div class="kpi-card"><div class="label">Mode</div><div class="value">${esc(c.mode || '-')}</div></div>
+            <div class="kpi-card"><div class="label">Region</div><div class="value">${esc(c.region || '-')}</div></div>
+            <div class="kpi-card"><div class="label">CPU Utilization</div><div class="value">${fmtPct(c.cpuUtilizationPct)}</div></div>
+            <div class="kpi-card"><div class="label">Memory Utilization</div><div class="value">${fmtPct(c.memUtilizationPct)}</div></div>
+          </div>
+          ${c.lastError ? errorMsg('Last poll failed: ' + c.lastError) : ''}
+          ${history.length ? '<div class="chart-container"><canvas id="mc-detail-chart"></canvas></div>' : ''}`;
+        if (history.length) {
+          makeChart('mc-detail-chart', {
+            type: 'line',
+            data: {
+              labels: history.map(h => new Date(h.lastUpdated).toLocaleString()),
+              datasets: [
+                { label: 'Monthly Cost', data: history.map(h => h.monthlyCostUSD || 0), borderColor: '#4361ee', tension: 0.3 },
+                { label: 'Potential Savings', data: history.map(h => h.potentialSavings || 0), borderColor: '#10b981', tension: 0.3 }
+              ]
+            },
+            options: { responsive: true, maintainAspectRatio: false, plugins: { legend: { position: 'top' } }, scales: { y: { beginAtZero: true } } }
+          });
+        }
+      } catch (e) {
+        toast('Failed to load cluster ' + id + ': ' + e.message, 'error');
+      }
     });
     addCleanup(() => unregisterAction('switchCluster'));
   } catch (e) {
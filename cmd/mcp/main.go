@@ -5,19 +5,23 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/koptimizer/koptimizer/internal/mcp"
 )
 
 func main() {
 	apiURL := flag.String("api-url", "http://localhost:8080", "Base URL of the KOptimizer REST API")
+	token := flag.String("token", os.Getenv("KOPTIMIZER_API_TOKEN"), "Bearer token for the API (default $KOPTIMIZER_API_TOKEN)")
+	pollInterval := flag.Duration("poll-interval", 30*time.Second, "How often subscribed resources are checked for changes")
 	flag.Parse()
 
 	// All informational output goes to stderr so stdout stays clean for JSON-RPC.
 	logger := log.New(os.Stderr, "[koptimizer-mcp] ", log.LstdFlags)
 	logger.Printf("starting MCP server, API URL: %s", *apiURL)
 
-	server := mcp.NewMCPServer(*apiURL)
+	server := mcp.NewMCPServer(*apiURL, *token)
+	server.SetPollInterval(*pollInterval)
 	if err := server.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
 		os.Exit(1)
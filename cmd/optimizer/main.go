@@ -20,29 +20,41 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
 	"github.com/koptimizer/koptimizer/internal/apiserver"
+	"github.com/koptimizer/koptimizer/internal/apiserver/auth"
+	"github.com/koptimizer/koptimizer/internal/chargeback"
 	"github.com/koptimizer/koptimizer/internal/cloud"
+	"github.com/koptimizer/koptimizer/internal/cloud/karpenter"
 	"github.com/koptimizer/koptimizer/internal/config"
 	"github.com/koptimizer/koptimizer/internal/controller/alerts"
 	"github.com/koptimizer/koptimizer/internal/controller/commitments"
 	"github.com/koptimizer/koptimizer/internal/controller/costmonitor"
+	"github.com/koptimizer/koptimizer/internal/controller/evictor"
+	"github.com/koptimizer/koptimizer/internal/controller/executor"
 	"github.com/koptimizer/koptimizer/internal/controller/gpu"
 	"github.com/koptimizer/koptimizer/internal/controller/hibernation"
 	"github.com/koptimizer/koptimizer/internal/controller/network"
 	"github.com/koptimizer/koptimizer/internal/controller/podpurger"
 	"github.com/koptimizer/koptimizer/internal/controller/nodegroupmgr"
+	"github.com/koptimizer/koptimizer/internal/controller/rebalancer"
 	"github.com/koptimizer/koptimizer/internal/controller/rightsizer"
 	"github.com/koptimizer/koptimizer/internal/controller/spot"
 	"github.com/koptimizer/koptimizer/internal/controller/storage"
 	"github.com/koptimizer/koptimizer/internal/controller/workloadscaler"
+	"github.com/koptimizer/koptimizer/internal/helmdrift"
+	"github.com/koptimizer/koptimizer/internal/hub"
 	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
 	"github.com/koptimizer/koptimizer/internal/state"
 	"github.com/koptimizer/koptimizer/internal/store"
+	"github.com/koptimizer/koptimizer/internal/webhook"
 	"github.com/koptimizer/koptimizer/pkg/aigate"
 	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
 	"github.com/koptimizer/koptimizer/pkg/familylock"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
 )
 
 var (
@@ -84,8 +96,9 @@ func main() {
 	if cfg.Database.Path != "" {
 		var dbErr error
 		appDB, dbErr = store.Open(store.Config{
-			Path:          cfg.Database.Path,
-			RetentionDays: cfg.Database.RetentionDays,
+			Path:             cfg.Database.Path,
+			RetentionDays:    cfg.Database.RetentionDays,
+			MetricsRetention: cfg.Metrics.Retention,
 		})
 		if dbErr != nil {
 			setupLog.Info("Database open failed, continuing with in-memory mode", "error", dbErr)
@@ -137,6 +150,10 @@ func main() {
 				cfg.AIGate.Enabled = enabled
 			case "podPurger":
 				cfg.PodPurger.Enabled = enabled
+			case "evictor":
+				cfg.Evictor.Enabled = enabled
+			case "rebalancer":
+				cfg.Rebalancer.Enabled = enabled
 			}
 		}
 		setupLog.Info("Restoring persisted controller states", "count", len(ctrlStates))
@@ -183,6 +200,10 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         true,
 		LeaderElectionID:       "koptimizer-leader",
+		WebhookServer: ctrlwebhook.NewServer(ctrlwebhook.Options{
+			Port:    cfg.Webhook.Port,
+			CertDir: cfg.Webhook.CertDir,
+		}),
 		// Bypass cache for metrics-server types so controller-runtime does not
 		// start informers that spam errors when metrics-server is unavailable.
 		Client: client.Options{
@@ -227,6 +248,10 @@ func main() {
 		setupLog.Error(err, "Unable to create cloud provider")
 		os.Exit(1)
 	}
+	// Karpenter NodePools become node groups alongside the cloud provider's.
+	if cfg.NodeGroupMgr.Karpenter.Enabled {
+		provider = karpenter.NewProvider(provider, directClient)
+	}
 	// Start background pricing cache refresh if the provider supports it.
 	// Uses a cancellable context that will be cancelled on process exit.
 	bgCtx, bgCancel := context.WithCancel(context.Background())
@@ -237,18 +262,64 @@ func main() {
 
 	// Initialize metrics collector
 	metricsCollector := intmetrics.NewCollector(mgr.GetClient())
+	gpuSource, err := intmetrics.NewGPUSource(mgr.GetClient(), cfg)
+	if err != nil {
+		setupLog.Error(err, "Unable to create GPU metrics source")
+		os.Exit(1)
+	}
+	if gpuSource != nil {
+		metricsCollector.SetGPUSource(gpuSource)
+		setupLog.Info("Using measured GPU metrics", "source", cfg.GPU.MetricsSource)
+	}
+
+	// Prometheus backend: live metrics come from Prometheus instead of
+	// metrics-server; GPU metrics still go through the collector above.
+	var collector pkgmetrics.MetricsCollector = metricsCollector
+	var promCollector *intmetrics.PrometheusCollector
+	if cfg.Metrics.Backend == "prometheus" {
+		promCollector, err = intmetrics.NewPrometheusCollector(cfg.Metrics, metricsCollector)
+		if err != nil {
+			setupLog.Error(err, "Unable to create Prometheus metrics collector")
+			os.Exit(1)
+		}
+		collector = promCollector
+		setupLog.Info("Using Prometheus metrics backend", "url", cfg.Metrics.PrometheusURL)
+	}
 
 	// Initialize metrics store for time-series data (percentiles)
+	metricsRetention := cfg.Metrics.Retention
+	if metricsRetention <= 0 {
+		metricsRetention = 7 * 24 * time.Hour
+	}
 	var metricsStore *intmetrics.Store
 	if sqlDBRef != nil {
-		metricsStore = intmetrics.NewStoreWithDB(7*24*time.Hour, sqlDBRef, dbWriter)
+		metricsStore = intmetrics.NewStoreWithDB(metricsRetention, sqlDBRef, dbWriter)
 	} else {
-		metricsStore = intmetrics.NewStore(7 * 24 * time.Hour)
+		metricsStore = intmetrics.NewStore(metricsRetention)
+	}
+
+	// Backfill history in the background so percentile analysis does not
+	// have to wait days for the store to fill from live samples.
+	if promCollector != nil {
+		window := cfg.Metrics.BackfillWindow
+		if window <= 0 || window > metricsRetention {
+			window = metricsRetention
+		}
+		go func() {
+			start := time.Now()
+			n, err := promCollector.Backfill(bgCtx, metricsStore, window)
+			if err != nil {
+				setupLog.Error(err, "Prometheus backfill incomplete", "samples", n)
+				return
+			}
+			setupLog.Info("Backfilled metrics history from Prometheus", "samples", n, "window", window, "took", time.Since(start).Round(time.Second))
+		}()
 	}
 
 	// Initialize cluster state (audit log backed by SQLite when available)
-	clusterState := state.NewClusterState(mgr.GetClient(), provider, metricsCollector, sqlDBRef, dbWriter, metricsStore, directClient)
+	clusterState := state.NewClusterState(mgr.GetClient(), provider, collector, sqlDBRef, dbWriter, metricsStore, directClient)
 	clusterState.SetRESTConfig(mgr.GetConfig())
+	clusterState.SetThrottlingCollection(cfg.Rightsizer.Throttling.Enabled)
 
 	// One-shot cleanup: uncordon any nodes previously cordoned by koptimizer.
 	{
@@ -289,6 +360,16 @@ func main() {
 		CostThresholdUSD:  cfg.AIGate.CostThresholdUSD,
 		ScaleThresholdPct: cfg.AIGate.ScaleThresholdPct,
 		MaxEvictNodes:     cfg.AIGate.MaxEvictNodes,
+		Validators:        cfg.AIGate.Validators,
+		SystemPrompt:      cfg.AIGate.SystemPrompt,
+		OpenAI: aigate.OpenAIConfig{
+			BaseURL: cfg.AIGate.OpenAI.BaseURL,
+			Model:   cfg.AIGate.OpenAI.Model,
+			APIKey:  cfg.AIGate.OpenAI.APIKey,
+		},
+	}
+	for _, r := range cfg.AIGate.Rules {
+		aiGateCfg.Rules = append(aiGateCfg.Rules, aigate.Rule{Name: r.Name, When: r.When, Decision: r.Decision, Reason: r.Reason})
 	}
 	// Configure business hours timezone for the AI Gate prompt.
 	if cfg.AIGate.Timezone != "" {
@@ -303,6 +384,17 @@ func main() {
 		setupLog.Error(err, "Unable to create AI Safety Gate")
 		os.Exit(1)
 	}
+	// Record every gate decision and its outcome for audits and replays (nil-safe)
+	aiGateStore := store.NewAIGateStore(sqlDBRef)
+	gate.SetRecorder(aiGateStore)
+	// Helm drift is shared by the API and the AI Gate workload context
+	helmDriftSvc := helmdrift.NewService(cfg, clusterState)
+	clusterState.SetHelmDrift(helmDriftSvc)
+
+	// Recommendation executor — always registered so approved Recommendation
+	// CRDs are applied once the mode is switched to active. Enabled controllers
+	// register themselves as executors for the recommendation types they own.
+	recExecutor := executor.NewController(mgr, clusterState, guard, gate, cfg)
 
 	// Register controllers based on config
 	if cfg.CostMonitor.Enabled {
@@ -320,24 +412,46 @@ func main() {
 	}
 
 	if cfg.NodeGroupMgr.Enabled {
-		if err := nodegroupmgr.NewController(mgr, provider, clusterState, guard, gate, cfg).SetupWithManager(mgr); err != nil {
+		ngMgr := nodegroupmgr.NewController(mgr, provider, clusterState, guard, gate, cfg)
+		if err := ngMgr.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "Unable to create controller", "controller", "NodeGroupMgr")
 			os.Exit(1)
 		}
+		recExecutor.Register(optimizer.RecommendationNodeGroupAdjust, ngMgr.ExecuteApproved)
 	}
 
+	var recSource webhook.RecommendationSource
 	if cfg.Rightsizer.Enabled {
-		if err := rightsizer.NewController(mgr, clusterState, gate, cfg, metricsStore).SetupWithManager(mgr); err != nil {
+		rs := rightsizer.NewController(mgr, clusterState, gate, cfg, metricsStore)
+		if promCollector != nil {
+			rs.SetHistory(promCollector)
+		}
+		if err := rs.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "Unable to create controller", "controller", "Rightsizer")
 			os.Exit(1)
 		}
+		recExecutor.Register(optimizer.RecommendationPodRightsize, rs.ExecuteApproved)
+		recExecutor.RegisterWait(optimizer.RecommendationPodRightsize, rs.WaitApproved)
+		recSource = rs
+	}
+
+	// The webhook is served by every replica, not only the leader. Injected
+	// recommendations come from the rightsizer, which only runs on the leader.
+	if cfg.Webhook.Enabled {
+		if err := webhook.SetupWithManager(mgr, cfg, recSource); err != nil {
+			setupLog.Error(err, "Unable to set up webhook", "webhook", "pod")
+			os.Exit(1)
+		}
+		setupLog.Info("Serving pod admission webhook", "port", cfg.Webhook.Port, "path", webhook.PodMutatePath)
 	}
 
 	if cfg.WorkloadScaler.Enabled {
-		if err := workloadscaler.NewController(mgr, clusterState, guard, gate, cfg).SetupWithManager(mgr); err != nil {
+		ws := workloadscaler.NewController(mgr, clusterState, guard, gate, cfg)
+		if err := ws.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "Unable to create controller", "controller", "WorkloadScaler")
 			os.Exit(1)
 		}
+		recExecutor.Register(optimizer.RecommendationWorkloadScale, ws.ExecuteApproved)
 	}
 
 	// Pod purger — always registered so it can be toggled at runtime via API.
@@ -348,24 +462,49 @@ func main() {
 	}
 
 	if cfg.GPU.Enabled {
-		if err := gpu.NewController(mgr, clusterState, guard, gate, cfg).SetupWithManager(mgr); err != nil {
+		gpuCtrl := gpu.NewController(mgr, clusterState, guard, gate, cfg)
+		if err := gpuCtrl.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "Unable to create controller", "controller", "GPU")
 			os.Exit(1)
 		}
+		recExecutor.Register(optimizer.RecommendationGPUOptimize, gpuCtrl.ExecuteApproved)
 	}
 
 	if cfg.Spot.Enabled {
-		if err := spot.NewController(mgr, provider, clusterState, guard, gate, cfg).SetupWithManager(mgr); err != nil {
+		spotCtrl := spot.NewController(mgr, provider, clusterState, guard, gate, cfg)
+		if err := spotCtrl.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "Unable to create controller", "controller", "Spot")
 			os.Exit(1)
 		}
+		recExecutor.Register(optimizer.RecommendationSpotOptimize, spotCtrl.ExecuteApproved)
+	}
+
+	if cfg.Evictor.Enabled {
+		ev := evictor.NewController(mgr, provider, clusterState, guard, gate, cfg)
+		if err := ev.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "Unable to create controller", "controller", "Evictor")
+			os.Exit(1)
+		}
+		recExecutor.Register(optimizer.RecommendationEviction, ev.ExecuteApproved)
+	}
+
+	var rb *rebalancer.Controller
+	if cfg.Rebalancer.Enabled {
+		rb = rebalancer.NewController(mgr, provider, clusterState, guard, gate, cfg)
+		if err := rb.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "Unable to create controller", "controller", "Rebalancer")
+			os.Exit(1)
+		}
+		recExecutor.Register(optimizer.RecommendationRebalance, rb.ExecuteApproved)
 	}
 
 	if cfg.Hibernation.Enabled {
-		if err := hibernation.NewController(mgr, provider, clusterState, guard, gate, cfg).SetupWithManager(mgr); err != nil {
+		hib := hibernation.NewController(mgr, provider, clusterState, guard, gate, cfg)
+		if err := hib.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "Unable to create controller", "controller", "Hibernation")
 			os.Exit(1)
 		}
+		recExecutor.Register(optimizer.RecommendationHibernation, hib.ExecuteApproved)
 	}
 
 	if cfg.StorageMonitor.Enabled {
@@ -389,6 +528,38 @@ func main() {
 		}
 	}
 
+	if err := recExecutor.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "Unable to create controller", "controller", "RecommendationExecutor")
+		os.Exit(1)
+	}
+
+	// Scheduled chargeback export of the previous month's reports
+	if cfg.Chargeback.Enabled {
+		exporter, err := chargeback.NewExporter(ctx, chargeback.NewGenerator(costStore, mgr.GetClient(), cfg), cfg)
+		if err != nil {
+			setupLog.Error(err, "Unable to configure chargeback export")
+			os.Exit(1)
+		}
+		if err := mgr.Add(exporter); err != nil {
+			setupLog.Error(err, "Unable to start chargeback export")
+			os.Exit(1)
+		}
+	}
+
+	// Fleet hub: poll remote koptimizer instances for /api/v1/clusters
+	var fleet *hub.Poller
+	if cfg.Hub.Enabled {
+		fleet, err = hub.NewPoller(cfg.Hub, store.NewHubStore(sqlDBRef))
+		if err != nil {
+			setupLog.Error(err, "Unable to configure hub")
+			os.Exit(1)
+		}
+		if err := mgr.Add(fleet); err != nil {
+			setupLog.Error(err, "Unable to start hub poller")
+			os.Exit(1)
+		}
+	}
+
 	// Health checks
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "Unable to set up health check")
@@ -402,7 +573,15 @@ func main() {
 	// Start REST API server
 	var apiSrv *http.Server
 	if cfg.APIServer.Enabled {
-		apiSrv = apiserver.NewServer(cfg, clusterState, provider, guard, mgr.GetClient(), costStore, metricsStore, settingsStore)
+		authn, err := auth.NewAuthenticator(cfg.APIServer.Auth, auth.NewClientReviewer(mgr.GetClient()))
+		if err != nil {
+			setupLog.Error(err, "Unable to configure API authentication")
+			os.Exit(1)
+		}
+		if !cfg.APIServer.Auth.Enabled {
+			setupLog.Info("API authentication is disabled; all API routes are open")
+		}
+		apiSrv = apiserver.NewServer(cfg, clusterState, provider, guard, mgr.GetClient(), costStore, metricsStore, settingsStore, aiGateStore, helmDriftSvc, rb, authn, fleet)
 		go func() {
 			addr := fmt.Sprintf("%s:%d", cfg.APIServer.Address, cfg.APIServer.Port)
 			setupLog.Info("Starting API server", "address", addr)
@@ -0,0 +1,352 @@
+// Package auth authenticates REST API requests with bearer tokens and
+// authorizes them by role.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+)
+
+// Role is an API access level. Each role includes the ones below it.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleApprover
+	RoleOperator
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleApprover:
+		return "approver"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// ParseRole converts a configured role name to a Role.
+func ParseRole(s string) (Role, error) {
+	switch s {
+	case "viewer":
+		return RoleViewer, nil
+	case "approver":
+		return RoleApprover, nil
+	case "operator":
+		return RoleOperator, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return RoleNone, fmt.Errorf("unknown role %q", s)
+	}
+}
+
+// Authentication methods recorded on a Principal.
+const (
+	MethodAnonymous   = "anonymous" // auth disabled
+	MethodToken       = "token"
+	MethodTokenReview = "tokenreview"
+)
+
+// Principal is the authenticated caller of an API request.
+type Principal struct {
+	Name   string
+	Groups []string
+	Role   Role
+	Method string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the request's principal, or nil.
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// User returns the principal name to record in the audit log. When the
+// request was not authenticated (auth disabled) it returns fallback.
+func User(ctx context.Context, fallback string) string {
+	if p := PrincipalFrom(ctx); p != nil && p.Method != MethodAnonymous {
+		return p.Name
+	}
+	return fallback
+}
+
+// TokenReviewer validates a bearer token with the Kubernetes API server.
+type TokenReviewer interface {
+	Review(ctx context.Context, token string, audiences []string) (*authv1.TokenReviewStatus, error)
+}
+
+type clientReviewer struct {
+	client client.Client
+}
+
+// NewClientReviewer returns a TokenReviewer that creates TokenReview
+// objects through c. The caller needs RBAC to create tokenreviews.
+func NewClientReviewer(c client.Client) TokenReviewer {
+	return &clientReviewer{client: c}
+}
+
+func (r *clientReviewer) Review(ctx context.Context, token string, audiences []string) (*authv1.TokenReviewStatus, error) {
+	tr := &authv1.TokenReview{Spec: authv1.TokenReviewSpec{Token: token, Audiences: audiences}}
+	if err := r.client.Create(ctx, tr); err != nil {
+		return nil, err
+	}
+	return &tr.Status, nil
+}
+
+const (
+	reviewTimeout     = 5 * time.Second
+	reviewCacheTTL    = time.Minute
+	reviewNegativeTTL = 10 * time.Second
+	maxReviewCache    = 1000
+)
+
+var (
+	errMissingToken = errors.New("missing or malformed Authorization header, expected \"Bearer <token>\"")
+	errInvalidToken = errors.New("invalid bearer token in Authorization header")
+)
+
+type staticToken struct {
+	name  string
+	token []byte
+	role  Role
+}
+
+type reviewResult struct {
+	principal *Principal
+	err       error
+	expires   time.Time
+}
+
+// Authenticator resolves bearer tokens to principals: static tokens first,
+// then the TokenReview API when configured. TokenReview results are cached
+// briefly, keyed by token hash.
+type Authenticator struct {
+	enabled     bool
+	tokens      []staticToken
+	reviewer    TokenReviewer
+	audiences   []string
+	userRoles   map[string]Role
+	groupRoles  map[string]Role
+	defaultRole Role
+
+	mu    sync.Mutex
+	cache map[[32]byte]reviewResult
+}
+
+// NewAuthenticator builds an Authenticator from cfg. reviewer is only used
+// when cfg.TokenReview is set.
+func NewAuthenticator(cfg config.APIAuthConfig, reviewer TokenReviewer) (*Authenticator, error) {
+	a := &Authenticator{
+		enabled:    cfg.Enabled,
+		audiences:  cfg.Audiences,
+		userRoles:  make(map[string]Role, len(cfg.UserRoles)),
+		groupRoles: make(map[string]Role, len(cfg.GroupRoles)),
+		cache:      make(map[[32]byte]reviewResult),
+	}
+	for _, t := range cfg.Tokens {
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("token %q: %w", t.Name, err)
+		}
+		a.tokens = append(a.tokens, staticToken{name: t.Name, token: []byte(t.Token), role: role})
+	}
+	if cfg.TokenReview {
+		if reviewer == nil {
+			return nil, fmt.Errorf("tokenReview is enabled but no Kubernetes client is available")
+		}
+		a.reviewer = reviewer
+	}
+	for user, name := range cfg.UserRoles {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("user %q: %w", user, err)
+		}
+		a.userRoles[user] = role
+	}
+	for group, name := range cfg.GroupRoles {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("group %q: %w", group, err)
+		}
+		a.groupRoles[group] = role
+	}
+	if cfg.DefaultRole != "" {
+		role, err := ParseRole(cfg.DefaultRole)
+		if err != nil {
+			return nil, fmt.Errorf("defaultRole: %w", err)
+		}
+		a.defaultRole = role
+	}
+	return a, nil
+}
+
+// Authenticate resolves the request's bearer token to a principal.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, errMissingToken
+	}
+	token = strings.TrimSpace(token)
+
+	// Compare against every static token so timing does not reveal which
+	// one matched.
+	var match *staticToken
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare(a.tokens[i].token, []byte(token)) == 1 {
+			match = &a.tokens[i]
+		}
+	}
+	if match != nil {
+		return &Principal{Name: match.name, Role: match.role, Method: MethodToken}, nil
+	}
+
+	if a.reviewer == nil {
+		return nil, errInvalidToken
+	}
+	return a.review(r.Context(), token)
+}
+
+func (a *Authenticator) review(ctx context.Context, token string) (*Principal, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	a.mu.Lock()
+	if res, ok := a.cache[key]; ok && now.Before(res.expires) {
+		a.mu.Unlock()
+		return res.principal, res.err
+	}
+	a.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, reviewTimeout)
+	defer cancel()
+	status, err := a.reviewer.Review(ctx, token, a.audiences)
+	if err != nil {
+		// API server errors are not cached: the token may well be valid.
+		slog.Warn("apiserver: TokenReview failed", "error", err)
+		return nil, fmt.Errorf("token review unavailable: %w", err)
+	}
+
+	res := reviewResult{expires: now.Add(reviewCacheTTL)}
+	if !status.Authenticated {
+		res.err = errInvalidToken
+		res.expires = now.Add(reviewNegativeTTL)
+	} else {
+		res.principal = &Principal{
+			Name:   status.User.Username,
+			Groups: status.User.Groups,
+			Role:   a.roleFor(status.User.Username, status.User.Groups),
+			Method: MethodTokenReview,
+		}
+	}
+
+	a.mu.Lock()
+	if len(a.cache) >= maxReviewCache {
+		for k, v := range a.cache {
+			if now.After(v.expires) {
+				delete(a.cache, k)
+			}
+		}
+	}
+	if len(a.cache) < maxReviewCache {
+		a.cache[key] = res
+	}
+	a.mu.Unlock()
+	return res.principal, res.err
+}
+
+// roleFor maps a TokenReview identity to a role: an explicit user mapping
+// wins, then the highest group mapping, then the default role.
+func (a *Authenticator) roleFor(user string, groups []string) Role {
+	if role, ok := a.userRoles[user]; ok {
+		return role
+	}
+	best := RoleNone
+	for _, g := range groups {
+		if role := a.groupRoles[g]; role > best {
+			best = role
+		}
+	}
+	if best != RoleNone {
+		return best
+	}
+	return a.defaultRole
+}
+
+// Middleware authenticates every request and stores the principal in the
+// request context. With auth disabled every request runs as an anonymous
+// admin, preserving the open API of earlier releases.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.enabled {
+			p := &Principal{Name: MethodAnonymous, Role: RoleAdmin, Method: MethodAnonymous}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+			return
+		}
+		p, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="koptimizer"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if p.Role == RoleNone {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("principal %q has no API role", p.Name))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+// Require rejects requests whose principal has a role below min.
+func Require(min Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := PrincipalFrom(r.Context())
+			if p == nil {
+				writeError(w, http.StatusUnauthorized, errMissingToken.Error())
+				return
+			}
+			if p.Role < min {
+				writeError(w, http.StatusForbidden, fmt.Sprintf("role %s cannot access this endpoint, requires %s", p.Role, min))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
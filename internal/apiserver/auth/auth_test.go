@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authv1 "k8s.io/api/authentication/v1"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+type fakeReviewer struct {
+	users map[string]authv1.UserInfo // token -> identity
+	err   error
+	calls int
+}
+
+func (f *fakeReviewer) Review(_ context.Context, token string, _ []string) (*authv1.TokenReviewStatus, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	user, ok := f.users[token]
+	return &authv1.TokenReviewStatus{Authenticated: ok, User: user}, nil
+}
+
+func testAuthConfig() config.APIAuthConfig {
+	return config.APIAuthConfig{
+		Enabled: true,
+		Tokens: []config.APITokenConfig{
+			{Name: "dashboard", Token: "admin-token", Role: "admin"},
+			{Name: "grafana", Token: "viewer-token", Role: "viewer"},
+		},
+		TokenReview: true,
+		UserRoles:   map[string]string{"system:serviceaccount:ci:deployer": "operator"},
+		GroupRoles:  map[string]string{"sre": "operator", "finops": "approver"},
+	}
+}
+
+func testReviewer() *fakeReviewer {
+	return &fakeReviewer{users: map[string]authv1.UserInfo{
+		"sa-token":     {Username: "system:serviceaccount:ci:deployer"},
+		"alice-token":  {Username: "alice", Groups: []string{"finops", "sre"}},
+		"bob-token":    {Username: "bob", Groups: []string{"finops"}},
+		"nobody-token": {Username: "mallory"},
+	}}
+}
+
+func request(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/config", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+// ---------------------------------------------------------------------------
+// Authenticator Tests
+// ---------------------------------------------------------------------------
+
+func TestAuthenticate(t *testing.T) {
+	a, err := NewAuthenticator(testAuthConfig(), testReviewer())
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		wantName   string
+		wantRole   Role
+		wantMethod string
+		wantErr    bool
+	}{
+		{name: "static admin token", header: "Bearer admin-token", wantName: "dashboard", wantRole: RoleAdmin, wantMethod: MethodToken},
+		{name: "static viewer token", header: "Bearer viewer-token", wantName: "grafana", wantRole: RoleViewer, wantMethod: MethodToken},
+		{name: "lowercase scheme", header: "bearer viewer-token", wantName: "grafana", wantRole: RoleViewer, wantMethod: MethodToken},
+		{name: "user mapping", header: "Bearer sa-token", wantName: "system:serviceaccount:ci:deployer", wantRole: RoleOperator, wantMethod: MethodTokenReview},
+		{name: "highest group wins", header: "Bearer alice-token", wantName: "alice", wantRole: RoleOperator, wantMethod: MethodTokenReview},
+		{name: "single group", header: "Bearer bob-token", wantName: "bob", wantRole: RoleApprover, wantMethod: MethodTokenReview},
+		{name: "no mapping and no default", header: "Bearer nobody-token", wantName: "mallory", wantRole: RoleNone, wantMethod: MethodTokenReview},
+		{name: "unknown token", header: "Bearer nope", wantErr: true},
+		{name: "missing header", header: "", wantErr: true},
+		{name: "basic auth", header: "Basic YWRtaW46cGFzcw==", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			p, err := a.Authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if p.Name != tt.wantName || p.Role != tt.wantRole || p.Method != tt.wantMethod {
+				t.Errorf("got %s/%s/%s, want %s/%s/%s", p.Name, p.Role, p.Method, tt.wantName, tt.wantRole, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_DefaultRole(t *testing.T) {
+	cfg := testAuthConfig()
+	cfg.DefaultRole = "viewer"
+	a, _ := NewAuthenticator(cfg, testReviewer())
+	p, err := a.Authenticate(request("nobody-token"))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if p.Role != RoleViewer {
+		t.Errorf("role = %s, want viewer", p.Role)
+	}
+}
+
+func TestAuthenticate_ReviewCache(t *testing.T) {
+	reviewer := testReviewer()
+	a, _ := NewAuthenticator(testAuthConfig(), reviewer)
+	for i := 0; i < 3; i++ {
+		if _, err := a.Authenticate(request("bob-token")); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		a.Authenticate(request("unknown"))
+	}
+	if reviewer.calls != 2 {
+		t.Errorf("TokenReview calls = %d, want 2 (one per distinct token)", reviewer.calls)
+	}
+
+	// API server errors are not cached.
+	reviewer.err = errors.New("connection refused")
+	a2, _ := NewAuthenticator(testAuthConfig(), reviewer)
+	a2.Authenticate(request("bob-token"))
+	a2.Authenticate(request("bob-token"))
+	if reviewer.calls != 4 {
+		t.Errorf("TokenReview calls = %d, want 4 after uncached failures", reviewer.calls)
+	}
+}
+
+func TestNewAuthenticator_TokenReviewWithoutClient(t *testing.T) {
+	if _, err := NewAuthenticator(testAuthConfig(), nil); err == nil {
+		t.Error("expected error when tokenReview is enabled without a reviewer")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Middleware Tests
+// ---------------------------------------------------------------------------
+
+func TestMiddleware_RoleEnforcement(t *testing.T) {
+	a, _ := NewAuthenticator(testAuthConfig(), testReviewer())
+
+	var gotUser string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = User(r.Context(), "fallback")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		token    string
+		required Role
+		want     int
+		wantUser string
+	}{
+		{name: "no token", token: "", required: RoleViewer, want: http.StatusUnauthorized},
+		{name: "bad token", token: "nope", required: RoleViewer, want: http.StatusUnauthorized},
+		{name: "no role", token: "nobody-token", required: RoleViewer, want: http.StatusForbidden},
+		{name: "viewer reads", token: "viewer-token", required: RoleViewer, want: http.StatusNoContent, wantUser: "grafana"},
+		{name: "viewer cannot approve", token: "viewer-token", required: RoleApprover, want: http.StatusForbidden},
+		{name: "approver approves", token: "bob-token", required: RoleApprover, want: http.StatusNoContent, wantUser: "bob"},
+		{name: "approver cannot operate", token: "bob-token", required: RoleOperator, want: http.StatusForbidden},
+		{name: "operator cannot configure", token: "sa-token", required: RoleAdmin, want: http.StatusForbidden},
+		{name: "admin configures", token: "admin-token", required: RoleAdmin, want: http.StatusNoContent, wantUser: "dashboard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUser = ""
+			h := a.Middleware(Require(tt.required)(ok))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request(tt.token))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+			if gotUser != tt.wantUser {
+				t.Errorf("audit user = %q, want %q", gotUser, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Disabled(t *testing.T) {
+	a, _ := NewAuthenticator(config.APIAuthConfig{}, nil)
+
+	var gotUser string
+	h := a.Middleware(Require(RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = User(r.Context(), "dashboard")
+	})))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request(""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 with auth disabled", w.Code)
+	}
+	if gotUser != "dashboard" {
+		t.Errorf("audit user = %q, want fallback for anonymous requests", gotUser)
+	}
+}
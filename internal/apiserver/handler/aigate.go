@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/store"
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+)
+
+const (
+	defaultReplayLimit = 20
+	maxReplayLimit     = 200
+	// replayWriteTimeout replaces the server's write timeout for replays,
+	// which wait on a model call per decision.
+	replayWriteTimeout = 15 * time.Minute
+)
+
+// AIGateHandler serves the AI Gate decision history and replays.
+type AIGateHandler struct {
+	config *config.Config
+	store  *store.AIGateStore
+}
+
+// NewAIGateHandler creates a new AIGateHandler.
+func NewAIGateHandler(cfg *config.Config, st *store.AIGateStore) *AIGateHandler {
+	return &AIGateHandler{config: cfg, store: st}
+}
+
+// ListDecisions returns recorded decisions, newest first. Query parameters:
+// since (a duration such as 24h), recommendationId, validator, outcome,
+// approved and limit (default 1000).
+func (h *AIGateHandler) ListDecisions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.AIGateDecisionFilter{
+		RecommendationID: q.Get("recommendationId"),
+		Validator:        q.Get("validator"),
+		Outcome:          q.Get("outcome"),
+		Limit:            1000,
+	}
+	if v := q.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be a positive duration, e.g. 24h"})
+			return
+		}
+		filter.Since = time.Now().Add(-d)
+	}
+	if v := q.Get("approved"); v != "" {
+		approved, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "approved must be true or false"})
+			return
+		}
+		filter.Approved = &approved
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	decisions := h.store.List(filter)
+	if decisions == nil {
+		decisions = []aigate.Decision{}
+	}
+	page, pageSize := parsePagination(r)
+	start, end, resp := paginateSlice(len(decisions), page, pageSize)
+	resp.Data = decisions[start:end]
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetDecision returns one recorded decision with its prompt and model output.
+func (h *AIGateHandler) GetDecision(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid decision id"})
+		return
+	}
+	d, ok := h.store.Get(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "decision not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// replayRequest selects recorded decisions and the gate to replay them
+// against. Unset gate fields keep the configured value.
+type replayRequest struct {
+	IDs       []int64 `json:"ids"`
+	Since     string  `json:"since"`
+	Validator string  `json:"validator"` // Only decisions made by this validator
+	Outcome   string  `json:"outcome"`
+	Limit     int     `json:"limit"`
+
+	Validators   []string `json:"validators"`
+	Model        string   `json:"model"`
+	SystemPrompt string   `json:"systemPrompt"`
+	OpenAI       *struct {
+		BaseURL string `json:"baseURL"`
+		Model   string `json:"model"`
+	} `json:"openai"`
+	Rules []struct {
+		Name     string `json:"name"`
+		When     string `json:"when"`
+		Decision string `json:"decision"`
+		Reason   string `json:"reason"`
+	} `json:"rules"`
+}
+
+// Replay asks a gate built from the request to decide recorded requests
+// again and returns the diff against the recorded decisions.
+func (h *AIGateHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	var req replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+	filter := store.AIGateDecisionFilter{
+		IDs:       req.IDs,
+		Validator: req.Validator,
+		Outcome:   req.Outcome,
+		Limit:     req.Limit,
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultReplayLimit
+	}
+	if filter.Limit > maxReplayLimit {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be at most %d", maxReplayLimit)})
+		return
+	}
+	if req.Since != "" {
+		d, err := time.ParseDuration(req.Since)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be a positive duration, e.g. 168h"})
+			return
+		}
+		filter.Since = time.Now().Add(-d)
+	}
+
+	gate, err := aigate.NewAIGate(h.replayConfig(req))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	decisions := h.store.List(filter)
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(replayWriteTimeout))
+	writeJSON(w, http.StatusOK, aigate.Replay(r.Context(), gate, decisions))
+}
+
+// replayConfig returns the configured gate with the overrides of req. The
+// configured OpenAI API key is only sent to the configured base URL.
+func (h *AIGateHandler) replayConfig(req replayRequest) aigate.Config {
+	c := h.config.AIGate
+	cfg := aigate.Config{
+		Enabled:      true,
+		Model:        c.Model,
+		Timeout:      c.Timeout,
+		Validators:   c.Validators,
+		SystemPrompt: c.SystemPrompt,
+		OpenAI: aigate.OpenAIConfig{
+			BaseURL: c.OpenAI.BaseURL,
+			Model:   c.OpenAI.Model,
+			APIKey:  c.OpenAI.APIKey,
+		},
+	}
+	for _, r := range c.Rules {
+		cfg.Rules = append(cfg.Rules, aigate.Rule{Name: r.Name, When: r.When, Decision: r.Decision, Reason: r.Reason})
+	}
+
+	if len(req.Validators) > 0 {
+		cfg.Validators = req.Validators
+	}
+	if req.Model != "" {
+		cfg.Model = req.Model
+	}
+	if req.SystemPrompt != "" {
+		cfg.SystemPrompt = req.SystemPrompt
+	}
+	if req.OpenAI != nil {
+		if req.OpenAI.BaseURL != "" && strings.TrimSuffix(req.OpenAI.BaseURL, "/") != strings.TrimSuffix(c.OpenAI.BaseURL, "/") {
+			cfg.OpenAI.BaseURL = req.OpenAI.BaseURL
+			cfg.OpenAI.APIKey = ""
+		}
+		if req.OpenAI.Model != "" {
+			cfg.OpenAI.Model = req.OpenAI.Model
+		}
+	}
+	if req.Rules != nil {
+		cfg.Rules = nil
+		for _, r := range req.Rules {
+			cfg.Rules = append(cfg.Rules, aigate.Rule{Name: r.Name, When: r.When, Decision: r.Decision, Reason: r.Reason})
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/koptimizer/koptimizer/internal/controller/costmonitor"
+	"github.com/koptimizer/koptimizer/internal/store"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+)
+
+// GetAllocation returns the live cost allocation with idle and shared
+// buckets. The configured options can be overridden per request with
+// sharedStrategy, usageWeight and sharedNamespaces (comma-separated, empty
+// for none) to compare allocation models.
+func (h *CostHandler) GetAllocation(w http.ResponseWriter, r *http.Request) {
+	h.config.Mu.RLock()
+	opts := h.config.CostMonitor.Allocation
+	opts.SharedNamespaces = append([]string(nil), opts.SharedNamespaces...)
+	h.config.Mu.RUnlock()
+
+	q := r.URL.Query()
+	if v := q.Get("sharedStrategy"); v != "" {
+		switch v {
+		case cost.SharedProportional, cost.SharedEven, cost.SharedNone:
+			opts.SharedStrategy = v
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "sharedStrategy must be proportional, even or none"})
+			return
+		}
+	}
+	if v := q.Get("usageWeight"); v != "" {
+		weight, err := strconv.ParseFloat(v, 64)
+		if err != nil || weight < 0 || weight > 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "usageWeight must be a number between 0 and 1"})
+			return
+		}
+		opts.UsageWeight = weight
+	}
+	if _, ok := q["sharedNamespaces"]; ok {
+		opts.SharedNamespaces = nil
+		for _, ns := range strings.Split(q.Get("sharedNamespaces"), ",") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				opts.SharedNamespaces = append(opts.SharedNamespaces, ns)
+			}
+		}
+	}
+
+	allocation, err := costmonitor.NewAllocator(h.provider).Allocate(r.Context(), h.state.Snapshot(), opts)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if allocation.Namespaces == nil {
+		allocation.Namespaces = []cost.NamespaceAllocation{}
+	}
+	if allocation.Shared == nil {
+		allocation.Shared = []cost.NamespaceAllocation{}
+	}
+	if allocation.Idle == nil {
+		allocation.Idle = []cost.IdleAllocation{}
+	}
+	writeJSON(w, http.StatusOK, allocation)
+}
+
+// allocationDay is one day of GET /cost/allocation/history.
+type allocationDay struct {
+	Date                string                   `json:"date"`
+	TotalMonthlyCostUSD float64                  `json:"totalMonthlyCostUSD"`
+	TenantCostUSD       float64                  `json:"tenantCostUSD"`
+	SharedCostUSD       float64                  `json:"sharedCostUSD"` // shared cost not redistributed
+	IdleCostUSD         float64                  `json:"idleCostUSD"`
+	Namespaces          []store.AllocationRecord `json:"namespaces"`
+	Shared              []store.AllocationRecord `json:"shared"`
+	Idle                []store.AllocationRecord `json:"idle"`
+}
+
+// GetAllocationHistory returns the daily allocations persisted by the cost
+// monitor for the last ?days (default 30).
+func (h *CostHandler) GetAllocationHistory(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+
+	result := []*allocationDay{}
+	var day *allocationDay
+	for _, rec := range h.costStore.GetAllocationHistory(days) {
+		if day == nil || day.Date != rec.Date {
+			day = &allocationDay{
+				Date:       rec.Date,
+				Namespaces: []store.AllocationRecord{},
+				Shared:     []store.AllocationRecord{},
+				Idle:       []store.AllocationRecord{},
+			}
+			result = append(result, day)
+		}
+		day.TotalMonthlyCostUSD += rec.MonthlyCostUSD
+		switch rec.Bucket {
+		case store.AllocationBucketNamespace:
+			day.TenantCostUSD += rec.MonthlyCostUSD
+			day.Namespaces = append(day.Namespaces, rec)
+		case store.AllocationBucketShared:
+			day.SharedCostUSD += rec.MonthlyCostUSD
+			day.Shared = append(day.Shared, rec)
+		case store.AllocationBucketIdle:
+			day.IdleCostUSD += rec.MonthlyCostUSD
+			day.Idle = append(day.Idle, rec)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"days": result})
+}
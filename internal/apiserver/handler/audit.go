@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/koptimizer/koptimizer/internal/apiserver/auth"
 	"github.com/koptimizer/koptimizer/internal/state"
 )
 
@@ -49,10 +50,13 @@ func (h *AuditHandler) Record(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "action is required"})
 		return
 	}
-	if req.User == "" {
-		req.User = "dashboard"
+	// The authenticated principal is authoritative; the body's user is
+	// only used when API auth is disabled.
+	fallback := req.User
+	if fallback == "" {
+		fallback = "dashboard"
 	}
-	h.auditLog.Record(req.Action, req.Target, req.User, req.Details)
+	h.auditLog.Record(req.Action, req.Target, auth.User(r.Context(), fallback), req.Details)
 	writeJSON(w, http.StatusCreated, map[string]string{"status": "recorded"})
 }
 
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/koptimizer/koptimizer/internal/chargeback"
+)
+
+// GetChargeback returns the chargeback report for ?month=YYYY-MM (default:
+// current month to date) grouped by ?groupBy=namespace|team|costCenter.
+// ?format=csv or ?format=focus downloads the report as a file instead of
+// JSON.
+func (h *CostHandler) GetChargeback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	month := time.Now()
+	if m := q.Get("month"); m != "" {
+		parsed, err := time.Parse("2006-01", m)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "month must be formatted as YYYY-MM"})
+			return
+		}
+		month = parsed
+	}
+	groupBy := q.Get("groupBy")
+	switch groupBy {
+	case "":
+		groupBy = chargeback.GroupByNamespace
+	case chargeback.GroupByNamespace, chargeback.GroupByTeam, chargeback.GroupByCostCenter:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "groupBy must be namespace, team or costCenter"})
+		return
+	}
+	format := q.Get("format")
+	switch format {
+	case "", "json", chargeback.FormatCSV, chargeback.FormatFOCUS:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json, csv or focus"})
+		return
+	}
+
+	report, err := chargeback.NewGenerator(h.costStore, h.client, h.config).Generate(r.Context(), month, groupBy)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if format == "" || format == "json" {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	data, err := chargeback.Render(report, format)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", chargeback.FileName(report, format)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		slog.Error("chargeback: failed to write report", "error", err)
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
 	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/hub"
 	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
 	"github.com/koptimizer/koptimizer/internal/state"
 	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
@@ -23,10 +26,11 @@ type ClusterHandler struct {
 	config       *config.Config
 	client       client.Client
 	metricsStore *intmetrics.Store
+	hub          *hub.Poller // nil unless hub mode is enabled
 }
 
-func NewClusterHandler(st *state.ClusterState, provider cloudprovider.CloudProvider, cfg *config.Config, c client.Client, metricsStore *intmetrics.Store) *ClusterHandler {
-	return &ClusterHandler{state: st, provider: provider, config: cfg, client: c, metricsStore: metricsStore}
+func NewClusterHandler(st *state.ClusterState, provider cloudprovider.CloudProvider, cfg *config.Config, c client.Client, metricsStore *intmetrics.Store, fleet *hub.Poller) *ClusterHandler {
+	return &ClusterHandler{state: st, provider: provider, config: cfg, client: c, metricsStore: metricsStore, hub: fleet}
 }
 
 func (h *ClusterHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
@@ -450,8 +454,70 @@ func roundTo1(f float64) float64 {
 	return math.Round(f*10) / 10
 }
 
-// GetClusters returns cluster info wrapped in {"clusters": [...]}.
+// GetClusters returns cluster info wrapped in {"clusters": [...]}. In hub
+// mode the list also holds every registered remote cluster with its cached
+// summary and poll status; ?scope=local returns only this cluster, which is
+// what hubs poll.
 func (h *ClusterHandler) GetClusters(w http.ResponseWriter, r *http.Request) {
+	clusters := []hub.ClusterSummary{h.localCluster(r.Context())}
+	if h.hub != nil && r.URL.Query().Get("scope") != "local" {
+		clusters = append(clusters, h.hub.Clusters()...)
+	}
+	hub.SortClusters(clusters)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"clusters": clusters,
+		"hub":      h.hub != nil,
+	})
+}
+
+// GetClusterRollup returns fleet-wide cost, savings and efficiency totals.
+func (h *ClusterHandler) GetClusterRollup(w http.ResponseWriter, r *http.Request) {
+	clusters := []hub.ClusterSummary{h.localCluster(r.Context())}
+	if h.hub != nil {
+		clusters = append(clusters, h.hub.Clusters()...)
+	}
+	writeJSON(w, http.StatusOK, hub.BuildRollup(clusters, time.Now()))
+}
+
+// GetCluster returns one cluster of the fleet. For remote clusters the
+// response includes the cached snapshot history of the last ?hours=24.
+func (h *ClusterHandler) GetCluster(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	local := h.localCluster(r.Context())
+	if id == local.ID {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"cluster": local})
+		return
+	}
+	if h.hub == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "cluster not found"})
+		return
+	}
+	summary, ok := h.hub.Cluster(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "cluster not found"})
+		return
+	}
+
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 24*90 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "hours must be between 1 and 2160"})
+			return
+		}
+		hours = n
+	}
+	history := h.hub.History(id, time.Now().Add(-time.Duration(hours)*time.Hour))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"cluster": summary,
+		"history": history,
+	})
+}
+
+// localCluster summarizes the cluster this instance runs in.
+func (h *ClusterHandler) localCluster(ctx context.Context) hub.ClusterSummary {
 	nodes := h.state.GetAllNodes()
 	pods := h.state.GetAllPods()
 
@@ -477,7 +543,7 @@ func (h *ClusterHandler) GetClusters(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch potential savings from Recommendation CRDs
 	potentialSavings := 0.0
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	var recList koptv1alpha1.RecommendationList
 	if err := h.client.List(ctx, &recList, client.InNamespace("koptimizer-system"), client.Limit(500)); err == nil {
@@ -493,21 +559,23 @@ func (h *ClusterHandler) GetClusters(w http.ResponseWriter, r *http.Request) {
 		clusterName = "default"
 	}
 
-	cluster := map[string]interface{}{
-		"id":               clusterName,
-		"name":             clusterName,
-		"provider":         h.config.CloudProvider,
-		"region":           h.config.Region,
-		"version":          k8sVersion,
-		"nodeCount":        len(nodes),
-		"podCount":         len(pods),
-		"monthlyCostUSD":   totalCost * cost.HoursPerMonth,
-		"potentialSavings": potentialSavings,
-		"efficiencyScore":  roundTo1(efficiencyScore),
-		"status":           "healthy",
+	now := time.Now()
+	return hub.ClusterSummary{
+		ID:                   clusterName,
+		Name:                 clusterName,
+		Source:               hub.SourceLocal,
+		Provider:             h.config.CloudProvider,
+		Region:               h.config.Region,
+		Version:              k8sVersion,
+		Mode:                 h.config.GetMode(),
+		NodeCount:            len(nodes),
+		PodCount:             len(pods),
+		MonthlyCostUSD:       totalCost * cost.HoursPerMonth,
+		PotentialSavings:     potentialSavings,
+		EfficiencyScore:      roundTo1(efficiencyScore),
+		CPUUtilizationPct:    roundTo1(cpuUtil),
+		MemoryUtilizationPct: roundTo1(memUtil),
+		Status:               hub.StatusHealthy,
+		LastUpdated:          &now,
 	}
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"clusters": []map[string]interface{}{cluster},
-	})
 }
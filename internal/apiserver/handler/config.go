@@ -37,6 +37,8 @@ func (h *ConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
 			"commitments":    h.config.Commitments.Enabled,
 			"aiGate":         h.config.AIGate.Enabled,
 			"podPurger":      h.config.PodPurger.Enabled,
+			"evictor":        h.config.Evictor.Enabled,
+			"rebalancer":     h.config.Rebalancer.Enabled,
 		},
 		"autoApprove": map[string]bool{
 			"rightsizer": h.config.Rightsizer.AutoApprove,
@@ -12,6 +12,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
+	"github.com/koptimizer/koptimizer/internal/config"
 	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
 	"github.com/koptimizer/koptimizer/internal/state"
 	"github.com/koptimizer/koptimizer/internal/store"
@@ -20,6 +21,7 @@ import (
 )
 
 type CostHandler struct {
+	config       *config.Config
 	state        *state.ClusterState
 	provider     cloudprovider.CloudProvider
 	client       client.Client
@@ -27,8 +29,8 @@ type CostHandler struct {
 	metricsStore *intmetrics.Store
 }
 
-func NewCostHandler(st *state.ClusterState, provider cloudprovider.CloudProvider, c client.Client, costStore *store.CostStore, metricsStore *intmetrics.Store) *CostHandler {
-	return &CostHandler{state: st, provider: provider, client: c, costStore: costStore, metricsStore: metricsStore}
+func NewCostHandler(cfg *config.Config, st *state.ClusterState, provider cloudprovider.CloudProvider, c client.Client, costStore *store.CostStore, metricsStore *intmetrics.Store) *CostHandler {
+	return &CostHandler{config: cfg, state: st, provider: provider, client: c, costStore: costStore, metricsStore: metricsStore}
 }
 
 func (h *CostHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
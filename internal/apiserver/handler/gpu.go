@@ -39,6 +39,10 @@ func (h *GPUHandler) GetNodes(w http.ResponseWriter, r *http.Request) {
 				"isScavenging":  hasAnnotation(n.Node, "koptimizer.io/cpu-scavenger"),
 				"hasTaint":      hasNoScheduleTaint(n.Node, "nvidia.com/gpu"),
 			}
+			if n.GPUMeasured {
+				entry["gpuUtilPct"] = n.GPUUtilization
+				entry["gpuMemoryUsedBytes"] = n.GPUMemoryUsed
+			}
 			if v, ok := n.Node.Annotations["koptimizer.io/scavenger-cpu-millis"]; ok {
 				entry["cpuHeadroomMillis"] = v
 			}
@@ -65,6 +69,7 @@ func (h *GPUHandler) GetNodes(w http.ResponseWriter, r *http.Request) {
 			"idleDuration":                 gpuCfg.IdleDuration.String(),
 			"scavengingCPUThresholdMillis": gpuCfg.ScavengingCPUThresholdMillis,
 			"reclaimGracePeriod":           gpuCfg.ReclaimGracePeriod.String(),
+			"metricsSource":                gpuCfg.MetricsSource,
 			"mode":                         h.config.GetMode(),
 		},
 		"total":    len(gpuNodes),
@@ -78,21 +83,33 @@ func (h *GPUHandler) GetUtilization(w http.ResponseWriter, r *http.Request) {
 	nodes := h.state.GetAllNodes()
 	totalGPUs := 0
 	usedGPUs := 0
+	measuredGPUs := 0
+	measuredUtilSum := 0.0
 	for _, n := range nodes {
 		if n.IsGPUNode {
 			totalGPUs += n.GPUCapacity
 			usedGPUs += n.GPUsUsed
+			if n.GPUMeasured {
+				measuredGPUs += n.GPUCapacity
+				measuredUtilSum += n.GPUUtilization * float64(n.GPUCapacity)
+			}
 		}
 	}
 	utilPct := 0.0
 	if totalGPUs > 0 {
 		utilPct = float64(usedGPUs) / float64(totalGPUs) * 100
 	}
-	writeJSON(w, http.StatusOK, map[string]interface{}{
+	result := map[string]interface{}{
 		"totalGPUs":      totalGPUs,
 		"usedGPUs":       usedGPUs,
 		"utilizationPct": utilPct,
-	})
+	}
+	// Measured SM utilization, weighted by GPU count, for nodes with DCGM data.
+	if measuredGPUs > 0 {
+		result["measuredGPUs"] = measuredGPUs
+		result["measuredUtilizationPct"] = measuredUtilSum / float64(measuredGPUs)
+	}
+	writeJSON(w, http.StatusOK, result)
 }
 
 func (h *GPUHandler) GetRecommendations(w http.ResponseWriter, r *http.Request) {
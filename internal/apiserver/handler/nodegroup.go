@@ -6,6 +6,7 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
 	"github.com/koptimizer/koptimizer/pkg/familylock"
 )
 
@@ -47,7 +48,7 @@ func (h *NodeGroupHandler) List(w http.ResponseWriter, r *http.Request) {
 			totalGPUs += n.GPUCapacity
 		}
 
-		result = append(result, map[string]interface{}{
+		entry := map[string]interface{}{
 			"id":             g.ID,
 			"name":           g.Name,
 			"instanceType":   g.InstanceType,
@@ -71,7 +72,9 @@ func (h *NodeGroupHandler) List(w http.ResponseWriter, r *http.Request) {
 			"diskSizeGB":     g.DiskSizeGB,
 			"hasGPU":         totalGPUs > 0,
 			"totalGPUs":      totalGPUs,
-		})
+		}
+		addSourceFields(entry, g)
+		result = append(result, entry)
 	}
 	if result == nil {
 		result = []map[string]interface{}{}
@@ -86,7 +89,7 @@ func (h *NodeGroupHandler) Get(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node group not found"})
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]interface{}{
+	entry := map[string]interface{}{
 		"id":             g.ID,
 		"name":           g.Name,
 		"instanceType":   g.InstanceType,
@@ -105,7 +108,34 @@ func (h *NodeGroupHandler) Get(w http.ResponseWriter, r *http.Request) {
 		"monthlyCostUSD": g.MonthlyCostUSD,
 		"diskType":       g.DiskType,
 		"diskSizeGB":     g.DiskSizeGB,
-	})
+	}
+	addSourceFields(entry, g)
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// addSourceFields adds the node group source and, for Karpenter NodePools,
+// the pool's limits, disruption budgets and permitted instance families.
+func addSourceFields(entry map[string]interface{}, g *state.NodeGroupInfo) {
+	source := g.Source
+	if source == "" {
+		source = "cloud"
+	}
+	entry["source"] = source
+	if g.Source != cloudprovider.NodeGroupSourceKarpenter {
+		return
+	}
+	limits := make(map[string]string, len(g.Limits))
+	for k, q := range g.Limits {
+		limits[string(k)] = q.String()
+	}
+	budgets := g.DisruptionBudgets
+	if budgets == nil {
+		budgets = []cloudprovider.DisruptionBudget{}
+	}
+	entry["limits"] = limits
+	entry["disruptionBudgets"] = budgets
+	entry["allowedFamilies"] = g.AllowedFamilies
+	entry["consolidationPolicy"] = g.ConsolidationPolicy
 }
 
 func (h *NodeGroupHandler) GetNodes(w http.ResponseWriter, r *http.Request) {
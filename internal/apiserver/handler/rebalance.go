@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/koptimizer/koptimizer/internal/controller/rebalancer"
+)
+
+type RebalanceHandler struct {
+	controller *rebalancer.Controller
+}
+
+func NewRebalanceHandler(c *rebalancer.Controller) *RebalanceHandler {
+	return &RebalanceHandler{controller: c}
+}
+
+// GetReport returns the projected and achieved savings of the most recent
+// scheduled rebalance run.
+func (h *RebalanceHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	if h.controller == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "rebalancer is disabled"})
+		return
+	}
+	report := h.controller.LastReport()
+	if report == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no rebalance has run yet"})
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -16,6 +16,7 @@ import (
 	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
 	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
 	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
 )
 
 type RecommendationHandler struct {
@@ -63,7 +64,7 @@ func (h *RecommendationHandler) List(w http.ResponseWriter, r *http.Request) {
 		if status == "" {
 			status = "pending"
 		}
-		result = append(result, map[string]interface{}{
+		item := map[string]interface{}{
 			"id":               rec.Name,
 			"type":             rec.Spec.Type,
 			"target":           target,
@@ -73,11 +74,56 @@ func (h *RecommendationHandler) List(w http.ResponseWriter, r *http.Request) {
 			"priority":         rec.Spec.Priority,
 			"createdAt":        rec.CreationTimestamp.Format(time.RFC3339),
 			"confidence":       0.90,
-		})
+		}
+		if changes := containerChanges(rec.Spec.Details); changes != nil {
+			item["containers"] = changes
+		}
+		result = append(result, item)
 	}
 	writePaginatedJSON(w, r, result)
 }
 
+// GetContainers returns the per-container diff of a rightsizing
+// recommendation: current and suggested requests for every container, and
+// why unchanged containers were left alone.
+func (h *RecommendationHandler) GetContainers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	id := chi.URLParam(r, "id")
+	var rec koptv1alpha1.Recommendation
+	if err := h.client.Get(ctx, types.NamespacedName{
+		Namespace: "koptimizer-system",
+		Name:      id,
+	}, &rec); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "recommendation not found", "id": id})
+		return
+	}
+	changes := containerChanges(rec.Spec.Details)
+	if changes == nil {
+		changes = []optimizer.ContainerChange{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":         rec.Name,
+		"target":     rec.Spec.TargetNamespace + "/" + rec.Spec.TargetName,
+		"containers": changes,
+	})
+}
+
+// containerChanges decodes the per-container diff stored in a
+// recommendation's details. Recommendations without one return nil.
+func containerChanges(details map[string]string) []optimizer.ContainerChange {
+	raw := details[optimizer.DetailContainers]
+	if raw == "" {
+		return nil
+	}
+	var changes []optimizer.ContainerChange
+	if err := json.Unmarshal([]byte(raw), &changes); err != nil {
+		slog.Warn("invalid per-container diff in recommendation details", "error", err)
+		return nil
+	}
+	return changes
+}
+
 func (h *RecommendationHandler) Get(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 	defer cancel()
@@ -1,15 +1,21 @@
 package apiserver
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/koptimizer/koptimizer/internal/apiserver/auth"
 	"github.com/koptimizer/koptimizer/internal/apiserver/handler"
 	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/controller/rebalancer"
 	"github.com/koptimizer/koptimizer/internal/helmdrift"
+	"github.com/koptimizer/koptimizer/internal/hub"
+	"github.com/koptimizer/koptimizer/internal/mcp"
 	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
 	"github.com/koptimizer/koptimizer/internal/state"
 	"github.com/koptimizer/koptimizer/internal/store"
@@ -17,14 +23,15 @@ import (
 	"github.com/koptimizer/koptimizer/pkg/familylock"
 )
 
-// NewRouter creates the API router with all endpoints.
-func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cloudprovider.CloudProvider, guard *familylock.FamilyLockGuard, k8sClient client.Client, costStore *store.CostStore, metricsStore *intmetrics.Store, settingsStore *store.SettingsStore) http.Handler {
+// NewRouter creates the API router with all endpoints. Every route requires
+// at least the viewer role; mutating routes require a higher role and are
+// recorded in the audit log under the caller's principal.
+func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cloudprovider.CloudProvider, guard *familylock.FamilyLockGuard, k8sClient client.Client, costStore *store.CostStore, metricsStore *intmetrics.Store, settingsStore *store.SettingsStore, aiGateStore *store.AIGateStore, helmDriftSvc *helmdrift.Service, rebalancerCtrl *rebalancer.Controller, authn *auth.Authenticator, fleet *hub.Poller) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.Throttle(100))
 
 	// Limit request body size to 1MB to prevent memory exhaustion.
 	r.Use(func(next http.Handler) http.Handler {
@@ -34,10 +41,10 @@ func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cl
 		})
 	})
 
-	clusterHandler := handler.NewClusterHandler(clusterState, provider, cfg, k8sClient, metricsStore)
+	clusterHandler := handler.NewClusterHandler(clusterState, provider, cfg, k8sClient, metricsStore, fleet)
 	nodeHandler := handler.NewNodeHandler(clusterState)
 	nodeGroupHandler := handler.NewNodeGroupHandler(clusterState, guard)
-	costHandler := handler.NewCostHandler(clusterState, provider, k8sClient, costStore, metricsStore)
+	costHandler := handler.NewCostHandler(cfg, clusterState, provider, k8sClient, costStore, metricsStore)
 	recHandler := handler.NewRecommendationHandler(clusterState, k8sClient, metricsStore)
 	workloadHandler := handler.NewWorkloadHandler(clusterState, k8sClient)
 	commitmentHandler := handler.NewCommitmentHandler(provider)
@@ -55,10 +62,20 @@ func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cl
 	scaledownHandler := handler.NewScaleDownBlockersHandler(clusterState, k8sClient)
 	overscaledHandler := handler.NewOverscaledHandler(clusterState, k8sClient)
 	inefficiencyHandler := handler.NewInefficiencyHandler(clusterState, k8sClient)
-	helmDriftSvc := helmdrift.NewService(cfg, clusterState)
 	helmDriftHandler := handler.NewHelmDriftHandler(helmDriftSvc)
+	aiGateHandler := handler.NewAIGateHandler(cfg, aiGateStore)
+	rebalanceHandler := handler.NewRebalanceHandler(rebalancerCtrl)
+
+	// Only the REST API is throttled: MCP streams are held open for as long
+	// as their clients stay connected.
+	api := r.With(middleware.Throttle(100)).Route("/api/v1", func(r chi.Router) {
+		r.Use(authn.Middleware)
+		r.Use(auth.Require(auth.RoleViewer))
+		audited := auditRequests(clusterState.AuditLog)
+		approver := r.With(auth.Require(auth.RoleApprover), audited)
+		operator := r.With(auth.Require(auth.RoleOperator), audited)
+		admin := r.With(auth.Require(auth.RoleAdmin), audited)
 
-	r.Route("/api/v1", func(r chi.Router) {
 		// Cluster
 		r.Get("/cluster/summary", clusterHandler.GetSummary)
 		r.Get("/cluster/health", clusterHandler.GetHealth)
@@ -85,6 +102,9 @@ func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cl
 		r.Get("/cost/network", networkHandler.GetCost)
 		r.Get("/cost/comparison", costHandler.GetComparison)
 		r.Get("/cost/impact", costHandler.GetImpact)
+		r.Get("/cost/allocation", costHandler.GetAllocation)
+		r.Get("/cost/allocation/history", costHandler.GetAllocationHistory)
+		r.Get("/cost/chargeback", costHandler.GetChargeback)
 
 		// Commitments
 		r.Get("/commitments", commitmentHandler.List)
@@ -95,11 +115,12 @@ func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cl
 		r.Get("/recommendations", recHandler.List)
 		r.Get("/recommendations/summary", recHandler.GetSummary)
 		r.Get("/recommendations/debug", recHandler.Debug)
-		r.Post("/recommendations/bulk-approve", recHandler.BulkApprove)
-		r.Post("/recommendations/bulk-dismiss", recHandler.BulkDismiss)
+		approver.Post("/recommendations/bulk-approve", recHandler.BulkApprove)
+		approver.Post("/recommendations/bulk-dismiss", recHandler.BulkDismiss)
 		r.Get("/recommendations/{id}", recHandler.Get)
-		r.Post("/recommendations/{id}/approve", recHandler.Approve)
-		r.Post("/recommendations/{id}/dismiss", recHandler.Dismiss)
+		r.Get("/recommendations/{id}/containers", recHandler.GetContainers)
+		approver.Post("/recommendations/{id}/approve", recHandler.Approve)
+		approver.Post("/recommendations/{id}/dismiss", recHandler.Dismiss)
 
 		// Workloads (literal routes BEFORE parameterized to avoid chi conflict)
 		r.Get("/workloads", workloadHandler.List)
@@ -124,14 +145,14 @@ func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cl
 
 		// Config
 		r.Get("/config", configHandler.Get)
-		r.Put("/config/mode", configHandler.SetMode)
-		r.Put("/config/pod-purger", configHandler.SetPodPurger)
-		r.Put("/config/controllers/{name}", configHandler.SetController)
-		r.Put("/config/controllers/{name}/auto-approve", configHandler.SetAutoApprove)
+		admin.Put("/config/mode", configHandler.SetMode)
+		admin.Put("/config/pod-purger", configHandler.SetPodPurger)
+		admin.Put("/config/controllers/{name}", configHandler.SetController)
+		admin.Put("/config/controllers/{name}/auto-approve", configHandler.SetAutoApprove)
 
 		// Audit
 		r.Get("/audit", auditHandler.List)
-		r.Post("/audit", auditHandler.Record)
+		r.With(auth.Require(auth.RoleOperator)).Post("/audit", auditHandler.Record)
 
 		// Diagnostics
 		r.Get("/diagnostics", clusterHandler.GetDiagnostics)
@@ -139,19 +160,21 @@ func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cl
 		// New endpoints
 		r.Get("/events", auditHandler.ListEvents)
 		r.Get("/clusters", clusterHandler.GetClusters)
+		r.Get("/clusters/rollup", clusterHandler.GetClusterRollup)
+		r.Get("/clusters/{id}", clusterHandler.GetCluster)
 		r.Get("/idle-resources", idleHandler.Get)
 		r.Get("/notifications", notifHandler.Get)
-		r.Post("/notifications/channels", notifHandler.AddChannel)
-		r.Put("/notifications/channels/{idx}", notifHandler.ToggleChannel)
-		r.Delete("/notifications/channels/{idx}", notifHandler.DeleteChannel)
+		admin.Post("/notifications/channels", notifHandler.AddChannel)
+		admin.Put("/notifications/channels/{idx}", notifHandler.ToggleChannel)
+		admin.Delete("/notifications/channels/{idx}", notifHandler.DeleteChannel)
 		r.Get("/policies", policyHandler.Get)
 		r.Get("/metrics", metricsHandler.Get)
 
 		// Actions
 		r.Get("/actions/bad-pods", actionsHandler.ListBadPods)
-		r.Post("/actions/delete-pods", actionsHandler.DeletePods)
+		operator.Post("/actions/delete-pods", actionsHandler.DeletePods)
 		r.Get("/actions/bad-replicasets", actionsHandler.ListBadReplicaSets)
-		r.Post("/actions/delete-replicasets", actionsHandler.DeleteReplicaSets)
+		operator.Post("/actions/delete-replicasets", actionsHandler.DeleteReplicaSets)
 
 		// Autoscaler
 		r.Get("/autoscaler/status", autoscalerHandler.GetStatus)
@@ -160,7 +183,7 @@ func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cl
 
 		// Scale-Down Blockers
 		r.Get("/scaledown/blockers", scaledownHandler.GetBlockers)
-		r.Post("/scaledown/delete-pdbs", scaledownHandler.DeletePDBs)
+		operator.Post("/scaledown/delete-pdbs", scaledownHandler.DeletePDBs)
 
 		// Cluster Inefficiencies
 		r.Get("/inefficiencies", inefficiencyHandler.Get)
@@ -168,7 +191,48 @@ func NewRouter(cfg *config.Config, clusterState *state.ClusterState, provider cl
 
 		// Helm Drift
 		r.Get("/helm-drift", helmDriftHandler.Get)
+
+		// Rebalancer
+		r.Get("/rebalance/report", rebalanceHandler.GetReport)
+
+		// AI Gate decision history and replays
+		r.Get("/aigate/decisions", aiGateHandler.ListDecisions)
+		r.Get("/aigate/decisions/{id}", aiGateHandler.GetDecision)
+		operator.Post("/aigate/replay", aiGateHandler.Replay)
 	})
 
+	// MCP over streamable HTTP. Tools and resources are served by the
+	// routes above in-process, with the caller's token and roles but
+	// without passing through the throttle and logger a second time.
+	if cfg.APIServer.MCP.Enabled {
+		mcpHandler := mcp.NewHTTPHandler(http.StripPrefix("/api/v1", api))
+		mcpHandler.SetPollInterval(cfg.APIServer.MCP.PollInterval)
+		r.With(authn.Middleware, auth.Require(auth.RoleViewer)).Handle("/mcp", mcpHandler)
+	}
+
 	return r
 }
+
+// auditRequests records successful requests in the audit log with the
+// authenticated principal as the user.
+func auditRequests(auditLog *state.AuditLog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= 400 {
+				return
+			}
+			role := auth.RoleAdmin
+			if p := auth.PrincipalFrom(r.Context()); p != nil {
+				role = p.Role
+			}
+			auditLog.Record("api-"+strings.ToLower(r.Method), r.URL.Path, auth.User(r.Context(), "anonymous"),
+				fmt.Sprintf("HTTP %d, role %s", status, role))
+		})
+	}
+}
@@ -7,7 +7,11 @@ import (
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/koptimizer/koptimizer/internal/apiserver/auth"
 	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/controller/rebalancer"
+	"github.com/koptimizer/koptimizer/internal/helmdrift"
+	"github.com/koptimizer/koptimizer/internal/hub"
 	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
 	"github.com/koptimizer/koptimizer/internal/state"
 	"github.com/koptimizer/koptimizer/internal/store"
@@ -16,8 +20,8 @@ import (
 )
 
 // NewServer creates a new HTTP server for the REST API.
-func NewServer(cfg *config.Config, clusterState *state.ClusterState, provider cloudprovider.CloudProvider, guard *familylock.FamilyLockGuard, k8sClient client.Client, costStore *store.CostStore, metricsStore *intmetrics.Store, settingsStore *store.SettingsStore) *http.Server {
-	router := NewRouter(cfg, clusterState, provider, guard, k8sClient, costStore, metricsStore, settingsStore)
+func NewServer(cfg *config.Config, clusterState *state.ClusterState, provider cloudprovider.CloudProvider, guard *familylock.FamilyLockGuard, k8sClient client.Client, costStore *store.CostStore, metricsStore *intmetrics.Store, settingsStore *store.SettingsStore, aiGateStore *store.AIGateStore, helmDriftSvc *helmdrift.Service, rebalancerCtrl *rebalancer.Controller, authn *auth.Authenticator, fleet *hub.Poller) *http.Server {
+	router := NewRouter(cfg, clusterState, provider, guard, k8sClient, costStore, metricsStore, settingsStore, aiGateStore, helmDriftSvc, rebalancerCtrl, authn, fleet)
 
 	return &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.APIServer.Address, cfg.APIServer.Port),
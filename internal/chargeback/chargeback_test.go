@@ -0,0 +1,517 @@
+package chargeback
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/store"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+type testStore struct {
+	t  *testing.T
+	db *store.DB
+}
+
+func openStore(t *testing.T) *testStore {
+	t.Helper()
+	db, err := store.Open(store.Config{Path: filepath.Join(t.TempDir(), "koptimizer.db")})
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &testStore{t: t, db: db}
+}
+
+func (s *testStore) exec(query string, args ...any) {
+	s.t.Helper()
+	if _, err := s.db.RawDB().Exec(query, args...); err != nil {
+		s.t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+func (s *testStore) total(date string, monthly float64) {
+	s.exec("INSERT INTO cost_snapshots (date, total_monthly_cost_usd) VALUES (?, ?)", date, monthly)
+}
+
+func (s *testStore) alloc(date, bucket, name string, direct, shared, monthly float64) {
+	s.exec("INSERT INTO cost_allocation (date, bucket, name, direct_cost_usd, shared_cost_usd, cost_usd) VALUES (?, ?, ?, ?, ?, ?)",
+		date, bucket, name, direct, shared, monthly)
+}
+
+func (s *testStore) namespace(date, ns string, monthly float64) {
+	s.exec("INSERT INTO cost_by_namespace (date, namespace, cost_usd) VALUES (?, ?, ?)", date, ns, monthly)
+}
+
+// seedDay records one reconciled day: 730.5/month so a day costs $24.
+func (s *testStore) seedDay(date string) {
+	s.total(date, 730.5)
+	s.alloc(date, store.AllocationBucketNamespace, "payments", 300, 50, 350)
+	s.alloc(date, store.AllocationBucketNamespace, "search", 150, 25, 175)
+	s.alloc(date, store.AllocationBucketNamespace, "web", 100, 0.5, 100.5)
+	s.alloc(date, store.AllocationBucketShared, "kube-system", 75.5, 0, 0)
+	s.alloc(date, store.AllocationBucketIdle, "general", 105, 0, 105)
+}
+
+func testConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.ClusterName = "prod"
+	cfg.CloudProvider = "aws"
+	return cfg
+}
+
+func namespaceObj(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+var september = time.Date(2026, time.September, 15, 0, 0, 0, 0, time.UTC)
+
+func findLine(r *Report, category, name string) *Line {
+	for i := range r.Lines {
+		if r.Lines[i].Category == category && r.Lines[i].Name == name {
+			return &r.Lines[i]
+		}
+	}
+	return nil
+}
+
+// assertReconciles checks the lines sum to the total to the cent.
+func assertReconciles(t *testing.T, r *Report) {
+	t.Helper()
+	var cents int64
+	for _, l := range r.Lines {
+		cents += int64(math.Round(l.CostUSD * 100))
+		if math.Abs(l.DirectCostUSD+l.SharedCostUSD-l.CostUSD) > 1e-9 {
+			t.Errorf("line %s/%s: direct %.2f + shared %.2f != cost %.2f", l.Category, l.Name, l.DirectCostUSD, l.SharedCostUSD, l.CostUSD)
+		}
+	}
+	if total := int64(math.Round(r.TotalCostUSD * 100)); cents != total {
+		t.Errorf("lines sum to %d cents, total is %d cents", cents, total)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Generate
+// ---------------------------------------------------------------------------
+
+func TestGenerate_ByNamespace(t *testing.T) {
+	st := openStore(t)
+	st.seedDay("2026-09-01")
+	st.seedDay("2026-09-02")
+	st.seedDay("2026-10-01") // outside the period
+
+	g := NewGenerator(store.NewCostStore(st.db.RawDB()), nil, testConfig())
+	r, err := g.Generate(context.Background(), september, GroupByNamespace)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if r.DaysCovered != 2 {
+		t.Errorf("daysCovered = %d, want 2", r.DaysCovered)
+	}
+	if !r.PeriodStart.Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)) || !r.PeriodEnd.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("period = %s..%s, want September", r.PeriodStart, r.PeriodEnd)
+	}
+	if r.TotalCostUSD != 48 {
+		t.Errorf("total = %.2f, want 48 (two days at $24)", r.TotalCostUSD)
+	}
+	payments := findLine(r, CategoryTenant, "payments")
+	if payments == nil {
+		t.Fatal("missing payments line")
+	}
+	// 350/month over two days = 350*48/730.5.
+	if want := math.Round(350*48/cost.HoursPerMonth*100) / 100; payments.CostUSD != want {
+		t.Errorf("payments = %.2f, want %.2f", payments.CostUSD, want)
+	}
+	if findLine(r, CategoryIdle, "general") == nil {
+		t.Error("missing idle line for node group general")
+	}
+	if r.Lines[0].Name != "payments" {
+		t.Errorf("first line = %q, want payments (tenants sorted by cost)", r.Lines[0].Name)
+	}
+	if findLine(r, CategoryUnallocated, CategoryUnallocated) != nil {
+		t.Error("reconciled days should not produce an unallocated line")
+	}
+	assertReconciles(t, r)
+}
+
+func TestGenerate_IdleNodeGroupsSharingAName(t *testing.T) {
+	st := openStore(t)
+	cs := store.NewCostStore(st.db.RawDB())
+	now := time.Now()
+	cs.RecordDailySnapshot(300, nil, nil)
+	cs.RecordDailyAllocation(&cost.Allocation{
+		Namespaces: []cost.NamespaceAllocation{{Namespace: "web", DirectCostUSD: 100, MonthlyCostUSD: 100}},
+		Idle: []cost.IdleAllocation{
+			{NodeGroupID: "nodepool/general", NodeGroup: "general", MonthlyCostUSD: 120},
+			{NodeGroupID: "asg-general-1a2b", NodeGroup: "general", MonthlyCostUSD: 80},
+		},
+	})
+
+	var idle []store.AllocationRecord
+	for _, rec := range cs.GetAllocationHistory(1) {
+		if rec.Bucket == store.AllocationBucketIdle {
+			idle = append(idle, rec)
+		}
+	}
+	if len(idle) != 2 {
+		t.Fatalf("idle rows = %+v, want one per node group", idle)
+	}
+	for _, rec := range idle {
+		if rec.DisplayName != "general" {
+			t.Errorf("idle row %s display name = %q, want general", rec.Name, rec.DisplayName)
+		}
+	}
+
+	r, err := NewGenerator(cs, nil, testConfig()).Generate(context.Background(), now, GroupByNamespace)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	n := 0
+	for _, l := range r.Lines {
+		if l.Category == CategoryIdle && l.Name == "general" {
+			n++
+		}
+	}
+	if n != 2 {
+		t.Errorf("idle lines named general = %d, want 2", n)
+	}
+	assertReconciles(t, r)
+}
+
+func TestGenerate_ByTeamLabel(t *testing.T) {
+	st := openStore(t)
+	st.seedDay("2026-09-01")
+
+	c := fake.NewClientBuilder().WithObjects(
+		namespaceObj("payments", map[string]string{"team": "money"}),
+		namespaceObj("search", map[string]string{"team": "money"}),
+		namespaceObj("web", nil),
+	).Build()
+	g := NewGenerator(store.NewCostStore(st.db.RawDB()), c, testConfig())
+
+	r, err := g.Generate(context.Background(), september, GroupByTeam)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if r.LabelKey != "team" {
+		t.Errorf("labelKey = %q, want team", r.LabelKey)
+	}
+	money := findLine(r, CategoryTenant, "money")
+	if money == nil {
+		t.Fatal("missing money team line")
+	}
+	if strings.Join(money.Namespaces, ",") != "payments,search" {
+		t.Errorf("money namespaces = %v, want [payments search]", money.Namespaces)
+	}
+	if want := math.Round(525*24/cost.HoursPerMonth*100) / 100; math.Abs(money.CostUSD-want) > 0.011 {
+		t.Errorf("money = %.2f, want ~%.2f", money.CostUSD, want)
+	}
+	if findLine(r, CategoryTenant, Unlabeled) == nil {
+		t.Error("web has no team label and should be reported as unlabeled")
+	}
+	assertReconciles(t, r)
+}
+
+func TestGenerate_FallbackToNamespaceCost(t *testing.T) {
+	st := openStore(t)
+	// A day recorded before allocation was persisted: namespaces cover
+	// only part of the recorded total.
+	st.total("2026-09-03", 730.5)
+	st.namespace("2026-09-03", "payments", 400)
+	st.namespace("2026-09-03", "web", 100)
+
+	g := NewGenerator(store.NewCostStore(st.db.RawDB()), nil, testConfig())
+	r, err := g.Generate(context.Background(), september, GroupByNamespace)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if r.TotalCostUSD != 24 {
+		t.Errorf("total = %.2f, want 24", r.TotalCostUSD)
+	}
+	un := findLine(r, CategoryUnallocated, CategoryUnallocated)
+	if un == nil {
+		t.Fatal("expected an unallocated line for the uncovered cost")
+	}
+	if want := math.Round(230.5*24/cost.HoursPerMonth*100) / 100; un.CostUSD != want {
+		t.Errorf("unallocated = %.2f, want %.2f", un.CostUSD, want)
+	}
+	assertReconciles(t, r)
+}
+
+func TestGenerate_InvalidGroupBy(t *testing.T) {
+	g := NewGenerator(store.NewCostStore(nil), nil, testConfig())
+	if _, err := g.Generate(context.Background(), september, "pod"); err == nil {
+		t.Error("expected an error for an unknown groupBy")
+	}
+	if _, err := g.Generate(context.Background(), september, GroupByCostCenter); err == nil {
+		t.Error("expected an error when grouping by label without a client")
+	}
+}
+
+func TestGenerate_Reproducible(t *testing.T) {
+	st := openStore(t)
+	st.seedDay("2026-09-01")
+	st.total("2026-09-02", 730.5)
+	st.namespace("2026-09-02", "a", 100.123)
+	st.namespace("2026-09-02", "b", 200.456)
+	st.namespace("2026-09-02", "c", 300.789)
+
+	g := NewGenerator(store.NewCostStore(st.db.RawDB()), nil, testConfig())
+	var first []byte
+	for i := 0; i < 5; i++ {
+		r, err := g.Generate(context.Background(), september, GroupByNamespace)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		data, err := Render(r, FormatCSV)
+		if err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+		if first == nil {
+			first = data
+		} else if !bytes.Equal(first, data) {
+			t.Fatalf("report %d differs from the first:\n%s\nvs\n%s", i, data, first)
+		}
+	}
+}
+
+func TestRoundLines_LargestRemainder(t *testing.T) {
+	lines := []Line{
+		{Name: "a", DirectCostUSD: 10.0 / 3},
+		{Name: "b", DirectCostUSD: 10.0 / 3},
+		{Name: "c", DirectCostUSD: 10.0 / 3},
+	}
+	total := roundLines(lines)
+	if total != 10 {
+		t.Fatalf("total = %.2f, want 10", total)
+	}
+	got := []float64{lines[0].CostUSD, lines[1].CostUSD, lines[2].CostUSD}
+	if got[0] != 3.34 || got[1] != 3.33 || got[2] != 3.33 {
+		t.Errorf("rounded = %v, want [3.34 3.33 3.33]", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Export formats
+// ---------------------------------------------------------------------------
+
+func sampleReport(t *testing.T) *Report {
+	t.Helper()
+	st := openStore(t)
+	st.seedDay("2026-09-01")
+	r, err := NewGenerator(store.NewCostStore(st.db.RawDB()), nil, testConfig()).Generate(context.Background(), september, GroupByNamespace)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	return r
+}
+
+func TestWriteCSV_TotalRowMatchesLines(t *testing.T) {
+	r := sampleReport(t)
+	data, err := Render(r, FormatCSV)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(rows) != len(r.Lines)+2 {
+		t.Fatalf("rows = %d, want header + %d lines + total", len(rows), len(r.Lines))
+	}
+	if rows[0][len(rows[0])-1] != "cost_usd" {
+		t.Errorf("header = %v", rows[0])
+	}
+	var cents int64
+	for _, row := range rows[1 : len(rows)-1] {
+		v, _ := strconv.ParseFloat(row[9], 64)
+		cents += int64(math.Round(v * 100))
+	}
+	last := rows[len(rows)-1]
+	if last[4] != "TOTAL" || last[9] != "24.00" {
+		t.Errorf("total row = %v, want TOTAL 24.00", last)
+	}
+	if cents != 2400 {
+		t.Errorf("cost_usd column sums to %d cents, want 2400", cents)
+	}
+}
+
+func TestWriteFOCUS(t *testing.T) {
+	r := sampleReport(t)
+	data, err := Render(r, FormatFOCUS)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(rows) != len(r.Lines)+1 {
+		t.Fatalf("rows = %d, want header + %d lines", len(rows), len(r.Lines))
+	}
+	col := make(map[string]int)
+	for i, h := range rows[0] {
+		col[h] = i
+	}
+	for _, required := range []string{"BilledCost", "EffectiveCost", "BillingCurrency", "BillingPeriodStart", "ChargePeriodEnd", "ProviderName", "SubAccountName", "Tags"} {
+		if _, ok := col[required]; !ok {
+			t.Errorf("missing FOCUS column %s", required)
+		}
+	}
+	first := rows[1]
+	if first[col["BillingPeriodStart"]] != "2026-09-01T00:00:00Z" || first[col["BillingPeriodEnd"]] != "2026-10-01T00:00:00Z" {
+		t.Errorf("billing period = %s..%s", first[col["BillingPeriodStart"]], first[col["BillingPeriodEnd"]])
+	}
+	if first[col["ProviderName"]] != "AWS" || first[col["BillingCurrency"]] != "USD" || first[col["ChargeCategory"]] != "Usage" {
+		t.Errorf("unexpected row %v", first)
+	}
+	var cents int64
+	for _, row := range rows[1:] {
+		v, _ := strconv.ParseFloat(row[col["BilledCost"]], 64)
+		cents += int64(math.Round(v * 100))
+	}
+	if cents != 2400 {
+		t.Errorf("BilledCost sums to %d cents, want 2400", cents)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	r := &Report{ClusterName: "prod", GroupBy: GroupByTeam, PeriodStart: september}
+	if got := FileName(r, FormatCSV); got != "prod-chargeback-team-2026-09.csv" {
+		t.Errorf("csv file name = %q", got)
+	}
+	if got := FileName(r, FormatFOCUS); got != "prod-chargeback-team-2026-09.focus.csv" {
+		t.Errorf("focus file name = %q", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Sinks and export
+// ---------------------------------------------------------------------------
+
+func TestExporter_ExportToDirectory(t *testing.T) {
+	st := openStore(t)
+	st.seedDay("2026-09-01")
+	dir := t.TempDir()
+
+	cfg := testConfig()
+	cfg.Chargeback.Enabled = true
+	cfg.Chargeback.Directory = dir
+	gen := NewGenerator(store.NewCostStore(st.db.RawDB()), nil, cfg)
+	e, err := NewExporter(context.Background(), gen, cfg)
+	if err != nil {
+		t.Fatalf("NewExporter: %v", err)
+	}
+	if err := e.Export(context.Background(), september); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	for _, name := range []string{"prod-chargeback-namespace-2026-09.csv", "prod-chargeback-namespace-2026-09.focus.csv"} {
+		data, err := os.ReadFile(filepath.Join(dir, "2026-09", name))
+		if err != nil {
+			t.Errorf("expected %s: %v", name, err)
+			continue
+		}
+		if len(data) == 0 {
+			t.Errorf("%s is empty", name)
+		}
+	}
+}
+
+func TestNewExporter_InvalidSchedule(t *testing.T) {
+	cfg := testConfig()
+	cfg.Chargeback.Schedule = "every month"
+	cfg.Chargeback.Directory = t.TempDir()
+	if _, err := NewExporter(context.Background(), nil, cfg); err == nil {
+		t.Error("expected an error for an invalid schedule")
+	}
+}
+
+func TestS3Sink_Put(t *testing.T) {
+	var gotPath, gotAuth, gotBody, gotHash string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method = %s, want PUT", r.Method)
+		}
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		gotHash = r.Header.Get("X-Amz-Content-Sha256")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+	}))
+	defer srv.Close()
+
+	creds := credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")
+	sink, err := newS3Sink(config.ChargebackS3Config{
+		Bucket:       "finops",
+		Prefix:       "/chargeback/prod/",
+		Endpoint:     srv.URL,
+		UsePathStyle: true,
+	}, "eu-west-1", creds, srv.Client())
+	if err != nil {
+		t.Fatalf("newS3Sink: %v", err)
+	}
+	if err := sink.Put(context.Background(), "2026-09/report.csv", []byte("a,b\n")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if gotPath != "/finops/chargeback/prod/2026-09/report.csv" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(gotAuth, "/eu-west-1/s3/aws4_request") {
+		t.Errorf("authorization = %q, want a SigV4 signature for eu-west-1/s3", gotAuth)
+	}
+	if gotHash == "" || gotBody != "a,b\n" {
+		t.Errorf("hash = %q body = %q", gotHash, gotBody)
+	}
+}
+
+func TestS3Sink_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	sink, err := newS3Sink(config.ChargebackS3Config{Bucket: "finops", Endpoint: srv.URL, UsePathStyle: true},
+		"us-east-1", credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""), srv.Client())
+	if err != nil {
+		t.Fatalf("newS3Sink: %v", err)
+	}
+	err = sink.Put(context.Background(), "x.csv", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("err = %v, want the S3 error body", err)
+	}
+}
+
+func TestS3Sink_VirtualHostedURL(t *testing.T) {
+	sink, err := newS3Sink(config.ChargebackS3Config{Bucket: "finops"}, "eu-west-1", aws.AnonymousCredentials{}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("newS3Sink: %v", err)
+	}
+	if got := sink.objectURL("2026-09/r.csv"); got != "https://finops.s3.eu-west-1.amazonaws.com/2026-09/r.csv" {
+		t.Errorf("objectURL = %q", got)
+	}
+}
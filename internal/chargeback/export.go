@@ -0,0 +1,150 @@
+package chargeback
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatCSV   = "csv"
+	FormatFOCUS = "focus"
+)
+
+var csvHeader = []string{
+	"cluster", "period_start", "period_end", "group_by", "name", "category",
+	"namespaces", "direct_cost_usd", "shared_cost_usd", "cost_usd",
+}
+
+// WriteCSV renders r as a chargeback CSV: one row per line followed by a
+// TOTAL row that equals the sum of the cost_usd column.
+func WriteCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	start, end := r.PeriodStart.Format("2006-01-02"), r.PeriodEnd.Format("2006-01-02")
+	for _, l := range r.Lines {
+		if err := cw.Write([]string{
+			r.ClusterName, start, end, r.GroupBy, l.Name, l.Category,
+			strings.Join(l.Namespaces, ";"),
+			money(l.DirectCostUSD), money(l.SharedCostUSD), money(l.CostUSD),
+		}); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write([]string{r.ClusterName, start, end, r.GroupBy, "TOTAL", "", "", "", "", money(r.TotalCostUSD)}); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// focusHeader lists the FOCUS 1.0 columns emitted by WriteFOCUS. Columns
+// prefixed with x_ are koptimizer extensions.
+var focusHeader = []string{
+	"BillingAccountId", "BillingAccountName", "BillingCurrency",
+	"BillingPeriodStart", "BillingPeriodEnd", "ChargePeriodStart", "ChargePeriodEnd",
+	"ChargeCategory", "ChargeClass", "ChargeDescription",
+	"BilledCost", "EffectiveCost", "ListCost", "ContractedCost",
+	"ProviderName", "PublisherName", "InvoiceIssuerName",
+	"ServiceCategory", "ServiceName", "SubAccountId", "SubAccountName", "Tags",
+	"x_AllocationCategory", "x_GroupBy", "x_Namespaces", "x_DirectCost", "x_SharedCost",
+}
+
+// WriteFOCUS renders r in the FinOps FOCUS schema with one usage row per
+// line. The billing account is the cluster and the sub account the group,
+// so FOCUS tooling can roll costs up the same way as the CSV report.
+func WriteFOCUS(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(focusHeader); err != nil {
+		return err
+	}
+	start, end := focusTime(r.PeriodStart), focusTime(r.PeriodEnd)
+	provider := providerName(r.Provider)
+	for _, l := range r.Lines {
+		tags, err := json.Marshal(map[string]string{
+			"koptimizer.io/cluster":      r.ClusterName,
+			"koptimizer.io/category":     l.Category,
+			"koptimizer.io/" + r.GroupBy: l.Name,
+		})
+		if err != nil {
+			return err
+		}
+		c := money(l.CostUSD)
+		if err := cw.Write([]string{
+			r.ClusterName, r.ClusterName, r.Currency,
+			start, end, start, end,
+			"Usage", "", fmt.Sprintf("Kubernetes %s cost for %s %q", l.Category, r.GroupBy, l.Name),
+			c, c, c, c,
+			provider, provider, provider,
+			"Compute", "Kubernetes", l.Name, l.Name, string(tags),
+			l.Category, r.GroupBy, strings.Join(l.Namespaces, ";"), money(l.DirectCostUSD), money(l.SharedCostUSD),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Render encodes r in format.
+func Render(r *Report, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatCSV:
+		err = WriteCSV(&buf, r)
+	case FormatFOCUS:
+		err = WriteFOCUS(&buf, r)
+	default:
+		return nil, fmt.Errorf("unknown chargeback format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FileName returns the file name for r in format, e.g.
+// "prod-chargeback-team-2026-09.csv" or "prod-chargeback-team-2026-09.focus.csv".
+func FileName(r *Report, format string) string {
+	cluster := r.ClusterName
+	if cluster == "" {
+		cluster = "cluster"
+	}
+	name := fmt.Sprintf("%s-chargeback-%s-%s", cluster, r.GroupBy, r.PeriodStart.Format("2006-01"))
+	if format == FormatFOCUS {
+		return name + ".focus.csv"
+	}
+	return name + ".csv"
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func focusTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+func providerName(p string) string {
+	switch p {
+	case "aws":
+		return "AWS"
+	case "gcp":
+		return "Google Cloud"
+	case "azure":
+		return "Microsoft"
+	case "":
+		return "Kubernetes"
+	default:
+		return p
+	}
+}
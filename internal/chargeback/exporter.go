@@ -0,0 +1,102 @@
+package chargeback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+)
+
+// Exporter writes the previous month's reports to every configured sink on
+// a cron schedule. It implements manager.Runnable.
+type Exporter struct {
+	generator *Generator
+	sinks     []Sink
+	schedule  cron.Schedule
+	groupBy   []string
+	formats   []string
+	now       func() time.Time
+}
+
+// NewExporter builds an Exporter from cfg.Chargeback.
+func NewExporter(ctx context.Context, gen *Generator, cfg *config.Config) (*Exporter, error) {
+	cb := cfg.Chargeback
+	sched, err := cron.ParseStandard(cb.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chargeback schedule %q: %w", cb.Schedule, err)
+	}
+	var sinks []Sink
+	if cb.Directory != "" {
+		sinks = append(sinks, NewDirSink(cb.Directory))
+	}
+	if cb.S3.Bucket != "" {
+		s3, err := NewS3Sink(ctx, cb.S3, cfg.Region)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s3)
+	}
+	return &Exporter{
+		generator: gen,
+		sinks:     sinks,
+		schedule:  sched,
+		groupBy:   cb.GroupBy,
+		formats:   cb.Formats,
+		now:       time.Now,
+	}, nil
+}
+
+// Start implements manager.Runnable.
+func (e *Exporter) Start(ctx context.Context) error {
+	for {
+		next := e.schedule.Next(e.now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			// Runs on the 1st by default, so the previous month is complete.
+			month := MonthStart(next).AddDate(0, -1, 0)
+			if err := e.Export(ctx, month); err != nil {
+				slog.Error("chargeback export failed", "month", month.Format("2006-01"), "error", err)
+			}
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// Export generates every configured grouping for the month containing
+// month and writes each format to every sink under "<YYYY-MM>/<file>".
+// It keeps going after a failure and returns all errors joined.
+func (e *Exporter) Export(ctx context.Context, month time.Time) error {
+	var errs []error
+	for _, groupBy := range e.groupBy {
+		report, err := e.generator.Generate(ctx, month, groupBy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s report: %w", groupBy, err))
+			continue
+		}
+		for _, format := range e.formats {
+			data, err := Render(report, format)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			key := path.Join(report.PeriodStart.Format("2006-01"), FileName(report, format))
+			for _, sink := range e.sinks {
+				if err := sink.Put(ctx, key, data); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+					continue
+				}
+				slog.Info("chargeback report exported", "sink", sink.Name(), "key", key, "totalCostUSD", report.TotalCostUSD)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
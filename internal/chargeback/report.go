@@ -0,0 +1,339 @@
+// Package chargeback builds monthly chargeback statements from the daily
+// cost allocation persisted by the cost monitor and renders them as CSV or
+// FinOps FOCUS files.
+package chargeback
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/store"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+)
+
+// Dimensions a report can be grouped by. Team and cost center are read from
+// namespace labels.
+const (
+	GroupByNamespace  = "namespace"
+	GroupByTeam       = "team"
+	GroupByCostCenter = "costCenter"
+)
+
+// Line categories.
+const (
+	CategoryTenant      = "tenant"
+	CategoryShared      = "shared"      // shared namespace cost that was not redistributed
+	CategoryIdle        = "idle"        // unallocated node group capacity
+	CategoryUnallocated = "unallocated" // recorded cluster cost not covered by allocation rows
+)
+
+// Unlabeled groups the namespaces that lack the team or cost center label.
+const Unlabeled = "unlabeled"
+
+// Report is a chargeback statement for one calendar month. Lines always
+// sum to TotalCostUSD to the cent, and TotalCostUSD is the cluster cost
+// recorded for the days in the period.
+type Report struct {
+	ClusterName  string    `json:"clusterName"`
+	Provider     string    `json:"provider"`
+	PeriodStart  time.Time `json:"periodStart"`
+	PeriodEnd    time.Time `json:"periodEnd"` // exclusive
+	GroupBy      string    `json:"groupBy"`
+	LabelKey     string    `json:"labelKey,omitempty"`
+	Currency     string    `json:"currency"`
+	DaysCovered  int       `json:"daysCovered"`
+	TotalCostUSD float64   `json:"totalCostUSD"`
+	Lines        []Line    `json:"lines"`
+	GeneratedAt  time.Time `json:"generatedAt"`
+}
+
+// Line is the cost of one group over the period. For tenant lines
+// SharedCostUSD is the group's slice of the shared namespaces.
+type Line struct {
+	Name          string   `json:"name"`
+	Category      string   `json:"category"`
+	Namespaces    []string `json:"namespaces,omitempty"`
+	DirectCostUSD float64  `json:"directCostUSD"`
+	SharedCostUSD float64  `json:"sharedCostUSD"`
+	CostUSD       float64  `json:"costUSD"`
+}
+
+// Generator builds reports from the CostStore.
+type Generator struct {
+	store           *store.CostStore
+	reader          client.Reader
+	clusterName     string
+	provider        string
+	teamLabel       string
+	costCenterLabel string
+	now             func() time.Time
+}
+
+// NewGenerator creates a Generator. reader is used to look up namespace
+// labels for team and cost center reports; it may be nil when only
+// namespace reports are needed.
+func NewGenerator(st *store.CostStore, reader client.Reader, cfg *config.Config) *Generator {
+	return &Generator{
+		store:           st,
+		reader:          reader,
+		clusterName:     cfg.ClusterName,
+		provider:        cfg.CloudProvider,
+		teamLabel:       cfg.Chargeback.TeamLabel,
+		costCenterLabel: cfg.Chargeback.CostCenterLabel,
+		now:             time.Now,
+	}
+}
+
+// MonthStart returns midnight UTC on the first day of t's month.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Generate builds the report for the calendar month containing month.
+// Each recorded day contributes its monthly run-rate prorated to one day;
+// days recorded before allocation was persisted fall back to the plain
+// per-namespace costs, with the remainder reported as unallocated.
+func (g *Generator) Generate(ctx context.Context, month time.Time, groupBy string) (*Report, error) {
+	labelKey, err := g.labelKey(groupBy)
+	if err != nil {
+		return nil, err
+	}
+	var nsLabels map[string]string
+	if labelKey != "" {
+		if nsLabels, err = g.namespaceLabels(ctx, labelKey); err != nil {
+			return nil, err
+		}
+	}
+
+	start := MonthStart(month)
+	end := start.AddDate(0, 1, 0)
+
+	byDay := make(map[string][]store.AllocationRecord)
+	for _, rec := range g.store.GetAllocationForPeriod(start, end) {
+		byDay[rec.Date] = append(byDay[rec.Date], rec)
+	}
+	totals := g.store.GetDailyTotalsForPeriod(start, end)
+	dates := make([]string, 0, len(totals))
+	for d := range totals {
+		dates = append(dates, d)
+	}
+	for d := range byDay {
+		if _, ok := totals[d]; !ok {
+			dates = append(dates, d)
+		}
+	}
+	sort.Strings(dates)
+
+	var nsDaily map[string]map[string]float64
+	lines := make(map[string]*Line)
+	// Lines are keyed separately from their name so that idle node groups
+	// sharing a display name stay apart.
+	add := func(category, key, name, namespace string, direct, shared float64) {
+		key = category + "/" + key
+		l := lines[key]
+		if l == nil {
+			l = &Line{Name: name, Category: category}
+			lines[key] = l
+		}
+		if namespace != "" && !containsString(l.Namespaces, namespace) {
+			l.Namespaces = append(l.Namespaces, namespace)
+		}
+		l.DirectCostUSD += direct
+		l.SharedCostUSD += shared
+	}
+
+	const daily = 24 / cost.HoursPerMonth
+	for _, date := range dates {
+		rows := byDay[date]
+		if len(rows) == 0 {
+			if nsDaily == nil {
+				nsDaily = g.store.GetNamespaceDailyForPeriod(start, end)
+			}
+			for ns, c := range nsDaily[date] {
+				rows = append(rows, store.AllocationRecord{Date: date, Bucket: store.AllocationBucketNamespace, Name: ns, DirectCostUSD: c, MonthlyCostUSD: c})
+			}
+			// Fixed summation order keeps the rounded output reproducible.
+			sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+		}
+
+		sum := 0.0
+		for _, r := range rows {
+			sum += r.MonthlyCostUSD
+		}
+		total, ok := totals[date]
+		if !ok {
+			total = sum
+		}
+		// Rows never exceed the recorded total by construction; if they do
+		// (e.g. the snapshot and allocation came from different reconciles)
+		// scale them so the day still reconciles.
+		scale := 1.0
+		if sum > total && sum > 0 {
+			scale = total / sum
+		}
+
+		for _, r := range rows {
+			direct := r.DirectCostUSD * scale * daily
+			shared := r.SharedCostUSD * scale * daily
+			switch r.Bucket {
+			case store.AllocationBucketNamespace:
+				group := g.groupName(r.Name, groupBy, nsLabels)
+				add(CategoryTenant, group, group, r.Name, direct, shared)
+			case store.AllocationBucketShared:
+				// Retained shared cost; the redistributed part already
+				// appears in the tenant lines.
+				add(CategoryShared, r.Name, r.Name, r.Name, r.MonthlyCostUSD*scale*daily, 0)
+			case store.AllocationBucketIdle:
+				name := r.DisplayName
+				if name == "" {
+					name = r.Name
+				}
+				add(CategoryIdle, r.Name, name, "", r.MonthlyCostUSD*scale*daily, 0)
+			}
+		}
+		if rest := total - sum*scale; rest > 0 {
+			add(CategoryUnallocated, CategoryUnallocated, CategoryUnallocated, "", rest*daily, 0)
+		}
+	}
+
+	report := &Report{
+		ClusterName: g.clusterName,
+		Provider:    g.provider,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GroupBy:     groupBy,
+		LabelKey:    labelKey,
+		Currency:    "USD",
+		DaysCovered: len(dates),
+		Lines:       make([]Line, 0, len(lines)),
+		GeneratedAt: g.now().UTC(),
+	}
+	for _, l := range lines {
+		sort.Strings(l.Namespaces)
+		report.Lines = append(report.Lines, *l)
+	}
+	sortLines(report.Lines)
+	report.TotalCostUSD = roundLines(report.Lines)
+	return report, nil
+}
+
+func (g *Generator) labelKey(groupBy string) (string, error) {
+	switch groupBy {
+	case GroupByNamespace:
+		return "", nil
+	case GroupByTeam:
+		return g.teamLabel, nil
+	case GroupByCostCenter:
+		return g.costCenterLabel, nil
+	default:
+		return "", fmt.Errorf("groupBy must be %s, %s or %s, got %q", GroupByNamespace, GroupByTeam, GroupByCostCenter, groupBy)
+	}
+}
+
+// namespaceLabels maps every namespace to its value of key.
+func (g *Generator) namespaceLabels(ctx context.Context, key string) (map[string]string, error) {
+	if g.reader == nil {
+		return nil, fmt.Errorf("grouping by namespace label %q needs a Kubernetes client", key)
+	}
+	var list corev1.NamespaceList
+	if err := g.reader.List(ctx, &list); err != nil {
+		return nil, fmt.Errorf("listing namespaces: %w", err)
+	}
+	labels := make(map[string]string, len(list.Items))
+	for _, ns := range list.Items {
+		if v := ns.Labels[key]; v != "" {
+			labels[ns.Name] = v
+		}
+	}
+	return labels, nil
+}
+
+func (g *Generator) groupName(namespace, groupBy string, nsLabels map[string]string) string {
+	if groupBy == GroupByNamespace {
+		return namespace
+	}
+	if v, ok := nsLabels[namespace]; ok {
+		return v
+	}
+	return Unlabeled
+}
+
+var categoryOrder = map[string]int{
+	CategoryTenant:      0,
+	CategoryShared:      1,
+	CategoryIdle:        2,
+	CategoryUnallocated: 3,
+}
+
+// sortLines orders lines by category, then by descending cost, then by
+// name, so the same data always renders identically.
+func sortLines(lines []Line) {
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		if categoryOrder[a.Category] != categoryOrder[b.Category] {
+			return categoryOrder[a.Category] < categoryOrder[b.Category]
+		}
+		ca, cb := a.DirectCostUSD+a.SharedCostUSD, b.DirectCostUSD+b.SharedCostUSD
+		if ca != cb {
+			return ca > cb
+		}
+		return a.Name < b.Name
+	})
+}
+
+// roundLines rounds every line to whole cents using the largest remainder
+// method, so the rounded lines add up exactly to the rounded total, and
+// returns that total. Ties go to the earlier line.
+func roundLines(lines []Line) float64 {
+	exact := make([]float64, len(lines))
+	var sum float64
+	for i, l := range lines {
+		exact[i] = (l.DirectCostUSD + l.SharedCostUSD) * 100
+		sum += exact[i]
+	}
+	totalCents := int64(math.Round(sum))
+
+	cents := make([]int64, len(lines))
+	var assigned int64
+	order := make([]int, len(lines))
+	for i, e := range exact {
+		cents[i] = int64(math.Floor(e))
+		assigned += cents[i]
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return exact[order[a]]-math.Floor(exact[order[a]]) > exact[order[b]]-math.Floor(exact[order[b]])
+	})
+	for k := 0; assigned < totalCents && k < len(order); k++ {
+		cents[order[k]]++
+		assigned++
+	}
+
+	for i := range lines {
+		l := &lines[i]
+		l.CostUSD = float64(cents[i]) / 100
+		direct := int64(math.Round(l.DirectCostUSD * 100))
+		if direct > cents[i] {
+			direct = cents[i]
+		}
+		l.DirectCostUSD = float64(direct) / 100
+		l.SharedCostUSD = float64(cents[i]-direct) / 100
+	}
+	return float64(totalCents) / 100
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package chargeback
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+)
+
+// Sink stores rendered reports under a slash-separated key.
+type Sink interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// DirSink writes reports below a local directory.
+type DirSink struct {
+	dir string
+}
+
+// NewDirSink creates a DirSink rooted at dir.
+func NewDirSink(dir string) *DirSink {
+	return &DirSink{dir: dir}
+}
+
+func (s *DirSink) Name() string { return "dir:" + s.dir }
+
+// Put writes data atomically so a reader never sees a partial report.
+func (s *DirSink) Put(_ context.Context, key string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".chargeback-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // no-op after Rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// S3Sink uploads reports to an S3-compatible bucket with a SigV4-signed
+// PUT, so any store that speaks the S3 API (MinIO, R2, GCS interop) works.
+type S3Sink struct {
+	bucket    string
+	prefix    string
+	region    string
+	endpoint  *url.URL
+	pathStyle bool
+	creds     aws.CredentialsProvider
+	signer    *v4.Signer
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3Sink creates an S3Sink using credentials from the default AWS chain.
+// defaultRegion is used when cfg.Region is empty.
+func NewS3Sink(ctx context.Context, cfg config.ChargebackS3Config, defaultRegion string) (*S3Sink, error) {
+	region := cfg.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS credentials: %w", err)
+	}
+	return newS3Sink(cfg, region, awsCfg.Credentials, &http.Client{Timeout: time.Minute})
+}
+
+func newS3Sink(cfg config.ChargebackS3Config, region string, creds aws.CredentialsProvider, client *http.Client) (*S3Sink, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: %w", endpoint, err)
+	}
+	return &S3Sink{
+		bucket:    cfg.Bucket,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		region:    region,
+		endpoint:  u,
+		pathStyle: cfg.UsePathStyle,
+		creds:     creds,
+		signer:    v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true }),
+		client:    client,
+		now:       time.Now,
+	}, nil
+}
+
+func (s *S3Sink) Name() string { return "s3://" + s.bucket + "/" + s.prefix }
+
+// objectURL returns the URL of key, addressing the bucket by path or by
+// virtual host.
+func (s *S3Sink) objectURL(key string) string {
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return u.String()
+}
+
+func (s *S3Sink) Put(ctx context.Context, key string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds, err := s.creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving credentials: %w", err)
+	}
+	if err := s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, s.now()); err != nil {
+		return fmt.Errorf("signing request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 put %s: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package karpenter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/familylock"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// stubCloud returns one cloud node group. Methods not overridden panic via
+// the nil embedded interface, which keeps tests honest about delegation.
+type stubCloud struct {
+	cloudprovider.CloudProvider
+	scaled map[string]int
+}
+
+func (s *stubCloud) Name() string { return "stub" }
+
+func (s *stubCloud) DiscoverNodeGroups(ctx context.Context) ([]*cloudprovider.NodeGroup, error) {
+	return []*cloudprovider.NodeGroup{{ID: "asg-system", Name: "system", InstanceType: "m5.large"}}, nil
+}
+
+func (s *stubCloud) ScaleNodeGroup(ctx context.Context, id string, desiredCount int) error {
+	s.scaled[id] = desiredCount
+	return nil
+}
+
+func newNodePool(name string, limits map[string]interface{}, requirements []interface{}, budgets []interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"team": "web"}},
+				"spec":     map[string]interface{}{"requirements": requirements},
+			},
+			"disruption": map[string]interface{}{
+				"consolidationPolicy": "WhenEmptyOrUnderutilized",
+				"budgets":             budgets,
+			},
+		},
+	}}
+	if limits != nil {
+		unstructured.SetNestedField(obj.Object, limits, "spec", "limits")
+	}
+	obj.SetGroupVersionKind(gvk("v1", "NodePool"))
+	obj.SetName(name)
+	return obj
+}
+
+func requirementOf(key, op string, values ...string) map[string]interface{} {
+	vals := make([]interface{}, len(values))
+	for i, v := range values {
+		vals[i] = v
+	}
+	return map[string]interface{}{"key": key, "operator": op, "values": vals}
+}
+
+func newNodeClaim(name, pool, node, instanceType, capacityType, cpu, mem string, age time.Duration) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"nodeName":   node,
+			"providerID": "aws:///us-east-1a/i-" + name,
+			"capacity":   map[string]interface{}{"cpu": cpu, "memory": mem},
+		},
+	}}
+	obj.SetGroupVersionKind(gvk("v1", "NodeClaim"))
+	obj.SetName(name)
+	obj.SetLabels(map[string]string{
+		nodePoolLabel:     pool,
+		instanceTypeLabel: instanceType,
+		capacityTypeLabel: capacityType,
+		zoneLabel:         "us-east-1a",
+	})
+	obj.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-age)))
+	return obj
+}
+
+func terminating(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	now := metav1.Now()
+	obj.SetDeletionTimestamp(&now)
+	obj.SetFinalizers([]string{"karpenter.sh/termination"})
+	return obj
+}
+
+func newNode(name, pool string, cordoned bool) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{nodePoolLabel: pool}},
+		Spec:       corev1.NodeSpec{Unschedulable: cordoned},
+	}
+}
+
+func testScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	_ = corev1.AddToScheme(s)
+	for _, kind := range []string{"NodePool", "NodeClaim"} {
+		s.AddKnownTypeWithName(gvk("v1", kind), &unstructured.Unstructured{})
+		s.AddKnownTypeWithName(gvk("v1", kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return s
+}
+
+func newTestProvider(objs ...client.Object) (*Provider, client.Client, *stubCloud) {
+	c := fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(objs...).Build()
+	inner := &stubCloud{scaled: map[string]int{}}
+	return NewProvider(inner, c), c, inner
+}
+
+// webPool is a NodePool with three c6i/m6i claims, one of them spot.
+func webPool() []client.Object {
+	return []client.Object{
+		newNodePool("web",
+			map[string]interface{}{"cpu": int64(64), "memory": "256Gi"},
+			[]interface{}{
+				requirementOf(capacityTypeLabel, "In", "spot", "on-demand"),
+				requirementOf(instanceFamilyLabel, "In", "c6i", "m6i"),
+			},
+			[]interface{}{
+				map[string]interface{}{"nodes": "20%"},
+				map[string]interface{}{"nodes": "0", "schedule": "0 9 * * 1-5", "duration": "8h", "reasons": []interface{}{"Underutilized"}},
+			}),
+		newNodeClaim("web-a", "web", "node-a", "c6i.2xlarge", "on-demand", "8", "16Gi", 3*time.Hour),
+		newNodeClaim("web-b", "web", "node-b", "c6i.2xlarge", "spot", "8", "16Gi", 2*time.Hour),
+		newNodeClaim("web-c", "web", "node-c", "m6i.xlarge", "on-demand", "4", "16Gi", time.Hour),
+	}
+}
+
+func getPool(t *testing.T, c client.Client, name string) *unstructured.Unstructured {
+	t.Helper()
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk("v1", "NodePool"))
+	if err := c.Get(context.Background(), types.NamespacedName{Name: name}, obj); err != nil {
+		t.Fatalf("get NodePool %s: %v", name, err)
+	}
+	return obj
+}
+
+func claimExists(t *testing.T, c client.Client, name string) bool {
+	t.Helper()
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk("v1", "NodeClaim"))
+	err := c.Get(context.Background(), types.NamespacedName{Name: name}, obj)
+	if apierrors.IsNotFound(err) {
+		return false
+	}
+	if err != nil {
+		t.Fatalf("get NodeClaim %s: %v", name, err)
+	}
+	return true
+}
+
+// ---------------------------------------------------------------------------
+// Discovery Tests
+// ---------------------------------------------------------------------------
+
+func TestDiscoverNodeGroups_NodePool(t *testing.T) {
+	p, _, _ := newTestProvider(webPool()...)
+	groups, err := p.DiscoverNodeGroups(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverNodeGroups: %v", err)
+	}
+	if len(groups) != 2 || groups[0].ID != "asg-system" {
+		t.Fatalf("expected cloud group followed by the NodePool, got %d groups", len(groups))
+	}
+
+	ng := groups[1]
+	if ng.ID != "karpenter_web" || ng.Name != "web" || ng.Source != cloudprovider.NodeGroupSourceKarpenter {
+		t.Errorf("identity = %s/%s/%s", ng.ID, ng.Name, ng.Source)
+	}
+	if ng.CurrentCount != 3 || ng.DesiredCount != 3 || ng.MinCount != 0 {
+		t.Errorf("counts current=%d desired=%d min=%d, want 3/3/0", ng.CurrentCount, ng.DesiredCount, ng.MinCount)
+	}
+	if ng.MaxCount != 8 {
+		t.Errorf("MaxCount = %d, want 8 (64 cpu / 8 cpu nodes)", ng.MaxCount)
+	}
+	if ng.InstanceType != "c6i.2xlarge" || ng.InstanceFamily != "c6i" {
+		t.Errorf("instance type = %s (%s), want most common c6i.2xlarge", ng.InstanceType, ng.InstanceFamily)
+	}
+	if strings.Join(ng.AllowedFamilies, ",") != "c6i,m6i" {
+		t.Errorf("AllowedFamilies = %v, want [c6i m6i]", ng.AllowedFamilies)
+	}
+	if ng.Lifecycle != "mixed" || ng.SpotPercentage != 33 {
+		t.Errorf("lifecycle = %s/%d%%, want mixed/33%%", ng.Lifecycle, ng.SpotPercentage)
+	}
+	if len(ng.InstanceIDs) != 3 || ng.InstanceIDs[0] != "i-web-a" {
+		t.Errorf("InstanceIDs = %v", ng.InstanceIDs)
+	}
+	if cpu := ng.Limits[corev1.ResourceCPU]; cpu.String() != "64" {
+		t.Errorf("cpu limit = %s, want 64", cpu.String())
+	}
+	if len(ng.DisruptionBudgets) != 2 || ng.DisruptionBudgets[1].Duration != 8*time.Hour {
+		t.Errorf("DisruptionBudgets = %+v", ng.DisruptionBudgets)
+	}
+	if ng.ConsolidationPolicy != "WhenEmptyOrUnderutilized" || ng.Labels["team"] != "web" {
+		t.Errorf("policy = %q labels = %v", ng.ConsolidationPolicy, ng.Labels)
+	}
+}
+
+func TestDiscoverNodeGroups_UnconstrainedPoolLocksRunningFamilies(t *testing.T) {
+	p, _, _ := newTestProvider(
+		newNodePool("batch", nil, nil, nil),
+		newNodeClaim("batch-a", "batch", "node-a", "r5.large", "spot", "2", "16Gi", time.Hour),
+	)
+	ng, err := p.GetNodeGroup(context.Background(), "karpenter_batch")
+	if err != nil {
+		t.Fatalf("GetNodeGroup: %v", err)
+	}
+	if strings.Join(ng.AllowedFamilies, ",") != "r5" {
+		t.Errorf("AllowedFamilies = %v, want running family r5", ng.AllowedFamilies)
+	}
+	if ng.Lifecycle != "on-demand" || ng.MaxCount != 0 {
+		t.Errorf("lifecycle = %s max = %d, want Karpenter default on-demand and unbounded", ng.Lifecycle, ng.MaxCount)
+	}
+}
+
+func TestDiscoverNodeGroups_NoCRDs(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(testScheme()).WithInterceptorFuncs(interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			gvk := list.GetObjectKind().GroupVersionKind()
+			return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+		},
+	}).Build()
+	p := NewProvider(&stubCloud{}, c)
+
+	groups, err := p.DiscoverNodeGroups(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverNodeGroups: %v", err)
+	}
+	if len(groups) != 1 || groups[0].ID != "asg-system" {
+		t.Errorf("expected only the cloud group without Karpenter CRDs, got %d", len(groups))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Scaling Tests
+// ---------------------------------------------------------------------------
+
+func TestScaleNodeGroup_CapsLimitsAndRestores(t *testing.T) {
+	objs := append(webPool(), newNode("node-a", "web", true), newNode("node-b", "web", false), newNode("node-c", "web", false))
+	p, c, _ := newTestProvider(objs...)
+	ctx := context.Background()
+
+	if err := p.ScaleNodeGroup(ctx, "karpenter_web", 1); err != nil {
+		t.Fatalf("ScaleNodeGroup(1): %v", err)
+	}
+	pool := getPool(t, c, "web")
+	limits, _, _ := unstructured.NestedStringMap(pool.Object, "spec", "limits")
+	if limits["cpu"] != "8" || limits["memory"] != "16Gi" {
+		t.Errorf("capped limits = %v, want one 8 cpu / 16Gi node", limits)
+	}
+	if got := pool.GetAnnotations()[OriginalLimitsAnnotation]; got != `{"cpu":"64","memory":"256Gi"}` {
+		t.Errorf("original limits annotation = %q", got)
+	}
+	// The cordoned node goes first, then the newest.
+	if claimExists(t, c, "web-a") || claimExists(t, c, "web-c") || !claimExists(t, c, "web-b") {
+		t.Error("expected web-a (cordoned) and web-c (newest) deleted, web-b kept")
+	}
+
+	if err := p.ScaleNodeGroup(ctx, "karpenter_web", 3); err != nil {
+		t.Fatalf("ScaleNodeGroup(3): %v", err)
+	}
+	pool = getPool(t, c, "web")
+	limits, _, _ = unstructured.NestedStringMap(pool.Object, "spec", "limits")
+	if limits["cpu"] != "64" || limits["memory"] != "256Gi" {
+		t.Errorf("restored limits = %v, want original", limits)
+	}
+	if _, ok := pool.GetAnnotations()[OriginalLimitsAnnotation]; ok {
+		t.Error("original limits annotation should be removed after restore")
+	}
+}
+
+func TestScaleNodeGroup_RestoresUnlimitedPool(t *testing.T) {
+	p, c, _ := newTestProvider(
+		newNodePool("batch", nil, nil, nil),
+		newNodeClaim("batch-a", "batch", "node-a", "r5.large", "spot", "2", "16Gi", time.Hour),
+	)
+	ctx := context.Background()
+	if err := p.ScaleNodeGroup(ctx, "karpenter_batch", 0); err != nil {
+		t.Fatalf("ScaleNodeGroup(0): %v", err)
+	}
+	limits, _, _ := unstructured.NestedStringMap(getPool(t, c, "batch").Object, "spec", "limits")
+	if limits["cpu"] != "0" {
+		t.Errorf("capped cpu limit = %q, want 0", limits["cpu"])
+	}
+	if err := p.ScaleNodeGroup(ctx, "karpenter_batch", 1); err != nil {
+		t.Fatalf("ScaleNodeGroup(1): %v", err)
+	}
+	if _, found, _ := unstructured.NestedMap(getPool(t, c, "batch").Object, "spec", "limits"); found {
+		t.Error("limits of an unlimited pool should be removed on restore")
+	}
+}
+
+func TestScaleNodeGroup_DelegatesCloudGroups(t *testing.T) {
+	p, _, inner := newTestProvider()
+	if err := p.ScaleNodeGroup(context.Background(), "asg-system", 4); err != nil {
+		t.Fatalf("ScaleNodeGroup: %v", err)
+	}
+	if inner.scaled["asg-system"] != 4 {
+		t.Errorf("cloud group not scaled through the wrapped provider")
+	}
+}
+
+func TestSetNodeGroupMinCount(t *testing.T) {
+	p, _, _ := newTestProvider(webPool()...)
+	if err := p.SetNodeGroupMinCount(context.Background(), "karpenter_web", 0); err != nil {
+		t.Errorf("min 0 should be accepted: %v", err)
+	}
+	if err := p.SetNodeGroupMinCount(context.Background(), "karpenter_web", 2); err == nil {
+		t.Error("expected error setting a minimum on a NodePool")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// NodeRemover Tests
+// ---------------------------------------------------------------------------
+
+func TestRemoveNode(t *testing.T) {
+	p, c, _ := newTestProvider(webPool()...)
+	if !p.CanRemoveNode("karpenter_web") || p.CanRemoveNode("asg-system") {
+		t.Fatal("CanRemoveNode should only accept NodePools")
+	}
+	if err := p.RemoveNode(context.Background(), "karpenter_web", "node-b"); err != nil {
+		t.Fatalf("RemoveNode: %v", err)
+	}
+	if claimExists(t, c, "web-b") || !claimExists(t, c, "web-a") {
+		t.Error("expected only the NodeClaim of node-b deleted")
+	}
+	if err := p.RemoveNode(context.Background(), "karpenter_web", "node-x"); err == nil {
+		t.Error("expected error for a node without a NodeClaim")
+	}
+}
+
+func TestDisruptionAllowed(t *testing.T) {
+	monday10 := time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+	sunday10 := time.Date(2026, 10, 11, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		budgets []interface{}
+		extra   []client.Object
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "default 10% rounds up to one", now: monday10},
+		{name: "default budget in use", extra: []client.Object{terminating(newNodeClaim("web-d", "web", "node-d", "c6i.2xlarge", "spot", "8", "16Gi", time.Minute))}, now: monday10, wantErr: true},
+		{name: "scheduled zero budget active", budgets: webBudgets(), now: monday10, wantErr: true},
+		{name: "scheduled zero budget inactive", budgets: webBudgets(), now: sunday10},
+		{name: "budget for other reasons ignored", budgets: []interface{}{
+			map[string]interface{}{"nodes": "0", "reasons": []interface{}{"Drifted"}},
+		}, now: monday10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := webPool()
+			objs[0] = newNodePool("web", nil, nil, tt.budgets)
+			p, _, _ := newTestProvider(append(objs, tt.extra...)...)
+			p.now = func() time.Time { return tt.now }
+			err := p.DisruptionAllowed(context.Background(), "karpenter_web")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func webBudgets() []interface{} {
+	return []interface{}{
+		map[string]interface{}{"nodes": "20%"},
+		map[string]interface{}{"nodes": "0", "schedule": "0 9 * * 1-5", "duration": "8h", "reasons": []interface{}{"Underutilized"}},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Family Lock Tests
+// ---------------------------------------------------------------------------
+
+func TestFamilyLockGuard_NodePoolRequirements(t *testing.T) {
+	p, _, _ := newTestProvider(webPool()...)
+	guard := familylock.NewFamilyLockGuard(p)
+	ctx := context.Background()
+
+	tests := []struct {
+		group    string
+		proposed string
+		wantErr  bool
+	}{
+		{group: "karpenter_web", proposed: "c6i.4xlarge"},
+		{group: "karpenter_web", proposed: "m6i.large"},
+		{group: "karpenter_web", proposed: "r5.large", wantErr: true},
+		{group: "asg-system", proposed: "m5.xlarge"},
+		{group: "asg-system", proposed: "c6i.large", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := guard.ValidateScaleUpCtx(ctx, tt.group, tt.proposed)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s -> %s: err = %v, wantErr %v", tt.group, tt.proposed, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,840 @@
+// Package karpenter exposes Karpenter NodePools as node groups. Provider
+// wraps the cloud provider: cloud node groups pass through unchanged and
+// every NodePool is added as a logical group backed by its NodeClaims.
+//
+// NodePools have no desired count. Scaling a pool down caps its cpu and
+// memory limits so Karpenter cannot replace the removed capacity, then
+// deletes the surplus NodeClaims; scaling it back up restores the original
+// limits. Consolidation removes the drained node's NodeClaim directly
+// (cloudprovider.NodeRemover) after checking the pool's disruption budgets.
+package karpenter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/familylock"
+)
+
+const (
+	// IDPrefix prefixes the node group ID of every NodePool. Underscores are
+	// valid in ConfigMap keys (hibernation state) but not in NodePool names.
+	IDPrefix = "karpenter_"
+
+	group = "karpenter.sh"
+
+	nodePoolLabel       = "karpenter.sh/nodepool"
+	capacityTypeLabel   = "karpenter.sh/capacity-type"
+	instanceTypeLabel   = "node.kubernetes.io/instance-type"
+	instanceFamilyLabel = "karpenter.k8s.aws/instance-family"
+	zoneLabel           = "topology.kubernetes.io/zone"
+
+	// OriginalLimitsAnnotation holds the NodePool's spec.limits (JSON) while
+	// koptimizer has them capped for a scale-down.
+	OriginalLimitsAnnotation = "koptimizer.io/original-limits"
+
+	// consolidationReason is the Karpenter disruption reason whose budgets
+	// apply to koptimizer's consolidation.
+	consolidationReason = "Underutilized"
+	// defaultBudget is Karpenter's budget when a NodePool declares none.
+	defaultBudget = "10%"
+)
+
+// apiVersions are tried in order until one is served.
+var apiVersions = []string{"v1", "v1beta1"}
+
+// Provider decorates a CloudProvider with Karpenter NodePools.
+type Provider struct {
+	cloudprovider.CloudProvider
+	client client.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	version string // karpenter.sh version last served, "" until discovered
+}
+
+// NewProvider wraps inner. c should be an uncached client: NodePools and
+// NodeClaims are read on every discovery.
+func NewProvider(inner cloudprovider.CloudProvider, c client.Client) *Provider {
+	return &Provider{CloudProvider: inner, client: c, now: time.Now}
+}
+
+// IsNodePool reports whether a node group ID refers to a Karpenter NodePool.
+func IsNodePool(id string) bool {
+	return strings.HasPrefix(id, IDPrefix)
+}
+
+type nodePool struct {
+	obj                 *unstructured.Unstructured
+	limits              corev1.ResourceList
+	requirements        []requirement
+	budgets             []cloudprovider.DisruptionBudget
+	consolidationPolicy string
+	labels              map[string]string
+	taints              []corev1.Taint
+}
+
+type requirement struct {
+	key      string
+	operator string
+	values   []string
+}
+
+type nodeClaim struct {
+	name         string
+	pool         string
+	nodeName     string
+	providerID   string
+	instanceType string
+	zone         string
+	capacityType string
+	cpu          resource.Quantity
+	memory       resource.Quantity
+	created      time.Time
+	deleting     bool
+}
+
+// ---------------------------------------------------------------------------
+// CloudProvider overrides
+// ---------------------------------------------------------------------------
+
+// DiscoverNodeGroups returns the cloud provider's node groups followed by
+// one group per NodePool. NodePool discovery is best-effort: when the CRDs
+// are missing or unreadable only the cloud groups are returned.
+func (p *Provider) DiscoverNodeGroups(ctx context.Context) ([]*cloudprovider.NodeGroup, error) {
+	groups, err := p.CloudProvider.DiscoverNodeGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pools, claims, err := p.load(ctx)
+	if err != nil {
+		slog.Warn("karpenter: failed to list NodePools", "error", err)
+		return groups, nil
+	}
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		groups = append(groups, buildNodeGroup(name, pools[name], claims[name]))
+	}
+	return groups, nil
+}
+
+func (p *Provider) GetNodeGroup(ctx context.Context, id string) (*cloudprovider.NodeGroup, error) {
+	if !IsNodePool(id) {
+		return p.CloudProvider.GetNodeGroup(ctx, id)
+	}
+	name := strings.TrimPrefix(id, IDPrefix)
+	pool, claims, err := p.loadPool(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return buildNodeGroup(name, pool, claims), nil
+}
+
+// ScaleNodeGroup scales a NodePool to desiredCount NodeClaims. Scaling
+// down caps the pool's limits at desiredCount of its largest nodes and
+// deletes the surplus claims, cordoned and not-yet-registered ones first.
+// Any count at or above the current one lifts the cap again.
+func (p *Provider) ScaleNodeGroup(ctx context.Context, id string, desiredCount int) error {
+	if !IsNodePool(id) {
+		return p.CloudProvider.ScaleNodeGroup(ctx, id, desiredCount)
+	}
+	if desiredCount < 0 {
+		return fmt.Errorf("invalid desired count %d", desiredCount)
+	}
+	name := strings.TrimPrefix(id, IDPrefix)
+	pool, claims, err := p.loadPool(ctx, name)
+	if err != nil {
+		return err
+	}
+	live := liveClaims(claims)
+	if desiredCount >= len(live) {
+		return p.restoreLimits(ctx, pool)
+	}
+
+	if err := p.capLimits(ctx, pool, desiredCount, live); err != nil {
+		return err
+	}
+	victims, err := p.pickVictims(ctx, name, live, len(live)-desiredCount)
+	if err != nil {
+		return err
+	}
+	for _, nc := range victims {
+		if err := p.deleteClaim(ctx, nc.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetNodeGroupMinCount accepts only 0 for NodePools, which have no minimum.
+func (p *Provider) SetNodeGroupMinCount(ctx context.Context, id string, minCount int) error {
+	if !IsNodePool(id) {
+		return p.CloudProvider.SetNodeGroupMinCount(ctx, id, minCount)
+	}
+	if minCount != 0 {
+		return fmt.Errorf("karpenter NodePool %s has no minimum size", strings.TrimPrefix(id, IDPrefix))
+	}
+	return nil
+}
+
+// SetNodeGroupMaxCount sets a NodePool's cpu and memory limits to maxCount
+// of its largest current nodes.
+func (p *Provider) SetNodeGroupMaxCount(ctx context.Context, id string, maxCount int) error {
+	if !IsNodePool(id) {
+		return p.CloudProvider.SetNodeGroupMaxCount(ctx, id, maxCount)
+	}
+	if maxCount < 0 {
+		return fmt.Errorf("invalid max count %d", maxCount)
+	}
+	name := strings.TrimPrefix(id, IDPrefix)
+	pool, claims, err := p.loadPool(ctx, name)
+	if err != nil {
+		return err
+	}
+	cpu, mem := nodeCapacity(liveClaims(claims))
+	if cpu.IsZero() || mem.IsZero() {
+		return fmt.Errorf("NodePool %s has no launched nodes to size limits from", name)
+	}
+	return p.patchPool(ctx, pool.obj, map[string]interface{}{
+		"spec": map[string]interface{}{"limits": map[string]interface{}{
+			"cpu":    scale(cpu, maxCount).String(),
+			"memory": scale(mem, maxCount).String(),
+		}},
+	})
+}
+
+// ---------------------------------------------------------------------------
+// NodeRemover
+// ---------------------------------------------------------------------------
+
+// CanRemoveNode reports true for NodePools.
+func (p *Provider) CanRemoveNode(nodeGroupID string) bool {
+	return IsNodePool(nodeGroupID)
+}
+
+// DisruptionAllowed checks the NodePool's active disruption budgets for
+// the Underutilized reason against the NodeClaims already being deleted.
+func (p *Provider) DisruptionAllowed(ctx context.Context, nodeGroupID string) error {
+	name := strings.TrimPrefix(nodeGroupID, IDPrefix)
+	pool, claims, err := p.loadPool(ctx, name)
+	if err != nil {
+		return err
+	}
+	disrupting := len(claims) - len(liveClaims(claims))
+	allowed := allowedDisruptions(pool.budgets, len(claims), p.now())
+	if disrupting >= allowed {
+		return fmt.Errorf("disruption budget exhausted: %d of %d allowed node disruptions in progress", disrupting, allowed)
+	}
+	return nil
+}
+
+// RemoveNode deletes the NodeClaim of nodeName; Karpenter terminates the
+// instance. The pool's limits are left alone so it can grow again.
+func (p *Provider) RemoveNode(ctx context.Context, nodeGroupID, nodeName string) error {
+	name := strings.TrimPrefix(nodeGroupID, IDPrefix)
+	_, claims, err := p.loadPool(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, nc := range claims {
+		if nc.nodeName == nodeName {
+			return p.deleteClaim(ctx, nc.name)
+		}
+	}
+	return fmt.Errorf("no NodeClaim for node %s in NodePool %s", nodeName, name)
+}
+
+// ---------------------------------------------------------------------------
+// Optional interfaces of the wrapped provider
+// ---------------------------------------------------------------------------
+
+func (p *Provider) StartBackgroundRefresh(ctx context.Context) {
+	if br, ok := p.CloudProvider.(cloudprovider.BackgroundRefresher); ok {
+		br.StartBackgroundRefresh(ctx)
+	}
+}
+
+func (p *Provider) GetSpotPricing(ctx context.Context, region string, instanceTypes []string) ([]*cloudprovider.SpotInstanceInfo, error) {
+	if sp, ok := p.CloudProvider.(cloudprovider.SpotProvider); ok {
+		return sp.GetSpotPricing(ctx, region, instanceTypes)
+	}
+	return nil, fmt.Errorf("%s provider does not support spot pricing", p.Name())
+}
+
+func (p *Provider) GetSpotInterruptionRate(ctx context.Context, region string, instanceTypes []string) (map[string]float64, error) {
+	if sp, ok := p.CloudProvider.(cloudprovider.SpotProvider); ok {
+		return sp.GetSpotInterruptionRate(ctx, region, instanceTypes)
+	}
+	return nil, fmt.Errorf("%s provider does not support spot interruption rates", p.Name())
+}
+
+// EstimateSpotDiscount falls back to the same 65% the callers use for
+// providers without an estimator.
+func (p *Provider) EstimateSpotDiscount(instanceType string) float64 {
+	if sde, ok := p.CloudProvider.(cloudprovider.SpotDiscountEstimator); ok {
+		return sde.EstimateSpotDiscount(instanceType)
+	}
+	return 0.65
+}
+
+func (p *Provider) EstimatePriceFromCapacity(instanceType, region string, cpuMilli int64, memBytes int64) float64 {
+	if fp, ok := p.CloudProvider.(cloudprovider.FallbackPricer); ok {
+		return fp.EstimatePriceFromCapacity(instanceType, region, cpuMilli, memBytes)
+	}
+	return 0
+}
+
+func (p *Provider) DetectGPUByInstanceType(instanceType string) (int, string) {
+	if d, ok := p.CloudProvider.(cloudprovider.GPUInstanceDetector); ok {
+		return d.DetectGPUByInstanceType(instanceType)
+	}
+	return 0, ""
+}
+
+// ---------------------------------------------------------------------------
+// Reading NodePools and NodeClaims
+// ---------------------------------------------------------------------------
+
+func gvk(version, kind string) schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: group, Version: version, Kind: kind}
+}
+
+// list reads all objects of kind, trying each Karpenter API version until
+// one is served. It returns a nil list when the CRDs are not installed.
+func (p *Provider) list(ctx context.Context, kind string) ([]unstructured.Unstructured, error) {
+	p.mu.Lock()
+	versions := apiVersions
+	if p.version != "" {
+		versions = []string{p.version}
+	}
+	p.mu.Unlock()
+
+	for _, v := range versions {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk(v, kind+"List"))
+		err := p.client.List(ctx, list)
+		if err == nil {
+			p.mu.Lock()
+			p.version = v
+			p.mu.Unlock()
+			return list.Items, nil
+		}
+		if !meta.IsNoMatchError(err) && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	// Not served (any more): rediscover the version next time.
+	p.mu.Lock()
+	p.version = ""
+	p.mu.Unlock()
+	return nil, nil
+}
+
+// load returns all NodePools by name and their NodeClaims by pool name.
+func (p *Provider) load(ctx context.Context) (map[string]*nodePool, map[string][]nodeClaim, error) {
+	poolObjs, err := p.list(ctx, "NodePool")
+	if err != nil || len(poolObjs) == 0 {
+		return nil, nil, err
+	}
+	claimObjs, err := p.list(ctx, "NodeClaim")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pools := make(map[string]*nodePool, len(poolObjs))
+	for i := range poolObjs {
+		pools[poolObjs[i].GetName()] = parseNodePool(&poolObjs[i])
+	}
+	claims := make(map[string][]nodeClaim)
+	for i := range claimObjs {
+		nc := parseNodeClaim(&claimObjs[i])
+		if nc.pool != "" {
+			claims[nc.pool] = append(claims[nc.pool], nc)
+		}
+	}
+	return pools, claims, nil
+}
+
+func (p *Provider) loadPool(ctx context.Context, name string) (*nodePool, []nodeClaim, error) {
+	pools, claims, err := p.load(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing Karpenter NodePools: %w", err)
+	}
+	pool, ok := pools[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("karpenter NodePool %s not found", name)
+	}
+	return pool, claims[name], nil
+}
+
+func parseNodePool(obj *unstructured.Unstructured) *nodePool {
+	pool := &nodePool{obj: obj, limits: corev1.ResourceList{}}
+
+	limits, _, _ := unstructured.NestedMap(obj.Object, "spec", "limits")
+	for k, v := range limits {
+		if q, err := resource.ParseQuantity(fmt.Sprint(v)); err == nil {
+			pool.limits[corev1.ResourceName(k)] = q
+		}
+	}
+
+	reqs, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "requirements")
+	for _, r := range reqs {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		req := requirement{}
+		req.key, _, _ = unstructured.NestedString(m, "key")
+		req.operator, _, _ = unstructured.NestedString(m, "operator")
+		req.values, _, _ = unstructured.NestedStringSlice(m, "values")
+		pool.requirements = append(pool.requirements, req)
+	}
+
+	taints, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "taints")
+	for _, t := range taints {
+		m, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key, _, _ := unstructured.NestedString(m, "key")
+		value, _, _ := unstructured.NestedString(m, "value")
+		effect, _, _ := unstructured.NestedString(m, "effect")
+		pool.taints = append(pool.taints, corev1.Taint{Key: key, Value: value, Effect: corev1.TaintEffect(effect)})
+	}
+
+	pool.labels, _, _ = unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+	pool.consolidationPolicy, _, _ = unstructured.NestedString(obj.Object, "spec", "disruption", "consolidationPolicy")
+
+	budgets, _, _ := unstructured.NestedSlice(obj.Object, "spec", "disruption", "budgets")
+	for _, b := range budgets {
+		m, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		budget := cloudprovider.DisruptionBudget{}
+		budget.Nodes, _, _ = unstructured.NestedString(m, "nodes")
+		budget.Schedule, _, _ = unstructured.NestedString(m, "schedule")
+		budget.Reasons, _, _ = unstructured.NestedStringSlice(m, "reasons")
+		if d, _, _ := unstructured.NestedString(m, "duration"); d != "" {
+			budget.Duration, _ = time.ParseDuration(d)
+		}
+		pool.budgets = append(pool.budgets, budget)
+	}
+	return pool
+}
+
+func parseNodeClaim(obj *unstructured.Unstructured) nodeClaim {
+	labels := obj.GetLabels()
+	nc := nodeClaim{
+		name:         obj.GetName(),
+		pool:         labels[nodePoolLabel],
+		instanceType: labels[instanceTypeLabel],
+		zone:         labels[zoneLabel],
+		capacityType: labels[capacityTypeLabel],
+		created:      obj.GetCreationTimestamp().Time,
+		deleting:     obj.GetDeletionTimestamp() != nil,
+	}
+	nc.nodeName, _, _ = unstructured.NestedString(obj.Object, "status", "nodeName")
+	nc.providerID, _, _ = unstructured.NestedString(obj.Object, "status", "providerID")
+	capacity, _, _ := unstructured.NestedStringMap(obj.Object, "status", "capacity")
+	if q, err := resource.ParseQuantity(capacity["cpu"]); err == nil {
+		nc.cpu = q
+	}
+	if q, err := resource.ParseQuantity(capacity["memory"]); err == nil {
+		nc.memory = q
+	}
+	return nc
+}
+
+// ---------------------------------------------------------------------------
+// NodeGroup mapping
+// ---------------------------------------------------------------------------
+
+func buildNodeGroup(name string, pool *nodePool, claims []nodeClaim) *cloudprovider.NodeGroup {
+	live := liveClaims(claims)
+	ng := &cloudprovider.NodeGroup{
+		ID:                  IDPrefix + name,
+		Name:                name,
+		CurrentCount:        len(live),
+		DesiredCount:        len(live),
+		Labels:              pool.labels,
+		Taints:              pool.taints,
+		Source:              cloudprovider.NodeGroupSourceKarpenter,
+		Limits:              pool.limits,
+		DisruptionBudgets:   pool.budgets,
+		ConsolidationPolicy: pool.consolidationPolicy,
+	}
+
+	typeCounts := make(map[string]int)
+	zones := make(map[string]bool)
+	spot := 0
+	for _, nc := range live {
+		if nc.instanceType != "" {
+			typeCounts[nc.instanceType]++
+		}
+		if nc.zone != "" {
+			zones[nc.zone] = true
+		}
+		if nc.capacityType == "spot" {
+			spot++
+		}
+		if id := instanceID(nc.providerID); id != "" {
+			ng.InstanceIDs = append(ng.InstanceIDs, id)
+		}
+	}
+	for t := range typeCounts {
+		ng.InstanceTypes = append(ng.InstanceTypes, t)
+	}
+	sort.Strings(ng.InstanceTypes)
+	for _, t := range ng.InstanceTypes {
+		if ng.InstanceType == "" || typeCounts[t] > typeCounts[ng.InstanceType] {
+			ng.InstanceType = t
+		}
+	}
+	ng.InstanceFamily, _ = familylock.ExtractFamily(ng.InstanceType)
+	if len(zones) == 1 {
+		for z := range zones {
+			ng.Zone = z
+		}
+	}
+
+	ng.Lifecycle = lifecycle(pool.requirements)
+	if ng.Lifecycle == "mixed" && len(live) > 0 {
+		ng.SpotPercentage = spot * 100 / len(live)
+	}
+	ng.AllowedFamilies = allowedFamilies(pool.requirements, ng.InstanceTypes)
+
+	if cpuLimit, ok := pool.limits[corev1.ResourceCPU]; ok {
+		if cpu, _ := nodeCapacity(live); !cpu.IsZero() {
+			ng.MaxCount = int(cpuLimit.MilliValue() / cpu.MilliValue())
+		}
+	}
+	return ng
+}
+
+func liveClaims(claims []nodeClaim) []nodeClaim {
+	live := make([]nodeClaim, 0, len(claims))
+	for _, nc := range claims {
+		if !nc.deleting {
+			live = append(live, nc)
+		}
+	}
+	return live
+}
+
+// lifecycle derives the group lifecycle from the capacity-type requirement.
+// Karpenter defaults to on-demand when the pool does not constrain it.
+func lifecycle(reqs []requirement) string {
+	for _, r := range reqs {
+		if r.key != capacityTypeLabel || r.operator != "In" {
+			continue
+		}
+		spot, other := false, false
+		for _, v := range r.values {
+			if v == "spot" {
+				spot = true
+			} else {
+				other = true
+			}
+		}
+		switch {
+		case spot && other:
+			return "mixed"
+		case spot:
+			return "spot"
+		}
+	}
+	return "on-demand"
+}
+
+// allowedFamilies returns the families the pool's requirements permit. A
+// pool without a family or instance-type requirement may launch anything,
+// so it is locked to the families it currently runs.
+func allowedFamilies(reqs []requirement, running []string) []string {
+	var families []string
+	for _, r := range reqs {
+		if r.operator != "In" {
+			continue
+		}
+		switch r.key {
+		case instanceFamilyLabel:
+			families = append(families, r.values...)
+		case instanceTypeLabel:
+			families = append(families, familiesOf(r.values)...)
+		}
+	}
+	if len(families) == 0 {
+		families = familiesOf(running)
+	}
+	return dedupe(families)
+}
+
+func familiesOf(instanceTypes []string) []string {
+	var families []string
+	for _, t := range instanceTypes {
+		if f, err := familylock.ExtractFamily(t); err == nil {
+			families = append(families, f)
+		}
+	}
+	return families
+}
+
+func dedupe(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// instanceID returns the last path segment of a providerID, e.g.
+// "i-0123456789abcdef0" for "aws:///us-east-1a/i-0123456789abcdef0".
+func instanceID(providerID string) string {
+	if providerID == "" {
+		return ""
+	}
+	return providerID[strings.LastIndex(providerID, "/")+1:]
+}
+
+// nodeCapacity returns the largest cpu and memory capacity among claims.
+func nodeCapacity(claims []nodeClaim) (cpu, mem resource.Quantity) {
+	for _, nc := range claims {
+		if nc.cpu.Cmp(cpu) > 0 {
+			cpu = nc.cpu
+		}
+		if nc.memory.Cmp(mem) > 0 {
+			mem = nc.memory
+		}
+	}
+	return cpu, mem
+}
+
+func scale(q resource.Quantity, n int) *resource.Quantity {
+	if q.MilliValue() < math.MaxInt64/1000 {
+		return resource.NewMilliQuantity(q.MilliValue()*int64(n), q.Format)
+	}
+	return resource.NewQuantity(q.Value()*int64(n), q.Format)
+}
+
+// ---------------------------------------------------------------------------
+// Disruption budgets
+// ---------------------------------------------------------------------------
+
+// allowedDisruptions returns how many of total nodes may be disrupted for
+// consolidation at now: the minimum over the active budgets that apply to
+// the Underutilized reason. Percentages round up, as in Karpenter.
+func allowedDisruptions(budgets []cloudprovider.DisruptionBudget, total int, now time.Time) int {
+	if len(budgets) == 0 {
+		budgets = []cloudprovider.DisruptionBudget{{Nodes: defaultBudget}}
+	}
+	allowed := math.MaxInt
+	for _, b := range budgets {
+		if !budgetApplies(b, now) {
+			continue
+		}
+		n := budgetNodes(b.Nodes, total)
+		if n < allowed {
+			allowed = n
+		}
+	}
+	if allowed == math.MaxInt {
+		return total
+	}
+	return allowed
+}
+
+func budgetApplies(b cloudprovider.DisruptionBudget, now time.Time) bool {
+	if len(b.Reasons) > 0 {
+		match := false
+		for _, r := range b.Reasons {
+			if strings.EqualFold(r, consolidationReason) {
+				match = true
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	if b.Schedule == "" {
+		return true
+	}
+	sched, err := cron.ParseStandard(b.Schedule)
+	if err != nil {
+		// Karpenter rejects invalid schedules; treat the budget as active
+		// rather than ignore a restriction.
+		return true
+	}
+	// Active when the last activation was within Duration of now.
+	now = now.UTC()
+	return !sched.Next(now.Add(-b.Duration)).After(now)
+}
+
+func budgetNodes(nodes string, total int) int {
+	nodes = strings.TrimSpace(nodes)
+	if pct, ok := strings.CutSuffix(nodes, "%"); ok {
+		var v float64
+		if _, err := fmt.Sscanf(pct, "%g", &v); err != nil {
+			return 0
+		}
+		return int(math.Ceil(v * float64(total) / 100))
+	}
+	var v int
+	if _, err := fmt.Sscanf(nodes, "%d", &v); err != nil {
+		return 0
+	}
+	return v
+}
+
+// ---------------------------------------------------------------------------
+// Writes
+// ---------------------------------------------------------------------------
+
+// capLimits saves the pool's limits in an annotation (once) and lowers its
+// cpu and memory limits to n of its largest live nodes.
+func (p *Provider) capLimits(ctx context.Context, pool *nodePool, n int, live []nodeClaim) error {
+	cpu, mem := nodeCapacity(live)
+	if cpu.IsZero() || mem.IsZero() {
+		return fmt.Errorf("NodePool %s: NodeClaims report no capacity to size limits from", pool.obj.GetName())
+	}
+
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{"limits": map[string]interface{}{
+			"cpu":    scale(cpu, n).String(),
+			"memory": scale(mem, n).String(),
+		}},
+	}
+	if _, saved := pool.obj.GetAnnotations()[OriginalLimitsAnnotation]; !saved {
+		original := make(map[string]string, len(pool.limits))
+		for k, q := range pool.limits {
+			original[string(k)] = q.String()
+		}
+		data, err := json.Marshal(original)
+		if err != nil {
+			return err
+		}
+		patch["metadata"] = map[string]interface{}{
+			"annotations": map[string]interface{}{OriginalLimitsAnnotation: string(data)},
+		}
+	}
+	return p.patchPool(ctx, pool.obj, patch)
+}
+
+// restoreLimits puts back the limits saved by capLimits, if any.
+func (p *Provider) restoreLimits(ctx context.Context, pool *nodePool) error {
+	saved, ok := pool.obj.GetAnnotations()[OriginalLimitsAnnotation]
+	if !ok {
+		return nil
+	}
+	var original map[string]string
+	if err := json.Unmarshal([]byte(saved), &original); err != nil {
+		return fmt.Errorf("NodePool %s: parsing %s: %w", pool.obj.GetName(), OriginalLimitsAnnotation, err)
+	}
+
+	var limits interface{}
+	if len(original) > 0 {
+		m := make(map[string]interface{}, len(pool.limits)+len(original))
+		for k := range pool.limits {
+			m[string(k)] = nil
+		}
+		for k, v := range original {
+			m[k] = v
+		}
+		limits = m
+	}
+	return p.patchPool(ctx, pool.obj, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{OriginalLimitsAnnotation: nil},
+		},
+		"spec": map[string]interface{}{"limits": limits},
+	})
+}
+
+func (p *Provider) patchPool(ctx context.Context, obj *unstructured.Unstructured, patch map[string]interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	if err := p.client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, data)); err != nil {
+		return fmt.Errorf("patching NodePool %s: %w", obj.GetName(), err)
+	}
+	return nil
+}
+
+// pickVictims orders live claims for removal: nodes already cordoned,
+// then claims whose node has not registered, then the newest.
+func (p *Provider) pickVictims(ctx context.Context, pool string, live []nodeClaim, n int) ([]nodeClaim, error) {
+	nodes := &corev1.NodeList{}
+	if err := p.client.List(ctx, nodes, client.MatchingLabels{nodePoolLabel: pool}); err != nil {
+		return nil, fmt.Errorf("listing nodes of NodePool %s: %w", pool, err)
+	}
+	cordoned := make(map[string]bool, len(nodes.Items))
+	for i := range nodes.Items {
+		cordoned[nodes.Items[i].Name] = nodes.Items[i].Spec.Unschedulable
+	}
+
+	rank := func(nc nodeClaim) int {
+		switch {
+		case nc.nodeName != "" && cordoned[nc.nodeName]:
+			return 0
+		case nc.nodeName == "":
+			return 1
+		default:
+			return 2
+		}
+	}
+	sorted := append([]nodeClaim(nil), live...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, rj := rank(sorted[i]), rank(sorted[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return sorted[i].created.After(sorted[j].created)
+	})
+	return sorted[:n], nil
+}
+
+func (p *Provider) deleteClaim(ctx context.Context, name string) error {
+	p.mu.Lock()
+	version := p.version
+	p.mu.Unlock()
+	if version == "" {
+		version = apiVersions[0]
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk(version, "NodeClaim"))
+	obj.SetName(name)
+	if err := p.client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting NodeClaim %s: %w", name, err)
+	}
+	return nil
+}
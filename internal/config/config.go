@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // Config is the top-level configuration for KOptimizer.
@@ -29,7 +32,10 @@ type Config struct {
 	Rightsizer     RightsizingConfig    `yaml:"rightsizer"`
 	WorkloadScaler WorkloadScalerConfig `yaml:"workloadScaler"`
 	PodPurger      PodPurgerConfig      `yaml:"podPurger"`
+	Evictor        EvictorConfig        `yaml:"evictor"`
+	Rebalancer     RebalancerConfig     `yaml:"rebalancer"`
 	GPU            GPUConfig            `yaml:"gpu"`
+	Metrics        MetricsConfig        `yaml:"metrics"`
 	Spot           SpotConfig           `yaml:"spot"`
 	Hibernation    HibernationConfig    `yaml:"hibernation"`
 	StorageMonitor StorageMonitorConfig `yaml:"storageMonitor"`
@@ -40,11 +46,25 @@ type Config struct {
 	APIServer      APIServerConfig      `yaml:"apiServer"`
 	Database       DatabaseConfig       `yaml:"database"`
 	HelmDrift      HelmDriftConfig      `yaml:"helmDrift"`
+	Hub            HubConfig            `yaml:"hub"`
+	Chargeback     ChargebackConfig     `yaml:"chargeback"`
+	Webhook        WebhookConfig        `yaml:"webhook"`
 }
 
 type CostMonitorConfig struct {
 	Enabled        bool          `yaml:"enabled"`
 	UpdateInterval time.Duration `yaml:"updateInterval"`
+
+	Allocation CostAllocationConfig `yaml:"allocation"`
+}
+
+// CostAllocationConfig controls how /cost/allocation attributes node cost
+// to namespaces. Unrequested capacity is always reported as per-node-group
+// idle cost; shared namespaces are spread across the tenant namespaces.
+type CostAllocationConfig struct {
+	SharedNamespaces []string `yaml:"sharedNamespaces"` // Platform namespaces whose cost is redistributed (default kube-system, monitoring, ingress-nginx)
+	SharedStrategy   string   `yaml:"sharedStrategy"`   // "proportional" to tenant cost (default), "even" across tenants, or "none" to report them separately
+	UsageWeight      float64  `yaml:"usageWeight"`      // 0 = allocate by requests (default), 1 = by measured usage, in between blends the two
 }
 
 type NodeGroupMgrConfig struct {
@@ -58,6 +78,11 @@ type NodeGroupMgrConfig struct {
 		Enabled     bool          `yaml:"enabled"`
 		EmptyPeriod time.Duration `yaml:"emptyPeriod"`
 	} `yaml:"emptyGroupDetection"`
+	// Karpenter exposes Karpenter NodePools as node groups next to the
+	// cloud provider's. It is a no-op on clusters without the Karpenter CRDs.
+	Karpenter struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"karpenter"`
 }
 
 type RightsizingConfig struct {
@@ -71,6 +96,61 @@ type RightsizingConfig struct {
 	MinKeepRatio        float64       `yaml:"minKeepRatio"` // Min fraction to keep per cycle (default 0.7 = max 30% reduction)
 	OOMBumpMultiplier   float64       `yaml:"oomBumpMultiplier"` // e.g., 2.5
 	ExcludeNamespaces   []string      `yaml:"excludeNamespaces"`
+	SidecarPolicy       string        `yaml:"sidecarPolicy"`     // "skip" (leave sidecars untouched) or "separate" (size them from their own usage)
+	SidecarContainers   []string      `yaml:"sidecarContainers"` // Container names treated as sidecars, in addition to injected ones
+
+	Watchdog   RightsizingWatchdogConfig   `yaml:"watchdog"`
+	Throttling RightsizingThrottlingConfig `yaml:"throttling"`
+	Limits     RightsizingLimitsConfig     `yaml:"limits"`
+	Rollout    RightsizingRolloutConfig    `yaml:"rollout"`
+}
+
+// RightsizingWatchdogConfig controls how rightsized workloads are watched
+// for regressions and rolled back to their original requests.
+type RightsizingWatchdogConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	Window              time.Duration `yaml:"window"`              // How long a workload is watched after a change
+	MaxRestarts         int           `yaml:"maxRestarts"`         // New container restarts across the workload that count as a spike
+	MaxReadinessDropPct float64       `yaml:"maxReadinessDropPct"` // Drop in ready pods, in percentage points, that counts as a regression
+
+	// Deprecated: ThrottlePct compared P95 CPU with the CPU limit. The
+	// watchdog now uses the CFS throttling threshold of
+	// rightsizer.throttling.throttledPct; a configured value is ignored
+	// with a warning.
+	ThrottlePct float64 `yaml:"throttlePct,omitempty"`
+}
+
+// RightsizingThrottlingConfig controls how CFS throttling, scraped from each
+// kubelet's cAdvisor endpoint, feeds CPU recommendations.
+type RightsizingThrottlingConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	ThrottledPct   float64 `yaml:"throttledPct"`   // P95 share of CFS periods throttled that counts as CPU-starved, and as a regression after a change
+	CPULimitPolicy string  `yaml:"cpuLimitPolicy"` // "raise", "remove" or "off": what to recommend for the CPU limit of a throttled container
+}
+
+// RightsizingLimitsConfig controls how container limits follow rightsized
+// requests and when memory limits are recommended.
+type RightsizingLimitsConfig struct {
+	DownsizeCPULimit   string  `yaml:"downsizeCPULimit"`   // "keepRatio", "multiplier", "remove" or "off": what a downsize does to CPU limits
+	CPULimitMultiplier float64 `yaml:"cpuLimitMultiplier"` // CPU limit as a multiple of the new request under "multiplier"
+	MemoryLimits       bool    `yaml:"memoryLimits"`       // Recommend memory limits from peak usage and OOM history
+	MemoryHeadroomPct  float64 `yaml:"memoryHeadroomPct"`  // Headroom above peak usage for a recommended memory limit
+}
+
+// RightsizingRolloutConfig controls when downsizes reach running workloads.
+// Windows and the budget only gate changes that restart pods.
+type RightsizingRolloutConfig struct {
+	Strategy             string              `yaml:"strategy"`             // "restart" patches the pod template now; "nextRollout" leaves the new requests for the admission webhook to apply to new pods
+	MaintenanceWindows   []MaintenanceWindow `yaml:"maintenanceWindows"`   // Restarting downsizes only run inside one of these (default: any time)
+	NamespaceDailyBudget int                 `yaml:"namespaceDailyBudget"` // Max restarting changes per namespace in any 24h (0 = unlimited)
+}
+
+// MaintenanceWindow opens at each activation of Schedule and stays open for
+// Duration.
+type MaintenanceWindow struct {
+	Schedule string        `yaml:"schedule"` // Cron expression, e.g. "0 2 * * MON-FRI"
+	Duration time.Duration `yaml:"duration"`
+	Timezone string        `yaml:"timezone"` // IANA name the schedule is evaluated in (default UTC)
 }
 
 type WorkloadScalerConfig struct {
@@ -83,6 +163,32 @@ type WorkloadScalerConfig struct {
 	ConfidenceStartPct float64  `yaml:"confidenceStartPct"`
 	ConfidenceFullDays int      `yaml:"confidenceFullDays"`
 	ExcludeNamespaces  []string `yaml:"excludeNamespaces"`
+
+	Predictive PredictiveScalingConfig `yaml:"predictive"`
+	HPATuning  HPATuningConfig         `yaml:"hpaTuning"`
+}
+
+// HPATuningConfig recommends HPA CPU targets, minReplicas and scale-down
+// stabilization from the replicas and utilization observed over a window.
+type HPATuningConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	HistoryWindow     time.Duration `yaml:"historyWindow"`     // HPA observations kept for analysis (default 24h)
+	MinHistory        time.Duration `yaml:"minHistory"`        // Observed history needed before tuning an HPA (default 6h)
+	MaxUtilizationPct float64       `yaml:"maxUtilizationPct"` // p95 CPU utilization of requests a recommended target keeps bursts under (default 90)
+	FlapsPerHour      float64       `yaml:"flapsPerHour"`      // Scaling direction reversals per hour that count as flapping (default 2)
+	MinReplicasFloor  int32         `yaml:"minReplicasFloor"`  // minReplicas is never recommended below this (default 2)
+	AutoApply         bool          `yaml:"autoApply"`         // Auto-execute low-risk changes: longer scale-down stabilization and lower CPU targets (default true)
+}
+
+// PredictiveScalingConfig pre-scales workloads ahead of the CPU peaks
+// forecast from their weekly usage pattern, by raising HPA minReplicas or,
+// without an HPA, the replica count, and lowers them again afterwards.
+type PredictiveScalingConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	Lookahead           time.Duration `yaml:"lookahead"`           // Replicas cover the highest forecast this far ahead (default 1h)
+	TargetCPUUtilPct    float64       `yaml:"targetCPUUtilPct"`    // CPU utilization of requests per replica at the forecast peak (default 70)
+	Headroom            float64       `yaml:"headroom"`            // Multiplier on the forecast peak (default 1.1)
+	MaxForecastErrorPct float64       `yaml:"maxForecastErrorPct"` // Raises are not auto-executed while a workload's forecast error is above this (default 25)
 }
 
 type PodPurgerConfig struct {
@@ -91,6 +197,27 @@ type PodPurgerConfig struct {
 	MinPodAge    time.Duration `yaml:"minPodAge"`
 }
 
+type EvictorConfig struct {
+	Enabled                 bool          `yaml:"enabled"`
+	UtilizationThresholdPct float64       `yaml:"utilizationThresholdPct"` // Nodes with CPU and memory requests below this % are consolidation candidates (default 50)
+	MaxPodsPerEviction      int           `yaml:"maxPodsPerEviction"`      // Nodes with more movable pods than this are skipped (default 10)
+	MaxNodesPerCycle        int           `yaml:"maxNodesPerCycle"`        // Max nodes consolidated per cycle (default 1)
+	DrainTimeout            time.Duration `yaml:"drainTimeout"`            // Max wait for evicted pods to terminate before scale-down (default 5m)
+	ExcludeNodeGroups       []string      `yaml:"excludeNodeGroups"`       // Node group IDs or names never consolidated
+}
+
+type RebalancerConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	Schedule           string        `yaml:"schedule"`           // Cron expression opening the maintenance window (default "0 3 * * SUN")
+	MaintenanceWindow  time.Duration `yaml:"maintenanceWindow"`  // No new wave starts after the window closes (default 2h)
+	ImbalanceThreshold float64       `yaml:"imbalanceThreshold"` // Fraction of a node group's nodes that tighter packing would free (default 0.2)
+	WaveSize           int           `yaml:"waveSize"`           // Nodes drained per wave (default 2)
+	WaveInterval       time.Duration `yaml:"waveInterval"`       // Settle time between waves (default 2m)
+	MaxNodesPerRun     int           `yaml:"maxNodesPerRun"`     // Max nodes removed per scheduled run (default 6)
+	DrainTimeout       time.Duration `yaml:"drainTimeout"`       // Max wait for evicted pods to terminate before scale-down (default 5m)
+	ExcludeNodeGroups  []string      `yaml:"excludeNodeGroups"`  // Node group IDs or names never rebalanced
+}
+
 type GPUConfig struct {
 	Enabled                      bool          `yaml:"enabled"`
 	IdleThresholdPct             float64       `yaml:"idleThresholdPct"`
@@ -100,6 +227,22 @@ type GPUConfig struct {
 	ScavengingCPUThresholdMillis int64         `yaml:"scavengingCPUThresholdMillis"`
 	ReclaimEnabled               bool          `yaml:"reclaimEnabled"`
 	ReclaimGracePeriod           time.Duration `yaml:"reclaimGracePeriod"`
+	MetricsSource                string        `yaml:"metricsSource"`        // "allocation" (default), "dcgm" or "endpoint"
+	DCGMNamespace                string        `yaml:"dcgmNamespace"`        // Namespace of dcgm-exporter pods (default "gpu-operator")
+	DCGMLabelSelector            string        `yaml:"dcgmLabelSelector"`    // Label selector for dcgm-exporter pods (default "app=nvidia-dcgm-exporter")
+	DCGMPort                     int           `yaml:"dcgmPort"`             // dcgm-exporter metrics port (default 9400)
+	MetricsEndpoint              string        `yaml:"metricsEndpoint"`      // Prometheus text URL for the "endpoint" source; {node} and {nodeIP} are substituted
+	MetricsScrapeTimeout         time.Duration `yaml:"metricsScrapeTimeout"` // Per-node scrape timeout (default 5s)
+}
+
+type MetricsConfig struct {
+	Backend         string        `yaml:"backend"`         // "metrics-server" (default) or "prometheus"
+	Retention       time.Duration `yaml:"retention"`       // History kept by the metrics store (default 7d)
+	PrometheusURL   string        `yaml:"prometheusURL"`   // Base URL of a Prometheus-compatible HTTP API, e.g. "http://prometheus.monitoring:9090"
+	PrometheusToken string        `yaml:"prometheusToken"` // Optional bearer token, or set KOPTIMIZER_PROMETHEUS_TOKEN env var
+	QueryTimeout    time.Duration `yaml:"queryTimeout"`    // Per-request timeout (default 30s)
+	Step            time.Duration `yaml:"step"`            // query_range resolution (default 60s, matching the reconcile interval)
+	BackfillWindow  time.Duration `yaml:"backfillWindow"`  // History loaded from Prometheus at startup (default: retention)
 }
 
 type SpotConfig struct {
@@ -168,14 +311,95 @@ type AIGateConfig struct {
 	ScaleThresholdPct float64       `yaml:"scaleThresholdPct"`
 	MaxEvictNodes     int           `yaml:"maxEvictNodes"`
 	Timezone          string        `yaml:"timezone"` // IANA timezone for business hours check (e.g., "America/New_York"). Defaults to UTC.
+
+	// Validators are asked in order until one decides: "rules", "anthropic",
+	// "openai" (default ["anthropic"]). "rules" abstains when no rule matches.
+	Validators []string           `yaml:"validators"`
+	Rules      []AIGateRule       `yaml:"rules"` // For the "rules" validator; the first matching rule decides
+	OpenAI     AIGateOpenAIConfig `yaml:"openai"`
+
+	// SystemPrompt replaces the built-in system prompt of the model
+	// validators. Replay recorded decisions against it before switching.
+	SystemPrompt string `yaml:"systemPrompt"`
+}
+
+// AIGateRule approves or rejects the changes its When expression matches.
+type AIGateRule struct {
+	Name     string `yaml:"name"`
+	When     string `yaml:"when"`     // CEL-like expression over the validation request, e.g. impact.nodesAffected <= 1
+	Decision string `yaml:"decision"` // "approve" or "reject"
+	Reason   string `yaml:"reason"`
+}
+
+// AIGateOpenAIConfig points the "openai" validator at an OpenAI-compatible
+// chat completions API, typically a self-hosted model.
+type AIGateOpenAIConfig struct {
+	BaseURL string `yaml:"baseURL"` // e.g. http://vllm.ai.svc:8000/v1
+	Model   string `yaml:"model"`
+	APIKey  string `yaml:"-"` // From KOPTIMIZER_AI_GATE_OPENAI_API_KEY
+}
+
+// WebhookConfig serves the mutating admission webhook for pods. The
+// certificate and key are read from CertDir as tls.crt and tls.key.
+type WebhookConfig struct {
+	Enabled               bool                `yaml:"enabled"`
+	Port                  int                 `yaml:"port"`                  // HTTPS port (default 9443)
+	CertDir               string              `yaml:"certDir"`               // Directory holding tls.crt and tls.key (default /tmp/k8s-webhook-server/serving-certs)
+	InjectRecommendations bool                `yaml:"injectRecommendations"` // Set the latest recommended requests on new pods of opted-in workloads (default true)
+	DefaultCPURequest     string              `yaml:"defaultCPURequest"`     // CPU request for containers without one; "" leaves them alone (default 100m)
+	DefaultMemoryRequest  string              `yaml:"defaultMemoryRequest"`  // Memory request for containers without one; "" leaves them alone (default 128Mi)
+	Policy                WebhookPolicyConfig `yaml:"policy"`
+}
+
+// WebhookPolicyConfig holds the request rules new pods are checked
+// against, after the webhook's own changes.
+type WebhookPolicyConfig struct {
+	Action              string  `yaml:"action"`              // "warn" (default) admits the pod with a warning, "deny" rejects it
+	MaxCPULimitRatio    float64 `yaml:"maxCPULimitRatio"`    // Max CPU limit:request ratio per container (0 = no check)
+	MaxMemoryLimitRatio float64 `yaml:"maxMemoryLimitRatio"` // Max memory limit:request ratio per container (0 = no check)
+	GPUFallbackRequests bool    `yaml:"gpuFallbackRequests"` // Flag GPU fallback and scavenger pods that list nvidia.com/gpu, even 0 (default true)
 }
 
 type APIServerConfig struct {
-	Enabled bool   `yaml:"enabled"`
-	Address string `yaml:"address"`
-	Port    int    `yaml:"port"`
+	Enabled bool          `yaml:"enabled"`
+	Address string        `yaml:"address"`
+	Port    int           `yaml:"port"`
+	Auth    APIAuthConfig `yaml:"auth"`
+	MCP     APIMCPConfig  `yaml:"mcp"`
+}
+
+// APIMCPConfig controls the MCP endpoint served at /mcp next to the REST
+// API. It uses the same authentication and roles as the REST API.
+type APIMCPConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	PollInterval time.Duration `yaml:"pollInterval"` // How often subscribed resources are checked for changes
+}
+
+// APIAuthConfig controls authentication of REST API requests. Roles, from
+// least to most privileged: viewer (read-only), approver (approve/dismiss
+// recommendations), operator (cluster actions such as deleting pods or
+// PDBs), admin (configuration and notification channels).
+type APIAuthConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Tokens      []APITokenConfig  `yaml:"tokens"`      // Static bearer tokens
+	TokenReview bool              `yaml:"tokenReview"` // Also accept Kubernetes ServiceAccount/OIDC tokens via the TokenReview API
+	Audiences   []string          `yaml:"audiences"`   // Audiences passed to TokenReview (default: API server default)
+	UserRoles   map[string]string `yaml:"userRoles"`   // TokenReview username -> role
+	GroupRoles  map[string]string `yaml:"groupRoles"`  // TokenReview group -> role; the highest matching role wins
+	DefaultRole string            `yaml:"defaultRole"` // Role for TokenReview principals without a mapping ("" denies them)
 }
 
+// APITokenConfig is a static bearer token. Name is the principal recorded
+// in the audit log.
+type APITokenConfig struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	Role  string `yaml:"role"`
+}
+
+// APIRoles lists the valid API roles from least to most privileged.
+var APIRoles = []string{"viewer", "approver", "operator", "admin"}
+
 type DatabaseConfig struct {
 	Path          string `yaml:"path"`
 	RetentionDays int    `yaml:"retentionDays"`
@@ -198,6 +422,56 @@ type HelmChartConfig struct {
 	Namespace    string `yaml:"namespace"`    // optional namespace filter
 }
 
+// HubConfig turns this instance into a fleet hub that polls other koptimizer
+// API servers and serves cross-cluster rollups from /api/v1/clusters.
+type HubConfig struct {
+	Enabled      bool               `yaml:"enabled"`
+	PollInterval time.Duration      `yaml:"pollInterval"` // How often each remote is polled (default 5m)
+	StaleAfter   time.Duration      `yaml:"staleAfter"`   // Age after which cached data is reported stale (default 3x pollInterval)
+	Timeout      time.Duration      `yaml:"timeout"`      // Per-request timeout (default 15s)
+	Clusters     []HubClusterConfig `yaml:"clusters"`
+}
+
+// HubClusterConfig registers one remote cluster. Either URL or Kubeconfig
+// must be set: URL reaches the remote API directly, Kubeconfig reaches it
+// through the remote API server's service proxy.
+type HubClusterConfig struct {
+	Name               string `yaml:"name"`               // Cluster ID shown in the fleet view
+	URL                string `yaml:"url"`                // Remote API base URL, e.g. "https://koptimizer.prod-eu.example.com"
+	Token              string `yaml:"token"`              // Bearer token for a URL endpoint (viewer role is enough)
+	TokenFile          string `yaml:"tokenFile"`          // Read the token from a file on every poll, e.g. a mounted Secret
+	Kubeconfig         string `yaml:"kubeconfig"`         // Path to a kubeconfig for the remote cluster
+	Context            string `yaml:"context"`            // Kubeconfig context (default: current context)
+	Namespace          string `yaml:"namespace"`          // Namespace of the remote koptimizer Service (default koptimizer-system)
+	Service            string `yaml:"service"`            // Remote Service name (default koptimizer)
+	Port               int    `yaml:"port"`               // Remote Service port (default 8080)
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"` // Skip TLS verification for URL endpoints
+}
+
+// ChargebackConfig controls monthly chargeback statements. Reports can
+// always be downloaded from /api/v1/cost/chargeback; Enabled additionally
+// exports the previous month on Schedule to Directory and/or S3.
+type ChargebackConfig struct {
+	Enabled         bool               `yaml:"enabled"`
+	Schedule        string             `yaml:"schedule"`        // Cron schedule for the export (default "0 6 1 * *", 06:00 on the 1st)
+	GroupBy         []string           `yaml:"groupBy"`         // "namespace", "team" and/or "costCenter" (default namespace)
+	Formats         []string           `yaml:"formats"`         // "csv" and/or "focus" (default both)
+	TeamLabel       string             `yaml:"teamLabel"`       // Namespace label naming the owning team (default "team")
+	CostCenterLabel string             `yaml:"costCenterLabel"` // Namespace label naming the cost center (default "cost-center")
+	Directory       string             `yaml:"directory"`       // Local directory to write reports to
+	S3              ChargebackS3Config `yaml:"s3"`
+}
+
+// ChargebackS3Config uploads reports to an S3-compatible bucket. Credentials
+// come from the standard AWS chain (env vars, shared config, IRSA).
+type ChargebackS3Config struct {
+	Bucket       string `yaml:"bucket"`
+	Prefix       string `yaml:"prefix"`       // Key prefix, e.g. "chargeback/prod"
+	Region       string `yaml:"region"`       // Bucket region (default: top-level region, or us-east-1)
+	Endpoint     string `yaml:"endpoint"`     // Custom endpoint for S3-compatible stores, e.g. "https://minio.example.com"
+	UsePathStyle bool   `yaml:"usePathStyle"` // Address the bucket in the path instead of the host name
+}
+
 // DefaultConfig returns a Config with sensible defaults.
 // Cloud provider and region can be set via CLOUD_PROVIDER and REGION env vars.
 func DefaultConfig() *Config {
@@ -210,6 +484,10 @@ func DefaultConfig() *Config {
 		CostMonitor: CostMonitorConfig{
 			Enabled:        true,
 			UpdateInterval: 5 * time.Minute,
+			Allocation: CostAllocationConfig{
+				SharedNamespaces: []string{"kube-system", "monitoring", "ingress-nginx"},
+				SharedStrategy:   "proportional",
+			},
 		},
 		NodeGroupMgr: NodeGroupMgrConfig{
 			Enabled: true,
@@ -224,6 +502,31 @@ func DefaultConfig() *Config {
 			MinKeepRatio:        0.7,
 			OOMBumpMultiplier:   2.5,
 			ExcludeNamespaces:   []string{"kube-system"},
+			SidecarPolicy:       "skip",
+			SidecarContainers: []string{
+				"istio-proxy", "linkerd-proxy", "envoy", "vault-agent",
+				"fluent-bit", "fluentd", "filebeat",
+			},
+			Watchdog: RightsizingWatchdogConfig{
+				Enabled:             true,
+				Window:              24 * time.Hour,
+				MaxRestarts:         3,
+				MaxReadinessDropPct: 20.0,
+			},
+			Throttling: RightsizingThrottlingConfig{
+				Enabled:        true,
+				ThrottledPct:   25.0,
+				CPULimitPolicy: "raise",
+			},
+			Limits: RightsizingLimitsConfig{
+				DownsizeCPULimit:   "keepRatio",
+				CPULimitMultiplier: 2.0,
+				MemoryLimits:       true,
+				MemoryHeadroomPct:  25.0,
+			},
+			Rollout: RightsizingRolloutConfig{
+				Strategy: "restart",
+			},
 		},
 		WorkloadScaler: WorkloadScalerConfig{
 			Enabled:            false,
@@ -235,12 +538,44 @@ func DefaultConfig() *Config {
 			ConfidenceStartPct: 50.0,
 			ConfidenceFullDays: 7,
 			ExcludeNamespaces:  []string{"kube-system", "monitoring"},
+			Predictive: PredictiveScalingConfig{
+				Lookahead:           time.Hour,
+				TargetCPUUtilPct:    70,
+				Headroom:            1.1,
+				MaxForecastErrorPct: 25,
+			},
+			HPATuning: HPATuningConfig{
+				Enabled:           true,
+				HistoryWindow:     24 * time.Hour,
+				MinHistory:        6 * time.Hour,
+				MaxUtilizationPct: 90,
+				FlapsPerHour:      2,
+				MinReplicasFloor:  2,
+				AutoApply:         true,
+			},
 		},
 		PodPurger: PodPurgerConfig{
 			Enabled:      true,
 			PollInterval: 5 * time.Minute,
 			MinPodAge:    30 * time.Minute,
 		},
+		Evictor: EvictorConfig{
+			Enabled:                 false,
+			UtilizationThresholdPct: 50.0,
+			MaxPodsPerEviction:      10,
+			MaxNodesPerCycle:        1,
+			DrainTimeout:            5 * time.Minute,
+		},
+		Rebalancer: RebalancerConfig{
+			Enabled:            false,
+			Schedule:           "0 3 * * SUN",
+			MaintenanceWindow:  2 * time.Hour,
+			ImbalanceThreshold: 0.2,
+			WaveSize:           2,
+			WaveInterval:       2 * time.Minute,
+			MaxNodesPerRun:     6,
+			DrainTimeout:       5 * time.Minute,
+		},
 		GPU: GPUConfig{
 			Enabled:                      true,
 			IdleThresholdPct:             5.0,
@@ -250,6 +585,17 @@ func DefaultConfig() *Config {
 			ScavengingCPUThresholdMillis: 2000,
 			ReclaimEnabled:               true,
 			ReclaimGracePeriod:           5 * time.Minute,
+			MetricsSource:                "allocation",
+			DCGMNamespace:                "gpu-operator",
+			DCGMLabelSelector:            "app=nvidia-dcgm-exporter",
+			DCGMPort:                     9400,
+			MetricsScrapeTimeout:         5 * time.Second,
+		},
+		Metrics: MetricsConfig{
+			Backend:      "metrics-server",
+			Retention:    7 * 24 * time.Hour,
+			QueryTimeout: 30 * time.Second,
+			Step:         60 * time.Second,
 		},
 		Spot: SpotConfig{
 			Enabled:                 true,
@@ -292,16 +638,43 @@ func DefaultConfig() *Config {
 			CostThresholdUSD:  500.0,
 			ScaleThresholdPct: 30.0,
 			MaxEvictNodes:     3,
+			Validators:        []string{"anthropic"},
 		},
 		APIServer: APIServerConfig{
 			Enabled: true,
 			Address: "0.0.0.0",
 			Port:    8080,
+			MCP: APIMCPConfig{
+				Enabled:      true,
+				PollInterval: 30 * time.Second,
+			},
 		},
 		Database: DatabaseConfig{
 			Path:          "/tmp/koptimizer.db",
 			RetentionDays: 90,
 		},
+		Hub: HubConfig{
+			PollInterval: 5 * time.Minute,
+			Timeout:      15 * time.Second,
+		},
+		Chargeback: ChargebackConfig{
+			Schedule:        "0 6 1 * *",
+			GroupBy:         []string{"namespace"},
+			Formats:         []string{"csv", "focus"},
+			TeamLabel:       "team",
+			CostCenterLabel: "cost-center",
+		},
+		Webhook: WebhookConfig{
+			Port:                  9443,
+			CertDir:               "/tmp/k8s-webhook-server/serving-certs",
+			InjectRecommendations: true,
+			DefaultCPURequest:     "100m",
+			DefaultMemoryRequest:  "128Mi",
+			Policy: WebhookPolicyConfig{
+				Action:              "warn",
+				GPUFallbackRequests: true,
+			},
+		},
 	}
 
 	// NodeGroupMgr defaults
@@ -310,6 +683,7 @@ func DefaultConfig() *Config {
 	cfg.NodeGroupMgr.MinAdjustment.ObservationPeriod = 48 * time.Hour
 	cfg.NodeGroupMgr.EmptyGroupDetection.Enabled = true
 	cfg.NodeGroupMgr.EmptyGroupDetection.EmptyPeriod = 14 * 24 * time.Hour
+	cfg.NodeGroupMgr.Karpenter.Enabled = true
 
 	cfg.applyEnvOverrides()
 	return cfg
@@ -336,10 +710,19 @@ func LoadFromFile(path string) (*Config, error) {
 		return nil, fmt.Errorf("parsing config file: %w", err)
 	}
 
+	cfg.warnDeprecated()
 	cfg.applyEnvOverrides()
 	return cfg, nil
 }
 
+// warnDeprecated logs settings that are still accepted but no longer used.
+func (c *Config) warnDeprecated() {
+	if pct := c.Rightsizer.Watchdog.ThrottlePct; pct != 0 {
+		slog.Warn("rightsizer.watchdog.throttlePct is deprecated and ignored; the watchdog judges CPU throttling by rightsizer.throttling.throttledPct",
+			"throttlePct", pct, "throttledPct", c.Rightsizer.Throttling.ThrottledPct)
+	}
+}
+
 // applyEnvOverrides fills in empty fields from environment variables.
 // This handles cases where the config file has empty values but cloud-specific
 // env vars are set (e.g., by the Helm chart or the cloud platform).
@@ -375,10 +758,33 @@ func (c *Config) applyEnvOverrides() {
 	if v := os.Getenv("KOPTIMIZER_SLACK_WEBHOOK_URL"); v != "" {
 		c.Alerts.SlackWebhookURL = v
 	}
+	// API key for an OpenAI-compatible AI Gate endpoint
+	if v := os.Getenv("KOPTIMIZER_AI_GATE_OPENAI_API_KEY"); v != "" {
+		c.AIGate.OpenAI.APIKey = v
+	}
 	// GitLab token for Helm drift detection
 	if v := os.Getenv("KATALYST_GITLAB_TOKEN"); v != "" {
 		c.HelmDrift.GitLabToken = v
 	}
+	// Shared API token (also injected by the dashboard proxy). Setting it
+	// turns on API authentication with the token as an admin principal.
+	if v := os.Getenv("KOPTIMIZER_API_TOKEN"); v != "" {
+		found := false
+		for _, t := range c.APIServer.Auth.Tokens {
+			if t.Token == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.APIServer.Auth.Tokens = append(c.APIServer.Auth.Tokens, APITokenConfig{Name: "dashboard", Token: v, Role: "admin"})
+		}
+		c.APIServer.Auth.Enabled = true
+	}
+	// Bearer token for the Prometheus metrics backend
+	if v := os.Getenv("KOPTIMIZER_PROMETHEUS_TOKEN"); v != "" {
+		c.Metrics.PrometheusToken = v
+	}
 }
 
 // Validate checks the config for errors.
@@ -433,6 +839,145 @@ func (c *Config) ValidateDetailed() error {
 		return fmt.Errorf("trafficPerPodGBPerHour must be >= 0, got %.2f", c.NetworkMonitor.TrafficPerPodGBPerHour)
 	}
 
+	switch c.CostMonitor.Allocation.SharedStrategy {
+	case "", "proportional", "even", "none":
+	default:
+		return fmt.Errorf("costMonitor.allocation.sharedStrategy must be proportional, even or none, got %q", c.CostMonitor.Allocation.SharedStrategy)
+	}
+	if w := c.CostMonitor.Allocation.UsageWeight; w < 0 || w > 1 {
+		return fmt.Errorf("costMonitor.allocation.usageWeight must be between 0 and 1, got %.2f", w)
+	}
+
+	// Evictor drains nodes, so keep its blast radius explicit.
+	if c.Evictor.Enabled {
+		if c.Evictor.UtilizationThresholdPct <= 0 || c.Evictor.UtilizationThresholdPct > 100 {
+			return fmt.Errorf("evictor.utilizationThresholdPct must be between 0 and 100, got %.1f", c.Evictor.UtilizationThresholdPct)
+		}
+		if c.Evictor.MaxNodesPerCycle < 1 {
+			return fmt.Errorf("evictor.maxNodesPerCycle must be >= 1, got %d", c.Evictor.MaxNodesPerCycle)
+		}
+	}
+
+	switch c.GPU.MetricsSource {
+	case "", "allocation", "dcgm":
+	case "endpoint":
+		if c.GPU.MetricsEndpoint == "" {
+			return fmt.Errorf("gpu.metricsEndpoint is required when gpu.metricsSource is \"endpoint\"")
+		}
+	default:
+		return fmt.Errorf("gpu.metricsSource must be one of allocation, dcgm, endpoint; got %q", c.GPU.MetricsSource)
+	}
+
+	switch c.Rightsizer.SidecarPolicy {
+	case "", "skip", "separate":
+	default:
+		return fmt.Errorf("rightsizer.sidecarPolicy must be skip or separate, got %q", c.Rightsizer.SidecarPolicy)
+	}
+
+	if wd := c.Rightsizer.Watchdog; wd.Enabled {
+		if wd.Window <= 0 {
+			return fmt.Errorf("rightsizer.watchdog.window must be > 0, got %s", wd.Window)
+		}
+		if wd.MaxRestarts < 1 {
+			return fmt.Errorf("rightsizer.watchdog.maxRestarts must be >= 1, got %d", wd.MaxRestarts)
+		}
+		if wd.MaxReadinessDropPct <= 0 || wd.MaxReadinessDropPct > 100 {
+			return fmt.Errorf("rightsizer.watchdog.maxReadinessDropPct must be between 0 and 100, got %.1f", wd.MaxReadinessDropPct)
+		}
+	}
+
+	if th := c.Rightsizer.Throttling; th.Enabled {
+		if th.ThrottledPct <= 0 || th.ThrottledPct > 100 {
+			return fmt.Errorf("rightsizer.throttling.throttledPct must be between 0 and 100, got %.1f", th.ThrottledPct)
+		}
+		switch th.CPULimitPolicy {
+		case "", "raise", "remove", "off":
+		default:
+			return fmt.Errorf("rightsizer.throttling.cpuLimitPolicy must be raise, remove or off, got %q", th.CPULimitPolicy)
+		}
+	}
+
+	lim := c.Rightsizer.Limits
+	switch lim.DownsizeCPULimit {
+	case "", "keepRatio", "multiplier", "remove", "off":
+	default:
+		return fmt.Errorf("rightsizer.limits.downsizeCPULimit must be keepRatio, multiplier, remove or off, got %q", lim.DownsizeCPULimit)
+	}
+	if lim.DownsizeCPULimit == "multiplier" && lim.CPULimitMultiplier < 1 {
+		return fmt.Errorf("rightsizer.limits.cpuLimitMultiplier must be >= 1, got %.2f", lim.CPULimitMultiplier)
+	}
+	if lim.MemoryLimits && (lim.MemoryHeadroomPct < 0 || lim.MemoryHeadroomPct > 100) {
+		return fmt.Errorf("rightsizer.limits.memoryHeadroomPct must be between 0 and 100, got %.1f", lim.MemoryHeadroomPct)
+	}
+
+	if err := c.Rightsizer.Rollout.validate(c.Webhook.Enabled); err != nil {
+		return err
+	}
+
+	if err := c.Webhook.validate(); err != nil {
+		return err
+	}
+
+	if err := c.WorkloadScaler.Predictive.validate(); err != nil {
+		return err
+	}
+	if err := c.WorkloadScaler.HPATuning.validate(); err != nil {
+		return err
+	}
+
+	if err := c.AIGate.validate(); err != nil {
+		return err
+	}
+	if c.WorkloadScaler.Enabled && c.WorkloadScaler.Predictive.Enabled && c.Metrics.Retention > 0 && c.Metrics.Retention < 14*24*time.Hour {
+		slog.Warn("metrics.retention is under two weeks; predictive scaling forecasts each hour of the week from a single week",
+			"retention", c.Metrics.Retention)
+	}
+
+	if c.Rightsizer.Enabled && c.Metrics.Retention > 0 && c.Rightsizer.LookbackWindow > c.Metrics.Retention {
+		slog.Warn("rightsizer.lookbackWindow exceeds metrics.retention; percentiles will only cover the retained history",
+			"lookbackWindow", c.Rightsizer.LookbackWindow, "retention", c.Metrics.Retention)
+	}
+
+	if err := c.APIServer.Auth.validate(); err != nil {
+		return err
+	}
+	if c.APIServer.MCP.Enabled && c.APIServer.MCP.PollInterval < time.Second {
+		return fmt.Errorf("apiServer.mcp.pollInterval must be >= 1s, got %s", c.APIServer.MCP.PollInterval)
+	}
+
+	switch c.Metrics.Backend {
+	case "", "metrics-server":
+	case "prometheus":
+		if c.Metrics.PrometheusURL == "" {
+			return fmt.Errorf("metrics.prometheusURL is required when metrics.backend is \"prometheus\"")
+		}
+		if c.Metrics.Step < time.Second {
+			return fmt.Errorf("metrics.step must be >= 1s, got %s", c.Metrics.Step)
+		}
+	default:
+		return fmt.Errorf("metrics.backend must be one of metrics-server, prometheus; got %q", c.Metrics.Backend)
+	}
+
+	if err := c.Hub.validate(c.ClusterName); err != nil {
+		return err
+	}
+
+	if err := c.Chargeback.validate(); err != nil {
+		return err
+	}
+
+	if c.Rebalancer.Enabled {
+		if c.Rebalancer.ImbalanceThreshold <= 0 || c.Rebalancer.ImbalanceThreshold >= 1 {
+			return fmt.Errorf("rebalancer.imbalanceThreshold must be between 0 and 1, got %.2f", c.Rebalancer.ImbalanceThreshold)
+		}
+		if c.Rebalancer.WaveSize < 1 {
+			return fmt.Errorf("rebalancer.waveSize must be >= 1, got %d", c.Rebalancer.WaveSize)
+		}
+		if c.Rebalancer.MaintenanceWindow <= 0 {
+			return fmt.Errorf("rebalancer.maintenanceWindow must be > 0, got %s", c.Rebalancer.MaintenanceWindow)
+		}
+	}
+
 	// Validate spot percentage bounds to prevent all nodes becoming spot
 	if c.Spot.Enabled {
 		if c.Spot.MaxSpotPercentage > 90 {
@@ -489,6 +1034,10 @@ func (c *Config) SetControllerEnabled(name string, enabled bool) bool {
 		c.AIGate.Enabled = enabled
 	case "podPurger":
 		c.PodPurger.Enabled = enabled
+	case "evictor":
+		c.Evictor.Enabled = enabled
+	case "rebalancer":
+		c.Rebalancer.Enabled = enabled
 	default:
 		return false
 	}
@@ -518,6 +1067,10 @@ func (c *Config) IsControllerEnabled(name string) bool {
 		return c.AIGate.Enabled
 	case "podPurger":
 		return c.PodPurger.Enabled
+	case "evictor":
+		return c.Evictor.Enabled
+	case "rebalancer":
+		return c.Rebalancer.Enabled
 	default:
 		return false
 	}
@@ -535,3 +1088,270 @@ func (c *Config) SetAutoApprove(name string, autoApprove bool) bool {
 	}
 	return true
 }
+
+func validAPIRole(role string) bool {
+	for _, r := range APIRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *APIAuthConfig) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	if len(a.Tokens) == 0 && !a.TokenReview {
+		return fmt.Errorf("apiServer.auth is enabled but no tokens are configured and tokenReview is off")
+	}
+	names := make(map[string]bool, len(a.Tokens))
+	for i, t := range a.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return fmt.Errorf("apiServer.auth.tokens[%d]: name and token are required", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("apiServer.auth.tokens[%d]: duplicate name %q", i, t.Name)
+		}
+		names[t.Name] = true
+		if !validAPIRole(t.Role) {
+			return fmt.Errorf("apiServer.auth.tokens[%d]: invalid role %q", i, t.Role)
+		}
+	}
+	for user, role := range a.UserRoles {
+		if !validAPIRole(role) {
+			return fmt.Errorf("apiServer.auth.userRoles[%s]: invalid role %q", user, role)
+		}
+	}
+	for group, role := range a.GroupRoles {
+		if !validAPIRole(role) {
+			return fmt.Errorf("apiServer.auth.groupRoles[%s]: invalid role %q", group, role)
+		}
+	}
+	if a.DefaultRole != "" && !validAPIRole(a.DefaultRole) {
+		return fmt.Errorf("apiServer.auth.defaultRole: invalid role %q", a.DefaultRole)
+	}
+	return nil
+}
+
+func (h *HubConfig) validate(localName string) error {
+	if !h.Enabled {
+		return nil
+	}
+	if h.PollInterval < 10*time.Second {
+		return fmt.Errorf("hub.pollInterval must be >= 10s, got %s", h.PollInterval)
+	}
+	if h.StaleAfter != 0 && h.StaleAfter < h.PollInterval {
+		return fmt.Errorf("hub.staleAfter must be >= hub.pollInterval, got %s", h.StaleAfter)
+	}
+	if localName == "" {
+		localName = "default"
+	}
+	names := map[string]bool{localName: true}
+	for i, cl := range h.Clusters {
+		if cl.Name == "" {
+			return fmt.Errorf("hub.clusters[%d]: name is required", i)
+		}
+		if names[cl.Name] {
+			return fmt.Errorf("hub.clusters[%d]: duplicate cluster name %q", i, cl.Name)
+		}
+		names[cl.Name] = true
+		if (cl.URL == "") == (cl.Kubeconfig == "") {
+			return fmt.Errorf("hub.clusters[%d]: exactly one of url and kubeconfig must be set", i)
+		}
+		if cl.URL != "" {
+			u, err := url.Parse(cl.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("hub.clusters[%d]: url must be an absolute http(s) URL, got %q", i, cl.URL)
+			}
+		}
+		if cl.Token != "" && cl.TokenFile != "" {
+			return fmt.Errorf("hub.clusters[%d]: set at most one of token and tokenFile", i)
+		}
+		if cl.Kubeconfig != "" && (cl.Token != "" || cl.TokenFile != "") {
+			return fmt.Errorf("hub.clusters[%d]: token and tokenFile only apply to url endpoints; kubeconfig credentials are used instead", i)
+		}
+	}
+	return nil
+}
+
+func (r *RightsizingRolloutConfig) validate(webhookEnabled bool) error {
+	switch r.Strategy {
+	case "", "restart":
+	case "nextRollout":
+		if !webhookEnabled {
+			return errors.New("rightsizer.rollout.strategy \"nextRollout\" requires webhook.enabled")
+		}
+	default:
+		return fmt.Errorf("rightsizer.rollout.strategy must be restart or nextRollout, got %q", r.Strategy)
+	}
+	if r.NamespaceDailyBudget < 0 {
+		return fmt.Errorf("rightsizer.rollout.namespaceDailyBudget must be >= 0, got %d", r.NamespaceDailyBudget)
+	}
+	for i, w := range r.MaintenanceWindows {
+		if _, err := cron.ParseStandard(w.Schedule); err != nil {
+			return fmt.Errorf("rightsizer.rollout.maintenanceWindows[%d].schedule %q: %w", i, w.Schedule, err)
+		}
+		if w.Duration <= 0 {
+			return fmt.Errorf("rightsizer.rollout.maintenanceWindows[%d].duration must be > 0, got %s", i, w.Duration)
+		}
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("rightsizer.rollout.maintenanceWindows[%d].timezone %q: %w", i, w.Timezone, err)
+		}
+	}
+	return nil
+}
+
+func (p *PredictiveScalingConfig) validate() error {
+	if !p.Enabled {
+		return nil
+	}
+	if p.Lookahead <= 0 || p.Lookahead > 24*time.Hour {
+		return fmt.Errorf("workloadScaler.predictive.lookahead must be between 0 and 24h, got %s", p.Lookahead)
+	}
+	if p.TargetCPUUtilPct <= 0 || p.TargetCPUUtilPct > 100 {
+		return fmt.Errorf("workloadScaler.predictive.targetCPUUtilPct must be between 0 and 100, got %.1f", p.TargetCPUUtilPct)
+	}
+	if p.Headroom < 1 {
+		return fmt.Errorf("workloadScaler.predictive.headroom must be >= 1, got %.2f", p.Headroom)
+	}
+	if p.MaxForecastErrorPct <= 0 {
+		return fmt.Errorf("workloadScaler.predictive.maxForecastErrorPct must be > 0, got %.1f", p.MaxForecastErrorPct)
+	}
+	return nil
+}
+
+func (a *AIGateConfig) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, v := range a.Validators {
+		switch v {
+		case "rules":
+			if len(a.Rules) == 0 {
+				return fmt.Errorf("aiGate.validators includes \"rules\" but aiGate.rules is empty")
+			}
+		case "anthropic":
+		case "openai":
+			u, err := url.Parse(a.OpenAI.BaseURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("aiGate.openai.baseURL must be an http(s) URL, got %q", a.OpenAI.BaseURL)
+			}
+			if a.OpenAI.Model == "" {
+				return fmt.Errorf("aiGate.openai.model is required for the \"openai\" validator")
+			}
+		default:
+			return fmt.Errorf("aiGate.validators: unknown validator %q (want rules, anthropic or openai)", v)
+		}
+		if seen[v] {
+			return fmt.Errorf("aiGate.validators lists %q twice", v)
+		}
+		seen[v] = true
+	}
+	for i, r := range a.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("aiGate.rules[%d].name is required", i)
+		}
+		if r.When == "" {
+			return fmt.Errorf("aiGate.rules[%d] (%s): when is required", i, r.Name)
+		}
+		if r.Decision != "approve" && r.Decision != "reject" {
+			return fmt.Errorf("aiGate.rules[%d] (%s): decision must be \"approve\" or \"reject\", got %q", i, r.Name, r.Decision)
+		}
+	}
+	if len(a.Rules) > 0 && !seen["rules"] {
+		slog.Warn("aiGate.rules are set but aiGate.validators does not include \"rules\"; the rules are ignored")
+	}
+	return nil
+}
+
+func (t *HPATuningConfig) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+	if t.HistoryWindow <= 0 {
+		return fmt.Errorf("workloadScaler.hpaTuning.historyWindow must be > 0, got %s", t.HistoryWindow)
+	}
+	if t.MinHistory <= 0 || t.MinHistory > t.HistoryWindow {
+		return fmt.Errorf("workloadScaler.hpaTuning.minHistory must be between 0 and historyWindow (%s), got %s", t.HistoryWindow, t.MinHistory)
+	}
+	if t.MaxUtilizationPct <= 0 || t.MaxUtilizationPct > 100 {
+		return fmt.Errorf("workloadScaler.hpaTuning.maxUtilizationPct must be between 0 and 100, got %.1f", t.MaxUtilizationPct)
+	}
+	if t.FlapsPerHour <= 0 {
+		return fmt.Errorf("workloadScaler.hpaTuning.flapsPerHour must be > 0, got %.2f", t.FlapsPerHour)
+	}
+	if t.MinReplicasFloor < 1 {
+		return fmt.Errorf("workloadScaler.hpaTuning.minReplicasFloor must be >= 1, got %d", t.MinReplicasFloor)
+	}
+	return nil
+}
+
+func (w *WebhookConfig) validate() error {
+	if !w.Enabled {
+		return nil
+	}
+	if w.Port <= 0 || w.Port > 65535 {
+		return fmt.Errorf("webhook.port must be between 1 and 65535, got %d", w.Port)
+	}
+	if w.DefaultCPURequest != "" {
+		if _, err := resource.ParseQuantity(w.DefaultCPURequest); err != nil {
+			return fmt.Errorf("webhook.defaultCPURequest %q: %w", w.DefaultCPURequest, err)
+		}
+	}
+	if w.DefaultMemoryRequest != "" {
+		if _, err := resource.ParseQuantity(w.DefaultMemoryRequest); err != nil {
+			return fmt.Errorf("webhook.defaultMemoryRequest %q: %w", w.DefaultMemoryRequest, err)
+		}
+	}
+	switch w.Policy.Action {
+	case "", "warn", "deny":
+	default:
+		return fmt.Errorf("webhook.policy.action must be warn or deny, got %q", w.Policy.Action)
+	}
+	if r := w.Policy.MaxCPULimitRatio; r != 0 && r < 1 {
+		return fmt.Errorf("webhook.policy.maxCPULimitRatio must be 0 or >= 1, got %.2f", r)
+	}
+	if r := w.Policy.MaxMemoryLimitRatio; r != 0 && r < 1 {
+		return fmt.Errorf("webhook.policy.maxMemoryLimitRatio must be 0 or >= 1, got %.2f", r)
+	}
+	return nil
+}
+
+func (cb *ChargebackConfig) validate() error {
+	if !cb.Enabled {
+		return nil
+	}
+	if cb.Schedule == "" {
+		return errors.New("chargeback.schedule is required")
+	}
+	if len(cb.GroupBy) == 0 {
+		return errors.New("chargeback.groupBy must list at least one of namespace, team, costCenter")
+	}
+	for _, g := range cb.GroupBy {
+		switch g {
+		case "namespace", "team", "costCenter":
+		default:
+			return fmt.Errorf("chargeback.groupBy must be namespace, team or costCenter, got %q", g)
+		}
+	}
+	if len(cb.Formats) == 0 {
+		return errors.New("chargeback.formats must list at least one of csv, focus")
+	}
+	for _, f := range cb.Formats {
+		if f != "csv" && f != "focus" {
+			return fmt.Errorf("chargeback.formats must be csv or focus, got %q", f)
+		}
+	}
+	if cb.Directory == "" && cb.S3.Bucket == "" {
+		return errors.New("chargeback: set directory and/or s3.bucket to export reports")
+	}
+	if cb.S3.Endpoint != "" {
+		u, err := url.Parse(cb.S3.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("chargeback.s3.endpoint must be an absolute http(s) URL, got %q", cb.S3.Endpoint)
+		}
+	}
+	return nil
+}
@@ -120,6 +120,33 @@ region: eastus
 	}
 }
 
+func TestLoadFromFile_DeprecatedWatchdogThrottlePct(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "legacy.yaml")
+
+	// rightsizer.watchdog.throttlePct is still accepted but no longer used.
+	yamlContent := []byte(`cloudProvider: aws
+region: us-east-1
+rightsizer:
+  watchdog:
+    throttlePct: 95
+`)
+	if err := os.WriteFile(path, yamlContent, 0644); err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+
+	cfg, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile(%q) returned error: %v", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	if cfg.Rightsizer.Throttling.ThrottledPct != 25 {
+		t.Errorf("Throttling.ThrottledPct = %v, want default 25", cfg.Rightsizer.Throttling.ThrottledPct)
+	}
+}
+
 func TestLoadFromFile_InvalidPath(t *testing.T) {
 	_, err := LoadFromFile("/nonexistent/path/config.yaml")
 	if err == nil {
@@ -267,3 +294,374 @@ func TestValidate_BoundarySurgeThreshold(t *testing.T) {
 		t.Errorf("Validate() with SurgeThreshold=1.0 should pass, got error: %v", err)
 	}
 }
+
+func TestValidateDetailed_APIAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    APIAuthConfig
+		wantErr bool
+	}{
+		{name: "disabled", auth: APIAuthConfig{}, wantErr: false},
+		{name: "enabled without credentials", auth: APIAuthConfig{Enabled: true}, wantErr: true},
+		{name: "tokenReview only", auth: APIAuthConfig{Enabled: true, TokenReview: true, DefaultRole: "viewer"}, wantErr: false},
+		{name: "valid token", auth: APIAuthConfig{Enabled: true, Tokens: []APITokenConfig{{Name: "ci", Token: "t", Role: "operator"}}}, wantErr: false},
+		{name: "invalid token role", auth: APIAuthConfig{Enabled: true, Tokens: []APITokenConfig{{Name: "ci", Token: "t", Role: "root"}}}, wantErr: true},
+		{name: "duplicate token name", auth: APIAuthConfig{Enabled: true, Tokens: []APITokenConfig{{Name: "ci", Token: "a", Role: "viewer"}, {Name: "ci", Token: "b", Role: "viewer"}}}, wantErr: true},
+		{name: "invalid group role", auth: APIAuthConfig{Enabled: true, TokenReview: true, GroupRoles: map[string]string{"sre": "superuser"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			cfg.APIServer.Auth = tt.auth
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_APIMCP(t *testing.T) {
+	tests := []struct {
+		name    string
+		mcp     APIMCPConfig
+		wantErr bool
+	}{
+		{name: "default", mcp: DefaultConfig().APIServer.MCP, wantErr: false},
+		{name: "disabled without interval", mcp: APIMCPConfig{}, wantErr: false},
+		{name: "poll interval too short", mcp: APIMCPConfig{Enabled: true, PollInterval: 100 * time.Millisecond}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			cfg.APIServer.MCP = tt.mcp
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_Hub(t *testing.T) {
+	remote := func(name, url string) HubClusterConfig { return HubClusterConfig{Name: name, URL: url} }
+	tests := []struct {
+		name    string
+		hub     HubConfig
+		wantErr bool
+	}{
+		{name: "disabled", hub: HubConfig{Clusters: []HubClusterConfig{{}}}, wantErr: false},
+		{name: "valid url", hub: HubConfig{Enabled: true, PollInterval: time.Minute, Clusters: []HubClusterConfig{remote("eu", "https://eu.example.com")}}, wantErr: false},
+		{name: "valid kubeconfig", hub: HubConfig{Enabled: true, PollInterval: time.Minute, Clusters: []HubClusterConfig{{Name: "eu", Kubeconfig: "/etc/hub/eu"}}}, wantErr: false},
+		{name: "poll interval too short", hub: HubConfig{Enabled: true, PollInterval: time.Second}, wantErr: true},
+		{name: "staleAfter below interval", hub: HubConfig{Enabled: true, PollInterval: time.Minute, StaleAfter: time.Second * 30}, wantErr: true},
+		{name: "missing name", hub: HubConfig{Enabled: true, PollInterval: time.Minute, Clusters: []HubClusterConfig{remote("", "https://eu.example.com")}}, wantErr: true},
+		{name: "name clashes with local cluster", hub: HubConfig{Enabled: true, PollInterval: time.Minute, Clusters: []HubClusterConfig{remote("prod", "https://eu.example.com")}}, wantErr: true},
+		{name: "duplicate name", hub: HubConfig{Enabled: true, PollInterval: time.Minute, Clusters: []HubClusterConfig{remote("eu", "https://a"), remote("eu", "https://b")}}, wantErr: true},
+		{name: "neither url nor kubeconfig", hub: HubConfig{Enabled: true, PollInterval: time.Minute, Clusters: []HubClusterConfig{{Name: "eu"}}}, wantErr: true},
+		{name: "relative url", hub: HubConfig{Enabled: true, PollInterval: time.Minute, Clusters: []HubClusterConfig{remote("eu", "eu.example.com:8080")}}, wantErr: true},
+		{name: "token with kubeconfig", hub: HubConfig{Enabled: true, PollInterval: time.Minute, Clusters: []HubClusterConfig{{Name: "eu", Kubeconfig: "/k", Token: "t"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			cfg.ClusterName = "prod"
+			cfg.Hub = tt.hub
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_CostAllocation(t *testing.T) {
+	tests := []struct {
+		name       string
+		allocation CostAllocationConfig
+		wantErr    bool
+	}{
+		{name: "defaults", allocation: CostAllocationConfig{}, wantErr: false},
+		{name: "even with usage blend", allocation: CostAllocationConfig{SharedStrategy: "even", UsageWeight: 0.5}, wantErr: false},
+		{name: "unknown strategy", allocation: CostAllocationConfig{SharedStrategy: "weighted"}, wantErr: true},
+		{name: "usage weight above 1", allocation: CostAllocationConfig{UsageWeight: 1.5}, wantErr: true},
+		{name: "negative usage weight", allocation: CostAllocationConfig{UsageWeight: -0.1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			cfg.CostMonitor.Allocation = tt.allocation
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_Chargeback(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(cb *ChargebackConfig)
+		wantErr bool
+	}{
+		{name: "disabled defaults", mutate: func(cb *ChargebackConfig) {}, wantErr: false},
+		{name: "directory export", mutate: func(cb *ChargebackConfig) { cb.Enabled = true; cb.Directory = "/reports" }, wantErr: false},
+		{name: "s3 export", mutate: func(cb *ChargebackConfig) {
+			cb.Enabled = true
+			cb.S3 = ChargebackS3Config{Bucket: "finops", Endpoint: "https://minio.example.com", UsePathStyle: true}
+		}, wantErr: false},
+		{name: "no destination", mutate: func(cb *ChargebackConfig) { cb.Enabled = true }, wantErr: true},
+		{name: "unknown groupBy", mutate: func(cb *ChargebackConfig) {
+			cb.Enabled = true
+			cb.Directory = "/reports"
+			cb.GroupBy = []string{"pod"}
+		}, wantErr: true},
+		{name: "unknown format", mutate: func(cb *ChargebackConfig) {
+			cb.Enabled = true
+			cb.Directory = "/reports"
+			cb.Formats = []string{"xlsx"}
+		}, wantErr: true},
+		{name: "relative s3 endpoint", mutate: func(cb *ChargebackConfig) {
+			cb.Enabled = true
+			cb.S3 = ChargebackS3Config{Bucket: "finops", Endpoint: "minio:9000"}
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			tt.mutate(&cfg.Chargeback)
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_RightsizingRollout(t *testing.T) {
+	window := MaintenanceWindow{Schedule: "0 2 * * MON-FRI", Duration: 3 * time.Hour, Timezone: "Europe/Berlin"}
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr bool
+	}{
+		{name: "defaults", mutate: func(c *Config) {}, wantErr: false},
+		{name: "window and budget", mutate: func(c *Config) {
+			c.Rightsizer.Rollout.MaintenanceWindows = []MaintenanceWindow{window}
+			c.Rightsizer.Rollout.NamespaceDailyBudget = 3
+		}, wantErr: false},
+		{name: "window without timezone", mutate: func(c *Config) {
+			c.Rightsizer.Rollout.MaintenanceWindows = []MaintenanceWindow{{Schedule: "30 1 * * *", Duration: time.Hour}}
+		}, wantErr: false},
+		{name: "next rollout with webhook", mutate: func(c *Config) {
+			c.Rightsizer.Rollout.Strategy = "nextRollout"
+			c.Webhook.Enabled = true
+		}, wantErr: false},
+		{name: "next rollout without webhook", mutate: func(c *Config) { c.Rightsizer.Rollout.Strategy = "nextRollout" }, wantErr: true},
+		{name: "unknown strategy", mutate: func(c *Config) { c.Rightsizer.Rollout.Strategy = "recreate" }, wantErr: true},
+		{name: "negative budget", mutate: func(c *Config) { c.Rightsizer.Rollout.NamespaceDailyBudget = -1 }, wantErr: true},
+		{name: "invalid schedule", mutate: func(c *Config) {
+			c.Rightsizer.Rollout.MaintenanceWindows = []MaintenanceWindow{{Schedule: "nightly", Duration: time.Hour}}
+		}, wantErr: true},
+		{name: "zero duration", mutate: func(c *Config) {
+			c.Rightsizer.Rollout.MaintenanceWindows = []MaintenanceWindow{{Schedule: window.Schedule}}
+		}, wantErr: true},
+		{name: "unknown timezone", mutate: func(c *Config) {
+			c.Rightsizer.Rollout.MaintenanceWindows = []MaintenanceWindow{{Schedule: window.Schedule, Duration: time.Hour, Timezone: "Mars/Olympus"}}
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			tt.mutate(cfg)
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_Webhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(w *WebhookConfig)
+		wantErr bool
+	}{
+		{name: "defaults", mutate: func(w *WebhookConfig) {}, wantErr: false},
+		{name: "deny with ratios", mutate: func(w *WebhookConfig) {
+			w.Policy.Action = "deny"
+			w.Policy.MaxCPULimitRatio = 4
+			w.Policy.MaxMemoryLimitRatio = 1
+		}, wantErr: false},
+		{name: "no default requests", mutate: func(w *WebhookConfig) {
+			w.DefaultCPURequest = ""
+			w.DefaultMemoryRequest = ""
+		}, wantErr: false},
+		{name: "invalid port", mutate: func(w *WebhookConfig) { w.Port = 70000 }, wantErr: true},
+		{name: "invalid cpu default", mutate: func(w *WebhookConfig) { w.DefaultCPURequest = "lots" }, wantErr: true},
+		{name: "invalid memory default", mutate: func(w *WebhookConfig) { w.DefaultMemoryRequest = "1 GB" }, wantErr: true},
+		{name: "unknown action", mutate: func(w *WebhookConfig) { w.Policy.Action = "block" }, wantErr: true},
+		{name: "ratio below one", mutate: func(w *WebhookConfig) { w.Policy.MaxCPULimitRatio = 0.5 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			cfg.Webhook.Enabled = true
+			tt.mutate(&cfg.Webhook)
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_PredictiveScaling(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(p *PredictiveScalingConfig)
+		wantErr bool
+	}{
+		{name: "defaults", mutate: func(p *PredictiveScalingConfig) {}, wantErr: false},
+		{name: "zero lookahead", mutate: func(p *PredictiveScalingConfig) { p.Lookahead = 0 }, wantErr: true},
+		{name: "lookahead over a day", mutate: func(p *PredictiveScalingConfig) { p.Lookahead = 25 * time.Hour }, wantErr: true},
+		{name: "target utilization over 100", mutate: func(p *PredictiveScalingConfig) { p.TargetCPUUtilPct = 120 }, wantErr: true},
+		{name: "headroom below one", mutate: func(p *PredictiveScalingConfig) { p.Headroom = 0.9 }, wantErr: true},
+		{name: "zero max forecast error", mutate: func(p *PredictiveScalingConfig) { p.MaxForecastErrorPct = 0 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			cfg.WorkloadScaler.Predictive.Enabled = true
+			tt.mutate(&cfg.WorkloadScaler.Predictive)
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_HPATuning(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(h *HPATuningConfig)
+		wantErr bool
+	}{
+		{name: "defaults", mutate: func(h *HPATuningConfig) {}, wantErr: false},
+		{name: "disabled ignores values", mutate: func(h *HPATuningConfig) { h.Enabled = false; h.HistoryWindow = 0 }, wantErr: false},
+		{name: "zero history window", mutate: func(h *HPATuningConfig) { h.HistoryWindow = 0 }, wantErr: true},
+		{name: "min history over window", mutate: func(h *HPATuningConfig) { h.MinHistory = 48 * time.Hour }, wantErr: true},
+		{name: "max utilization over 100", mutate: func(h *HPATuningConfig) { h.MaxUtilizationPct = 110 }, wantErr: true},
+		{name: "zero flaps per hour", mutate: func(h *HPATuningConfig) { h.FlapsPerHour = 0 }, wantErr: true},
+		{name: "zero min replicas floor", mutate: func(h *HPATuningConfig) { h.MinReplicasFloor = 0 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			tt.mutate(&cfg.WorkloadScaler.HPATuning)
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDetailed_AIGateValidators(t *testing.T) {
+	rule := AIGateRule{Name: "small", When: "impact.nodesAffected <= 1", Decision: "approve"}
+	tests := []struct {
+		name    string
+		mutate  func(a *AIGateConfig)
+		wantErr bool
+	}{
+		{name: "defaults", mutate: func(a *AIGateConfig) {}, wantErr: false},
+		{name: "rules then anthropic", mutate: func(a *AIGateConfig) {
+			a.Validators = []string{"rules", "anthropic"}
+			a.Rules = []AIGateRule{rule}
+		}, wantErr: false},
+		{name: "openai", mutate: func(a *AIGateConfig) {
+			a.Validators = []string{"openai"}
+			a.OpenAI = AIGateOpenAIConfig{BaseURL: "http://vllm.ai.svc:8000/v1", Model: "llama"}
+		}, wantErr: false},
+		{name: "unknown validator", mutate: func(a *AIGateConfig) { a.Validators = []string{"gemini"} }, wantErr: true},
+		{name: "duplicate validator", mutate: func(a *AIGateConfig) { a.Validators = []string{"anthropic", "anthropic"} }, wantErr: true},
+		{name: "rules without rules", mutate: func(a *AIGateConfig) { a.Validators = []string{"rules"} }, wantErr: true},
+		{name: "openai without URL", mutate: func(a *AIGateConfig) {
+			a.Validators = []string{"openai"}
+			a.OpenAI.Model = "llama"
+		}, wantErr: true},
+		{name: "openai without model", mutate: func(a *AIGateConfig) {
+			a.Validators = []string{"openai"}
+			a.OpenAI.BaseURL = "http://vllm:8000/v1"
+		}, wantErr: true},
+		{name: "rule with bad decision", mutate: func(a *AIGateConfig) {
+			a.Validators = []string{"rules"}
+			a.Rules = []AIGateRule{{Name: "x", When: "true", Decision: "allow"}}
+		}, wantErr: true},
+		{name: "rule without condition", mutate: func(a *AIGateConfig) {
+			a.Validators = []string{"rules"}
+			a.Rules = []AIGateRule{{Name: "x", Decision: "approve"}}
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CloudProvider = "aws"
+			cfg.Region = "us-east-1"
+			cfg.AIGate.Enabled = true
+			tt.mutate(&cfg.AIGate)
+			err := cfg.ValidateDetailed()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyEnvOverrides_APIToken(t *testing.T) {
+	t.Setenv("KOPTIMIZER_API_TOKEN", "s3cret")
+	cfg := DefaultConfig()
+	cfg.applyEnvOverrides()
+	cfg.applyEnvOverrides() // idempotent
+
+	if !cfg.APIServer.Auth.Enabled {
+		t.Error("KOPTIMIZER_API_TOKEN should enable API auth")
+	}
+	if len(cfg.APIServer.Auth.Tokens) != 1 {
+		t.Fatalf("Tokens = %d, want 1", len(cfg.APIServer.Auth.Tokens))
+	}
+	if tok := cfg.APIServer.Auth.Tokens[0]; tok.Name != "dashboard" || tok.Role != "admin" || tok.Token != "s3cret" {
+		t.Errorf("unexpected token: %+v", tok)
+	}
+}
@@ -0,0 +1,194 @@
+package costmonitor
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// podShare is the fraction of a node's CPU, memory and GPU capacity
+// allocated to one pod.
+type podShare struct {
+	namespace     string
+	cpu, mem, gpu float64
+}
+
+// Allocate attributes the full cost of every node. Each node's cost is
+// split into CPU, memory and (on GPU nodes) GPU pools; running pods are
+// charged their share of each pool and whatever remains is the node
+// group's idle cost. Shared namespaces are then redistributed across the
+// tenant namespaces according to opts.SharedStrategy.
+//
+// A pod's share is (1-w)*request + w*usage with w = opts.UsageWeight; pods
+// without a usage sample fall back to their requests, and GPUs are always
+// charged by request. When the pods on a node add up to more than its
+// capacity their shares are scaled down, so the result always reconciles
+// with the node cost.
+func (a *Allocator) Allocate(ctx context.Context, snapshot *optimizer.ClusterSnapshot, opts config.CostAllocationConfig) (*cost.Allocation, error) {
+	strategy := opts.SharedStrategy
+	if strategy == "" {
+		strategy = cost.SharedProportional
+	}
+	shared := make(map[string]bool, len(opts.SharedNamespaces))
+	for _, ns := range opts.SharedNamespaces {
+		shared[ns] = true
+	}
+	usage := make(map[string]*optimizer.PodInfo, len(snapshot.Pods))
+	for i := range snapshot.Pods {
+		p := &snapshot.Pods[i]
+		usage[p.Pod.Namespace+"/"+p.Pod.Name] = p
+	}
+	groupNames := make(map[string]string, len(snapshot.NodeGroups))
+	for _, ng := range snapshot.NodeGroups {
+		groupNames[ng.ID] = ng.Name
+	}
+
+	result := &cost.Allocation{
+		SharedStrategy: strategy,
+		UsageWeight:    opts.UsageWeight,
+		Timestamp:      snapshot.Timestamp,
+	}
+	byNS := make(map[string]*cost.NamespaceAllocation)
+	idle := make(map[string]*cost.IdleAllocation)
+
+	for _, node := range snapshot.Nodes {
+		nodeCost := node.HourlyCostUSD * cost.HoursPerMonth
+		if nodeCost == 0 {
+			continue
+		}
+		result.TotalMonthlyCostUSD += nodeCost
+
+		gpuPool := 0.0
+		if node.GPUs > 0 {
+			gpuPool = nodeCost * (1 - cost.EstimateCPUCostFraction(node.CPUCapacity, true))
+		}
+		cpuPool := (nodeCost - gpuPool) / 2
+		memPool := nodeCost - gpuPool - cpuPool
+
+		var shares []podShare
+		var sumCPU, sumMem, sumGPU float64
+		for _, pod := range node.Pods {
+			if pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			cpu, mem, gpu := blendedPodResources(pod, usage[pod.Namespace+"/"+pod.Name], opts.UsageWeight)
+			s := podShare{namespace: pod.Namespace}
+			if node.CPUCapacity > 0 {
+				s.cpu = cpu / float64(node.CPUCapacity)
+			}
+			if node.MemoryCapacity > 0 {
+				s.mem = mem / float64(node.MemoryCapacity)
+			}
+			if node.GPUs > 0 {
+				s.gpu = gpu / float64(node.GPUs)
+			}
+			sumCPU += s.cpu
+			sumMem += s.mem
+			sumGPU += s.gpu
+			shares = append(shares, s)
+		}
+		cpuScale, memScale, gpuScale := capScale(sumCPU), capScale(sumMem), capScale(sumGPU)
+
+		for _, s := range shares {
+			na := byNS[s.namespace]
+			if na == nil {
+				na = &cost.NamespaceAllocation{Namespace: s.namespace}
+				byNS[s.namespace] = na
+			}
+			na.CPUCostUSD += cpuPool * s.cpu * cpuScale
+			na.MemoryCostUSD += memPool * s.mem * memScale
+			na.GPUCostUSD += gpuPool * s.gpu * gpuScale
+		}
+
+		ia := idle[node.NodeGroup]
+		if ia == nil {
+			ia = &cost.IdleAllocation{NodeGroupID: node.NodeGroup, NodeGroup: groupNames[node.NodeGroup]}
+			idle[node.NodeGroup] = ia
+		}
+		ia.CPUCostUSD += cpuPool * (1 - sumCPU*cpuScale)
+		ia.MemoryCostUSD += memPool * (1 - sumMem*memScale)
+		ia.GPUCostUSD += gpuPool * (1 - sumGPU*gpuScale)
+	}
+
+	// Split namespaces into tenants and shared, then redistribute.
+	var tenantDirect float64
+	for ns, na := range byNS {
+		na.DirectCostUSD = na.CPUCostUSD + na.MemoryCostUSD + na.GPUCostUSD
+		if shared[ns] {
+			result.SharedCostUSD += na.DirectCostUSD
+			result.Shared = append(result.Shared, *na)
+		} else {
+			tenantDirect += na.DirectCostUSD
+			result.Namespaces = append(result.Namespaces, *na)
+		}
+	}
+
+	distribute := strategy != cost.SharedNone && len(result.Namespaces) > 0 && result.SharedCostUSD > 0
+	for i := range result.Namespaces {
+		t := &result.Namespaces[i]
+		if distribute {
+			if strategy == cost.SharedProportional && tenantDirect > 0 {
+				t.SharedCostUSD = result.SharedCostUSD * t.DirectCostUSD / tenantDirect
+			} else {
+				t.SharedCostUSD = result.SharedCostUSD / float64(len(result.Namespaces))
+			}
+		}
+		t.MonthlyCostUSD = t.DirectCostUSD + t.SharedCostUSD
+		result.TenantCostUSD += t.MonthlyCostUSD
+	}
+	for i := range result.Shared {
+		if !distribute {
+			result.Shared[i].MonthlyCostUSD = result.Shared[i].DirectCostUSD
+		}
+	}
+	for _, ia := range idle {
+		ia.MonthlyCostUSD = ia.CPUCostUSD + ia.MemoryCostUSD + ia.GPUCostUSD
+		result.IdleCostUSD += ia.MonthlyCostUSD
+		result.Idle = append(result.Idle, *ia)
+	}
+
+	sort.Slice(result.Namespaces, func(i, j int) bool {
+		return result.Namespaces[i].MonthlyCostUSD > result.Namespaces[j].MonthlyCostUSD
+	})
+	sort.Slice(result.Shared, func(i, j int) bool {
+		return result.Shared[i].DirectCostUSD > result.Shared[j].DirectCostUSD
+	})
+	sort.Slice(result.Idle, func(i, j int) bool {
+		return result.Idle[i].MonthlyCostUSD > result.Idle[j].MonthlyCostUSD
+	})
+	return result, nil
+}
+
+// blendedPodResources returns the CPU (millicores), memory (bytes) and GPUs
+// to charge a pod: requests blended with measured usage by weight.
+func blendedPodResources(pod *corev1.Pod, info *optimizer.PodInfo, weight float64) (cpu, mem, gpu float64) {
+	var cpuReq, memReq, gpuReq int64
+	for _, c := range pod.Spec.Containers {
+		cpuReq += c.Resources.Requests.Cpu().MilliValue()
+		memReq += c.Resources.Requests.Memory().Value()
+		if q, ok := c.Resources.Requests[corev1.ResourceName("nvidia.com/gpu")]; ok {
+			gpuReq += q.Value()
+		}
+	}
+	cpu, mem, gpu = float64(cpuReq), float64(memReq), float64(gpuReq)
+	if weight <= 0 || info == nil || (info.CPUUsage == 0 && info.MemoryUsage == 0) {
+		return cpu, mem, gpu
+	}
+	cpu = (1-weight)*cpu + weight*float64(info.CPUUsage)
+	mem = (1-weight)*mem + weight*float64(info.MemoryUsage)
+	return cpu, mem, gpu
+}
+
+// capScale returns the factor that brings an oversubscribed total back to
+// the node's capacity.
+func capScale(sum float64) float64 {
+	if sum > 1 {
+		return 1 / sum
+	}
+	return 1
+}
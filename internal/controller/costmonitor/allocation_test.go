@@ -0,0 +1,284 @@
+package costmonitor
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// hourlyFor returns the hourly price whose monthly cost is exactly monthly.
+func hourlyFor(monthly float64) float64 {
+	return monthly / cost.HoursPerMonth
+}
+
+func inGroup(n optimizer.NodeInfo, group string) optimizer.NodeInfo {
+	n.NodeGroup = group
+	return n
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+func findNS(list []cost.NamespaceAllocation, ns string) *cost.NamespaceAllocation {
+	for i := range list {
+		if list[i].Namespace == ns {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+// assertReconciles checks that tenants, retained shared cost and idle add
+// up to the total node cost.
+func assertReconciles(t *testing.T, a *cost.Allocation) {
+	t.Helper()
+	sum := a.IdleCostUSD
+	for _, ns := range a.Namespaces {
+		sum += ns.MonthlyCostUSD
+	}
+	for _, ns := range a.Shared {
+		sum += ns.MonthlyCostUSD
+	}
+	if !approx(sum, a.TotalMonthlyCostUSD) {
+		t.Errorf("allocation sums to %.4f, want total %.4f", sum, a.TotalMonthlyCostUSD)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Allocate — idle
+// ---------------------------------------------------------------------------
+
+func TestAllocate_IdlePerNodeGroup(t *testing.T) {
+	a := NewAllocator(nil)
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes: []optimizer.NodeInfo{
+			inGroup(nodeWithPods("a1", 4000, 16*gi, hourlyFor(100), []*corev1.Pod{
+				pod("web-1", "prod", 2000, 8*gi, corev1.PodRunning, "", ""),
+			}), "ng-a"),
+			inGroup(nodeWithPods("b1", 4000, 16*gi, hourlyFor(200), nil), "ng-b"),
+		},
+		NodeGroups: []*cloudprovider.NodeGroup{{ID: "ng-a", Name: "general"}, {ID: "ng-b", Name: "batch"}},
+	}
+
+	alloc, err := a.Allocate(context.Background(), snapshot, config.CostAllocationConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !approx(alloc.TotalMonthlyCostUSD, 300) {
+		t.Errorf("total = %.2f, want 300", alloc.TotalMonthlyCostUSD)
+	}
+	if len(alloc.Idle) != 2 {
+		t.Fatalf("expected 2 idle buckets, got %d", len(alloc.Idle))
+	}
+	// Sorted by cost: the empty batch group first.
+	if alloc.Idle[0].NodeGroupID != "ng-b" || alloc.Idle[0].NodeGroup != "batch" || !approx(alloc.Idle[0].MonthlyCostUSD, 200) {
+		t.Errorf("idle[0] = %+v, want ng-b/batch at 200", alloc.Idle[0])
+	}
+	if !approx(alloc.Idle[1].MonthlyCostUSD, 50) {
+		t.Errorf("ng-a idle = %.2f, want 50 (half the node)", alloc.Idle[1].MonthlyCostUSD)
+	}
+	if prod := findNS(alloc.Namespaces, "prod"); prod == nil || !approx(prod.MonthlyCostUSD, 50) {
+		t.Errorf("prod = %+v, want 50", prod)
+	}
+	assertReconciles(t, alloc)
+}
+
+func TestAllocate_OversubscribedNodeScaled(t *testing.T) {
+	a := NewAllocator(nil)
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes: []optimizer.NodeInfo{
+			nodeWithPods("n1", 2000, 8*gi, hourlyFor(100), []*corev1.Pod{
+				pod("a", "team-a", 2000, 8*gi, corev1.PodRunning, "", ""),
+				pod("b", "team-b", 2000, 8*gi, corev1.PodRunning, "", ""),
+			}),
+		},
+	}
+
+	alloc, err := a.Allocate(context.Background(), snapshot, config.CostAllocationConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !approx(alloc.IdleCostUSD, 0) {
+		t.Errorf("idle = %.2f, want 0 on an oversubscribed node", alloc.IdleCostUSD)
+	}
+	for _, ns := range []string{"team-a", "team-b"} {
+		if got := findNS(alloc.Namespaces, ns); got == nil || !approx(got.MonthlyCostUSD, 50) {
+			t.Errorf("%s = %+v, want 50", ns, got)
+		}
+	}
+	assertReconciles(t, alloc)
+}
+
+func TestAllocate_GPUPool(t *testing.T) {
+	a := NewAllocator(nil)
+	gpuPod := pod("train", "ml", 1000, 4*gi, corev1.PodRunning, "", "")
+	gpuPod.Spec.Containers[0].Resources.Requests[corev1.ResourceName("nvidia.com/gpu")] = *resource.NewQuantity(1, resource.DecimalSI)
+	node := nodeWithPods("gpu1", 8000, 32*gi, hourlyFor(1000), []*corev1.Pod{gpuPod})
+	node.GPUs = 2
+
+	alloc, err := a.Allocate(context.Background(), &optimizer.ClusterSnapshot{Nodes: []optimizer.NodeInfo{node}}, config.CostAllocationConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ml := findNS(alloc.Namespaces, "ml")
+	if ml == nil {
+		t.Fatal("expected an allocation for ml")
+	}
+	gpuPool := 1000 * (1 - cost.EstimateCPUCostFraction(8000, true))
+	if !approx(ml.GPUCostUSD, gpuPool/2) {
+		t.Errorf("ml GPU cost = %.2f, want %.2f (one of two GPUs)", ml.GPUCostUSD, gpuPool/2)
+	}
+	if len(alloc.Idle) != 1 || !approx(alloc.Idle[0].GPUCostUSD, gpuPool/2) {
+		t.Errorf("idle = %+v, want half the GPU pool idle", alloc.Idle)
+	}
+	assertReconciles(t, alloc)
+}
+
+// ---------------------------------------------------------------------------
+// Allocate — shared namespaces
+// ---------------------------------------------------------------------------
+
+func TestAllocate_SharedStrategies(t *testing.T) {
+	// team-a uses 3x what team-b does; kube-system costs 20 directly.
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes: []optimizer.NodeInfo{
+			nodeWithPods("n1", 10000, 10*gi, hourlyFor(100), []*corev1.Pod{
+				pod("a", "team-a", 6000, 6*gi, corev1.PodRunning, "", ""),
+				pod("b", "team-b", 2000, 2*gi, corev1.PodRunning, "", ""),
+				pod("dns", "kube-system", 2000, 2*gi, corev1.PodRunning, "", ""),
+			}),
+		},
+	}
+
+	tests := []struct {
+		name         string
+		strategy     string
+		wantA, wantB float64
+		wantRetained float64
+	}{
+		{"proportional", cost.SharedProportional, 60 + 15, 20 + 5, 0},
+		{"default is proportional", "", 60 + 15, 20 + 5, 0},
+		{"even", cost.SharedEven, 60 + 10, 20 + 10, 0},
+		{"none", cost.SharedNone, 60, 20, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alloc, err := NewAllocator(nil).Allocate(context.Background(), snapshot, config.CostAllocationConfig{
+				SharedNamespaces: []string{"kube-system"},
+				SharedStrategy:   tt.strategy,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !approx(alloc.SharedCostUSD, 20) {
+				t.Errorf("shared cost = %.2f, want 20", alloc.SharedCostUSD)
+			}
+			if findNS(alloc.Namespaces, "kube-system") != nil {
+				t.Error("kube-system should not be listed as a tenant")
+			}
+			if got := findNS(alloc.Namespaces, "team-a"); got == nil || !approx(got.MonthlyCostUSD, tt.wantA) {
+				t.Errorf("team-a = %+v, want %.2f", got, tt.wantA)
+			}
+			if got := findNS(alloc.Namespaces, "team-b"); got == nil || !approx(got.MonthlyCostUSD, tt.wantB) {
+				t.Errorf("team-b = %+v, want %.2f", got, tt.wantB)
+			}
+			if got := findNS(alloc.Shared, "kube-system"); got == nil || !approx(got.MonthlyCostUSD, tt.wantRetained) {
+				t.Errorf("kube-system retained = %+v, want %.2f", got, tt.wantRetained)
+			}
+			assertReconciles(t, alloc)
+		})
+	}
+}
+
+func TestAllocate_OnlySharedNamespacesRetained(t *testing.T) {
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes: []optimizer.NodeInfo{
+			nodeWithPods("n1", 4000, 4*gi, hourlyFor(100), []*corev1.Pod{
+				pod("dns", "kube-system", 1000, 1*gi, corev1.PodRunning, "", ""),
+			}),
+		},
+	}
+
+	alloc, err := NewAllocator(nil).Allocate(context.Background(), snapshot, config.CostAllocationConfig{
+		SharedNamespaces: []string{"kube-system"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(alloc.Namespaces) != 0 {
+		t.Errorf("expected no tenants, got %d", len(alloc.Namespaces))
+	}
+	if got := findNS(alloc.Shared, "kube-system"); got == nil || !approx(got.MonthlyCostUSD, 25) {
+		t.Errorf("kube-system = %+v, want 25 retained with no tenants to absorb it", got)
+	}
+	assertReconciles(t, alloc)
+}
+
+// ---------------------------------------------------------------------------
+// Allocate — usage blending
+// ---------------------------------------------------------------------------
+
+func TestAllocate_UsageWeight(t *testing.T) {
+	p := pod("web", "prod", 2000, 4*gi, corev1.PodRunning, "", "")
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes: []optimizer.NodeInfo{
+			nodeWithPods("n1", 4000, 8*gi, hourlyFor(100), []*corev1.Pod{p}),
+		},
+		Pods: []optimizer.PodInfo{{Pod: p, CPUUsage: 1000, MemoryUsage: 2 * gi}},
+	}
+
+	tests := []struct {
+		name   string
+		weight float64
+		want   float64
+	}{
+		{"requests only", 0, 50},
+		{"usage only", 1, 25},
+		{"half and half", 0.5, 37.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alloc, err := NewAllocator(nil).Allocate(context.Background(), snapshot, config.CostAllocationConfig{UsageWeight: tt.weight})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := findNS(alloc.Namespaces, "prod"); got == nil || !approx(got.MonthlyCostUSD, tt.want) {
+				t.Errorf("prod = %+v, want %.2f", got, tt.want)
+			}
+			assertReconciles(t, alloc)
+		})
+	}
+}
+
+func TestAllocate_UsageWeightWithoutSampleUsesRequests(t *testing.T) {
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes: []optimizer.NodeInfo{
+			nodeWithPods("n1", 4000, 8*gi, hourlyFor(100), []*corev1.Pod{
+				pod("web", "prod", 2000, 4*gi, corev1.PodRunning, "", ""),
+			}),
+		},
+	}
+
+	alloc, err := NewAllocator(nil).Allocate(context.Background(), snapshot, config.CostAllocationConfig{UsageWeight: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := findNS(alloc.Namespaces, "prod"); got == nil || !approx(got.MonthlyCostUSD, 50) {
+		t.Errorf("prod = %+v, want 50 (requests)", got)
+	}
+}
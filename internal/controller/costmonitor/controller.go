@@ -110,6 +110,14 @@ func (c *Controller) reconcile(ctx context.Context) error {
 	// Persist daily cost snapshot to SQLite (nil-safe inside CostStore)
 	c.costStore.RecordDailySnapshot(totalMonthlyCost, costByNamespace, costByNodeGroup)
 
+	// Persist the full allocation (idle and shared buckets) for chargeback.
+	allocation, err := c.allocator.Allocate(ctx, snapshot, c.config.CostMonitor.Allocation)
+	if err != nil {
+		logger.Error(err, "Cost allocation failed")
+	} else {
+		c.costStore.RecordDailyAllocation(allocation)
+	}
+
 	// Record hourly cost snapshot for intra-day trend analysis
 	c.costStore.RecordHourlySnapshot(totalMonthlyCost)
 
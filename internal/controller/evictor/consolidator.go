@@ -0,0 +1,325 @@
+package evictor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/scheduler"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// Consolidator finds underutilized nodes whose pods can all be rescheduled
+// onto the remaining nodes, so the node can be drained and removed.
+type Consolidator struct {
+	simulator *scheduler.Simulator
+	nodeLock  *state.NodeLock
+	config    *config.Config
+}
+
+func NewConsolidator(nodeLock *state.NodeLock, cfg *config.Config) *Consolidator {
+	return &Consolidator{
+		simulator: scheduler.NewSimulator(),
+		nodeLock:  nodeLock,
+		config:    cfg,
+	}
+}
+
+// candidate is an underutilized node considered for consolidation.
+type candidate struct {
+	info    *optimizer.NodeInfo
+	group   *cloudprovider.NodeGroup
+	reqFrac float64 // max(cpu, memory) request fraction
+}
+
+// Analyze simulates draining underutilized nodes one at a time. Every movable
+// pod must fit on some other node (including topology constraints) for the
+// node to be recommended. Placements from earlier candidates in the same cycle
+// are carried forward so two nodes are never planned onto the same headroom.
+func (c *Consolidator) Analyze(snapshot *optimizer.ClusterSnapshot) []optimizer.Recommendation {
+	cfg := c.config.Evictor
+	threshold := cfg.UtilizationThresholdPct / 100
+	maxNodes := cfg.MaxNodesPerCycle
+	if maxNodes <= 0 {
+		maxNodes = 1
+	}
+
+	excluded := make(map[string]bool, len(cfg.ExcludeNodeGroups))
+	for _, g := range cfg.ExcludeNodeGroups {
+		excluded[g] = true
+	}
+
+	groups := make(map[string]*cloudprovider.NodeGroup, len(snapshot.NodeGroups))
+	// removable tracks how many more nodes each group can lose before hitting min.
+	removable := make(map[string]int, len(snapshot.NodeGroups))
+	for _, g := range snapshot.NodeGroups {
+		groups[g.ID] = g
+		removable[g.ID] = g.CurrentCount - g.MinCount
+	}
+
+	podsByNode := ActivePodsByNode(snapshot)
+
+	var candidates []candidate
+	for i := range snapshot.Nodes {
+		n := &snapshot.Nodes[i]
+		if n.Node.Spec.Unschedulable || n.IsGPUNode || !scheduler.IsNodeReady(n.Node) {
+			continue
+		}
+		g, ok := groups[n.NodeGroup]
+		if !ok || excluded[g.ID] || excluded[g.Name] || removable[g.ID] <= 0 {
+			continue
+		}
+		if locked, _ := c.nodeLock.IsLocked(n.Node.Name); locked {
+			continue
+		}
+		if n.CPUCapacity == 0 || n.MemoryCapacity == 0 {
+			continue
+		}
+		cpuFrac := float64(n.CPURequested) / float64(n.CPUCapacity)
+		memFrac := float64(n.MemoryRequested) / float64(n.MemoryCapacity)
+		if cpuFrac >= threshold || memFrac >= threshold {
+			continue
+		}
+		candidates = append(candidates, candidate{info: n, group: g, reqFrac: max(cpuFrac, memFrac)})
+	}
+
+	// Emptiest nodes first; among equals prefer the most expensive.
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].reqFrac != candidates[j].reqFrac {
+			return candidates[i].reqFrac < candidates[j].reqFrac
+		}
+		return candidates[i].info.HourlyCostUSD > candidates[j].info.HourlyCostUSD
+	})
+
+	removed := make(map[string]bool)
+	var recs []optimizer.Recommendation
+	for _, cand := range candidates {
+		if len(recs) >= maxNodes {
+			break
+		}
+		if removable[cand.group.ID] <= 0 {
+			continue
+		}
+		nodeName := cand.info.Node.Name
+
+		placements, ok := SimulateDrain(c.simulator, nodeName, snapshot, podsByNode, removed, cfg.MaxPodsPerEviction)
+		if !ok {
+			continue
+		}
+
+		// Commit placements so later candidates see the consumed headroom.
+		for target, pods := range placements {
+			podsByNode[target] = append(podsByNode[target], pods...)
+		}
+		removed[nodeName] = true
+		delete(podsByNode, nodeName)
+		removable[cand.group.ID]--
+
+		recs = append(recs, buildConsolidationRec(cand, placements))
+	}
+	return recs
+}
+
+// ActivePodsByNode indexes the snapshot's pods that still occupy node
+// resources by node name.
+func ActivePodsByNode(snapshot *optimizer.ClusterSnapshot) map[string][]*corev1.Pod {
+	podsByNode := make(map[string][]*corev1.Pod, len(snapshot.Nodes))
+	for i := range snapshot.Nodes {
+		n := &snapshot.Nodes[i]
+		for _, pod := range n.Pods {
+			if isActivePod(pod) {
+				podsByNode[n.Node.Name] = append(podsByNode[n.Node.Name], pod)
+			}
+		}
+	}
+	return podsByNode
+}
+
+// SimulateDrain returns, per target node, the pods that would move there if
+// nodeName were drained. Nodes in removed are not used as targets. The
+// boolean is false if any pod cannot be placed, blocks the drain, or the node
+// has more than maxPods movable pods (0 means no limit). podsByNode is not
+// modified; callers commit the returned placements themselves.
+func SimulateDrain(simulator *scheduler.Simulator, nodeName string, snapshot *optimizer.ClusterSnapshot, podsByNode map[string][]*corev1.Pod, removed map[string]bool, maxPods int) (map[string][]*corev1.Pod, bool) {
+	var movable []*corev1.Pod
+	for _, pod := range podsByNode[nodeName] {
+		if isDaemonSetPod(pod) || isMirrorPod(pod) {
+			continue
+		}
+		if reason := PodBlocksConsolidation(pod); reason != "" {
+			return nil, false
+		}
+		movable = append(movable, pod)
+	}
+	if maxPods > 0 && len(movable) > maxPods {
+		return nil, false
+	}
+
+	// Target set excludes the node being drained and nodes already planned
+	// for removal, so topology spread is evaluated on the post-drain cluster.
+	var targets []*corev1.Node
+	for i := range snapshot.Nodes {
+		n := snapshot.Nodes[i].Node
+		if n.Name == nodeName || removed[n.Name] {
+			continue
+		}
+		targets = append(targets, n)
+	}
+
+	// Working copy of podsByNode so a failed simulation leaves no trace.
+	sim := make(map[string][]*corev1.Pod, len(targets))
+	for _, n := range targets {
+		sim[n.Name] = append([]*corev1.Pod(nil), podsByNode[n.Name]...)
+	}
+
+	// First-fit decreasing onto the most-requested nodes packs tightest.
+	sort.Slice(movable, func(i, j int) bool {
+		ci, mi := scheduler.EffectivePodResources(movable[i])
+		cj, mj := scheduler.EffectivePodResources(movable[j])
+		if ci != cj {
+			return ci > cj
+		}
+		return mi > mj
+	})
+	sort.SliceStable(targets, func(i, j int) bool {
+		return requestedCPU(sim[targets[i].Name]) > requestedCPU(sim[targets[j].Name])
+	})
+
+	placements := make(map[string][]*corev1.Pod)
+	for _, pod := range movable {
+		placed := false
+		for _, target := range targets {
+			result := simulator.CanScheduleWithTopology(pod, target, sim[target.Name], targets, sim)
+			if !result.Feasible {
+				continue
+			}
+			moved := pod.DeepCopy()
+			moved.Spec.NodeName = target.Name
+			sim[target.Name] = append(sim[target.Name], moved)
+			placements[target.Name] = append(placements[target.Name], moved)
+			placed = true
+			break
+		}
+		if !placed {
+			return nil, false
+		}
+	}
+	return placements, true
+}
+
+func buildConsolidationRec(cand candidate, placements map[string][]*corev1.Pod) optimizer.Recommendation {
+	nodeName := cand.info.Node.Name
+	podCount := 0
+	targetNames := make([]string, 0, len(placements))
+	for target, pods := range placements {
+		podCount += len(pods)
+		targetNames = append(targetNames, target)
+	}
+	sort.Strings(targetNames)
+
+	monthly := cand.info.HourlyCostUSD * cost.HoursPerMonth
+	steps := []string{
+		fmt.Sprintf("Cordon node %s", nodeName),
+		fmt.Sprintf("Evict %d pods (PDB-checked) onto %d other nodes", podCount, len(targetNames)),
+		fmt.Sprintf("Scale node group %s from %d to %d", cand.group.Name, cand.group.DesiredCount, cand.group.DesiredCount-1),
+	}
+
+	return optimizer.Recommendation{
+		ID:             fmt.Sprintf("consolidate-%s-%d", nodeName, time.Now().Unix()),
+		Type:           optimizer.RecommendationEviction,
+		Priority:       optimizer.PriorityMedium,
+		AutoExecutable: true,
+		TargetKind:     "Node",
+		TargetName:     nodeName,
+		Summary: fmt.Sprintf("Consolidate node %s (%.0f%% requested): %d pods fit on remaining nodes, saves $%.2f/mo",
+			nodeName, cand.reqFrac*100, podCount, monthly),
+		ActionSteps: steps,
+		EstimatedSaving: optimizer.SavingEstimate{
+			MonthlySavingsUSD: monthly,
+			AnnualSavingsUSD:  monthly * 12,
+			Currency:          "USD",
+		},
+		EstimatedImpact: optimizer.ImpactEstimate{
+			MonthlyCostChangeUSD: -monthly,
+			NodesAffected:        1,
+			PodsAffected:         podCount,
+			RiskLevel:            "medium",
+		},
+		Details: map[string]string{
+			"action":      "consolidate-node",
+			"nodeName":    nodeName,
+			"nodeGroupID": cand.group.ID,
+			"podCount":    fmt.Sprintf("%d", podCount),
+		},
+		CreatedAt: time.Now(),
+	}
+}
+
+func requestedCPU(pods []*corev1.Pod) int64 {
+	var total int64
+	for _, p := range pods {
+		cpu, _ := scheduler.EffectivePodResources(p)
+		total += cpu
+	}
+	return total
+}
+
+// isActivePod returns true for pods that still occupy node resources.
+func isActivePod(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+// PodBlocksConsolidation returns a non-empty reason if the pod must not be
+// evicted, which makes its node ineligible for consolidation. DaemonSet and
+// mirror pods are not blockers: they go away with the node.
+func PodBlocksConsolidation(pod *corev1.Pod) string {
+	// Never evict koptimizer itself.
+	if pod.Labels["app.kubernetes.io/name"] == "koptimizer" {
+		return "koptimizer pod"
+	}
+	switch pod.Labels["app"] {
+	case "koptimizer", "koptimizer-dashboard", "mockapi":
+		return "koptimizer pod"
+	}
+	switch pod.Namespace {
+	case "kube-system", "kube-public", "kube-node-lease":
+		return "system namespace"
+	}
+	if pod.Spec.PriorityClassName == "system-cluster-critical" || pod.Spec.PriorityClassName == "system-node-critical" {
+		return "system-critical priority"
+	}
+	if pod.Annotations["koptimizer.io/exclude"] == "true" {
+		return "excluded by annotation"
+	}
+	if pod.Annotations["cluster-autoscaler.kubernetes.io/safe-to-evict"] == "false" {
+		return "marked not safe to evict"
+	}
+	// Bare pods have no controller to recreate them elsewhere.
+	if len(pod.OwnerReferences) == 0 {
+		return "unmanaged pod"
+	}
+	return ""
+}
+
+func isDaemonSetPod(pod *corev1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
+
+func isMirrorPod(pod *corev1.Pod) bool {
+	_, ok := pod.Annotations["kubernetes.io/config.mirror"]
+	return ok
+}
@@ -0,0 +1,127 @@
+package evictor
+
+import (
+	"context"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/familylock"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// Controller consolidates pods onto fewer nodes (bin-packing) and removes the
+// drained nodes by scaling their node group down.
+type Controller struct {
+	client       client.Client
+	provider     cloudprovider.CloudProvider
+	state        *state.ClusterState
+	guard        *familylock.FamilyLockGuard
+	gate         *aigate.AIGate
+	config       *config.Config
+	consolidator *Consolidator
+	drainer      *Drainer
+}
+
+func NewController(mgr ctrl.Manager, provider cloudprovider.CloudProvider, st *state.ClusterState, guard *familylock.FamilyLockGuard, gate *aigate.AIGate, cfg *config.Config) *Controller {
+	c := mgr.GetClient()
+	return &Controller{
+		client:       c,
+		provider:     provider,
+		state:        st,
+		guard:        guard,
+		gate:         gate,
+		config:       cfg,
+		consolidator: NewConsolidator(st.NodeLock, cfg),
+		drainer:      NewDrainer(c, provider, st.NodeLock, st.AuditLog, "evictor", cfg.Evictor.DrainTimeout),
+	}
+}
+
+func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(c)
+}
+
+// Start implements manager.Runnable.
+func (c *Controller) Start(ctx context.Context) error {
+	c.run(ctx)
+	return nil
+}
+
+func (c *Controller) Name() string { return "evictor" }
+
+func (c *Controller) Analyze(ctx context.Context, snapshot *optimizer.ClusterSnapshot) ([]optimizer.Recommendation, error) {
+	return c.consolidator.Analyze(snapshot), nil
+}
+
+func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation) error {
+	if c.config.GetMode() != "active" {
+		return nil
+	}
+	if !rec.AutoExecutable {
+		return nil
+	}
+
+	// AI Gate validation — fail-closed
+	if c.gate.RequiresValidation(rec) {
+		valReq := c.state.GateRequest(ctx, rec, "Node drain evicts all non-DaemonSet pods on the node")
+		result, err := c.gate.Validate(ctx, valReq)
+		if err != nil || !result.Approved {
+			return nil // Falls back to recommendation mode
+		}
+	}
+
+	return c.ExecuteApproved(ctx, rec)
+}
+
+// ExecuteApproved drains the consolidation candidate and scales its node
+// group down by one, provided the family lock allows scaling.
+func (c *Controller) ExecuteApproved(ctx context.Context, rec optimizer.Recommendation) error {
+	if err := c.guard.ValidateNodeGroupAction(familylock.NodeGroupScale); err != nil {
+		return err
+	}
+	return c.drainer.Execute(ctx, rec)
+}
+
+func (c *Controller) run(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("evictor")
+	// Consolidation is disruptive; a slower cadence than the other
+	// controllers gives rescheduled pods time to settle between drains.
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !c.config.IsControllerEnabled("evictor") {
+				continue
+			}
+			if c.state.Breaker.IsTripped(c.Name()) {
+				logger.V(1).Info("Circuit breaker tripped, skipping execution cycle")
+				continue
+			}
+			snapshot := c.state.Snapshot()
+			recs, err := c.Analyze(ctx, snapshot)
+			if err != nil {
+				logger.Error(err, "Analysis failed")
+				c.state.Breaker.RecordFailure(c.Name())
+				continue
+			}
+			for _, rec := range recs {
+				if err := c.Execute(ctx, rec); err != nil {
+					logger.Error(err, "Execution failed", "recommendation", rec.ID)
+					c.state.Breaker.RecordFailure(c.Name())
+				} else {
+					c.state.Breaker.RecordSuccess(c.Name())
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package evictor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+const (
+	// Annotations written on cordon. main.go uncordons any node carrying
+	// cordonedByAnnotation at startup, so an interrupted drain never strands
+	// a node unschedulable.
+	cordonedByAnnotation = "koptimizer.io/cordoned-by"
+	cordonedAtAnnotation = "koptimizer.io/cordoned-at"
+
+	defaultDrainTimeout = 5 * time.Minute
+)
+
+// Drainer cordons and drains a node, then removes it by scaling its node
+// group down by one. owner identifies the calling controller in node locks,
+// cordon annotations and the audit log.
+type Drainer struct {
+	client       client.Client
+	provider     cloudprovider.CloudProvider
+	nodeLock     *state.NodeLock
+	auditLog     *state.AuditLog
+	owner        string
+	drainTimeout time.Duration
+}
+
+func NewDrainer(c client.Client, provider cloudprovider.CloudProvider, nodeLock *state.NodeLock, auditLog *state.AuditLog, owner string, drainTimeout time.Duration) *Drainer {
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+	return &Drainer{
+		client:       c,
+		provider:     provider,
+		nodeLock:     nodeLock,
+		auditLog:     auditLog,
+		owner:        owner,
+		drainTimeout: drainTimeout,
+	}
+}
+
+// Execute drains the node named in the recommendation and scales its node
+// group down by one. PDBs are checked for every pod before anything is
+// evicted; if any pod is protected the node is uncordoned and the drain is
+// abandoned so no half-drained nodes are left behind.
+//
+// Note: for ASG/MIG/VMSS backed groups the provider chooses which instance
+// to terminate on scale-down. Termination policies generally prefer the
+// cordoned, empty node, but this is not guaranteed by the CloudProvider API.
+// Groups that support cloudprovider.NodeRemover (Karpenter NodePools)
+// remove exactly the drained node instead, after checking the group's
+// disruption budgets.
+func (d *Drainer) Execute(ctx context.Context, rec optimizer.Recommendation) error {
+	logger := log.FromContext(ctx).WithName(d.owner)
+	nodeName := rec.Details["nodeName"]
+	nodeGroupID := rec.Details["nodeGroupID"]
+	if nodeName == "" || nodeGroupID == "" {
+		return fmt.Errorf("recommendation %s missing nodeName or nodeGroupID", rec.ID)
+	}
+
+	if err := d.nodeLock.TryLock(nodeName, d.owner); err != nil {
+		return fmt.Errorf("cannot consolidate node: %w", err)
+	}
+	defer d.nodeLock.Unlock(nodeName, d.owner)
+
+	// Re-check bounds against the live node group, not the snapshot.
+	ng, err := d.provider.GetNodeGroup(ctx, nodeGroupID)
+	if err != nil {
+		return fmt.Errorf("getting node group %s: %w", nodeGroupID, err)
+	}
+	if ng.DesiredCount <= ng.MinCount {
+		return fmt.Errorf("node group %s already at min count %d", ng.Name, ng.MinCount)
+	}
+	remover, _ := d.provider.(cloudprovider.NodeRemover)
+	if remover != nil && !remover.CanRemoveNode(nodeGroupID) {
+		remover = nil
+	}
+	if remover != nil {
+		if err := remover.DisruptionAllowed(ctx, nodeGroupID); err != nil {
+			return fmt.Errorf("node group %s: %w", ng.Name, err)
+		}
+	}
+
+	d.auditLog.Record("consolidate-node", nodeName, d.owner, rec.Summary)
+
+	if err := d.cordon(ctx, nodeName); err != nil {
+		return err
+	}
+
+	evicted, err := d.drain(ctx, nodeName)
+	if err != nil {
+		if uerr := d.uncordon(ctx, nodeName); uerr != nil {
+			logger.Error(uerr, "Failed to uncordon node after aborted drain", "node", nodeName)
+		}
+		d.auditLog.Record("consolidate-node-aborted", nodeName, d.owner, err.Error())
+		return err
+	}
+
+	if remover != nil {
+		err = remover.RemoveNode(ctx, nodeGroupID, nodeName)
+		if err != nil {
+			err = fmt.Errorf("removing node %s from node group %s: %w", nodeName, nodeGroupID, err)
+		}
+	} else if err = d.provider.ScaleNodeGroup(ctx, nodeGroupID, ng.DesiredCount-1); err != nil {
+		err = fmt.Errorf("scaling node group %s to %d: %w", nodeGroupID, ng.DesiredCount-1, err)
+	}
+	if err != nil {
+		if uerr := d.uncordon(ctx, nodeName); uerr != nil {
+			logger.Error(uerr, "Failed to uncordon node after scale-down failure", "node", nodeName)
+		}
+		return err
+	}
+
+	intmetrics.NodesConsolidated.Inc()
+	logger.Info("Consolidated node",
+		"node", nodeName,
+		"nodeGroup", ng.Name,
+		"evicted", evicted,
+		"newDesired", ng.DesiredCount-1,
+	)
+	d.auditLog.Record("consolidate-node-complete", nodeName, d.owner,
+		fmt.Sprintf("evicted %d pods, scaled %s to %d", evicted, ng.Name, ng.DesiredCount-1))
+	return nil
+}
+
+// drain evicts all movable pods and waits for them to terminate.
+func (d *Drainer) drain(ctx context.Context, nodeName string) (int, error) {
+	logger := log.FromContext(ctx).WithName(d.owner)
+
+	pods, err := d.movablePods(ctx, nodeName)
+	if err != nil {
+		return 0, err
+	}
+
+	// Pre-flight: reject the whole drain if any pod would block. The
+	// consolidator checked the snapshot, but pods may have landed since.
+	pdbByNamespace := make(map[string]*policyv1.PodDisruptionBudgetList)
+	for _, pod := range pods {
+		if reason := PodBlocksConsolidation(pod); reason != "" {
+			return 0, fmt.Errorf("pod %s/%s blocks drain: %s", pod.Namespace, pod.Name, reason)
+		}
+		if _, ok := pdbByNamespace[pod.Namespace]; !ok {
+			pdbList := &policyv1.PodDisruptionBudgetList{}
+			if err := d.client.List(ctx, pdbList, client.InNamespace(pod.Namespace)); err != nil {
+				logger.Error(err, "Failed to list PDBs, treating namespace as protected", "namespace", pod.Namespace)
+				pdbByNamespace[pod.Namespace] = nil
+			} else {
+				pdbByNamespace[pod.Namespace] = pdbList
+			}
+		}
+		if !checkPDBSafe(pod, pdbByNamespace[pod.Namespace]) {
+			return 0, fmt.Errorf("pod %s/%s is protected by a PodDisruptionBudget", pod.Namespace, pod.Name)
+		}
+	}
+
+	evicted := 0
+	for _, pod := range pods {
+		// The eviction API enforces PDBs server-side as well; a 429 here
+		// means another disruption consumed the budget since pre-flight.
+		if err := evictPod(ctx, d.client, pod); err != nil {
+			return evicted, fmt.Errorf("evicting pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+		intmetrics.EvictionsTotal.Inc()
+		evicted++
+	}
+
+	if err := d.waitForDrain(ctx, nodeName); err != nil {
+		return evicted, err
+	}
+	return evicted, nil
+}
+
+// waitForDrain polls until no movable pods remain on the node.
+func (d *Drainer) waitForDrain(ctx context.Context, nodeName string) error {
+	timeout := d.drainTimeout
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		pods, err := d.movablePods(ctx, nodeName)
+		if err != nil {
+			return err
+		}
+		if len(pods) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %d pods to leave node %s", timeout, len(pods), nodeName)
+		}
+		// Keep the lock fresh so ExpireStale does not reclaim it mid-drain.
+		d.nodeLock.Refresh(nodeName, d.owner)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// movablePods lists pods on the node that must be evicted before removal.
+func (d *Drainer) movablePods(ctx context.Context, nodeName string) ([]*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	if err := d.client.List(ctx, podList, client.MatchingFields{"spec.nodeName": nodeName}); err != nil {
+		return nil, fmt.Errorf("listing pods on node %s: %w", nodeName, err)
+	}
+	var pods []*corev1.Pod
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if isDaemonSetPod(pod) || isMirrorPod(pod) {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+func (d *Drainer) cordon(ctx context.Context, nodeName string) error {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node := &corev1.Node{}
+		if err := d.client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+			return err
+		}
+		if node.Spec.Unschedulable {
+			return fmt.Errorf("node %s is already cordoned", nodeName)
+		}
+		node.Spec.Unschedulable = true
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		node.Annotations[cordonedByAnnotation] = d.owner
+		node.Annotations[cordonedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		return d.client.Update(ctx, node)
+	})
+	if err != nil {
+		return fmt.Errorf("cordoning node %s: %w", nodeName, err)
+	}
+	return nil
+}
+
+func (d *Drainer) uncordon(ctx context.Context, nodeName string) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node := &corev1.Node{}
+		if err := d.client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+			return err
+		}
+		// Only undo our own cordon; leave operator cordons alone.
+		if node.Annotations[cordonedByAnnotation] != d.owner {
+			return nil
+		}
+		node.Spec.Unschedulable = false
+		delete(node.Annotations, cordonedByAnnotation)
+		delete(node.Annotations, cordonedAtAnnotation)
+		return d.client.Update(ctx, node)
+	})
+}
+
+// checkPDBSafe returns true if evicting the pod won't violate any PDB.
+func checkPDBSafe(pod *corev1.Pod, pdbList *policyv1.PodDisruptionBudgetList) bool {
+	if pdbList == nil {
+		return false // Fail-safe: if PDBs unavailable, block eviction
+	}
+	for _, pdb := range pdbList.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(pod.Labels)) && pdb.Status.DisruptionsAllowed <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// evictPod evicts a pod using the Kubernetes eviction API.
+func evictPod(ctx context.Context, c client.Client, pod *corev1.Pod) error {
+	gracePeriod := pod.Spec.TerminationGracePeriodSeconds
+	if gracePeriod == nil {
+		defaultGrace := int64(30)
+		gracePeriod = &defaultGrace
+	}
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+		DeleteOptions: &metav1.DeleteOptions{
+			GracePeriodSeconds: gracePeriod,
+		},
+	}
+	return c.SubResource("eviction").Create(ctx, pod, eviction)
+}
@@ -0,0 +1,240 @@
+package evictor
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+const gi = int64(1024 * 1024 * 1024)
+
+func testNode(name string, cpuMillis, memBytes int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    *resource.NewMilliQuantity(cpuMillis, resource.DecimalSI),
+				corev1.ResourceMemory: *resource.NewQuantity(memBytes, resource.BinarySI),
+			},
+		},
+	}
+}
+
+func testPod(name, nodeName string, cpuMillis, memBytes int64) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": name},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: name + "-rs"},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{{
+				Name: "main",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    *resource.NewMilliQuantity(cpuMillis, resource.DecimalSI),
+						corev1.ResourceMemory: *resource.NewQuantity(memBytes, resource.BinarySI),
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func nodeInfo(node *corev1.Node, group string, pods ...*corev1.Pod) optimizer.NodeInfo {
+	var cpuReq, memReq int64
+	for _, p := range pods {
+		cpuReq += p.Spec.Containers[0].Resources.Requests.Cpu().MilliValue()
+		memReq += p.Spec.Containers[0].Resources.Requests.Memory().Value()
+	}
+	return optimizer.NodeInfo{
+		Node:            node,
+		Pods:            pods,
+		CPUCapacity:     node.Status.Allocatable.Cpu().MilliValue(),
+		MemoryCapacity:  node.Status.Allocatable.Memory().Value(),
+		CPURequested:    cpuReq,
+		MemoryRequested: memReq,
+		HourlyCostUSD:   0.2,
+		NodeGroup:       group,
+	}
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Evictor: config.EvictorConfig{
+			Enabled:                 true,
+			UtilizationThresholdPct: 50,
+			MaxPodsPerEviction:      10,
+			MaxNodesPerCycle:        1,
+		},
+	}
+}
+
+func testGroup(current, min int) *cloudprovider.NodeGroup {
+	return &cloudprovider.NodeGroup{ID: "ng-1", Name: "general", CurrentCount: current, DesiredCount: current, MinCount: min, MaxCount: 10}
+}
+
+// ---------------------------------------------------------------------------
+// Consolidator Tests
+// ---------------------------------------------------------------------------
+
+func TestConsolidator_RecommendsDrainWhenPodsFit(t *testing.T) {
+	busy := nodeInfo(testNode("busy", 4000, 16*gi), "ng-1", testPod("a", "busy", 2000, 8*gi))
+	idle := nodeInfo(testNode("idle", 4000, 16*gi), "ng-1", testPod("b", "idle", 500, 1*gi))
+
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes:      []optimizer.NodeInfo{busy, idle},
+		NodeGroups: []*cloudprovider.NodeGroup{testGroup(2, 1)},
+	}
+
+	recs := NewConsolidator(state.NewNodeLock(), testConfig()).Analyze(snapshot)
+	if len(recs) != 1 {
+		t.Fatalf("expected 1 recommendation, got %d", len(recs))
+	}
+	rec := recs[0]
+	if rec.Details["nodeName"] != "idle" {
+		t.Errorf("expected idle node to be consolidated, got %q", rec.Details["nodeName"])
+	}
+	if rec.Type != optimizer.RecommendationEviction {
+		t.Errorf("expected eviction recommendation, got %q", rec.Type)
+	}
+	if rec.Details["podCount"] != "1" {
+		t.Errorf("expected podCount 1, got %q", rec.Details["podCount"])
+	}
+}
+
+func TestConsolidator_SkipsWhenPodsDoNotFit(t *testing.T) {
+	busy := nodeInfo(testNode("busy", 4000, 16*gi), "ng-1", testPod("a", "busy", 3800, 8*gi))
+	idle := nodeInfo(testNode("idle", 4000, 16*gi), "ng-1", testPod("b", "idle", 1000, 1*gi))
+
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes:      []optimizer.NodeInfo{busy, idle},
+		NodeGroups: []*cloudprovider.NodeGroup{testGroup(2, 1)},
+	}
+
+	recs := NewConsolidator(state.NewNodeLock(), testConfig()).Analyze(snapshot)
+	if len(recs) != 0 {
+		t.Fatalf("expected no recommendations when pods cannot be rescheduled, got %d", len(recs))
+	}
+}
+
+func TestConsolidator_RespectsNodeGroupMin(t *testing.T) {
+	busy := nodeInfo(testNode("busy", 4000, 16*gi), "ng-1", testPod("a", "busy", 2000, 8*gi))
+	idle := nodeInfo(testNode("idle", 4000, 16*gi), "ng-1")
+
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes:      []optimizer.NodeInfo{busy, idle},
+		NodeGroups: []*cloudprovider.NodeGroup{testGroup(2, 2)},
+	}
+
+	recs := NewConsolidator(state.NewNodeLock(), testConfig()).Analyze(snapshot)
+	if len(recs) != 0 {
+		t.Fatalf("expected no recommendations at node group min, got %d", len(recs))
+	}
+}
+
+func TestConsolidator_SkipsLockedNodes(t *testing.T) {
+	busy := nodeInfo(testNode("busy", 4000, 16*gi), "ng-1", testPod("a", "busy", 2000, 8*gi))
+	idle := nodeInfo(testNode("idle", 4000, 16*gi), "ng-1", testPod("b", "idle", 500, 1*gi))
+
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes:      []optimizer.NodeInfo{busy, idle},
+		NodeGroups: []*cloudprovider.NodeGroup{testGroup(2, 1)},
+	}
+
+	lock := state.NewNodeLock()
+	if err := lock.TryLock("idle", "gpu-reclaimer"); err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+
+	recs := NewConsolidator(lock, testConfig()).Analyze(snapshot)
+	if len(recs) != 0 {
+		t.Fatalf("expected locked node to be skipped, got %d recommendations", len(recs))
+	}
+}
+
+func TestConsolidator_DoesNotDoubleBookHeadroom(t *testing.T) {
+	// Two idle nodes, each with a 1500m pod. The busy node has room for
+	// only one of them, so only one node may be consolidated even with a
+	// higher per-cycle limit.
+	busy := nodeInfo(testNode("busy", 4000, 16*gi), "ng-1", testPod("a", "busy", 2000, 4*gi))
+	idle1 := nodeInfo(testNode("idle-1", 4000, 16*gi), "ng-1", testPod("b", "idle-1", 1500, 1*gi))
+	idle2 := nodeInfo(testNode("idle-2", 4000, 16*gi), "ng-1", testPod("c", "idle-2", 1500, 1*gi))
+
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes:      []optimizer.NodeInfo{busy, idle1, idle2},
+		NodeGroups: []*cloudprovider.NodeGroup{testGroup(3, 1)},
+	}
+
+	cfg := testConfig()
+	cfg.Evictor.MaxNodesPerCycle = 2
+
+	recs := NewConsolidator(state.NewNodeLock(), cfg).Analyze(snapshot)
+	if len(recs) != 1 {
+		t.Fatalf("expected exactly 1 recommendation, got %d", len(recs))
+	}
+}
+
+func TestConsolidator_BlockedByUnmanagedPod(t *testing.T) {
+	bare := testPod("bare", "idle", 100, 1*gi)
+	bare.OwnerReferences = nil
+
+	busy := nodeInfo(testNode("busy", 4000, 16*gi), "ng-1", testPod("a", "busy", 2000, 4*gi))
+	idle := nodeInfo(testNode("idle", 4000, 16*gi), "ng-1", bare)
+
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes:      []optimizer.NodeInfo{busy, idle},
+		NodeGroups: []*cloudprovider.NodeGroup{testGroup(2, 1)},
+	}
+
+	recs := NewConsolidator(state.NewNodeLock(), testConfig()).Analyze(snapshot)
+	if len(recs) != 0 {
+		t.Fatalf("expected unmanaged pod to block consolidation, got %d recommendations", len(recs))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// PDB Tests
+// ---------------------------------------------------------------------------
+
+func TestCheckPDBSafe(t *testing.T) {
+	pod := testPod("web", "n1", 100, gi)
+	pdb := func(allowed int32) *policyv1.PodDisruptionBudgetList {
+		return &policyv1.PodDisruptionBudgetList{Items: []policyv1.PodDisruptionBudget{{
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+			Status: policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		}}}
+	}
+
+	if !checkPDBSafe(pod, pdb(1)) {
+		t.Error("expected eviction allowed when DisruptionsAllowed > 0")
+	}
+	if checkPDBSafe(pod, pdb(0)) {
+		t.Error("expected eviction blocked when DisruptionsAllowed == 0")
+	}
+	if checkPDBSafe(pod, nil) {
+		t.Error("expected fail-closed when PDB list is unavailable")
+	}
+}
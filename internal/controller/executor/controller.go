@@ -0,0 +1,380 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+	"github.com/koptimizer/koptimizer/pkg/familylock"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+const (
+	// RecommendationNamespace is where recommendation CRDs are persisted.
+	RecommendationNamespace = "koptimizer-system"
+
+	// maxExecutionsPerCycle bounds how many approved recommendations are
+	// applied per tick so a bulk approval does not trigger a storm of
+	// rolling restarts and node group changes at once.
+	maxExecutionsPerCycle = 5
+)
+
+// ExecuteFunc applies a single approved recommendation. Implementations are
+// provided by the owning controllers and must not repeat mode, family lock or
+// AI Gate checks; the executor performs those before dispatching.
+type ExecuteFunc func(ctx context.Context, rec optimizer.Recommendation) error
+
+// WaitFunc reports why an approved recommendation cannot be applied yet, or
+// "" if it can. A recommendation that has to wait stays approved and is
+// checked again next cycle, before the AI Gate is consulted.
+type WaitFunc func(ctx context.Context, rec optimizer.Recommendation) string
+
+// Controller picks up Recommendation CRDs that a user has approved and
+// dispatches them to the controller that owns the recommendation type.
+type Controller struct {
+	client client.Client
+	state  *state.ClusterState
+	guard  *familylock.FamilyLockGuard
+	gate   *aigate.AIGate
+	config *config.Config
+
+	mu       sync.RWMutex
+	handlers map[optimizer.RecommendationType]ExecuteFunc
+	waits    map[optimizer.RecommendationType]WaitFunc
+}
+
+func NewController(mgr ctrl.Manager, st *state.ClusterState, guard *familylock.FamilyLockGuard, gate *aigate.AIGate, cfg *config.Config) *Controller {
+	return &Controller{
+		client:   mgr.GetClient(),
+		state:    st,
+		guard:    guard,
+		gate:     gate,
+		config:   cfg,
+		handlers: make(map[optimizer.RecommendationType]ExecuteFunc),
+		waits:    make(map[optimizer.RecommendationType]WaitFunc),
+	}
+}
+
+// Register associates a recommendation type with the function that executes it.
+// Registering the same type twice replaces the previous handler.
+func (c *Controller) Register(recType optimizer.RecommendationType, fn ExecuteFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[recType] = fn
+}
+
+// RegisterWait sets the function that decides whether approved
+// recommendations of a type have to wait, e.g. for a maintenance window.
+func (c *Controller) RegisterWait(recType optimizer.RecommendationType, fn WaitFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits[recType] = fn
+}
+
+func (c *Controller) handlerFor(recType optimizer.RecommendationType) (ExecuteFunc, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	fn, ok := c.handlers[recType]
+	return fn, ok
+}
+
+func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(c)
+}
+
+// Start implements manager.Runnable.
+func (c *Controller) Start(ctx context.Context) error {
+	if err := c.recoverInterrupted(ctx); err != nil {
+		log.FromContext(ctx).WithName("executor").Error(err, "Failed to recover interrupted recommendations")
+	}
+	c.run(ctx)
+	return nil
+}
+
+// recoverInterrupted marks recommendations left "executing" by a previous
+// run as failed. Their outcome is unknown, so they are not retried.
+func (c *Controller) recoverInterrupted(ctx context.Context) error {
+	var list koptv1alpha1.RecommendationList
+	if err := c.client.List(ctx, &list, client.InNamespace(RecommendationNamespace)); err != nil {
+		return fmt.Errorf("listing recommendations: %w", err)
+	}
+	for i := range list.Items {
+		crd := &list.Items[i]
+		if crd.Status.State != "executing" {
+			continue
+		}
+		cause := fmt.Errorf("execution was interrupted before its outcome was recorded; check %s before approving it again", crd.Spec.TargetName)
+		if err := c.markFailed(ctx, crd, cause); err != nil && !errors.Is(err, cause) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Controller) Name() string { return "recommendation-executor" }
+
+func (c *Controller) run(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("executor")
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Approved recommendations stay queued until the optimizer is
+			// switched to active mode.
+			if c.config.GetMode() != "active" {
+				continue
+			}
+			if c.state.Breaker.IsTripped(c.Name()) {
+				logger.V(1).Info("Circuit breaker tripped, skipping execution cycle")
+				continue
+			}
+			if err := c.executeApproved(ctx); err != nil {
+				logger.Error(err, "Failed to process approved recommendations")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// executeApproved runs one pass over approved recommendation CRDs.
+func (c *Controller) executeApproved(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("executor")
+
+	var list koptv1alpha1.RecommendationList
+	if err := c.client.List(ctx, &list, client.InNamespace(RecommendationNamespace)); err != nil {
+		return fmt.Errorf("listing recommendations: %w", err)
+	}
+
+	executed := 0
+	for i := range list.Items {
+		if executed >= maxExecutionsPerCycle {
+			break
+		}
+		crd := &list.Items[i]
+		if crd.Status.State != "approved" {
+			continue
+		}
+		// Waiting recommendations do not use up the cycle's executions.
+		if c.waiting(ctx, crd) {
+			continue
+		}
+		executed++
+
+		if err := c.executeOne(ctx, crd); err != nil {
+			logger.Error(err, "Recommendation execution failed", "recommendation", crd.Name, "type", crd.Spec.Type)
+			c.state.Breaker.RecordFailure(c.Name())
+			continue
+		}
+		c.state.Breaker.RecordSuccess(c.Name())
+	}
+	return nil
+}
+
+// waiting reports whether crd has to wait for a later cycle, and records
+// the reason in its execution result.
+func (c *Controller) waiting(ctx context.Context, crd *koptv1alpha1.Recommendation) bool {
+	c.mu.RLock()
+	wait, ok := c.waits[optimizer.RecommendationType(crd.Spec.Type)]
+	c.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	reason := wait(ctx, FromCRD(crd))
+	if reason == "" {
+		return false
+	}
+	if result := "Waiting: " + reason; crd.Status.ExecutionResult != result {
+		crd.Status.ExecutionResult = result
+		if err := c.client.Status().Update(ctx, crd); err != nil {
+			log.FromContext(ctx).WithName("executor").V(1).Info("Recording wait reason failed", "recommendation", crd.Name, "error", err)
+		}
+	}
+	return true
+}
+
+// executeOne re-validates and applies a single approved recommendation, then
+// writes the outcome back to the CRD status. A returned error means the
+// recommendation was marked failed.
+func (c *Controller) executeOne(ctx context.Context, crd *koptv1alpha1.Recommendation) error {
+	rec := FromCRD(crd)
+
+	fn, ok := c.handlerFor(rec.Type)
+	if !ok {
+		return c.markFailed(ctx, crd, fmt.Errorf("no executor registered for recommendation type %q (owning controller disabled?)", rec.Type))
+	}
+
+	// The family lock may have changed since approval (e.g. node group
+	// replaced), so re-validate against the current guard state.
+	if err := c.checkFamilyLock(ctx, rec); err != nil {
+		return c.markFailed(ctx, crd, err)
+	}
+
+	// User approval does not bypass the AI Gate: cluster conditions may
+	// have changed between recommendation and execution.
+	if c.gate.RequiresValidation(rec) {
+		result, err := c.gate.Validate(ctx, c.state.GateRequest(ctx, rec))
+		if err != nil {
+			return c.markFailed(ctx, crd, fmt.Errorf("AI Gate error: %w", err))
+		}
+		crd.Status.AIGateResult = &koptv1alpha1.AIGateResult{
+			Approved:    result.Approved,
+			Confidence:  result.Confidence,
+			Reasoning:   result.Reasoning,
+			Warnings:    result.Warnings,
+			ValidatedAt: metav1.Now(),
+		}
+		if !result.Approved {
+			return c.markFailed(ctx, crd, fmt.Errorf("rejected by AI Gate: %s", result.Reasoning))
+		}
+	}
+
+	crd.Status.State = "executing"
+	crd.Status.Error = ""
+	if err := c.client.Status().Update(ctx, crd); err != nil {
+		// Most likely a conflict with a concurrent dismiss; retry next cycle.
+		return fmt.Errorf("marking recommendation executing: %w", err)
+	}
+
+	if err := fn(ctx, rec); err != nil {
+		return c.markFailed(ctx, crd, err)
+	}
+
+	crd.Status.State = "executed"
+	crd.Status.ExecutedAt = metav1.Now()
+	crd.Status.ExecutionResult = fmt.Sprintf("Applied %s to %s", rec.Type, targetString(rec))
+	crd.Status.Error = ""
+	if err := c.updateStatus(ctx, crd); err != nil {
+		return fmt.Errorf("recording execution result: %w", err)
+	}
+
+	c.state.AuditLog.Record("recommendation-executed", targetString(rec), "executor", rec.Summary)
+	return nil
+}
+
+// markFailed records a failed execution on the CRD and returns the cause.
+func (c *Controller) markFailed(ctx context.Context, crd *koptv1alpha1.Recommendation, cause error) error {
+	crd.Status.State = "failed"
+	crd.Status.ExecutedAt = metav1.Now()
+	crd.Status.ExecutionResult = "Execution failed"
+	crd.Status.Error = cause.Error()
+	if err := c.updateStatus(ctx, crd); err != nil {
+		return fmt.Errorf("%v (status update failed: %w)", cause, err)
+	}
+	c.state.AuditLog.Record("recommendation-failed", crd.Spec.TargetName, "executor", cause.Error())
+	return cause
+}
+
+// updateStatus writes the status of crd, retrying with backoff and re-reading
+// the CRD between attempts so an outcome is not lost to a conflict or a
+// transient API error.
+func (c *Controller) updateStatus(ctx context.Context, crd *koptv1alpha1.Recommendation) error {
+	status := *crd.Status.DeepCopy()
+	attempt := 0
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool { return !apierrors.IsNotFound(err) }, func() error {
+		if attempt++; attempt > 1 {
+			if err := c.client.Get(ctx, client.ObjectKeyFromObject(crd), crd); err != nil {
+				return err
+			}
+			crd.Status = *status.DeepCopy()
+		}
+		return c.client.Status().Update(ctx, crd)
+	})
+}
+
+// checkFamilyLock re-validates node group operations against the family lock.
+func (c *Controller) checkFamilyLock(ctx context.Context, rec optimizer.Recommendation) error {
+	if c.guard == nil {
+		return nil
+	}
+	if action, ok := FamilyLockAction(rec); ok {
+		if err := c.guard.ValidateNodeGroupAction(action); err != nil {
+			return err
+		}
+	}
+	// Recommendations that propose a concrete instance type must stay in
+	// the node group's current family.
+	if ngID, proposed := rec.Details["nodeGroupID"], rec.Details["instanceType"]; ngID != "" && proposed != "" {
+		if err := c.guard.ValidateScaleUpCtx(ctx, ngID, proposed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FamilyLockAction maps a recommendation to the node group action it performs.
+// The boolean is false for recommendations that do not touch node groups.
+func FamilyLockAction(rec optimizer.Recommendation) (familylock.NodeGroupAction, bool) {
+	if rec.Details["action"] == "change-instance-type" {
+		return familylock.NodeGroupChangeType, true
+	}
+	switch rec.Type {
+	case optimizer.RecommendationNodeGroupDelete:
+		return familylock.NodeGroupDelete, true
+	case optimizer.RecommendationNodeGroupAdjust:
+		if rec.Details["action"] == "set-max" {
+			return familylock.NodeGroupModifyMax, true
+		}
+		return familylock.NodeGroupModifyMin, true
+	case optimizer.RecommendationNodeScale, optimizer.RecommendationEviction,
+		optimizer.RecommendationRebalance, optimizer.RecommendationHibernation:
+		return familylock.NodeGroupScale, true
+	}
+	return 0, false
+}
+
+// FromCRD converts a Recommendation CRD back to the optimizer representation
+// used by controller executors.
+func FromCRD(crd *koptv1alpha1.Recommendation) optimizer.Recommendation {
+	details := make(map[string]string, len(crd.Spec.Details))
+	for k, v := range crd.Spec.Details {
+		details[k] = v
+	}
+	return optimizer.Recommendation{
+		ID:              crd.Name,
+		Type:            optimizer.RecommendationType(crd.Spec.Type),
+		Priority:        optimizer.Priority(crd.Spec.Priority),
+		AutoExecutable:  crd.Spec.AutoExecutable,
+		RequiresAIGate:  crd.Spec.RequiresAIGate,
+		TargetKind:      crd.Spec.TargetKind,
+		TargetName:      crd.Spec.TargetName,
+		TargetNamespace: crd.Spec.TargetNamespace,
+		Summary:         crd.Spec.Summary,
+		ActionSteps:     crd.Spec.ActionSteps,
+		EstimatedSaving: optimizer.SavingEstimate{
+			MonthlySavingsUSD: crd.Spec.EstimatedSaving.MonthlySavingsUSD,
+			AnnualSavingsUSD:  crd.Spec.EstimatedSaving.AnnualSavingsUSD,
+			Currency:          crd.Spec.EstimatedSaving.Currency,
+		},
+		EstimatedImpact: optimizer.ImpactEstimate{
+			MonthlyCostChangeUSD: crd.Spec.EstimatedImpact.MonthlyCostChangeUSD,
+			NodesAffected:        crd.Spec.EstimatedImpact.NodesAffected,
+			PodsAffected:         crd.Spec.EstimatedImpact.PodsAffected,
+			RiskLevel:            crd.Spec.EstimatedImpact.RiskLevel,
+		},
+		Details:   details,
+		CreatedAt: crd.CreationTimestamp.Time,
+	}
+}
+
+func targetString(rec optimizer.Recommendation) string {
+	if rec.TargetNamespace != "" {
+		return rec.TargetNamespace + "/" + rec.TargetKind + "/" + rec.TargetName
+	}
+	return rec.TargetKind + "/" + rec.TargetName
+}
@@ -0,0 +1,265 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/familylock"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+func TestFamilyLockAction(t *testing.T) {
+	tests := []struct {
+		name       string
+		rec        optimizer.Recommendation
+		wantAction familylock.NodeGroupAction
+		wantOK     bool
+	}{
+		{
+			name:       "set-min maps to modify min",
+			rec:        optimizer.Recommendation{Type: optimizer.RecommendationNodeGroupAdjust, Details: map[string]string{"action": "set-min"}},
+			wantAction: familylock.NodeGroupModifyMin,
+			wantOK:     true,
+		},
+		{
+			name:       "node group delete is a delete",
+			rec:        optimizer.Recommendation{Type: optimizer.RecommendationNodeGroupDelete},
+			wantAction: familylock.NodeGroupDelete,
+			wantOK:     true,
+		},
+		{
+			name:       "instance type change is blocked regardless of type",
+			rec:        optimizer.Recommendation{Type: optimizer.RecommendationNodeScale, Details: map[string]string{"action": "change-instance-type"}},
+			wantAction: familylock.NodeGroupChangeType,
+			wantOK:     true,
+		},
+		{
+			name:       "hibernation scales node groups",
+			rec:        optimizer.Recommendation{Type: optimizer.RecommendationHibernation},
+			wantAction: familylock.NodeGroupScale,
+			wantOK:     true,
+		},
+		{
+			name:   "pod rightsize does not touch node groups",
+			rec:    optimizer.Recommendation{Type: optimizer.RecommendationPodRightsize},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, ok := FamilyLockAction(tt.rec)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && action != tt.wantAction {
+				t.Errorf("action = %d, want %d", action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestFromCRD(t *testing.T) {
+	crd := &koptv1alpha1.Recommendation{
+		ObjectMeta: metav1.ObjectMeta{Name: "minadj-ng-1", Namespace: RecommendationNamespace},
+		Spec: koptv1alpha1.RecommendationSpec{
+			Type:           string(optimizer.RecommendationNodeGroupAdjust),
+			Priority:       string(optimizer.PriorityMedium),
+			TargetKind:     "NodeGroup",
+			TargetName:     "ng-1",
+			Summary:        "Reduce min count",
+			RequiresAIGate: true,
+			EstimatedImpact: koptv1alpha1.ImpactEstimate{
+				NodesAffected: 2,
+				RiskLevel:     "medium",
+			},
+			Details: map[string]string{"nodeGroupID": "ng-1", "newMin": "1"},
+		},
+	}
+
+	rec := FromCRD(crd)
+	if rec.ID != "minadj-ng-1" {
+		t.Errorf("ID = %q, want %q", rec.ID, "minadj-ng-1")
+	}
+	if rec.Type != optimizer.RecommendationNodeGroupAdjust {
+		t.Errorf("Type = %q, want %q", rec.Type, optimizer.RecommendationNodeGroupAdjust)
+	}
+	if !rec.RequiresAIGate {
+		t.Error("RequiresAIGate should be carried over")
+	}
+	if rec.EstimatedImpact.NodesAffected != 2 {
+		t.Errorf("NodesAffected = %d, want 2", rec.EstimatedImpact.NodesAffected)
+	}
+	if rec.Details["newMin"] != "1" {
+		t.Errorf("Details[newMin] = %q, want %q", rec.Details["newMin"], "1")
+	}
+
+	// Details must be copied so executors cannot mutate the cached CRD.
+	rec.Details["newMin"] = "0"
+	if crd.Spec.Details["newMin"] != "1" {
+		t.Error("FromCRD should copy Details, not alias the CRD map")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Execution Tests
+// ---------------------------------------------------------------------------
+
+func approvedCRD(name string, recType optimizer.RecommendationType, state string) *koptv1alpha1.Recommendation {
+	return &koptv1alpha1.Recommendation{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: RecommendationNamespace},
+		Spec: koptv1alpha1.RecommendationSpec{
+			Type:       string(recType),
+			TargetKind: "Deployment",
+			TargetName: "web",
+			Summary:    "Rightsize web",
+		},
+		Status: koptv1alpha1.RecommendationStatus{State: state},
+	}
+}
+
+func newTestExecutor(t *testing.T, funcs interceptor.Funcs, objs ...client.Object) (*Controller, client.Client) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := koptv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&koptv1alpha1.Recommendation{}).WithInterceptorFuncs(funcs).Build()
+	return &Controller{
+		client:   c,
+		state:    state.NewClusterState(nil, nil, nil, nil, nil, nil),
+		config:   config.DefaultConfig(),
+		handlers: make(map[optimizer.RecommendationType]ExecuteFunc),
+		waits:    make(map[optimizer.RecommendationType]WaitFunc),
+	}, c
+}
+
+func getCRD(t *testing.T, c client.Client, name string) *koptv1alpha1.Recommendation {
+	t.Helper()
+	var crd koptv1alpha1.Recommendation
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: RecommendationNamespace, Name: name}, &crd); err != nil {
+		t.Fatal(err)
+	}
+	return &crd
+}
+
+func TestExecuteOne(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  bool
+		handler   error
+		wantState string
+		wantError string
+	}{
+		{name: "success", register: true, wantState: "executed"},
+		{name: "handler error", register: true, handler: errors.New("unsupported action"), wantState: "failed", wantError: "unsupported action"},
+		{name: "no handler", register: false, wantState: "failed", wantError: "no executor registered"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl, c := newTestExecutor(t, interceptor.Funcs{}, approvedCRD("rs-web", optimizer.RecommendationPodRightsize, "approved"))
+			calls := 0
+			if tt.register {
+				ctrl.Register(optimizer.RecommendationPodRightsize, func(ctx context.Context, rec optimizer.Recommendation) error {
+					calls++
+					if got := getCRD(t, c, "rs-web").Status.State; got != "executing" {
+						t.Errorf("state during execution = %q, want executing", got)
+					}
+					return tt.handler
+				})
+			}
+
+			err := ctrl.executeOne(context.Background(), getCRD(t, c, "rs-web"))
+			if (err != nil) != (tt.wantError != "") {
+				t.Fatalf("executeOne() error = %v, want error %q", err, tt.wantError)
+			}
+			crd := getCRD(t, c, "rs-web")
+			if crd.Status.State != tt.wantState || !strings.Contains(crd.Status.Error, tt.wantError) {
+				t.Errorf("status = %q (%q), want %q (%q)", crd.Status.State, crd.Status.Error, tt.wantState, tt.wantError)
+			}
+			if tt.register && calls != 1 {
+				t.Errorf("handler called %d times, want 1", calls)
+			}
+		})
+	}
+}
+
+func TestExecuteOne_RetriesFinalStatusUpdate(t *testing.T) {
+	conflicts := 0
+	funcs := interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, sub string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			if rec := obj.(*koptv1alpha1.Recommendation); rec.Status.State == "executed" && conflicts < 2 {
+				conflicts++
+				return apierrors.NewConflict(schema.GroupResource{Resource: "recommendations"}, rec.Name, errors.New("modified"))
+			}
+			return c.SubResource(sub).Update(ctx, obj, opts...)
+		},
+	}
+	ctrl, c := newTestExecutor(t, funcs, approvedCRD("rs-web", optimizer.RecommendationPodRightsize, "approved"))
+	ctrl.Register(optimizer.RecommendationPodRightsize, func(context.Context, optimizer.Recommendation) error { return nil })
+
+	if err := ctrl.executeOne(context.Background(), getCRD(t, c, "rs-web")); err != nil {
+		t.Fatal(err)
+	}
+	if got := getCRD(t, c, "rs-web").Status.State; got != "executed" {
+		t.Errorf("state = %q after %d conflicts, want executed", got, conflicts)
+	}
+}
+
+func TestExecuteApproved_Waits(t *testing.T) {
+	ctrl, c := newTestExecutor(t, interceptor.Funcs{}, approvedCRD("rs-web", optimizer.RecommendationPodRightsize, "approved"))
+	calls := 0
+	ctrl.Register(optimizer.RecommendationPodRightsize, func(context.Context, optimizer.Recommendation) error {
+		calls++
+		return nil
+	})
+	reason := "outside the maintenance window"
+	ctrl.RegisterWait(optimizer.RecommendationPodRightsize, func(context.Context, optimizer.Recommendation) string { return reason })
+
+	if err := ctrl.executeApproved(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	crd := getCRD(t, c, "rs-web")
+	if calls != 0 || crd.Status.State != "approved" || crd.Status.ExecutionResult != "Waiting: "+reason {
+		t.Errorf("while waiting: calls = %d, status = %q (%q), want 0, approved (Waiting: %s)", calls, crd.Status.State, crd.Status.ExecutionResult, reason)
+	}
+
+	reason = ""
+	if err := ctrl.executeApproved(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := getCRD(t, c, "rs-web").Status.State; calls != 1 || got != "executed" {
+		t.Errorf("after the wait: calls = %d, state = %q, want 1, executed", calls, got)
+	}
+}
+
+func TestRecoverInterrupted(t *testing.T) {
+	ctrl, c := newTestExecutor(t, interceptor.Funcs{},
+		approvedCRD("stuck", optimizer.RecommendationPodRightsize, "executing"),
+		approvedCRD("queued", optimizer.RecommendationPodRightsize, "approved"))
+
+	if err := ctrl.recoverInterrupted(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if crd := getCRD(t, c, "stuck"); crd.Status.State != "failed" || !strings.Contains(crd.Status.Error, "interrupted") {
+		t.Errorf("stuck = %q (%q), want failed as interrupted", crd.Status.State, crd.Status.Error)
+	}
+	if got := getCRD(t, c, "queued").Status.State; got != "approved" {
+		t.Errorf("queued = %q, want approved", got)
+	}
+}
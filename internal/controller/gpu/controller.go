@@ -31,13 +31,18 @@ type Controller struct {
 
 func NewController(mgr ctrl.Manager, st *state.ClusterState, guard *familylock.FamilyLockGuard, gate *aigate.AIGate, cfg *config.Config) *Controller {
 	c := mgr.GetClient()
+	// Guard against a typed-nil interface when no metrics store is configured.
+	var history GPUHistory
+	if ms := st.MetricsStore(); ms != nil {
+		history = ms
+	}
 	return &Controller{
 		client:        c,
 		state:         st,
 		guard:         guard,
 		gate:          gate,
 		config:        cfg,
-		detector:      NewDetector(cfg, st.AuditLog),
+		detector:      NewDetector(cfg, st.AuditLog, history),
 		fallback:      NewFallbackManager(c, cfg, st.AuditLog),
 		scavenger:     NewScavenger(c, cfg, st.AuditLog),
 		reclaimer:     NewReclaimer(c, cfg, st.NodeLock, st.AuditLog),
@@ -116,16 +121,20 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 
 	// AI Gate validation — fail-closed
 	if c.gate.RequiresValidation(rec) {
-		valReq := aigate.ValidationRequest{
-			Action:         rec.Summary,
-			Recommendation: rec,
-		}
+		valReq := c.state.GateRequest(ctx, rec)
 		result, err := c.gate.Validate(ctx, valReq)
 		if err != nil || !result.Approved {
 			return nil // Falls back to recommendation mode
 		}
 	}
 
+	return c.ExecuteApproved(ctx, rec)
+}
+
+// ExecuteApproved applies a recommendation by its action: scavenging,
+// reclaim and redistribution each have their own component, and every other
+// action is a CPU fallback taint change.
+func (c *Controller) ExecuteApproved(ctx context.Context, rec optimizer.Recommendation) error {
 	action := rec.Details["action"]
 	switch action {
 	case "enable-cpu-scavenging", "disable-cpu-scavenging", "update-cpu-scavenging":
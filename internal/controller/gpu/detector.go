@@ -11,25 +11,44 @@ import (
 	"github.com/koptimizer/koptimizer/internal/config"
 	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
 	"github.com/koptimizer/koptimizer/internal/state"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
 	"github.com/koptimizer/koptimizer/pkg/optimizer"
 )
 
-// Detector identifies idle and underutilized GPU nodes.
+// GPUHistory provides recorded GPU utilization for idle detection.
+type GPUHistory interface {
+	GetGPUWindow(nodeName string, duration time.Duration) *pkgmetrics.GPUWindow
+}
+
+// Detector identifies idle and underutilized GPU nodes. When measured GPU
+// history is available, idleness is decided from recorded utilization over
+// the idle window; otherwise it falls back to GPU allocation.
 type Detector struct {
 	config    *config.Config
 	auditLog  *state.AuditLog
+	history   GPUHistory
 	mu        sync.Mutex
 	idleSince map[string]time.Time // nodeName -> when it became idle
 }
 
-func NewDetector(cfg *config.Config, auditLog *state.AuditLog) *Detector {
+func NewDetector(cfg *config.Config, auditLog *state.AuditLog, history GPUHistory) *Detector {
 	return &Detector{
 		config:    cfg,
 		auditLog:  auditLog,
+		history:   history,
 		idleSince: make(map[string]time.Time),
 	}
 }
 
+// measuredWindow returns the recorded GPU window for the node, or nil if the
+// node has no measured history.
+func (d *Detector) measuredWindow(node *optimizer.NodeInfo) *pkgmetrics.GPUWindow {
+	if d.history == nil || !node.GPUMeasured {
+		return nil
+	}
+	return d.history.GetGPUWindow(node.Node.Name, d.config.GPU.IdleDuration)
+}
+
 // DetectIdle finds GPU nodes that have been idle beyond the configured threshold.
 func (d *Detector) DetectIdle(ctx context.Context, snapshot *optimizer.ClusterSnapshot) ([]optimizer.Recommendation, error) {
 	var recs []optimizer.Recommendation
@@ -40,15 +59,27 @@ func (d *Detector) DetectIdle(ctx context.Context, snapshot *optimizer.ClusterSn
 			continue
 		}
 
-		// Check if GPU is idle
-		gpuIdle := node.GPUsUsed == 0
-		gpuUnderutilized := false
-		if node.GPUs > 0 {
-			gpuUtilPct := float64(node.GPUsUsed) / float64(node.GPUs) * 100
-			gpuUnderutilized = gpuUtilPct < d.config.GPU.IdleThresholdPct
+		var gpuIdle bool
+		var idleDuration time.Duration
+		basis := "allocation"
+		window := d.measuredWindow(&node)
+		if window != nil {
+			// Measured: idle only if P95 utilization over the recorded
+			// window stayed below the threshold. Allocated-but-unused GPUs
+			// count as idle here.
+			basis = "measured"
+			gpuIdle = window.P95Utilization < d.config.GPU.IdleThresholdPct
+			idleDuration = time.Since(window.Start)
+		} else {
+			// Allocation: idle when no (or too few) GPUs are requested.
+			gpuIdle = node.GPUsUsed == 0
+			if node.GPUs > 0 {
+				gpuUtilPct := float64(node.GPUsUsed) / float64(node.GPUs) * 100
+				gpuIdle = gpuIdle || gpuUtilPct < d.config.GPU.IdleThresholdPct
+			}
 		}
 
-		if gpuIdle || gpuUnderutilized {
+		if gpuIdle {
 			// Track how long it's been idle
 			d.mu.Lock()
 			firstDetection := false
@@ -56,16 +87,23 @@ func (d *Detector) DetectIdle(ctx context.Context, snapshot *optimizer.ClusterSn
 				d.idleSince[node.Node.Name] = time.Now()
 				firstDetection = true
 			}
-
-			idleDuration := time.Since(d.idleSince[node.Node.Name])
+			if window == nil {
+				idleDuration = time.Since(d.idleSince[node.Node.Name])
+			}
 			d.mu.Unlock()
 
 			if firstDetection {
 				d.auditLog.Record("gpu-idle-detected", node.Node.Name, "gpu-detector",
-					fmt.Sprintf("GPU node idle (GPUs: %d, used: %d), grace period started", node.GPUs, node.GPUsUsed))
+					fmt.Sprintf("GPU node idle by %s (GPUs: %d, used: %d, util: %.1f%%), grace period started", basis, node.GPUs, node.GPUsUsed, node.GPUUtilization))
 			}
 
-			if idleDuration >= d.config.GPU.IdleDuration {
+			// Measured history may cover slightly less than IdleDuration
+			// because samples are taken once per refresh.
+			required := d.config.GPU.IdleDuration
+			if window != nil {
+				required = required * 9 / 10
+			}
+			if idleDuration >= required {
 				idleCount++
 				monthlyCost := node.HourlyCostUSD * 730
 
@@ -100,8 +138,10 @@ func (d *Detector) DetectIdle(ctx context.Context, snapshot *optimizer.ClusterSn
 					},
 					Details: map[string]string{
 						"nodeName":           node.Node.Name,
+						"idleBasis":          basis,
 						"gpuCount":           fmt.Sprintf("%d", node.GPUs),
 						"gpuUsed":            fmt.Sprintf("%d", node.GPUsUsed),
+						"gpuUtilPct":         fmt.Sprintf("%.1f", node.GPUUtilization),
 						"idleDuration":       idleDuration.String(),
 						"action":             "enable-cpu-fallback",
 						"monthlyCostUSD":     fmt.Sprintf("%.2f", monthlyCost),
@@ -209,6 +209,19 @@ func violatesGPURequestPolicy(pod *corev1.Pod) bool {
 	return false
 }
 
+// ViolatesFallbackPodPolicy reports whether pod is a CPU pod meant for GPU
+// fallback or scavenging, by its PriorityClass, that still lists
+// nvidia.com/gpu. The pod webhook uses it to catch such pods at creation
+// rather than once they run on a fallback node.
+func ViolatesFallbackPodPolicy(pod *corev1.Pod) bool {
+	switch pod.Spec.PriorityClassName {
+	case GPUFallbackPriority, GPUScavengerPriority:
+		return violatesGPURequestPolicy(pod)
+	default:
+		return false
+	}
+}
+
 // ComputeCPUHeadroom calculates how much CPU is available for scavenger (CPU fallback)
 // pods on a GPU node, after reserving capacity for GPU pod data-loading phases.
 //
@@ -174,8 +174,29 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 	}
 }
 
+// ExecuteApproved hibernates or wakes the cluster for a user-approved
+// recommendation, skipping the mode and AI Gate checks of the schedule. An
+// unknown action is an error so the recommendation is not marked executed.
+func (c *Controller) ExecuteApproved(ctx context.Context, rec optimizer.Recommendation) error {
+	action := rec.Details["action"]
+	switch action {
+	case "hibernate":
+		return c.hibernate(ctx, true)
+	case "wake":
+		return c.wake(ctx, true)
+	default:
+		return fmt.Errorf("unsupported hibernation action %q", action)
+	}
+}
+
 // Hibernate scales all non-excluded node groups to zero, saving their state.
 func (c *Controller) Hibernate(ctx context.Context) error {
+	return c.hibernate(ctx, false)
+}
+
+// hibernate implements Hibernate. An approved hibernation skips the mode and
+// AI Gate checks.
+func (c *Controller) hibernate(ctx context.Context, approved bool) error {
 	logger := log.FromContext(ctx).WithName("hibernation")
 
 	c.mu.Lock()
@@ -186,7 +207,7 @@ func (c *Controller) Hibernate(ctx context.Context) error {
 		return nil
 	}
 
-	if c.config.GetMode() != "active" {
+	if !approved && c.config.GetMode() != "active" {
 		logger.Info("Not in active mode, skipping hibernate")
 		return nil
 	}
@@ -200,7 +221,7 @@ func (c *Controller) Hibernate(ctx context.Context) error {
 	}
 
 	// AI Gate validation for hibernation (high-impact operation)
-	if c.gate != nil {
+	if c.gate != nil && !approved {
 		rec := optimizer.Recommendation{
 			Summary:        "Hibernate cluster: scale all non-excluded node groups to minimum",
 			RequiresAIGate: true,
@@ -209,11 +230,7 @@ func (c *Controller) Hibernate(ctx context.Context) error {
 			},
 		}
 		if c.gate.RequiresValidation(rec) {
-			valReq := aigate.ValidationRequest{
-				Action:         rec.Summary,
-				Recommendation: rec,
-				RiskFactors:    []string{"Cluster hibernation scales most node groups to minimum"},
-			}
+			valReq := c.state.GateRequest(ctx, rec, "Cluster hibernation scales most node groups to minimum")
 			result, err := c.gate.Validate(ctx, valReq)
 			if err != nil || !result.Approved {
 				logger.Info("AI Gate rejected hibernation", "reasoning", result.Reasoning)
@@ -290,6 +307,11 @@ func (c *Controller) Hibernate(ctx context.Context) error {
 
 // Wake restores node groups to their pre-hibernation desired counts.
 func (c *Controller) Wake(ctx context.Context) error {
+	return c.wake(ctx, false)
+}
+
+// wake implements Wake. An approved wake skips the mode check.
+func (c *Controller) wake(ctx context.Context, approved bool) error {
 	logger := log.FromContext(ctx).WithName("hibernation")
 
 	c.mu.Lock()
@@ -300,7 +322,7 @@ func (c *Controller) Wake(ctx context.Context) error {
 		return nil
 	}
 
-	if c.config.GetMode() != "active" {
+	if !approved && c.config.GetMode() != "active" {
 		logger.Info("Not in active mode, skipping wake")
 		return nil
 	}
@@ -324,6 +346,12 @@ func (c *Controller) Wake(ctx context.Context) error {
 			}
 		}
 
+		// Karpenter NodePools size themselves to demand; waking only lifts
+		// the hibernation cap and must not remove nodes added since.
+		if ng.Source == cloudprovider.NodeGroupSourceKarpenter && savedDesired < ng.CurrentCount {
+			savedDesired = ng.CurrentCount
+		}
+
 		if err := c.provider.ScaleNodeGroup(ctx, ng.ID, savedDesired); err != nil {
 			logger.Error(err, "Failed to wake node group", "nodeGroup", ng.Name)
 			continue
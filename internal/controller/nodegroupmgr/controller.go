@@ -98,10 +98,7 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 	// AI Gate validation — fail-closed: use RequiresValidation which checks
 	// both the RequiresAIGate flag AND actual impact metrics.
 	if c.gate.RequiresValidation(rec) {
-		valReq := aigate.ValidationRequest{
-			Action:         rec.Summary,
-			Recommendation: rec,
-		}
+		valReq := c.state.GateRequest(ctx, rec)
 		result, err := c.gate.Validate(ctx, valReq)
 		if err != nil {
 			rec.AIGateResult = &optimizer.AIGateResult{
@@ -125,6 +122,17 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 	return rec, err == nil, err
 }
 
+// ExecuteApproved applies a user-approved recommendation. Mode, family lock
+// and AI Gate checks are performed by the recommendation executor.
+func (c *Controller) ExecuteApproved(ctx context.Context, rec optimizer.Recommendation) error {
+	switch rec.Details["action"] {
+	case "set-min":
+		return c.minAdjuster.Execute(ctx, rec)
+	default:
+		return fmt.Errorf("unsupported node group action %q", rec.Details["action"])
+	}
+}
+
 func (c *Controller) run(ctx context.Context) {
 	logger := log.FromContext(ctx).WithName("nodegroupmgr")
 	ticker := time.NewTicker(60 * time.Second)
@@ -0,0 +1,367 @@
+package rebalancer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/controller/evictor"
+	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/familylock"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// Report summarizes one scheduled rebalance run.
+type Report struct {
+	StartedAt                  time.Time `json:"startedAt"`
+	FinishedAt                 time.Time `json:"finishedAt"`
+	NodesPlanned               int       `json:"nodesPlanned"`
+	NodesDrained               int       `json:"nodesDrained"`
+	WavesPlanned               int       `json:"wavesPlanned"`
+	WavesCompleted             int       `json:"wavesCompleted"`
+	ProjectedMonthlySavingsUSD float64   `json:"projectedMonthlySavingsUSD"`
+	AchievedMonthlySavingsUSD  float64   `json:"achievedMonthlySavingsUSD"`
+	StoppedReason              string    `json:"stoppedReason,omitempty"` // empty if the plan completed
+}
+
+// Controller repacks fragmented node groups on a cron schedule. Each run
+// plans a target packing with the scheduler simulator, then drains the
+// planned nodes in bounded waves while the maintenance window is open,
+// stopping early if any pod becomes unschedulable.
+type Controller struct {
+	client   client.Client
+	provider cloudprovider.CloudProvider
+	state    *state.ClusterState
+	guard    *familylock.FamilyLockGuard
+	gate     *aigate.AIGate
+	config   *config.Config
+	planner  *Planner
+	drainer  *evictor.Drainer
+	cron     *cron.Cron
+
+	mu         sync.Mutex
+	running    bool
+	lastReport *Report
+}
+
+func NewController(mgr ctrl.Manager, provider cloudprovider.CloudProvider, st *state.ClusterState, guard *familylock.FamilyLockGuard, gate *aigate.AIGate, cfg *config.Config) *Controller {
+	c := mgr.GetClient()
+	return &Controller{
+		client:   c,
+		provider: provider,
+		state:    st,
+		guard:    guard,
+		gate:     gate,
+		config:   cfg,
+		planner:  NewPlanner(st.NodeLock, cfg),
+		drainer:  evictor.NewDrainer(c, provider, st.NodeLock, st.AuditLog, "rebalancer", cfg.Rebalancer.DrainTimeout),
+		cron:     cron.New(),
+	}
+}
+
+func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	if _, err := cron.ParseStandard(c.config.Rebalancer.Schedule); err != nil {
+		return fmt.Errorf("invalid rebalancer schedule %q: %w", c.config.Rebalancer.Schedule, err)
+	}
+	return mgr.Add(c)
+}
+
+// Start implements manager.Runnable. It registers the maintenance window
+// schedule with the manager context, then blocks until shutdown.
+func (c *Controller) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("rebalancer")
+
+	schedule := c.config.Rebalancer.Schedule
+	if _, err := c.cron.AddFunc(schedule, func() {
+		if _, err := c.RunOnce(ctx); err != nil {
+			logger.Error(err, "Rebalance run failed", "schedule", schedule)
+		}
+	}); err != nil {
+		return fmt.Errorf("invalid rebalancer schedule %q: %w", schedule, err)
+	}
+
+	c.cron.Start()
+	c.run(ctx)
+	return nil
+}
+
+func (c *Controller) Name() string { return "rebalancer" }
+
+// Analyze updates the imbalance gauges and returns one rebalance
+// recommendation per node in the current target packing.
+func (c *Controller) Analyze(ctx context.Context, snapshot *optimizer.ClusterSnapshot) ([]optimizer.Recommendation, error) {
+	plan := c.planner.Plan(snapshot)
+	intmetrics.RebalanceImbalance.Reset()
+	for _, g := range plan.Groups {
+		intmetrics.RebalanceImbalance.WithLabelValues(g.NodeGroupID).Set(g.Imbalance)
+	}
+	return plan.Recommendations(), nil
+}
+
+// Execute drains a single planned node outside the scheduled run.
+func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation) error {
+	if c.config.GetMode() != "active" {
+		return nil
+	}
+	if !rec.AutoExecutable {
+		return nil
+	}
+
+	// AI Gate validation — fail-closed
+	if c.gate.RequiresValidation(rec) {
+		valReq := c.state.GateRequest(ctx, rec, "Node drain evicts all non-DaemonSet pods on the node")
+		result, err := c.gate.Validate(ctx, valReq)
+		if err != nil || !result.Approved {
+			return nil // Falls back to recommendation mode
+		}
+	}
+
+	return c.ExecuteApproved(ctx, rec)
+}
+
+// ExecuteApproved drains the planned node. It still honours the family lock
+// and refuses to drain while any pod is unschedulable.
+func (c *Controller) ExecuteApproved(ctx context.Context, rec optimizer.Recommendation) error {
+	if err := c.guard.ValidateNodeGroupAction(familylock.NodeGroupScale); err != nil {
+		return err
+	}
+	pending, err := c.unschedulablePodCount(ctx)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d pods are unschedulable, refusing to drain node %s", pending, rec.Details["nodeName"])
+	}
+	return c.drainer.Execute(ctx, rec)
+}
+
+// LastReport returns the report of the most recent scheduled run, or nil.
+func (c *Controller) LastReport() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastReport == nil {
+		return nil
+	}
+	r := *c.lastReport
+	return &r
+}
+
+// RunOnce executes one scheduled rebalance: plan, then drain wave by wave
+// until the plan completes, the maintenance window closes, or pods become
+// unschedulable. A nil report means the run was skipped.
+func (c *Controller) RunOnce(ctx context.Context) (*Report, error) {
+	logger := log.FromContext(ctx).WithName("rebalancer")
+
+	if !c.config.IsControllerEnabled("rebalancer") {
+		return nil, nil
+	}
+	if c.config.GetMode() != "active" {
+		logger.Info("Not in active mode, skipping scheduled rebalance")
+		return nil, nil
+	}
+	if c.state.Breaker.IsTripped(c.Name()) {
+		logger.V(1).Info("Circuit breaker tripped, skipping scheduled rebalance")
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("previous rebalance run still in progress")
+	}
+	c.running = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.running = false
+		c.mu.Unlock()
+	}()
+
+	windowEnd := time.Now().Add(c.config.Rebalancer.MaintenanceWindow)
+
+	if err := c.guard.ValidateNodeGroupAction(familylock.NodeGroupScale); err != nil {
+		logger.Info("Family lock guard blocked rebalance", "error", err)
+		return nil, err
+	}
+
+	// Never start while the cluster is already short on capacity.
+	pending, err := c.unschedulablePodCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		logger.Info("Unschedulable pods present, skipping scheduled rebalance", "pending", pending)
+		return nil, nil
+	}
+
+	plan := c.planner.Plan(c.state.Snapshot())
+	report := &Report{
+		StartedAt:                  time.Now(),
+		NodesPlanned:               plan.NodeCount(),
+		WavesPlanned:               len(plan.Waves),
+		ProjectedMonthlySavingsUSD: plan.ProjectedMonthlySavingsUSD,
+	}
+	if report.NodesPlanned == 0 {
+		logger.V(1).Info("No node group exceeds the imbalance threshold")
+		return nil, nil
+	}
+
+	// AI Gate reviews the run as a whole — fail-closed.
+	runRec := optimizer.Recommendation{
+		Type:           optimizer.RecommendationRebalance,
+		Summary:        fmt.Sprintf("Rebalance %d nodes in %d waves, projected savings $%.2f/mo", report.NodesPlanned, report.WavesPlanned, report.ProjectedMonthlySavingsUSD),
+		RequiresAIGate: true,
+		EstimatedImpact: optimizer.ImpactEstimate{
+			MonthlyCostChangeUSD: -report.ProjectedMonthlySavingsUSD,
+			NodesAffected:        report.NodesPlanned,
+			RiskLevel:            "medium",
+		},
+	}
+	if c.gate.RequiresValidation(runRec) {
+		result, err := c.gate.Validate(ctx, c.state.GateRequest(ctx, runRec, "Rebalance drains multiple nodes and reschedules their pods"))
+		if err != nil || !result.Approved {
+			logger.Info("AI Gate rejected rebalance", "nodes", report.NodesPlanned)
+			return nil, nil
+		}
+	}
+
+	c.state.AuditLog.Record("rebalance-start", fmt.Sprintf("%d nodes", report.NodesPlanned), c.Name(), runRec.Summary)
+	recsByNode := make(map[string]optimizer.Recommendation)
+	for _, rec := range plan.Recommendations() {
+		recsByNode[rec.Details["nodeName"]] = rec
+	}
+
+	report.StoppedReason = c.executeWaves(ctx, plan, recsByNode, windowEnd, report)
+	report.FinishedAt = time.Now()
+	c.finish(ctx, report)
+	return report, nil
+}
+
+// executeWaves drains the plan wave by wave, updating report as it goes. It
+// returns the reason the run stopped early, or "" if the plan completed.
+func (c *Controller) executeWaves(ctx context.Context, plan *Plan, recsByNode map[string]optimizer.Recommendation, windowEnd time.Time, report *Report) string {
+	logger := log.FromContext(ctx).WithName("rebalancer")
+
+	for i, wave := range plan.Waves {
+		if i > 0 {
+			select {
+			case <-time.After(c.config.Rebalancer.WaveInterval):
+			case <-ctx.Done():
+				return "shutdown"
+			}
+		}
+		if time.Now().After(windowEnd) {
+			return "maintenance window closed"
+		}
+
+		for _, d := range wave {
+			if err := c.drainer.Execute(ctx, recsByNode[d.NodeName]); err != nil {
+				// A single blocked node (PDB, new bare pod) does not void
+				// the rest of the plan; capacity for it was never consumed.
+				logger.Error(err, "Rebalance drain failed", "node", d.NodeName, "wave", i+1)
+				c.state.Breaker.RecordFailure(c.Name())
+				continue
+			}
+			c.state.Breaker.RecordSuccess(c.Name())
+			report.NodesDrained++
+			report.AchievedMonthlySavingsUSD += d.MonthlyCostUSD
+
+			pending, err := c.unschedulablePodCount(ctx)
+			if err != nil {
+				return fmt.Sprintf("checking pending pods: %v", err)
+			}
+			if pending > 0 {
+				return fmt.Sprintf("%d pods unschedulable after draining %s", pending, d.NodeName)
+			}
+		}
+		report.WavesCompleted++
+	}
+	return ""
+}
+
+func (c *Controller) finish(ctx context.Context, report *Report) {
+	logger := log.FromContext(ctx).WithName("rebalancer")
+
+	intmetrics.RebalanceProjectedSavingsUSD.Set(report.ProjectedMonthlySavingsUSD)
+	intmetrics.RebalanceAchievedSavingsUSD.Set(report.AchievedMonthlySavingsUSD)
+
+	summary := fmt.Sprintf("drained %d/%d nodes in %d/%d waves, achieved $%.2f/mo of projected $%.2f/mo",
+		report.NodesDrained, report.NodesPlanned, report.WavesCompleted, report.WavesPlanned,
+		report.AchievedMonthlySavingsUSD, report.ProjectedMonthlySavingsUSD)
+	if report.StoppedReason != "" {
+		summary += "; stopped: " + report.StoppedReason
+	}
+	c.state.AuditLog.Record("rebalance-complete", fmt.Sprintf("%d nodes", report.NodesDrained), c.Name(), summary)
+	logger.Info("Rebalance run finished",
+		"nodesDrained", report.NodesDrained,
+		"nodesPlanned", report.NodesPlanned,
+		"achievedMonthlyUSD", report.AchievedMonthlySavingsUSD,
+		"projectedMonthlyUSD", report.ProjectedMonthlySavingsUSD,
+		"stoppedReason", report.StoppedReason,
+	)
+
+	c.mu.Lock()
+	c.lastReport = report
+	c.mu.Unlock()
+}
+
+func (c *Controller) unschedulablePodCount(ctx context.Context) (int, error) {
+	podList := &corev1.PodList{}
+	if err := c.client.List(ctx, podList); err != nil {
+		return 0, fmt.Errorf("listing pods: %w", err)
+	}
+	return countUnschedulable(podList.Items), nil
+}
+
+// countUnschedulable counts pending pods the scheduler has marked
+// unschedulable. Pods that are merely pulling images are not counted.
+func countUnschedulable(pods []corev1.Pod) int {
+	count := 0
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodPending || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse && cond.Reason == corev1.PodReasonUnschedulable {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+func (c *Controller) run(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("rebalancer")
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !c.config.IsControllerEnabled("rebalancer") {
+				continue
+			}
+			// Between windows only refresh the imbalance gauges; draining
+			// happens exclusively in the scheduled run.
+			if _, err := c.Analyze(ctx, c.state.Snapshot()); err != nil {
+				logger.Error(err, "Rebalance analysis failed")
+			}
+		case <-ctx.Done():
+			c.cron.Stop()
+			return
+		}
+	}
+}
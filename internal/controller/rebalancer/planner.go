@@ -0,0 +1,290 @@
+package rebalancer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/controller/evictor"
+	"github.com/koptimizer/koptimizer/internal/scheduler"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// GroupImbalance describes how fragmented a node group is. Imbalance is the
+// fraction of the group's nodes that would be freed if its requests were
+// packed onto the fewest nodes: 1 - IdealNodes/CurrentNodes.
+type GroupImbalance struct {
+	NodeGroupID  string
+	Name         string
+	CurrentNodes int
+	IdealNodes   int
+	Imbalance    float64
+}
+
+// PlannedDrain is a single node in the target packing that will be emptied.
+type PlannedDrain struct {
+	NodeName       string
+	NodeGroupID    string
+	NodeGroupName  string
+	PodCount       int
+	Targets        []string
+	MonthlyCostUSD float64
+}
+
+// Plan is the target packing for one rebalance run, split into waves.
+type Plan struct {
+	Groups                     []GroupImbalance
+	Waves                      [][]PlannedDrain
+	ProjectedMonthlySavingsUSD float64
+}
+
+// NodeCount returns the total number of nodes the plan drains.
+func (p *Plan) NodeCount() int {
+	n := 0
+	for _, w := range p.Waves {
+		n += len(w)
+	}
+	return n
+}
+
+// Planner computes a target packing for fragmented node groups using the
+// scheduler simulator.
+type Planner struct {
+	simulator *scheduler.Simulator
+	nodeLock  *state.NodeLock
+	config    *config.Config
+}
+
+func NewPlanner(nodeLock *state.NodeLock, cfg *config.Config) *Planner {
+	return &Planner{
+		simulator: scheduler.NewSimulator(),
+		nodeLock:  nodeLock,
+		config:    cfg,
+	}
+}
+
+// Imbalance computes per-group fragmentation from ready, schedulable,
+// non-GPU nodes. Groups with fewer than two such nodes are omitted.
+func (p *Planner) Imbalance(snapshot *optimizer.ClusterSnapshot) []GroupImbalance {
+	type totals struct {
+		nodes          int
+		cpuReq, memReq int64
+		cpuCap, memCap int64
+	}
+	byGroup := make(map[string]*totals)
+	for i := range snapshot.Nodes {
+		n := &snapshot.Nodes[i]
+		if n.NodeGroup == "" || n.IsGPUNode || n.Node.Spec.Unschedulable || !scheduler.IsNodeReady(n.Node) {
+			continue
+		}
+		t, ok := byGroup[n.NodeGroup]
+		if !ok {
+			t = &totals{}
+			byGroup[n.NodeGroup] = t
+		}
+		t.nodes++
+		t.cpuReq += n.CPURequested
+		t.memReq += n.MemoryRequested
+		t.cpuCap += n.CPUCapacity
+		t.memCap += n.MemoryCapacity
+	}
+
+	names := make(map[string]string, len(snapshot.NodeGroups))
+	for _, g := range snapshot.NodeGroups {
+		names[g.ID] = g.Name
+	}
+
+	var result []GroupImbalance
+	for id, t := range byGroup {
+		if t.nodes < 2 || t.cpuCap == 0 || t.memCap == 0 {
+			continue
+		}
+		// Nodes in a group share an instance type, so the average node is
+		// a fair unit for the ideal count.
+		avgCPU := float64(t.cpuCap) / float64(t.nodes)
+		avgMem := float64(t.memCap) / float64(t.nodes)
+		ideal := int(math.Ceil(math.Max(float64(t.cpuReq)/avgCPU, float64(t.memReq)/avgMem)))
+		if ideal < 1 {
+			ideal = 1
+		}
+		if ideal > t.nodes {
+			ideal = t.nodes
+		}
+		result = append(result, GroupImbalance{
+			NodeGroupID:  id,
+			Name:         names[id],
+			CurrentNodes: t.nodes,
+			IdealNodes:   ideal,
+			Imbalance:    1 - float64(ideal)/float64(t.nodes),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Imbalance != result[j].Imbalance {
+			return result[i].Imbalance > result[j].Imbalance
+		}
+		return result[i].NodeGroupID < result[j].NodeGroupID
+	})
+	return result
+}
+
+// Plan selects nodes to empty in groups whose imbalance meets the configured
+// threshold. Each node is only planned if every movable pod fits on the
+// remaining nodes, with placements of earlier drains carried forward. Nodes
+// are ordered emptiest first and split into waves of WaveSize.
+func (p *Planner) Plan(snapshot *optimizer.ClusterSnapshot) *Plan {
+	cfg := p.config.Rebalancer
+	plan := &Plan{Groups: p.Imbalance(snapshot)}
+
+	excluded := make(map[string]bool, len(cfg.ExcludeNodeGroups))
+	for _, g := range cfg.ExcludeNodeGroups {
+		excluded[g] = true
+	}
+	groups := make(map[string]*cloudprovider.NodeGroup, len(snapshot.NodeGroups))
+	for _, g := range snapshot.NodeGroups {
+		groups[g.ID] = g
+	}
+
+	// budget is how many nodes each eligible group may lose: never more
+	// than the packing frees, and never below the group's min count.
+	budget := make(map[string]int)
+	for _, imb := range plan.Groups {
+		g, ok := groups[imb.NodeGroupID]
+		if !ok || excluded[g.ID] || excluded[g.Name] || imb.Imbalance < cfg.ImbalanceThreshold {
+			continue
+		}
+		budget[g.ID] = min(imb.CurrentNodes-imb.IdealNodes, g.CurrentCount-g.MinCount)
+	}
+	if len(budget) == 0 {
+		return plan
+	}
+
+	type candidate struct {
+		info    *optimizer.NodeInfo
+		reqFrac float64
+	}
+	var candidates []candidate
+	for i := range snapshot.Nodes {
+		n := &snapshot.Nodes[i]
+		if budget[n.NodeGroup] <= 0 || n.IsGPUNode || n.Node.Spec.Unschedulable || !scheduler.IsNodeReady(n.Node) {
+			continue
+		}
+		if n.CPUCapacity == 0 || n.MemoryCapacity == 0 {
+			continue
+		}
+		if locked, _ := p.nodeLock.IsLocked(n.Node.Name); locked {
+			continue
+		}
+		frac := math.Max(float64(n.CPURequested)/float64(n.CPUCapacity), float64(n.MemoryRequested)/float64(n.MemoryCapacity))
+		candidates = append(candidates, candidate{info: n, reqFrac: frac})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].reqFrac != candidates[j].reqFrac {
+			return candidates[i].reqFrac < candidates[j].reqFrac
+		}
+		return candidates[i].info.HourlyCostUSD > candidates[j].info.HourlyCostUSD
+	})
+
+	maxNodes := cfg.MaxNodesPerRun
+	podsByNode := evictor.ActivePodsByNode(snapshot)
+	removed := make(map[string]bool)
+	var drains []PlannedDrain
+	for _, cand := range candidates {
+		if maxNodes > 0 && len(drains) >= maxNodes {
+			break
+		}
+		groupID := cand.info.NodeGroup
+		if budget[groupID] <= 0 {
+			continue
+		}
+		nodeName := cand.info.Node.Name
+		placements, ok := evictor.SimulateDrain(p.simulator, nodeName, snapshot, podsByNode, removed, 0)
+		if !ok {
+			continue
+		}
+		drain := PlannedDrain{
+			NodeName:       nodeName,
+			NodeGroupID:    groupID,
+			NodeGroupName:  groups[groupID].Name,
+			MonthlyCostUSD: cand.info.HourlyCostUSD * cost.HoursPerMonth,
+		}
+		for target, pods := range placements {
+			podsByNode[target] = append(podsByNode[target], pods...)
+			drain.PodCount += len(pods)
+			drain.Targets = append(drain.Targets, target)
+		}
+		sort.Strings(drain.Targets)
+		removed[nodeName] = true
+		delete(podsByNode, nodeName)
+		budget[groupID]--
+
+		drains = append(drains, drain)
+		plan.ProjectedMonthlySavingsUSD += drain.MonthlyCostUSD
+	}
+
+	waveSize := cfg.WaveSize
+	if waveSize <= 0 {
+		waveSize = 1
+	}
+	for start := 0; start < len(drains); start += waveSize {
+		end := min(start+waveSize, len(drains))
+		plan.Waves = append(plan.Waves, drains[start:end])
+	}
+	return plan
+}
+
+// Recommendations converts the plan into one rebalance recommendation per
+// planned node, so each can be reviewed and approved individually.
+func (p *Plan) Recommendations() []optimizer.Recommendation {
+	imbalance := make(map[string]float64, len(p.Groups))
+	for _, g := range p.Groups {
+		imbalance[g.NodeGroupID] = g.Imbalance
+	}
+
+	now := time.Now()
+	var recs []optimizer.Recommendation
+	for wave, drains := range p.Waves {
+		for _, d := range drains {
+			recs = append(recs, optimizer.Recommendation{
+				ID:             fmt.Sprintf("rebalance-%s-%d", d.NodeName, now.Unix()),
+				Type:           optimizer.RecommendationRebalance,
+				Priority:       optimizer.PriorityMedium,
+				AutoExecutable: false, // executed in waves by the scheduled run
+				TargetKind:     "Node",
+				TargetName:     d.NodeName,
+				Summary: fmt.Sprintf("Rebalance node group %s (%.0f%% fragmented): drain %s, moving %d pods onto %d nodes, saves $%.2f/mo",
+					d.NodeGroupName, imbalance[d.NodeGroupID]*100, d.NodeName, d.PodCount, len(d.Targets), d.MonthlyCostUSD),
+				ActionSteps: []string{
+					fmt.Sprintf("Cordon node %s", d.NodeName),
+					fmt.Sprintf("Evict %d pods (PDB-checked) onto %d other nodes", d.PodCount, len(d.Targets)),
+					fmt.Sprintf("Scale node group %s down by one", d.NodeGroupName),
+				},
+				EstimatedSaving: optimizer.SavingEstimate{
+					MonthlySavingsUSD: d.MonthlyCostUSD,
+					AnnualSavingsUSD:  d.MonthlyCostUSD * 12,
+					Currency:          "USD",
+				},
+				EstimatedImpact: optimizer.ImpactEstimate{
+					MonthlyCostChangeUSD: -d.MonthlyCostUSD,
+					NodesAffected:        1,
+					PodsAffected:         d.PodCount,
+					RiskLevel:            "medium",
+				},
+				Details: map[string]string{
+					"action":      "rebalance-node",
+					"nodeName":    d.NodeName,
+					"nodeGroupID": d.NodeGroupID,
+					"podCount":    fmt.Sprintf("%d", d.PodCount),
+					"wave":        fmt.Sprintf("%d", wave+1),
+					"imbalance":   fmt.Sprintf("%.2f", imbalance[d.NodeGroupID]),
+				},
+				CreatedAt: now,
+			})
+		}
+	}
+	return recs
+}
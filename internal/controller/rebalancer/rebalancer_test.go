@@ -0,0 +1,203 @@
+package rebalancer
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+const gi = int64(1024 * 1024 * 1024)
+
+func testNode(name string, cpuMillis, memBytes int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    *resource.NewMilliQuantity(cpuMillis, resource.DecimalSI),
+				corev1.ResourceMemory: *resource.NewQuantity(memBytes, resource.BinarySI),
+			},
+		},
+	}
+}
+
+func testPod(name, nodeName string, cpuMillis, memBytes int64) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: name + "-rs"},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{{
+				Name: "main",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    *resource.NewMilliQuantity(cpuMillis, resource.DecimalSI),
+						corev1.ResourceMemory: *resource.NewQuantity(memBytes, resource.BinarySI),
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// nodeWithLoad returns a 4-core/16Gi node in ng-1 running a single pod.
+func nodeWithLoad(name string, cpuMillis int64) optimizer.NodeInfo {
+	node := testNode(name, 4000, 16*gi)
+	pod := testPod(name+"-pod", name, cpuMillis, gi)
+	return optimizer.NodeInfo{
+		Node:            node,
+		Pods:            []*corev1.Pod{pod},
+		CPUCapacity:     4000,
+		MemoryCapacity:  16 * gi,
+		CPURequested:    cpuMillis,
+		MemoryRequested: gi,
+		HourlyCostUSD:   0.2,
+		NodeGroup:       "ng-1",
+	}
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Rebalancer: config.RebalancerConfig{
+			Enabled:            true,
+			ImbalanceThreshold: 0.2,
+			WaveSize:           2,
+			MaxNodesPerRun:     10,
+		},
+	}
+}
+
+func fragmentedSnapshot(minCount int) *optimizer.ClusterSnapshot {
+	// Five nodes at 1000m each: 5000m of requests fits on two 4-core nodes,
+	// so three of five nodes (60%) are freed by repacking.
+	var nodes []optimizer.NodeInfo
+	for _, name := range []string{"n1", "n2", "n3", "n4", "n5"} {
+		nodes = append(nodes, nodeWithLoad(name, 1000))
+	}
+	return &optimizer.ClusterSnapshot{
+		Nodes: nodes,
+		NodeGroups: []*cloudprovider.NodeGroup{
+			{ID: "ng-1", Name: "general", CurrentCount: 5, DesiredCount: 5, MinCount: minCount, MaxCount: 10},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Planner Tests
+// ---------------------------------------------------------------------------
+
+func TestPlanner_Imbalance(t *testing.T) {
+	groups := NewPlanner(state.NewNodeLock(), testConfig()).Imbalance(fragmentedSnapshot(1))
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(groups))
+	}
+	g := groups[0]
+	if g.IdealNodes != 2 || g.CurrentNodes != 5 {
+		t.Errorf("ideal/current = %d/%d, want 2/5", g.IdealNodes, g.CurrentNodes)
+	}
+	if g.Imbalance < 0.59 || g.Imbalance > 0.61 {
+		t.Errorf("imbalance = %.2f, want 0.60", g.Imbalance)
+	}
+}
+
+func TestPlanner_Plan(t *testing.T) {
+	tests := []struct {
+		name      string
+		minCount  int
+		threshold float64
+		waveSize  int
+		wantNodes int
+		wantWaves int
+	}{
+		{name: "frees nodes down to ideal packing", minCount: 1, threshold: 0.2, waveSize: 2, wantNodes: 3, wantWaves: 2},
+		{name: "respects node group min", minCount: 4, threshold: 0.2, waveSize: 2, wantNodes: 1, wantWaves: 1},
+		{name: "below threshold plans nothing", minCount: 1, threshold: 0.7, waveSize: 2, wantNodes: 0, wantWaves: 0},
+		{name: "wave size of one", minCount: 1, threshold: 0.2, waveSize: 1, wantNodes: 3, wantWaves: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Rebalancer.ImbalanceThreshold = tt.threshold
+			cfg.Rebalancer.WaveSize = tt.waveSize
+
+			plan := NewPlanner(state.NewNodeLock(), cfg).Plan(fragmentedSnapshot(tt.minCount))
+			if plan.NodeCount() != tt.wantNodes {
+				t.Errorf("planned nodes = %d, want %d", plan.NodeCount(), tt.wantNodes)
+			}
+			if len(plan.Waves) != tt.wantWaves {
+				t.Errorf("waves = %d, want %d", len(plan.Waves), tt.wantWaves)
+			}
+			wantSavings := float64(tt.wantNodes) * 0.2 * cost.HoursPerMonth
+			if diff := plan.ProjectedMonthlySavingsUSD - wantSavings; diff > 0.01 || diff < -0.01 {
+				t.Errorf("projected savings = %.2f, want %.2f", plan.ProjectedMonthlySavingsUSD, wantSavings)
+			}
+		})
+	}
+}
+
+func TestPlan_Recommendations(t *testing.T) {
+	plan := NewPlanner(state.NewNodeLock(), testConfig()).Plan(fragmentedSnapshot(1))
+	recs := plan.Recommendations()
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 recommendations, got %d", len(recs))
+	}
+	for _, rec := range recs {
+		if rec.Type != optimizer.RecommendationRebalance {
+			t.Errorf("Type = %q, want %q", rec.Type, optimizer.RecommendationRebalance)
+		}
+		if rec.Details["nodeGroupID"] != "ng-1" || rec.Details["nodeName"] == "" {
+			t.Errorf("missing drain details: %v", rec.Details)
+		}
+	}
+	if recs[2].Details["wave"] != "2" {
+		t.Errorf("third node wave = %q, want 2", recs[2].Details["wave"])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Pending Pod Tests
+// ---------------------------------------------------------------------------
+
+func TestCountUnschedulable(t *testing.T) {
+	unschedulable := corev1.Pod{Status: corev1.PodStatus{
+		Phase: corev1.PodPending,
+		Conditions: []corev1.PodCondition{{
+			Type:   corev1.PodScheduled,
+			Status: corev1.ConditionFalse,
+			Reason: corev1.PodReasonUnschedulable,
+		}},
+	}}
+	scheduledPending := corev1.Pod{Status: corev1.PodStatus{
+		Phase: corev1.PodPending,
+		Conditions: []corev1.PodCondition{{
+			Type:   corev1.PodScheduled,
+			Status: corev1.ConditionTrue,
+		}},
+	}}
+	running := corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+
+	if got := countUnschedulable([]corev1.Pod{unschedulable, scheduledPending, running}); got != 1 {
+		t.Errorf("countUnschedulable = %d, want 1", got)
+	}
+}
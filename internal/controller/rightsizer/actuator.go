@@ -3,9 +3,11 @@ package rightsizer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
@@ -19,7 +21,8 @@ import (
 // Actuator applies resource patches to workloads. It supports two modes:
 // 1. In-place pod resize (K8s 1.27+ with InPlacePodVerticalScaling feature gate)
 //    — patches running pods directly without restart
-// 2. Deployment/StatefulSet patch — triggers a rolling restart
+// 2. Deployment/StatefulSet/DaemonSet patch — triggers a rolling restart;
+//    CronJob patches only take effect on the next scheduled run
 type Actuator struct {
 	client              client.Client
 	config              *config.Config
@@ -64,12 +67,6 @@ func (a *Actuator) hasResizePolicy(pod *corev1.Pod) bool {
 func (a *Actuator) Apply(ctx context.Context, rec optimizer.Recommendation) error {
 	logger := log.FromContext(ctx).WithName("rightsizer-actuator")
 
-	// Safety net: never patch DaemonSet workloads.
-	if rec.TargetKind == "DaemonSet" {
-		logger.Info("Skipping DaemonSet workload", "name", rec.TargetName, "namespace", rec.TargetNamespace)
-		return nil
-	}
-
 	resourceType := rec.Details["resource"]
 
 	// Dispatch combined CPU+memory patches
@@ -77,6 +74,17 @@ func (a *Actuator) Apply(ctx context.Context, rec optimizer.Recommendation) erro
 		return a.applyCombinedResources(ctx, rec)
 	}
 
+	// Rollback recommendations restore the recorded originals.
+	if resourceType == "rollback" {
+		return a.Rollback(ctx, rec.TargetNamespace, rec.TargetKind, rec.TargetName, rec.Details["reason"])
+	}
+
+	// CPU limit recommendations for throttled containers, and memory
+	// limit recommendations from peak usage.
+	if isLimitRec(rec) {
+		return a.applyLimit(ctx, rec)
+	}
+
 	suggestedStr := rec.Details["suggestedRequest"]
 
 	// Validate inputs before applying any changes
@@ -153,9 +161,21 @@ func (a *Actuator) applyCombinedResources(ctx context.Context, rec optimizer.Rec
 		return fmt.Errorf("suggested quantities must be positive: cpu=%s, memory=%s", cpuStr, memStr)
 	}
 
+	// Per-container recommendations carry their own targets; the pod totals
+	// above are only validated, not applied.
+	changes, err := containerChanges(rec)
+	if err != nil {
+		return err
+	}
+
 	// Try in-place pod resize first if supported
 	if a.inPlaceResizeSupported && rec.Details["podName"] != "" {
-		err := a.resizePodInPlaceCombined(ctx, rec.TargetNamespace, rec.Details["podName"], cpuStr, memStr)
+		var err error
+		if len(changes) > 0 {
+			err = a.resizePodInPlaceContainers(ctx, rec.TargetNamespace, rec.Details["podName"], changes)
+		} else {
+			err = a.resizePodInPlaceCombined(ctx, rec.TargetNamespace, rec.Details["podName"], cpuStr, memStr)
+		}
 		if err == nil {
 			logger.Info("Applied in-place combined pod resize",
 				"pod", rec.Details["podName"],
@@ -182,10 +202,8 @@ func (a *Actuator) applyCombinedResources(ctx context.Context, rec optimizer.Rec
 	}
 
 	switch targetKind {
-	case "Deployment":
-		return a.patchDeploymentCombined(ctx, rec.TargetNamespace, targetName, cpuStr, memStr)
-	case "StatefulSet":
-		return a.patchStatefulSetCombined(ctx, rec.TargetNamespace, targetName, cpuStr, memStr)
+	case "Deployment", "StatefulSet", "DaemonSet", "CronJob":
+		return a.patchWorkloadCombined(ctx, rec.TargetNamespace, targetKind, targetName, cpuStr, memStr, changes)
 	default:
 		logger.V(1).Info("Unsupported target kind for combined patching", "kind", targetKind)
 		return nil
@@ -368,6 +386,10 @@ func (a *Actuator) resizePodInPlaceCombined(ctx context.Context, namespace, podN
 		},
 	}
 
+	if err := a.checkLimitRanges(ctx, namespace, pod.Spec.Containers, containerPatches); err != nil {
+		return err
+	}
+
 	patchData := map[string]interface{}{
 		"spec": map[string]interface{}{
 			"containers": containerPatches,
@@ -382,48 +404,108 @@ func (a *Actuator) resizePodInPlaceCombined(ctx context.Context, namespace, podN
 	return a.client.Patch(ctx, pod, client.RawPatch(types.StrategicMergePatchType, patch))
 }
 
-func (a *Actuator) patchDeploymentCombined(ctx context.Context, namespace, name, cpuValue, memValue string) error {
-	deploy := &appsv1.Deployment{}
-	if err := a.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, deploy); err != nil {
-		return fmt.Errorf("getting deployment %s/%s: %w", namespace, name, err)
+// patchWorkloadCombined applies a combined CPU+memory change to the pod
+// template of a Deployment, StatefulSet, DaemonSet or CronJob. CronJob
+// changes only reach the next scheduled run; the others roll out, unless
+// the nextRollout strategy leaves them to the pod webhook.
+func (a *Actuator) patchWorkloadCombined(ctx context.Context, namespace, kind, name, cpuValue, memValue string, changes []optimizer.ContainerChange) error {
+	obj, tmpl, err := a.getWorkload(ctx, namespace, kind, name)
+	if err != nil {
+		return err
 	}
 
-	recordOriginalResources(deploy.Annotations, deploy.Spec.Template.Spec.Containers, "cpu+memory")
-
-	patchData := buildCombinedResourcePatch(deploy.Spec.Template.Spec.Containers, cpuValue, memValue)
-	if patchData == nil {
-		return nil
+	patchData, err := combinedTemplatePatch(obj.GetAnnotations(), tmpl.Spec.Containers, cpuValue, memValue, changes)
+	if patchData == nil || err != nil {
+		return err
+	}
+	if err := a.checkLimitRanges(ctx, namespace, tmpl.Spec.Containers, templateContainerPatches(patchData)); err != nil {
+		return fmt.Errorf("patching %s %s/%s: %w", kind, namespace, name, err)
+	}
+	if kind == "CronJob" {
+		wrapJobTemplate(patchData)
+	} else if a.config.Rightsizer.Rollout.Strategy == RolloutNextRollout {
+		patchData, err = pendingResourcesPatch(patchData, obj.GetAnnotations(), tmpl.Spec.Containers)
+		if patchData == nil || err != nil {
+			return err
+		}
 	}
-	addOriginalAnnotations(patchData, deploy.Annotations)
 
 	patch, err := json.Marshal(patchData)
 	if err != nil {
 		return err
 	}
 
-	return a.client.Patch(ctx, deploy, client.RawPatch(types.StrategicMergePatchType, patch))
+	return a.client.Patch(ctx, obj, client.RawPatch(types.StrategicMergePatchType, patch))
 }
 
-func (a *Actuator) patchStatefulSetCombined(ctx context.Context, namespace, name, cpuValue, memValue string) error {
-	sts := &appsv1.StatefulSet{}
-	if err := a.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, sts); err != nil {
-		return fmt.Errorf("getting statefulset %s/%s: %w", namespace, name, err)
+// getWorkload fetches a workload and returns its pod template.
+func (a *Actuator) getWorkload(ctx context.Context, namespace, kind, name string) (client.Object, *corev1.PodTemplateSpec, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	switch kind {
+	case "Deployment":
+		deploy := &appsv1.Deployment{}
+		if err := a.client.Get(ctx, key, deploy); err != nil {
+			return nil, nil, fmt.Errorf("getting deployment %s/%s: %w", namespace, name, err)
+		}
+		return deploy, &deploy.Spec.Template, nil
+	case "StatefulSet":
+		sts := &appsv1.StatefulSet{}
+		if err := a.client.Get(ctx, key, sts); err != nil {
+			return nil, nil, fmt.Errorf("getting statefulset %s/%s: %w", namespace, name, err)
+		}
+		return sts, &sts.Spec.Template, nil
+	case "DaemonSet":
+		ds := &appsv1.DaemonSet{}
+		if err := a.client.Get(ctx, key, ds); err != nil {
+			return nil, nil, fmt.Errorf("getting daemonset %s/%s: %w", namespace, name, err)
+		}
+		return ds, &ds.Spec.Template, nil
+	case "CronJob":
+		cj := &batchv1.CronJob{}
+		if err := a.client.Get(ctx, key, cj); err != nil {
+			return nil, nil, fmt.Errorf("getting cronjob %s/%s: %w", namespace, name, err)
+		}
+		return cj, &cj.Spec.JobTemplate.Spec.Template, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported workload kind %q", kind)
 	}
+}
 
-	recordOriginalResources(sts.Annotations, sts.Spec.Template.Spec.Containers, "cpu+memory")
-
-	patchData := buildCombinedResourcePatch(sts.Spec.Template.Spec.Containers, cpuValue, memValue)
-	if patchData == nil {
-		return nil
+// wrapJobTemplate turns a pod template patch into a CronJob patch. A pod
+// template patch is {"spec":{"template":...}}, which is exactly the shape
+// of a job template.
+func wrapJobTemplate(patchData map[string]interface{}) {
+	patchData["spec"] = map[string]interface{}{
+		"jobTemplate": map[string]interface{}{"spec": patchData["spec"]},
 	}
-	addOriginalAnnotations(patchData, sts.Annotations)
+}
 
-	patch, err := json.Marshal(patchData)
-	if err != nil {
-		return err
+// combinedTemplatePatch records the original requests and builds the
+// strategic merge patch for a pod template, per container when changes is
+// set and pod-wide otherwise. Returns nil when there is nothing to patch,
+// and ErrRightsizingBlocked for workloads the watchdog rolled back.
+func combinedTemplatePatch(annotations map[string]string, containers []corev1.Container, cpuValue, memValue string, changes []optimizer.ContainerChange) (map[string]interface{}, error) {
+	if reason, ok := annotations[annRightsizingBlocked]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrRightsizingBlocked, reason)
+	}
+	if annotations == nil {
+		// Recorded values only need to reach the patch, not the object.
+		annotations = map[string]string{}
 	}
+	recordOriginalResources(annotations, containers, "cpu+memory")
 
-	return a.client.Patch(ctx, sts, client.RawPatch(types.StrategicMergePatchType, patch))
+	var patchData map[string]interface{}
+	if len(changes) > 0 {
+		recordOriginalContainerResources(annotations, containers, changes)
+		patchData = buildContainerResourcePatch(containers, changes)
+	} else {
+		patchData = buildCombinedResourcePatch(containers, cpuValue, memValue)
+	}
+	if patchData == nil {
+		return nil, nil
+	}
+	addOriginalAnnotations(patchData, annotations)
+	return patchData, nil
 }
 
 func buildCombinedResourcePatch(containers []corev1.Container, cpuValue, memValue string) map[string]interface{} {
@@ -480,11 +562,292 @@ func buildCombinedResourcePatch(containers []corev1.Container, cpuValue, memValu
 	}
 }
 
+// buildContainerResourcePatch patches each container marked for resize with
+// its own CPU and memory request. Containers missing from the spec, or whose
+// suggestion would now exceed the current request, are left untouched.
+func buildContainerResourcePatch(containers []corev1.Container, changes []optimizer.ContainerChange) map[string]interface{} {
+	containerPatches := containerRequestPatches(containers, changes)
+	if len(containerPatches) == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": containerPatches,
+				},
+			},
+		},
+	}
+}
+
+// resizePodInPlaceContainers applies per-container requests to a running pod.
+func (a *Actuator) resizePodInPlaceContainers(ctx context.Context, namespace, podName string, changes []optimizer.ContainerChange) error {
+	pod := &corev1.Pod{}
+	if err := a.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: podName}, pod); err != nil {
+		return fmt.Errorf("getting pod %s/%s: %w", namespace, podName, err)
+	}
+
+	containerPatches := containerRequestPatches(pod.Spec.Containers, changes)
+	if len(containerPatches) == 0 {
+		return fmt.Errorf("no containers in pod %s/%s match the recommendation", namespace, podName)
+	}
+	if err := a.checkLimitRanges(ctx, namespace, pod.Spec.Containers, containerPatches); err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": containerPatches,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.client.Patch(ctx, pod, client.RawPatch(types.StrategicMergePatchType, patch))
+}
+
+// containerRequestPatches returns one strategic-merge entry per resized
+// container, keyed by container name.
+func containerRequestPatches(containers []corev1.Container, changes []optimizer.ContainerChange) []map[string]interface{} {
+	byName := make(map[string]corev1.Container, len(containers))
+	for _, c := range containers {
+		byName[c.Name] = c
+	}
+
+	var patches []map[string]interface{}
+	for _, ch := range changes {
+		if ch.Action != optimizer.ContainerActionResize {
+			continue
+		}
+		c, ok := byName[ch.Name]
+		if !ok {
+			continue
+		}
+		cpuQty, err := resource.ParseQuantity(ch.SuggestedCPURequest)
+		if err != nil || cpuQty.Sign() <= 0 {
+			continue
+		}
+		memQty, err := resource.ParseQuantity(ch.SuggestedMemRequest)
+		if err != nil || memQty.Sign() <= 0 {
+			continue
+		}
+		// The spec may have changed since the recommendation was made;
+		// never raise a request as part of a downsize.
+		if cpuQty.Cmp(*c.Resources.Requests.Cpu()) > 0 || memQty.Cmp(*c.Resources.Requests.Memory()) > 0 {
+			continue
+		}
+		resources := map[string]interface{}{
+			"requests": map[string]string{
+				string(corev1.ResourceCPU):    cpuQty.String(),
+				string(corev1.ResourceMemory): memQty.String(),
+			},
+		}
+		if limit, ok := changedCPULimit(ch, cpuQty); ok {
+			resources["limits"] = map[string]interface{}{string(corev1.ResourceCPU): limit}
+		}
+		patches = append(patches, map[string]interface{}{
+			"name":      ch.Name,
+			"resources": resources,
+		})
+	}
+	return patches
+}
+
+// changedCPULimit returns the CPU limit patch value of a resized container:
+// nil to remove the limit, or the suggested limit when it is valid and not
+// below the new request. ok is false when the limit stays.
+func changedCPULimit(ch optimizer.ContainerChange, request resource.Quantity) (interface{}, bool) {
+	if ch.RemoveCPULimit {
+		return nil, true
+	}
+	if ch.SuggestedCPULimit == "" {
+		return nil, false
+	}
+	qty, err := resource.ParseQuantity(ch.SuggestedCPULimit)
+	if err != nil || qty.Cmp(request) < 0 {
+		return nil, false
+	}
+	return qty.String(), true
+}
+
 const (
 	annOriginalCPU = "koptimizer.io/original-cpu-request"
 	annOriginalMem = "koptimizer.io/original-mem-request"
+
+	// annOriginalContainers holds a JSON object of container name →
+	// {"cpu": ..., "memory": ...} recorded before per-container patches.
+	annOriginalContainers = "koptimizer.io/original-container-requests"
+
+	// annRightsizingBlocked is set by a rollback and holds its reason. The
+	// actuator refuses further downsizes until it is removed.
+	annRightsizingBlocked = "koptimizer.io/rightsizing-blocked"
 )
 
+// ErrRightsizingBlocked is returned for workloads that were rolled back and
+// carry the rightsizing-blocked annotation.
+var ErrRightsizingBlocked = errors.New("rightsizing blocked after a rollback")
+
+// Rollback restores the requests recorded in a workload's original-request
+// annotations, drops those annotations and blocks the workload from further
+// downsizing with reason.
+func (a *Actuator) Rollback(ctx context.Context, namespace, kind, name, reason string) error {
+	if kind == "ReplicaSet" {
+		deployName, err := a.resolveReplicaSetOwner(ctx, namespace, name)
+		if err != nil {
+			return fmt.Errorf("resolving ReplicaSet %s/%s owner: %w", namespace, name, err)
+		}
+		kind, name = "Deployment", deployName
+	}
+	obj, tmpl, err := a.getWorkload(ctx, namespace, kind, name)
+	if err != nil {
+		return err
+	}
+
+	patchData, err := buildRollbackPatch(obj.GetAnnotations(), tmpl.Spec.Containers, reason)
+	if err != nil {
+		return fmt.Errorf("rolling back %s %s/%s: %w", kind, namespace, name, err)
+	}
+	if kind == "CronJob" {
+		wrapJobTemplate(patchData)
+	}
+
+	patch, err := json.Marshal(patchData)
+	if err != nil {
+		return err
+	}
+
+	return a.client.Patch(ctx, obj, client.RawPatch(types.StrategicMergePatchType, patch))
+}
+
+// buildRollbackPatch builds a pod template patch that puts every container
+// with a recorded original back to it. Per-container originals take
+// precedence over the pod-wide ones, which describe the first container.
+func buildRollbackPatch(annotations map[string]string, containers []corev1.Container, reason string) (map[string]interface{}, error) {
+	originals := map[string]map[string]string{}
+	if len(containers) > 0 && (annotations[annOriginalCPU] != "" || annotations[annOriginalMem] != "") {
+		originals[containers[0].Name] = map[string]string{
+			"cpu":    annotations[annOriginalCPU],
+			"memory": annotations[annOriginalMem],
+		}
+	}
+	if v := annotations[annOriginalContainers]; v != "" {
+		perContainer := map[string]map[string]string{}
+		if err := json.Unmarshal([]byte(v), &perContainer); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", annOriginalContainers, err)
+		}
+		for name, req := range perContainer {
+			originals[name] = req
+		}
+	}
+
+	var containerPatches []map[string]interface{}
+	for _, c := range containers {
+		orig, ok := originals[c.Name]
+		if !ok {
+			continue
+		}
+		requests := map[string]string{}
+		var cpuLimit string
+		for res, value := range orig {
+			if value == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(value); err != nil {
+				return nil, fmt.Errorf("invalid original %s request %q for container %s: %w", res, value, c.Name, err)
+			}
+			if res == originalCPULimitKey {
+				cpuLimit = value
+				continue
+			}
+			requests[res] = value
+		}
+		if len(requests) == 0 {
+			continue
+		}
+		resources := map[string]interface{}{"requests": requests}
+		if cpuLimit != "" {
+			resources["limits"] = map[string]string{string(corev1.ResourceCPU): cpuLimit}
+		}
+		containerPatches = append(containerPatches, map[string]interface{}{
+			"name":      c.Name,
+			"resources": resources,
+		})
+	}
+	if len(containerPatches) == 0 {
+		return nil, fmt.Errorf("no original requests recorded")
+	}
+
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				annOriginalCPU:        nil,
+				annOriginalMem:        nil,
+				annOriginalContainers: nil,
+				AnnPendingResources:   nil,
+				annRightsizingBlocked: reason,
+			},
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": containerPatches,
+				},
+			},
+		},
+	}, nil
+}
+
+// recordOriginalContainerResources records the pre-rightsizer requests of
+// every container about to be resized. Containers already recorded keep
+// their first value.
+func recordOriginalContainerResources(annotations map[string]string, containers []corev1.Container, changes []optimizer.ContainerChange) {
+	if annotations == nil {
+		return // annotations map is nil — will be set via patch
+	}
+	originals := map[string]map[string]string{}
+	if v, ok := annotations[annOriginalContainers]; ok {
+		if err := json.Unmarshal([]byte(v), &originals); err != nil {
+			originals = map[string]map[string]string{}
+		}
+	}
+	resized := map[string]optimizer.ContainerChange{}
+	for _, ch := range changes {
+		if ch.Action == optimizer.ContainerActionResize {
+			resized[ch.Name] = ch
+		}
+	}
+	for _, c := range containers {
+		ch, ok := resized[c.Name]
+		if !ok {
+			continue
+		}
+		orig, exists := originals[c.Name]
+		if !exists {
+			orig = map[string]string{
+				"cpu":    c.Resources.Requests.Cpu().String(),
+				"memory": c.Resources.Requests.Memory().String(),
+			}
+			originals[c.Name] = orig
+		}
+		// A CPU limit changed with the requests is restored with them.
+		if limit, ok := c.Resources.Limits[corev1.ResourceCPU]; ok && (ch.RemoveCPULimit || ch.SuggestedCPULimit != "") {
+			if _, recorded := orig[originalCPULimitKey]; !recorded {
+				orig[originalCPULimitKey] = limit.String()
+			}
+		}
+	}
+	if len(originals) == 0 {
+		return
+	}
+	data, err := json.Marshal(originals)
+	if err != nil {
+		return
+	}
+	annotations[annOriginalContainers] = string(data)
+}
+
 // recordOriginalResources stores the current resource requests as annotations
 // so the UI can show what the original (helm) values were before rightsizer
 // modified them. Only sets the annotation if it doesn't already exist, so
@@ -526,6 +889,9 @@ func addOriginalAnnotations(patchData map[string]interface{}, annotations map[st
 	if v, ok := annotations[annOriginalMem]; ok {
 		annPatch[annOriginalMem] = v
 	}
+	if v, ok := annotations[annOriginalContainers]; ok {
+		annPatch[annOriginalContainers] = v
+	}
 	if len(annPatch) == 0 {
 		return
 	}
@@ -2,10 +2,17 @@ package rightsizer
 
 import (
 	"context"
+	"log/slog"
+	"strings"
 	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	"github.com/koptimizer/koptimizer/internal/config"
 	"github.com/koptimizer/koptimizer/internal/metrics"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
 	"github.com/koptimizer/koptimizer/pkg/optimizer"
 )
 
@@ -26,8 +33,8 @@ type PodAnalysis struct {
 	IsOverProvCPU   bool
 	IsOverProvMem   bool
 	IsBothOverProv  bool    // true when both CPU and memory are over-provisioned
-	CPUUtilRatio    float64 // P95 / request (range 0-1+)
-	MemUtilRatio    float64 // P95 / request (range 0-1+)
+	CPUUtilRatio    float64 // usage at the policy percentile / request (range 0-1+)
+	MemUtilRatio    float64 // usage at the policy percentile / request (range 0-1+)
 	IsUnderProvCPU  bool
 	IsUnderProvMem  bool
 
@@ -38,20 +45,284 @@ type PodAnalysis struct {
 	// Node capacity for ratio-based rightsizing (0 if unknown)
 	NodeCPUCapMilli int64
 	NodeMemCapBytes int64
+
+	// Containers holds the per-container breakdown when the metrics store
+	// has windowed data. Empty means only the pod-wide view is available.
+	Containers []ContainerAnalysis
+
+	// CFS throttling of the most throttled container (see ContainerAnalysis)
+	ThrottledP95 float64
+	IsThrottled  bool
+
+	// Policy is the workload's effective rightsizing policy. Nil means
+	// the global config applies unchanged.
+	Policy *Policy
+
+	// LimitRange holds the Container constraints of the namespace's
+	// LimitRanges. Nil means the namespace has none.
+	LimitRange *LimitRangeConstraints
+}
+
+// usage returns the CPU and memory usage the pod is sized from: the
+// percentile its policy picks, P95 by default.
+func (a *PodAnalysis) usage(pct int) (int64, int64) {
+	return usageAt(pct, a.CPUP50, a.CPUP95, a.CPUP99, a.CPUMax),
+		usageAt(pct, a.MemP50, a.MemP95, a.MemP99, a.MemMax)
+}
+
+// ContainerAnalysis contains the resource analysis for a single container.
+type ContainerAnalysis struct {
+	Name            string
+	Sidecar         bool
+	HasData         bool // false when no window was found for this container
+	CPURequestMilli int64
+	MemRequestBytes int64
+	CPULimitMilli   int64
+	MemLimitBytes   int64
+	CPUP50          int64
+	CPUP95          int64
+	CPUP99          int64
+	CPUMax          int64
+	MemP50          int64
+	MemP95          int64
+	MemP99          int64
+	MemMax          int64
+	DataPoints      int
+	IsOverProvCPU   bool
+	IsOverProvMem   bool
+
+	// ThrottledP95 is the P95 share (0-1) of CFS periods in which the
+	// container was throttled, from ThrottleSamples scrape intervals. P95
+	// usage of a throttled container is capped by its limit and understates
+	// demand.
+	ThrottledP95    float64
+	ThrottleSamples int
+	IsThrottled     bool
+
+	// LastOOMKill is the most recent OOM kill of this container in any
+	// replica of the workload, zero if none was seen.
+	LastOOMKill time.Time
+}
+
+// usage returns the container's CPU and memory usage at percentile pct.
+func (c *ContainerAnalysis) usage(pct int) (int64, int64) {
+	return usageAt(pct, c.CPUP50, c.CPUP95, c.CPUP99, c.CPUMax),
+		usageAt(pct, c.MemP50, c.MemP95, c.MemP99, c.MemMax)
 }
 
 // Analyzer performs usage pattern analysis on pod metrics.
 type Analyzer struct {
-	config *config.Config
-	store  *metrics.Store
+	config   *config.Config
+	store    *metrics.Store
+	history  metrics.WindowQuerier
+	policies *PolicyResolver
 }
 
 func NewAnalyzer(cfg *config.Config, store *metrics.Store) *Analyzer {
-	return &Analyzer{config: cfg, store: store}
+	return &Analyzer{config: cfg, store: store, policies: NewPolicyResolver(nil, cfg)}
+}
+
+// SetPolicies installs the resolver that supplies each workload's
+// rightsizing policy. By default only annotations override the config.
+func (a *Analyzer) SetPolicies(r *PolicyResolver) {
+	a.policies = r
+}
+
+// SetHistory installs an external history source that is queried for
+// containers the metrics store has no samples for.
+func (a *Analyzer) SetHistory(h metrics.WindowQuerier) {
+	a.history = h
+}
+
+// containerWindow returns the store's window for a container, falling back
+// to the history source while the store has fewer than MinPodDataPoints
+// samples, e.g. right after a restart. The store's window is kept if the
+// history source has nothing better.
+func (a *Analyzer) containerWindow(ctx context.Context, namespace, pod, container string, lookback time.Duration) *pkgmetrics.MetricsWindow {
+	var local *pkgmetrics.MetricsWindow
+	if a.store != nil {
+		local = a.store.GetPodContainerWindow(namespace, pod, container, lookback)
+		if local != nil && local.DataPoints >= MinPodDataPoints {
+			return local
+		}
+	}
+	if a.history == nil {
+		return local
+	}
+	w, err := a.history.ContainerWindow(ctx, namespace, pod, container, lookback)
+	if err != nil {
+		slog.Debug("rightsizer: history window unavailable", "pod", namespace+"/"+pod, "container", container, "error", err)
+		return local
+	}
+	if w == nil || (local != nil && w.DataPoints <= local.DataPoints) {
+		return local
+	}
+	return w
 }
 
 // AnalyzePod analyzes resource usage patterns for a single pod.
 func (a *Analyzer) AnalyzePod(ctx context.Context, pod optimizer.PodInfo) *PodAnalysis {
+	var window func(container string) *pkgmetrics.MetricsWindow
+	if (a.store != nil || a.history != nil) && pod.Pod != nil {
+		lookback := a.lookback()
+		window = func(container string) *pkgmetrics.MetricsWindow {
+			return a.containerWindow(ctx, pod.Pod.Namespace, pod.Pod.Name, container, lookback)
+		}
+	}
+	analysis := a.analyze(pod, window)
+	if analysis != nil && a.store != nil && a.config.Rightsizer.Throttling.Enabled {
+		a.attachThrottling(analysis)
+	}
+	return analysis
+}
+
+// attachThrottling adds each container's CFS throttling over the lookback
+// window and reclassifies the analysis.
+func (a *Analyzer) attachThrottling(analysis *PodAnalysis) {
+	pod := analysis.PodInfo.Pod
+	lookback := a.lookback()
+	for i := range analysis.Containers {
+		c := &analysis.Containers[i]
+		if w := a.store.GetThrottlingWindow(pod.Namespace, pod.Name, c.Name, lookback); w != nil {
+			c.ThrottledP95 = w.P95Ratio
+			c.ThrottleSamples = w.DataPoints
+		}
+		a.classifyContainer(c, a.policy(analysis))
+	}
+	a.classify(analysis)
+}
+
+// AnalyzeCronJob analyzes a CronJob from the runs it has left in the metrics
+// store. Job pods are too short-lived to build a window of their own, so
+// each container is sized from the peak of every past run (see
+// Store.GetPeakContainerWindow). Returns nil until MinBatchRuns runs have
+// been observed.
+func (a *Analyzer) AnalyzeCronJob(cj *batchv1.CronJob) *PodAnalysis {
+	if a.store == nil {
+		return nil
+	}
+	tmpl := cj.Spec.JobTemplate.Spec.Template
+	pod := optimizer.PodInfo{
+		Pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cj.Namespace, Name: cj.Name, Labels: tmpl.Labels, Annotations: tmpl.Annotations},
+			Spec:       tmpl.Spec,
+		},
+		OwnerKind: "CronJob",
+		OwnerName: cj.Name,
+	}
+	for _, c := range tmpl.Spec.Containers {
+		pod.CPURequest += c.Resources.Requests.Cpu().MilliValue()
+		pod.MemoryRequest += c.Resources.Requests.Memory().Value()
+		pod.CPULimit += c.Resources.Limits.Cpu().MilliValue()
+		pod.MemoryLimit += c.Resources.Limits.Memory().Value()
+	}
+
+	lookback := a.lookback()
+	match := cronJobPodMatcher(cj.Name)
+	analysis := a.analyze(pod, func(container string) *pkgmetrics.MetricsWindow {
+		w := a.store.GetPeakContainerWindow(cj.Namespace, container, match, lookback)
+		if w == nil || w.Runs < MinBatchRuns {
+			return nil
+		}
+		return w
+	})
+	if analysis == nil || analysis.DataPoints == 0 {
+		return nil
+	}
+	return analysis
+}
+
+// cronJobPodMatcher matches pods created by the CronJob name. The Job
+// controller names jobs "<cronjob>-<scheduled unix minutes>" and their pods
+// "<job>-<random suffix>", so the digits keep "backup" from matching pods of
+// a "backup-db" CronJob.
+func cronJobPodMatcher(name string) func(pod string) bool {
+	return func(pod string) bool {
+		rest, ok := strings.CutPrefix(pod, name+"-")
+		if !ok {
+			return false
+		}
+		schedule, suffix, ok := strings.Cut(rest, "-")
+		if !ok || schedule == "" || suffix == "" {
+			return false
+		}
+		for _, r := range schedule {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MergePeak combines the analyses of every pod of one workload into a single
+// analysis sized for the busiest pod: each percentile is the maximum across
+// pods and DataPoints the minimum. DaemonSet pods see very different load
+// per node, so sizing from any single pod could starve the others.
+func (a *Analyzer) MergePeak(analyses []*PodAnalysis) *PodAnalysis {
+	if len(analyses) == 0 {
+		return nil
+	}
+	merged := *analyses[0]
+	merged.Containers = append([]ContainerAnalysis(nil), analyses[0].Containers...)
+	for _, o := range analyses[1:] {
+		if o.CPUP95 > merged.CPUP95 {
+			// Keep the node capacity of the busiest pod for ratio alignment.
+			merged.NodeCPUCapMilli, merged.NodeMemCapBytes = o.NodeCPUCapMilli, o.NodeMemCapBytes
+		}
+		merged.CPUP50 = max(merged.CPUP50, o.CPUP50)
+		merged.CPUP95 = max(merged.CPUP95, o.CPUP95)
+		merged.CPUP99 = max(merged.CPUP99, o.CPUP99)
+		merged.CPUMax = max(merged.CPUMax, o.CPUMax)
+		merged.MemP50 = max(merged.MemP50, o.MemP50)
+		merged.MemP95 = max(merged.MemP95, o.MemP95)
+		merged.MemP99 = max(merged.MemP99, o.MemP99)
+		merged.MemMax = max(merged.MemMax, o.MemMax)
+		merged.DataPoints = min(merged.DataPoints, o.DataPoints)
+		for i := range merged.Containers {
+			mc := &merged.Containers[i]
+			for _, oc := range o.Containers {
+				if oc.Name != mc.Name {
+					continue
+				}
+				// A container is only as well observed as its least
+				// observed replica.
+				mc.HasData = mc.HasData && oc.HasData
+				mc.DataPoints = min(mc.DataPoints, oc.DataPoints)
+				mc.CPUP50 = max(mc.CPUP50, oc.CPUP50)
+				mc.CPUP95 = max(mc.CPUP95, oc.CPUP95)
+				mc.CPUP99 = max(mc.CPUP99, oc.CPUP99)
+				mc.CPUMax = max(mc.CPUMax, oc.CPUMax)
+				mc.MemP50 = max(mc.MemP50, oc.MemP50)
+				mc.MemP95 = max(mc.MemP95, oc.MemP95)
+				mc.MemP99 = max(mc.MemP99, oc.MemP99)
+				mc.MemMax = max(mc.MemMax, oc.MemMax)
+				if oc.ThrottledP95 > mc.ThrottledP95 {
+					mc.ThrottledP95, mc.ThrottleSamples = oc.ThrottledP95, oc.ThrottleSamples
+				}
+				if oc.LastOOMKill.After(mc.LastOOMKill) {
+					mc.LastOOMKill = oc.LastOOMKill
+				}
+			}
+		}
+	}
+	for i := range merged.Containers {
+		a.classifyContainer(&merged.Containers[i], a.policy(&merged))
+	}
+	a.classify(&merged)
+	return &merged
+}
+
+func (a *Analyzer) lookback() time.Duration {
+	if a.config.Rightsizer.LookbackWindow == 0 {
+		return 7 * 24 * time.Hour
+	}
+	return a.config.Rightsizer.LookbackWindow
+}
+
+// analyze builds a PodAnalysis from per-container windows. window may be nil
+// (no metrics source) and may return nil for containers without samples.
+func (a *Analyzer) analyze(pod optimizer.PodInfo, window func(container string) *pkgmetrics.MetricsWindow) *PodAnalysis {
 	// Both CPU and memory requests must be set for meaningful analysis.
 	// CPURequest=0 would produce cpuUtil=0 → false IsOverProvCPU=true.
 	if pod.CPURequest == 0 || pod.MemoryRequest == 0 {
@@ -64,32 +335,31 @@ func (a *Analyzer) AnalyzePod(ctx context.Context, pod optimizer.PodInfo) *PodAn
 		MemRequestBytes: pod.MemoryRequest,
 		CPULimitMilli:   pod.CPULimit,
 		MemLimitBytes:   pod.MemoryLimit,
+		Policy:          a.policies.Resolve(pod.Pod),
 	}
 
 	// Try to get real percentile data from the metrics store for each container.
 	// Aggregate across all containers since PodAnalysis is pod-level.
 	gotWindowData := false
-	if a.store != nil && pod.Pod != nil {
-		lookback := a.config.Rightsizer.LookbackWindow
-		if lookback == 0 {
-			lookback = 7 * 24 * time.Hour
-		}
+	if window != nil {
+		sidecars := sidecarNames(pod.Pod, a.config.Rightsizer.SidecarContainers)
 		for _, container := range pod.Pod.Spec.Containers {
-			window := a.store.GetPodContainerWindow(pod.Pod.Namespace, pod.Pod.Name, container.Name, lookback)
-			if window != nil {
+			w := window(container.Name)
+			analysis.Containers = append(analysis.Containers, a.analyzeContainer(container, sidecars[container.Name], w, analysis.Policy))
+			if w != nil {
 				gotWindowData = true
-				analysis.CPUP50 += window.P50CPU
-				analysis.CPUP95 += window.P95CPU
-				analysis.CPUP99 += window.P99CPU
-				analysis.CPUMax += window.MaxCPU
-				analysis.MemP50 += window.P50Memory
-				analysis.MemP95 += window.P95Memory
-				analysis.MemP99 += window.P99Memory
-				analysis.MemMax += window.MaxMemory
+				analysis.CPUP50 += w.P50CPU
+				analysis.CPUP95 += w.P95CPU
+				analysis.CPUP99 += w.P99CPU
+				analysis.CPUMax += w.MaxCPU
+				analysis.MemP50 += w.P50Memory
+				analysis.MemP95 += w.P95Memory
+				analysis.MemP99 += w.P99Memory
+				analysis.MemMax += w.MaxMemory
 				// Use the min DataPoints across containers — the analysis
 				// is only as reliable as the least-observed container.
-				if analysis.DataPoints == 0 || window.DataPoints < analysis.DataPoints {
-					analysis.DataPoints = window.DataPoints
+				if analysis.DataPoints == 0 || w.DataPoints < analysis.DataPoints {
+					analysis.DataPoints = w.DataPoints
 				}
 			}
 		}
@@ -97,6 +367,7 @@ func (a *Analyzer) AnalyzePod(ctx context.Context, pod optimizer.PodInfo) *PodAn
 
 	// Fall back to point-in-time values if the store had no data
 	if !gotWindowData {
+		analysis.Containers = nil
 		analysis.CPUP50 = pod.CPUUsage
 		analysis.CPUP95 = pod.CPUUsage
 		analysis.CPUP99 = pod.CPUUsage
@@ -107,23 +378,88 @@ func (a *Analyzer) AnalyzePod(ctx context.Context, pod optimizer.PodInfo) *PodAn
 		analysis.MemMax = pod.MemoryUsage
 	}
 
-	// Determine if over/under provisioned using P95 values
+	a.classify(analysis)
+	return analysis
+}
+
+// policy returns the analysis' policy, or the global one when it has none.
+func (a *Analyzer) policy(analysis *PodAnalysis) *Policy {
+	if analysis.Policy != nil {
+		return analysis.Policy
+	}
+	return defaultPolicy(a.config)
+}
+
+// classify sets the over/under-provisioning flags from usage at the policy
+// percentile (P95 by default) against the policy's target utilization.
+func (a *Analyzer) classify(analysis *PodAnalysis) {
+	p := a.policy(analysis)
+	cpuUse, memUse := analysis.usage(p.Percentile)
 	cpuUtil := float64(0)
-	if pod.CPURequest > 0 {
-		cpuUtil = float64(analysis.CPUP95) / float64(pod.CPURequest) * 100
+	if analysis.CPURequestMilli > 0 {
+		cpuUtil = float64(cpuUse) / float64(analysis.CPURequestMilli) * 100
 	}
 	memUtil := float64(0)
-	if pod.MemoryRequest > 0 {
-		memUtil = float64(analysis.MemP95) / float64(pod.MemoryRequest) * 100
+	if analysis.MemRequestBytes > 0 {
+		memUtil = float64(memUse) / float64(analysis.MemRequestBytes) * 100
 	}
 
-	analysis.IsOverProvCPU = cpuUtil < a.config.Rightsizer.CPUTargetUtilPct*0.5
-	analysis.IsOverProvMem = memUtil < a.config.Rightsizer.MemoryTargetUtilPct*0.5
+	analysis.IsOverProvCPU = cpuUtil < p.CPUTargetUtilPct*0.5
+	analysis.IsOverProvMem = memUtil < p.MemoryTargetUtilPct*0.5
 	analysis.IsBothOverProv = analysis.IsOverProvCPU && analysis.IsOverProvMem
 	analysis.CPUUtilRatio = cpuUtil / 100.0 // convert percentage to ratio
 	analysis.MemUtilRatio = memUtil / 100.0
 	analysis.IsUnderProvCPU = cpuUtil > 95
 	analysis.IsUnderProvMem = memUtil > 95
 
-	return analysis
+	analysis.ThrottledP95, analysis.IsThrottled = 0, false
+	for _, c := range analysis.Containers {
+		analysis.ThrottledP95 = max(analysis.ThrottledP95, c.ThrottledP95)
+		analysis.IsThrottled = analysis.IsThrottled || c.IsThrottled
+	}
+}
+
+// analyzeContainer builds the per-container view from window, which may be
+// nil when the container has no samples yet.
+func (a *Analyzer) analyzeContainer(c corev1.Container, sidecar bool, window *pkgmetrics.MetricsWindow, p *Policy) ContainerAnalysis {
+	ca := ContainerAnalysis{
+		Name:            c.Name,
+		Sidecar:         sidecar,
+		CPURequestMilli: c.Resources.Requests.Cpu().MilliValue(),
+		MemRequestBytes: c.Resources.Requests.Memory().Value(),
+		CPULimitMilli:   c.Resources.Limits.Cpu().MilliValue(),
+		MemLimitBytes:   c.Resources.Limits.Memory().Value(),
+	}
+	if window == nil {
+		return ca
+	}
+	ca.HasData = true
+	ca.CPUP50 = window.P50CPU
+	ca.CPUP95 = window.P95CPU
+	ca.CPUP99 = window.P99CPU
+	ca.CPUMax = window.MaxCPU
+	ca.MemP50 = window.P50Memory
+	ca.MemP95 = window.P95Memory
+	ca.MemP99 = window.P99Memory
+	ca.MemMax = window.MaxMemory
+	ca.DataPoints = window.DataPoints
+	a.classifyContainer(&ca, p)
+	return ca
+}
+
+func (a *Analyzer) classifyContainer(ca *ContainerAnalysis, p *Policy) {
+	ca.IsOverProvCPU, ca.IsOverProvMem = false, false
+	ca.IsThrottled = a.config.Rightsizer.Throttling.Enabled &&
+		ca.ThrottleSamples >= MinThrottleSamples &&
+		ca.ThrottledP95*100 >= a.config.Rightsizer.Throttling.ThrottledPct
+	if !ca.HasData {
+		return
+	}
+	cpuUse, memUse := ca.usage(p.Percentile)
+	if ca.CPURequestMilli > 0 {
+		ca.IsOverProvCPU = float64(cpuUse)/float64(ca.CPURequestMilli)*100 < p.CPUTargetUtilPct*0.5
+	}
+	if ca.MemRequestBytes > 0 {
+		ca.IsOverProvMem = float64(memUse)/float64(ca.MemRequestBytes)*100 < p.MemoryTargetUtilPct*0.5
+	}
 }
@@ -0,0 +1,280 @@
+package rightsizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// Sidecar policies.
+const (
+	SidecarPolicySkip     = "skip"
+	SidecarPolicySeparate = "separate"
+)
+
+// annIstioSidecarStatus is set by the Istio injector and lists the
+// containers it added to the pod.
+const annIstioSidecarStatus = "sidecar.istio.io/status"
+
+// sidecarNames returns the set of containers in pod that are sidecars:
+// those whose name is in names, plus anything a mesh injector reports
+// having added.
+func sidecarNames(pod *corev1.Pod, names []string) map[string]bool {
+	known := make(map[string]bool, len(names))
+	for _, n := range names {
+		known[n] = true
+	}
+	if status, ok := pod.Annotations[annIstioSidecarStatus]; ok {
+		var injected struct {
+			Containers []string `json:"containers"`
+		}
+		if err := json.Unmarshal([]byte(status), &injected); err == nil {
+			for _, n := range injected.Containers {
+				known[n] = true
+			}
+		}
+	}
+
+	sidecars := map[string]bool{}
+	for _, c := range pod.Spec.Containers {
+		if known[c.Name] {
+			sidecars[c.Name] = true
+		}
+	}
+	return sidecars
+}
+
+// hasContainerRequests reports whether any container carries both a CPU and
+// a memory request, i.e. whether per-container sizing has anything to work on.
+func hasContainerRequests(containers []ContainerAnalysis) bool {
+	for _, c := range containers {
+		if c.CPURequestMilli > 0 && c.MemRequestBytes > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// computeContainerDownsize sizes each container from its own usage instead
+// of spreading one pod-wide value across them.
+//
+// Application containers get the same treatment as computeDownsize: CPU from
+// P95 * headroom clamped by MinKeepRatio, memory aligned to the node ratio
+// and floored at usage. Sidecars are left alone under the "skip" policy or
+// sized purely from usage under "separate". The CPU limit of each resized
+// container follows its request per the policy's DownsizeCPULimit. The pod
+// totals must still pass ValidateDownsizeTargets, so the disruption
+// thresholds are unchanged.
+func (r *Recommender) computeContainerDownsize(analysis *PodAnalysis, p *Policy, replicaCount int64) *optimizer.Recommendation {
+	pod := analysis.PodInfo
+	separate := r.config.Rightsizer.SidecarPolicy == SidecarPolicySeparate
+
+	changes := make([]optimizer.ContainerChange, len(analysis.Containers))
+	sugCPUs := make([]int64, len(analysis.Containers))
+	sugMems := make([]int64, len(analysis.Containers))
+	var curCPU, curMem, sugCPU, sugMem int64
+	for i, c := range analysis.Containers {
+		sugCPUs[i], sugMems[i] = c.CPURequestMilli, c.MemRequestBytes
+		changes[i] = optimizer.ContainerChange{
+			Name:    c.Name,
+			Sidecar: c.Sidecar,
+			Action:  optimizer.ContainerActionUnchanged,
+		}
+		if c.HasData {
+			changes[i].P95CPU = fmt.Sprintf("%dm", c.CPUP95)
+			changes[i].P95Mem = formatBytes(c.MemP95)
+		}
+
+		switch {
+		case c.Sidecar && !separate:
+			changes[i].Action = optimizer.ContainerActionSkipped
+			changes[i].Reason = "sidecar"
+		case c.CPURequestMilli == 0 || c.MemRequestBytes == 0:
+			changes[i].Reason = "no cpu or memory request"
+		case !c.HasData:
+			changes[i].Reason = "no usage data"
+		case !c.IsOverProvCPU || containerCPUUse(c, p) == 0:
+			changes[i].Reason = "cpu within target"
+		case c.Sidecar:
+			sugCPUs[i], sugMems[i] = sidecarTargets(c, p)
+		default:
+			sugCPUs[i], sugMems[i] = containerTargets(c, analysis, p)
+		}
+	}
+
+	// Keep the pod at or above the CPU floor by giving back to the first
+	// resized application container.
+	for i := range analysis.Containers {
+		curCPU += analysis.Containers[i].CPURequestMilli
+		sugCPU += sugCPUs[i]
+	}
+	if deficit := MinCPUFloorMilli - sugCPU; deficit > 0 {
+		for i, c := range analysis.Containers {
+			if c.Sidecar || sugCPUs[i] >= c.CPURequestMilli {
+				continue
+			}
+			give := min(deficit, c.CPURequestMilli-sugCPUs[i])
+			sugCPUs[i] += give
+			sugCPU += give
+			break
+		}
+	}
+
+	var steps []string
+	resulting := make([]ContainerResources, len(analysis.Containers))
+	for i, c := range analysis.Containers {
+		curMem += c.MemRequestBytes
+		sugMem += sugMems[i]
+		changes[i].CurrentCPURequest = fmt.Sprintf("%dm", c.CPURequestMilli)
+		changes[i].SuggestedCPURequest = fmt.Sprintf("%dm", sugCPUs[i])
+		changes[i].CurrentMemRequest = formatBytes(c.MemRequestBytes)
+		changes[i].SuggestedMemRequest = formatBytes(sugMems[i])
+		if c.CPULimitMilli > 0 {
+			changes[i].CurrentCPULimit = fmt.Sprintf("%dm", c.CPULimitMilli)
+		}
+		resulting[i] = ContainerResources{
+			Name:            c.Name,
+			CPURequestMilli: sugCPUs[i],
+			CPULimitMilli:   c.CPULimitMilli,
+			MemRequestBytes: sugMems[i],
+			MemLimitBytes:   c.MemLimitBytes,
+		}
+		if sugCPUs[i] < c.CPURequestMilli || sugMems[i] < c.MemRequestBytes {
+			changes[i].Action = optimizer.ContainerActionResize
+			step := fmt.Sprintf("Patch container %q: CPU %dm→%dm, memory %s→%s",
+				c.Name, c.CPURequestMilli, sugCPUs[i], formatBytes(c.MemRequestBytes), formatBytes(sugMems[i]))
+
+			// Keep the CPU limit in line with the new request per policy.
+			if limit, remove, changed := downsizedCPULimit(c, sugCPUs[i], p); changed {
+				resulting[i].CPULimitMilli = limit
+				if remove {
+					changes[i].RemoveCPULimit = true
+					step += ", CPU limit removed"
+				} else {
+					changes[i].SuggestedCPULimit = fmt.Sprintf("%dm", limit)
+					step += fmt.Sprintf(", CPU limit %dm→%dm", c.CPULimitMilli, limit)
+				}
+			}
+			steps = append(steps, step)
+		} else if changes[i].Reason == "" && changes[i].Action == optimizer.ContainerActionUnchanged {
+			changes[i].Reason = "already at target"
+		}
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+
+	// --- Safety validation on the pod totals and LimitRanges ---
+	if err := ValidateDownsizeTargets(curCPU, sugCPU, curMem, sugMem, analysis.LimitRange, resulting...); err != nil {
+		return nil
+	}
+
+	cpuSavings := estimateCPUSavings(curCPU, sugCPU, r.config.CloudProvider)
+	memSavings := estimateMemorySavings(curMem, sugMem, r.config.CloudProvider)
+	totalSavings := (cpuSavings + memSavings) * float64(replicaCount)
+	if totalSavings <= 0 {
+		return nil
+	}
+
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return nil
+	}
+
+	return &optimizer.Recommendation{
+		ID:              fmt.Sprintf("rightsize-combined-%s-%s-%d", pod.Pod.Namespace, pod.Pod.Name, time.Now().Unix()),
+		Type:            optimizer.RecommendationPodRightsize,
+		Priority:        optimizer.PriorityMedium,
+		AutoExecutable:  false,
+		TargetKind:      pod.OwnerKind,
+		TargetName:      pod.OwnerName,
+		TargetNamespace: pod.Pod.Namespace,
+		Summary: fmt.Sprintf("Rightsize %s/%s: CPU %dm→%dm, memory %s→%s across %d of %d containers (%d replicas)",
+			pod.Pod.Namespace, pod.OwnerName,
+			curCPU, sugCPU, formatBytes(curMem), formatBytes(sugMem),
+			len(steps), len(changes), replicaCount),
+		ActionSteps: steps,
+		EstimatedSaving: optimizer.SavingEstimate{
+			MonthlySavingsUSD: totalSavings,
+			AnnualSavingsUSD:  totalSavings * 12,
+			Currency:          "USD",
+		},
+		Details: map[string]string{
+			"resource":                 "cpu+memory",
+			"currentCPURequest":        fmt.Sprintf("%dm", curCPU),
+			"suggestedCPURequest":      fmt.Sprintf("%dm", sugCPU),
+			"currentMemRequest":        formatBytes(curMem),
+			"suggestedMemRequest":      formatBytes(sugMem),
+			"p95CPU":                   fmt.Sprintf("%dm", analysis.CPUP95),
+			"p95Mem":                   formatBytes(analysis.MemP95),
+			"replicaCount":             fmt.Sprintf("%d", replicaCount),
+			optimizer.DetailContainers: string(diff),
+		},
+		CreatedAt: time.Now(),
+	}
+}
+
+// containerTargets computes CPU and memory for an application container.
+// Unlike computeCPUTarget there is no per-container 1 CPU floor; the floor
+// applies to the pod total.
+func containerTargets(c ContainerAnalysis, analysis *PodAnalysis, p *Policy) (int64, int64) {
+	_, memUse := c.usage(p.Percentile)
+	cpu := containerCPUTarget(c, p)
+	memFloor := computeMemFloor(memUse)
+	mem := computeMemTarget(cpu, &PodAnalysis{
+		CPURequestMilli: c.CPURequestMilli,
+		MemRequestBytes: c.MemRequestBytes,
+		NodeCPUCapMilli: analysis.NodeCPUCapMilli,
+		NodeMemCapBytes: analysis.NodeMemCapBytes,
+	}, memFloor)
+	return cpu, boundContainerMem(min(mem, c.MemRequestBytes), memFloor, c, p)
+}
+
+// sidecarTargets sizes a sidecar from its own usage only. Node-ratio
+// alignment makes no sense for a proxy or log shipper, so memory is kept at
+// max(usage * headroom, request * minKeepRatio).
+func sidecarTargets(c ContainerAnalysis, p *Policy) (int64, int64) {
+	_, memUse := c.usage(p.Percentile)
+	cpu := containerCPUTarget(c, p)
+	memFloor := computeMemFloor(memUse)
+	mem := max(memFloor, int64(float64(c.MemRequestBytes)*p.MinKeepRatio))
+	return cpu, boundContainerMem(min(mem, c.MemRequestBytes), memFloor, c, p)
+}
+
+// containerCPUTarget sizes a container's CPU from usage at the policy
+// percentile, clamped by MinKeepRatio and the policy bounds, and never
+// above the current request.
+func containerCPUTarget(c ContainerAnalysis, p *Policy) int64 {
+	keep := int64(float64(c.CPURequestMilli) * p.MinKeepRatio)
+	cpu := max(int64(float64(containerCPUUse(c, p))*UsageHeadroom), keep, MinCPUAbsolute)
+	cpu = boundDownsize(cpu, p.MinCPUMilli, p.MaxCPUMilli, keep)
+	return min(cpu, c.CPURequestMilli)
+}
+
+// boundContainerMem clamps a container's memory target into the policy
+// bounds without going below its usage floor or above its request.
+func boundContainerMem(mem, memFloor int64, c ContainerAnalysis, p *Policy) int64 {
+	return min(boundDownsize(mem, p.MinMemBytes, p.MaxMemBytes, min(memFloor, c.MemRequestBytes)), c.MemRequestBytes)
+}
+
+func containerCPUUse(c ContainerAnalysis, p *Policy) int64 {
+	cpu, _ := c.usage(p.Percentile)
+	return cpu
+}
+
+// containerChanges decodes the per-container diff of rec, if any.
+func containerChanges(rec optimizer.Recommendation) ([]optimizer.ContainerChange, error) {
+	raw, ok := rec.Details[optimizer.DetailContainers]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	var changes []optimizer.ContainerChange
+	if err := json.Unmarshal([]byte(raw), &changes); err != nil {
+		return nil, fmt.Errorf("invalid %s detail: %w", optimizer.DetailContainers, err)
+	}
+	return changes, nil
+}
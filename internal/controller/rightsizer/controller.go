@@ -2,12 +2,14 @@ package rightsizer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
 	"time"
 
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -33,29 +35,51 @@ type Controller struct {
 	actuator     *Actuator
 	oomTracker   *OOMTracker
 	notifier     *Notifier
+	watchdog     *Watchdog
+	policies     *PolicyResolver
+	targets      *RecommendedTargets
 
 	mu        sync.Mutex
 	downsized map[string]time.Time // tracks workloads already downsized with TTL
 	execTimes []time.Time          // sliding window for rate limiting executions
+	restarts  restartBudget        // restarting changes per namespace, for the daily budget
 }
 
 func NewController(mgr ctrl.Manager, st *state.ClusterState, gate *aigate.AIGate, cfg *config.Config, metricsStore *metrics.Store) *Controller {
 	c := mgr.GetClient()
+	actuator := NewActuator(c, cfg)
+	oomTracker := NewOOMTracker(c, cfg)
+	analyzer := NewAnalyzer(cfg, metricsStore)
+	policies := NewPolicyResolver(c, cfg)
+	analyzer.SetPolicies(policies)
+	oomTracker.SetPolicies(policies)
+	watchdog := NewWatchdog(c, cfg, metricsStore, st.AuditLog, oomTracker, actuator)
+	watchdog.SetGate(gate)
 	return &Controller{
 		client:       c,
 		state:        st,
 		gate:         gate,
 		config:       cfg,
 		metricsStore: metricsStore,
-		analyzer:     NewAnalyzer(cfg, metricsStore),
+		analyzer:     analyzer,
 		recommender:  NewRecommender(cfg),
-		actuator:     NewActuator(c, cfg),
-		oomTracker:   NewOOMTracker(c, cfg),
+		actuator:     actuator,
+		oomTracker:   oomTracker,
 		notifier:     NewNotifier(cfg, st.AuditLog),
+		watchdog:     watchdog,
+		policies:     policies,
+		targets:      NewRecommendedTargets(),
 		downsized:    make(map[string]time.Time),
+		restarts:     restartBudget{},
 	}
 }
 
+// SetHistory lets the analyzer query an external metrics history for
+// containers the metrics store has not sampled yet.
+func (c *Controller) SetHistory(h metrics.WindowQuerier) {
+	c.analyzer.SetHistory(h)
+}
+
 func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
 	return mgr.Add(c)
 }
@@ -71,6 +95,12 @@ func (c *Controller) Name() string { return "rightsizer" }
 func (c *Controller) Analyze(ctx context.Context, snapshot *optimizer.ClusterSnapshot) ([]optimizer.Recommendation, error) {
 	var recs []optimizer.Recommendation
 
+	// Reload RightsizingPolicies once per cycle. Without the CRD installed
+	// only annotations and the global config apply.
+	if err := c.policies.Refresh(ctx); err != nil {
+		log.FromContext(ctx).WithName("rightsizer").V(1).Info("Loading rightsizing policies failed", "error", err)
+	}
+
 	// Check for OOM events using snapshot pods (avoids redundant API call)
 	oomRecs, err := c.oomTracker.Analyze(ctx, snapshot.Pods)
 	if err != nil {
@@ -81,18 +111,31 @@ func (c *Controller) Analyze(ctx context.Context, snapshot *optimizer.ClusterSna
 	// Build HPA targets set to skip HPA-managed workloads
 	hpaTargets := c.buildHPATargets(ctx)
 
+	// LimitRanges bound every target, so they are listed once per cycle.
+	limitRanges := c.limitRanges(ctx)
+
 	// Build node capacity lookup for ratio-based rightsizing
 	nodeCapacity := make(map[string]*optimizer.NodeInfo, len(snapshot.Nodes))
 	for i := range snapshot.Nodes {
 		nodeCapacity[snapshot.Nodes[i].Node.Name] = &snapshot.Nodes[i]
 	}
 
+	// DaemonSet pods are collected per DaemonSet and sized for the busiest
+	// node once every pod has been analyzed.
+	daemonSets := map[string][]*PodAnalysis{}
+
 	// Analyze pod resource usage patterns
 	for _, pod := range snapshot.Pods {
 		if c.isExcluded(pod.Pod.Namespace) {
 			continue
 		}
 
+		// Job pods are analyzed through their CronJob from past runs below;
+		// a Job's own pod template is immutable.
+		if pod.OwnerKind == "Job" {
+			continue
+		}
+
 		// Skip non-Running pods — Pending pods report 0 utilization and
 		// would generate incorrect downsize recommendations.
 		if pod.Pod.Status.Phase != corev1.PodRunning {
@@ -121,12 +164,109 @@ func (c *Controller) Analyze(ctx context.Context, snapshot *optimizer.ClusterSna
 			analysis.NodeCPUCapMilli = node.CPUCapacity
 			analysis.NodeMemCapBytes = node.MemoryCapacity
 		}
+		c.attachLimitContext(analysis, limitRanges)
+
+		if pod.OwnerKind == "DaemonSet" {
+			key := pod.Pod.Namespace + "/" + pod.OwnerName
+			daemonSets[key] = append(daemonSets[key], analysis)
+			continue
+		}
 
 		podRecs := c.recommender.Recommend(analysis)
 		recs = append(recs, podRecs...)
 	}
 
-	return recs, nil
+	keys := make([]string, 0, len(daemonSets))
+	for key := range daemonSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		merged := c.analyzer.MergePeak(daemonSets[key])
+		merged.PodInfo.ReplicaCount = len(daemonSets[key])
+		recs = append(recs, c.recommender.Recommend(merged)...)
+	}
+
+	recs = append(recs, c.analyzeCronJobs(ctx, limitRanges)...)
+
+	// Workloads the watchdog rolled back are not downsized again.
+	filtered := recs[:0]
+	for _, rec := range recs {
+		if isDownsizeRec(rec) && c.watchdog.IsBlocked(rec) {
+			continue
+		}
+		filtered = append(filtered, rec)
+	}
+	c.targets.Observe(filtered)
+
+	return filtered, nil
+}
+
+// RecommendedRequests returns the latest recommended container requests of
+// a workload for the pod webhook, or nil when there are none or the
+// watchdog blocked the workload.
+func (c *Controller) RecommendedRequests(namespace, kind, name string) map[string]corev1.ResourceList {
+	if c.watchdog.IsBlocked(optimizer.Recommendation{TargetNamespace: namespace, TargetKind: kind, TargetName: name}) {
+		return nil
+	}
+	return c.targets.Requests(namespace, kind, name)
+}
+
+// analyzeCronJobs rightsizes CronJob job templates from the peak usage of
+// their past runs.
+func (c *Controller) analyzeCronJobs(ctx context.Context, limitRanges map[string]*LimitRangeConstraints) []optimizer.Recommendation {
+	cronJobs := &batchv1.CronJobList{}
+	if err := c.client.List(ctx, cronJobs); err != nil {
+		log.FromContext(ctx).WithName("rightsizer").V(1).Info("Listing CronJobs failed", "error", err)
+		return nil
+	}
+
+	var recs []optimizer.Recommendation
+	for i := range cronJobs.Items {
+		cj := &cronJobs.Items[i]
+		if c.isExcluded(cj.Namespace) {
+			continue
+		}
+		analysis := c.analyzer.AnalyzeCronJob(cj)
+		if analysis == nil {
+			continue
+		}
+		c.attachLimitContext(analysis, limitRanges)
+		recs = append(recs, c.recommender.Recommend(analysis)...)
+	}
+	return recs
+}
+
+// limitRanges returns the LimitRange constraints of every namespace that has
+// any. Without them targets are only checked again at apply time.
+func (c *Controller) limitRanges(ctx context.Context) map[string]*LimitRangeConstraints {
+	list := &corev1.LimitRangeList{}
+	if err := c.client.List(ctx, list); err != nil {
+		log.FromContext(ctx).WithName("rightsizer").V(1).Info("Listing LimitRanges failed", "error", err)
+		return nil
+	}
+	byNamespace := map[string][]corev1.LimitRange{}
+	for _, lr := range list.Items {
+		byNamespace[lr.Namespace] = append(byNamespace[lr.Namespace], lr)
+	}
+	constraints := make(map[string]*LimitRangeConstraints, len(byNamespace))
+	for ns, ranges := range byNamespace {
+		if lr := limitRangeConstraints(ranges); lr != nil {
+			constraints[ns] = lr
+		}
+	}
+	return constraints
+}
+
+// attachLimitContext adds what limit recommendations need beyond usage:
+// the namespace's LimitRange constraints and each container's OOM history
+// across the workload.
+func (c *Controller) attachLimitContext(analysis *PodAnalysis, limitRanges map[string]*LimitRangeConstraints) {
+	pod := analysis.PodInfo
+	analysis.LimitRange = limitRanges[pod.Pod.Namespace]
+	for i := range analysis.Containers {
+		analysis.Containers[i].LastOOMKill = c.oomTracker.LastKilled(pod.Pod.Namespace, pod.OwnerKind, pod.OwnerName, analysis.Containers[i].Name)
+	}
 }
 
 func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation) error {
@@ -144,11 +284,13 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 
 	// Safety actions (OOM memory bumps) always execute immediately.
 	if !isDownsizeRec(rec) {
-		return c.executeWithGate(ctx, rec)
+		_, err := c.executeWithGate(ctx, rec)
+		return err
 	}
 
-	// Downsize recommendations require auto-approve to be enabled.
-	if !c.config.Rightsizer.AutoApprove {
+	// Downsize recommendations require auto-approve, either globally or
+	// through the workload's "auto" policy mode.
+	if !c.config.Rightsizer.AutoApprove && rec.Details["policyMode"] != PolicyModeAuto {
 		return nil
 	}
 
@@ -167,32 +309,131 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 		return nil
 	}
 
-	if err := c.executeWithGate(ctx, rec); err != nil {
+	// Changes that restart pods wait for a maintenance window and for room
+	// in the namespace's daily budget. They stay pending until then.
+	restarts := restartsPods(rec, c.config.Rightsizer.Rollout.Strategy)
+	if restarts && !c.canRestart(ctx, rec, time.Now()) {
+		return nil
+	}
+
+	applied, err := c.executeWithGate(ctx, rec)
+	if errors.Is(err, ErrRightsizingBlocked) {
+		c.watchdog.Block(rec, err.Error())
+		return nil
+	}
+	if err != nil {
 		return err
 	}
+	if applied {
+		c.watchdog.Watch(ctx, rec, c.state.Snapshot().Pods)
+	}
 
 	c.recordExecution()
 
 	// Record successful downsize with timestamp for TTL-based expiry.
 	c.mu.Lock()
 	c.downsized[workloadKey] = time.Now()
+	if applied && restarts {
+		c.restarts.record(rec.TargetNamespace, time.Now())
+	}
 	c.mu.Unlock()
 	return nil
 }
 
+// canRestart reports whether a downsize that restarts pods may run at now:
+// inside a maintenance window and within the namespace's daily budget.
+func (c *Controller) canRestart(ctx context.Context, rec optimizer.Recommendation, now time.Time) bool {
+	reason := c.restartWait(rec, now)
+	if reason != "" {
+		log.FromContext(ctx).WithName("rightsizer").V(1).Info("Deferring rightsizing",
+			"namespace", rec.TargetNamespace, "kind", rec.TargetKind, "name", rec.TargetName, "reason", reason)
+	}
+	return reason == ""
+}
+
+// restartWait returns why a change that restarts pods cannot run at now, or
+// "" if it can.
+func (c *Controller) restartWait(rec optimizer.Recommendation, now time.Time) string {
+	rollout := c.config.Rightsizer.Rollout
+	if !inMaintenanceWindow(rollout.MaintenanceWindows, now) {
+		return "outside the maintenance windows"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.restarts.allow(rec.TargetNamespace, rollout.NamespaceDailyBudget, now) {
+		return fmt.Sprintf("daily restart budget of %d for namespace %s is used up", rollout.NamespaceDailyBudget, rec.TargetNamespace)
+	}
+	return ""
+}
+
+// WaitApproved holds back an approved downsize or limit change that
+// restarts pods until a maintenance window is open and the namespace's
+// restart budget has room, exactly like an auto-approved downsize.
+func (c *Controller) WaitApproved(_ context.Context, rec optimizer.Recommendation) string {
+	if !c.approvedRestart(rec) {
+		return ""
+	}
+	return c.restartWait(rec, time.Now())
+}
+
+// approvedRestart reports whether rec, once approved, restarts pods under
+// the maintenance windows and restart budget. OOM memory bumps are safety
+// actions and never wait.
+func (c *Controller) approvedRestart(rec optimizer.Recommendation) bool {
+	return (isDownsizeRec(rec) || isLimitRec(rec)) && restartsPods(rec, c.config.Rightsizer.Rollout.Strategy)
+}
+
 // executeWithGate runs AI Gate validation then applies the recommendation.
-func (c *Controller) executeWithGate(ctx context.Context, rec optimizer.Recommendation) error {
+// It reports whether the recommendation was applied.
+func (c *Controller) executeWithGate(ctx context.Context, rec optimizer.Recommendation) (bool, error) {
 	if c.gate.RequiresValidation(rec) {
-		valReq := aigate.ValidationRequest{
-			Action:         rec.Summary,
-			Recommendation: rec,
-		}
+		valReq := c.state.GateRequest(ctx, rec)
 		result, err := c.gate.Validate(ctx, valReq)
 		if err != nil || !result.Approved {
-			return nil // Falls back to recommendation mode
+			return false, nil // Falls back to recommendation mode
+		}
+	}
+	if err := c.actuator.Apply(ctx, rec); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ExecuteApproved applies a user-approved recommendation via the actuator.
+// Approval replaces the auto-approve setting, but the 24h downsize cooldown
+// still applies, and WaitApproved keeps restarting downsizes and limit
+// changes queued outside the maintenance windows. Mode and AI Gate checks
+// are performed by the executor.
+func (c *Controller) ExecuteApproved(ctx context.Context, rec optimizer.Recommendation) error {
+	workloadKey := rec.TargetNamespace + "/" + rec.TargetKind + "/" + rec.TargetName
+	if isDownsizeRec(rec) {
+		c.mu.Lock()
+		downsizedAt, already := c.downsized[workloadKey]
+		c.mu.Unlock()
+		if already && time.Since(downsizedAt) < 24*time.Hour {
+			return fmt.Errorf("%s was downsized %s ago, cooldown is 24h", workloadKey, time.Since(downsizedAt).Round(time.Minute))
+		}
+	}
+
+	if err := c.actuator.Apply(ctx, rec); err != nil {
+		if errors.Is(err, ErrRightsizingBlocked) {
+			c.watchdog.Block(rec, err.Error())
 		}
+		return err
+	}
+
+	c.mu.Lock()
+	if isDownsizeRec(rec) {
+		c.downsized[workloadKey] = time.Now()
+	}
+	if c.approvedRestart(rec) {
+		c.restarts.record(rec.TargetNamespace, time.Now())
 	}
-	return c.actuator.Apply(ctx, rec)
+	c.mu.Unlock()
+	if isDownsizeRec(rec) {
+		c.watchdog.Watch(ctx, rec, c.state.Snapshot().Pods)
+	}
+	return nil
 }
 
 // isDownsizeRec returns true for proportional CPU+memory downsize recommendations.
@@ -202,6 +443,11 @@ func isDownsizeRec(rec optimizer.Recommendation) bool {
 	return rec.Details["resource"] == "cpu+memory" && rec.Details["direction"] != "upsize"
 }
 
+// isLimitRec returns true for CPU and memory limit recommendations.
+func isLimitRec(rec optimizer.Recommendation) bool {
+	return rec.Details["resource"] == "cpu-limit" || rec.Details["resource"] == "memory-limit"
+}
+
 func (c *Controller) isExcluded(namespace string) bool {
 	for _, ns := range c.config.Rightsizer.ExcludeNamespaces {
 		if ns == namespace {
@@ -295,6 +541,11 @@ func (c *Controller) run(ctx context.Context) {
 			c.oomTracker.Cleanup()
 			c.notifier.Cleanup()
 		case <-ticker.C:
+			// Regressions are rolled back even while the breaker is
+			// tripped: a rollback only restores what was there before.
+			if c.config.Rightsizer.Watchdog.Enabled {
+				c.watchdog.Check(ctx, c.state.Snapshot().Pods)
+			}
 			if c.state.Breaker.IsTripped(c.Name()) {
 				logger.V(1).Info("Circuit breaker tripped, skipping execution cycle")
 				continue
@@ -0,0 +1,440 @@
+package rightsizer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// Downsize CPU limit policies: what a downsize does to the CPU limit of
+// each container it resizes.
+const (
+	DownsizeCPULimitKeepRatio  = "keepRatio"  // scale the limit with the request
+	DownsizeCPULimitMultiplier = "multiplier" // set the limit to a multiple of the new request
+	DownsizeCPULimitRemove     = "remove"     // drop the limit
+	DownsizeCPULimitOff        = "off"        // leave the limit alone
+)
+
+// memLimitActionSet is the only memory limit action, stored in
+// Details["action"]. The limit may go either way.
+const memLimitActionSet = "set"
+
+// originalCPULimitKey is the key of a container's original CPU limit in
+// the annOriginalContainers annotation, next to its "cpu" and "memory"
+// requests.
+const originalCPULimitKey = "cpuLimit"
+
+// downsizedCPULimit returns the CPU limit of container c once its request
+// drops to request under policy p, and whether the limit changes. remove
+// reports that the limit is dropped. Containers without a CPU limit are
+// never given one, and a limit is never set below the request.
+func downsizedCPULimit(c ContainerAnalysis, request int64, p *Policy) (limit int64, remove, changed bool) {
+	if c.CPULimitMilli == 0 || c.CPURequestMilli == 0 || request >= c.CPURequestMilli {
+		return c.CPULimitMilli, false, false
+	}
+	switch p.DownsizeCPULimit {
+	case DownsizeCPULimitRemove:
+		return 0, true, true
+	case DownsizeCPULimitMultiplier:
+		limit = ceilCPU(float64(request) * p.CPULimitMultiplier)
+	case DownsizeCPULimitKeepRatio:
+		limit = ceilCPU(float64(c.CPULimitMilli) * float64(request) / float64(c.CPURequestMilli))
+	default:
+		return c.CPULimitMilli, false, false
+	}
+	limit = max(limit, request)
+	return limit, false, limit != c.CPULimitMilli
+}
+
+// ceilCPU rounds millicores up to the MinCPUAbsolute step.
+func ceilCPU(milli float64) int64 {
+	return int64(math.Ceil(milli/MinCPUAbsolute)) * MinCPUAbsolute
+}
+
+// ceilMi rounds bytes up to a whole MiB.
+func ceilMi(bytes float64) int64 {
+	const mi = 1024 * 1024
+	return int64(math.Ceil(bytes/mi)) * mi
+}
+
+// memLimitTarget returns the memory limit to recommend for c, or 0 when the
+// current limit should stay. The target is the observed peak plus
+// headroomPct, never below the request. A container OOM killed within
+// OOMHistoryWindow has its limit raised by at least oomBump and never
+// lowered. Lowering also needs MinMemLimitDataPoints samples: the peak of a
+// short window understates the real one. Changes under MemLimitChangeRatio
+// are not worth a restart.
+func memLimitTarget(c ContainerAnalysis, headroomPct, oomBump float64, now time.Time) int64 {
+	if c.MemLimitBytes == 0 || c.MemMax == 0 {
+		return 0
+	}
+	target := max(ceilMi(float64(c.MemMax)*(1+headroomPct/100)), c.MemRequestBytes)
+	oom := !c.LastOOMKill.IsZero() && now.Sub(c.LastOOMKill) < OOMHistoryWindow
+	if oom {
+		target = max(target, ceilMi(float64(c.MemLimitBytes)*oomBump))
+	}
+
+	minChange := float64(c.MemLimitBytes) * MemLimitChangeRatio
+	switch {
+	case target > c.MemLimitBytes:
+		if !oom && float64(target-c.MemLimitBytes) < minChange {
+			return 0
+		}
+	case target < c.MemLimitBytes:
+		if oom || c.DataPoints < MinMemLimitDataPoints || float64(c.MemLimitBytes-target) < minChange {
+			return 0
+		}
+	default:
+		return 0
+	}
+	return target
+}
+
+// recommendMemLimits returns one recommendation per container whose memory
+// limit is far from its observed peak (see memLimitTarget). Limits don't
+// affect cost, so these carry no savings and are never auto-executed.
+// Sidecars are left alone under the "skip" sidecar policy, and a target
+// the namespace's LimitRanges would reject is dropped.
+func (r *Recommender) recommendMemLimits(analysis *PodAnalysis, p *Policy) []optimizer.Recommendation {
+	lim := r.config.Rightsizer.Limits
+	if !lim.MemoryLimits {
+		return nil
+	}
+	skipSidecars := r.config.Rightsizer.SidecarPolicy != SidecarPolicySeparate
+	now := time.Now()
+
+	pod := analysis.PodInfo
+	var recs []optimizer.Recommendation
+	for _, c := range analysis.Containers {
+		if !c.HasData || (c.Sidecar && skipSidecars) {
+			continue
+		}
+		target := memLimitTarget(c, lim.MemoryHeadroomPct, p.OOMBumpMultiplier, now)
+		if target == 0 {
+			continue
+		}
+		res := ContainerResources{
+			Name:            c.Name,
+			CPURequestMilli: c.CPURequestMilli,
+			CPULimitMilli:   c.CPULimitMilli,
+			MemRequestBytes: c.MemRequestBytes,
+			MemLimitBytes:   target,
+		}
+		if err := analysis.LimitRange.Check(res); err != nil {
+			continue
+		}
+
+		details := map[string]string{
+			"resource":          "memory-limit",
+			"action":            memLimitActionSet,
+			"container":         c.Name,
+			"currentMemLimit":   formatBytes(c.MemLimitBytes),
+			"suggestedMemLimit": formatBytes(target),
+			"memRequest":        formatBytes(c.MemRequestBytes),
+			"maxMem":            formatBytes(c.MemMax),
+		}
+		priority := optimizer.PriorityLow
+		verb := "Lower"
+		if target > c.MemLimitBytes {
+			priority, verb = optimizer.PriorityHigh, "Raise"
+		}
+		reason := fmt.Sprintf("peak usage %s", formatBytes(c.MemMax))
+		if !c.LastOOMKill.IsZero() && now.Sub(c.LastOOMKill) < OOMHistoryWindow {
+			details["lastOOMKill"] = c.LastOOMKill.UTC().Format(time.RFC3339)
+			reason += ", OOM killed " + c.LastOOMKill.UTC().Format(time.RFC3339)
+		}
+
+		recs = append(recs, optimizer.Recommendation{
+			ID:              fmt.Sprintf("rightsize-memlimit-%s-%s-%s-%d", pod.Pod.Namespace, pod.Pod.Name, c.Name, now.Unix()),
+			Type:            optimizer.RecommendationPodRightsize,
+			Priority:        priority,
+			AutoExecutable:  false,
+			TargetKind:      pod.OwnerKind,
+			TargetName:      pod.OwnerName,
+			TargetNamespace: pod.Pod.Namespace,
+			Summary: fmt.Sprintf("%s memory limit of %s/%s container %q: %s→%s (%s)",
+				verb, pod.Pod.Namespace, pod.OwnerName, c.Name, formatBytes(c.MemLimitBytes), formatBytes(target), reason),
+			ActionSteps: []string{
+				fmt.Sprintf("Patch memory limit of container %q from %s to %s", c.Name, formatBytes(c.MemLimitBytes), formatBytes(target)),
+			},
+			Details:   details,
+			CreatedAt: now,
+		})
+	}
+	return recs
+}
+
+// buildMemLimitPatch builds a pod template patch that sets container's
+// memory limit to value, which may not drop below its memory request.
+func buildMemLimitPatch(containers []corev1.Container, container, action, value string) (map[string]interface{}, error) {
+	target := findContainer(containers, container)
+	if target == nil {
+		return nil, fmt.Errorf("container %q not found", container)
+	}
+	if action != memLimitActionSet {
+		return nil, fmt.Errorf("unsupported memory limit action %q: must be set", action)
+	}
+	qty, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory limit %q: %w", value, err)
+	}
+	if qty.Sign() <= 0 {
+		return nil, fmt.Errorf("memory limit must be positive, got %s", value)
+	}
+	if req, ok := target.Resources.Requests[corev1.ResourceMemory]; ok && qty.Cmp(req) < 0 {
+		return nil, fmt.Errorf("memory limit %s is below the memory request %s", value, req.String())
+	}
+	return limitPatch(container, corev1.ResourceMemory, qty.String()), nil
+}
+
+// limitPatch builds a pod template patch setting one limit of container;
+// a nil value removes it.
+func limitPatch(container string, name corev1.ResourceName, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []map[string]interface{}{{
+						"name": container,
+						"resources": map[string]interface{}{
+							"limits": map[string]interface{}{string(name): value},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func findContainer(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// --- LimitRange enforcement ---
+
+// ContainerResources is a container's requests and limits, in millicores
+// and bytes, as a change would leave them. A zero limit is unset.
+type ContainerResources struct {
+	Name            string
+	CPURequestMilli int64
+	CPULimitMilli   int64
+	MemRequestBytes int64
+	MemLimitBytes   int64
+}
+
+func containerResources(name string, res corev1.ResourceRequirements) ContainerResources {
+	return ContainerResources{
+		Name:            name,
+		CPURequestMilli: res.Requests.Cpu().MilliValue(),
+		CPULimitMilli:   res.Limits.Cpu().MilliValue(),
+		MemRequestBytes: res.Requests.Memory().Value(),
+		MemLimitBytes:   res.Limits.Memory().Value(),
+	}
+}
+
+// podWideResources returns pod's containers as a pod-wide downsize to the
+// given totals leaves them: the first container takes the totals minus the
+// other containers' requests (see buildCombinedResourcePatch).
+func podWideResources(pod *corev1.Pod, cpuMilli, memBytes int64) []ContainerResources {
+	if pod == nil || len(pod.Spec.Containers) == 0 {
+		return nil
+	}
+	out := make([]ContainerResources, 0, len(pod.Spec.Containers))
+	for i, c := range pod.Spec.Containers {
+		res := containerResources(c.Name, c.Resources)
+		if i > 0 {
+			cpuMilli -= res.CPURequestMilli
+			memBytes -= res.MemRequestBytes
+		}
+		out = append(out, res)
+	}
+	out[0].CPURequestMilli, out[0].MemRequestBytes = cpuMilli, memBytes
+	return out
+}
+
+// LimitBounds are the LimitRange constraints on one resource of a
+// container, in millicores for CPU and bytes for memory. Zero is unset.
+type LimitBounds struct {
+	Min                  int64
+	Max                  int64
+	MaxLimitRequestRatio float64
+	// DefaultLimit is set when a LimitRange fills in a missing limit, so
+	// Max and MaxLimitRequestRatio don't require one.
+	DefaultLimit bool
+}
+
+// LimitRangeConstraints are the tightest "Container" constraints of every
+// LimitRange in a namespace. Pod-level LimitRanges are not checked.
+type LimitRangeConstraints struct {
+	CPU    LimitBounds
+	Memory LimitBounds
+}
+
+// limitRangeConstraints merges the Container items of ranges, or returns
+// nil when none constrain containers.
+func limitRangeConstraints(ranges []corev1.LimitRange) *LimitRangeConstraints {
+	var lr *LimitRangeConstraints
+	for _, r := range ranges {
+		for _, item := range r.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			if lr == nil {
+				lr = &LimitRangeConstraints{}
+			}
+			lr.CPU.merge(item, corev1.ResourceCPU, func(q resource.Quantity) int64 { return q.MilliValue() })
+			lr.Memory.merge(item, corev1.ResourceMemory, func(q resource.Quantity) int64 { return q.Value() })
+		}
+	}
+	return lr
+}
+
+func (b *LimitBounds) merge(item corev1.LimitRangeItem, name corev1.ResourceName, value func(resource.Quantity) int64) {
+	if q, ok := item.Min[name]; ok {
+		b.Min = max(b.Min, value(q))
+	}
+	if q, ok := item.Max[name]; ok {
+		if v := value(q); b.Max == 0 || v < b.Max {
+			b.Max = v
+		}
+	}
+	if q, ok := item.MaxLimitRequestRatio[name]; ok {
+		if v := q.AsApproximateFloat64(); b.MaxLimitRequestRatio == 0 || v < b.MaxLimitRequestRatio {
+			b.MaxLimitRequestRatio = v
+		}
+	}
+	if _, ok := item.Default[name]; ok {
+		b.DefaultLimit = true
+	}
+}
+
+// Check returns an error when c violates the constraints. A nil receiver
+// has none.
+func (l *LimitRangeConstraints) Check(c ContainerResources) error {
+	if l == nil {
+		return nil
+	}
+	if err := l.CPU.check(c.Name, "cpu", c.CPURequestMilli, c.CPULimitMilli, func(v int64) string { return fmt.Sprintf("%dm", v) }); err != nil {
+		return err
+	}
+	return l.Memory.check(c.Name, "memory", c.MemRequestBytes, c.MemLimitBytes, formatBytes)
+}
+
+func (b LimitBounds) check(container, res string, request, limit int64, format func(int64) string) error {
+	if b.Min > 0 {
+		if request > 0 && request < b.Min {
+			return fmt.Errorf("container %q %s request %s is below the LimitRange minimum %s", container, res, format(request), format(b.Min))
+		}
+		if limit > 0 && limit < b.Min {
+			return fmt.Errorf("container %q %s limit %s is below the LimitRange minimum %s", container, res, format(limit), format(b.Min))
+		}
+	}
+	if limit == 0 && !b.DefaultLimit && (b.Max > 0 || b.MaxLimitRequestRatio > 0) {
+		return fmt.Errorf("container %q has no %s limit, which the LimitRange requires", container, res)
+	}
+	if b.Max > 0 {
+		if request > b.Max {
+			return fmt.Errorf("container %q %s request %s is above the LimitRange maximum %s", container, res, format(request), format(b.Max))
+		}
+		if limit > b.Max {
+			return fmt.Errorf("container %q %s limit %s is above the LimitRange maximum %s", container, res, format(limit), format(b.Max))
+		}
+	}
+	if b.MaxLimitRequestRatio > 0 && limit > 0 && request > 0 {
+		if ratio := float64(limit) / float64(request); ratio > b.MaxLimitRequestRatio {
+			return fmt.Errorf("container %q %s limit/request ratio %.2f is above the LimitRange maximum %.2f", container, res, ratio, b.MaxLimitRequestRatio)
+		}
+	}
+	return nil
+}
+
+// checkLimitRanges rejects container patches that would leave a container
+// outside the LimitRanges of namespace. The API server accepts such a pod
+// template but refuses every pod created from it.
+func (a *Actuator) checkLimitRanges(ctx context.Context, namespace string, containers []corev1.Container, patches []map[string]interface{}) error {
+	list := &corev1.LimitRangeList{}
+	if err := a.client.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("listing LimitRanges in %s: %w", namespace, err)
+	}
+	lr := limitRangeConstraints(list.Items)
+	for _, c := range patchedResources(containers, patches) {
+		if err := lr.Check(c); err != nil {
+			return fmt.Errorf("LimitRange in namespace %s: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
+// patchedResources returns the resources of containers as the strategic
+// merge entries in patches would leave them. A nil value removes a
+// quantity.
+func patchedResources(containers []corev1.Container, patches []map[string]interface{}) []ContainerResources {
+	byName := make(map[string]map[string]interface{}, len(patches))
+	for _, p := range patches {
+		name, _ := p["name"].(string)
+		if res, ok := p["resources"].(map[string]interface{}); ok {
+			byName[name] = res
+		}
+	}
+
+	out := make([]ContainerResources, 0, len(containers))
+	for _, c := range containers {
+		res := *c.Resources.DeepCopy()
+		if p, ok := byName[c.Name]; ok {
+			res.Requests = overlayResources(res.Requests, p["requests"])
+			res.Limits = overlayResources(res.Limits, p["limits"])
+		}
+		out = append(out, containerResources(c.Name, res))
+	}
+	return out
+}
+
+func overlayResources(list corev1.ResourceList, patch interface{}) corev1.ResourceList {
+	values := map[string]interface{}{}
+	switch p := patch.(type) {
+	case map[string]string:
+		for k, v := range p {
+			values[k] = v
+		}
+	case map[string]interface{}:
+		values = p
+	default:
+		return list
+	}
+	if list == nil {
+		list = corev1.ResourceList{}
+	}
+	for k, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			delete(list, corev1.ResourceName(k))
+			continue
+		}
+		if q, err := resource.ParseQuantity(s); err == nil {
+			list[corev1.ResourceName(k)] = q
+		}
+	}
+	return list
+}
+
+// templateContainerPatches returns the container entries of a pod template
+// patch.
+func templateContainerPatches(patchData map[string]interface{}) []map[string]interface{} {
+	spec, _ := patchData["spec"].(map[string]interface{})
+	tmpl, _ := spec["template"].(map[string]interface{})
+	podSpec, _ := tmpl["spec"].(map[string]interface{})
+	patches, _ := podSpec["containers"].([]map[string]interface{})
+	return patches
+}
@@ -16,15 +16,29 @@ import (
 
 // OOMTracker detects OOM kills and recommends memory increases.
 type OOMTracker struct {
-	client client.Client
-	config *config.Config
+	client   client.Client
+	config   *config.Config
+	policies *PolicyResolver
 
-	mu   sync.Mutex
-	seen map[string]time.Time // ns/pod/container -> last rec time (dedup)
+	mu      sync.Mutex
+	seen    map[string]time.Time // ns/pod/container -> last rec time (dedup)
+	history map[string]time.Time // ns/ownerKind/ownerName/container -> last OOM kill
 }
 
 func NewOOMTracker(c client.Client, cfg *config.Config) *OOMTracker {
-	return &OOMTracker{client: c, config: cfg, seen: make(map[string]time.Time)}
+	return &OOMTracker{
+		client:   c,
+		config:   cfg,
+		policies: NewPolicyResolver(nil, cfg),
+		seen:     make(map[string]time.Time),
+		history:  make(map[string]time.Time),
+	}
+}
+
+// SetPolicies installs the resolver that supplies each workload's OOM bump
+// multiplier.
+func (t *OOMTracker) SetPolicies(r *PolicyResolver) {
+	t.policies = r
 }
 
 // Analyze checks for recent OOM kills and generates recommendations.
@@ -38,6 +52,8 @@ func (t *OOMTracker) Analyze(ctx context.Context, pods []optimizer.PodInfo) ([]o
 		if t.isExcluded(pod.Namespace) {
 			continue
 		}
+		policy := t.policies.Resolve(pod)
+		t.recordKills(pi)
 
 		for _, cs := range pod.Status.ContainerStatuses {
 			if cs.LastTerminationState.Terminated == nil ||
@@ -77,8 +93,9 @@ func (t *OOMTracker) Analyze(ctx context.Context, pods []optimizer.PodInfo) ([]o
 					continue
 				}
 
-				// Apply bump multiplier (default 2.5x)
-				suggestedMem := int64(float64(currentMem) * t.config.Rightsizer.OOMBumpMultiplier)
+				// Apply bump multiplier (default 2.5x). Policy bounds do not
+				// cap a safety bump.
+				suggestedMem := int64(float64(currentMem) * policy.OOMBumpMultiplier)
 				if suggestedMem <= currentMem {
 					continue // multiplier <= 1.0 produces no actual increase
 				}
@@ -105,7 +122,7 @@ func (t *OOMTracker) Analyze(ctx context.Context, pods []optimizer.PodInfo) ([]o
 					TargetName:      ownerName,
 					TargetNamespace: pod.Namespace,
 					Summary: fmt.Sprintf("OOM killed: increase memory for %s/%s container %s from %s to %s (%.1fx bump)",
-						pod.Namespace, ownerName, cs.Name, formatBytes(currentMem), formatBytes(suggestedMem), t.config.Rightsizer.OOMBumpMultiplier),
+						pod.Namespace, ownerName, cs.Name, formatBytes(currentMem), formatBytes(suggestedMem), policy.OOMBumpMultiplier),
 					ActionSteps: []string{
 						fmt.Sprintf("Patch memory request/limit from %s to %s", formatBytes(currentMem), formatBytes(suggestedMem)),
 					},
@@ -114,7 +131,8 @@ func (t *OOMTracker) Analyze(ctx context.Context, pods []optimizer.PodInfo) ([]o
 						"currentRequest":   formatBytes(currentMem),
 						"suggestedRequest": formatBytes(suggestedMem),
 						"reason":           "OOMKilled",
-						"bumpMultiplier":   fmt.Sprintf("%.1f", t.config.Rightsizer.OOMBumpMultiplier),
+						"bumpMultiplier":   fmt.Sprintf("%.1f", policy.OOMBumpMultiplier),
+						"policy":           policy.Source,
 					},
 					CreatedAt: time.Now(),
 				})
@@ -125,7 +143,63 @@ func (t *OOMTracker) Analyze(ctx context.Context, pods []optimizer.PodInfo) ([]o
 	return recs, nil
 }
 
-// Cleanup removes expired entries from the seen map.
+// KilledSince returns "pod/container" for every container in pods that was
+// OOM killed after since. Unlike Analyze it is not deduplicated, so the
+// watchdog can ask about the same pods on every check.
+func (t *OOMTracker) KilledSince(pods []optimizer.PodInfo, since time.Time) []string {
+	var killed []string
+	for _, pi := range pods {
+		for _, cs := range pi.Pod.Status.ContainerStatuses {
+			for _, term := range []*corev1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+				if term != nil && term.Reason == "OOMKilled" && term.FinishedAt.After(since) {
+					killed = append(killed, pi.Pod.Name+"/"+cs.Name)
+					break
+				}
+			}
+		}
+	}
+	return killed
+}
+
+// recordKills remembers the latest OOM kill of each container of pi's
+// workload, so memory limits are not lowered after the pod is replaced.
+func (t *OOMTracker) recordKills(pi optimizer.PodInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, cs := range pi.Pod.Status.ContainerStatuses {
+		for _, term := range []*corev1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+			if term == nil || term.Reason != "OOMKilled" {
+				continue
+			}
+			at := term.FinishedAt.Time
+			if at.IsZero() {
+				at = time.Now()
+			}
+			key := oomHistoryKey(pi.Pod.Namespace, pi.OwnerKind, pi.OwnerName, cs.Name)
+			if at.After(t.history[key]) {
+				t.history[key] = at
+			}
+		}
+	}
+}
+
+// LastKilled returns the most recent OOM kill seen for container in any
+// pod of the workload within OOMHistoryWindow, or the zero time.
+func (t *OOMTracker) LastKilled(namespace, kind, name, container string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	at := t.history[oomHistoryKey(namespace, kind, name, container)]
+	if time.Since(at) > OOMHistoryWindow {
+		return time.Time{}
+	}
+	return at
+}
+
+func oomHistoryKey(namespace, kind, name, container string) string {
+	return namespace + "/" + kind + "/" + name + "/" + container
+}
+
+// Cleanup removes expired entries from the seen map and the OOM history.
 func (t *OOMTracker) Cleanup() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -135,6 +209,12 @@ func (t *OOMTracker) Cleanup() {
 			delete(t.seen, k)
 		}
 	}
+	historyCutoff := time.Now().Add(-OOMHistoryWindow)
+	for k, v := range t.history {
+		if v.Before(historyCutoff) {
+			delete(t.history, k)
+		}
+	}
 }
 
 func (t *OOMTracker) isExcluded(namespace string) bool {
@@ -0,0 +1,396 @@
+package rightsizer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
+	"github.com/koptimizer/koptimizer/internal/config"
+)
+
+// Rightsizing modes.
+const (
+	PolicyModeOff       = "off"       // no rightsizing recommendations
+	PolicyModeRecommend = "recommend" // recommendations wait for approval
+	PolicyModeAuto      = "auto"      // downsizes are applied without approval
+)
+
+// Pod template annotations that override the rightsizing policy of a
+// single workload. They take precedence over any RightsizingPolicy.
+const (
+	RightsizingModeAnnotation           = "koptimizer.io/rightsizing-mode"
+	RightsizingPercentileAnnotation     = "koptimizer.io/rightsizing-percentile"
+	RightsizingCPUTargetAnnotation      = "koptimizer.io/rightsizing-cpu-target-util-pct"
+	RightsizingMemoryTargetAnnotation   = "koptimizer.io/rightsizing-memory-target-util-pct"
+	RightsizingMinKeepRatioAnnotation   = "koptimizer.io/rightsizing-min-keep-ratio"
+	RightsizingOOMBumpAnnotation        = "koptimizer.io/rightsizing-oom-bump-multiplier"
+	RightsizingMinCPUAnnotation         = "koptimizer.io/rightsizing-min-cpu"
+	RightsizingMaxCPUAnnotation         = "koptimizer.io/rightsizing-max-cpu"
+	RightsizingMinMemoryAnnotation      = "koptimizer.io/rightsizing-min-memory"
+	RightsizingMaxMemoryAnnotation      = "koptimizer.io/rightsizing-max-memory"
+	RightsizingCPULimitPolicyAnnotation = "koptimizer.io/rightsizing-cpu-limit-policy"
+
+	RightsizingDownsizeCPULimitAnnotation   = "koptimizer.io/rightsizing-downsize-cpu-limit"
+	RightsizingCPULimitMultiplierAnnotation = "koptimizer.io/rightsizing-cpu-limit-multiplier"
+)
+
+const (
+	policySourceDefault     = "default"
+	policySourceAnnotations = "annotations"
+	defaultPercentile       = 95
+)
+
+// Policy is the effective rightsizing policy of one workload: the global
+// config, overridden by the best matching RightsizingPolicy and then by the
+// workload's annotations. Bounds of 0 are unset.
+type Policy struct {
+	// Source names what produced the policy, e.g. "default",
+	// "RightsizingPolicy/jvm" or "RightsizingPolicy/jvm+annotations".
+	Source string
+
+	Mode                string
+	Percentile          int
+	CPUTargetUtilPct    float64
+	MemoryTargetUtilPct float64
+	MinKeepRatio        float64
+	OOMBumpMultiplier   float64
+	MinCPUMilli         int64
+	MaxCPUMilli         int64
+	MinMemBytes         int64
+	MaxMemBytes         int64
+	CPULimitPolicy      string
+	DownsizeCPULimit    string
+	CPULimitMultiplier  float64
+}
+
+// defaultPolicy builds the policy of a workload nothing overrides.
+func defaultPolicy(cfg *config.Config) *Policy {
+	rs := cfg.Rightsizer
+	p := &Policy{
+		Source:              policySourceDefault,
+		Mode:                PolicyModeRecommend,
+		Percentile:          defaultPercentile,
+		CPUTargetUtilPct:    rs.CPUTargetUtilPct,
+		MemoryTargetUtilPct: rs.MemoryTargetUtilPct,
+		MinKeepRatio:        rs.MinKeepRatio,
+		OOMBumpMultiplier:   rs.OOMBumpMultiplier,
+		CPULimitPolicy:      rs.Throttling.CPULimitPolicy,
+		DownsizeCPULimit:    rs.Limits.DownsizeCPULimit,
+		CPULimitMultiplier:  rs.Limits.CPULimitMultiplier,
+	}
+	if p.MinKeepRatio <= 0 {
+		p.MinKeepRatio = DefaultMinKeepRatio
+	}
+	if p.CPULimitPolicy == "" {
+		p.CPULimitPolicy = CPULimitPolicyRaise
+	}
+	if p.DownsizeCPULimit == "" {
+		p.DownsizeCPULimit = DownsizeCPULimitKeepRatio
+	}
+	if p.CPULimitMultiplier < 1 {
+		p.CPULimitMultiplier = DefaultCPULimitMultiplier
+	}
+	if q, err := resource.ParseQuantity(rs.MinCPURequest); err == nil {
+		p.MinCPUMilli = q.MilliValue()
+	}
+	if q, err := resource.ParseQuantity(rs.MinMemoryRequest); err == nil {
+		p.MinMemBytes = q.Value()
+	}
+	return p
+}
+
+// PolicyResolver resolves the effective Policy of a workload from the
+// RightsizingPolicy objects in the cluster and the workload's annotations.
+// Policies are listed once per cycle by Refresh; without a client only
+// annotations apply.
+type PolicyResolver struct {
+	client client.Client
+	config *config.Config
+
+	mu       sync.RWMutex
+	policies []koptv1alpha1.RightsizingPolicy
+}
+
+func NewPolicyResolver(c client.Client, cfg *config.Config) *PolicyResolver {
+	return &PolicyResolver{client: c, config: cfg}
+}
+
+// Refresh reloads the RightsizingPolicy objects. On error the previously
+// loaded policies stay in effect.
+func (r *PolicyResolver) Refresh(ctx context.Context) error {
+	if r.client == nil {
+		return nil
+	}
+	list := &koptv1alpha1.RightsizingPolicyList{}
+	if err := r.client.List(ctx, list); err != nil {
+		return fmt.Errorf("listing rightsizing policies: %w", err)
+	}
+	// Highest priority first, then by name, so the first match wins.
+	sort.Slice(list.Items, func(i, j int) bool {
+		a, b := list.Items[i], list.Items[j]
+		if a.Spec.Priority != b.Spec.Priority {
+			return a.Spec.Priority > b.Spec.Priority
+		}
+		return a.Name < b.Name
+	})
+	r.mu.Lock()
+	r.policies = list.Items
+	r.mu.Unlock()
+	return nil
+}
+
+// Resolve returns the effective policy of the workload that owns pod. The
+// pod's labels and annotations are those of its template.
+func (r *PolicyResolver) Resolve(pod *corev1.Pod) *Policy {
+	p := defaultPolicy(r.config)
+	if pod == nil {
+		return p
+	}
+
+	r.mu.RLock()
+	for i := range r.policies {
+		if policyMatches(&r.policies[i].Spec, pod) {
+			applyPolicySpec(p, &r.policies[i].Spec)
+			p.Source = "RightsizingPolicy/" + r.policies[i].Name
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if applyPolicyAnnotations(p, pod.Annotations) {
+		if p.Source == policySourceDefault {
+			p.Source = policySourceAnnotations
+		} else {
+			p.Source += "+" + policySourceAnnotations
+		}
+	}
+	return p
+}
+
+// policyMatches reports whether a policy's namespace and label scope cover pod.
+func policyMatches(spec *koptv1alpha1.RightsizingPolicySpec, pod *corev1.Pod) bool {
+	if len(spec.Namespaces) > 0 {
+		found := false
+		for _, ns := range spec.Namespaces {
+			if ns == pod.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if spec.Selector == nil {
+		return true
+	}
+	sel, err := metav1.LabelSelectorAsSelector(spec.Selector)
+	if err != nil {
+		return false
+	}
+	return sel.Matches(labels.Set(pod.Labels))
+}
+
+// applyPolicySpec overrides p with the fields a RightsizingPolicy sets.
+// Unparseable bounds are ignored.
+func applyPolicySpec(p *Policy, spec *koptv1alpha1.RightsizingPolicySpec) {
+	if spec.Mode != "" {
+		p.Mode = spec.Mode
+	}
+	if spec.Percentile != 0 {
+		p.Percentile = int(spec.Percentile)
+	}
+	if spec.CPUTargetUtilPct > 0 {
+		p.CPUTargetUtilPct = spec.CPUTargetUtilPct
+	}
+	if spec.MemoryTargetUtilPct > 0 {
+		p.MemoryTargetUtilPct = spec.MemoryTargetUtilPct
+	}
+	if spec.MinKeepRatio > 0 {
+		p.MinKeepRatio = spec.MinKeepRatio
+	}
+	if spec.OOMBumpMultiplier > 0 {
+		p.OOMBumpMultiplier = spec.OOMBumpMultiplier
+	}
+	if spec.CPULimitPolicy != "" {
+		p.CPULimitPolicy = spec.CPULimitPolicy
+	}
+	if spec.DownsizeCPULimit != "" {
+		p.DownsizeCPULimit = spec.DownsizeCPULimit
+	}
+	if spec.CPULimitMultiplier >= 1 {
+		p.CPULimitMultiplier = spec.CPULimitMultiplier
+	}
+	setMilli(&p.MinCPUMilli, spec.MinCPU)
+	setMilli(&p.MaxCPUMilli, spec.MaxCPU)
+	setBytes(&p.MinMemBytes, spec.MinMemory)
+	setBytes(&p.MaxMemBytes, spec.MaxMemory)
+}
+
+// applyPolicyAnnotations overrides p with the rightsizing annotations in
+// ann and reports whether any applied. Invalid values are skipped.
+func applyPolicyAnnotations(p *Policy, ann map[string]string) bool {
+	applied := false
+	for key, value := range ann {
+		if !strings.HasPrefix(key, "koptimizer.io/rightsizing-") || key == annRightsizingBlocked {
+			continue
+		}
+		if err := applyPolicyAnnotation(p, key, strings.TrimSpace(value)); err != nil {
+			slog.Debug("rightsizer: ignoring invalid policy annotation", "annotation", key, "value", value, "error", err)
+			continue
+		}
+		applied = true
+	}
+	return applied
+}
+
+func applyPolicyAnnotation(p *Policy, key, value string) error {
+	switch key {
+	case RightsizingModeAnnotation:
+		switch value {
+		case PolicyModeOff, PolicyModeRecommend, PolicyModeAuto:
+			p.Mode = value
+		default:
+			return fmt.Errorf("must be off, recommend or auto")
+		}
+	case RightsizingPercentileAnnotation:
+		pct, err := strconv.Atoi(value)
+		if err != nil || (pct != 50 && pct != 95 && pct != 99 && pct != 100) {
+			return fmt.Errorf("must be 50, 95, 99 or 100")
+		}
+		p.Percentile = pct
+	case RightsizingCPUTargetAnnotation:
+		return setPct(&p.CPUTargetUtilPct, value)
+	case RightsizingMemoryTargetAnnotation:
+		return setPct(&p.MemoryTargetUtilPct, value)
+	case RightsizingMinKeepRatioAnnotation:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v <= 0 || v > 1 {
+			return fmt.Errorf("must be in (0, 1]")
+		}
+		p.MinKeepRatio = v
+	case RightsizingOOMBumpAnnotation:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v <= 1 {
+			return fmt.Errorf("must be greater than 1")
+		}
+		p.OOMBumpMultiplier = v
+	case RightsizingMinCPUAnnotation:
+		return quantityMilli(&p.MinCPUMilli, value)
+	case RightsizingMaxCPUAnnotation:
+		return quantityMilli(&p.MaxCPUMilli, value)
+	case RightsizingMinMemoryAnnotation:
+		return quantityBytes(&p.MinMemBytes, value)
+	case RightsizingMaxMemoryAnnotation:
+		return quantityBytes(&p.MaxMemBytes, value)
+	case RightsizingCPULimitPolicyAnnotation:
+		switch value {
+		case CPULimitPolicyRaise, CPULimitPolicyRemove, CPULimitPolicyOff:
+			p.CPULimitPolicy = value
+		default:
+			return fmt.Errorf("must be raise, remove or off")
+		}
+	case RightsizingDownsizeCPULimitAnnotation:
+		switch value {
+		case DownsizeCPULimitKeepRatio, DownsizeCPULimitMultiplier, DownsizeCPULimitRemove, DownsizeCPULimitOff:
+			p.DownsizeCPULimit = value
+		default:
+			return fmt.Errorf("must be keepRatio, multiplier, remove or off")
+		}
+	case RightsizingCPULimitMultiplierAnnotation:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 1 {
+			return fmt.Errorf("must be at least 1")
+		}
+		p.CPULimitMultiplier = v
+	default:
+		return fmt.Errorf("unknown rightsizing annotation")
+	}
+	return nil
+}
+
+func setPct(dst *float64, value string) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v <= 0 || v > 100 {
+		return fmt.Errorf("must be in (0, 100]")
+	}
+	*dst = v
+	return nil
+}
+
+func quantityMilli(dst *int64, value string) error {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return err
+	}
+	*dst = q.MilliValue()
+	return nil
+}
+
+func quantityBytes(dst *int64, value string) error {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return err
+	}
+	*dst = q.Value()
+	return nil
+}
+
+func setMilli(dst *int64, value string) {
+	if value != "" {
+		_ = quantityMilli(dst, value)
+	}
+}
+
+func setBytes(dst *int64, value string) {
+	if value != "" {
+		_ = quantityBytes(dst, value)
+	}
+}
+
+// usageAt picks the usage at percentile pct from a P50/P95/P99/max series.
+// Unknown percentiles fall back to P95.
+func usageAt(pct int, p50, p95, p99, peak int64) int64 {
+	switch pct {
+	case 50:
+		return p50
+	case 99:
+		return p99
+	case 100:
+		return peak
+	default:
+		return p95
+	}
+}
+
+// boundDownsize clamps a downsize target into the policy bounds [lo, hi],
+// where 0 is unbounded. The upper bound never takes the target below keep,
+// the most a single cycle may remove.
+func boundDownsize(target, lo, hi, keep int64) int64 {
+	if hi > 0 && target > hi {
+		target = max(hi, keep)
+	}
+	if lo > 0 && target < lo {
+		target = lo
+	}
+	return target
+}
+
+// boundUpsize caps an upsize target at the policy bound hi (0 is unbounded).
+func boundUpsize(target, hi int64) int64 {
+	if hi > 0 && target > hi {
+		return hi
+	}
+	return target
+}
@@ -35,6 +35,39 @@ const (
 
 	// MinCPUAbsolute is the smallest CPU value we'd ever compute (cosmetic floor).
 	MinCPUAbsolute = 10
+
+	// MinBatchRuns is the number of past runs a CronJob needs in the metrics
+	// store before its peak usage is trusted.
+	MinBatchRuns = 3
+
+	// MinPodDataPoints is the number of usage samples a pod needs before it
+	// is rightsized.
+	MinPodDataPoints = 6
+
+	// MinThrottleSamples is the number of throttling intervals (one per
+	// state refresh) needed before a container can count as throttled,
+	// whether for a recommendation or by the watchdog after a change.
+	MinThrottleSamples = 6
+
+	// MaxCPULimitRaise caps how far one recommendation raises a throttled
+	// container's CPU limit (2x).
+	MaxCPULimitRaise = 2.0
+
+	// DefaultCPULimitMultiplier is the limit:request ratio of the
+	// "multiplier" downsize CPU limit policy when none is configured.
+	DefaultCPULimitMultiplier = 2.0
+
+	// MinMemLimitDataPoints is the number of samples (a day at the default
+	// 60s reconcile interval) needed before a memory limit is lowered.
+	MinMemLimitDataPoints = 1440
+
+	// MemLimitChangeRatio is the smallest change of a memory limit, as a
+	// fraction of the current one, worth recommending (20%).
+	MemLimitChangeRatio = 0.2
+
+	// OOMHistoryWindow is how long an OOM kill keeps a container's memory
+	// limit from being lowered.
+	OOMHistoryWindow = 7 * 24 * time.Hour
 )
 
 // Recommender generates CPU/memory rightsizing recommendations.
@@ -51,19 +84,48 @@ func NewRecommender(cfg *config.Config) *Recommender {
 // All returned recommendations are validated against safety invariants before
 // being returned. Even if a bug in the computation produces an unsafe result,
 // the validation catches it.
+//
+// The workload's policy can turn rightsizing off, and under the "auto" mode
+// its downsizes become auto-executable. Every recommendation names the
+// policy that produced it in Details["policy"].
 func (r *Recommender) Recommend(analysis *PodAnalysis) []optimizer.Recommendation {
-	if analysis.DataPoints < 6 {
+	if analysis.DataPoints < MinPodDataPoints {
+		return nil
+	}
+	p := r.policy(analysis)
+	if p.Mode == PolicyModeOff {
 		return nil
 	}
 
-	pod := analysis.PodInfo
+	recs := r.recommend(analysis, p)
+	for i := range recs {
+		recs[i].Details["policy"] = p.Source
+		recs[i].Details["policyMode"] = p.Mode
+		if p.Percentile != defaultPercentile {
+			recs[i].Details["percentile"] = fmt.Sprintf("%d", p.Percentile)
+		}
+		if p.Mode == PolicyModeAuto && isDownsizeRec(recs[i]) {
+			recs[i].AutoExecutable = true
+		}
+	}
+	return recs
+}
 
-	// Never generate any recommendations for DaemonSets. DaemonSets run on
-	// every node, so any resource change multiplies across the entire cluster.
-	if pod.OwnerKind == "DaemonSet" {
-		return nil
+// policy returns the analysis' policy, or the global one when it has none.
+func (r *Recommender) policy(analysis *PodAnalysis) *Policy {
+	if analysis.Policy != nil {
+		return analysis.Policy
 	}
+	return defaultPolicy(r.config)
+}
+
+// recommend generates the recommendations of one workload under policy p.
+func (r *Recommender) recommend(analysis *PodAnalysis, p *Policy) []optimizer.Recommendation {
+	pod := analysis.PodInfo
 
+	// DaemonSets and CronJobs reach here already merged to their peak
+	// (Analyzer.MergePeak, Analyzer.AnalyzeCronJob); a DaemonSet's
+	// ReplicaCount is its pod count, so savings scale with the node count.
 	replicaCount := int64(1)
 	if pod.ReplicaCount > 1 {
 		replicaCount = int64(pod.ReplicaCount)
@@ -71,17 +133,38 @@ func (r *Recommender) Recommend(analysis *PodAnalysis) []optimizer.Recommendatio
 
 	var recs []optimizer.Recommendation
 
-	// Only generate downsizing recommendations when CPU is over-provisioned.
-	// Upsize recommendations are intentionally disabled — all scaling decisions
-	// must be human-reviewed via the bulk approval UI.
-	if analysis.IsOverProvCPU && analysis.CPUP95 > 0 {
-		rec := r.computeDownsize(analysis, replicaCount)
-		if rec != nil {
+	// A CFS-throttled workload's P95 is capped by its CPU limit and
+	// understates demand, so it is never downsized. Its request may be
+	// raised instead, and its CPU limits raised or removed per policy; none
+	// of these are auto-executable.
+	if analysis.IsThrottled {
+		if rec := r.recommendUpsize(analysis, p, replicaCount); rec != nil {
 			recs = append(recs, *rec)
 		}
+		recs = append(recs, r.recommendCPULimits(analysis, p)...)
+		return append(recs, r.recommendMemLimits(analysis, p)...)
 	}
 
-	return recs
+	// Otherwise only generate downsizing recommendations when CPU is
+	// over-provisioned. Upsize recommendations are intentionally disabled —
+	// all scaling decisions must be human-reviewed via the bulk approval UI.
+	// With per-container data each container is judged on its own usage,
+	// so an idle app next to a busy sidecar still gets rightsized.
+	cpuUse, _ := analysis.usage(p.Percentile)
+	var rec *optimizer.Recommendation
+	switch {
+	case hasContainerRequests(analysis.Containers):
+		rec = r.computeContainerDownsize(analysis, p, replicaCount)
+	case analysis.IsOverProvCPU && cpuUse > 0:
+		rec = r.computeDownsize(analysis, p, replicaCount)
+	}
+	if rec != nil {
+		recs = append(recs, *rec)
+	}
+
+	// Memory limits are judged on peak usage and OOM history, separately
+	// from the requests.
+	return append(recs, r.recommendMemLimits(analysis, p)...)
 }
 
 // computeDownsize generates a combined CPU+memory recommendation that aligns
@@ -92,20 +175,20 @@ func (r *Recommender) Recommend(analysis *PodAnalysis) []optimizer.Recommendatio
 //     and 1 CPU floor)
 //  2. Compute memory to match node's CPU:memory ratio for the target CPU
 //  3. Clamp memory: never below usage floor, never above current request
-//  4. Validate all safety invariants before emitting
-func (r *Recommender) computeDownsize(analysis *PodAnalysis, replicaCount int64) *optimizer.Recommendation {
+//  4. Clamp both into the policy's min/max bounds
+//  5. Validate all safety invariants before emitting
+//
+// Usage is taken at the policy's percentile, P95 unless overridden.
+func (r *Recommender) computeDownsize(analysis *PodAnalysis, p *Policy, replicaCount int64) *optimizer.Recommendation {
 	pod := analysis.PodInfo
-
-	minKeepRatio := r.config.Rightsizer.MinKeepRatio
-	if minKeepRatio <= 0 {
-		minKeepRatio = DefaultMinKeepRatio
-	}
+	minKeepRatio := p.MinKeepRatio
+	cpuUse, memUse := analysis.usage(p.Percentile)
 
 	// --- CPU target ---
-	suggestedCPU := computeCPUTarget(analysis.CPUP95, analysis.CPURequestMilli, minKeepRatio)
+	suggestedCPU := computeCPUTarget(cpuUse, analysis.CPURequestMilli, minKeepRatio)
 
 	// --- Memory target ---
-	memFloor := computeMemFloor(analysis.MemP95)
+	memFloor := computeMemFloor(memUse)
 	suggestedMem := computeMemTarget(suggestedCPU, analysis, memFloor)
 
 	// Cap memory at current — never increase memory during a cost-saving
@@ -115,8 +198,13 @@ func (r *Recommender) computeDownsize(analysis *PodAnalysis, replicaCount int64)
 		suggestedMem = analysis.MemRequestBytes
 	}
 
+	// --- Policy bounds ---
+	suggestedCPU = boundDownsize(suggestedCPU, p.MinCPUMilli, p.MaxCPUMilli, int64(float64(analysis.CPURequestMilli)*minKeepRatio))
+	suggestedMem = boundDownsize(suggestedMem, p.MinMemBytes, p.MaxMemBytes, min(memFloor, analysis.MemRequestBytes))
+
 	// --- Safety validation (catches bugs in the computation above) ---
-	if err := ValidateDownsizeTargets(analysis.CPURequestMilli, suggestedCPU, analysis.MemRequestBytes, suggestedMem); err != nil {
+	if err := ValidateDownsizeTargets(analysis.CPURequestMilli, suggestedCPU, analysis.MemRequestBytes, suggestedMem,
+		analysis.LimitRange, podWideResources(pod.Pod, suggestedCPU, suggestedMem)...); err != nil {
 		return nil
 	}
 
@@ -169,15 +257,19 @@ func (r *Recommender) computeDownsize(analysis *PodAnalysis, replicaCount int64)
 // recommendUpsize generates a request increase recommendation for under-provisioned pods.
 // It skips the increase if the pod has a high limit that provides burst headroom — the pod
 // can already burst beyond its request without needing a permanent request increase.
-func (r *Recommender) recommendUpsize(analysis *PodAnalysis, replicaCount int64) *optimizer.Recommendation {
+// CFS throttling counts as CPU under-provisioning: a throttled limit provides no headroom.
+// Usage is taken at the policy's percentile and targets are capped at its
+// max bounds.
+func (r *Recommender) recommendUpsize(analysis *PodAnalysis, p *Policy, replicaCount int64) *optimizer.Recommendation {
 	pod := analysis.PodInfo
+	cpuUse, memUse := analysis.usage(p.Percentile)
 
-	needCPUUpsize := analysis.IsUnderProvCPU
+	needCPUUpsize := analysis.IsUnderProvCPU || analysis.IsThrottled
 	needMemUpsize := analysis.IsUnderProvMem
 
 	// Skip CPU upsize if limit provides burst headroom (usage < 70% of limit).
-	if needCPUUpsize && analysis.CPULimitMilli > 0 {
-		usageToLimit := float64(analysis.CPUP95) / float64(analysis.CPULimitMilli)
+	if needCPUUpsize && !analysis.IsThrottled && analysis.CPULimitMilli > 0 {
+		usageToLimit := float64(cpuUse) / float64(analysis.CPULimitMilli)
 		if usageToLimit < 0.7 {
 			needCPUUpsize = false
 		}
@@ -185,7 +277,7 @@ func (r *Recommender) recommendUpsize(analysis *PodAnalysis, replicaCount int64)
 
 	// Skip memory upsize if limit provides headroom (usage < 70% of limit).
 	if needMemUpsize && analysis.MemLimitBytes > 0 {
-		usageToLimit := float64(analysis.MemP95) / float64(analysis.MemLimitBytes)
+		usageToLimit := float64(memUse) / float64(analysis.MemLimitBytes)
 		if usageToLimit < 0.7 {
 			needMemUpsize = false
 		}
@@ -200,7 +292,7 @@ func (r *Recommender) recommendUpsize(analysis *PodAnalysis, replicaCount int64)
 
 	if needCPUUpsize {
 		// Set request to P95 * 1.2 headroom
-		suggestedCPU = int64(float64(analysis.CPUP95) * 1.2)
+		suggestedCPU = boundUpsize(int64(float64(cpuUse)*1.2), p.MaxCPUMilli)
 		if suggestedCPU <= analysis.CPURequestMilli {
 			suggestedCPU = analysis.CPURequestMilli // no-op, don't decrease
 			needCPUUpsize = false
@@ -208,7 +300,7 @@ func (r *Recommender) recommendUpsize(analysis *PodAnalysis, replicaCount int64)
 	}
 
 	if needMemUpsize {
-		suggestedMem = int64(float64(analysis.MemP95) * 1.2)
+		suggestedMem = boundUpsize(int64(float64(memUse)*1.2), p.MaxMemBytes)
 		if suggestedMem <= analysis.MemRequestBytes {
 			suggestedMem = analysis.MemRequestBytes
 			needMemUpsize = false
@@ -219,7 +311,7 @@ func (r *Recommender) recommendUpsize(analysis *PodAnalysis, replicaCount int64)
 		return nil
 	}
 
-	var summary string
+	var summary, suggested string
 	resource := ""
 	if needCPUUpsize && needMemUpsize {
 		resource = "cpu+memory"
@@ -230,18 +322,20 @@ func (r *Recommender) recommendUpsize(analysis *PodAnalysis, replicaCount int64)
 			replicaCount)
 	} else if needCPUUpsize {
 		resource = "cpu"
+		suggested = fmt.Sprintf("%dm", suggestedCPU)
 		summary = fmt.Sprintf("Upsize %s/%s: CPU %dm→%dm (%d replicas)",
 			pod.Pod.Namespace, pod.OwnerName,
 			analysis.CPURequestMilli, suggestedCPU, replicaCount)
 	} else {
 		resource = "memory"
+		suggested = formatBytes(suggestedMem)
 		summary = fmt.Sprintf("Upsize %s/%s: memory %s→%s (%d replicas)",
 			pod.Pod.Namespace, pod.OwnerName,
 			formatBytes(analysis.MemRequestBytes), formatBytes(suggestedMem),
 			replicaCount)
 	}
 
-	return &optimizer.Recommendation{
+	rec := &optimizer.Recommendation{
 		ID:              fmt.Sprintf("rightsize-upsize-%s-%s-%d", pod.Pod.Namespace, pod.Pod.Name, time.Now().Unix()),
 		Type:            optimizer.RecommendationPodRightsize,
 		Priority:        optimizer.PriorityHigh,
@@ -270,6 +364,15 @@ func (r *Recommender) recommendUpsize(analysis *PodAnalysis, replicaCount int64)
 		},
 		CreatedAt: time.Now(),
 	}
+	// Single-resource patches are applied from suggestedRequest.
+	if suggested != "" {
+		rec.Details["suggestedRequest"] = suggested
+	}
+	if analysis.IsThrottled {
+		rec.Details["throttledPct"] = fmt.Sprintf("%.0f", analysis.ThrottledP95*100)
+		rec.Summary += fmt.Sprintf(", CPU throttled in %.0f%% of CFS periods", analysis.ThrottledP95*100)
+	}
+	return rec
 }
 
 // computeCPUTarget determines the suggested CPU in millicores.
@@ -330,7 +433,11 @@ func computeMemTarget(suggestedCPU int64, analysis *PodAnalysis, memFloor int64)
 // This is the safety net — even if the computation has a bug, this function
 // prevents unsafe recommendations from being emitted. Tests also call this
 // directly to verify invariants.
-func ValidateDownsizeTargets(currentCPU, suggestedCPU, currentMem, suggestedMem int64) error {
+//
+// containers are the pod's containers as the downsize leaves them; each
+// must satisfy the namespace's LimitRange constraints lr, if any, or the
+// API server would refuse the pods.
+func ValidateDownsizeTargets(currentCPU, suggestedCPU, currentMem, suggestedMem int64, lr *LimitRangeConstraints, containers ...ContainerResources) error {
 	if suggestedCPU >= currentCPU {
 		return fmt.Errorf("CPU not decreasing: %dm -> %dm", currentCPU, suggestedCPU)
 	}
@@ -344,6 +451,11 @@ func ValidateDownsizeTargets(currentCPU, suggestedCPU, currentMem, suggestedMem
 	if memDelta < MinMemDeltaBytes {
 		return fmt.Errorf("memory delta %s below %s minimum", formatBytes(memDelta), formatBytes(MinMemDeltaBytes))
 	}
+	for _, c := range containers {
+		if err := lr.Check(c); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1,15 +1,33 @@
 package rightsizer
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
 	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/metrics"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
 	"github.com/koptimizer/koptimizer/pkg/optimizer"
 )
 
@@ -257,9 +275,9 @@ func TestRecommend_SafetyInvariants(t *testing.T) {
 			wantN: 0,
 		},
 
-		// --- DaemonSet ---
+		// --- DaemonSet (pre-merged to its busiest pod by the controller) ---
 		{
-			name: "DaemonSet always skipped regardless of over-provisioning",
+			name: "DaemonSet over-provisioned: held to the same invariants",
 			analysis: &PodAnalysis{
 				PodInfo:         podInfo("a", "kube-system", "DaemonSet", "my-ds", 4000, 16*gi),
 				CPURequestMilli: 4000, MemRequestBytes: 16 * gi,
@@ -267,7 +285,7 @@ func TestRecommend_SafetyInvariants(t *testing.T) {
 				IsOverProvCPU: true, IsBothOverProv: true,
 				DataPoints: 1000,
 			},
-			wantN: 0,
+			wantN: 1,
 		},
 	}
 
@@ -662,7 +680,7 @@ func TestValidateDownsizeTargets(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := ValidateDownsizeTargets(tt.currentCPU, tt.sugCPU, tt.currentMem, tt.sugMem)
+			err := ValidateDownsizeTargets(tt.currentCPU, tt.sugCPU, tt.currentMem, tt.sugMem, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateDownsizeTargets() error = %v, wantErr = %v", err, tt.wantErr)
 			}
@@ -949,3 +967,2210 @@ func TestIsDownsizeRec_RejectsUpsize(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Analyzer History Tests
+// ---------------------------------------------------------------------------
+
+type fakeHistory struct {
+	window *pkgmetrics.MetricsWindow
+	calls  int
+}
+
+func (f *fakeHistory) ContainerWindow(_ context.Context, _, _, _ string, _ time.Duration) (*pkgmetrics.MetricsWindow, error) {
+	f.calls++
+	return f.window, nil
+}
+
+func TestAnalyzePod_HistoryFallback(t *testing.T) {
+	pod := podInfo("web-0", "default", "Deployment", "web", 1000, 2*gi)
+	pod.Pod.Spec.Containers = []corev1.Container{{Name: "app"}}
+	pod.CPUUsage = 900 // point-in-time value the analyzer falls back to without history
+
+	history := &fakeHistory{window: &pkgmetrics.MetricsWindow{DataPoints: 10080, P95CPU: 200, P95Memory: 512 * mi}}
+	store := metrics.NewStore(7 * 24 * time.Hour)
+	a := NewAnalyzer(defaultCfg(), store)
+	a.SetHistory(history)
+
+	analysis := a.AnalyzePod(context.Background(), pod)
+	if history.calls != 1 {
+		t.Fatalf("history calls = %d, want 1", history.calls)
+	}
+	if analysis.CPUP95 != 200 || analysis.DataPoints != 10080 {
+		t.Errorf("CPUP95/DataPoints = %d/%d, want 200/10080 from history", analysis.CPUP95, analysis.DataPoints)
+	}
+
+	// Once the store has enough samples for the container, history is not queried.
+	recordSamples(store, "web-0", "app", MinPodDataPoints, 300)
+	analysis = a.AnalyzePod(context.Background(), pod)
+	if history.calls != 1 {
+		t.Errorf("history queried despite store data: calls = %d", history.calls)
+	}
+	if analysis.CPUP95 != 300 {
+		t.Errorf("CPUP95 = %d, want 300 from store", analysis.CPUP95)
+	}
+}
+
+func TestAnalyzePod_HistoryFallbackWithFewStoreSamples(t *testing.T) {
+	pod := podInfo("web-0", "default", "Deployment", "web", 1000, 2*gi)
+	pod.Pod.Spec.Containers = []corev1.Container{{Name: "app"}}
+
+	// One sample since the optimizer restarted must not shadow weeks of history.
+	store := metrics.NewStore(7 * 24 * time.Hour)
+	recordSamples(store, "web-0", "app", 1, 900)
+	history := &fakeHistory{window: &pkgmetrics.MetricsWindow{DataPoints: 10080, P95CPU: 200, P95Memory: 512 * mi}}
+	a := NewAnalyzer(defaultCfg(), store)
+	a.SetHistory(history)
+
+	analysis := a.AnalyzePod(context.Background(), pod)
+	if history.calls != 1 {
+		t.Fatalf("history calls = %d, want 1", history.calls)
+	}
+	if analysis.CPUP95 != 200 || analysis.DataPoints != 10080 {
+		t.Errorf("CPUP95/DataPoints = %d/%d, want 200/10080 from history", analysis.CPUP95, analysis.DataPoints)
+	}
+
+	// Without history, the few store samples are still used.
+	history.window = nil
+	analysis = a.AnalyzePod(context.Background(), pod)
+	if analysis.CPUP95 != 900 || analysis.DataPoints != 1 {
+		t.Errorf("CPUP95/DataPoints = %d/%d, want 900/1 from store", analysis.CPUP95, analysis.DataPoints)
+	}
+}
+
+// recordSamples records n one-minute-apart samples of a container in the store.
+func recordSamples(store *metrics.Store, pod, container string, n int, cpu int64) {
+	start := time.Now().Add(-time.Duration(n) * time.Minute)
+	for i := 0; i < n; i++ {
+		store.RecordPodMetrics(pkgmetrics.PodMetrics{
+			Namespace:  "default",
+			Name:       pod,
+			Timestamp:  start.Add(time.Duration(i) * time.Minute),
+			Containers: []pkgmetrics.ContainerMetrics{{Name: container, CPUUsage: cpu, MemoryUsage: gi}},
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Per-container rightsizing
+// ---------------------------------------------------------------------------
+
+// containerPodAnalysis builds a pod analysis from per-container views, with
+// pod-level totals summed the way AnalyzePod does.
+func containerPodAnalysis(containers ...ContainerAnalysis) *PodAnalysis {
+	a := &PodAnalysis{Containers: containers, DataPoints: 1000}
+	for _, c := range containers {
+		a.CPURequestMilli += c.CPURequestMilli
+		a.MemRequestBytes += c.MemRequestBytes
+		a.CPUP95 += c.CPUP95
+		a.MemP95 += c.MemP95
+	}
+	a.PodInfo = podInfo("web-0", "default", "Deployment", "web", a.CPURequestMilli, a.MemRequestBytes)
+	a.IsOverProvCPU = true
+	return a
+}
+
+func containerUsage(name string, cpuReq, memReq, cpuP95, memP95 int64) ContainerAnalysis {
+	return ContainerAnalysis{
+		Name:            name,
+		HasData:         true,
+		CPURequestMilli: cpuReq,
+		MemRequestBytes: memReq,
+		CPUP95:          cpuP95,
+		MemP95:          memP95,
+		DataPoints:      1000,
+		IsOverProvCPU:   float64(cpuP95)/float64(cpuReq) < 0.475,
+	}
+}
+
+func decodeChanges(t *testing.T, rec optimizer.Recommendation) map[string]optimizer.ContainerChange {
+	t.Helper()
+	changes, err := containerChanges(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]optimizer.ContainerChange{}
+	for _, c := range changes {
+		byName[c.Name] = c
+	}
+	return byName
+}
+
+func TestRecommend_PerContainer(t *testing.T) {
+	proxy := containerUsage("istio-proxy", 2000, 2*gi, 100, 100*mi)
+	proxy.Sidecar = true
+
+	tests := []struct {
+		name       string
+		policy     string
+		containers []ContainerAnalysis
+		wantAction map[string]string
+		wantCPU    map[string]string
+		wantMem    map[string]string
+	}{
+		{
+			name:       "app resized, sidecar skipped",
+			policy:     SidecarPolicySkip,
+			containers: []ContainerAnalysis{containerUsage("app", 4000, 16*gi, 500, 2*gi), proxy},
+			wantAction: map[string]string{"app": optimizer.ContainerActionResize, "istio-proxy": optimizer.ContainerActionSkipped},
+			wantCPU:    map[string]string{"app": "2800m", "istio-proxy": "2000m"},
+			wantMem:    map[string]string{"istio-proxy": "2Gi"},
+		},
+		{
+			name:       "sidecar sized from its own usage",
+			policy:     SidecarPolicySeparate,
+			containers: []ContainerAnalysis{containerUsage("app", 4000, 16*gi, 500, 2*gi), proxy},
+			wantAction: map[string]string{"app": optimizer.ContainerActionResize, "istio-proxy": optimizer.ContainerActionResize},
+			wantCPU:    map[string]string{"app": "2800m", "istio-proxy": "1400m"},
+			wantMem:    map[string]string{"istio-proxy": "1433Mi"},
+		},
+		{
+			name:   "busy container left alone",
+			policy: SidecarPolicySkip,
+			containers: []ContainerAnalysis{
+				containerUsage("app", 4000, 16*gi, 500, 2*gi),
+				containerUsage("worker", 2000, 4*gi, 1900, 3*gi),
+			},
+			wantAction: map[string]string{"app": optimizer.ContainerActionResize, "worker": optimizer.ContainerActionUnchanged},
+			wantCPU:    map[string]string{"worker": "2000m"},
+			wantMem:    map[string]string{"worker": "4Gi"},
+		},
+		{
+			name:   "container without data left alone",
+			policy: SidecarPolicySkip,
+			containers: []ContainerAnalysis{
+				containerUsage("app", 4000, 16*gi, 500, 2*gi),
+				{Name: "init-cache", CPURequestMilli: 1000, MemRequestBytes: 4 * gi},
+			},
+			wantAction: map[string]string{"app": optimizer.ContainerActionResize, "init-cache": optimizer.ContainerActionUnchanged},
+			wantCPU:    map[string]string{"init-cache": "1000m"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultCfg()
+			cfg.Rightsizer.SidecarPolicy = tt.policy
+			analysis := containerPodAnalysis(tt.containers...)
+
+			recs := NewRecommender(cfg).Recommend(analysis)
+			if len(recs) != 1 {
+				t.Fatalf("got %d recs, want 1", len(recs))
+			}
+			assertInvariants(t, recs, analysis)
+
+			changes := decodeChanges(t, recs[0])
+			if len(changes) != len(tt.containers) {
+				t.Fatalf("diff has %d containers, want %d", len(changes), len(tt.containers))
+			}
+			var sugCPU int64
+			for name, c := range changes {
+				sugCPU += parseMilli(c.SuggestedCPURequest)
+				if want, ok := tt.wantAction[name]; ok && c.Action != want {
+					t.Errorf("%s action = %q (%s), want %q", name, c.Action, c.Reason, want)
+				}
+				if want, ok := tt.wantCPU[name]; ok && c.SuggestedCPURequest != want {
+					t.Errorf("%s suggested CPU = %s, want %s", name, c.SuggestedCPURequest, want)
+				}
+				if want, ok := tt.wantMem[name]; ok && c.SuggestedMemRequest != want {
+					t.Errorf("%s suggested memory = %s, want %s", name, c.SuggestedMemRequest, want)
+				}
+				if c.Action != optimizer.ContainerActionResize && c.SuggestedCPURequest != c.CurrentCPURequest {
+					t.Errorf("%s is %s but CPU changes %s → %s", name, c.Action, c.CurrentCPURequest, c.SuggestedCPURequest)
+				}
+			}
+			// The pod total is the sum of the per-container suggestions.
+			if got := parseMilli(recs[0].Details["suggestedCPURequest"]); got != sugCPU {
+				t.Errorf("suggestedCPURequest = %dm, want sum of containers %dm", got, sugCPU)
+			}
+		})
+	}
+}
+
+func TestRecommend_PerContainerGating(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []ContainerAnalysis
+	}{
+		{
+			name:       "only sidecar over-provisioned under skip",
+			containers: []ContainerAnalysis{containerUsage("app", 2000, 8*gi, 1900, 7*gi), {Name: "envoy", Sidecar: true, HasData: true, CPURequestMilli: 4000, MemRequestBytes: 8 * gi, CPUP95: 10, MemP95: mi, IsOverProvCPU: true}},
+		},
+		{
+			name:       "memory saving below minimum delta",
+			containers: []ContainerAnalysis{containerUsage("app", 4000, 4*gi, 500, 2*gi)},
+		},
+		{
+			name:       "nothing over-provisioned",
+			containers: []ContainerAnalysis{containerUsage("app", 4000, 16*gi, 3900, 15*gi)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recs := NewRecommender(defaultCfg()).Recommend(containerPodAnalysis(tt.containers...))
+			if len(recs) != 0 {
+				t.Errorf("got %d recs, want none: %s", len(recs), recs[0].Summary)
+			}
+		})
+	}
+}
+
+func TestRecommend_PerContainerKeepsPodFloor(t *testing.T) {
+	cfg := defaultCfg()
+	cfg.Rightsizer.MinKeepRatio = 0.1
+	analysis := containerPodAnalysis(
+		containerUsage("app", 1200, 16*gi, 50, gi),
+		containerUsage("worker", 200, gi, 150, 900*mi),
+	)
+
+	recs := NewRecommender(cfg).Recommend(analysis)
+	if len(recs) != 1 {
+		t.Fatalf("got %d recs, want 1", len(recs))
+	}
+	assertInvariants(t, recs, analysis)
+	if got := recs[0].Details["suggestedCPURequest"]; got != "1000m" {
+		t.Errorf("suggestedCPURequest = %s, want the 1000m pod floor", got)
+	}
+	if got := decodeChanges(t, recs[0])["app"].SuggestedCPURequest; got != "800m" {
+		t.Errorf("app CPU = %s, want 800m after giving back to the floor", got)
+	}
+}
+
+func TestSidecarNames(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			annIstioSidecarStatus: `{"initContainers":["istio-init"],"containers":["mesh-proxy"]}`,
+		}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "app"}, {Name: "mesh-proxy"}, {Name: "fluent-bit"},
+		}},
+	}
+	got := sidecarNames(pod, []string{"fluent-bit", "istio-proxy"})
+	want := map[string]bool{"mesh-proxy": true, "fluent-bit": true}
+	if len(got) != len(want) {
+		t.Fatalf("sidecars = %v, want %v", got, want)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("%s not detected as sidecar", name)
+		}
+	}
+}
+
+func TestBuildContainerResourcePatch(t *testing.T) {
+	containers := []corev1.Container{
+		{Name: "app", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("16Gi"),
+		}}},
+		{Name: "istio-proxy", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("512Mi"),
+		}}},
+		{Name: "worker", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi"),
+		}}},
+	}
+	changes := []optimizer.ContainerChange{
+		{Name: "app", Action: optimizer.ContainerActionResize, SuggestedCPURequest: "2800m", SuggestedMemRequest: "11468Mi"},
+		{Name: "istio-proxy", Sidecar: true, Action: optimizer.ContainerActionSkipped, SuggestedCPURequest: "100m", SuggestedMemRequest: "128Mi"},
+		// Spec was lowered since the recommendation: never raise it back.
+		{Name: "worker", Action: optimizer.ContainerActionResize, SuggestedCPURequest: "1200m", SuggestedMemRequest: "1Gi"},
+		{Name: "gone", Action: optimizer.ContainerActionResize, SuggestedCPURequest: "100m", SuggestedMemRequest: "64Mi"},
+	}
+
+	patch := buildContainerResourcePatch(containers, changes)
+	if patch == nil {
+		t.Fatal("patch is nil")
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"spec":{"template":{"spec":{"containers":[{"name":"app","resources":{"requests":{"cpu":"2800m","memory":"11468Mi"}}}]}}}}`
+	if string(data) != want {
+		t.Errorf("patch =\n%s\nwant\n%s", data, want)
+	}
+
+	if p := buildContainerResourcePatch(containers, changes[1:2]); p != nil {
+		t.Errorf("patch with only skipped containers = %v, want nil", p)
+	}
+}
+
+func TestRecordOriginalContainerResources(t *testing.T) {
+	containers := []corev1.Container{
+		{Name: "app", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("16Gi"),
+		}}},
+		{Name: "worker", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi"),
+		}}},
+	}
+	ann := map[string]string{annOriginalContainers: `{"app":{"cpu":"8","memory":"32Gi"}}`}
+	recordOriginalContainerResources(ann, containers, []optimizer.ContainerChange{
+		{Name: "app", Action: optimizer.ContainerActionResize},
+		{Name: "worker", Action: optimizer.ContainerActionResize},
+	})
+
+	want := `{"app":{"cpu":"8","memory":"32Gi"},"worker":{"cpu":"2","memory":"4Gi"}}`
+	if ann[annOriginalContainers] != want {
+		t.Errorf("annotation = %s, want %s", ann[annOriginalContainers], want)
+	}
+}
+
+func TestAnalyzePod_PerContainer(t *testing.T) {
+	pod := podInfo("web-0", "default", "Deployment", "web", 3000, 5*gi)
+	pod.Pod.Spec.Containers = []corev1.Container{
+		{Name: "app", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi"),
+		}}},
+		{Name: "istio-proxy", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi"),
+		}}},
+		{Name: "cache"},
+	}
+	store := metrics.NewStore(7 * 24 * time.Hour)
+	store.RecordPodMetrics(pkgmetrics.PodMetrics{
+		Namespace: "default",
+		Name:      "web-0",
+		Timestamp: time.Now(),
+		Containers: []pkgmetrics.ContainerMetrics{
+			{Name: "app", CPUUsage: 200, MemoryUsage: gi},
+			{Name: "istio-proxy", CPUUsage: 900, MemoryUsage: 100 * mi},
+		},
+	})
+	cfg := defaultCfg()
+	cfg.Rightsizer.SidecarContainers = []string{"istio-proxy"}
+
+	analysis := NewAnalyzer(cfg, store).AnalyzePod(context.Background(), pod)
+	if len(analysis.Containers) != 3 {
+		t.Fatalf("got %d containers, want 3", len(analysis.Containers))
+	}
+	app, proxy, cache := analysis.Containers[0], analysis.Containers[1], analysis.Containers[2]
+	if app.Sidecar || !app.HasData || app.CPURequestMilli != 2000 || app.CPUP95 != 200 || !app.IsOverProvCPU {
+		t.Errorf("app = %+v", app)
+	}
+	if !proxy.Sidecar || proxy.CPUP95 != 900 || proxy.IsOverProvCPU {
+		t.Errorf("istio-proxy = %+v", proxy)
+	}
+	if cache.HasData {
+		t.Errorf("cache has data without samples: %+v", cache)
+	}
+	if analysis.CPUP95 != 1100 {
+		t.Errorf("pod CPUP95 = %d, want 1100", analysis.CPUP95)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// DaemonSets and CronJobs
+// ---------------------------------------------------------------------------
+
+func TestCronJobPodMatcher(t *testing.T) {
+	match := cronJobPodMatcher("backup")
+	tests := []struct {
+		pod  string
+		want bool
+	}{
+		{"backup-28700000-x7k2p", true},
+		{"backup-db-28700000-x7k2p", false},
+		{"backup-28700000", false},
+		{"backup-manual-x7k2p", false},
+		{"restore-28700000-x7k2p", false},
+	}
+	for _, tt := range tests {
+		if got := match(tt.pod); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.pod, got, tt.want)
+		}
+	}
+}
+
+// recordRun stores one short-lived Job pod run with a flat usage profile
+// and a single spike.
+func recordRun(store *metrics.Store, pod string, start time.Time, cpu, mem, peakCPU, peakMem int64) {
+	for i := 0; i < 5; i++ {
+		c, m := cpu, mem
+		if i == 2 {
+			c, m = peakCPU, peakMem
+		}
+		store.RecordPodMetrics(pkgmetrics.PodMetrics{
+			Namespace:  "batch",
+			Name:       pod,
+			Timestamp:  start.Add(time.Duration(i) * time.Minute),
+			Containers: []pkgmetrics.ContainerMetrics{{Name: "job", CPUUsage: c, MemoryUsage: m}},
+		})
+	}
+}
+
+func cronJob(cpu, mem string) *batchv1.CronJob {
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "batch"},
+		Spec: batchv1.CronJobSpec{
+			Schedule: "0 * * * *",
+			JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "job",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(mem),
+					}},
+				}}},
+			}}},
+		},
+	}
+}
+
+func TestAnalyzeCronJob_PeakAcrossRuns(t *testing.T) {
+	store := metrics.NewStore(7 * 24 * time.Hour)
+	now := time.Now().Add(-6 * time.Hour)
+	recordRun(store, "backup-100-aaaaa", now, 100, gi, 400, 2*gi)
+	recordRun(store, "backup-160-bbbbb", now.Add(time.Hour), 100, gi, 600, 3*gi)
+	// Another CronJob sharing the name prefix must not be counted.
+	recordRun(store, "backup-db-100-ccccc", now, 7000, 30*gi, 7000, 30*gi)
+
+	a := NewAnalyzer(defaultCfg(), store)
+	if got := a.AnalyzeCronJob(cronJob("8", "32Gi")); got != nil {
+		t.Fatalf("analysis with 2 runs = %+v, want nil below MinBatchRuns", got)
+	}
+
+	recordRun(store, "backup-220-ddddd", now.Add(2*time.Hour), 100, gi, 500, 2*gi)
+	analysis := a.AnalyzeCronJob(cronJob("8", "32Gi"))
+	if analysis == nil {
+		t.Fatal("analysis is nil with 3 runs")
+	}
+	if analysis.CPUP95 != 600 || analysis.MemP95 != 3*gi {
+		t.Errorf("P95 = %dm/%s, want the 600m/3Gi peak run", analysis.CPUP95, formatBytes(analysis.MemP95))
+	}
+	if analysis.DataPoints != 15 {
+		t.Errorf("DataPoints = %d, want 15 samples across runs", analysis.DataPoints)
+	}
+	if analysis.PodInfo.OwnerKind != "CronJob" || analysis.PodInfo.OwnerName != "backup" {
+		t.Errorf("owner = %s/%s, want CronJob/backup", analysis.PodInfo.OwnerKind, analysis.PodInfo.OwnerName)
+	}
+
+	recs := NewRecommender(defaultCfg()).Recommend(analysis)
+	if len(recs) != 1 {
+		t.Fatalf("got %d recs, want 1", len(recs))
+	}
+	assertInvariants(t, recs, analysis)
+	if recs[0].TargetKind != "CronJob" {
+		t.Errorf("TargetKind = %s, want CronJob", recs[0].TargetKind)
+	}
+}
+
+func TestMergePeak(t *testing.T) {
+	quiet := containerPodAnalysis(containerUsage("agent", 4000, 16*gi, 200, gi))
+	quiet.NodeCPUCapMilli, quiet.NodeMemCapBytes = 4000, 16*gi
+	busy := containerPodAnalysis(containerUsage("agent", 4000, 16*gi, 2000, 4*gi))
+	busy.NodeCPUCapMilli, busy.NodeMemCapBytes = 16000, 64*gi
+	busy.DataPoints = 200
+	busy.Containers[0].DataPoints = 200
+
+	merged := NewAnalyzer(defaultCfg(), nil).MergePeak([]*PodAnalysis{quiet, busy})
+	if merged.CPUP95 != 2000 || merged.MemP95 != 4*gi {
+		t.Errorf("pod P95 = %dm/%s, want busiest pod's 2000m/4Gi", merged.CPUP95, formatBytes(merged.MemP95))
+	}
+	if merged.DataPoints != 200 || merged.Containers[0].DataPoints != 200 {
+		t.Errorf("DataPoints = %d/%d, want the minimum 200", merged.DataPoints, merged.Containers[0].DataPoints)
+	}
+	if merged.NodeCPUCapMilli != 16000 {
+		t.Errorf("node capacity = %dm, want the busiest pod's node", merged.NodeCPUCapMilli)
+	}
+	if merged.Containers[0].CPUP95 != 2000 || merged.Containers[0].IsOverProvCPU {
+		t.Errorf("container = %+v, want P95 2000m and not over-provisioned", merged.Containers[0])
+	}
+	if quiet.CPUP95 != 200 || quiet.Containers[0].CPUP95 != 200 {
+		t.Error("MergePeak modified its input")
+	}
+}
+
+func TestActuator_PatchesDaemonSetAndCronJob(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "monitoring"},
+		Spec: appsv1.DaemonSetSpec{Template: cronJob("4", "16Gi").Spec.JobTemplate.Spec.Template},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ds, cronJob("8", "32Gi")).Build()
+	a := NewActuator(c, defaultCfg())
+
+	for _, rec := range []optimizer.Recommendation{
+		{TargetKind: "DaemonSet", TargetName: "agent", TargetNamespace: "monitoring",
+			Details: map[string]string{"resource": "cpu+memory", "suggestedCPURequest": "2800m", "suggestedMemRequest": "11Gi"}},
+		{TargetKind: "CronJob", TargetName: "backup", TargetNamespace: "batch",
+			Details: map[string]string{"resource": "cpu+memory", "suggestedCPURequest": "5600m", "suggestedMemRequest": "22Gi"}},
+	} {
+		if err := a.Apply(context.Background(), rec); err != nil {
+			t.Fatalf("Apply %s: %v", rec.TargetKind, err)
+		}
+	}
+
+	var gotDS appsv1.DaemonSet
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "monitoring", Name: "agent"}, &gotDS); err != nil {
+		t.Fatal(err)
+	}
+	if req := gotDS.Spec.Template.Spec.Containers[0].Resources.Requests; req.Cpu().String() != "2800m" || req.Memory().String() != "11Gi" {
+		t.Errorf("daemonset requests = %s/%s, want 2800m/11Gi", req.Cpu(), req.Memory())
+	}
+	if gotDS.Annotations[annOriginalCPU] != "4" {
+		t.Errorf("daemonset original CPU annotation = %q, want 4", gotDS.Annotations[annOriginalCPU])
+	}
+
+	var gotCJ batchv1.CronJob
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "batch", Name: "backup"}, &gotCJ); err != nil {
+		t.Fatal(err)
+	}
+	if req := gotCJ.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Resources.Requests; req.Cpu().String() != "5600m" || req.Memory().String() != "22Gi" {
+		t.Errorf("cronjob requests = %s/%s, want 5600m/22Gi", req.Cpu(), req.Memory())
+	}
+	if gotCJ.Spec.Schedule != "0 * * * *" {
+		t.Errorf("cronjob schedule = %q, patch clobbered the spec", gotCJ.Spec.Schedule)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rollback watchdog
+// ---------------------------------------------------------------------------
+
+type watchdogFixture struct {
+	w        *Watchdog
+	client   client.Client
+	audit    *state.AuditLog
+	outcomes *outcomeRecorder
+	now      time.Time
+}
+
+// outcomeRecorder records the outcomes reported to the AI Gate by
+// recommendation ID.
+type outcomeRecorder struct {
+	outcomes map[string]string
+}
+
+func (r *outcomeRecorder) RecordDecision(aigate.Decision) {}
+
+func (r *outcomeRecorder) RecordOutcome(recID, outcome, _ string, _ time.Time) {
+	r.outcomes[recID] = outcome
+}
+
+// newWatchdogFixture builds a watchdog over a Deployment "shop/api" that was
+// rightsized from 2 CPU / 4Gi to 1 CPU / 2Gi.
+func newWatchdogFixture(t *testing.T, originals bool) *watchdogFixture {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := koptv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop", Annotations: map[string]string{}},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("1"),
+					corev1.ResourceMemory: resource.MustParse("2Gi"),
+				},
+			}}},
+		}}},
+	}
+	if originals {
+		deploy.Annotations[annOriginalCPU] = "2"
+		deploy.Annotations[annOriginalMem] = "4Gi"
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deploy).
+		WithStatusSubresource(&koptv1alpha1.Recommendation{}).Build()
+
+	cfg := defaultCfg()
+	cfg.Rightsizer.Watchdog = config.RightsizingWatchdogConfig{
+		Enabled: true, Window: 24 * time.Hour, MaxRestarts: 3, MaxReadinessDropPct: 20,
+	}
+	f := &watchdogFixture{client: c, audit: state.NewAuditLog(100), now: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)}
+	f.w = NewWatchdog(c, cfg, metrics.NewStore(time.Hour), f.audit, NewOOMTracker(c, cfg), NewActuator(c, cfg))
+	f.w.now = func() time.Time { return f.now }
+	f.outcomes = &outcomeRecorder{outcomes: map[string]string{}}
+	gate := aigate.NewAIGateWithValidator(nil)
+	gate.SetRecorder(f.outcomes)
+	f.w.SetGate(gate)
+	return f
+}
+
+func watchedPod(name string, restarts int32, ready bool) optimizer.PodInfo {
+	pi := podInfo(name, "shop", "ReplicaSet", "api-7d9f8", 1000, 2*gi)
+	pi.Pod.Status = corev1.PodStatus{
+		Phase:             corev1.PodRunning,
+		ContainerStatuses: []corev1.ContainerStatus{{Name: "app", Ready: ready, RestartCount: restarts}},
+	}
+	return pi
+}
+
+var downsizeRec = optimizer.Recommendation{
+	ID:              "rightsize-combined-shop-api",
+	TargetKind:      "ReplicaSet",
+	TargetName:      "api-7d9f8",
+	TargetNamespace: "shop",
+	Details:         map[string]string{"resource": "cpu+memory"},
+}
+
+func (f *watchdogFixture) deployment(t *testing.T) *appsv1.Deployment {
+	t.Helper()
+	var d appsv1.Deployment
+	if err := f.client.Get(context.Background(), types.NamespacedName{Namespace: "shop", Name: "api"}, &d); err != nil {
+		t.Fatal(err)
+	}
+	return &d
+}
+
+func (f *watchdogFixture) rollbackRecs(t *testing.T) []koptv1alpha1.Recommendation {
+	t.Helper()
+	var list koptv1alpha1.RecommendationList
+	if err := f.client.List(context.Background(), &list); err != nil {
+		t.Fatal(err)
+	}
+	return list.Items
+}
+
+func hasAudit(log *state.AuditLog, action string) bool {
+	for _, e := range log.GetAll() {
+		if e.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWatchdog_RollsBackOnRegression(t *testing.T) {
+	tests := []struct {
+		name       string
+		after      func(now time.Time) []optimizer.PodInfo
+		advance    time.Duration
+		checks     int
+		wantSignal string
+		wantGate   string
+	}{
+		{
+			name: "OOM kill after the change",
+			after: func(now time.Time) []optimizer.PodInfo {
+				p := watchedPod("api-7d9f8-a", 1, true)
+				p.Pod.Status.ContainerStatuses[0].LastTerminationState.Terminated = &corev1.ContainerStateTerminated{
+					Reason: "OOMKilled", FinishedAt: metav1.NewTime(now.Add(-time.Minute)),
+				}
+				return []optimizer.PodInfo{p, watchedPod("api-7d9f8-b", 0, true)}
+			},
+			advance:    10 * time.Minute,
+			checks:     1,
+			wantSignal: SignalOOMKill,
+			wantGate:   aigate.OutcomeOOM,
+		},
+		{
+			name: "restart spike across pods",
+			after: func(time.Time) []optimizer.PodInfo {
+				return []optimizer.PodInfo{watchedPod("api-7d9f8-a", 2, true), watchedPod("api-7d9f8-b", 1, true)}
+			},
+			advance:    10 * time.Minute,
+			checks:     1,
+			wantSignal: SignalRestarts,
+			wantGate:   aigate.OutcomeRolledBack,
+		},
+		{
+			name: "readiness drop that persists",
+			after: func(time.Time) []optimizer.PodInfo {
+				return []optimizer.PodInfo{watchedPod("api-7d9f8-a", 0, true), watchedPod("api-7d9f8-b", 0, false)}
+			},
+			advance:    10 * time.Minute,
+			checks:     readinessChecks,
+			wantSignal: SignalReadiness,
+			wantGate:   aigate.OutcomeRolledBack,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWatchdogFixture(t, true)
+			ctx := context.Background()
+			f.w.Watch(ctx, downsizeRec, []optimizer.PodInfo{watchedPod("api-7d9f8-a", 0, true), watchedPod("api-7d9f8-b", 0, true)})
+			if got := f.w.Watched(); len(got) != 1 || got[0] != "shop/Deployment/api" {
+				t.Fatalf("watched = %v, want [shop/Deployment/api]", got)
+			}
+
+			f.now = f.now.Add(tt.advance)
+			for i := 0; i < tt.checks; i++ {
+				if i < tt.checks-1 && len(f.rollbackRecs(t)) > 0 {
+					t.Fatalf("rolled back after %d checks, want %d", i, tt.checks)
+				}
+				f.w.Check(ctx, tt.after(f.now))
+			}
+
+			d := f.deployment(t)
+			if req := d.Spec.Template.Spec.Containers[0].Resources.Requests; req.Cpu().String() != "2" || req.Memory().String() != "4Gi" {
+				t.Errorf("requests = %s/%s, want the originals 2/4Gi", req.Cpu(), req.Memory())
+			}
+			if _, ok := d.Annotations[annOriginalCPU]; ok {
+				t.Error("original CPU annotation should be removed after the rollback")
+			}
+			if !strings.HasPrefix(d.Annotations[annRightsizingBlocked], tt.wantSignal) {
+				t.Errorf("blocked annotation = %q, want a %s reason", d.Annotations[annRightsizingBlocked], tt.wantSignal)
+			}
+			if !f.w.IsBlocked(downsizeRec) || len(f.w.Watched()) != 0 {
+				t.Error("workload should be blocked and no longer watched")
+			}
+			if !hasAudit(f.audit, "rightsize-rollback") {
+				t.Error("missing rightsize-rollback audit event")
+			}
+			if got := f.outcomes.outcomes[downsizeRec.ID]; got != tt.wantGate {
+				t.Errorf("AI Gate outcome = %q, want %q", got, tt.wantGate)
+			}
+
+			recs := f.rollbackRecs(t)
+			if len(recs) != 1 {
+				t.Fatalf("rollback recommendations = %d, want 1", len(recs))
+			}
+			if recs[0].Spec.Details["resource"] != "rollback" || recs[0].Spec.Details["signal"] != tt.wantSignal {
+				t.Errorf("details = %v", recs[0].Spec.Details)
+			}
+			if recs[0].Status.State != "executed" || recs[0].Spec.TargetKind != "Deployment" {
+				t.Errorf("rollback rec state = %q target = %s, want executed Deployment", recs[0].Status.State, recs[0].Spec.TargetKind)
+			}
+		})
+	}
+}
+
+func TestWatchdog_VerifiesAfterWindow(t *testing.T) {
+	f := newWatchdogFixture(t, true)
+	ctx := context.Background()
+	pods := []optimizer.PodInfo{watchedPod("api-7d9f8-a", 4, true)}
+	f.w.Watch(ctx, downsizeRec, pods)
+
+	// Restarts from before the change and an unrelated workload's pods do
+	// not count.
+	f.now = f.now.Add(time.Hour)
+	other := podInfo("web-5c6b7-a", "shop", "ReplicaSet", "web-5c6b7", 1000, gi)
+	other.Pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", RestartCount: 10}}
+	f.w.Check(ctx, append(pods, other))
+	if len(f.w.Watched()) != 1 || len(f.rollbackRecs(t)) != 0 {
+		t.Fatal("healthy workload should still be watched and not rolled back")
+	}
+
+	f.now = f.now.Add(24 * time.Hour)
+	f.w.Check(ctx, pods)
+	if len(f.w.Watched()) != 0 {
+		t.Error("watch should end once the window has passed")
+	}
+	if !hasAudit(f.audit, "rightsize-verified") {
+		t.Error("missing rightsize-verified audit event")
+	}
+	if got := f.outcomes.outcomes[downsizeRec.ID]; got != aigate.OutcomeSuccess {
+		t.Errorf("AI Gate outcome = %q, want %q", got, aigate.OutcomeSuccess)
+	}
+	if req := f.deployment(t).Spec.Template.Spec.Containers[0].Resources.Requests; req.Cpu().String() != "1" {
+		t.Errorf("cpu = %s, healthy workload should keep its new requests", req.Cpu())
+	}
+}
+
+func TestWatchdog_FailedRollbackLeavesPendingRecommendation(t *testing.T) {
+	f := newWatchdogFixture(t, false)
+	ctx := context.Background()
+	f.w.Watch(ctx, downsizeRec, []optimizer.PodInfo{watchedPod("api-7d9f8-a", 0, true)})
+
+	f.now = f.now.Add(time.Minute)
+	f.w.Check(ctx, []optimizer.PodInfo{watchedPod("api-7d9f8-a", 3, true)})
+
+	recs := f.rollbackRecs(t)
+	if len(recs) != 1 {
+		t.Fatalf("rollback recommendations = %d, want 1", len(recs))
+	}
+	if recs[0].Status.State != "pending" || recs[0].Status.Error == "" {
+		t.Errorf("state = %q error = %q, want pending with the restore error", recs[0].Status.State, recs[0].Status.Error)
+	}
+	if !recs[0].Spec.AutoExecutable {
+		t.Error("rollback recommendation should be executable on approval")
+	}
+	if !f.w.IsBlocked(downsizeRec) {
+		t.Error("workload should be blocked even when the restore failed")
+	}
+}
+
+func TestBuildRollbackPatch(t *testing.T) {
+	containers := []corev1.Container{{Name: "app"}, {Name: "istio-proxy"}}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]map[string]string
+		wantErr     bool
+	}{
+		{
+			name:        "pod-wide originals restore the first container",
+			annotations: map[string]string{annOriginalCPU: "2", annOriginalMem: "4Gi"},
+			want:        map[string]map[string]string{"app": {"cpu": "2", "memory": "4Gi"}},
+		},
+		{
+			name: "per-container originals take precedence",
+			annotations: map[string]string{
+				annOriginalCPU:        "2",
+				annOriginalMem:        "4Gi",
+				annOriginalContainers: `{"app":{"cpu":"1500m","memory":"3Gi"},"istio-proxy":{"cpu":"200m"}}`,
+			},
+			want: map[string]map[string]string{"app": {"cpu": "1500m", "memory": "3Gi"}, "istio-proxy": {"cpu": "200m"}},
+		},
+		{name: "nothing recorded", annotations: map[string]string{}, wantErr: true},
+		{name: "invalid quantity", annotations: map[string]string{annOriginalCPU: "lots"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := buildRollbackPatch(tt.annotations, containers, "oom-kill: test")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildRollbackPatch: %v", err)
+			}
+			raw, _ := json.Marshal(patch)
+			var decoded struct {
+				Metadata struct {
+					Annotations map[string]*string `json:"annotations"`
+				} `json:"metadata"`
+				Spec struct {
+					Template struct {
+						Spec struct {
+							Containers []struct {
+								Name      string `json:"name"`
+								Resources struct {
+									Requests map[string]string `json:"requests"`
+								} `json:"resources"`
+							} `json:"containers"`
+						} `json:"spec"`
+					} `json:"template"`
+				} `json:"spec"`
+			}
+			if err := json.Unmarshal(raw, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]map[string]string{}
+			for _, c := range decoded.Spec.Template.Spec.Containers {
+				got[c.Name] = c.Resources.Requests
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("requests = %v, want %v", got, tt.want)
+			}
+			ann := decoded.Metadata.Annotations
+			if ann[annOriginalCPU] != nil || ann[annOriginalContainers] != nil {
+				t.Error("original annotations should be cleared")
+			}
+			if v := ann[annRightsizingBlocked]; v == nil || *v != "oom-kill: test" {
+				t.Errorf("blocked annotation = %v, want the reason", v)
+			}
+		})
+	}
+}
+
+func TestActuator_RefusesBlockedWorkload(t *testing.T) {
+	f := newWatchdogFixture(t, false)
+	d := f.deployment(t)
+	d.SetAnnotations(map[string]string{annRightsizingBlocked: "oom-kill: earlier rollback"})
+	if err := f.client.Update(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewActuator(f.client, defaultCfg())
+	err := a.Apply(context.Background(), optimizer.Recommendation{
+		TargetKind: "Deployment", TargetName: "api", TargetNamespace: "shop",
+		Details: map[string]string{"resource": "cpu+memory", "suggestedCPURequest": "500m", "suggestedMemRequest": "1Gi"},
+	})
+	if !errors.Is(err, ErrRightsizingBlocked) {
+		t.Fatalf("err = %v, want ErrRightsizingBlocked", err)
+	}
+	if req := f.deployment(t).Spec.Template.Spec.Containers[0].Resources.Requests; req.Cpu().String() != "1" {
+		t.Errorf("cpu = %s, blocked workload must not be patched", req.Cpu())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// CPU throttling
+// ---------------------------------------------------------------------------
+
+// recordThrottling records n scrape intervals in which container was
+// throttled in ratio of its CFS periods.
+func recordThrottling(store *metrics.Store, pod, container string, n int, ratio float64) {
+	start := time.Now().Add(-time.Duration(n+1) * time.Minute)
+	for i := 0; i <= n; i++ {
+		store.RecordThrottling([]pkgmetrics.CFSThrottling{{
+			Namespace: "default", Pod: pod, Container: container,
+			Periods:          float64(i * 600),
+			ThrottledPeriods: float64(i*600) * ratio,
+			Timestamp:        start.Add(time.Duration(i) * time.Minute),
+		}})
+	}
+}
+
+func throttledAnalysis(cpuLimit int64, ratio float64) *PodAnalysis {
+	app := containerUsage("app", 2000, 8*gi, 400, 2*gi)
+	app.CPULimitMilli = cpuLimit
+	app.ThrottledP95, app.ThrottleSamples, app.IsThrottled = ratio, 30, true
+	proxy := containerUsage("istio-proxy", 500, gi, 100, 200*mi)
+	proxy.CPULimitMilli = 500
+	proxy.Sidecar = true
+	proxy.ThrottledP95, proxy.ThrottleSamples, proxy.IsThrottled = ratio, 30, true
+
+	a := containerPodAnalysis(app, proxy)
+	a.CPULimitMilli = cpuLimit + 500
+	a.ThrottledP95, a.IsThrottled = ratio, true
+	return a
+}
+
+func TestAnalyzePod_Throttling(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		samples   int
+		ratio     float64
+		throttled bool
+	}{
+		{"throttled above threshold", true, 10, 0.5, true},
+		{"below threshold", true, 10, 0.1, false},
+		{"too few samples", true, MinThrottleSamples - 1, 0.5, false},
+		{"disabled", false, 10, 0.5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := podInfo("web-0", "default", "Deployment", "web", 2000, 4*gi)
+			pod.CPULimit = 1000
+			pod.Pod.Spec.Containers = []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			}}}
+			store := metrics.NewStore(7 * 24 * time.Hour)
+			store.RecordPodMetrics(pkgmetrics.PodMetrics{
+				Namespace: "default", Name: "web-0", Timestamp: time.Now(),
+				Containers: []pkgmetrics.ContainerMetrics{{Name: "app", CPUUsage: 200, MemoryUsage: gi}},
+			})
+			recordThrottling(store, "web-0", "app", tt.samples, tt.ratio)
+
+			cfg := defaultCfg()
+			cfg.Rightsizer.Throttling = config.RightsizingThrottlingConfig{Enabled: tt.enabled, ThrottledPct: 25}
+			analysis := NewAnalyzer(cfg, store).AnalyzePod(context.Background(), pod)
+
+			app := analysis.Containers[0]
+			if app.IsThrottled != tt.throttled || analysis.IsThrottled != tt.throttled {
+				t.Errorf("IsThrottled container/pod = %v/%v, want %v", app.IsThrottled, analysis.IsThrottled, tt.throttled)
+			}
+			if tt.enabled && app.ThrottleSamples != tt.samples {
+				t.Errorf("ThrottleSamples = %d, want %d", app.ThrottleSamples, tt.samples)
+			}
+			if app.CPULimitMilli != 1000 {
+				t.Errorf("CPULimitMilli = %d, want 1000", app.CPULimitMilli)
+			}
+		})
+	}
+}
+
+func TestRecommend_ThrottledWorkload(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        string
+		sidecarPolicy string
+		wantActions   map[string]string // container → action
+	}{
+		{"raise by default", "", "", map[string]string{"app": cpuLimitActionRaise}},
+		{"remove", CPULimitPolicyRemove, "", map[string]string{"app": cpuLimitActionRemove}},
+		{"off", CPULimitPolicyOff, "", map[string]string{}},
+		{"separate sidecars", CPULimitPolicyRaise, SidecarPolicySeparate, map[string]string{"app": cpuLimitActionRaise, "istio-proxy": cpuLimitActionRaise}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultCfg()
+			cfg.Rightsizer.Throttling.CPULimitPolicy = tt.policy
+			cfg.Rightsizer.SidecarPolicy = tt.sidecarPolicy
+			analysis := throttledAnalysis(1000, 0.5)
+			recs := NewRecommender(cfg).Recommend(analysis)
+
+			actions := map[string]string{}
+			var upsizes int
+			for _, rec := range recs {
+				if rec.AutoExecutable {
+					t.Errorf("%s: throttling recs MUST NOT be auto-executable", rec.ID)
+				}
+				switch {
+				case isDownsizeRec(rec):
+					t.Errorf("throttled workload got a downsize: %s", rec.Summary)
+				case rec.Details["direction"] == "upsize":
+					upsizes++
+				case rec.Details["resource"] == "cpu-limit":
+					actions[rec.Details["container"]] = rec.Details["action"]
+					if rec.Details["action"] == cpuLimitActionRaise && rec.Details["suggestedCPULimit"] == "" {
+						t.Errorf("raise rec without suggestedCPULimit: %v", rec.Details)
+					}
+				default:
+					t.Errorf("unexpected rec: %v", rec.Details)
+				}
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("cpu-limit actions = %v, want %v", actions, tt.wantActions)
+			}
+			// P95 of 500m across the pod is far below the 2500m request, so
+			// there is nothing to raise the request to.
+			if upsizes != 0 {
+				t.Errorf("got %d upsize recs, want 0", upsizes)
+			}
+		})
+	}
+}
+
+func TestRecommend_ThrottledUpsize(t *testing.T) {
+	// Throttled at the limit, P95 usage is pinned close to it: the request
+	// is raised even though the limit would otherwise count as headroom.
+	analysis := &PodAnalysis{
+		PodInfo:         podInfo("a", "prod", "Deployment", "api", 500, 4*gi),
+		CPURequestMilli: 500, MemRequestBytes: 4 * gi, CPULimitMilli: 1000,
+		CPUP95: 650, MemP95: 2 * gi,
+		IsThrottled: true, ThrottledP95: 0.4,
+		DataPoints: 500,
+	}
+	recs := NewRecommender(defaultCfg()).Recommend(analysis)
+	if len(recs) != 1 {
+		t.Fatalf("got %d recs, want 1 upsize", len(recs))
+	}
+	rec := recs[0]
+	if rec.Details["direction"] != "upsize" || rec.Details["resource"] != "cpu" {
+		t.Fatalf("unexpected rec details: %v", rec.Details)
+	}
+	if rec.Details["suggestedRequest"] != "780m" {
+		t.Errorf("suggestedRequest = %q, want 780m", rec.Details["suggestedRequest"])
+	}
+	if rec.Details["throttledPct"] != "40" {
+		t.Errorf("throttledPct = %q, want 40", rec.Details["throttledPct"])
+	}
+}
+
+func TestRaisedCPULimit(t *testing.T) {
+	tests := []struct {
+		limit int64
+		ratio float64
+		want  int64
+	}{
+		{1000, 0.5, 2000},
+		{1000, 0.25, 1340}, // 1333.3m rounded up to 10m
+		{1000, 0.9, 2000},   // capped at MaxCPULimitRaise
+		{1000, 0.001, 1010}, // always raised by at least 10m
+		{250, 0.3, 360},
+	}
+	for _, tt := range tests {
+		got := raisedCPULimit(ContainerAnalysis{CPULimitMilli: tt.limit, ThrottledP95: tt.ratio})
+		if got != tt.want {
+			t.Errorf("raisedCPULimit(%dm, %.3f) = %dm, want %dm", tt.limit, tt.ratio, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCPULimitPatch(t *testing.T) {
+	containers := []corev1.Container{
+		{Name: "app", Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+		}},
+	}
+	tests := []struct {
+		name      string
+		container string
+		action    string
+		value     string
+		want      interface{}
+		wantErr   bool
+	}{
+		{name: "raise", container: "app", action: cpuLimitActionRaise, value: "1500m", want: "1500m"},
+		{name: "remove", container: "app", action: cpuLimitActionRemove, want: nil},
+		{name: "not a raise", container: "app", action: cpuLimitActionRaise, value: "800m", wantErr: true},
+		{name: "invalid quantity", container: "app", action: cpuLimitActionRaise, value: "lots", wantErr: true},
+		{name: "unknown container", container: "db", action: cpuLimitActionRemove, wantErr: true},
+		{name: "unknown action", container: "app", action: "lower", value: "1500m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := buildCPULimitPatch(containers, tt.container, tt.action, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			spec := patch["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+			c := spec["containers"].([]map[string]interface{})[0]
+			limits := c["resources"].(map[string]interface{})["limits"].(map[string]interface{})
+			if got, ok := limits["cpu"]; !ok || got != tt.want {
+				t.Errorf("limits.cpu = %v (present %v), want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestActuator_AppliesCPULimit(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			}}},
+		}}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(d).Build()
+
+	err := NewActuator(c, defaultCfg()).Apply(context.Background(), optimizer.Recommendation{
+		TargetKind: "Deployment", TargetName: "web", TargetNamespace: "default",
+		Details: map[string]string{
+			"resource": "cpu-limit", "container": "app",
+			"action": cpuLimitActionRaise, "suggestedCPULimit": "2",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	got := &appsv1.Deployment{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "web"}, got); err != nil {
+		t.Fatal(err)
+	}
+	res := got.Spec.Template.Spec.Containers[0].Resources
+	if res.Limits.Cpu().MilliValue() != 2000 || res.Requests.Cpu().MilliValue() != 500 {
+		t.Errorf("resources = %v, want limit 2 with request 500m unchanged", res)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rightsizing policies
+// ---------------------------------------------------------------------------
+
+func rightsizingPolicy(name string, priority int32, spec koptv1alpha1.RightsizingPolicySpec) *koptv1alpha1.RightsizingPolicy {
+	spec.Priority = priority
+	return &koptv1alpha1.RightsizingPolicy{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+}
+
+func TestPolicyResolver_Resolve(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := koptv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		rightsizingPolicy("batch", 0, koptv1alpha1.RightsizingPolicySpec{
+			Namespaces: []string{"batch"}, CPUTargetUtilPct: 99,
+		}),
+		rightsizingPolicy("jvm", 10, koptv1alpha1.RightsizingPolicySpec{
+			Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"runtime": "jvm"}},
+			Percentile: 99, MemoryTargetUtilPct: 70, MinMemory: "2Gi", Mode: PolicyModeAuto,
+		}),
+		rightsizingPolicy("jvm-low", 1, koptv1alpha1.RightsizingPolicySpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"runtime": "jvm"}},
+			Mode:     PolicyModeOff,
+		}),
+	).Build()
+	cfg := defaultCfg()
+	cfg.Rightsizer.OOMBumpMultiplier = 2.5
+	r := NewPolicyResolver(c, cfg)
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	pod := func(ns string, lbls, ann map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: ns, Labels: lbls, Annotations: ann}}
+	}
+	jvm := map[string]string{"runtime": "jvm"}
+
+	tests := []struct {
+		name   string
+		pod    *corev1.Pod
+		source string
+		check  func(t *testing.T, p *Policy)
+	}{
+		{
+			name:   "no match keeps the global config",
+			pod:    pod("web", nil, nil),
+			source: "default",
+			check: func(t *testing.T, p *Policy) {
+				if p.Mode != PolicyModeRecommend || p.Percentile != 95 || p.CPUTargetUtilPct != 95 || p.MinKeepRatio != 0.7 {
+					t.Errorf("policy = %+v, want the global defaults", p)
+				}
+			},
+		},
+		{
+			name:   "namespace scope",
+			pod:    pod("batch", nil, nil),
+			source: "RightsizingPolicy/batch",
+			check: func(t *testing.T, p *Policy) {
+				if p.CPUTargetUtilPct != 99 || p.MemoryTargetUtilPct != 95 {
+					t.Errorf("targets = %.0f/%.0f, want 99/95", p.CPUTargetUtilPct, p.MemoryTargetUtilPct)
+				}
+			},
+		},
+		{
+			name:   "highest priority selector wins over namespace",
+			pod:    pod("batch", jvm, nil),
+			source: "RightsizingPolicy/jvm",
+			check: func(t *testing.T, p *Policy) {
+				if p.Mode != PolicyModeAuto || p.Percentile != 99 || p.MinMemBytes != 2*gi || p.CPUTargetUtilPct != 95 {
+					t.Errorf("policy = %+v", p)
+				}
+			},
+		},
+		{
+			name: "annotations override the policy",
+			pod: pod("web", jvm, map[string]string{
+				RightsizingModeAnnotation:       PolicyModeRecommend,
+				RightsizingMaxCPUAnnotation:     "1500m",
+				RightsizingOOMBumpAnnotation:    "1.5",
+				RightsizingPercentileAnnotation: "75", // invalid: ignored
+			}),
+			source: "RightsizingPolicy/jvm+annotations",
+			check: func(t *testing.T, p *Policy) {
+				if p.Mode != PolicyModeRecommend || p.MaxCPUMilli != 1500 || p.OOMBumpMultiplier != 1.5 || p.Percentile != 99 {
+					t.Errorf("policy = %+v", p)
+				}
+			},
+		},
+		{
+			name:   "annotations alone",
+			pod:    pod("web", nil, map[string]string{RightsizingCPULimitPolicyAnnotation: CPULimitPolicyRemove}),
+			source: "annotations",
+			check: func(t *testing.T, p *Policy) {
+				if p.CPULimitPolicy != CPULimitPolicyRemove {
+					t.Errorf("CPULimitPolicy = %q, want remove", p.CPULimitPolicy)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := r.Resolve(tt.pod)
+			if p.Source != tt.source {
+				t.Errorf("Source = %q, want %q", p.Source, tt.source)
+			}
+			tt.check(t, p)
+		})
+	}
+}
+
+func TestRecommend_Policy(t *testing.T) {
+	analysis := func(p *Policy) *PodAnalysis {
+		return &PodAnalysis{
+			PodInfo:         podInfo("svc-pod", "staging", "StatefulSet", "my-svc", 4000, 16*gi),
+			CPURequestMilli: 4000, MemRequestBytes: 16 * gi,
+			CPUP95: 200, CPUP99: 2000, MemP95: gi, MemP99: gi,
+			IsOverProvCPU: true,
+			DataPoints:    1000,
+			Policy:        p,
+		}
+	}
+	policy := func(mutate func(p *Policy)) *Policy {
+		p := defaultPolicy(defaultCfg())
+		p.Source = "RightsizingPolicy/test"
+		mutate(p)
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		policy   *Policy
+		wantRecs int
+		wantAuto bool
+		wantCPU  string
+		wantMem  string
+	}{
+		{name: "default", policy: nil, wantRecs: 1, wantCPU: "2800m", wantMem: "11468Mi"},
+		{name: "off", policy: policy(func(p *Policy) { p.Mode = PolicyModeOff })},
+		{name: "auto", policy: policy(func(p *Policy) { p.Mode = PolicyModeAuto }), wantRecs: 1, wantAuto: true, wantCPU: "2800m"},
+		{
+			name:     "p99 sizing",
+			policy:   policy(func(p *Policy) { p.Percentile = 99; p.MinKeepRatio = 0.3 }),
+			wantRecs: 1, wantCPU: "2400m",
+		},
+		{
+			name:     "max CPU bound",
+			policy:   policy(func(p *Policy) { p.Percentile = 99; p.MinKeepRatio = 0.3; p.MaxCPUMilli = 1500 }),
+			wantRecs: 1, wantCPU: "1500m",
+		},
+		{
+			name:     "min memory bound",
+			policy:   policy(func(p *Policy) { p.MinMemBytes = 14 * gi }),
+			wantRecs: 1, wantCPU: "2800m", wantMem: "14Gi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := analysis(tt.policy)
+			recs := NewRecommender(defaultCfg()).Recommend(a)
+			if len(recs) != tt.wantRecs {
+				t.Fatalf("got %d recs, want %d", len(recs), tt.wantRecs)
+			}
+			if tt.wantRecs == 0 {
+				return
+			}
+			assertInvariants(t, recs, a)
+			rec := recs[0]
+			if rec.AutoExecutable != tt.wantAuto {
+				t.Errorf("AutoExecutable = %v, want %v", rec.AutoExecutable, tt.wantAuto)
+			}
+			wantSource := "default"
+			if tt.policy != nil {
+				wantSource = tt.policy.Source
+			}
+			if rec.Details["policy"] != wantSource {
+				t.Errorf("Details[policy] = %q, want %q", rec.Details["policy"], wantSource)
+			}
+			if rec.Details["suggestedCPURequest"] != tt.wantCPU {
+				t.Errorf("suggestedCPURequest = %q, want %q", rec.Details["suggestedCPURequest"], tt.wantCPU)
+			}
+			if tt.wantMem != "" && rec.Details["suggestedMemRequest"] != tt.wantMem {
+				t.Errorf("suggestedMemRequest = %q, want %q", rec.Details["suggestedMemRequest"], tt.wantMem)
+			}
+		})
+	}
+}
+
+func TestAnalyzePod_PolicyTargetUtil(t *testing.T) {
+	// 40% CPU utilization is over-provisioned against a 95% target (half
+	// of it is 47.5%) but not against an annotated 70% target.
+	pod := podInfo("web-0", "default", "Deployment", "web", 1000, gi)
+	pod.CPUUsage, pod.MemoryUsage = 400, gi/2
+	if a := NewAnalyzer(defaultCfg(), nil).AnalyzePod(context.Background(), pod); !a.IsOverProvCPU || a.Policy.Source != "default" {
+		t.Fatalf("IsOverProvCPU = %v, source %q; want true, default", a.IsOverProvCPU, a.Policy.Source)
+	}
+
+	pod.Pod.Annotations = map[string]string{RightsizingCPUTargetAnnotation: "70"}
+	a := NewAnalyzer(defaultCfg(), nil).AnalyzePod(context.Background(), pod)
+	if a.IsOverProvCPU || a.Policy.Source != "annotations" {
+		t.Errorf("IsOverProvCPU = %v, source %q; want false, annotations", a.IsOverProvCPU, a.Policy.Source)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Limits
+// ---------------------------------------------------------------------------
+
+func TestDownsizedCPULimit(t *testing.T) {
+	c := containerUsage("app", 4000, 16*gi, 500, 2*gi)
+	c.CPULimitMilli = 8000
+	tests := []struct {
+		name        string
+		policy      string
+		multiplier  float64
+		limit       int64
+		request     int64
+		wantLimit   int64
+		wantRemove  bool
+		wantChanged bool
+	}{
+		{name: "keep ratio", policy: DownsizeCPULimitKeepRatio, limit: 8000, request: 2800, wantLimit: 5600, wantChanged: true},
+		{name: "multiplier", policy: DownsizeCPULimitMultiplier, multiplier: 1.5, limit: 8000, request: 2800, wantLimit: 4200, wantChanged: true},
+		{name: "multiplier never below request", policy: DownsizeCPULimitMultiplier, multiplier: 1, limit: 8000, request: 2805, wantLimit: 2810, wantChanged: true},
+		{name: "remove", policy: DownsizeCPULimitRemove, limit: 8000, request: 2800, wantRemove: true, wantChanged: true},
+		{name: "off", policy: DownsizeCPULimitOff, limit: 8000, request: 2800, wantLimit: 8000},
+		{name: "no limit is never given one", policy: DownsizeCPULimitMultiplier, multiplier: 2, request: 2800},
+		{name: "request not lowered", policy: DownsizeCPULimitKeepRatio, limit: 8000, request: 4000, wantLimit: 8000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.CPULimitMilli = tt.limit
+			p := &Policy{DownsizeCPULimit: tt.policy, CPULimitMultiplier: tt.multiplier}
+			limit, remove, changed := downsizedCPULimit(c, tt.request, p)
+			if limit != tt.wantLimit || remove != tt.wantRemove || changed != tt.wantChanged {
+				t.Errorf("downsizedCPULimit() = (%d, %v, %v), want (%d, %v, %v)",
+					limit, remove, changed, tt.wantLimit, tt.wantRemove, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestMemLimitTarget(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		limit      int64
+		request    int64
+		peak       int64
+		dataPoints int
+		oomAgo     time.Duration // 0 = never OOM killed
+		bump       float64
+		want       int64
+	}{
+		{name: "lowered to peak plus headroom", limit: 16 * gi, request: 4 * gi, peak: 4 * gi, dataPoints: 2000, want: 5 * gi},
+		{name: "never below the request", limit: 16 * gi, request: 8 * gi, peak: 4 * gi, dataPoints: 2000, want: 8 * gi},
+		{name: "too few samples to lower", limit: 16 * gi, request: 4 * gi, peak: 4 * gi, dataPoints: 100},
+		{name: "change too small", limit: 5632 * mi, request: 4 * gi, peak: 4 * gi, dataPoints: 2000},
+		{name: "raised when peak nears the limit", limit: 4 * gi, request: 2 * gi, peak: 4000 * mi, dataPoints: 100, want: 5000 * mi},
+		{name: "recent OOM raises by the bump", limit: 4 * gi, request: 2 * gi, peak: 4 * gi, dataPoints: 100, oomAgo: time.Hour, bump: 2, want: 8 * gi},
+		{name: "recent OOM never lowers", limit: 16 * gi, request: 4 * gi, peak: 4 * gi, dataPoints: 2000, oomAgo: time.Hour, bump: 1},
+		{name: "old OOM no longer counts", limit: 16 * gi, request: 4 * gi, peak: 4 * gi, dataPoints: 2000, oomAgo: 8 * 24 * time.Hour, bump: 2, want: 5 * gi},
+		{name: "no limit", request: 4 * gi, peak: 4 * gi, dataPoints: 2000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ContainerAnalysis{
+				Name: "app", HasData: true,
+				MemRequestBytes: tt.request, MemLimitBytes: tt.limit,
+				MemMax: tt.peak, DataPoints: tt.dataPoints,
+			}
+			if tt.oomAgo > 0 {
+				c.LastOOMKill = now.Add(-tt.oomAgo)
+			}
+			if got := memLimitTarget(c, 25, tt.bump, now); got != tt.want {
+				t.Errorf("memLimitTarget() = %s, want %s", formatBytes(got), formatBytes(tt.want))
+			}
+		})
+	}
+}
+
+func TestRecommend_DownsizeCPULimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		limitRange *LimitRangeConstraints
+		wantRecs   int
+		wantLimit  string
+		wantRemove bool
+	}{
+		{name: "keep ratio", policy: DownsizeCPULimitKeepRatio, wantRecs: 1, wantLimit: "5600m"},
+		{name: "remove", policy: DownsizeCPULimitRemove, wantRecs: 1, wantRemove: true},
+		{name: "off", policy: DownsizeCPULimitOff, wantRecs: 1},
+		{
+			name:       "keep ratio satisfies maxLimitRequestRatio",
+			policy:     DownsizeCPULimitKeepRatio,
+			limitRange: &LimitRangeConstraints{CPU: LimitBounds{MaxLimitRequestRatio: 2}},
+			wantRecs:   1, wantLimit: "5600m",
+		},
+		{
+			name:       "stale limit would break maxLimitRequestRatio",
+			policy:     DownsizeCPULimitOff,
+			limitRange: &LimitRangeConstraints{CPU: LimitBounds{MaxLimitRequestRatio: 2}},
+		},
+		{
+			name:       "request below LimitRange minimum",
+			policy:     DownsizeCPULimitKeepRatio,
+			limitRange: &LimitRangeConstraints{CPU: LimitBounds{Min: 3000}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultCfg()
+			cfg.Rightsizer.Limits.DownsizeCPULimit = tt.policy
+			app := containerUsage("app", 4000, 16*gi, 500, 2*gi)
+			app.CPULimitMilli = 8000
+			analysis := containerPodAnalysis(app)
+			analysis.LimitRange = tt.limitRange
+
+			recs := NewRecommender(cfg).Recommend(analysis)
+			if len(recs) != tt.wantRecs {
+				t.Fatalf("got %d recs, want %d", len(recs), tt.wantRecs)
+			}
+			if tt.wantRecs == 0 {
+				return
+			}
+			assertInvariants(t, recs, analysis)
+			ch := decodeChanges(t, recs[0])["app"]
+			if ch.SuggestedCPURequest != "2800m" || ch.CurrentCPULimit != "8000m" {
+				t.Errorf("request %s, current limit %s; want 2800m and 8000m", ch.SuggestedCPURequest, ch.CurrentCPULimit)
+			}
+			if ch.SuggestedCPULimit != tt.wantLimit || ch.RemoveCPULimit != tt.wantRemove {
+				t.Errorf("CPU limit = %q (remove %v), want %q (remove %v)", ch.SuggestedCPULimit, ch.RemoveCPULimit, tt.wantLimit, tt.wantRemove)
+			}
+		})
+	}
+}
+
+func TestRecommend_MemoryLimit(t *testing.T) {
+	app := containerUsage("app", 2000, 8*gi, 1900, 3*gi)
+	app.MemLimitBytes, app.MemMax, app.DataPoints = 16*gi, 4*gi, 2000
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		oom        bool
+		limitRange *LimitRangeConstraints
+		want       string
+		priority   optimizer.Priority
+	}{
+		{name: "lowered to the request", enabled: true, want: "8Gi", priority: optimizer.PriorityLow},
+		{name: "raised after an OOM kill", enabled: true, oom: true, want: "40Gi", priority: optimizer.PriorityHigh},
+		{name: "disabled", enabled: false},
+		{
+			name: "below the LimitRange minimum", enabled: true,
+			limitRange: &LimitRangeConstraints{Memory: LimitBounds{Min: 10 * gi}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultCfg()
+			cfg.Rightsizer.OOMBumpMultiplier = 2.5
+			cfg.Rightsizer.Limits.MemoryLimits = tt.enabled
+			cfg.Rightsizer.Limits.MemoryHeadroomPct = 25
+			c := app
+			if tt.oom {
+				c.LastOOMKill = time.Now().Add(-time.Hour)
+			}
+			analysis := containerPodAnalysis(c)
+			analysis.IsOverProvCPU = false
+			analysis.LimitRange = tt.limitRange
+
+			recs := NewRecommender(cfg).Recommend(analysis)
+			if tt.want == "" {
+				if len(recs) != 0 {
+					t.Fatalf("got %d recs, want none: %v", len(recs), recs[0].Summary)
+				}
+				return
+			}
+			if len(recs) != 1 {
+				t.Fatalf("got %d recs, want 1", len(recs))
+			}
+			rec := recs[0]
+			if rec.Details["resource"] != "memory-limit" || rec.Details["container"] != "app" || rec.Details["action"] != memLimitActionSet {
+				t.Errorf("details = %v, want a memory-limit set for app", rec.Details)
+			}
+			if rec.Details["currentMemLimit"] != "16Gi" || rec.Details["suggestedMemLimit"] != tt.want {
+				t.Errorf("limit %s → %s, want 16Gi → %s", rec.Details["currentMemLimit"], rec.Details["suggestedMemLimit"], tt.want)
+			}
+			if rec.Priority != tt.priority || rec.AutoExecutable {
+				t.Errorf("priority %v, auto %v; want %v and not auto-executable", rec.Priority, rec.AutoExecutable, tt.priority)
+			}
+			if _, ok := rec.Details["lastOOMKill"]; ok != tt.oom {
+				t.Errorf("lastOOMKill present = %v, want %v", ok, tt.oom)
+			}
+		})
+	}
+}
+
+func TestLimitRangeConstraints(t *testing.T) {
+	ranges := []corev1.LimitRange{
+		{Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{
+			{
+				Type:    corev1.LimitTypeContainer,
+				Min:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("64Mi")},
+				Max:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+				Default: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+			// Pod items bound the pod total and are not checked.
+			{Type: corev1.LimitTypePod, Max: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+		}}},
+		{Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+			Type:                 corev1.LimitTypeContainer,
+			Min:                  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+			Max:                  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")},
+			MaxLimitRequestRatio: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2")},
+		}}}},
+	}
+	lr := limitRangeConstraints(ranges)
+	want := &LimitRangeConstraints{
+		CPU:    LimitBounds{Min: 200, Max: 4000, DefaultLimit: true},
+		Memory: LimitBounds{Min: 64 * mi, MaxLimitRequestRatio: 2},
+	}
+	if !reflect.DeepEqual(lr, want) {
+		t.Fatalf("limitRangeConstraints() = %+v, want the tightest bounds %+v", lr, want)
+	}
+	if got := limitRangeConstraints(ranges[:0]); got != nil {
+		t.Errorf("no LimitRanges = %+v, want nil", got)
+	}
+
+	tests := []struct {
+		name    string
+		c       ContainerResources
+		wantErr string
+	}{
+		{name: "within bounds", c: ContainerResources{CPURequestMilli: 500, CPULimitMilli: 1000, MemRequestBytes: gi, MemLimitBytes: 2 * gi}},
+		{name: "default fills a missing CPU limit", c: ContainerResources{CPURequestMilli: 500, MemRequestBytes: gi, MemLimitBytes: gi}},
+		{name: "CPU request below min", c: ContainerResources{CPURequestMilli: 150, MemRequestBytes: gi, MemLimitBytes: gi}, wantErr: "cpu request 150m is below"},
+		{name: "CPU limit above max", c: ContainerResources{CPURequestMilli: 500, CPULimitMilli: 5000, MemRequestBytes: gi, MemLimitBytes: gi}, wantErr: "cpu limit 5000m is above"},
+		{name: "memory ratio", c: ContainerResources{CPURequestMilli: 500, MemRequestBytes: gi, MemLimitBytes: 3 * gi}, wantErr: "ratio 3.00"},
+		{name: "ratio needs a memory limit", c: ContainerResources{CPURequestMilli: 500, MemRequestBytes: gi}, wantErr: "no memory limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.Name = "app"
+			err := lr.Check(tt.c)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Check() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	var none *LimitRangeConstraints
+	if err := none.Check(ContainerResources{CPURequestMilli: 1}); err != nil {
+		t.Errorf("nil constraints: Check() = %v, want nil", err)
+	}
+}
+
+func TestContainerRequestPatches_CPULimit(t *testing.T) {
+	containers := []corev1.Container{
+		{Name: "app", Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("16Gi")},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8"), corev1.ResourceMemory: resource.MustParse("16Gi")},
+		}},
+		{Name: "worker", Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+		}},
+	}
+	changes := []optimizer.ContainerChange{
+		{Name: "app", Action: optimizer.ContainerActionResize, SuggestedCPURequest: "2800m", SuggestedMemRequest: "12Gi", SuggestedCPULimit: "5600m"},
+		{Name: "worker", Action: optimizer.ContainerActionResize, SuggestedCPURequest: "1400m", SuggestedMemRequest: "4Gi", RemoveCPULimit: true},
+	}
+
+	got := patchedResources(containers, containerRequestPatches(containers, changes))
+	want := []ContainerResources{
+		{Name: "app", CPURequestMilli: 2800, CPULimitMilli: 5600, MemRequestBytes: 12 * gi, MemLimitBytes: 16 * gi},
+		{Name: "worker", CPURequestMilli: 1400, MemRequestBytes: 4 * gi},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("patched resources = %+v, want %+v", got, want)
+	}
+
+	// The original limits are recorded and restored by a rollback.
+	annotations := map[string]string{}
+	recordOriginalContainerResources(annotations, containers, changes)
+	rollback, err := buildRollbackPatch(annotations, containers, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := patchedResources(containers, templateContainerPatches(rollback))
+	for i, c := range containers {
+		if want := containerResources(c.Name, c.Resources); restored[i] != want {
+			t.Errorf("rollback of %s = %+v, want %+v", c.Name, restored[i], want)
+		}
+	}
+}
+
+func TestActuator_LimitRanges(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+			}}},
+		}}},
+	}
+	lr := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "limits", Namespace: "default"},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+			Type:    corev1.LimitTypeContainer,
+			Max:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1500m"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+			Default: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+		}}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(d, lr).Build()
+	a := NewActuator(c, defaultCfg())
+	ctx := context.Background()
+
+	rec := func(details map[string]string) optimizer.Recommendation {
+		return optimizer.Recommendation{TargetKind: "Deployment", TargetName: "web", TargetNamespace: "default", Details: details}
+	}
+	current := func() corev1.ResourceRequirements {
+		got := &appsv1.Deployment{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, got); err != nil {
+			t.Fatal(err)
+		}
+		return got.Spec.Template.Spec.Containers[0].Resources
+	}
+
+	err := a.Apply(ctx, rec(map[string]string{
+		"resource": "cpu-limit", "container": "app", "action": cpuLimitActionRaise, "suggestedCPULimit": "2",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "LimitRange") {
+		t.Fatalf("raising the CPU limit above the LimitRange max: err = %v, want a LimitRange error", err)
+	}
+	if res := current(); res.Limits.Cpu().MilliValue() != 1000 {
+		t.Errorf("CPU limit = %s after a rejected patch, want 1", res.Limits.Cpu())
+	}
+
+	err = a.Apply(ctx, rec(map[string]string{
+		"resource": "memory-limit", "container": "app", "action": memLimitActionSet, "suggestedMemLimit": "3Gi",
+	}))
+	if err != nil {
+		t.Fatalf("Apply memory limit: %v", err)
+	}
+	if res := current(); res.Limits.Memory().Value() != 3*gi || res.Requests.Memory().Value() != gi {
+		t.Errorf("resources = %v, want memory limit 3Gi with request 1Gi unchanged", res)
+	}
+
+	err = a.Apply(ctx, rec(map[string]string{
+		"resource": "memory-limit", "container": "app", "action": memLimitActionSet, "suggestedMemLimit": "512Mi",
+	}))
+	if err == nil {
+		t.Error("memory limit below the request: want an error")
+	}
+}
+
+func TestOOMTracker_LastKilled(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	oomPod := func(name, owner string, finished time.Time) optimizer.PodInfo {
+		pi := podInfo(name, "default", "Deployment", owner, 1000, gi)
+		pi.Pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{Name: "app", LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason: "OOMKilled", FinishedAt: metav1.NewTime(finished),
+			}}},
+			{Name: "istio-proxy"},
+		}
+		return pi
+	}
+
+	tracker := NewOOMTracker(nil, defaultCfg())
+	if _, err := tracker.Analyze(context.Background(), []optimizer.PodInfo{
+		oomPod("web-1", "web", now.Add(-2*time.Hour)),
+		oomPod("web-2", "web", now.Add(-time.Hour)),
+		oomPod("batch-1", "batch", now.Add(-8*24*time.Hour)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := tracker.LastKilled("default", "Deployment", "web", "app"); !got.Equal(now.Add(-time.Hour)) {
+		t.Errorf("LastKilled(app) = %v, want the latest kill across replicas %v", got, now.Add(-time.Hour))
+	}
+	if got := tracker.LastKilled("default", "Deployment", "web", "istio-proxy"); !got.IsZero() {
+		t.Errorf("LastKilled(istio-proxy) = %v, want zero", got)
+	}
+	if got := tracker.LastKilled("default", "Deployment", "batch", "app"); !got.IsZero() {
+		t.Errorf("LastKilled(batch) = %v, want zero for a kill older than OOMHistoryWindow", got)
+	}
+
+	// The history outlives the pods.
+	if _, err := tracker.Analyze(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Cleanup()
+	if got := tracker.LastKilled("default", "Deployment", "web", "app"); got.IsZero() {
+		t.Error("LastKilled(app) forgot the kill once the pods were gone")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rollouts: maintenance windows, namespace budget, next rollout
+// ---------------------------------------------------------------------------
+
+func TestInMaintenanceWindow(t *testing.T) {
+	nightly := config.MaintenanceWindow{Schedule: "0 2 * * *", Duration: 3 * time.Hour, Timezone: "Europe/Berlin"}
+	weekend := config.MaintenanceWindow{Schedule: "0 22 * * FRI", Duration: 48 * time.Hour}
+	// Berlin is UTC+1 in January; 2026-01-14 is a Wednesday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		windows []config.MaintenanceWindow
+		now     time.Time
+		want    bool
+	}{
+		{name: "no windows", now: at(14, 12, 0), want: true},
+		{name: "before the window", windows: []config.MaintenanceWindow{nightly}, now: at(14, 0, 30), want: false},
+		{name: "window opens in its timezone", windows: []config.MaintenanceWindow{nightly}, now: at(14, 1, 0), want: true},
+		{name: "last minute of the window", windows: []config.MaintenanceWindow{nightly}, now: at(14, 3, 59), want: true},
+		{name: "window closed", windows: []config.MaintenanceWindow{nightly}, now: at(14, 4, 0), want: false},
+		{name: "weekend window on Sunday", windows: []config.MaintenanceWindow{weekend}, now: at(18, 12, 0), want: true},
+		{name: "weekend window on Wednesday", windows: []config.MaintenanceWindow{weekend}, now: at(14, 12, 0), want: false},
+		{name: "any window matches", windows: []config.MaintenanceWindow{weekend, nightly}, now: at(14, 2, 0), want: true},
+		{name: "invalid schedule never matches", windows: []config.MaintenanceWindow{{Schedule: "nightly", Duration: 24 * time.Hour}}, now: at(14, 2, 0), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inMaintenanceWindow(tt.windows, tt.now); got != tt.want {
+				t.Errorf("inMaintenanceWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartBudget(t *testing.T) {
+	now := time.Date(2026, time.January, 14, 12, 0, 0, 0, time.UTC)
+	b := restartBudget{}
+	b.record("payments", now.Add(-23*time.Hour))
+	b.record("payments", now.Add(-time.Hour))
+
+	if b.allow("payments", 2, now) {
+		t.Error("allow(payments) = true with 2 of 2 restarts used")
+	}
+	if !b.allow("payments", 3, now) {
+		t.Error("allow(payments) = false with 2 of 3 restarts used")
+	}
+	if !b.allow("payments", 0, now) {
+		t.Error("allow(payments) = false with an unlimited budget")
+	}
+	if !b.allow("orders", 2, now) {
+		t.Error("allow(orders) = false, budgets are per namespace")
+	}
+	if !b.allow("payments", 2, now.Add(2*time.Hour)) {
+		t.Error("allow(payments) = false after the oldest restart left the 24h window")
+	}
+
+	b.record("orders", now.Add(-25*time.Hour))
+	b.prune("orders", now)
+	if _, ok := b["orders"]; ok {
+		t.Error("prune kept a namespace with no restarts in the window")
+	}
+}
+
+func TestWaitApproved(t *testing.T) {
+	downsize := optimizer.Recommendation{
+		TargetKind: "Deployment", TargetNamespace: "payments", TargetName: "api",
+		Details: map[string]string{"resource": "cpu+memory", "direction": "downsize"},
+	}
+	upsize := downsize
+	upsize.Details = map[string]string{"resource": "cpu+memory", "direction": "upsize"}
+	limit := downsize
+	limit.Details = map[string]string{"resource": "cpu-limit", "container": "app", "action": cpuLimitActionRaise}
+	oomBump := downsize
+	oomBump.Details = map[string]string{"resource": "memory", "reason": "OOMKilled"}
+	// 2026-01-14 is a Wednesday; the window only opens at weekends.
+	now := time.Date(2026, time.January, 14, 12, 0, 0, 0, time.UTC)
+	weekend := config.MaintenanceWindow{Schedule: "0 22 * * FRI", Duration: 48 * time.Hour}
+
+	tests := []struct {
+		name     string
+		windows  []config.MaintenanceWindow
+		strategy string
+		used     int
+		rec      optimizer.Recommendation
+		want     string
+	}{
+		{name: "no windows, budget left", used: 1, rec: downsize, want: ""},
+		{name: "outside the window", windows: []config.MaintenanceWindow{weekend}, rec: downsize, want: "maintenance windows"},
+		{name: "budget used up", used: 2, rec: downsize, want: "restart budget of 2"},
+		{name: "upsize never waits", windows: []config.MaintenanceWindow{weekend}, used: 2, rec: upsize, want: ""},
+		{name: "next rollout never waits", windows: []config.MaintenanceWindow{weekend}, strategy: RolloutNextRollout, rec: downsize, want: ""},
+		{name: "limit change outside the window", windows: []config.MaintenanceWindow{weekend}, rec: limit, want: "maintenance windows"},
+		{name: "limit change with budget used up", used: 2, rec: limit, want: "restart budget of 2"},
+		{name: "limit change under next rollout never waits", windows: []config.MaintenanceWindow{weekend}, strategy: RolloutNextRollout, rec: limit, want: ""},
+		{name: "OOM bump never waits", windows: []config.MaintenanceWindow{weekend}, used: 2, rec: oomBump, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultCfg()
+			cfg.Rightsizer.Rollout.MaintenanceWindows = tt.windows
+			cfg.Rightsizer.Rollout.NamespaceDailyBudget = 2
+			cfg.Rightsizer.Rollout.Strategy = tt.strategy
+			c := &Controller{config: cfg, restarts: restartBudget{}}
+			for i := 0; i < tt.used; i++ {
+				c.restarts.record("payments", now.Add(-time.Hour))
+			}
+
+			got := ""
+			if c.approvedRestart(tt.rec) {
+				got = c.restartWait(tt.rec, now)
+			} else if got = c.WaitApproved(context.Background(), tt.rec); got != "" {
+				t.Fatalf("WaitApproved() = %q, want no wait", got)
+			}
+			if (got == "") != (tt.want == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("wait reason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteApproved_LimitUsesRestartBudget(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			}}},
+		}}},
+	}
+	cfg := defaultCfg()
+	cfg.Rightsizer.Rollout.NamespaceDailyBudget = 1
+	c := &Controller{
+		config:    cfg,
+		actuator:  NewActuator(fake.NewClientBuilder().WithScheme(scheme).WithObjects(d).Build(), cfg),
+		downsized: make(map[string]time.Time),
+		restarts:  restartBudget{},
+	}
+	rec := optimizer.Recommendation{
+		TargetKind: "Deployment", TargetName: "web", TargetNamespace: "default",
+		Details: map[string]string{
+			"resource": "cpu-limit", "container": "app",
+			"action": cpuLimitActionRaise, "suggestedCPULimit": "2",
+		},
+	}
+	if reason := c.WaitApproved(context.Background(), rec); reason != "" {
+		t.Fatalf("WaitApproved() = %q before any restart, want no wait", reason)
+	}
+	if err := c.ExecuteApproved(context.Background(), rec); err != nil {
+		t.Fatalf("ExecuteApproved: %v", err)
+	}
+	if reason := c.WaitApproved(context.Background(), rec); !strings.Contains(reason, "restart budget of 1") {
+		t.Errorf("WaitApproved() = %q after the limit change, want the budget used up", reason)
+	}
+}
+
+func TestRestartsPods(t *testing.T) {
+	tests := []struct {
+		kind, strategy string
+		want           bool
+	}{
+		{kind: "Deployment", strategy: RolloutRestart, want: true},
+		{kind: "ReplicaSet", strategy: "", want: true},
+		{kind: "CronJob", strategy: RolloutRestart, want: false},
+		{kind: "StatefulSet", strategy: RolloutNextRollout, want: false},
+	}
+	for _, tt := range tests {
+		rec := optimizer.Recommendation{TargetKind: tt.kind}
+		if got := restartsPods(rec, tt.strategy); got != tt.want {
+			t.Errorf("restartsPods(%s, %q) = %v, want %v", tt.kind, tt.strategy, got, tt.want)
+		}
+	}
+}
+
+func TestActuator_NextRollout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	resources := func(cpu, mem string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(mem)},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+		}
+	}
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Resources: resources("1", "2Gi")}},
+		}}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(d).Build()
+	cfg := defaultCfg()
+	cfg.Rightsizer.Rollout.Strategy = RolloutNextRollout
+	cfg.Rightsizer.Limits.DownsizeCPULimit = "remove"
+	a := NewActuator(c, cfg)
+	ctx := context.Background()
+
+	rec := optimizer.Recommendation{TargetKind: "Deployment", TargetName: "web", TargetNamespace: "default",
+		Details: map[string]string{"resource": "cpu+memory", "suggestedCPURequest": "500m", "suggestedMemRequest": "1Gi"}}
+	changes, _ := json.Marshal([]optimizer.ContainerChange{{
+		Name: "app", Action: optimizer.ContainerActionResize,
+		SuggestedCPURequest: "500m", SuggestedMemRequest: "1Gi", RemoveCPULimit: true,
+	}})
+	rec.Details["containers"] = string(changes)
+	if err := a.Apply(ctx, rec); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	got := &appsv1.Deployment{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if req := got.Spec.Template.Spec.Containers[0].Resources.Requests; req.Cpu().String() != "1" || req.Memory().String() != "2Gi" {
+		t.Errorf("template requests = %s/%s, want 1/2Gi untouched under nextRollout", req.Cpu(), req.Memory())
+	}
+	if got.Annotations[AnnPendingResources] == "" {
+		t.Fatal("pending-resources annotation not set")
+	}
+	if got.Annotations[annOriginalContainers] == "" {
+		t.Error("original requests not recorded next to the pending change")
+	}
+
+	pod := func(cpu, mem string) *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "app", Resources: resources(cpu, mem)},
+			{Name: "istio-proxy", Resources: resources("100m", "128Mi")},
+		}}}
+	}
+
+	p := pod("1000m", "2Gi")
+	changed, err := ApplyPendingResources(p, got.Annotations)
+	if err != nil {
+		t.Fatalf("ApplyPendingResources: %v", err)
+	}
+	if !reflect.DeepEqual(changed, []string{"app"}) {
+		t.Errorf("changed = %v, want [app]", changed)
+	}
+	app := p.Spec.Containers[0].Resources
+	if app.Requests.Cpu().String() != "500m" || app.Requests.Memory().String() != "1Gi" {
+		t.Errorf("pod requests = %s/%s, want 500m/1Gi", app.Requests.Cpu(), app.Requests.Memory())
+	}
+	if _, ok := app.Limits[corev1.ResourceCPU]; ok {
+		t.Errorf("pod CPU limit = %s, want it removed", app.Limits.Cpu())
+	}
+	if sidecar := p.Spec.Containers[1].Resources.Requests; sidecar.Cpu().String() != "100m" {
+		t.Errorf("sidecar CPU request = %s, want 100m untouched", sidecar.Cpu())
+	}
+
+	// A pod from a template whose requests changed since keeps its own.
+	p = pod("2", "2Gi")
+	if changed, err := ApplyPendingResources(p, got.Annotations); err != nil || len(changed) != 0 {
+		t.Errorf("ApplyPendingResources on a changed template = %v, %v; want no change", changed, err)
+	}
+
+	if err := a.Rollback(ctx, "default", "Deployment", "web", "oom-kill: test"); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := got.Annotations[AnnPendingResources]; ok {
+		t.Errorf("pending-resources annotation = %q after a rollback, want it removed", v)
+	}
+}
+
+func TestActuator_NextRolloutLimit(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+	}
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Resources: resources}},
+		}}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(d).Build()
+	cfg := defaultCfg()
+	cfg.Rightsizer.Rollout.Strategy = RolloutNextRollout
+	a := NewActuator(c, cfg)
+	ctx := context.Background()
+
+	for _, details := range []map[string]string{
+		{"resource": "cpu-limit", "container": "app", "action": cpuLimitActionRaise, "suggestedCPULimit": "2"},
+		{"resource": "memory-limit", "container": "app", "action": memLimitActionSet, "suggestedMemLimit": "3Gi"},
+	} {
+		rec := optimizer.Recommendation{TargetKind: "Deployment", TargetName: "web", TargetNamespace: "default", Details: details}
+		if err := a.Apply(ctx, rec); err != nil {
+			t.Fatalf("Apply %s: %v", details["resource"], err)
+		}
+	}
+
+	got := &appsv1.Deployment{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if lim := got.Spec.Template.Spec.Containers[0].Resources.Limits; lim.Cpu().String() != "1" || lim.Memory().String() != "2Gi" {
+		t.Errorf("template limits = %s/%s, want 1/2Gi untouched under nextRollout", lim.Cpu(), lim.Memory())
+	}
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: *resources.DeepCopy()}}}}
+	if _, err := ApplyPendingResources(pod, got.Annotations); err != nil {
+		t.Fatalf("ApplyPendingResources: %v", err)
+	}
+	res := pod.Spec.Containers[0].Resources
+	if res.Limits.Cpu().String() != "2" || res.Limits.Memory().String() != "3Gi" {
+		t.Errorf("pod limits = %s/%s, want both pending changes 2/3Gi", res.Limits.Cpu(), res.Limits.Memory())
+	}
+	if res.Requests.Cpu().String() != "500m" || res.Requests.Memory().String() != "1Gi" {
+		t.Errorf("pod requests = %s/%s, want 500m/1Gi unchanged", res.Requests.Cpu(), res.Requests.Memory())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Recommended targets
+// ---------------------------------------------------------------------------
+
+func TestRecommendedTargets(t *testing.T) {
+	rec := func(kind, name string, details map[string]string) optimizer.Recommendation {
+		return optimizer.Recommendation{Type: optimizer.RecommendationPodRightsize,
+			TargetKind: kind, TargetName: name, TargetNamespace: "default", Details: details}
+	}
+	downsize := func(kind, name, cpu, mem string) optimizer.Recommendation {
+		changes, _ := json.Marshal([]optimizer.ContainerChange{
+			{Name: "app", Action: optimizer.ContainerActionResize, SuggestedCPURequest: cpu, SuggestedMemRequest: mem},
+			{Name: "istio-proxy", Action: optimizer.ContainerActionUnchanged},
+		})
+		return rec(kind, name, map[string]string{"resource": "cpu+memory", "containers": string(changes)})
+	}
+
+	targets := NewRecommendedTargets()
+	targets.Observe([]optimizer.Recommendation{
+		downsize("ReplicaSet", "web-7d9f", "500m", "1Gi"),
+		downsize("StatefulSet", "db", "1", "4Gi"),
+		rec("Deployment", "api", map[string]string{"resource": "cpu+memory", "direction": "upsize"}),
+	})
+
+	got := targets.Requests("default", "Deployment", "web")
+	if app := got["app"]; len(got) != 1 || app.Cpu().String() != "500m" || app.Memory().String() != "1Gi" {
+		t.Fatalf("web targets = %v, want app at 500m/1Gi", got)
+	}
+	if got := targets.Requests("default", "Deployment", "api"); got != nil {
+		t.Errorf("upsize recorded as target: %v", got)
+	}
+
+	// Callers get a copy.
+	got["app"][corev1.ResourceCPU] = resource.MustParse("9")
+	if app := targets.Requests("default", "Deployment", "web")["app"]; app.Cpu().String() != "500m" {
+		t.Errorf("stored target changed through a returned copy: %s", app.Cpu())
+	}
+
+	targets.Observe([]optimizer.Recommendation{
+		rec("Deployment", "web", map[string]string{"resource": "memory", "reason": "OOMKilled"}),
+		rec("StatefulSet", "db", map[string]string{"resource": "rollback"}),
+	})
+	if got := targets.Requests("default", "Deployment", "web"); got != nil {
+		t.Errorf("targets kept after an OOM bump: %v", got)
+	}
+	if got := targets.Requests("default", "StatefulSet", "db"); got != nil {
+		t.Errorf("targets kept after a rollback: %v", got)
+	}
+}
+
+func TestInjectRequests(t *testing.T) {
+	resources := func(cpu, mem string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(mem),
+		}}
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "app", Resources: resources("1", "1Gi")},
+		{Name: "worker", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("2"),
+		}}},
+		{Name: "istio-proxy", Resources: resources("100m", "128Mi")},
+	}}}
+	targets := map[string]corev1.ResourceList{
+		"app":    {corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+		"worker": {corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+	}
+
+	changed := InjectRequests(pod, targets)
+	if !reflect.DeepEqual(changed, []string{"app", "worker"}) {
+		t.Errorf("changed = %v, want [app worker]", changed)
+	}
+	app := pod.Spec.Containers[0].Resources.Requests
+	if app.Cpu().String() != "500m" || app.Memory().String() != "1Gi" {
+		t.Errorf("app requests = %s/%s, want cpu lowered and memory never raised", app.Cpu(), app.Memory())
+	}
+	worker := pod.Spec.Containers[1].Resources.Requests
+	if worker.Cpu().String() != "1" {
+		t.Errorf("worker cpu = %s, want 1", worker.Cpu())
+	}
+	if _, ok := worker[corev1.ResourceMemory]; ok {
+		t.Error("memory request added to a container without one")
+	}
+	if proxy := pod.Spec.Containers[2].Resources.Requests; proxy.Cpu().String() != "100m" {
+		t.Errorf("istio-proxy cpu = %s, want untouched", proxy.Cpu())
+	}
+}
@@ -0,0 +1,231 @@
+package rightsizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// Rollout strategies (config rightsizer.rollout.strategy).
+const (
+	RolloutRestart     = "restart"
+	RolloutNextRollout = "nextRollout"
+)
+
+// AnnPendingResources holds, under the nextRollout strategy, a JSON object
+// of container name → pendingResources. The pod webhook applies it to new
+// pods of the workload; the pod template is left alone so nothing restarts.
+const AnnPendingResources = "koptimizer.io/pending-resources"
+
+// restartBudgetWindow is the rolling period of the namespace daily budget.
+const restartBudgetWindow = 24 * time.Hour
+
+// pendingResources is the change recorded for one container. From holds
+// the template requests it was computed against; pods whose requests have
+// moved on since are left untouched. Resources is the strategic merge
+// value of the container's resources, where null removes a quantity.
+type pendingResources struct {
+	From      map[string]string      `json:"from"`
+	Resources map[string]interface{} `json:"resources"`
+}
+
+// inMaintenanceWindow reports whether now falls inside any of windows. No
+// windows means any time. Windows with an invalid schedule or timezone,
+// which validation rejects, never match.
+func inMaintenanceWindow(windows []config.MaintenanceWindow, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, w := range windows {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			continue
+		}
+		sched, err := cron.ParseStandard(w.Schedule)
+		if err != nil {
+			continue
+		}
+		// Open when the last activation was within Duration of now.
+		local := now.In(loc)
+		if !sched.Next(local.Add(-w.Duration)).After(local) {
+			return true
+		}
+	}
+	return false
+}
+
+// restartBudget counts restarting rightsizing changes per namespace over a
+// rolling 24h. It is not safe for concurrent use.
+type restartBudget map[string][]time.Time
+
+// allow reports whether namespace has room for another restart. A limit of
+// 0 means unlimited.
+func (b restartBudget) allow(namespace string, limit int, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+	b.prune(namespace, now)
+	return len(b[namespace]) < limit
+}
+
+func (b restartBudget) record(namespace string, now time.Time) {
+	b.prune(namespace, now)
+	b[namespace] = append(b[namespace], now)
+}
+
+func (b restartBudget) prune(namespace string, now time.Time) {
+	cutoff := now.Add(-restartBudgetWindow)
+	valid := b[namespace][:0]
+	for _, t := range b[namespace] {
+		if t.After(cutoff) {
+			valid = append(valid, t)
+		}
+	}
+	if len(valid) == 0 {
+		delete(b, namespace)
+		return
+	}
+	b[namespace] = valid
+}
+
+// restartsPods reports whether applying rec would restart pods now, which
+// is what maintenance windows and the namespace budget gate. CronJob
+// changes only reach the next run, and under nextRollout nothing restarts.
+func restartsPods(rec optimizer.Recommendation, strategy string) bool {
+	return rec.TargetKind != "CronJob" && strategy != RolloutNextRollout
+}
+
+// pendingResourcesPatch turns a pod template patch into a workload
+// metadata patch for the nextRollout strategy: the container changes are
+// merged into the pending-resources annotation, next to the recorded
+// originals, and the template is left alone.
+func pendingResourcesPatch(patchData map[string]interface{}, annotations map[string]string, containers []corev1.Container) (map[string]interface{}, error) {
+	pending := map[string]pendingResources{}
+	if v := annotations[AnnPendingResources]; v != "" {
+		if err := json.Unmarshal([]byte(v), &pending); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", AnnPendingResources, err)
+		}
+	}
+
+	byName := make(map[string]corev1.Container, len(containers))
+	for _, c := range containers {
+		byName[c.Name] = c
+	}
+	for _, p := range templateContainerPatches(patchData) {
+		name, _ := p["name"].(string)
+		res, _ := p["resources"].(map[string]interface{})
+		c, ok := byName[name]
+		if !ok || res == nil {
+			continue
+		}
+		entry := pendingResources{
+			From: map[string]string{
+				string(corev1.ResourceCPU):    c.Resources.Requests.Cpu().String(),
+				string(corev1.ResourceMemory): c.Resources.Requests.Memory().String(),
+			},
+			Resources: res,
+		}
+		// A change computed against the same requests adds to the one
+		// already pending, such as a limit change to a pending downsize.
+		if prev, ok := pending[name]; ok && maps.Equal(prev.From, entry.From) {
+			entry.Resources = mergePendingResources(prev.Resources, res)
+		}
+		pending[name] = entry
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return nil, err
+	}
+	ann := map[string]interface{}{AnnPendingResources: string(value)}
+	if meta, ok := patchData["metadata"].(map[string]interface{}); ok {
+		if originals, ok := meta["annotations"].(map[string]string); ok {
+			for k, v := range originals {
+				ann[k] = v
+			}
+		}
+	}
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": ann},
+	}, nil
+}
+
+// mergePendingResources overlays the requests and limits of next on those
+// of prev, as successive strategic merge patches of the template would.
+func mergePendingResources(prev, next map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(prev)+len(next))
+	for section, v := range prev {
+		merged[section] = v
+	}
+	for section, v := range next {
+		values := map[string]interface{}{}
+		for _, m := range []interface{}{prev[section], v} {
+			switch m := m.(type) {
+			case map[string]interface{}:
+				for k, q := range m {
+					values[k] = q
+				}
+			case map[string]string:
+				for k, q := range m {
+					values[k] = q
+				}
+			}
+		}
+		merged[section] = values
+	}
+	return merged
+}
+
+// ApplyPendingResources applies the pending-resources annotation of a
+// workload to a pod being created from it. Containers whose requests no
+// longer match the ones the change was computed against are skipped. It
+// returns the names of the containers it changed.
+func ApplyPendingResources(pod *corev1.Pod, annotations map[string]string) ([]string, error) {
+	v := annotations[AnnPendingResources]
+	if v == "" {
+		return nil, nil
+	}
+	pending := map[string]pendingResources{}
+	if err := json.Unmarshal([]byte(v), &pending); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", AnnPendingResources, err)
+	}
+
+	var changed []string
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		p, ok := pending[c.Name]
+		if !ok || !requestsMatch(c.Resources.Requests, p.From) {
+			continue
+		}
+		c.Resources.Requests = overlayResources(c.Resources.Requests, p.Resources["requests"])
+		if limits, ok := p.Resources["limits"]; ok {
+			c.Resources.Limits = overlayResources(c.Resources.Limits, limits)
+		}
+		changed = append(changed, c.Name)
+	}
+	return changed, nil
+}
+
+// requestsMatch reports whether requests hold exactly the quantities in
+// from. Quantities are compared by value, so "1" matches "1000m".
+func requestsMatch(requests corev1.ResourceList, from map[string]string) bool {
+	for name, value := range from {
+		current := requests[corev1.ResourceName(name)]
+		want, err := resource.ParseQuantity(value)
+		if err != nil || current.Cmp(want) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package rightsizer
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// AnnInjectRequests on a pod template opts the workload into having the
+// latest recommended requests set on its new pods by the pod webhook,
+// without patching the workload.
+const AnnInjectRequests = "koptimizer.io/rightsizing-inject"
+
+// RecommendedTargets remembers the latest per-container request targets of
+// every workload. Targets stick until a newer recommendation replaces them:
+// pods created with them no longer produce one. An OOM bump or a rollback
+// drops them. It is safe for concurrent use.
+type RecommendedTargets struct {
+	mu      sync.RWMutex
+	targets map[string]map[string]corev1.ResourceList // workload key → container → requests
+}
+
+func NewRecommendedTargets() *RecommendedTargets {
+	return &RecommendedTargets{targets: map[string]map[string]corev1.ResourceList{}}
+}
+
+// Observe records the per-container targets of downsize recommendations
+// and forgets workloads that were OOM-bumped or rolled back. Upsizes are
+// never recorded.
+func (t *RecommendedTargets) Observe(recs []optimizer.Recommendation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, rec := range recs {
+		if rec.Type != optimizer.RecommendationPodRightsize {
+			continue
+		}
+		kind, name := targetWorkload(rec)
+		key := workloadKey(rec.TargetNamespace, kind, name)
+		switch {
+		case rec.Details["reason"] == "OOMKilled", rec.Details["resource"] == "rollback":
+			delete(t.targets, key)
+		case isDownsizeRec(rec):
+			if containers := recommendedRequests(rec); len(containers) > 0 {
+				t.targets[key] = containers
+			}
+		}
+	}
+}
+
+// Forget drops the targets of a workload.
+func (t *RecommendedTargets) Forget(namespace, kind, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.targets, workloadKey(namespace, kind, name))
+}
+
+// Requests returns a copy of the container name → requests targets of a
+// workload, or nil when there are none.
+func (t *RecommendedTargets) Requests(namespace, kind, name string) map[string]corev1.ResourceList {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	targets, ok := t.targets[workloadKey(namespace, kind, name)]
+	if !ok {
+		return nil
+	}
+	out := make(map[string]corev1.ResourceList, len(targets))
+	for c, req := range targets {
+		out[c] = req.DeepCopy()
+	}
+	return out
+}
+
+// recommendedRequests returns the requests of every resized container of
+// a per-container recommendation.
+func recommendedRequests(rec optimizer.Recommendation) map[string]corev1.ResourceList {
+	changes, err := containerChanges(rec)
+	if err != nil {
+		return nil
+	}
+	targets := map[string]corev1.ResourceList{}
+	for _, ch := range changes {
+		if ch.Action != optimizer.ContainerActionResize {
+			continue
+		}
+		cpu, err := resource.ParseQuantity(ch.SuggestedCPURequest)
+		if err != nil || cpu.Sign() <= 0 {
+			continue
+		}
+		mem, err := resource.ParseQuantity(ch.SuggestedMemRequest)
+		if err != nil || mem.Sign() <= 0 {
+			continue
+		}
+		targets[ch.Name] = corev1.ResourceList{corev1.ResourceCPU: cpu, corev1.ResourceMemory: mem}
+	}
+	return targets
+}
+
+// InjectRequests lowers the requests of pod's containers to targets. A
+// request is never raised, and a resource without a request stays without
+// one. It returns the names of the containers it changed.
+func InjectRequests(pod *corev1.Pod, targets map[string]corev1.ResourceList) []string {
+	var changed []string
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		target, ok := targets[c.Name]
+		if !ok {
+			continue
+		}
+		updated := false
+		for name, want := range target {
+			current, ok := c.Resources.Requests[name]
+			if !ok || want.Cmp(current) >= 0 {
+				continue
+			}
+			c.Resources.Requests[name] = want
+			updated = true
+		}
+		if updated {
+			changed = append(changed, c.Name)
+		}
+	}
+	return changed
+}
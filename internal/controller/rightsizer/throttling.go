@@ -0,0 +1,188 @@
+package rightsizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// CPU limit policies for throttled containers.
+const (
+	CPULimitPolicyRaise  = "raise"
+	CPULimitPolicyRemove = "remove"
+	CPULimitPolicyOff    = "off"
+)
+
+// CPU limit recommendation actions, stored in Details["action"].
+const (
+	cpuLimitActionRaise  = "raise"
+	cpuLimitActionRemove = "remove"
+)
+
+// recommendCPULimits returns one recommendation per throttled container
+// with a CPU limit: raise the limit, or remove it when that is the policy.
+// Limits don't affect cost, so these carry no savings and are never
+// auto-executed. Sidecars are left alone under the "skip" sidecar policy,
+// and changes the namespace's LimitRanges would reject are dropped. The
+// limit policy comes from the workload's rightsizing policy.
+func (r *Recommender) recommendCPULimits(analysis *PodAnalysis, p *Policy) []optimizer.Recommendation {
+	policy := p.CPULimitPolicy
+	if policy == CPULimitPolicyOff {
+		return nil
+	}
+	skipSidecars := r.config.Rightsizer.SidecarPolicy != SidecarPolicySeparate
+
+	pod := analysis.PodInfo
+	var recs []optimizer.Recommendation
+	for _, c := range analysis.Containers {
+		if !c.IsThrottled || c.CPULimitMilli == 0 || (c.Sidecar && skipSidecars) {
+			continue
+		}
+		throttledPct := c.ThrottledP95 * 100
+		details := map[string]string{
+			"resource":        "cpu-limit",
+			"container":       c.Name,
+			"currentCPULimit": fmt.Sprintf("%dm", c.CPULimitMilli),
+			"cpuRequest":      fmt.Sprintf("%dm", c.CPURequestMilli),
+			"throttledPct":    fmt.Sprintf("%.0f", throttledPct),
+			"p95CPU":          fmt.Sprintf("%dm", c.CPUP95),
+		}
+
+		var summary, step string
+		var limit int64
+		if policy == CPULimitPolicyRemove {
+			details["action"] = cpuLimitActionRemove
+			summary = fmt.Sprintf("Remove CPU limit of %s/%s container %q: throttled in %.0f%% of CFS periods (P95)",
+				pod.Pod.Namespace, pod.OwnerName, c.Name, throttledPct)
+			step = fmt.Sprintf("Remove the %dm CPU limit from container %q", c.CPULimitMilli, c.Name)
+		} else {
+			limit = raisedCPULimit(c)
+			details["action"] = cpuLimitActionRaise
+			details["suggestedCPULimit"] = fmt.Sprintf("%dm", limit)
+			summary = fmt.Sprintf("Raise CPU limit of %s/%s container %q: %dm→%dm, throttled in %.0f%% of CFS periods (P95)",
+				pod.Pod.Namespace, pod.OwnerName, c.Name, c.CPULimitMilli, limit, throttledPct)
+			step = fmt.Sprintf("Patch CPU limit of container %q from %dm to %dm", c.Name, c.CPULimitMilli, limit)
+		}
+		res := ContainerResources{
+			Name:            c.Name,
+			CPURequestMilli: c.CPURequestMilli,
+			CPULimitMilli:   limit,
+			MemRequestBytes: c.MemRequestBytes,
+			MemLimitBytes:   c.MemLimitBytes,
+		}
+		if err := analysis.LimitRange.Check(res); err != nil {
+			continue
+		}
+
+		recs = append(recs, optimizer.Recommendation{
+			ID:              fmt.Sprintf("rightsize-cpulimit-%s-%s-%s-%d", pod.Pod.Namespace, pod.Pod.Name, c.Name, time.Now().Unix()),
+			Type:            optimizer.RecommendationPodRightsize,
+			Priority:        optimizer.PriorityHigh,
+			AutoExecutable:  false,
+			TargetKind:      pod.OwnerKind,
+			TargetName:      pod.OwnerName,
+			TargetNamespace: pod.Pod.Namespace,
+			Summary:         summary,
+			ActionSteps:     []string{step},
+			Details:         details,
+			CreatedAt:       time.Now(),
+		})
+	}
+	return recs
+}
+
+// raisedCPULimit scales a throttled container's limit by the share of
+// periods it was throttled in, limit / (1 - P95 ratio), rounded up to 10m
+// and capped at MaxCPULimitRaise times the current limit.
+func raisedCPULimit(c ContainerAnalysis) int64 {
+	ratio := min(c.ThrottledP95, 1-1/MaxCPULimitRaise)
+	limit := ceilCPU(float64(c.CPULimitMilli) / (1 - ratio))
+	return max(limit, c.CPULimitMilli+MinCPUAbsolute)
+}
+
+// applyLimit changes one container limit in the owning workload's pod
+// template: the CPU limit for "cpu-limit" recommendations, the memory limit
+// for "memory-limit" ones. Requests are left alone, and the result must
+// still satisfy the namespace's LimitRanges. Under the nextRollout strategy
+// the change is left to the pod webhook, like a downsize.
+func (a *Actuator) applyLimit(ctx context.Context, rec optimizer.Recommendation) error {
+	kind, name := rec.TargetKind, rec.TargetName
+	if kind == "ReplicaSet" {
+		deployName, err := a.resolveReplicaSetOwner(ctx, rec.TargetNamespace, name)
+		if err != nil {
+			return fmt.Errorf("resolving ReplicaSet %s/%s owner: %w", rec.TargetNamespace, name, err)
+		}
+		kind, name = "Deployment", deployName
+	}
+	obj, tmpl, err := a.getWorkload(ctx, rec.TargetNamespace, kind, name)
+	if err != nil {
+		return err
+	}
+
+	build, what, value := buildCPULimitPatch, "CPU limit", rec.Details["suggestedCPULimit"]
+	if rec.Details["resource"] == "memory-limit" {
+		build, what, value = buildMemLimitPatch, "memory limit", rec.Details["suggestedMemLimit"]
+	}
+	patchData, err := build(tmpl.Spec.Containers, rec.Details["container"], rec.Details["action"], value)
+	if err != nil {
+		return fmt.Errorf("patching %s of %s %s/%s: %w", what, kind, rec.TargetNamespace, name, err)
+	}
+	if err := a.checkLimitRanges(ctx, rec.TargetNamespace, tmpl.Spec.Containers, templateContainerPatches(patchData)); err != nil {
+		return fmt.Errorf("patching %s of %s %s/%s: %w", what, kind, rec.TargetNamespace, name, err)
+	}
+	if kind == "CronJob" {
+		wrapJobTemplate(patchData)
+	} else if a.config.Rightsizer.Rollout.Strategy == RolloutNextRollout {
+		patchData, err = pendingResourcesPatch(patchData, obj.GetAnnotations(), tmpl.Spec.Containers)
+		if patchData == nil || err != nil {
+			return err
+		}
+	}
+
+	patch, err := json.Marshal(patchData)
+	if err != nil {
+		return err
+	}
+
+	return a.client.Patch(ctx, obj, client.RawPatch(types.StrategicMergePatchType, patch))
+}
+
+// buildCPULimitPatch builds a pod template patch that removes container's
+// CPU limit, or raises it to value. A raised limit must exceed the current
+// one and may not drop below the container's CPU request.
+func buildCPULimitPatch(containers []corev1.Container, container, action, value string) (map[string]interface{}, error) {
+	target := findContainer(containers, container)
+	if target == nil {
+		return nil, fmt.Errorf("container %q not found", container)
+	}
+
+	var limit interface{}
+	switch action {
+	case cpuLimitActionRemove:
+		limit = nil
+	case cpuLimitActionRaise:
+		qty, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU limit %q: %w", value, err)
+		}
+		if current, ok := target.Resources.Limits[corev1.ResourceCPU]; ok && qty.Cmp(current) <= 0 {
+			return nil, fmt.Errorf("CPU limit %s does not raise the current limit %s", value, current.String())
+		}
+		if req, ok := target.Resources.Requests[corev1.ResourceCPU]; ok && qty.Cmp(req) < 0 {
+			return nil, fmt.Errorf("CPU limit %s is below the CPU request %s", value, req.String())
+		}
+		limit = qty.String()
+	default:
+		return nil, fmt.Errorf("unsupported CPU limit action %q: must be raise or remove", action)
+	}
+
+	return limitPatch(container, corev1.ResourceCPU, limit), nil
+}
@@ -0,0 +1,401 @@
+package rightsizer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	koptv1alpha1 "github.com/koptimizer/koptimizer/api/v1alpha1"
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/metrics"
+	"github.com/koptimizer/koptimizer/internal/state"
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+const (
+	// readinessGrace is how long after a change readiness is ignored, so a
+	// rolling restart is not mistaken for a regression.
+	readinessGrace = 5 * time.Minute
+
+	// readinessChecks is the number of consecutive checks readiness must
+	// stay below the threshold before it counts as a regression.
+	readinessChecks = 3
+
+	recommendationNamespace = "koptimizer-system"
+)
+
+// Regression signals.
+const (
+	SignalOOMKill   = "oom-kill"
+	SignalThrottled = "cpu-throttling"
+	SignalRestarts  = "restart-spike"
+	SignalReadiness = "readiness-drop"
+)
+
+// Watchdog watches rightsized workloads for a window after each change and
+// rolls them back when they regress: OOM kills, CPU pinned at its limit,
+// restart spikes or a drop in ready pods. A rollback restores the annotated
+// original requests, blocks the workload from further downsizing, and is
+// recorded as an audit event and a rollback recommendation.
+//
+// Watches live in memory, so an optimizer restart ends them early; the
+// blocked annotation written by a rollback persists.
+type Watchdog struct {
+	client   client.Client
+	config   *config.Config
+	store    *metrics.Store
+	auditLog *state.AuditLog
+	oom      *OOMTracker
+	actuator *Actuator
+	gate     *aigate.AIGate
+	now      func() time.Time
+
+	mu      sync.Mutex
+	watched map[string]*workloadWatch
+	blocked map[string]string // workload key -> rollback reason
+}
+
+type workloadWatch struct {
+	namespace, kind, name string
+	recID                 string
+	appliedAt             time.Time
+	restarts              map[string]int32 // pod -> container restarts when applied
+	readyPct              float64          // ready pods when applied, 0-100
+	lowReadiness          int              // consecutive checks below threshold
+}
+
+func NewWatchdog(c client.Client, cfg *config.Config, store *metrics.Store, auditLog *state.AuditLog, oom *OOMTracker, actuator *Actuator) *Watchdog {
+	return &Watchdog{
+		client:   c,
+		config:   cfg,
+		store:    store,
+		auditLog: auditLog,
+		oom:      oom,
+		actuator: actuator,
+		now:      time.Now,
+		watched:  make(map[string]*workloadWatch),
+		blocked:  make(map[string]string),
+	}
+}
+
+// SetGate makes the watchdog report whether gated changes held or were
+// rolled back to the gate's decision history.
+func (w *Watchdog) SetGate(g *aigate.AIGate) {
+	w.gate = g
+}
+
+// Watch starts watching the workload rec was applied to, taking the
+// restart and readiness baseline from pods.
+func (w *Watchdog) Watch(ctx context.Context, rec optimizer.Recommendation, pods []optimizer.PodInfo) {
+	if !w.config.Rightsizer.Watchdog.Enabled {
+		return
+	}
+	kind, name := targetWorkload(rec)
+	if rec.TargetKind == "ReplicaSet" {
+		// New pods of the rollout belong to a new ReplicaSet, so watch the
+		// Deployment itself.
+		if deployName, err := w.actuator.resolveReplicaSetOwner(ctx, rec.TargetNamespace, rec.TargetName); err == nil {
+			name = deployName
+		}
+	}
+
+	ww := &workloadWatch{
+		namespace: rec.TargetNamespace,
+		kind:      kind,
+		name:      name,
+		recID:     rec.ID,
+		appliedAt: w.now(),
+		restarts:  map[string]int32{},
+	}
+	current := workloadPods(pods, ww.namespace, ww.kind, ww.name)
+	for _, p := range current {
+		ww.restarts[p.Pod.Name] = podRestarts(p.Pod)
+	}
+	ww.readyPct = readyPct(current)
+
+	w.mu.Lock()
+	w.watched[workloadKey(ww.namespace, ww.kind, ww.name)] = ww
+	w.mu.Unlock()
+}
+
+// IsBlocked reports whether rec targets a workload that was rolled back.
+func (w *Watchdog) IsBlocked(rec optimizer.Recommendation) bool {
+	kind, name := targetWorkload(rec)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.blocked[workloadKey(rec.TargetNamespace, kind, name)]
+	return ok
+}
+
+// Block records that rec's workload must not be downsized again, e.g. after
+// the actuator found the blocked annotation left by an earlier rollback.
+func (w *Watchdog) Block(rec optimizer.Recommendation, reason string) {
+	kind, name := targetWorkload(rec)
+	w.mu.Lock()
+	w.blocked[workloadKey(rec.TargetNamespace, kind, name)] = reason
+	w.mu.Unlock()
+}
+
+// Watched returns the keys of the workloads currently being watched.
+func (w *Watchdog) Watched() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	keys := make([]string, 0, len(w.watched))
+	for k := range w.watched {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Check evaluates every watched workload against pods, rolling back those
+// that regressed and releasing those whose window has passed.
+func (w *Watchdog) Check(ctx context.Context, pods []optimizer.PodInfo) {
+	cfg := w.config.Rightsizer.Watchdog
+	now := w.now()
+
+	w.mu.Lock()
+	watches := make(map[string]*workloadWatch, len(w.watched))
+	for k, ww := range w.watched {
+		watches[k] = ww
+	}
+	w.mu.Unlock()
+
+	for key, ww := range watches {
+		if now.Sub(ww.appliedAt) > cfg.Window {
+			w.release(key)
+			verified := fmt.Sprintf("No regression within %s of the change", cfg.Window)
+			if w.auditLog != nil {
+				w.auditLog.Record("rightsize-verified", key, "rightsizer-watchdog", verified)
+			}
+			w.gate.ReportOutcome(ww.recID, aigate.OutcomeSuccess, verified)
+			continue
+		}
+
+		signal, detail := w.detect(ww, workloadPods(pods, ww.namespace, ww.kind, ww.name), now)
+		if signal == "" {
+			continue
+		}
+		w.rollback(ctx, key, ww, signal, detail)
+	}
+}
+
+// detect returns the first regression signal found, or "" if none.
+func (w *Watchdog) detect(ww *workloadWatch, pods []optimizer.PodInfo, now time.Time) (signal, detail string) {
+	cfg := w.config.Rightsizer.Watchdog
+
+	if killed := w.oom.KilledSince(pods, ww.appliedAt); len(killed) > 0 {
+		return SignalOOMKill, fmt.Sprintf("OOM killed since the change: %s", strings.Join(killed, ", "))
+	}
+
+	var restarts int32
+	for _, p := range pods {
+		restarts += podRestarts(p.Pod) - ww.restarts[p.Pod.Name]
+	}
+	if int(restarts) >= cfg.MaxRestarts {
+		return SignalRestarts, fmt.Sprintf("%d container restarts since the change (limit %d)", restarts, cfg.MaxRestarts)
+	}
+
+	if throttled := w.throttled(ww, pods, now); len(throttled) > 0 {
+		return SignalThrottled, fmt.Sprintf("CFS-throttled in at least %.0f%% of periods: %s", w.config.Rightsizer.Throttling.ThrottledPct, strings.Join(throttled, ", "))
+	}
+
+	// Batch pods come and go by design; readiness says nothing about them.
+	if ww.kind != "CronJob" && now.Sub(ww.appliedAt) >= readinessGrace && len(pods) > 0 {
+		ready := readyPct(pods)
+		if ww.readyPct-ready > cfg.MaxReadinessDropPct {
+			ww.lowReadiness++
+		} else {
+			ww.lowReadiness = 0
+		}
+		if ww.lowReadiness >= readinessChecks {
+			return SignalReadiness, fmt.Sprintf("ready pods dropped from %.0f%% to %.0f%%", ww.readyPct, ready)
+		}
+	}
+	return "", ""
+}
+
+// throttled returns "pod/container" for containers whose cAdvisor CFS
+// counters show heavy throttling since the change.
+func (w *Watchdog) throttled(ww *workloadWatch, pods []optimizer.PodInfo, now time.Time) []string {
+	if w.store == nil {
+		return nil
+	}
+	pct := w.config.Rightsizer.Throttling.ThrottledPct
+	since := now.Sub(ww.appliedAt)
+	var out []string
+	for _, p := range pods {
+		for _, c := range p.Pod.Spec.Containers {
+			window := w.store.GetThrottlingWindow(p.Pod.Namespace, p.Pod.Name, c.Name, since)
+			if window == nil || window.DataPoints < MinThrottleSamples {
+				continue
+			}
+			if window.P95Ratio*100 >= pct {
+				out = append(out, p.Pod.Name+"/"+c.Name)
+			}
+		}
+	}
+	return out
+}
+
+// rollback restores ww's workload, blocks it and records the outcome.
+func (w *Watchdog) rollback(ctx context.Context, key string, ww *workloadWatch, signal, detail string) {
+	logger := log.FromContext(ctx).WithName("rightsizer-watchdog")
+	reason := fmt.Sprintf("%s: %s", signal, detail)
+
+	err := w.actuator.Rollback(ctx, ww.namespace, ww.kind, ww.name, reason)
+	if err != nil {
+		logger.Error(err, "Rollback failed", "workload", key, "signal", signal)
+	} else {
+		logger.Info("Rolled back rightsizing", "workload", key, "signal", signal, "detail", detail)
+	}
+
+	w.mu.Lock()
+	delete(w.watched, key)
+	w.blocked[key] = reason
+	w.mu.Unlock()
+
+	if w.auditLog != nil {
+		outcome := "restored original requests"
+		if err != nil {
+			outcome = "restore failed: " + err.Error()
+		}
+		w.auditLog.Record("rightsize-rollback", key, "rightsizer-watchdog", fmt.Sprintf("%s; %s", reason, outcome))
+	}
+
+	gateOutcome := aigate.OutcomeRolledBack
+	if signal == SignalOOMKill {
+		gateOutcome = aigate.OutcomeOOM
+	}
+	w.gate.ReportOutcome(ww.recID, gateOutcome, reason)
+
+	if err := w.recordRollback(ctx, ww, signal, reason, err); err != nil {
+		logger.Error(err, "Failed to record rollback recommendation", "workload", key)
+	}
+}
+
+// recordRollback creates a rollback recommendation. When the automatic
+// restore failed it stays pending, so approving it retries the restore.
+func (w *Watchdog) recordRollback(ctx context.Context, ww *workloadWatch, signal, reason string, rollbackErr error) error {
+	crd := &koptv1alpha1.Recommendation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      strings.ToLower(fmt.Sprintf("rollback-%s-%s-%d", ww.namespace, ww.name, w.now().Unix())),
+			Namespace: recommendationNamespace,
+		},
+		Spec: koptv1alpha1.RecommendationSpec{
+			Type:            string(optimizer.RecommendationPodRightsize),
+			Priority:        string(optimizer.PriorityCritical),
+			TargetKind:      ww.kind,
+			TargetName:      ww.name,
+			TargetNamespace: ww.namespace,
+			Summary:         fmt.Sprintf("Roll back rightsizing of %s/%s: %s", ww.namespace, ww.name, reason),
+			ActionSteps:     []string{"Restore the original requests recorded before the change and block further downsizing"},
+			AutoExecutable:  true,
+			Details: map[string]string{
+				"resource":         "rollback",
+				"signal":           signal,
+				"reason":           reason,
+				"recommendationID": ww.recID,
+				"appliedAt":        ww.appliedAt.Format(time.RFC3339),
+			},
+		},
+	}
+	if err := w.client.Create(ctx, crd); err != nil {
+		return fmt.Errorf("creating rollback recommendation: %w", err)
+	}
+
+	if rollbackErr != nil {
+		crd.Status.State = "pending"
+		crd.Status.Error = rollbackErr.Error()
+	} else {
+		crd.Status.State = "executed"
+		crd.Status.ExecutedAt = metav1.NewTime(w.now())
+		crd.Status.ExecutionResult = "Original requests restored by the rightsizer watchdog"
+	}
+	return w.client.Status().Update(ctx, crd)
+}
+
+func (w *Watchdog) release(key string) {
+	w.mu.Lock()
+	delete(w.watched, key)
+	w.mu.Unlock()
+}
+
+func workloadKey(namespace, kind, name string) string {
+	return namespace + "/" + kind + "/" + name
+}
+
+// targetWorkload returns the workload a recommendation changes. ReplicaSet
+// targets are mapped to their Deployment by the "<deployment>-<hash>"
+// naming convention.
+func targetWorkload(rec optimizer.Recommendation) (kind, name string) {
+	if rec.TargetKind == "ReplicaSet" {
+		if i := strings.LastIndex(rec.TargetName, "-"); i > 0 {
+			return "Deployment", rec.TargetName[:i]
+		}
+	}
+	return rec.TargetKind, rec.TargetName
+}
+
+// workloadPods returns the pods in pods that belong to the workload.
+func workloadPods(pods []optimizer.PodInfo, namespace, kind, name string) []optimizer.PodInfo {
+	var matchCronJob func(string) bool
+	if kind == "CronJob" {
+		matchCronJob = cronJobPodMatcher(name)
+	}
+	var out []optimizer.PodInfo
+	for _, p := range pods {
+		if p.Pod == nil || p.Pod.Namespace != namespace {
+			continue
+		}
+		var match bool
+		switch kind {
+		case "Deployment":
+			rs, ok := strings.CutPrefix(p.OwnerName, name+"-")
+			match = ok && p.OwnerKind == "ReplicaSet" && !strings.Contains(rs, "-")
+		case "CronJob":
+			match = p.OwnerKind == "Job" && matchCronJob(p.Pod.Name)
+		default:
+			match = p.OwnerKind == kind && p.OwnerName == name
+		}
+		if match {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func podRestarts(pod *corev1.Pod) int32 {
+	var n int32
+	for _, cs := range pod.Status.ContainerStatuses {
+		n += cs.RestartCount
+	}
+	return n
+}
+
+// readyPct returns the share of live pods that are ready, 0-100.
+func readyPct(pods []optimizer.PodInfo) float64 {
+	live, ready := 0, 0
+	for _, p := range pods {
+		if p.Pod.Status.Phase == corev1.PodSucceeded || p.Pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		live++
+		if allContainersReady(p.Pod) {
+			ready++
+		}
+	}
+	if live == 0 {
+		return 0
+	}
+	return float64(ready) / float64(live) * 100
+}
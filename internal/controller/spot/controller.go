@@ -2,6 +2,7 @@ package spot
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -91,30 +92,47 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 	if !rec.AutoExecutable {
 		return nil
 	}
+	// Recommendation types without a spot action are informational.
+	if c.executorFor(rec.Details["action"]) == nil {
+		return nil
+	}
 
 	// AI Gate validation — fail-closed
 	if c.gate.RequiresValidation(rec) {
-		valReq := aigate.ValidationRequest{
-			Action:         rec.Summary,
-			Recommendation: rec,
-		}
+		valReq := c.state.GateRequest(ctx, rec)
 		result, err := c.gate.Validate(ctx, valReq)
 		if err != nil || !result.Approved {
 			return nil // Falls back to recommendation mode
 		}
 	}
 
+	return c.ExecuteApproved(ctx, rec)
+}
+
+// ExecuteApproved applies a spot action through the mixer, the interruption
+// handler or the diversity manager. Unlike Execute, it fails on actions none
+// of them handle.
+func (c *Controller) ExecuteApproved(ctx context.Context, rec optimizer.Recommendation) error {
 	action := rec.Details["action"]
+	execute := c.executorFor(action)
+	if execute == nil {
+		return fmt.Errorf("unsupported spot action %q", action)
+	}
+	return execute(ctx, rec)
+}
+
+// executorFor returns the sub-component method that applies action, or nil
+// when no sub-component acts on it.
+func (c *Controller) executorFor(action string) func(context.Context, optimizer.Recommendation) error {
 	switch action {
 	case "convert-to-spot", "convert-to-ondemand", "adjust-spot-mix":
-		return c.mixer.Execute(ctx, rec)
+		return c.mixer.Execute
 	case "drain-spot-interruption":
-		return c.interruption.Execute(ctx, rec)
+		return c.interruption.Execute
 	case "diversify-spot-types":
-		return c.diversity.Execute(ctx, rec)
-	default:
-		return nil
+		return c.diversity.Execute
 	}
+	return nil
 }
 
 func (c *Controller) run(ctx context.Context) {
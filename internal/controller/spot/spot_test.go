@@ -711,3 +711,26 @@ func TestLabelSet(t *testing.T) {
 		t.Errorf("Get(missing) = %q, want empty", ls.Get("missing"))
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Controller Execution Tests
+// ---------------------------------------------------------------------------
+
+func TestController_UnsupportedAction(t *testing.T) {
+	cfg := defaultSpotConfig()
+	cfg.Mode = "active"
+	c := &Controller{config: cfg, mixer: NewMixer(&stubProvider{}, cfg)}
+	rec := optimizer.Recommendation{AutoExecutable: true, Details: map[string]string{"action": "report-spot-savings"}}
+
+	if err := c.Execute(context.Background(), rec); err != nil {
+		t.Errorf("Execute() = %v, want a no-op for an action the controller does not act on", err)
+	}
+	if err := c.ExecuteApproved(context.Background(), rec); err == nil {
+		t.Error("ExecuteApproved() = nil, want an error so the approval is not marked executed")
+	}
+
+	rec.Details["action"] = "adjust-spot-mix"
+	if err := c.ExecuteApproved(context.Background(), rec); err != nil {
+		t.Errorf("ExecuteApproved(adjust-spot-mix) = %v", err)
+	}
+}
@@ -28,6 +28,7 @@ type Controller struct {
 	vertical    *VerticalScaler
 	coordinator *Coordinator
 	surge       *SurgeDetector
+	predictive  *PredictiveScaler
 }
 
 func NewController(mgr ctrl.Manager, st *state.ClusterState, guard *familylock.FamilyLockGuard, gate *aigate.AIGate, cfg *config.Config) *Controller {
@@ -42,6 +43,7 @@ func NewController(mgr ctrl.Manager, st *state.ClusterState, guard *familylock.F
 		vertical:    NewVerticalScaler(c, cfg),
 		coordinator: NewCoordinator(cfg),
 		surge:       NewSurgeDetector(cfg),
+		predictive:  NewPredictiveScaler(c, st.MetricsStore(), cfg),
 	}
 }
 
@@ -87,6 +89,15 @@ func (c *Controller) Analyze(ctx context.Context, snapshot *optimizer.ClusterSna
 		recs = append(recs, hRecs...)
 	}
 
+	// Predictive scaling ahead of forecast peaks
+	if c.config.WorkloadScaler.Predictive.Enabled {
+		pRecs, err := c.predictive.Analyze(ctx, snapshot)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, pRecs...)
+	}
+
 	// Coordinate to prevent conflicts
 	recs = c.coordinator.Resolve(recs)
 
@@ -104,11 +115,7 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 
 	// AI Gate validation — fail-closed for workload scaling changes
 	if c.gate.RequiresValidation(rec) {
-		valReq := aigate.ValidationRequest{
-			Action:         rec.Summary,
-			Recommendation: rec,
-			RiskFactors:    []string{fmt.Sprintf("Workload scaling: %s", rec.Details["scalingType"])},
-		}
+		valReq := c.state.GateRequest(ctx, rec, fmt.Sprintf("Workload scaling: %s", rec.Details["scalingType"]))
 		result, err := c.gate.Validate(ctx, valReq)
 		if err != nil || !result.Approved {
 			logger.Info("AI Gate rejected workload scaling", "recommendation", rec.ID)
@@ -116,8 +123,18 @@ func (c *Controller) Execute(ctx context.Context, rec optimizer.Recommendation)
 		}
 	}
 
+	return c.ExecuteApproved(ctx, rec)
+}
+
+// ExecuteApproved applies a scaling change with the scaler named by the
+// recommendation's scalingType; predictive horizontal changes go to the
+// predictive scaler.
+func (c *Controller) ExecuteApproved(ctx context.Context, rec optimizer.Recommendation) error {
 	switch rec.Details["scalingType"] {
 	case "horizontal":
+		if rec.Details["reason"] == "predictive" {
+			return c.predictive.Execute(ctx, rec)
+		}
 		return c.horizontal.Execute(ctx, rec)
 	case "vertical":
 		return c.vertical.Execute(ctx, rec)
@@ -0,0 +1,139 @@
+package workloadscaler
+
+import (
+	"math"
+	"time"
+
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+)
+
+const (
+	// forecastStep is the resolution of usage history and forecasts.
+	forecastStep = time.Hour
+	hoursPerWeek = 7 * 24
+
+	// minSlotCoverage is the share of the hour-of-week slots the history
+	// must cover before a workload is forecast.
+	minSlotCoverage = 0.9
+
+	// levelWindow is how much recent history scales the seasonal profile
+	// to the workload's current level, clamped to [minLevel, maxLevel].
+	levelWindow = 24 * time.Hour
+	minLevel    = 0.5
+	maxLevel    = 2.0
+)
+
+// seasonalForecast predicts CPU usage for an hour from the mean usage of the
+// same hour of the week, scaled by how the last day compared to its slots.
+type seasonalForecast struct {
+	slots [hoursPerWeek]float64 // mean millicores per hour-of-week
+	level float64
+}
+
+// hourOfWeek returns the slot of t, 0 being Sunday 00:00-01:00 UTC.
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// fitSeasonal builds a forecast from hourly usage samples. It returns false
+// when the samples cover too few hours of the week.
+func fitSeasonal(samples []pkgmetrics.UsageSample, now time.Time) (*seasonalForecast, bool) {
+	var sums [hoursPerWeek]float64
+	var counts [hoursPerWeek]int
+	for _, s := range samples {
+		slot := hourOfWeek(s.Timestamp)
+		sums[slot] += float64(s.CPUUsage)
+		counts[slot]++
+	}
+
+	f := &seasonalForecast{level: 1}
+	covered := 0
+	for i := range f.slots {
+		if counts[i] > 0 {
+			f.slots[i] = sums[i] / float64(counts[i])
+			covered++
+		}
+	}
+	if float64(covered) < minSlotCoverage*hoursPerWeek {
+		return nil, false
+	}
+
+	var actual, expected float64
+	for _, s := range samples {
+		if now.Sub(s.Timestamp) <= levelWindow {
+			actual += float64(s.CPUUsage)
+			expected += f.slots[hourOfWeek(s.Timestamp)]
+		}
+	}
+	if actual > 0 && expected > 0 {
+		f.level = math.Max(minLevel, math.Min(maxLevel, actual/expected))
+	}
+	return f, true
+}
+
+// at returns the forecast for the hour containing t.
+func (f *seasonalForecast) at(t time.Time) float64 {
+	return f.slots[hourOfWeek(t)] * f.level
+}
+
+// peak returns the highest forecast of the hours overlapping [from, to].
+func (f *seasonalForecast) peak(from, to time.Time) float64 {
+	var p float64
+	for t := from.Truncate(forecastStep); !t.After(to); t = t.Add(forecastStep) {
+		p = math.Max(p, f.at(t))
+	}
+	return p
+}
+
+// forecastAccuracy scores hourly forecasts against the usage that followed.
+type forecastAccuracy struct {
+	pending map[time.Time]float64 // hour start → forecast made before it began
+	errors  []float64             // absolute percentage errors of the last week of scored hours
+}
+
+func newForecastAccuracy() *forecastAccuracy {
+	return &forecastAccuracy{pending: map[time.Time]float64{}}
+}
+
+// predict records the forecast for the hour starting at hour, unless one
+// was already made.
+func (a *forecastAccuracy) predict(hour time.Time, value float64) {
+	if _, ok := a.pending[hour]; !ok {
+		a.pending[hour] = value
+	}
+}
+
+// score compares the forecasts of hours that ended by now with the usage in
+// samples. Hours without usage are dropped unscored.
+func (a *forecastAccuracy) score(samples []pkgmetrics.UsageSample, now time.Time) {
+	actual := make(map[time.Time]int64, len(samples))
+	for _, s := range samples {
+		actual[s.Timestamp] = s.CPUUsage
+	}
+	for hour, predicted := range a.pending {
+		if hour.Add(forecastStep).After(now) {
+			continue
+		}
+		delete(a.pending, hour)
+		if v := actual[hour]; v > 0 {
+			a.errors = append(a.errors, math.Abs(predicted-float64(v))/float64(v))
+		}
+	}
+	if len(a.errors) > hoursPerWeek {
+		a.errors = a.errors[len(a.errors)-hoursPerWeek:]
+	}
+}
+
+// errorPct returns the mean absolute percentage error of the scored hours
+// and their count. A nil forecastAccuracy has scored none.
+func (a *forecastAccuracy) errorPct() (float64, int) {
+	if a == nil || len(a.errors) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, e := range a.errors {
+		sum += e
+	}
+	return sum / float64(len(a.errors)) * 100, len(a.errors)
+}
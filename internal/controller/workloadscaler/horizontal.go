@@ -9,6 +9,7 @@ import (
 	"time"
 
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -17,14 +18,26 @@ import (
 	"github.com/koptimizer/koptimizer/pkg/optimizer"
 )
 
-// HorizontalScaler manages horizontal pod autoscaling.
+// HorizontalScaler manages horizontal pod autoscaling. Besides raising the
+// maxReplicas of HPAs stuck at their maximum, it records the replicas and
+// CPU utilization of every HPA over workloadScaler.hpaTuning.historyWindow
+// and recommends tuning their CPU target, minReplicas and scale-down
+// behavior from that history. The history is in memory and starts over
+// when the leader changes.
 type HorizontalScaler struct {
-	client client.Client
-	config *config.Config
+	client  client.Client
+	config  *config.Config
+	now     func() time.Time
+	history *hpaHistory
 }
 
 func NewHorizontalScaler(c client.Client, cfg *config.Config) *HorizontalScaler {
-	return &HorizontalScaler{client: c, config: cfg}
+	return &HorizontalScaler{
+		client:  c,
+		config:  cfg,
+		now:     time.Now,
+		history: newHPAHistory(cfg.WorkloadScaler.HPATuning.HistoryWindow),
+	}
 }
 
 func (h *HorizontalScaler) Analyze(ctx context.Context, snapshot *optimizer.ClusterSnapshot) ([]optimizer.Recommendation, error) {
@@ -38,11 +51,33 @@ func (h *HorizontalScaler) Analyze(ctx context.Context, snapshot *optimizer.Clus
 
 	// For workloads without HPA but with multiple replicas, suggest creating one
 	// For workloads with HPA, check if targets are optimal
-	for _, hpa := range hpaList.Items {
+	tuning := h.config.WorkloadScaler.HPATuning
+	now := h.now()
+	seen := map[string]bool{}
+	for i := range hpaList.Items {
+		hpa := &hpaList.Items[i]
 		if h.isExcluded(hpa.Namespace) {
 			continue
 		}
 
+		// KEDA rewrites the HPAs it owns from their ScaledObject.
+		if tuning.Enabled && !ownedByScaledObject(hpa) {
+			key := hpa.Namespace + "/" + hpa.Name
+			seen[key] = true
+			obs := h.history.observe(hpa, now)
+			if !h.history.coolingDown(key, now) {
+				tuner := &hpaTuner{
+					cfg:        tuning,
+					hpa:        hpa,
+					obs:        obs,
+					podCost:    replicaMonthlyCost(snapshot, hpa.Namespace, hpa.Spec.ScaleTargetRef.Kind, hpa.Spec.ScaleTargetRef.Name),
+					now:        now,
+					predictive: hpa.Annotations[AnnPredictiveOriginalMinReplicas] != "",
+				}
+				recs = append(recs, tuner.recommendations()...)
+			}
+		}
+
 		// Check if HPA is hitting min/max bounds frequently
 		if hpa.Status.CurrentReplicas == hpa.Spec.MaxReplicas {
 			// Surge detection: if replicas are at max, this is a surge scenario.
@@ -89,6 +124,7 @@ func (h *HorizontalScaler) Analyze(ctx context.Context, snapshot *optimizer.Clus
 			})
 		}
 	}
+	h.history.retain(seen)
 
 	return recs, nil
 }
@@ -101,6 +137,10 @@ func (h *HorizontalScaler) Execute(ctx context.Context, rec optimizer.Recommenda
 		return nil
 	}
 
+	if rec.Details["tuning"] != "" {
+		return h.executeTuning(ctx, rec)
+	}
+
 	hpaName := rec.Details["hpaName"]
 	hpaNamespace := rec.Details["hpaNamespace"]
 	newMaxStr := rec.Details["newMaxReplicas"]
@@ -140,6 +180,77 @@ func (h *HorizontalScaler) Execute(ctx context.Context, rec optimizer.Recommenda
 	return nil
 }
 
+// executeTuning applies an HPA tuning recommendation. Request findings
+// need changes to the workload and are never applied. A tuned HPA starts
+// its history over and is not tuned again for hpaTuningCooldown.
+func (h *HorizontalScaler) executeTuning(ctx context.Context, rec optimizer.Recommendation) error {
+	logger := log.FromContext(ctx).WithName("horizontal-scaler")
+	hpaName := rec.Details["hpaName"]
+	hpaNamespace := rec.Details["hpaNamespace"]
+	if hpaName == "" || hpaNamespace == "" {
+		return fmt.Errorf("missing HPA details in recommendation: hpaName=%q, hpaNamespace=%q", hpaName, hpaNamespace)
+	}
+
+	var key, value string
+	switch tuning := rec.Details["tuning"]; tuning {
+	case TuningTarget:
+		key = "suggestedTarget"
+	case TuningBehavior:
+		key = "suggestedStabilizationSeconds"
+	case TuningMinReplicas:
+		key = "suggestedMinReplicas"
+	default:
+		return fmt.Errorf("HPA tuning %q cannot be applied automatically", tuning)
+	}
+	value = rec.Details[key]
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("parsing %s %q: %w", key, value, err)
+	}
+	v := int32(n)
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	if err := h.client.Get(ctx, types.NamespacedName{Namespace: hpaNamespace, Name: hpaName}, hpa); err != nil {
+		return fmt.Errorf("getting HPA %s/%s: %w", hpaNamespace, hpaName, err)
+	}
+	original := hpa.DeepCopy()
+
+	switch rec.Details["tuning"] {
+	case TuningTarget:
+		found := false
+		for i := range hpa.Spec.Metrics {
+			m := &hpa.Spec.Metrics[i]
+			if m.Type == autoscalingv2.ResourceMetricSourceType && m.Resource != nil &&
+				m.Resource.Name == corev1.ResourceCPU && m.Resource.Target.Type == autoscalingv2.UtilizationMetricType {
+				m.Resource.Target.AverageUtilization = &v
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("HPA %s/%s has no CPU utilization target", hpaNamespace, hpaName)
+		}
+	case TuningBehavior:
+		if hpa.Spec.Behavior == nil {
+			hpa.Spec.Behavior = &autoscalingv2.HorizontalPodAutoscalerBehavior{}
+		}
+		if hpa.Spec.Behavior.ScaleDown == nil {
+			hpa.Spec.Behavior.ScaleDown = &autoscalingv2.HPAScalingRules{}
+		}
+		hpa.Spec.Behavior.ScaleDown.StabilizationWindowSeconds = &v
+	case TuningMinReplicas:
+		hpa.Spec.MinReplicas = &v
+	}
+
+	// spec.metrics is a list, so the patch carries the whole of it.
+	if err := h.client.Patch(ctx, hpa, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("patching HPA %s/%s %s to %d: %w", hpaNamespace, hpaName, rec.Details["tuning"], v, err)
+	}
+
+	h.history.tuned(hpaNamespace+"/"+hpaName, h.now())
+	logger.Info("Tuned HPA", "hpa", hpaNamespace+"/"+hpaName, "tuning", rec.Details["tuning"], "value", v)
+	return nil
+}
+
 func (h *HorizontalScaler) isExcluded(namespace string) bool {
 	for _, ns := range h.config.WorkloadScaler.ExcludeNamespaces {
 		if ns == namespace {
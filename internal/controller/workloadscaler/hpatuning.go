@@ -0,0 +1,524 @@
+package workloadscaler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// HPA tuning kinds, the "tuning" detail of a tuning recommendation.
+const (
+	TuningTarget      = "target"
+	TuningBehavior    = "behavior"
+	TuningMinReplicas = "minReplicas"
+	TuningRequests    = "requests"
+)
+
+const (
+	// hpaSampleInterval is the least time between two recorded observations
+	// of an HPA.
+	hpaSampleInterval = time.Minute
+
+	// hpaTuningCooldown is how long an HPA is not tuned again after a
+	// tuning change was applied to it.
+	hpaTuningCooldown = 24 * time.Hour
+
+	// minSteeringSamples is how many observations with the HPA free to
+	// scale both ways a target recommendation needs.
+	minSteeringSamples = 30
+
+	// Recommended CPU targets stay within [minTargetUtilPct, maxTargetUtilPct]
+	// and must differ from the current target by minTargetChangePct.
+	minTargetUtilPct   = 30
+	maxTargetUtilPct   = 85
+	minTargetChangePct = 10
+
+	// idleShare is the share of observations an HPA must spend at
+	// minReplicas below half its target to count as parked while idle.
+	idleShare = 0.9
+
+	// defaultScaleDownStabilization is the stabilization window the HPA
+	// controller applies when behavior.scaleDown does not set one.
+	defaultScaleDownStabilization = 300
+	minFlapStabilization          = 600
+	maxFlapStabilization          = 3600
+)
+
+// hpaObservation is the state of an HPA at one point in time.
+type hpaObservation struct {
+	at       time.Time
+	replicas int32
+	min      int32
+	max      int32
+	cpuUtil  float64 // average CPU utilization of requests in %, -1 when not reported
+
+	// The CPU target and scale-down stabilization window the HPA scaled
+	// under; observations taken under other values are dropped.
+	target        int32 // 0 without a CPU utilization target
+	stabilization int32 // -1 when scale-down is disabled
+}
+
+// hpaHistory records observations of HPAs over a sliding window, and when
+// each HPA was last tuned.
+type hpaHistory struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	obs     map[string][]hpaObservation // namespace/name → observations, oldest first
+	tunedAt map[string]time.Time        // namespace/name → last applied tuning change
+}
+
+func newHPAHistory(window time.Duration) *hpaHistory {
+	return &hpaHistory{window: window, obs: map[string][]hpaObservation{}, tunedAt: map[string]time.Time{}}
+}
+
+// observe records the current state of hpa, at most once per
+// hpaSampleInterval, and drops observations older than the window. A
+// changed CPU target or stabilization window starts the history over, as
+// the earlier observations no longer describe how the HPA behaves.
+func (h *hpaHistory) observe(hpa *autoscalingv2.HorizontalPodAutoscaler, now time.Time) []hpaObservation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := hpa.Namespace + "/" + hpa.Name
+	obs := h.obs[key]
+	target, _ := cpuTarget(hpa)
+	stabilization, ok := scaleDownStabilization(hpa)
+	if !ok {
+		stabilization = -1
+	}
+	if n := len(obs); n > 0 && (obs[n-1].target != target || obs[n-1].stabilization != stabilization) {
+		obs = nil
+	}
+	if n := len(obs); n == 0 || now.Sub(obs[n-1].at) >= hpaSampleInterval {
+		util := -1.0
+		if u, ok := currentCPUUtil(hpa); ok {
+			util = u
+		}
+		minReplicas := int32(1)
+		if hpa.Spec.MinReplicas != nil {
+			minReplicas = *hpa.Spec.MinReplicas
+		}
+		obs = append(obs, hpaObservation{
+			at:            now,
+			replicas:      hpa.Status.CurrentReplicas,
+			min:           minReplicas,
+			max:           hpa.Spec.MaxReplicas,
+			cpuUtil:       util,
+			target:        target,
+			stabilization: stabilization,
+		})
+	}
+	cutoff := now.Add(-h.window)
+	i := 0
+	for i < len(obs) && obs[i].at.Before(cutoff) {
+		i++
+	}
+	obs = obs[i:]
+	h.obs[key] = obs
+	return obs
+}
+
+// tuned starts the history of an HPA over after a tuning change was
+// applied to it at now.
+func (h *hpaHistory) tuned(key string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.obs, key)
+	h.tunedAt[key] = now
+}
+
+// coolingDown reports whether the HPA was tuned within hpaTuningCooldown.
+func (h *hpaHistory) coolingDown(key string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	at, ok := h.tunedAt[key]
+	return ok && now.Sub(at) < hpaTuningCooldown
+}
+
+// retain forgets HPAs whose key is not in seen.
+func (h *hpaHistory) retain(seen map[string]bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key := range h.obs {
+		if !seen[key] {
+			delete(h.obs, key)
+		}
+	}
+	for key := range h.tunedAt {
+		if !seen[key] {
+			delete(h.tunedAt, key)
+		}
+	}
+}
+
+// cpuTarget returns the averageUtilization target of hpa's CPU resource
+// metric.
+func cpuTarget(hpa *autoscalingv2.HorizontalPodAutoscaler) (int32, bool) {
+	for _, m := range hpa.Spec.Metrics {
+		if m.Type == autoscalingv2.ResourceMetricSourceType && m.Resource != nil &&
+			m.Resource.Name == corev1.ResourceCPU &&
+			m.Resource.Target.Type == autoscalingv2.UtilizationMetricType &&
+			m.Resource.Target.AverageUtilization != nil {
+			return *m.Resource.Target.AverageUtilization, true
+		}
+	}
+	return 0, false
+}
+
+// currentCPUUtil returns the CPU utilization the HPA last observed.
+func currentCPUUtil(hpa *autoscalingv2.HorizontalPodAutoscaler) (float64, bool) {
+	for _, m := range hpa.Status.CurrentMetrics {
+		if m.Type == autoscalingv2.ResourceMetricSourceType && m.Resource != nil &&
+			m.Resource.Name == corev1.ResourceCPU && m.Resource.Current.AverageUtilization != nil {
+			return float64(*m.Resource.Current.AverageUtilization), true
+		}
+	}
+	return 0, false
+}
+
+// scaleDownStabilization returns the scale-down stabilization window of hpa
+// in seconds, and false when scale-down is disabled.
+func scaleDownStabilization(hpa *autoscalingv2.HorizontalPodAutoscaler) (int32, bool) {
+	b := hpa.Spec.Behavior
+	if b == nil || b.ScaleDown == nil {
+		return defaultScaleDownStabilization, true
+	}
+	if b.ScaleDown.SelectPolicy != nil && *b.ScaleDown.SelectPolicy == autoscalingv2.DisabledPolicySelect {
+		return 0, false
+	}
+	if b.ScaleDown.StabilizationWindowSeconds == nil {
+		return defaultScaleDownStabilization, true
+	}
+	return *b.ScaleDown.StabilizationWindowSeconds, true
+}
+
+// percentile returns the p-th percentile (0-1) of values, which it sorts.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	i := int(math.Ceil(p*float64(len(values)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return values[i]
+}
+
+// flaps counts the reversals of scaling direction in obs and the mean
+// replica swing of the reversed moves.
+func flaps(obs []hpaObservation) (int, float64) {
+	var reversals, swing int32
+	var lastDelta int32
+	for i := 1; i < len(obs); i++ {
+		d := obs[i].replicas - obs[i-1].replicas
+		if d == 0 {
+			continue
+		}
+		if (d > 0) != (lastDelta > 0) && lastDelta != 0 {
+			reversals++
+			if d < 0 {
+				swing -= d
+			} else {
+				swing += d
+			}
+		}
+		lastDelta = d
+	}
+	if reversals == 0 {
+		return 0, 0
+	}
+	return int(reversals), float64(swing) / float64(reversals)
+}
+
+// hpaTuner derives tuning recommendations for one HPA from its history.
+type hpaTuner struct {
+	cfg        config.HPATuningConfig
+	hpa        *autoscalingv2.HorizontalPodAutoscaler
+	obs        []hpaObservation
+	podCost    float64 // monthly cost of one replica in USD, 0 when unknown
+	now        time.Time
+	predictive bool // minReplicas is currently raised by predictive scaling
+}
+
+func (t *hpaTuner) recommendations() []optimizer.Recommendation {
+	var recs []optimizer.Recommendation
+	if rec, ok := t.requests(); ok {
+		// The utilization the other analyses rely on is meaningless.
+		return append(recs, rec)
+	}
+	if len(t.obs) < 2 || t.obs[len(t.obs)-1].at.Sub(t.obs[0].at) < t.cfg.MinHistory {
+		return nil
+	}
+	for _, analyze := range []func() (optimizer.Recommendation, bool){t.target, t.flapping, t.idleMin} {
+		if rec, ok := analyze(); ok {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// target compares the CPU utilization while the HPA was free to scale with
+// its target. A target the pods overshoot routinely is lowered so peaks stay
+// under cfg.MaxUtilizationPct; one they never approach is raised.
+func (t *hpaTuner) target() (optimizer.Recommendation, bool) {
+	target, ok := cpuTarget(t.hpa)
+	if !ok || target <= 0 {
+		return optimizer.Recommendation{}, false
+	}
+	var utils []float64
+	var replicas float64
+	for _, o := range t.obs {
+		if o.cpuUtil < 0 || o.replicas <= o.min || o.replicas >= o.max {
+			continue
+		}
+		utils = append(utils, o.cpuUtil)
+		replicas += float64(o.replicas)
+	}
+	if len(utils) < minSteeringSamples {
+		return optimizer.Recommendation{}, false
+	}
+	replicas /= float64(len(utils))
+	p95 := percentile(utils, 0.95)
+	if p95 <= 0 {
+		return optimizer.Recommendation{}, false
+	}
+
+	// The HPA keeps utilization near target; p95/target is how far above
+	// it the pods burst before new replicas arrive.
+	ratio := p95 / float64(target)
+	suggested := int32(math.Round(math.Max(minTargetUtilPct, math.Min(maxTargetUtilPct, t.cfg.MaxUtilizationPct/ratio))))
+	if abs32(suggested-target) < minTargetChangePct {
+		return optimizer.Recommendation{}, false
+	}
+
+	// Replica count scales with target/suggested for the same load.
+	change := replicas * (float64(target)/float64(suggested) - 1) * t.podCost
+	lower := suggested < target
+	rec := t.recommendation(TuningTarget, lower, change,
+		fmt.Sprintf("HPA %s/%s CPU target %d%% → %d%%: p95 utilization is %.0f%% while scaling", t.hpa.Namespace, t.hpa.Name, target, suggested, p95),
+		fmt.Sprintf("Set the CPU averageUtilization target of HPA %s/%s from %d%% to %d%%", t.hpa.Namespace, t.hpa.Name, target, suggested))
+	rec.Details["currentTarget"] = fmt.Sprintf("%d", target)
+	rec.Details["suggestedTarget"] = fmt.Sprintf("%d", suggested)
+	rec.Details["p95Utilization"] = fmt.Sprintf("%.1f", p95)
+	return rec, true
+}
+
+// flapping recommends a longer scale-down stabilization window when the HPA
+// reverses direction more often than cfg.FlapsPerHour.
+func (t *hpaTuner) flapping() (optimizer.Recommendation, bool) {
+	current, ok := scaleDownStabilization(t.hpa)
+	if !ok {
+		return optimizer.Recommendation{}, false
+	}
+	reversals, swing := flaps(t.obs)
+	hours := t.obs[len(t.obs)-1].at.Sub(t.obs[0].at).Hours()
+	rate := float64(reversals) / hours
+	if rate < t.cfg.FlapsPerHour {
+		return optimizer.Recommendation{}, false
+	}
+	suggested := current * 2
+	if suggested < minFlapStabilization {
+		suggested = minFlapStabilization
+	}
+	if suggested > maxFlapStabilization {
+		suggested = maxFlapStabilization
+	}
+	if suggested <= current {
+		return optimizer.Recommendation{}, false
+	}
+
+	// Replicas are held for the swing a little longer instead of being
+	// removed and re-added; half the swing is a rough average.
+	change := swing / 2 * t.podCost
+	rec := t.recommendation(TuningBehavior, true, change,
+		fmt.Sprintf("HPA %s/%s flaps %.1f times per hour, lengthen scale-down stabilization %ds → %ds", t.hpa.Namespace, t.hpa.Name, rate, current, suggested),
+		fmt.Sprintf("Set behavior.scaleDown.stabilizationWindowSeconds of HPA %s/%s from %d to %d", t.hpa.Namespace, t.hpa.Name, current, suggested))
+	rec.Details["currentStabilizationSeconds"] = fmt.Sprintf("%d", current)
+	rec.Details["suggestedStabilizationSeconds"] = fmt.Sprintf("%d", suggested)
+	rec.Details["flapsPerHour"] = fmt.Sprintf("%.2f", rate)
+	return rec, true
+}
+
+// idleMin recommends a lower minReplicas for an HPA that sits at
+// minReplicas well under its target: the floor, not load, sets its size.
+func (t *hpaTuner) idleMin() (optimizer.Recommendation, bool) {
+	target, ok := cpuTarget(t.hpa)
+	if !ok || target <= 0 || t.predictive || t.hpa.Spec.MinReplicas == nil {
+		return optimizer.Recommendation{}, false
+	}
+	minReplicas := *t.hpa.Spec.MinReplicas
+	if minReplicas <= t.cfg.MinReplicasFloor {
+		return optimizer.Recommendation{}, false
+	}
+	var idle int
+	var utils []float64
+	for _, o := range t.obs {
+		if o.cpuUtil < 0 {
+			continue
+		}
+		utils = append(utils, o.cpuUtil)
+		if o.replicas <= o.min && o.cpuUtil < float64(target)/2 {
+			idle++
+		}
+	}
+	if len(utils) == 0 || float64(idle) < idleShare*float64(len(utils)) {
+		return optimizer.Recommendation{}, false
+	}
+
+	// Enough replicas to keep the peak load of the window at target.
+	peak := percentile(utils, 1)
+	suggested := int32(math.Ceil(float64(minReplicas) * peak / float64(target)))
+	if suggested < t.cfg.MinReplicasFloor {
+		suggested = t.cfg.MinReplicasFloor
+	}
+	if suggested >= minReplicas {
+		return optimizer.Recommendation{}, false
+	}
+
+	change := -float64(minReplicas-suggested) * t.podCost
+	rec := t.recommendation(TuningMinReplicas, false, change,
+		fmt.Sprintf("HPA %s/%s is parked at minReplicas %d with peak CPU %.0f%% of a %d%% target, lower minReplicas to %d", t.hpa.Namespace, t.hpa.Name, minReplicas, peak, target, suggested),
+		fmt.Sprintf("Set minReplicas of HPA %s/%s from %d to %d", t.hpa.Namespace, t.hpa.Name, minReplicas, suggested))
+	rec.Details["currentMinReplicas"] = fmt.Sprintf("%d", minReplicas)
+	rec.Details["suggestedMinReplicas"] = fmt.Sprintf("%d", suggested)
+	rec.Details["peakUtilization"] = fmt.Sprintf("%.1f", peak)
+	return rec, true
+}
+
+// requests reports HPAs whose CPU target cannot work with the pods'
+// requests: the HPA cannot compute utilization because a container has no
+// CPU request, or the pods use several times their request even at
+// maxReplicas, so utilization says nothing about load.
+func (t *hpaTuner) requests() (optimizer.Recommendation, bool) {
+	target, ok := cpuTarget(t.hpa)
+	if !ok || target <= 0 {
+		return optimizer.Recommendation{}, false
+	}
+	var problem string
+	for _, c := range t.hpa.Status.Conditions {
+		if c.Type == autoscalingv2.ScalingActive && c.Status == corev1.ConditionFalse &&
+			strings.Contains(c.Message, "missing request") {
+			problem = "a container has no CPU request, so the HPA cannot compute utilization"
+		}
+	}
+	if problem == "" {
+		var utils []float64
+		for _, o := range t.obs {
+			if o.cpuUtil >= 0 {
+				utils = append(utils, o.cpuUtil)
+			}
+		}
+		if len(utils) < minSteeringSamples {
+			return optimizer.Recommendation{}, false
+		}
+		if median := percentile(utils, 0.5); median > math.Max(100, 2*float64(target)) {
+			problem = fmt.Sprintf("median CPU utilization is %.0f%% of requests against a %d%% target", median, target)
+		}
+	}
+	if problem == "" {
+		return optimizer.Recommendation{}, false
+	}
+
+	// Replicas × request stays about the same once the HPA re-settles, so
+	// no cost change is estimated.
+	rec := t.recommendation(TuningRequests, false, 0,
+		fmt.Sprintf("HPA %s/%s CPU target is meaningless: %s", t.hpa.Namespace, t.hpa.Name, problem),
+		fmt.Sprintf("Set CPU requests on every container of %s %s/%s close to typical per-replica usage", t.hpa.Spec.ScaleTargetRef.Kind, t.hpa.Namespace, t.hpa.Spec.ScaleTargetRef.Name),
+		fmt.Sprintf("Review the CPU target of HPA %s/%s once requests are set", t.hpa.Namespace, t.hpa.Name))
+	rec.Details["problem"] = problem
+	return rec, true
+}
+
+// recommendation builds a tuning recommendation. Low-risk changes only add
+// capacity or slow scale-down and are auto-executable when auto-apply is on.
+// monthlyChange is the estimated monthly cost change in USD.
+func (t *hpaTuner) recommendation(tuning string, lowRisk bool, monthlyChange float64, summary string, steps ...string) optimizer.Recommendation {
+	risk, priority := "medium", optimizer.PriorityMedium
+	if lowRisk {
+		risk, priority = "low", optimizer.PriorityLow
+	}
+	rec := optimizer.Recommendation{
+		ID:              fmt.Sprintf("hpa-tune-%s-%s-%s-%d", tuning, t.hpa.Namespace, t.hpa.Name, t.now.Unix()),
+		Type:            optimizer.RecommendationWorkloadScale,
+		Priority:        priority,
+		AutoExecutable:  lowRisk && t.cfg.AutoApply,
+		TargetKind:      t.hpa.Spec.ScaleTargetRef.Kind,
+		TargetName:      t.hpa.Spec.ScaleTargetRef.Name,
+		TargetNamespace: t.hpa.Namespace,
+		Summary:         summary,
+		ActionSteps:     steps,
+		EstimatedImpact: optimizer.ImpactEstimate{
+			MonthlyCostChangeUSD: monthlyChange,
+			PodsAffected:         int(t.hpa.Status.CurrentReplicas),
+			RiskLevel:            risk,
+		},
+		Details: map[string]string{
+			"scalingType":     "horizontal",
+			"tuning":          tuning,
+			"hpaName":         t.hpa.Name,
+			"hpaNamespace":    t.hpa.Namespace,
+			"currentReplicas": fmt.Sprintf("%d", t.hpa.Status.CurrentReplicas),
+		},
+		CreatedAt: t.now,
+	}
+	if monthlyChange < 0 {
+		rec.EstimatedSaving = optimizer.SavingEstimate{
+			MonthlySavingsUSD: -monthlyChange,
+			AnnualSavingsUSD:  -monthlyChange * 12,
+			Currency:          "USD",
+		}
+	}
+	return rec
+}
+
+// replicaMonthlyCost returns the mean monthly cost in USD of the running
+// pods of a workload in snapshot, each charged the share of its node's
+// cost that its CPU and memory requests take. It returns 0 when no pod's
+// node has a known cost.
+func replicaMonthlyCost(snapshot *optimizer.ClusterSnapshot, namespace, kind, name string) float64 {
+	nodes := make(map[string]*optimizer.NodeInfo, len(snapshot.Nodes))
+	for i := range snapshot.Nodes {
+		if n := snapshot.Nodes[i].Node; n != nil {
+			nodes[n.Name] = &snapshot.Nodes[i]
+		}
+	}
+	var total float64
+	var pods int
+	for _, pod := range snapshot.Pods {
+		if pod.Pod == nil || pod.Pod.Namespace != namespace || pod.Pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		if k, n := ownerWorkload(pod); k != kind || n != name {
+			continue
+		}
+		node, ok := nodes[pod.Pod.Spec.NodeName]
+		if !ok || node.HourlyCostUSD <= 0 || node.CPUCapacity <= 0 || node.MemoryCapacity <= 0 {
+			continue
+		}
+		share := (float64(pod.CPURequest)/float64(node.CPUCapacity) + float64(pod.MemoryRequest)/float64(node.MemoryCapacity)) / 2
+		total += node.HourlyCostUSD * cost.HoursPerMonth * share
+		pods++
+	}
+	if pods == 0 {
+		return 0
+	}
+	return total / float64(pods)
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
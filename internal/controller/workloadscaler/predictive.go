@@ -0,0 +1,479 @@
+package workloadscaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	intmetrics "github.com/koptimizer/koptimizer/internal/metrics"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+const (
+	// AnnPredictiveOriginalMinReplicas holds, on an HPA raised ahead of a
+	// forecast peak, the minReplicas to return to afterwards.
+	AnnPredictiveOriginalMinReplicas = "koptimizer.io/predictive-original-min-replicas"
+	// AnnPredictiveOriginalReplicas holds, on a Deployment or StatefulSet
+	// without an HPA scaled up ahead of a forecast peak, the replica count
+	// to return to afterwards.
+	AnnPredictiveOriginalReplicas = "koptimizer.io/predictive-original-replicas"
+
+	// forecastHistory is how far back usage is read to fit forecasts. The
+	// metrics store's retention usually ends it sooner.
+	forecastHistory = 4 * 7 * 24 * time.Hour
+
+	// minScoredHours is how many hours a workload's forecast must have been
+	// scored against actual usage before its raises are auto-executed.
+	minScoredHours = 24
+
+	// podNameSuffixLen is the length of the random suffix of generated pod
+	// names.
+	podNameSuffixLen = 5
+)
+
+// predictiveWorkload is a Deployment or StatefulSet seen in the snapshot.
+type predictiveWorkload struct {
+	namespace, kind, name string
+	cpuRequest            int64 // mean CPU request per pod, millicores
+}
+
+func (w predictiveWorkload) key() string { return w.namespace + "/" + w.kind + "/" + w.name }
+
+// PredictiveScaler pre-scales workloads ahead of the CPU peaks forecast from
+// their weekly usage pattern in the metrics store, and scales them back once
+// the peak has passed. Workloads with an HPA get their minReplicas raised;
+// others get their replica count raised. The value to return to is kept in
+// an annotation, and scaling never goes below it.
+type PredictiveScaler struct {
+	client client.Client
+	store  *intmetrics.Store
+	config *config.Config
+	now    func() time.Time
+
+	mu       sync.Mutex
+	fittedAt time.Time                    // hour the forecasts were fitted in
+	fits     map[string]*seasonalForecast // workload key → forecast
+	accuracy map[string]*forecastAccuracy // workload key → scored forecasts
+}
+
+func NewPredictiveScaler(c client.Client, store *intmetrics.Store, cfg *config.Config) *PredictiveScaler {
+	return &PredictiveScaler{
+		client:   c,
+		store:    store,
+		config:   cfg,
+		now:      time.Now,
+		fits:     map[string]*seasonalForecast{},
+		accuracy: map[string]*forecastAccuracy{},
+	}
+}
+
+// Analyze returns a recommendation for every forecast workload whose
+// replicas should change for the peak forecast within the lookahead.
+func (p *PredictiveScaler) Analyze(ctx context.Context, snapshot *optimizer.ClusterSnapshot) ([]optimizer.Recommendation, error) {
+	if p.store == nil {
+		return nil, nil
+	}
+	now := p.now()
+	workloads := p.workloads(snapshot)
+	p.refit(ctx, workloads, now)
+
+	hpaList := &autoscalingv2.HorizontalPodAutoscalerList{}
+	if err := p.client.List(ctx, hpaList); err != nil {
+		return nil, fmt.Errorf("listing HPAs: %w", err)
+	}
+	hpas := make(map[string]*autoscalingv2.HorizontalPodAutoscaler, len(hpaList.Items))
+	for i := range hpaList.Items {
+		hpa := &hpaList.Items[i]
+		ref := hpa.Spec.ScaleTargetRef
+		hpas[hpa.Namespace+"/"+ref.Kind+"/"+ref.Name] = hpa
+	}
+	logger := log.FromContext(ctx).WithName("predictive-scaler")
+
+	cfg := p.config.WorkloadScaler.Predictive
+	var recs []optimizer.Recommendation
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, wl := range workloads {
+		// Without a forecast, desired stays 0 so that a raised workload
+		// returns to its original value.
+		var peak float64
+		var desired int32
+		if f, ok := p.fits[wl.key()]; ok {
+			peak = f.peak(now, now.Add(cfg.Lookahead))
+			desired = int32(math.Ceil(peak * cfg.Headroom / (float64(wl.cpuRequest) * cfg.TargetCPUUtilPct / 100)))
+			desired = max(desired, 1)
+		}
+		errPct, scored := p.accuracy[wl.key()].errorPct()
+
+		var rec *optimizer.Recommendation
+		if hpa, ok := hpas[wl.key()]; ok {
+			if ownedByScaledObject(hpa) {
+				continue // KEDA rewrites its HPAs
+			}
+			rec = p.hpaRecommendation(wl, hpa, desired)
+		} else {
+			var err error
+			if rec, err = p.replicasRecommendation(ctx, wl, desired); err != nil {
+				logger.V(1).Info("Skipping predictive scaling", "workload", wl.key(), "error", err)
+				continue
+			}
+		}
+		if rec == nil {
+			continue
+		}
+
+		rec.ID = fmt.Sprintf("predictive-%s-%s-%s-%d", wl.namespace, wl.kind, wl.name, now.Unix())
+		rec.Type = optimizer.RecommendationWorkloadScale
+		rec.TargetKind = wl.kind
+		rec.TargetName = wl.name
+		rec.TargetNamespace = wl.namespace
+		rec.CreatedAt = now
+		rec.Details["scalingType"] = "horizontal"
+		rec.Details["reason"] = "predictive"
+		rec.Details["forecastPeakCPU"] = fmt.Sprintf("%d", int64(peak))
+		rec.Details["forecastErrorPct"] = fmt.Sprintf("%.1f", errPct)
+		rec.Details["scoredHours"] = fmt.Sprintf("%d", scored)
+		if rec.Details["direction"] == "up" {
+			// Raises need a forecast that has proven itself; returning to
+			// the original value is always safe.
+			rec.Priority = optimizer.PriorityHigh
+			rec.AutoExecutable = scored >= minScoredHours && errPct <= cfg.MaxForecastErrorPct
+		} else {
+			rec.Priority = optimizer.PriorityMedium
+			rec.AutoExecutable = true
+		}
+		recs = append(recs, *rec)
+	}
+	return recs, nil
+}
+
+// hpaRecommendation moves an HPA's minReplicas towards desired, never below
+// its original value nor above maxReplicas.
+func (p *PredictiveScaler) hpaRecommendation(wl predictiveWorkload, hpa *autoscalingv2.HorizontalPodAutoscaler, desired int32) *optimizer.Recommendation {
+	current := int32(1)
+	if hpa.Spec.MinReplicas != nil {
+		current = *hpa.Spec.MinReplicas
+	}
+	original := originalReplicas(hpa.Annotations, AnnPredictiveOriginalMinReplicas, current)
+	target := min(max(desired, original), hpa.Spec.MaxReplicas)
+	if target == current {
+		return nil
+	}
+
+	direction, summary := "up", fmt.Sprintf("Raise HPA %s/%s minReplicas from %d to %d ahead of the forecast CPU peak", hpa.Namespace, hpa.Name, current, target)
+	if target < current {
+		direction, summary = "down", fmt.Sprintf("Lower HPA %s/%s minReplicas from %d to %d after the forecast CPU peak", hpa.Namespace, hpa.Name, current, target)
+	}
+	return &optimizer.Recommendation{
+		Summary:     summary,
+		ActionSteps: []string{fmt.Sprintf("Set HPA %s/%s minReplicas to %d", hpa.Namespace, hpa.Name, target)},
+		Details: map[string]string{
+			"target":           "hpaMinReplicas",
+			"direction":        direction,
+			"hpaName":          hpa.Name,
+			"hpaNamespace":     hpa.Namespace,
+			"currentReplicas":  fmt.Sprintf("%d", current),
+			"newReplicas":      fmt.Sprintf("%d", target),
+			"originalReplicas": fmt.Sprintf("%d", original),
+		},
+	}
+}
+
+// replicasRecommendation moves the replica count of a workload without an
+// HPA towards desired, never below its original value nor above
+// maxReplicasLimit. Workloads scaled to zero are left alone.
+func (p *PredictiveScaler) replicasRecommendation(ctx context.Context, wl predictiveWorkload, desired int32) (*optimizer.Recommendation, error) {
+	obj, replicas, err := p.getWorkload(ctx, wl.kind, wl.namespace, wl.name)
+	if err != nil {
+		return nil, err
+	}
+	current := int32(1)
+	if replicas != nil {
+		current = *replicas
+	}
+	annotations := obj.GetAnnotations()
+	if _, raised := annotations[AnnPredictiveOriginalReplicas]; current == 0 && !raised {
+		return nil, nil
+	}
+	original := originalReplicas(annotations, AnnPredictiveOriginalReplicas, current)
+	target := max(desired, original)
+	if limit := int32(p.config.WorkloadScaler.MaxReplicasLimit); limit > 0 {
+		target = max(min(target, limit), original)
+	}
+	if target == current {
+		return nil, nil
+	}
+
+	direction, summary := "up", fmt.Sprintf("Scale %s %s/%s from %d to %d replicas ahead of the forecast CPU peak", wl.kind, wl.namespace, wl.name, current, target)
+	if target < current {
+		direction, summary = "down", fmt.Sprintf("Scale %s %s/%s from %d back to %d replicas after the forecast CPU peak", wl.kind, wl.namespace, wl.name, current, target)
+	}
+	return &optimizer.Recommendation{
+		Summary:     summary,
+		ActionSteps: []string{fmt.Sprintf("Set %s %s/%s replicas to %d", wl.kind, wl.namespace, wl.name, target)},
+		Details: map[string]string{
+			"target":           "replicas",
+			"direction":        direction,
+			"currentReplicas":  fmt.Sprintf("%d", current),
+			"newReplicas":      fmt.Sprintf("%d", target),
+			"originalReplicas": fmt.Sprintf("%d", original),
+		},
+	}, nil
+}
+
+// Execute applies a predictive recommendation. The original value is
+// recorded in an annotation while the workload is above it and removed once
+// it is back.
+func (p *PredictiveScaler) Execute(ctx context.Context, rec optimizer.Recommendation) error {
+	logger := log.FromContext(ctx).WithName("predictive-scaler")
+
+	newReplicas, err := strconv.ParseInt(rec.Details["newReplicas"], 10, 32)
+	if err != nil {
+		return fmt.Errorf("parsing newReplicas %q: %w", rec.Details["newReplicas"], err)
+	}
+	original, err := strconv.ParseInt(rec.Details["originalReplicas"], 10, 32)
+	if err != nil {
+		return fmt.Errorf("parsing originalReplicas %q: %w", rec.Details["originalReplicas"], err)
+	}
+
+	var obj client.Object
+	var field, ann string
+	switch rec.Details["target"] {
+	case "hpaMinReplicas":
+		hpaName, hpaNamespace := rec.Details["hpaName"], rec.Details["hpaNamespace"]
+		if hpaName == "" || hpaNamespace == "" {
+			return fmt.Errorf("missing HPA details in recommendation: hpaName=%q, hpaNamespace=%q", hpaName, hpaNamespace)
+		}
+		obj = &autoscalingv2.HorizontalPodAutoscaler{}
+		obj.SetNamespace(hpaNamespace)
+		obj.SetName(hpaName)
+		field, ann = "minReplicas", AnnPredictiveOriginalMinReplicas
+	case "replicas":
+		switch rec.TargetKind {
+		case "Deployment":
+			obj = &appsv1.Deployment{}
+		case "StatefulSet":
+			obj = &appsv1.StatefulSet{}
+		default:
+			return fmt.Errorf("unsupported kind for predictive scaling: %s", rec.TargetKind)
+		}
+		obj.SetNamespace(rec.TargetNamespace)
+		obj.SetName(rec.TargetName)
+		field, ann = "replicas", AnnPredictiveOriginalReplicas
+	default:
+		return fmt.Errorf("unknown predictive scaling target %q", rec.Details["target"])
+	}
+
+	var annValue interface{} // null removes the annotation
+	if newReplicas != original {
+		annValue = strconv.FormatInt(original, 10)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{ann: annValue}},
+		"spec":     map[string]interface{}{field: newReplicas},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling predictive scaling patch: %w", err)
+	}
+	if err := p.client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return fmt.Errorf("patching %s/%s %s to %d: %w", obj.GetNamespace(), obj.GetName(), field, newReplicas, err)
+	}
+
+	logger.Info("Applied predictive scaling", "target", rec.TargetNamespace+"/"+rec.TargetKind+"/"+rec.TargetName,
+		field, newReplicas, "direction", rec.Details["direction"], "forecastErrorPct", rec.Details["forecastErrorPct"])
+	return nil
+}
+
+// refit fits the forecasts of workloads once per hour, scores the previous
+// forecasts against the usage that followed, and records the forecast of
+// the next hour for scoring. State of workloads that are gone is dropped.
+func (p *PredictiveScaler) refit(ctx context.Context, workloads []predictiveWorkload, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hour := now.Truncate(forecastStep)
+	if hour.Equal(p.fittedAt) {
+		return
+	}
+	p.fittedAt = hour
+	logger := log.FromContext(ctx).WithName("predictive-scaler")
+
+	seen := make(map[string]bool, len(workloads))
+	for _, wl := range workloads {
+		key := wl.key()
+		seen[key] = true
+		samples := p.store.GetWorkloadCPUSeries(wl.namespace, podNameMatcher(wl.kind, wl.name), forecastHistory, forecastStep)
+
+		acc, ok := p.accuracy[key]
+		if !ok {
+			acc = newForecastAccuracy()
+			p.accuracy[key] = acc
+		}
+		acc.score(samples, now)
+		if errPct, scored := acc.errorPct(); scored > 0 {
+			intmetrics.WorkloadForecastErrorPct.WithLabelValues(wl.namespace, wl.kind, wl.name).Set(errPct)
+		}
+
+		f, ok := fitSeasonal(samples, now)
+		if !ok {
+			delete(p.fits, key)
+			continue
+		}
+		p.fits[key] = f
+		next := hour.Add(forecastStep)
+		acc.predict(next, f.at(next))
+	}
+
+	for key := range p.accuracy {
+		if seen[key] {
+			continue
+		}
+		delete(p.accuracy, key)
+		delete(p.fits, key)
+		if parts := strings.SplitN(key, "/", 3); len(parts) == 3 {
+			intmetrics.WorkloadForecastErrorPct.DeleteLabelValues(parts[0], parts[1], parts[2])
+		}
+	}
+	logger.V(1).Info("Fitted workload forecasts", "workloads", len(workloads), "forecast", len(p.fits))
+}
+
+// ForecastErrorPct returns the mean absolute percentage error of a
+// workload's hourly forecasts and the number of hours scored.
+func (p *PredictiveScaler) ForecastErrorPct(namespace, kind, name string) (float64, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.accuracy[namespace+"/"+kind+"/"+name].errorPct()
+}
+
+// workloads returns the Deployments and StatefulSets with running pods in
+// snapshot, outside excluded namespaces, with their mean CPU request.
+func (p *PredictiveScaler) workloads(snapshot *optimizer.ClusterSnapshot) []predictiveWorkload {
+	type usage struct {
+		wl       predictiveWorkload
+		requests int64
+		pods     int64
+	}
+	byKey := map[string]*usage{}
+	var order []string
+	for _, pod := range snapshot.Pods {
+		if pod.Pod == nil || pod.Pod.Status.Phase != corev1.PodRunning || pod.CPURequest <= 0 {
+			continue
+		}
+		kind, name := ownerWorkload(pod)
+		if kind != "Deployment" && kind != "StatefulSet" {
+			continue
+		}
+		if p.isExcluded(pod.Pod.Namespace) {
+			continue
+		}
+		wl := predictiveWorkload{namespace: pod.Pod.Namespace, kind: kind, name: name}
+		u, ok := byKey[wl.key()]
+		if !ok {
+			u = &usage{wl: wl}
+			byKey[wl.key()] = u
+			order = append(order, wl.key())
+		}
+		u.requests += pod.CPURequest
+		u.pods++
+	}
+
+	out := make([]predictiveWorkload, 0, len(order))
+	for _, key := range order {
+		u := byKey[key]
+		u.wl.cpuRequest = u.requests / u.pods
+		out = append(out, u.wl)
+	}
+	return out
+}
+
+// ownerWorkload returns the kind and name of the workload owning pod,
+// resolving ReplicaSets to their Deployment by the pod-template-hash
+// suffix. It returns empty strings for ReplicaSets it cannot resolve.
+func ownerWorkload(pod optimizer.PodInfo) (string, string) {
+	kind, name := pod.OwnerKind, pod.OwnerName
+	if kind == "ReplicaSet" {
+		hash := pod.Pod.Labels["pod-template-hash"]
+		if hash == "" || !strings.HasSuffix(name, "-"+hash) {
+			return "", ""
+		}
+		return "Deployment", strings.TrimSuffix(name, "-"+hash)
+	}
+	return kind, name
+}
+
+func (p *PredictiveScaler) getWorkload(ctx context.Context, kind, namespace, name string) (client.Object, *int32, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	switch kind {
+	case "Deployment":
+		d := &appsv1.Deployment{}
+		if err := p.client.Get(ctx, key, d); err != nil {
+			return nil, nil, fmt.Errorf("getting deployment %s/%s: %w", namespace, name, err)
+		}
+		return d, d.Spec.Replicas, nil
+	case "StatefulSet":
+		s := &appsv1.StatefulSet{}
+		if err := p.client.Get(ctx, key, s); err != nil {
+			return nil, nil, fmt.Errorf("getting statefulset %s/%s: %w", namespace, name, err)
+		}
+		return s, s.Spec.Replicas, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported kind for predictive scaling: %s", kind)
+	}
+}
+
+func (p *PredictiveScaler) isExcluded(namespace string) bool {
+	for _, ns := range p.config.WorkloadScaler.ExcludeNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// originalReplicas returns the value recorded under ann, or current when
+// there is none.
+func originalReplicas(annotations map[string]string, ann string, current int32) int32 {
+	if v, err := strconv.ParseInt(annotations[ann], 10, 32); err == nil && v >= 0 {
+		return int32(v)
+	}
+	return current
+}
+
+// podNameMatcher matches the names of the pods of a Deployment
+// (<name>-<template hash>-<suffix>) or StatefulSet (<name>-<ordinal>),
+// including pods that no longer exist.
+func podNameMatcher(kind, name string) func(string) bool {
+	return func(pod string) bool {
+		rest, ok := strings.CutPrefix(pod, name+"-")
+		if !ok {
+			return false
+		}
+		if kind == "StatefulSet" {
+			_, err := strconv.Atoi(rest)
+			return err == nil
+		}
+		hash, suffix, ok := strings.Cut(rest, "-")
+		return ok && hash != "" && len(suffix) == podNameSuffixLen && !strings.Contains(suffix, "-")
+	}
+}
+
+func ownedByScaledObject(hpa *autoscalingv2.HorizontalPodAutoscaler) bool {
+	for _, ref := range hpa.OwnerReferences {
+		if ref.Kind == "ScaledObject" {
+			return true
+		}
+	}
+	return false
+}
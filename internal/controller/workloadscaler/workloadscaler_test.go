@@ -3,11 +3,21 @@ package workloadscaler
 import (
 	"context"
 	"testing"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/metrics"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
 	"github.com/koptimizer/koptimizer/pkg/optimizer"
 )
 
@@ -578,3 +588,660 @@ func TestVertical_IsExcluded(t *testing.T) {
 		t.Error("prod should not be excluded")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Predictive Scaling
+// ---------------------------------------------------------------------------
+
+// weeklySamples returns hourly samples over weeks weeks before end, at peak
+// millicores in the hours of the week peakHours holds and base otherwise.
+func weeklySamples(end time.Time, weeks int, base, peak int64, peakHours map[int]bool) []pkgmetrics.UsageSample {
+	var samples []pkgmetrics.UsageSample
+	for t := end.Add(-time.Duration(weeks) * 7 * 24 * time.Hour); t.Before(end); t = t.Add(time.Hour) {
+		cpu := base
+		if peakHours[hourOfWeek(t)] {
+			cpu = peak
+		}
+		samples = append(samples, pkgmetrics.UsageSample{Timestamp: t, CPUUsage: cpu})
+	}
+	return samples
+}
+
+func TestFitSeasonal(t *testing.T) {
+	now := time.Date(2026, 3, 4, 8, 10, 0, 0, time.UTC) // Wednesday
+	peakHours := map[int]bool{hourOfWeek(now.Add(time.Hour)): true}
+	samples := weeklySamples(now.Truncate(time.Hour), 2, 1000, 4000, peakHours)
+
+	f, ok := fitSeasonal(samples, now)
+	if !ok {
+		t.Fatal("two weeks of hourly history should be enough to forecast")
+	}
+	if got := f.at(now.Add(time.Hour)); got != 4000 {
+		t.Errorf("forecast at the peak hour = %.0f, want 4000", got)
+	}
+	if got := f.at(now.Add(3 * time.Hour)); got != 1000 {
+		t.Errorf("forecast off peak = %.0f, want 1000", got)
+	}
+	if got := f.peak(now, now.Add(time.Hour)); got != 4000 {
+		t.Errorf("peak within the next hour = %.0f, want 4000", got)
+	}
+	if got := f.peak(now.Add(2*time.Hour), now.Add(3*time.Hour)); got != 1000 {
+		t.Errorf("peak after the peak hour = %.0f, want 1000", got)
+	}
+
+	// The last day running at twice the usual level scales the forecast.
+	doubled := make([]pkgmetrics.UsageSample, len(samples))
+	copy(doubled, samples)
+	for i := range doubled {
+		if now.Sub(doubled[i].Timestamp) <= levelWindow {
+			doubled[i].CPUUsage *= 3
+		}
+	}
+	f, _ = fitSeasonal(doubled, now)
+	if f.level <= 1 || f.level > maxLevel {
+		t.Errorf("level = %.2f, want above 1 and clamped to %.0f", f.level, maxLevel)
+	}
+
+	if _, ok := fitSeasonal(samples[len(samples)-72:], now); ok {
+		t.Error("three days of history must not be enough to forecast weekly seasonality")
+	}
+}
+
+func TestForecastAccuracy(t *testing.T) {
+	hour := time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)
+	a := newForecastAccuracy()
+	a.predict(hour, 1100)
+	a.predict(hour, 5000) // only the first forecast of an hour counts
+	a.predict(hour.Add(time.Hour), 900)
+
+	actual := []pkgmetrics.UsageSample{{Timestamp: hour, CPUUsage: 1000}}
+	a.score(actual, hour.Add(30*time.Minute))
+	if _, n := a.errorPct(); n != 0 {
+		t.Fatalf("scored %d hours before the hour ended, want 0", n)
+	}
+
+	a.score(actual, hour.Add(2*time.Hour))
+	errPct, n := a.errorPct()
+	if n != 1 {
+		t.Fatalf("scored hours = %d, want 1 (the hour without usage is dropped)", n)
+	}
+	if errPct < 9.99 || errPct > 10.01 {
+		t.Errorf("error = %.2f%%, want 10%%", errPct)
+	}
+	if len(a.pending) != 0 {
+		t.Errorf("pending forecasts = %v, want none left for ended hours", a.pending)
+	}
+
+	var nilAccuracy *forecastAccuracy
+	if _, n := nilAccuracy.errorPct(); n != 0 {
+		t.Error("a workload without forecasts should report no scored hours")
+	}
+}
+
+func TestPodNameMatcher(t *testing.T) {
+	tests := []struct {
+		kind, name, pod string
+		want            bool
+	}{
+		{"Deployment", "web", "web-7d9f8c6b5-x2k4p", true},
+		{"Deployment", "web", "web-api-7d9f8c6b5-x2k4p", false},
+		{"Deployment", "web", "web-0", false},
+		{"Deployment", "web", "api-7d9f8c6b5-x2k4p", false},
+		{"StatefulSet", "db", "db-0", true},
+		{"StatefulSet", "db", "db-12", true},
+		{"StatefulSet", "db", "db-backup-0", false},
+	}
+	for _, tt := range tests {
+		if got := podNameMatcher(tt.kind, tt.name)(tt.pod); got != tt.want {
+			t.Errorf("podNameMatcher(%s, %s)(%s) = %v, want %v", tt.kind, tt.name, tt.pod, got, tt.want)
+		}
+	}
+}
+
+// predictiveFixture runs a PredictiveScaler over a Deployment "web" with
+// pods requesting 500m CPU. Its usage history has a 4000m peak in the hour
+// after hour and 200m otherwise.
+type predictiveFixture struct {
+	scaler *PredictiveScaler
+	client client.Client
+	hour   time.Time
+}
+
+func newPredictiveFixture(t *testing.T, objs ...client.Object) *predictiveFixture {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+	hour := time.Now().Truncate(time.Hour)
+	store := metrics.NewStore(15 * 24 * time.Hour)
+	peakHours := map[int]bool{hourOfWeek(hour.Add(time.Hour)): true}
+	for _, s := range weeklySamples(hour, 2, 200, 4000, peakHours) {
+		store.RecordPodMetrics(pkgmetrics.PodMetrics{
+			Namespace: "prod", Name: "web-7d9f8c6b5-x2k4p", Timestamp: s.Timestamp.Add(30 * time.Minute),
+			Containers: []pkgmetrics.ContainerMetrics{{Name: "app", CPUUsage: s.CPUUsage}},
+		})
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.WorkloadScaler.Predictive.Enabled = true
+	cfg.WorkloadScaler.ExcludeNamespaces = nil
+	return &predictiveFixture{scaler: NewPredictiveScaler(c, store, cfg), client: c, hour: hour}
+}
+
+// analyze runs the scaler as of offset past the fixture's hour.
+func (f *predictiveFixture) analyze(t *testing.T, offset time.Duration) []optimizer.Recommendation {
+	t.Helper()
+	f.scaler.now = func() time.Time { return f.hour.Add(offset) }
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-7d9f8c6b5-x2k4p", Namespace: "prod", Labels: map[string]string{"pod-template-hash": "7d9f8c6b5"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	snapshot := &optimizer.ClusterSnapshot{Pods: []optimizer.PodInfo{{
+		Pod: pod, CPURequest: 500, OwnerKind: "ReplicaSet", OwnerName: "web-7d9f8c6b5",
+	}}}
+	recs, err := f.scaler.Analyze(context.Background(), snapshot)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	return recs
+}
+
+func webHPA(minReplicas int32, annotations map[string]string) *autoscalingv2.HorizontalPodAutoscaler {
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod", Annotations: annotations},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    20,
+		},
+	}
+}
+
+func TestPredictive_HPAMinReplicas(t *testing.T) {
+	f := newPredictiveFixture(t, webHPA(2, nil))
+	ctx := context.Background()
+
+	// Ahead of the peak: 4000m × 1.1 headroom at 70% of 500m per pod.
+	recs := f.analyze(t, 10*time.Minute)
+	if len(recs) != 1 {
+		t.Fatalf("got %d recommendations, want 1", len(recs))
+	}
+	rec := recs[0]
+	if rec.Details["target"] != "hpaMinReplicas" || rec.Details["direction"] != "up" || rec.Details["newReplicas"] != "13" {
+		t.Fatalf("details = %v, want minReplicas raised to 13", rec.Details)
+	}
+	if rec.AutoExecutable {
+		t.Error("a raise from a forecast never scored against actual usage must not be auto-executable")
+	}
+	if err := f.scaler.Execute(ctx, rec); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	if err := f.client.Get(ctx, types.NamespacedName{Namespace: "prod", Name: "web"}, hpa); err != nil {
+		t.Fatal(err)
+	}
+	if *hpa.Spec.MinReplicas != 13 || hpa.Annotations[AnnPredictiveOriginalMinReplicas] != "2" {
+		t.Fatalf("HPA minReplicas = %d, annotation %q; want 13 with the original 2 recorded",
+			*hpa.Spec.MinReplicas, hpa.Annotations[AnnPredictiveOriginalMinReplicas])
+	}
+
+	// After the peak the HPA goes back to its original minReplicas.
+	recs = f.analyze(t, 2*time.Hour+10*time.Minute)
+	if len(recs) != 1 || recs[0].Details["direction"] != "down" || recs[0].Details["newReplicas"] != "2" {
+		t.Fatalf("recs = %+v, want minReplicas lowered back to 2", recs)
+	}
+	if !recs[0].AutoExecutable {
+		t.Error("returning to the original minReplicas should be auto-executable")
+	}
+	if err := f.scaler.Execute(ctx, recs[0]); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if err := f.client.Get(ctx, types.NamespacedName{Namespace: "prod", Name: "web"}, hpa); err != nil {
+		t.Fatal(err)
+	}
+	if *hpa.Spec.MinReplicas != 2 {
+		t.Errorf("HPA minReplicas = %d, want 2", *hpa.Spec.MinReplicas)
+	}
+	if _, ok := hpa.Annotations[AnnPredictiveOriginalMinReplicas]; ok {
+		t.Error("original minReplicas annotation should be removed once back")
+	}
+
+	if recs := f.analyze(t, 3*time.Hour+10*time.Minute); len(recs) != 0 {
+		t.Errorf("recs = %+v, want none off peak at the original minReplicas", recs)
+	}
+}
+
+func TestPredictive_ScoredForecastAutoExecutes(t *testing.T) {
+	f := newPredictiveFixture(t, webHPA(2, nil))
+	acc := newForecastAccuracy()
+	for i := 0; i < minScoredHours; i++ {
+		acc.errors = append(acc.errors, 0.1)
+	}
+	f.scaler.accuracy["prod/Deployment/web"] = acc
+	f.scaler.fittedAt = f.hour // keep the seeded accuracy
+
+	f.scaler.fits["prod/Deployment/web"], _ = fitSeasonal(
+		f.scaler.store.GetWorkloadCPUSeries("prod", podNameMatcher("Deployment", "web"), forecastHistory, forecastStep), f.hour)
+	recs := f.analyze(t, 10*time.Minute)
+	if len(recs) != 1 || !recs[0].AutoExecutable {
+		t.Fatalf("recs = %+v, want an auto-executable raise", recs)
+	}
+	if recs[0].Details["forecastErrorPct"] != "10.0" || recs[0].Details["scoredHours"] != "24" {
+		t.Errorf("details = %v, want forecastErrorPct 10.0 over 24 scored hours", recs[0].Details)
+	}
+
+	f.scaler.config.WorkloadScaler.Predictive.MaxForecastErrorPct = 5
+	if recs := f.analyze(t, 10*time.Minute); len(recs) != 1 || recs[0].AutoExecutable {
+		t.Errorf("recs = %+v, want a raise held back by the forecast error", recs)
+	}
+}
+
+func TestPredictive_DeploymentReplicas(t *testing.T) {
+	replicas := int32(3)
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+	f := newPredictiveFixture(t, deploy)
+	f.scaler.config.WorkloadScaler.MaxReplicasLimit = 10
+	ctx := context.Background()
+
+	recs := f.analyze(t, 10*time.Minute)
+	if len(recs) != 1 || recs[0].Details["target"] != "replicas" || recs[0].Details["newReplicas"] != "10" {
+		t.Fatalf("recs = %+v, want replicas raised to the limit of 10", recs)
+	}
+	if err := f.scaler.Execute(ctx, recs[0]); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got := &appsv1.Deployment{}
+	if err := f.client.Get(ctx, types.NamespacedName{Namespace: "prod", Name: "web"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if *got.Spec.Replicas != 10 || got.Annotations[AnnPredictiveOriginalReplicas] != "3" {
+		t.Fatalf("replicas = %d, annotation %q; want 10 with the original 3 recorded", *got.Spec.Replicas, got.Annotations[AnnPredictiveOriginalReplicas])
+	}
+
+	recs = f.analyze(t, 2*time.Hour+10*time.Minute)
+	if len(recs) != 1 || recs[0].Details["newReplicas"] != "3" {
+		t.Fatalf("recs = %+v, want replicas back to 3", recs)
+	}
+}
+
+func TestPredictive_SkipsKEDAManagedHPA(t *testing.T) {
+	hpa := webHPA(2, nil)
+	hpa.OwnerReferences = []metav1.OwnerReference{{APIVersion: "keda.sh/v1alpha1", Kind: "ScaledObject", Name: "web", UID: "uid-web"}}
+	replicas := int32(2)
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+	f := newPredictiveFixture(t, hpa, deploy)
+
+	if recs := f.analyze(t, 10*time.Minute); len(recs) != 0 {
+		t.Errorf("recs = %+v, want none for a workload scaled by KEDA", recs)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// HPA Tuning Tests
+// ---------------------------------------------------------------------------
+
+func tuningHPA(target, minReplicas, maxReplicas int32) *autoscalingv2.HorizontalPodAutoscaler {
+	hpa := webHPA(minReplicas, nil)
+	hpa.Spec.MaxReplicas = maxReplicas
+	hpa.Spec.Metrics = []autoscalingv2.MetricSpec{{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name:   corev1.ResourceCPU,
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &target},
+		},
+	}}
+	return hpa
+}
+
+// tuningObs returns one observation every 10 minutes over hours, with
+// replicas and utilization from f.
+func tuningObs(hpa *autoscalingv2.HorizontalPodAutoscaler, hours int, f func(i int) (int32, float64)) []hpaObservation {
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	var obs []hpaObservation
+	for i := 0; i <= hours*6; i++ {
+		replicas, util := f(i)
+		obs = append(obs, hpaObservation{
+			at: start.Add(time.Duration(i) * 10 * time.Minute), replicas: replicas,
+			min: *hpa.Spec.MinReplicas, max: hpa.Spec.MaxReplicas, cpuUtil: util,
+		})
+	}
+	return obs
+}
+
+func newTuner(hpa *autoscalingv2.HorizontalPodAutoscaler, obs []hpaObservation) *hpaTuner {
+	return &hpaTuner{
+		cfg:     config.DefaultConfig().WorkloadScaler.HPATuning,
+		hpa:     hpa,
+		obs:     obs,
+		podCost: 20,
+		now:     obs[len(obs)-1].at,
+	}
+}
+
+func TestHPATuning_Target(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    int32
+		util      float64
+		want      string // suggestedTarget, "" for none
+		auto      bool
+		costDelta func(float64) bool
+	}{
+		{"bursts above safe utilization lowers target", 60, 120, "45", true, func(c float64) bool { return c > 0 }},
+		{"never reaching target raises it", 70, 40, "85", false, func(c float64) bool { return c < 0 }},
+		{"bursts within safe utilization keep target", 60, 95, "", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hpa := tuningHPA(tt.target, 2, 20)
+			obs := tuningObs(hpa, 8, func(int) (int32, float64) { return 5, tt.util })
+			rec, ok := newTuner(hpa, obs).target()
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("got %v, want no recommendation", rec.Details)
+				}
+				return
+			}
+			if !ok || rec.Details["suggestedTarget"] != tt.want {
+				t.Fatalf("got ok=%v details=%v, want suggestedTarget %s", ok, rec.Details, tt.want)
+			}
+			if rec.AutoExecutable != tt.auto {
+				t.Errorf("AutoExecutable = %v, want %v", rec.AutoExecutable, tt.auto)
+			}
+			if !tt.costDelta(rec.EstimatedImpact.MonthlyCostChangeUSD) {
+				t.Errorf("MonthlyCostChangeUSD = %.2f has the wrong sign", rec.EstimatedImpact.MonthlyCostChangeUSD)
+			}
+		})
+	}
+}
+
+func TestHPATuning_TargetIgnoresObservationsAtBounds(t *testing.T) {
+	hpa := tuningHPA(60, 2, 20)
+	// Utilization at minReplicas says nothing about the target.
+	obs := tuningObs(hpa, 8, func(int) (int32, float64) { return 2, 10 })
+	if rec, ok := newTuner(hpa, obs).target(); ok {
+		t.Errorf("got %v, want no target change from observations at minReplicas", rec.Details)
+	}
+}
+
+func TestHPATuning_Flapping(t *testing.T) {
+	hpa := tuningHPA(60, 2, 20)
+	// p95 utilization at 90% of requests keeps the 60% target as it is.
+	obs := tuningObs(hpa, 8, func(i int) (int32, float64) { return int32(3 + i%2), 90 })
+	recs := newTuner(hpa, obs).recommendations()
+	if len(recs) != 1 || recs[0].Details["tuning"] != TuningBehavior {
+		t.Fatalf("recs = %+v, want a behavior recommendation", recs)
+	}
+	rec := recs[0]
+	if rec.Details["currentStabilizationSeconds"] != "300" || rec.Details["suggestedStabilizationSeconds"] != "600" {
+		t.Errorf("details = %v, want stabilization 300 → 600", rec.Details)
+	}
+	if !rec.AutoExecutable || rec.EstimatedImpact.RiskLevel != "low" {
+		t.Errorf("AutoExecutable = %v, risk %q; want a low-risk auto-executable change", rec.AutoExecutable, rec.EstimatedImpact.RiskLevel)
+	}
+
+	steady := tuningObs(hpa, 8, func(i int) (int32, float64) { return int32(3 + i/24), 90 })
+	for _, r := range newTuner(hpa, steady).recommendations() {
+		if r.Details["tuning"] == TuningBehavior {
+			t.Errorf("got %v for a steady ramp, want no behavior change", r.Details)
+		}
+	}
+}
+
+func TestHPATuning_IdleMin(t *testing.T) {
+	hpa := tuningHPA(60, 6, 20)
+	obs := tuningObs(hpa, 8, func(int) (int32, float64) { return 6, 10 })
+	rec, ok := newTuner(hpa, obs).idleMin()
+	if !ok || rec.Details["suggestedMinReplicas"] != "2" {
+		t.Fatalf("got ok=%v details=%v, want minReplicas lowered to the floor of 2", ok, rec.Details)
+	}
+	if rec.AutoExecutable {
+		t.Error("lowering minReplicas removes capacity and must not be auto-executable")
+	}
+	if rec.EstimatedSaving.MonthlySavingsUSD != 80 {
+		t.Errorf("MonthlySavingsUSD = %.2f, want 4 replicas × $20 = 80", rec.EstimatedSaving.MonthlySavingsUSD)
+	}
+
+	tuner := newTuner(hpa, obs)
+	tuner.predictive = true
+	if _, ok := tuner.idleMin(); ok {
+		t.Error("minReplicas raised by predictive scaling should not be tuned")
+	}
+}
+
+func TestHPATuning_Requests(t *testing.T) {
+	hpa := tuningHPA(60, 2, 20)
+	hpa.Status.Conditions = []autoscalingv2.HorizontalPodAutoscalerCondition{{
+		Type: autoscalingv2.ScalingActive, Status: corev1.ConditionFalse, Reason: "FailedGetResourceMetric",
+		Message: "the HPA was unable to compute the replica count: failed to get cpu utilization: missing request for cpu in container app",
+	}}
+	obs := tuningObs(hpa, 1, func(int) (int32, float64) { return 3, -1 })
+	recs := newTuner(hpa, obs).recommendations()
+	if len(recs) != 1 || recs[0].Details["tuning"] != TuningRequests || recs[0].AutoExecutable {
+		t.Fatalf("recs = %+v, want one manual requests recommendation", recs)
+	}
+
+	overloaded := tuningHPA(60, 2, 20)
+	obs = tuningObs(overloaded, 8, func(int) (int32, float64) { return 20, 300 })
+	recs = newTuner(overloaded, obs).recommendations()
+	if len(recs) != 1 || recs[0].Details["tuning"] != TuningRequests {
+		t.Fatalf("recs = %+v, want only a requests recommendation", recs)
+	}
+}
+
+func TestHPATuning_NeedsMinHistory(t *testing.T) {
+	hpa := tuningHPA(60, 2, 20)
+	obs := tuningObs(hpa, 2, func(i int) (int32, float64) { return int32(3 + i%2), 120 })
+	if recs := newTuner(hpa, obs).recommendations(); len(recs) != 0 {
+		t.Errorf("recs = %+v, want none before minHistory", recs)
+	}
+}
+
+func TestHPAHistory_Observe(t *testing.T) {
+	h := newHPAHistory(time.Hour)
+	hpa := tuningHPA(60, 2, 20)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 180; i++ {
+		h.observe(hpa, start.Add(time.Duration(i)*30*time.Second))
+	}
+	obs := h.obs["prod/web"]
+	if len(obs) != 60 {
+		t.Fatalf("got %d observations, want one a minute over the last hour", len(obs))
+	}
+	if obs[0].cpuUtil != -1 {
+		t.Errorf("cpuUtil = %.1f, want -1 without a current metric", obs[0].cpuUtil)
+	}
+	h.retain(map[string]bool{})
+	if len(h.obs) != 0 {
+		t.Error("retain should forget HPAs that are gone")
+	}
+
+	// Observations taken under another CPU target are dropped.
+	h.observe(hpa, start)
+	h.observe(hpa, start.Add(time.Minute))
+	if obs := h.observe(tuningHPA(45, 2, 20), start.Add(2*time.Minute)); len(obs) != 1 || obs[0].target != 45 {
+		t.Errorf("got %+v after the target changed, want only the new observation", obs)
+	}
+}
+
+func TestHorizontal_TuningDoesNotRepeat(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tuningHPA(60, 2, 20)).
+		WithStatusSubresource(&autoscalingv2.HorizontalPodAutoscaler{}).Build()
+	cfg := config.DefaultConfig()
+	cfg.WorkloadScaler.ExcludeNamespaces = nil
+	h := NewHorizontalScaler(c, cfg)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	// Pods burst to twice the target while the HPA scales freely; the
+	// status is left as it was so stale samples would lower it again.
+	analyze := func(minute int) []optimizer.Recommendation {
+		t.Helper()
+		h.now = func() time.Time { return start.Add(time.Duration(minute) * time.Minute) }
+		current := &autoscalingv2.HorizontalPodAutoscaler{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "prod", Name: "web"}, current); err != nil {
+			t.Fatal(err)
+		}
+		util := int32(120)
+		current.Status.CurrentReplicas = 5
+		current.Status.CurrentMetrics = []autoscalingv2.MetricStatus{{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricStatus{
+				Name:    corev1.ResourceCPU,
+				Current: autoscalingv2.MetricValueStatus{AverageUtilization: &util},
+			},
+		}}
+		if err := c.Status().Update(ctx, current); err != nil {
+			t.Fatal(err)
+		}
+		recs, err := h.Analyze(ctx, &optimizer.ClusterSnapshot{})
+		if err != nil {
+			t.Fatalf("Analyze: %v", err)
+		}
+		return recs
+	}
+
+	var recs []optimizer.Recommendation
+	minute := 0
+	for ; minute <= 7*60 && len(recs) == 0; minute++ {
+		recs = analyze(minute)
+	}
+	if len(recs) != 1 || recs[0].Details["tuning"] != TuningTarget || !recs[0].AutoExecutable {
+		t.Fatalf("recs = %+v, want one auto-executable target recommendation", recs)
+	}
+	if err := h.Execute(ctx, recs[0]); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	for end := minute + 12*60; minute <= end; minute++ {
+		if recs := analyze(minute); len(recs) != 0 {
+			t.Fatalf("minute %d after tuning: recs = %+v, want none", minute, recs)
+		}
+	}
+}
+
+func TestHorizontal_ExecuteTuning(t *testing.T) {
+	tests := []struct {
+		tuning, key, value string
+		check              func(*autoscalingv2.HorizontalPodAutoscaler) bool
+	}{
+		{TuningTarget, "suggestedTarget", "45", func(h *autoscalingv2.HorizontalPodAutoscaler) bool {
+			return *h.Spec.Metrics[0].Resource.Target.AverageUtilization == 45
+		}},
+		{TuningBehavior, "suggestedStabilizationSeconds", "600", func(h *autoscalingv2.HorizontalPodAutoscaler) bool {
+			return h.Spec.Behavior != nil && *h.Spec.Behavior.ScaleDown.StabilizationWindowSeconds == 600
+		}},
+		{TuningMinReplicas, "suggestedMinReplicas", "2", func(h *autoscalingv2.HorizontalPodAutoscaler) bool {
+			return *h.Spec.MinReplicas == 2
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tuning, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tuningHPA(60, 6, 20)).Build()
+			h := NewHorizontalScaler(c, config.DefaultConfig())
+			rec := optimizer.Recommendation{
+				AutoExecutable: true,
+				Details: map[string]string{
+					"scalingType": "horizontal", "tuning": tt.tuning,
+					"hpaName": "web", "hpaNamespace": "prod", tt.key: tt.value,
+				},
+			}
+			if err := h.Execute(context.Background(), rec); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			got := &autoscalingv2.HorizontalPodAutoscaler{}
+			if err := c.Get(context.Background(), types.NamespacedName{Namespace: "prod", Name: "web"}, got); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(got) {
+				t.Errorf("HPA spec not updated: %+v", got.Spec)
+			}
+		})
+	}
+
+	h := NewHorizontalScaler(nil, config.DefaultConfig())
+	rec := optimizer.Recommendation{AutoExecutable: true, Details: map[string]string{
+		"tuning": TuningRequests, "hpaName": "web", "hpaNamespace": "prod",
+	}}
+	if err := h.Execute(context.Background(), rec); err == nil {
+		t.Error("a requests finding should not be executable")
+	}
+}
+
+func TestHorizontal_AnalyzeTuning(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	hpa := tuningHPA(60, 2, 20)
+	keda := tuningHPA(60, 2, 20)
+	keda.Name = "keda-web"
+	keda.OwnerReferences = []metav1.OwnerReference{{APIVersion: "keda.sh/v1alpha1", Kind: "ScaledObject", Name: "web", UID: "uid-web"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hpa, keda).
+		WithStatusSubresource(&autoscalingv2.HorizontalPodAutoscaler{}).Build()
+
+	cfg := config.DefaultConfig()
+	cfg.WorkloadScaler.ExcludeNamespaces = nil
+	h := NewHorizontalScaler(c, cfg)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-7d9f8c6b5-x2k4p", Namespace: "prod", Labels: map[string]string{"pod-template-hash": "7d9f8c6b5"}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	snapshot := &optimizer.ClusterSnapshot{
+		Nodes: []optimizer.NodeInfo{{Node: node, CPUCapacity: 4000, MemoryCapacity: 16 * gi, HourlyCostUSD: 0.2}},
+		Pods: []optimizer.PodInfo{{
+			Pod: pod, CPURequest: 1000, MemoryRequest: 4 * gi, OwnerKind: "ReplicaSet", OwnerName: "web-7d9f8c6b5",
+		}},
+	}
+
+	var recs []optimizer.Recommendation
+	for i := 0; i <= 7*60; i++ {
+		h.now = func() time.Time { return start.Add(time.Duration(i) * time.Minute) }
+		for _, obj := range []*autoscalingv2.HorizontalPodAutoscaler{hpa, keda} {
+			current := &autoscalingv2.HorizontalPodAutoscaler{}
+			if err := c.Get(ctx, types.NamespacedName{Namespace: "prod", Name: obj.Name}, current); err != nil {
+				t.Fatal(err)
+			}
+			current.Status.CurrentReplicas = int32(3 + (i/10)%2)
+			if err := c.Status().Update(ctx, current); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var err error
+		if recs, err = h.Analyze(ctx, snapshot); err != nil {
+			t.Fatalf("Analyze: %v", err)
+		}
+	}
+
+	if len(recs) != 1 || recs[0].TargetName != "web" || recs[0].Details["hpaName"] != "web" || recs[0].Details["tuning"] != TuningBehavior {
+		t.Fatalf("recs = %+v, want one behavior recommendation for the HPA not owned by KEDA", recs)
+	}
+	// $0.20/h node × 730.5h × (1/4 CPU + 1/4 memory)/2 per replica, half a
+	// replica held on average.
+	want := 0.2 * 730.5 * 0.25 / 2
+	if got := recs[0].EstimatedImpact.MonthlyCostChangeUSD; got < want-0.01 || got > want+0.01 {
+		t.Errorf("MonthlyCostChangeUSD = %.3f, want %.3f", got, want)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -72,6 +73,7 @@ type Service struct {
 	mu         sync.RWMutex
 	cache      *DriftResult
 	lastUpdate time.Time
+	refreshing atomic.Bool
 }
 
 func NewService(cfg *config.Config, cs *state.ClusterState) *Service {
@@ -105,6 +107,44 @@ func (s *Service) GetDrift(forceRefresh bool) (*DriftResult, error) {
 	return result, nil
 }
 
+// DriftSummary returns the drifted fields of a workload from the cached
+// results, e.g. "cpuRequest helm=500m actual=1", or "" if it has not drifted
+// or nothing is cached yet. A stale cache is refreshed in the background so
+// callers never wait on GitLab.
+func (s *Service) DriftSummary(namespace, kind, name string) string {
+	if !s.cfg.HelmDrift.Enabled {
+		return ""
+	}
+	s.mu.RLock()
+	cache, stale := s.cache, time.Since(s.lastUpdate) >= 5*time.Minute
+	s.mu.RUnlock()
+	if stale && s.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer s.refreshing.Store(false)
+			if _, err := s.GetDrift(true); err != nil {
+				slog.Warn("helm drift refresh failed", "error", err)
+			}
+		}()
+	}
+	if cache == nil {
+		return ""
+	}
+
+	for _, wl := range cache.Workloads {
+		if wl.Namespace != namespace || wl.Kind != kind || wl.Name != name {
+			continue
+		}
+		var fields []string
+		for _, f := range wl.Fields {
+			if f.Drifted {
+				fields = append(fields, fmt.Sprintf("%s helm=%s actual=%s", f.Field, f.HelmValue, f.ActualValue))
+			}
+		}
+		return strings.Join(fields, ", ")
+	}
+	return ""
+}
+
 func (s *Service) detectDrift() (*DriftResult, error) {
 	hd := s.cfg.HelmDrift
 	if !hd.Enabled || hd.GitLabURL == "" || hd.GitLabToken == "" {
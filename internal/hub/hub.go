@@ -0,0 +1,140 @@
+// Package hub implements fleet mode: one koptimizer instance polls the REST
+// APIs of koptimizer instances in other clusters, caches their summaries in
+// SQLite and serves fleet-wide rollups.
+package hub
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Cluster status values. Local clusters are always healthy; remote ones
+// are derived from the outcome and age of the last poll.
+const (
+	StatusHealthy     = "healthy"     // last poll succeeded and data is fresh
+	StatusDegraded    = "degraded"    // last poll failed but cached data is still fresh
+	StatusStale       = "stale"       // cached data is older than staleAfter
+	StatusUnreachable = "unreachable" // no poll has ever succeeded
+	StatusPending     = "pending"     // not polled yet
+)
+
+// Cluster sources.
+const (
+	SourceLocal  = "local"
+	SourceRemote = "remote"
+)
+
+// ClusterSummary is one entry of GET /api/v1/clusters. The hub polls the
+// same shape from each remote, so every koptimizer can be a fleet member.
+type ClusterSummary struct {
+	ID                   string     `json:"id"`
+	Name                 string     `json:"name"`
+	Source               string     `json:"source"`
+	Provider             string     `json:"provider"`
+	Region               string     `json:"region"`
+	Version              string     `json:"version"`
+	Mode                 string     `json:"mode,omitempty"`
+	NodeCount            int        `json:"nodeCount"`
+	PodCount             int        `json:"podCount"`
+	MonthlyCostUSD       float64    `json:"monthlyCostUSD"`
+	PotentialSavings     float64    `json:"potentialSavings"`
+	EfficiencyScore      float64    `json:"efficiencyScore"`
+	CPUUtilizationPct    float64    `json:"cpuUtilizationPct"`
+	MemoryUtilizationPct float64    `json:"memUtilizationPct"`
+	Status               string     `json:"status"`
+	LastUpdated          *time.Time `json:"lastUpdated,omitempty"`
+	LastError            string     `json:"lastError,omitempty"`
+}
+
+// ProviderRollup aggregates the clusters of one cloud provider.
+type ProviderRollup struct {
+	Clusters         int     `json:"clusters"`
+	MonthlyCostUSD   float64 `json:"monthlyCostUSD"`
+	PotentialSavings float64 `json:"potentialSavings"`
+}
+
+// Rollup is the fleet-wide aggregate served by GET /api/v1/clusters/rollup.
+// Stale and degraded clusters contribute their last known values and are
+// counted in StatusCounts so consumers can judge how current the totals are.
+type Rollup struct {
+	ClusterCount     int                       `json:"clusterCount"`
+	StatusCounts     map[string]int            `json:"statusCounts"`
+	NodeCount        int                       `json:"nodeCount"`
+	PodCount         int                       `json:"podCount"`
+	MonthlyCostUSD   float64                   `json:"monthlyCostUSD"`
+	PotentialSavings float64                   `json:"potentialSavings"`
+	SavingsPct       float64                   `json:"savingsPct"`
+	EfficiencyScore  float64                   `json:"efficiencyScore"` // cost-weighted across clusters
+	ByProvider       map[string]ProviderRollup `json:"byProvider"`
+	OldestData       *time.Time                `json:"oldestData,omitempty"`
+	GeneratedAt      time.Time                 `json:"generatedAt"`
+}
+
+// BuildRollup aggregates clusters. Clusters that have never reported data
+// (pending or unreachable) are counted but contribute no totals.
+func BuildRollup(clusters []ClusterSummary, now time.Time) Rollup {
+	r := Rollup{
+		ClusterCount: len(clusters),
+		StatusCounts: make(map[string]int),
+		ByProvider:   make(map[string]ProviderRollup),
+		GeneratedAt:  now,
+	}
+
+	var weighted, weights, plainSum float64
+	var reporting int
+	for _, c := range clusters {
+		r.StatusCounts[c.Status]++
+		if c.Status == StatusPending || c.Status == StatusUnreachable {
+			continue
+		}
+		reporting++
+		r.NodeCount += c.NodeCount
+		r.PodCount += c.PodCount
+		r.MonthlyCostUSD += c.MonthlyCostUSD
+		r.PotentialSavings += c.PotentialSavings
+		weighted += c.EfficiencyScore * c.MonthlyCostUSD
+		weights += c.MonthlyCostUSD
+		plainSum += c.EfficiencyScore
+
+		provider := c.Provider
+		if provider == "" {
+			provider = "unknown"
+		}
+		p := r.ByProvider[provider]
+		p.Clusters++
+		p.MonthlyCostUSD += c.MonthlyCostUSD
+		p.PotentialSavings += c.PotentialSavings
+		r.ByProvider[provider] = p
+
+		if c.LastUpdated != nil && (r.OldestData == nil || c.LastUpdated.Before(*r.OldestData)) {
+			t := *c.LastUpdated
+			r.OldestData = &t
+		}
+	}
+
+	switch {
+	case weights > 0:
+		r.EfficiencyScore = round1(weighted / weights)
+	case reporting > 0:
+		r.EfficiencyScore = round1(plainSum / float64(reporting))
+	}
+	if r.MonthlyCostUSD > 0 {
+		r.SavingsPct = round1(r.PotentialSavings / r.MonthlyCostUSD * 100)
+	}
+	return r
+}
+
+// SortClusters orders clusters local first, then by name.
+func SortClusters(clusters []ClusterSummary) {
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if (clusters[i].Source == SourceLocal) != (clusters[j].Source == SourceLocal) {
+			return clusters[i].Source == SourceLocal
+		}
+		return clusters[i].Name < clusters[j].Name
+	})
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
@@ -0,0 +1,288 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/store"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// remoteStub serves GET /api/v1/clusters like a koptimizer API with auth
+// enabled. When hub is set it also lists a cluster of its own fleet.
+type remoteStub struct {
+	mu     sync.Mutex
+	token  string
+	cost   float64
+	fail   bool
+	hub    bool
+	scopes []string
+}
+
+func (s *remoteStub) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.URL.Path != "/api/v1/clusters" {
+			http.NotFound(w, r)
+			return
+		}
+		s.scopes = append(s.scopes, r.URL.Query().Get("scope"))
+		if r.Header.Get("Authorization") != "Bearer "+s.token {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid bearer token in Authorization header"}`))
+			return
+		}
+		if s.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		clusters := []ClusterSummary{}
+		if s.hub && r.URL.Query().Get("scope") != "local" {
+			clusters = append(clusters, ClusterSummary{ID: "downstream", Source: SourceRemote, MonthlyCostUSD: 999})
+		}
+		clusters = append(clusters, ClusterSummary{
+			ID: "self", Name: "self", Source: SourceLocal, Provider: "gcp", Region: "europe-west1",
+			NodeCount: 10, PodCount: 120, MonthlyCostUSD: s.cost, PotentialSavings: s.cost / 10,
+			EfficiencyScore: 60, Status: StatusHealthy,
+		})
+		json.NewEncoder(w).Encode(map[string]any{"clusters": clusters})
+	}
+}
+
+func startRemote(t *testing.T, token string, cost float64) (*remoteStub, string) {
+	t.Helper()
+	stub := &remoteStub{token: token, cost: cost}
+	srv := httptest.NewServer(stub.handler(t))
+	t.Cleanup(srv.Close)
+	return stub, srv.URL
+}
+
+func openStore(t *testing.T, path string) *store.HubStore {
+	t.Helper()
+	db, err := store.Open(store.Config{Path: path})
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return store.NewHubStore(db.RawDB())
+}
+
+// fakeClock lets tests advance time between polls.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// ---------------------------------------------------------------------------
+// Poller Tests
+// ---------------------------------------------------------------------------
+
+func TestPoller_PollAndStatus(t *testing.T) {
+	eu, euURL := startRemote(t, "eu-token", 1000)
+	_, usURL := startRemote(t, "us-token", 3000)
+
+	p, err := NewPoller(config.HubConfig{
+		PollInterval: time.Minute,
+		StaleAfter:   5 * time.Minute,
+		Clusters: []config.HubClusterConfig{
+			{Name: "prod-eu", URL: euURL + "/", Token: "eu-token"},
+			{Name: "prod-us", URL: usURL, Token: "wrong"},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+	clock := &fakeClock{t: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)}
+	p.now = clock.now
+
+	if got := p.Clusters(); got[0].Status != StatusPending || got[1].Status != StatusPending {
+		t.Fatalf("statuses before first poll = %s/%s, want pending", got[0].Status, got[1].Status)
+	}
+
+	p.PollOnce(context.Background())
+	got := p.Clusters()
+	if len(got) != 2 {
+		t.Fatalf("Clusters() = %d entries, want 2", len(got))
+	}
+	euSummary, us := got[0], got[1]
+	if euSummary.ID != "prod-eu" || euSummary.Source != SourceRemote || euSummary.Status != StatusHealthy {
+		t.Errorf("prod-eu = %s/%s/%s, want prod-eu/remote/healthy", euSummary.ID, euSummary.Source, euSummary.Status)
+	}
+	if euSummary.MonthlyCostUSD != 1000 || euSummary.Region != "europe-west1" || euSummary.LastUpdated == nil {
+		t.Errorf("unexpected prod-eu summary: %+v", euSummary)
+	}
+	if us.Status != StatusUnreachable || !strings.Contains(us.LastError, "HTTP 401") {
+		t.Errorf("prod-us = %s (%q), want unreachable with HTTP 401", us.Status, us.LastError)
+	}
+	if eu.scopes[0] != "local" {
+		t.Errorf("scope = %q, want local", eu.scopes[0])
+	}
+
+	// A failed poll keeps the cached data but marks the cluster degraded.
+	eu.fail = true
+	clock.advance(time.Minute)
+	p.PollOnce(context.Background())
+	if c, _ := p.Cluster("prod-eu"); c.Status != StatusDegraded || c.MonthlyCostUSD != 1000 || c.LastError == "" {
+		t.Errorf("after failed poll: %+v, want degraded with cached cost", c)
+	}
+
+	// Once the cached data ages past staleAfter the cluster is stale.
+	clock.advance(5 * time.Minute)
+	if c, _ := p.Cluster("prod-eu"); c.Status != StatusStale {
+		t.Errorf("status = %s, want stale", c.Status)
+	}
+
+	eu.fail = false
+	p.PollOnce(context.Background())
+	if c, _ := p.Cluster("prod-eu"); c.Status != StatusHealthy || c.LastError != "" {
+		t.Errorf("after recovery: %s (%q), want healthy", c.Status, c.LastError)
+	}
+
+	if _, ok := p.Cluster("unknown"); ok {
+		t.Error("Cluster(unknown) should report not found")
+	}
+}
+
+func TestPoller_RemoteHubReturnsOwnCluster(t *testing.T) {
+	stub, url := startRemote(t, "t", 500)
+	stub.hub = true
+
+	p, err := NewPoller(config.HubConfig{Clusters: []config.HubClusterConfig{{Name: "edge", URL: url, Token: "t"}}}, nil)
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+	p.PollOnce(context.Background())
+	c, _ := p.Cluster("edge")
+	if c.MonthlyCostUSD != 500 {
+		t.Errorf("MonthlyCostUSD = %.0f, want the remote's own cluster (500)", c.MonthlyCostUSD)
+	}
+}
+
+func TestPoller_TokenFile(t *testing.T) {
+	_, url := startRemote(t, "rotated", 100)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("rotated\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPoller(config.HubConfig{Clusters: []config.HubClusterConfig{{Name: "a", URL: url, TokenFile: tokenFile}}}, nil)
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+	p.PollOnce(context.Background())
+	if c, _ := p.Cluster("a"); c.Status != StatusHealthy {
+		t.Errorf("status = %s (%q), want healthy with token from file", c.Status, c.LastError)
+	}
+}
+
+func TestPoller_SQLiteCache(t *testing.T) {
+	stub, url := startRemote(t, "t", 1000)
+	dbPath := filepath.Join(t.TempDir(), "hub.db")
+	cfg := config.HubConfig{
+		PollInterval: time.Minute,
+		Clusters:     []config.HubClusterConfig{{Name: "prod-eu", URL: url, Token: "t"}},
+	}
+
+	st := openStore(t, dbPath)
+	p, err := NewPoller(cfg, st)
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+	p.PollOnce(context.Background())
+	stub.cost = 1200
+	p.PollOnce(context.Background())
+
+	history := p.History("prod-eu", time.Now().Add(-time.Hour))
+	if len(history) != 2 || history[0].MonthlyCostUSD != 1000 || history[1].MonthlyCostUSD != 1200 {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+	if history[0].ID != "prod-eu" || history[0].LastUpdated == nil {
+		t.Errorf("history entries should carry the registered name and timestamp: %+v", history[0])
+	}
+
+	// A new poller (e.g. after a restart) serves the cached snapshot before
+	// its first poll, as stale data once it ages out.
+	restarted, err := NewPoller(cfg, openStore(t, dbPath))
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+	c, _ := restarted.Cluster("prod-eu")
+	if c.MonthlyCostUSD != 1200 || c.Status != StatusHealthy {
+		t.Errorf("seeded cluster = %.0f/%s, want 1200/healthy", c.MonthlyCostUSD, c.Status)
+	}
+	restarted.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if c, _ := restarted.Cluster("prod-eu"); c.Status != StatusStale {
+		t.Errorf("status = %s, want stale after staleAfter", c.Status)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rollup Tests
+// ---------------------------------------------------------------------------
+
+func TestBuildRollup(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-time.Hour)
+	clusters := []ClusterSummary{
+		{ID: "local", Source: SourceLocal, Provider: "aws", NodeCount: 10, PodCount: 100, MonthlyCostUSD: 3000, PotentialSavings: 300, EfficiencyScore: 80, Status: StatusHealthy, LastUpdated: &now},
+		{ID: "eu", Source: SourceRemote, Provider: "gcp", NodeCount: 5, PodCount: 40, MonthlyCostUSD: 1000, PotentialSavings: 200, EfficiencyScore: 40, Status: StatusStale, LastUpdated: &old},
+		{ID: "new", Source: SourceRemote, Status: StatusPending},
+		{ID: "down", Source: SourceRemote, Status: StatusUnreachable},
+	}
+
+	r := BuildRollup(clusters, now)
+	if r.ClusterCount != 4 || r.NodeCount != 15 || r.PodCount != 140 {
+		t.Errorf("counts = %d/%d/%d, want 4/15/140", r.ClusterCount, r.NodeCount, r.PodCount)
+	}
+	if r.MonthlyCostUSD != 4000 || r.PotentialSavings != 500 || r.SavingsPct != 12.5 {
+		t.Errorf("cost/savings/pct = %.0f/%.0f/%.1f, want 4000/500/12.5", r.MonthlyCostUSD, r.PotentialSavings, r.SavingsPct)
+	}
+	// Cost-weighted: (80*3000 + 40*1000) / 4000 = 70.
+	if r.EfficiencyScore != 70 {
+		t.Errorf("EfficiencyScore = %.1f, want 70", r.EfficiencyScore)
+	}
+	if r.StatusCounts[StatusStale] != 1 || r.StatusCounts[StatusPending] != 1 || r.StatusCounts[StatusUnreachable] != 1 {
+		t.Errorf("StatusCounts = %v", r.StatusCounts)
+	}
+	if r.ByProvider["gcp"].MonthlyCostUSD != 1000 || r.ByProvider["aws"].Clusters != 1 {
+		t.Errorf("ByProvider = %+v", r.ByProvider)
+	}
+	if r.OldestData == nil || !r.OldestData.Equal(old) {
+		t.Errorf("OldestData = %v, want %v", r.OldestData, old)
+	}
+}
+
+func TestBuildRollup_NoCost(t *testing.T) {
+	r := BuildRollup([]ClusterSummary{
+		{EfficiencyScore: 50, Status: StatusHealthy},
+		{EfficiencyScore: 70, Status: StatusHealthy},
+	}, time.Now())
+	if r.EfficiencyScore != 60 || r.SavingsPct != 0 {
+		t.Errorf("EfficiencyScore/SavingsPct = %.1f/%.1f, want 60/0", r.EfficiencyScore, r.SavingsPct)
+	}
+}
+
+func TestSortClusters(t *testing.T) {
+	clusters := []ClusterSummary{
+		{Name: "b", Source: SourceRemote},
+		{Name: "z", Source: SourceLocal},
+		{Name: "a", Source: SourceRemote},
+	}
+	SortClusters(clusters)
+	if clusters[0].Name != "z" || clusters[1].Name != "a" || clusters[2].Name != "b" {
+		t.Errorf("order = %s,%s,%s, want z,a,b", clusters[0].Name, clusters[1].Name, clusters[2].Name)
+	}
+}
@@ -2,10 +2,13 @@ package mcp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,108 +16,169 @@ import (
 // APIClient wraps an http.Client and a base URL to call the KOptimizer REST API.
 type APIClient struct {
 	baseURL    string
+	token      string
 	httpClient *http.Client
+	// ctx is the context of every request; cancelling it abandons them.
+	ctx context.Context
 }
 
-// NewAPIClient creates a new APIClient targeting the given base URL.
-func NewAPIClient(baseURL string) *APIClient {
+// NewAPIClient creates a new APIClient targeting the given base URL. A
+// non-empty token is sent as a bearer token with every request.
+func NewAPIClient(baseURL, token string) *APIClient {
 	return &APIClient{
 		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		ctx: context.Background(),
 	}
 }
 
-// doGet performs an HTTP GET and returns the response body as raw JSON.
-func (c *APIClient) doGet(path string) (json.RawMessage, error) {
-	url := c.baseURL + path
-	resp, err := c.httpClient.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("GET %s: %w", path, err)
+// inProcessBaseURL is the base URL of clients that serve requests through
+// an in-process handler; only its path is ever used.
+const inProcessBaseURL = "http://koptimizer.local"
+
+// NewInProcessAPIClient creates an APIClient that serves requests through h
+// in the calling process instead of over the network. A non-empty token is
+// sent as a bearer token, so h authenticates and authorizes each call as
+// it would a remote one. Requests are abandoned when ctx is done.
+func NewInProcessAPIClient(ctx context.Context, h http.Handler, token string) *APIClient {
+	c := NewAPIClient(inProcessBaseURL, token)
+	c.httpClient.Transport = handlerTransport{handler: h}
+	c.ctx = ctx
+	return c
+}
+
+// handlerTransport is an http.RoundTripper that serves requests with an
+// http.Handler.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Handlers expect what a server would hand them: a non-nil body, a
+	// remote address and a context of their own. The context is cancelled
+	// with the caller's but carries none of its values, such as the
+	// routing state of a router the caller is itself being served by.
+	caller := req.Context()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer context.AfterFunc(caller, cancel)()
+	req = req.Clone(ctx)
+	if req.Body == nil {
+		req.Body = http.NoBody
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response from GET %s: %w", path, err)
+	req.RemoteAddr = "in-process"
+	req.RequestURI = req.URL.RequestURI()
+	rw := &bufferedResponse{header: make(http.Header)}
+	t.handler.ServeHTTP(rw, req)
+	if err := caller.Err(); err != nil {
+		return nil, err
 	}
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("GET %s returned HTTP %d: %s", path, resp.StatusCode, string(body))
+	if rw.status == 0 {
+		rw.status = http.StatusOK
 	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rw.status, http.StatusText(rw.status)),
+		StatusCode:    rw.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rw.header,
+		Body:          io.NopCloser(&rw.body),
+		ContentLength: int64(rw.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// bufferedResponse is an http.ResponseWriter that keeps the response in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
 
-	return json.RawMessage(body), nil
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
 }
 
-// doPost performs an HTTP POST with a JSON body and returns the response body as raw JSON.
-func (c *APIClient) doPost(path string, payload interface{}) (json.RawMessage, error) {
-	url := c.baseURL + path
+// ObjectRef identifies a namespaced Kubernetes object.
+type ObjectRef struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
 
+// do performs an HTTP request with an optional JSON body and returns the
+// response body as raw JSON.
+func (c *APIClient) do(method, path string, payload interface{}) (json.RawMessage, error) {
 	var reqBody io.Reader
 	if payload != nil {
 		data, err := json.Marshal(payload)
 		if err != nil {
-			return nil, fmt.Errorf("marshaling POST body for %s: %w", path, err)
+			return nil, fmt.Errorf("marshaling %s body for %s: %w", method, path, err)
 		}
 		reqBody = bytes.NewReader(data)
 	}
 
-	resp, err := c.httpClient.Post(url, "application/json", reqBody)
+	req, err := http.NewRequestWithContext(c.ctx, method, c.baseURL+path, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("POST %s: %w", path, err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response from POST %s: %w", path, err)
-	}
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("POST %s returned HTTP %d: %s", path, resp.StatusCode, string(body))
+		return nil, fmt.Errorf("creating %s request for %s: %w", method, path, err)
 	}
-
-	return json.RawMessage(body), nil
-}
-
-// doPut performs an HTTP PUT with a JSON body and returns the response body as raw JSON.
-func (c *APIClient) doPut(path string, payload interface{}) (json.RawMessage, error) {
-	url := c.baseURL + path
-
-	var reqBody []byte
 	if payload != nil {
-		var err error
-		reqBody, err = json.Marshal(payload)
-		if err != nil {
-			return nil, fmt.Errorf("marshaling PUT body for %s: %w", path, err)
-		}
+		req.Header.Set("Content-Type", "application/json")
 	}
-
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("creating PUT request for %s: %w", path, err)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("PUT %s: %w", path, err)
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response from PUT %s: %w", path, err)
+		return nil, fmt.Errorf("reading response from %s %s: %w", method, path, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("PUT %s returned HTTP %d: %s", path, resp.StatusCode, string(body))
+		return nil, fmt.Errorf("%s %s returned HTTP %d: %s", method, path, resp.StatusCode, string(body))
 	}
 
 	return json.RawMessage(body), nil
 }
 
+// doGet performs an HTTP GET and returns the response body as raw JSON.
+func (c *APIClient) doGet(path string) (json.RawMessage, error) {
+	return c.do(http.MethodGet, path, nil)
+}
+
+// doPost performs an HTTP POST with a JSON body and returns the response body as raw JSON.
+func (c *APIClient) doPost(path string, payload interface{}) (json.RawMessage, error) {
+	return c.do(http.MethodPost, path, payload)
+}
+
+// doPut performs an HTTP PUT with a JSON body and returns the response body as raw JSON.
+func (c *APIClient) doPut(path string, payload interface{}) (json.RawMessage, error) {
+	return c.do(http.MethodPut, path, payload)
+}
+
+// doDelete performs an HTTP DELETE and returns the response body as raw JSON.
+func (c *APIClient) doDelete(path string) (json.RawMessage, error) {
+	return c.do(http.MethodDelete, path, nil)
+}
+
 // ── Cluster ──────────────────────────────────────────────────────────────
 
 // GetClusterSummary calls GET /api/v1/cluster/summary.
@@ -297,3 +361,109 @@ func (c *APIClient) GetConfig() (json.RawMessage, error) {
 func (c *APIClient) SetMode(mode string) (json.RawMessage, error) {
 	return c.doPut("/api/v1/config/mode", map[string]string{"mode": mode})
 }
+
+// SetControllerEnabled calls PUT /api/v1/config/controllers/{name}.
+func (c *APIClient) SetControllerEnabled(name string, enabled bool) (json.RawMessage, error) {
+	return c.doPut("/api/v1/config/controllers/"+url.PathEscape(name), map[string]bool{"enabled": enabled})
+}
+
+// SetAutoApprove calls PUT /api/v1/config/controllers/{name}/auto-approve.
+func (c *APIClient) SetAutoApprove(name string, autoApprove bool) (json.RawMessage, error) {
+	return c.doPut("/api/v1/config/controllers/"+url.PathEscape(name)+"/auto-approve", map[string]bool{"autoApprove": autoApprove})
+}
+
+// ── Actions ──────────────────────────────────────────────────────────────
+
+// ListBadPods calls GET /api/v1/actions/bad-pods.
+func (c *APIClient) ListBadPods() (json.RawMessage, error) {
+	return c.doGet("/api/v1/actions/bad-pods")
+}
+
+// DeletePods calls POST /api/v1/actions/delete-pods.
+func (c *APIClient) DeletePods(pods []ObjectRef) (json.RawMessage, error) {
+	return c.doPost("/api/v1/actions/delete-pods", map[string][]ObjectRef{"pods": pods})
+}
+
+// ListBadReplicaSets calls GET /api/v1/actions/bad-replicasets.
+func (c *APIClient) ListBadReplicaSets() (json.RawMessage, error) {
+	return c.doGet("/api/v1/actions/bad-replicasets")
+}
+
+// DeleteReplicaSets calls POST /api/v1/actions/delete-replicasets.
+func (c *APIClient) DeleteReplicaSets(replicaSets []ObjectRef) (json.RawMessage, error) {
+	return c.doPost("/api/v1/actions/delete-replicasets", map[string][]ObjectRef{"replicaSets": replicaSets})
+}
+
+// ── Scale-Down Blockers ──────────────────────────────────────────────────
+
+// GetScaleDownBlockers calls GET /api/v1/scaledown/blockers.
+func (c *APIClient) GetScaleDownBlockers() (json.RawMessage, error) {
+	return c.doGet("/api/v1/scaledown/blockers")
+}
+
+// DeletePDBs calls POST /api/v1/scaledown/delete-pdbs.
+func (c *APIClient) DeletePDBs(pdbs []ObjectRef) (json.RawMessage, error) {
+	return c.doPost("/api/v1/scaledown/delete-pdbs", map[string][]ObjectRef{"pdbs": pdbs})
+}
+
+// ── Notifications ────────────────────────────────────────────────────────
+
+// GetNotifications calls GET /api/v1/notifications.
+func (c *APIClient) GetNotifications() (json.RawMessage, error) {
+	return c.doGet("/api/v1/notifications")
+}
+
+// AddNotificationChannel calls POST /api/v1/notifications/channels.
+func (c *APIClient) AddNotificationChannel(channelType, name, webhookURL string) (json.RawMessage, error) {
+	return c.doPost("/api/v1/notifications/channels", map[string]string{"type": channelType, "name": name, "url": webhookURL})
+}
+
+// ToggleNotificationChannel calls PUT /api/v1/notifications/channels/{id}.
+func (c *APIClient) ToggleNotificationChannel(id int, enabled bool) (json.RawMessage, error) {
+	return c.doPut("/api/v1/notifications/channels/"+strconv.Itoa(id), map[string]bool{"enabled": enabled})
+}
+
+// DeleteNotificationChannel calls DELETE /api/v1/notifications/channels/{id}.
+func (c *APIClient) DeleteNotificationChannel(id int) (json.RawMessage, error) {
+	return c.doDelete("/api/v1/notifications/channels/" + strconv.Itoa(id))
+}
+
+// ── Diagnostics ──────────────────────────────────────────────────────────
+
+// GetInefficiencies calls GET /api/v1/inefficiencies.
+func (c *APIClient) GetInefficiencies() (json.RawMessage, error) {
+	return c.doGet("/api/v1/inefficiencies")
+}
+
+// GetNetworkInefficiencies calls GET /api/v1/inefficiencies/network.
+func (c *APIClient) GetNetworkInefficiencies() (json.RawMessage, error) {
+	return c.doGet("/api/v1/inefficiencies/network")
+}
+
+// GetHelmDrift calls GET /api/v1/helm-drift, bypassing its cache if refresh is set.
+func (c *APIClient) GetHelmDrift(refresh bool) (json.RawMessage, error) {
+	if refresh {
+		return c.doGet("/api/v1/helm-drift?refresh=true")
+	}
+	return c.doGet("/api/v1/helm-drift")
+}
+
+// GetIdleResources calls GET /api/v1/idle-resources.
+func (c *APIClient) GetIdleResources() (json.RawMessage, error) {
+	return c.doGet("/api/v1/idle-resources")
+}
+
+// GetAutoscalerStatus calls GET /api/v1/autoscaler/status.
+func (c *APIClient) GetAutoscalerStatus() (json.RawMessage, error) {
+	return c.doGet("/api/v1/autoscaler/status")
+}
+
+// GetAutoscalerEvents calls GET /api/v1/autoscaler/events.
+func (c *APIClient) GetAutoscalerEvents() (json.RawMessage, error) {
+	return c.doGet("/api/v1/autoscaler/events")
+}
+
+// GetOverscaledWorkloads calls GET /api/v1/autoscaler/overscaled.
+func (c *APIClient) GetOverscaledWorkloads() (json.RawMessage, error) {
+	return c.doGet("/api/v1/autoscaler/overscaled")
+}
@@ -0,0 +1,299 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/koptimizer/koptimizer/internal/apiserver/auth"
+)
+
+const (
+	// sessionHeader carries the session ID of the streamable HTTP transport.
+	sessionHeader = "Mcp-Session-Id"
+
+	// sessionIdleTimeout is how long an unused session is kept.
+	sessionIdleTimeout = time.Hour
+
+	// maxSessionsPerUser is how many sessions, and so open streams, one
+	// principal may hold. Starting another ends the least recently used.
+	maxSessionsPerUser = 10
+)
+
+// HTTPHandler serves MCP over the streamable HTTP transport: clients POST
+// JSON-RPC messages and receive JSON responses, and may hold a GET request
+// open as a server-sent event stream for resource change notifications.
+// Tools and resources are served by the wrapped REST API handler in the same
+// process, authorized with the bearer token of each MCP request and
+// cancelled with it.
+type HTTPHandler struct {
+	api          http.Handler
+	logger       *log.Logger
+	pollInterval time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// httpSession is the state of one MCP client across HTTP requests.
+type httpSession struct {
+	server   *MCPServer
+	user     string
+	lastSeen time.Time
+	// cancelStream ends the session's open GET stream, if any; stream
+	// numbers the streams so that a replaced one does not clear it.
+	cancelStream context.CancelFunc
+	stream       int
+}
+
+// NewHTTPHandler creates an HTTPHandler that serves tools and resources
+// through the REST API handler api.
+func NewHTTPHandler(api http.Handler) *HTTPHandler {
+	return &HTTPHandler{
+		api:          api,
+		logger:       log.New(os.Stderr, "[koptimizer-mcp] ", log.LstdFlags),
+		pollInterval: defaultPollInterval,
+		sessions:     make(map[string]*httpSession),
+	}
+}
+
+// SetPollInterval sets how often subscribed resources are checked for changes.
+func (h *HTTPHandler) SetPollInterval(d time.Duration) {
+	if d > 0 {
+		h.pollInterval = d
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleStream(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeHTTPError(w, http.StatusMethodNotAllowed, ErrCodeInvalidRequest, "method not allowed")
+	}
+}
+
+// handlePost dispatches a JSON-RPC message or batch. An initialize request
+// starts a new session; every other message must name an existing one.
+func (h *HTTPHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, ErrCodeParseError, "Parse error: "+err.Error())
+		return
+	}
+	batch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	var reqs []Request
+	if batch {
+		err = json.Unmarshal(body, &reqs)
+	} else {
+		reqs = make([]Request, 1)
+		err = json.Unmarshal(body, &reqs[0])
+	}
+	if err != nil || len(reqs) == 0 {
+		msg := "Parse error: empty batch"
+		if err != nil {
+			msg = "Parse error: " + err.Error()
+		}
+		writeHTTPError(w, http.StatusBadRequest, ErrCodeParseError, msg)
+		return
+	}
+
+	var sess *httpSession
+	if reqs[0].Method == "initialize" {
+		if len(reqs) > 1 {
+			writeHTTPError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "initialize must not be batched")
+			return
+		}
+		var id string
+		id, sess = h.newSession(r)
+		w.Header().Set(sessionHeader, id)
+	} else if sess = h.session(w, r); sess == nil {
+		return
+	}
+
+	server := sess.server.withClient(NewInProcessAPIClient(r.Context(), h.api, bearerToken(r)))
+	var resps []*Response
+	for i := range reqs {
+		h.logger.Printf("received method=%s id=%s", reqs[i].Method, string(reqs[i].ID))
+		if resp := server.dispatch(&reqs[i]); resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+
+	if len(resps) == 0 {
+		// Only notifications or responses were sent.
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		_ = json.NewEncoder(w).Encode(resps)
+	} else {
+		_ = json.NewEncoder(w).Encode(resps[0])
+	}
+}
+
+// handleStream holds the request open as a server-sent event stream of
+// resource change notifications until the client disconnects. A new stream
+// replaces the session's previous one.
+func (h *HTTPHandler) handleStream(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		writeHTTPError(w, http.StatusNotAcceptable, ErrCodeInvalidRequest, "GET requires Accept: text/event-stream")
+		return
+	}
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	h.mu.Lock()
+	if sess.cancelStream != nil {
+		sess.cancelStream()
+	}
+	sess.cancelStream = cancel
+	sess.stream++
+	stream := sess.stream
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		if sess.stream == stream {
+			sess.cancelStream = nil
+		}
+		sess.lastSeen = time.Now()
+		h.mu.Unlock()
+	}()
+
+	// The stream outlives the API server's read and write timeouts.
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	server := sess.server.withClient(NewInProcessAPIClient(ctx, h.api, bearerToken(r)))
+	server.watchResources(ctx, func(n *Notification) {
+		data, err := json.Marshal(n)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+			cancel()
+			return
+		}
+		_ = rc.Flush()
+	})
+}
+
+// handleDelete ends a session.
+func (h *HTTPHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	h.mu.Lock()
+	if sess.cancelStream != nil {
+		sess.cancelStream()
+	}
+	delete(h.sessions, r.Header.Get(sessionHeader))
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newSession starts a session owned by the request's principal and drops
+// sessions without an open stream that have been idle too long. A principal
+// at maxSessionsPerUser loses its least recently used session.
+func (h *HTTPHandler) newSession(r *http.Request) (string, *httpSession) {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	sess := &httpSession{
+		server:   newMCPServer(nil, h.logger),
+		user:     auth.User(r.Context(), ""),
+		lastSeen: time.Now(),
+	}
+	sess.server.SetPollInterval(h.pollInterval)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var owned []string
+	for sid, s := range h.sessions {
+		if s.cancelStream == nil && time.Since(s.lastSeen) > sessionIdleTimeout {
+			delete(h.sessions, sid)
+		} else if s.user == sess.user {
+			owned = append(owned, sid)
+		}
+	}
+	for len(owned) >= maxSessionsPerUser {
+		oldest := 0
+		for i := range owned {
+			if h.sessions[owned[i]].lastSeen.Before(h.sessions[owned[oldest]].lastSeen) {
+				oldest = i
+			}
+		}
+		if s := h.sessions[owned[oldest]]; s.cancelStream != nil {
+			s.cancelStream()
+		}
+		delete(h.sessions, owned[oldest])
+		owned = append(owned[:oldest], owned[oldest+1:]...)
+	}
+	h.sessions[id] = sess
+	return id, sess
+}
+
+// session returns the session named by the request, or writes an error and
+// returns nil. Sessions belong to the principal that started them.
+func (h *HTTPHandler) session(w http.ResponseWriter, r *http.Request) *httpSession {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		writeHTTPError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "missing "+sessionHeader+" header")
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sess, ok := h.sessions[id]
+	if !ok || sess.user != auth.User(r.Context(), "") {
+		writeHTTPError(w, http.StatusNotFound, ErrCodeInvalidRequest, "unknown session")
+		return nil
+	}
+	sess.lastSeen = time.Now()
+	return sess
+}
+
+// bearerToken returns the bearer token of the request, if any.
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// writeHTTPError writes a JSON-RPC error without an ID as the HTTP response.
+func writeHTTPError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&Response{
+		JSONRPC: "2.0",
+		Error:   &RPCError{Code: code, Message: message},
+	})
+}
@@ -0,0 +1,181 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Streamable HTTP Transport Tests
+// ---------------------------------------------------------------------------
+
+func newHTTPTransport(t *testing.T, responses map[string]string) (*httptest.Server, *apiStub) {
+	t.Helper()
+	stub := &apiStub{responses: responses, bodies: map[string]string{}}
+	h := NewHTTPHandler(stub.handler())
+	h.SetPollInterval(10 * time.Millisecond)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv, stub
+}
+
+func post(t *testing.T, url, session, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("Authorization", "Bearer caller-token")
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHTTPHandler_Session(t *testing.T) {
+	srv, stub := newHTTPTransport(t, map[string]string{
+		"GET /api/v1/cluster/summary": `{"nodeCount":3}`,
+	})
+
+	resp := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	session := resp.Header.Get(sessionHeader)
+	if resp.StatusCode != http.StatusOK || session == "" {
+		t.Fatalf("initialize: status %d, session %q", resp.StatusCode, session)
+	}
+
+	if resp := post(t, srv.URL, session, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notification status = %d, want 202", resp.StatusCode)
+	}
+
+	resp = post(t, srv.URL, session, `[{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"get_cluster_summary"}},{"jsonrpc":"2.0","id":3,"method":"ping"}]`)
+	var batch []struct {
+		ID     int            `json:"id"`
+		Result ToolCallResult `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 || batch[0].Result.Content[0].Text != `{"nodeCount":3}` {
+		t.Errorf("batch = %+v", batch)
+	}
+	if len(stub.auth) != 1 || stub.auth[0] != "Bearer caller-token" {
+		t.Errorf("API saw Authorization %q, want the caller's token", stub.auth)
+	}
+
+	if resp := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":4,"method":"ping"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("without session: status %d, want 400", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	req.Header.Set(sessionHeader, session)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete session: %v %v", resp, err)
+	}
+	if resp := post(t, srv.URL, session, `{"jsonrpc":"2.0","id":5,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted session: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHTTPHandler_StreamsResourceUpdates(t *testing.T) {
+	srv, stub := newHTTPTransport(t, map[string]string{
+		"GET /api/v1/recommendations": `[]`,
+	})
+	session := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`).Header.Get(sessionHeader)
+	if resp := post(t, srv.URL, session, `{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"koptimizer://recommendations"}}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("subscribe: status %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(sessionHeader, session)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+
+	stub.set("GET /api/v1/recommendations", `[{"id":"r-1"}]`)
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var n struct {
+			Method string         `json:"method"`
+			Params ResourceParams `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(data), &n); err != nil {
+			t.Fatal(err)
+		}
+		if n.Method != "notifications/resources/updated" || n.Params.URI != uriRecommendations {
+			t.Errorf("notification = %+v", n)
+		}
+		return
+	}
+	t.Fatalf("stream ended without a notification: %v", lines.Err())
+}
+
+func TestHTTPHandler_SessionLimit(t *testing.T) {
+	srv, _ := newHTTPTransport(t, nil)
+	var sessions []string
+	for i := 0; i <= maxSessionsPerUser; i++ {
+		resp := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+		sessions = append(sessions, resp.Header.Get(sessionHeader))
+	}
+
+	if resp := post(t, srv.URL, sessions[0], `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("least recently used session: status %d, want 404", resp.StatusCode)
+	}
+	for _, session := range sessions[1:] {
+		if resp := post(t, srv.URL, session, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+			t.Errorf("session %s: status %d, want 200", session, resp.StatusCode)
+		}
+	}
+}
+
+func TestInProcessAPIClient_Context(t *testing.T) {
+	type key struct{}
+	served := make(chan context.Context, 1)
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served <- r.Context()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "caller"))
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewInProcessAPIClient(ctx, api, "").GetClusterSummary()
+		done <- err
+	}()
+
+	handlerCtx := <-served
+	if handlerCtx.Value(key{}) != nil {
+		t.Error("handler context carries the caller's values")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("cancelled call returned no error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling the caller's context did not end the call")
+	}
+}
@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AllPrompts returns the MCP prompt definitions for KOptimizer.
+func AllPrompts() []Prompt {
+	return []Prompt{
+		{
+			Name:        "weekly_cost_review",
+			Description: "Review last week's cluster cost, the largest savings opportunities and waste.",
+			Arguments: []PromptArgument{
+				{Name: "namespace", Description: "Limit the review to this namespace."},
+			},
+		},
+		{
+			Name:        "investigate_cost_spike",
+			Description: "Find the cause of a cost increase and what would reverse it.",
+			Arguments: []PromptArgument{
+				{Name: "namespace", Description: "Namespace where the increase was noticed."},
+				{Name: "since", Description: "When the increase started, e.g. \"yesterday\" or \"2024-06-01\"."},
+			},
+		},
+	}
+}
+
+// getPrompt renders the named prompt with the given arguments.
+func getPrompt(name string, args map[string]string) (*PromptGetResult, error) {
+	var desc, text string
+	switch name {
+	case "weekly_cost_review":
+		desc = "Weekly cost review"
+		scope := "the cluster"
+		if ns := args["namespace"]; ns != "" {
+			scope = fmt.Sprintf("namespace %q", ns)
+		}
+		text = fmt.Sprintf(`Review the cost of %s over the past week using the KOptimizer tools.
+
+1. Read the %s resource (or call get_cost_summary, get_cost_by_namespace and get_cost_trend). Report the total and projected monthly cost and how it moved during the week.
+2. List the most expensive namespaces and any whose cost grew noticeably.
+3. Call get_recommendations_summary and list_recommendations. Highlight the pending recommendations with the largest estimated savings and what each would change.
+4. Call get_idle_resources and get_inefficiencies to find idle nodes, idle workloads, orphaned volumes and over-provisioned requests.
+5. Call list_underutilized_commitments and list_expiring_commitments.
+
+Finish with a short list of actions ordered by monthly savings. Do not approve recommendations or delete anything; only report.`,
+			scope, uriCostReport)
+
+	case "investigate_cost_spike":
+		desc = "Cost spike investigation"
+		var what strings.Builder
+		what.WriteString("Cluster cost has increased")
+		if ns := args["namespace"]; ns != "" {
+			fmt.Fprintf(&what, " in namespace %q", ns)
+		}
+		if since := args["since"]; since != "" {
+			fmt.Fprintf(&what, " since %s", since)
+		}
+		text = what.String() + `. Find out why using the KOptimizer tools.
+
+1. Call get_cost_trend to find when the increase started and how large it is.
+2. Call get_cost_by_namespace and get_cost_by_workload to attribute the increase to namespaces and workloads.
+3. Call list_nodegroups and list_nodes to check for more nodes, larger instance types or a shift from spot to on-demand.
+4. Call get_autoscaler_events and get_overscaled_workloads to see whether autoscaling added capacity that is not used.
+5. Call get_helm_drift for workloads whose replicas or requests drifted from their Helm values, and list_bad_pods for failing pods that still hold capacity.
+
+Report the most likely cause with the evidence for it, and the recommendation or change that would reverse it. Do not change anything.`
+
+	default:
+		return nil, fmt.Errorf("unknown prompt: %s", name)
+	}
+
+	return &PromptGetResult{
+		Description: desc,
+		Messages: []PromptMessage{
+			{Role: "user", Content: TextContent{Type: "text", Text: text}},
+		},
+	}, nil
+}
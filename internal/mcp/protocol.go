@@ -34,8 +34,19 @@ const (
 	ErrCodeMethodNotFound = -32601
 	ErrCodeInvalidParams  = -32602
 	ErrCodeInternal       = -32603
+
+	// ErrCodeResourceNotFound is the MCP error code for an unknown resource URI.
+	ErrCodeResourceNotFound = -32002
 )
 
+// Notification represents a JSON-RPC 2.0 notification, a message without an
+// ID that expects no response.
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 // MCP-specific types
 
 // InitializeParams are sent by the client during initialization.
@@ -64,7 +75,9 @@ type InitializeResult struct {
 
 // ServerCaps declares server capabilities.
 type ServerCaps struct {
-	Tools *ToolsCap `json:"tools,omitempty"`
+	Tools     *ToolsCap     `json:"tools,omitempty"`
+	Resources *ResourcesCap `json:"resources,omitempty"`
+	Prompts   *PromptsCap   `json:"prompts,omitempty"`
 }
 
 // ToolsCap indicates that the server supports tools.
@@ -72,6 +85,18 @@ type ToolsCap struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+// ResourcesCap indicates that the server supports resources and, with
+// Subscribe, notifications when a subscribed resource changes.
+type ResourcesCap struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// PromptsCap indicates that the server supports prompts.
+type PromptsCap struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
 // ServerInfo identifies the MCP server.
 type ServerInfo struct {
 	Name    string `json:"name"`
@@ -92,12 +117,17 @@ type InputSchema struct {
 	Required   []string              `json:"required,omitempty"`
 }
 
-// Property describes a single parameter in a JSON Schema.
+// Property describes a single parameter in a JSON Schema. Properties and
+// Required describe the fields of an object, such as the items of an array
+// of objects.
 type Property struct {
-	Type        string    `json:"type"`
-	Description string    `json:"description"`
-	Enum        []string  `json:"enum,omitempty"`
-	Items       *Property `json:"items,omitempty"`
+	Type        string              `json:"type"`
+	Description string              `json:"description,omitempty"`
+	Enum        []string            `json:"enum,omitempty"`
+	Items       *Property           `json:"items,omitempty"`
+	Properties  map[string]Property `json:"properties,omitempty"`
+	Required    []string            `json:"required,omitempty"`
+	Minimum     *float64            `json:"minimum,omitempty"`
 }
 
 // ToolsListResult is the response for tools/list.
@@ -122,3 +152,84 @@ type TextContent struct {
 	Type string `json:"type"`
 	Text string `json:"text"`
 }
+
+// Resource represents an MCP resource definition.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate describes a family of resources by an RFC 6570 URI template.
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourcesListResult is the response for resources/list.
+type ResourcesListResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+// ResourceTemplatesListResult is the response for resources/templates/list.
+type ResourceTemplatesListResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+// ResourceParams identify the resource of a resources/read,
+// resources/subscribe or resources/unsubscribe request.
+type ResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ResourceReadResult is returned by resources/read.
+type ResourceReadResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// ResourceContents is the text content of a resource.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// Prompt represents an MCP prompt definition.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument describes an argument a prompt accepts.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptsListResult is the response for prompts/list.
+type PromptsListResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+// PromptGetParams are sent by the client to render a prompt.
+type PromptGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// PromptGetResult is returned by prompts/get.
+type PromptGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptMessage is a single message of a rendered prompt.
+type PromptMessage struct {
+	Role    string      `json:"role"`
+	Content TextContent `json:"content"`
+}
@@ -0,0 +1,187 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	uriClusterSummary  = "koptimizer://cluster/summary"
+	uriCostReport      = "koptimizer://cost/report"
+	uriRecommendations = "koptimizer://recommendations"
+
+	// recommendationURIPrefix prefixes the URI of a single recommendation.
+	recommendationURIPrefix = uriRecommendations + "/"
+)
+
+// errResourceNotFound is returned when a URI names no resource.
+var errResourceNotFound = errors.New("resource not found")
+
+// AllResources returns the static MCP resources exposed by KOptimizer.
+func AllResources() []Resource {
+	return []Resource{
+		{
+			URI:         uriClusterSummary,
+			Name:        "Cluster summary",
+			Description: "Node, pod and node group counts, resource utilization and estimated monthly cost of the cluster.",
+			MimeType:    "application/json",
+		},
+		{
+			URI:         uriCostReport,
+			Name:        "Cost report",
+			Description: "Current cost summary, cost by namespace, the cost trend and the potential savings from open recommendations.",
+			MimeType:    "application/json",
+		},
+		{
+			URI:         uriRecommendations,
+			Name:        "Recommendations",
+			Description: "All optimization recommendations with their status and estimated savings.",
+			MimeType:    "application/json",
+		},
+	}
+}
+
+// AllResourceTemplates returns the parameterized MCP resources exposed by KOptimizer.
+func AllResourceTemplates() []ResourceTemplate {
+	return []ResourceTemplate{
+		{
+			URITemplate: recommendationURIPrefix + "{id}",
+			Name:        "Recommendation",
+			Description: "A single optimization recommendation by ID.",
+			MimeType:    "application/json",
+		},
+	}
+}
+
+// readResource returns the current content of the resource at uri.
+func (s *MCPServer) readResource(uri string) (json.RawMessage, error) {
+	switch uri {
+	case uriClusterSummary:
+		return s.client.GetClusterSummary()
+	case uriCostReport:
+		return s.costReport()
+	case uriRecommendations:
+		return s.client.ListRecommendations()
+	}
+	if id, ok := strings.CutPrefix(uri, recommendationURIPrefix); ok && id != "" && !strings.Contains(id, "/") {
+		return s.client.GetRecommendation(id)
+	}
+	return nil, fmt.Errorf("%w: %s", errResourceNotFound, uri)
+}
+
+// costReport combines the cost endpoints into a single document.
+func (s *MCPServer) costReport() (json.RawMessage, error) {
+	parts := []struct {
+		key   string
+		fetch func() (json.RawMessage, error)
+	}{
+		{"summary", s.client.GetCostSummary},
+		{"byNamespace", s.client.GetCostByNamespace},
+		{"trend", s.client.GetCostTrend},
+		{"savings", s.client.GetCostSavings},
+	}
+	report := make(map[string]json.RawMessage, len(parts))
+	for _, p := range parts {
+		raw, err := p.fetch()
+		if err != nil {
+			return nil, err
+		}
+		report[p.key] = raw
+	}
+	return json.Marshal(report)
+}
+
+// subscriptions tracks the resources a client subscribed to together with
+// a hash of the content it was last notified about.
+type subscriptions struct {
+	mu     sync.Mutex
+	hashes map[string][sha256.Size]byte
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{hashes: make(map[string][sha256.Size]byte)}
+}
+
+func (subs *subscriptions) add(uri string, content []byte) {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	subs.hashes[uri] = sha256.Sum256(content)
+}
+
+func (subs *subscriptions) remove(uri string) {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	delete(subs.hashes, uri)
+}
+
+func (subs *subscriptions) uris() []string {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	uris := make([]string, 0, len(subs.hashes))
+	for uri := range subs.hashes {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// update records the latest content of uri and reports whether it differs
+// from the content seen before. It returns false for URIs that are no
+// longer subscribed.
+func (subs *subscriptions) update(uri string, content []byte) bool {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	prev, ok := subs.hashes[uri]
+	if !ok {
+		return false
+	}
+	hash := sha256.Sum256(content)
+	subs.hashes[uri] = hash
+	return hash != prev
+}
+
+// changedResources re-reads every subscribed resource and returns the
+// URIs whose content changed since the last check.
+func (s *MCPServer) changedResources() []string {
+	var changed []string
+	for _, uri := range s.subs.uris() {
+		content, err := s.readResource(uri)
+		if err != nil {
+			s.logger.Printf("checking subscribed resource %s: %v", uri, err)
+			continue
+		}
+		if s.subs.update(uri, content) {
+			changed = append(changed, uri)
+		}
+	}
+	return changed
+}
+
+// watchResources checks the subscribed resources every pollInterval and
+// calls notify with a notifications/resources/updated message for each one
+// that changed. It blocks until ctx is done.
+func (s *MCPServer) watchResources(ctx context.Context, notify func(*Notification)) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, uri := range s.changedResources() {
+				notify(&Notification{
+					JSONRPC: "2.0",
+					Method:  "notifications/resources/updated",
+					Params:  ResourceParams{URI: uri},
+				})
+			}
+		}
+	}
+}
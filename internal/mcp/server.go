@@ -2,43 +2,86 @@ package mcp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"slices"
+	"sync"
+	"time"
 )
 
 const (
-	serverName      = "koptimizer-mcp"
-	serverVersion   = "0.1.0"
-	protocolVersion = "2024-11-05"
+	serverName    = "koptimizer-mcp"
+	serverVersion = "0.1.0"
+
+	// defaultPollInterval is how often subscribed resources are checked for changes.
+	defaultPollInterval = 30 * time.Second
 )
 
-// MCPServer is the MCP server that bridges stdio JSON-RPC to the KOptimizer REST API.
+// supportedProtocolVersions lists the MCP protocol revisions the server
+// speaks, newest first.
+var supportedProtocolVersions = []string{"2025-03-26", "2024-11-05"}
+
+// MCPServer is the MCP server that bridges JSON-RPC to the KOptimizer REST API.
 type MCPServer struct {
-	client *APIClient
-	tools  []Tool
-	logger *log.Logger
+	client       *APIClient
+	tools        []Tool
+	logger       *log.Logger
+	pollInterval time.Duration
+	subs         *subscriptions
+}
+
+// NewMCPServer creates a new MCPServer with the given API base URL. A
+// non-empty token is forwarded to the API as a bearer token.
+func NewMCPServer(baseURL, token string) *MCPServer {
+	return newMCPServer(NewAPIClient(baseURL, token), log.New(os.Stderr, "[koptimizer-mcp] ", log.LstdFlags))
 }
 
-// NewMCPServer creates a new MCPServer with the given API base URL.
-func NewMCPServer(baseURL string) *MCPServer {
+func newMCPServer(client *APIClient, logger *log.Logger) *MCPServer {
 	return &MCPServer{
-		client: NewAPIClient(baseURL),
-		tools:  AllTools(),
-		logger: log.New(os.Stderr, "[koptimizer-mcp] ", log.LstdFlags),
+		client:       client,
+		tools:        AllTools(),
+		logger:       logger,
+		pollInterval: defaultPollInterval,
+		subs:         newSubscriptions(),
 	}
 }
 
+// SetPollInterval sets how often subscribed resources are checked for changes.
+func (s *MCPServer) SetPollInterval(d time.Duration) {
+	if d > 0 {
+		s.pollInterval = d
+	}
+}
+
+// withClient returns a copy of s that calls the API through client and
+// shares the subscriptions of s.
+func (s *MCPServer) withClient(client *APIClient) *MCPServer {
+	cp := *s
+	cp.client = client
+	return &cp
+}
+
 // Run starts the stdio JSON-RPC loop. It reads requests from stdin, dispatches
-// them, and writes responses to stdout. It blocks until stdin is closed.
+// them, and writes responses to stdout. Notifications about subscribed
+// resources are written to stdout as they change. It blocks until stdin is
+// closed.
 func (s *MCPServer) Run() error {
 	reader := bufio.NewReader(os.Stdin)
-	writer := os.Stdout
+	writer := &lockedWriter{w: os.Stdout}
 
 	s.logger.Println("MCP server starting, reading from stdin")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.watchResources(ctx, func(n *Notification) {
+		s.writeMessage(writer, n)
+	})
+
 	for {
 		line, err := reader.ReadBytes('\n')
 		if err != nil {
@@ -82,6 +125,20 @@ func (s *MCPServer) dispatch(req *Request) *Response {
 		return s.handleToolsList(req)
 	case "tools/call":
 		return s.handleToolsCall(req)
+	case "resources/list":
+		return &Response{JSONRPC: "2.0", ID: req.ID, Result: ResourcesListResult{Resources: AllResources()}}
+	case "resources/templates/list":
+		return &Response{JSONRPC: "2.0", ID: req.ID, Result: ResourceTemplatesListResult{ResourceTemplates: AllResourceTemplates()}}
+	case "resources/read":
+		return s.handleResourcesRead(req)
+	case "resources/subscribe":
+		return s.handleResourcesSubscribe(req)
+	case "resources/unsubscribe":
+		return s.handleResourcesUnsubscribe(req)
+	case "prompts/list":
+		return &Response{JSONRPC: "2.0", ID: req.ID, Result: PromptsListResult{Prompts: AllPrompts()}}
+	case "prompts/get":
+		return s.handlePromptsGet(req)
 	case "ping":
 		return &Response{
 			JSONRPC: "2.0",
@@ -89,6 +146,10 @@ func (s *MCPServer) dispatch(req *Request) *Response {
 			Result:  map[string]interface{}{},
 		}
 	default:
+		if req.ID == nil {
+			// Other notifications, such as notifications/cancelled, need no response.
+			return nil
+		}
 		return &Response{
 			JSONRPC: "2.0",
 			ID:      req.ID,
@@ -100,18 +161,31 @@ func (s *MCPServer) dispatch(req *Request) *Response {
 	}
 }
 
-// handleInitialize handles the "initialize" method.
+// handleInitialize handles the "initialize" method. The server answers with
+// the client's protocol version when it supports it and its newest otherwise.
 func (s *MCPServer) handleInitialize(req *Request) *Response {
+	var params InitializeParams
+	if req.Params != nil {
+		// An unparseable request falls back to the newest version.
+		_ = json.Unmarshal(req.Params, &params)
+	}
+	version := supportedProtocolVersions[0]
+	if slices.Contains(supportedProtocolVersions, params.ProtocolVersion) {
+		version = params.ProtocolVersion
+	}
+
 	result := InitializeResult{
-		ProtocolVersion: protocolVersion,
+		ProtocolVersion: version,
 		Capabilities: ServerCaps{
-			Tools: &ToolsCap{},
+			Tools:     &ToolsCap{},
+			Resources: &ResourcesCap{Subscribe: true},
+			Prompts:   &PromptsCap{},
 		},
 		ServerInfo: ServerInfo{
 			Name:    serverName,
 			Version: serverVersion,
 		},
-		Instructions: "KOptimizer MCP server. Provides tools to query and manage Kubernetes cluster cost optimization. Connect to a running KOptimizer instance to use these tools.",
+		Instructions: "KOptimizer MCP server. Provides tools to query and manage Kubernetes cluster cost optimization, resources with the cluster summary, cost report and recommendations, and prompts for cost reviews.",
 	}
 	return &Response{
 		JSONRPC: "2.0",
@@ -187,6 +261,99 @@ func (s *MCPServer) handleToolsCall(req *Request) *Response {
 	}
 }
 
+// handleResourcesRead handles the "resources/read" method.
+func (s *MCPServer) handleResourcesRead(req *Request) *Response {
+	var params ResourceParams
+	if resp := parseResourceParams(req, &params); resp != nil {
+		return resp
+	}
+	content, err := s.readResource(params.URI)
+	if err != nil {
+		return resourceError(req, err)
+	}
+	return &Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result: ResourceReadResult{
+			Contents: []ResourceContents{
+				{URI: params.URI, MimeType: "application/json", Text: string(content)},
+			},
+		},
+	}
+}
+
+// handleResourcesSubscribe handles the "resources/subscribe" method. The
+// resource is read once so that only later changes are notified.
+func (s *MCPServer) handleResourcesSubscribe(req *Request) *Response {
+	var params ResourceParams
+	if resp := parseResourceParams(req, &params); resp != nil {
+		return resp
+	}
+	content, err := s.readResource(params.URI)
+	if err != nil {
+		return resourceError(req, err)
+	}
+	s.subs.add(params.URI, content)
+	s.logger.Printf("subscribed to %s", params.URI)
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: map[string]interface{}{}}
+}
+
+// handleResourcesUnsubscribe handles the "resources/unsubscribe" method.
+func (s *MCPServer) handleResourcesUnsubscribe(req *Request) *Response {
+	var params ResourceParams
+	if resp := parseResourceParams(req, &params); resp != nil {
+		return resp
+	}
+	s.subs.remove(params.URI)
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: map[string]interface{}{}}
+}
+
+// parseResourceParams decodes the params of a resources request and
+// returns an error response when they are invalid.
+func parseResourceParams(req *Request, params *ResourceParams) *Response {
+	if req.Params != nil {
+		if err := json.Unmarshal(req.Params, params); err != nil {
+			return errorResponse(req, ErrCodeInvalidParams, "Invalid params: "+err.Error())
+		}
+	}
+	if params.URI == "" {
+		return errorResponse(req, ErrCodeInvalidParams, "Missing required parameter: uri")
+	}
+	return nil
+}
+
+// resourceError converts a failure to read a resource into an error response.
+func resourceError(req *Request, err error) *Response {
+	if errors.Is(err, errResourceNotFound) {
+		return errorResponse(req, ErrCodeResourceNotFound, "Resource not found")
+	}
+	return errorResponse(req, ErrCodeInternal, err.Error())
+}
+
+// handlePromptsGet handles the "prompts/get" method.
+func (s *MCPServer) handlePromptsGet(req *Request) *Response {
+	var params PromptGetParams
+	if req.Params != nil {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return errorResponse(req, ErrCodeInvalidParams, "Invalid params: "+err.Error())
+		}
+	}
+	result, err := getPrompt(params.Name, params.Arguments)
+	if err != nil {
+		return errorResponse(req, ErrCodeInvalidParams, err.Error())
+	}
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// errorResponse builds a JSON-RPC error response to req.
+func errorResponse(req *Request, code int, message string) *Response {
+	return &Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error:   &RPCError{Code: code, Message: message},
+	}
+}
+
 // executeTool dispatches to the correct API client method based on the tool name.
 func (s *MCPServer) executeTool(name string, args map[string]interface{}) (json.RawMessage, error) {
 	// Helper to extract a string argument.
@@ -223,6 +390,66 @@ func (s *MCPServer) executeTool(name string, args map[string]interface{}) (json.
 		return result, nil
 	}
 
+	// Helper to extract an optional boolean argument, false when absent.
+	getBool := func(key string) (bool, error) {
+		v, ok := args[key]
+		if !ok || v == nil {
+			return false, nil
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return false, fmt.Errorf("argument %s must be a boolean", key)
+		}
+		return b, nil
+	}
+
+	// Helper to extract a required boolean argument.
+	getRequiredBool := func(key string) (bool, error) {
+		if _, ok := args[key]; !ok {
+			return false, fmt.Errorf("missing required argument: %s", key)
+		}
+		return getBool(key)
+	}
+
+	// Helper to extract an integer argument.
+	getInt := func(key string) (int, error) {
+		v, ok := args[key]
+		if !ok {
+			return 0, fmt.Errorf("missing required argument: %s", key)
+		}
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) {
+			return 0, fmt.Errorf("argument %s must be an integer", key)
+		}
+		return int(f), nil
+	}
+
+	// Helper to extract an array of {name, namespace} objects.
+	getObjectRefs := func(key string) ([]ObjectRef, error) {
+		v, ok := args[key]
+		if !ok {
+			return nil, fmt.Errorf("missing required argument: %s", key)
+		}
+		arr, ok := v.([]interface{})
+		if !ok || len(arr) == 0 {
+			return nil, fmt.Errorf("argument %s must be a non-empty array", key)
+		}
+		refs := make([]ObjectRef, 0, len(arr))
+		for i, item := range arr {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("argument %s[%d] must be an object", key, i)
+			}
+			name, _ := obj["name"].(string)
+			namespace, _ := obj["namespace"].(string)
+			if name == "" || namespace == "" {
+				return nil, fmt.Errorf("argument %s[%d] needs a name and a namespace", key, i)
+			}
+			refs = append(refs, ObjectRef{Name: name, Namespace: namespace})
+		}
+		return refs, nil
+	}
+
 	switch name {
 	// ── Cluster ──
 	case "get_cluster_summary":
@@ -379,19 +606,222 @@ func (s *MCPServer) executeTool(name string, args map[string]interface{}) (json.
 			return nil, err
 		}
 		return s.client.SetMode(mode)
+	case "set_controller_enabled":
+		controller, err := getString("controller")
+		if err != nil {
+			return nil, err
+		}
+		enabled, err := getRequiredBool("enabled")
+		if err != nil {
+			return nil, err
+		}
+		return s.client.SetControllerEnabled(controller, enabled)
+	case "set_auto_approve":
+		controller, err := getString("controller")
+		if err != nil {
+			return nil, err
+		}
+		autoApprove, err := getRequiredBool("autoApprove")
+		if err != nil {
+			return nil, err
+		}
+		return s.client.SetAutoApprove(controller, autoApprove)
+
+	// ── Actions ──
+	case "list_bad_pods":
+		return s.client.ListBadPods()
+	case "delete_pods":
+		pods, err := getObjectRefs("pods")
+		if err != nil {
+			return nil, err
+		}
+		confirm, err := getBool("confirm")
+		if err != nil {
+			return nil, err
+		}
+		if !confirm {
+			return s.previewDeletion(s.client.ListBadPods, "pods", pods, "not a failed or stuck pod")
+		}
+		return s.client.DeletePods(pods)
+	case "list_bad_replicasets":
+		return s.client.ListBadReplicaSets()
+	case "delete_replicasets":
+		replicaSets, err := getObjectRefs("replicaSets")
+		if err != nil {
+			return nil, err
+		}
+		confirm, err := getBool("confirm")
+		if err != nil {
+			return nil, err
+		}
+		if !confirm {
+			return s.previewDeletion(s.client.ListBadReplicaSets, "replicaSets", replicaSets, "not an orphaned, stale or stuck ReplicaSet")
+		}
+		return s.client.DeleteReplicaSets(replicaSets)
+
+	// ── Scale-Down Blockers ──
+	case "get_scaledown_blockers":
+		return s.client.GetScaleDownBlockers()
+	case "delete_pdbs":
+		pdbs, err := getObjectRefs("pdbs")
+		if err != nil {
+			return nil, err
+		}
+		confirm, err := getBool("confirm")
+		if err != nil {
+			return nil, err
+		}
+		if !confirm {
+			return s.previewDeletion(s.client.GetScaleDownBlockers, "blockingPDBs", pdbs, "not a PDB blocking scale-down")
+		}
+		return s.client.DeletePDBs(pdbs)
+
+	// ── Notifications ──
+	case "get_notifications":
+		return s.client.GetNotifications()
+	case "add_notification_channel":
+		channelType, err := getString("type")
+		if err != nil {
+			return nil, err
+		}
+		channelName, err := getString("name")
+		if err != nil {
+			return nil, err
+		}
+		webhookURL, err := getString("url")
+		if err != nil {
+			return nil, err
+		}
+		return s.client.AddNotificationChannel(channelType, channelName, webhookURL)
+	case "toggle_notification_channel":
+		id, err := getInt("id")
+		if err != nil {
+			return nil, err
+		}
+		enabled, err := getRequiredBool("enabled")
+		if err != nil {
+			return nil, err
+		}
+		return s.client.ToggleNotificationChannel(id, enabled)
+	case "delete_notification_channel":
+		id, err := getInt("id")
+		if err != nil {
+			return nil, err
+		}
+		confirm, err := getBool("confirm")
+		if err != nil {
+			return nil, err
+		}
+		if !confirm {
+			return s.previewChannelDeletion(id)
+		}
+		return s.client.DeleteNotificationChannel(id)
+
+	// ── Diagnostics ──
+	case "get_inefficiencies":
+		return s.client.GetInefficiencies()
+	case "get_network_inefficiencies":
+		return s.client.GetNetworkInefficiencies()
+	case "get_helm_drift":
+		refresh, err := getBool("refresh")
+		if err != nil {
+			return nil, err
+		}
+		return s.client.GetHelmDrift(refresh)
+	case "get_idle_resources":
+		return s.client.GetIdleResources()
+	case "get_autoscaler_status":
+		return s.client.GetAutoscalerStatus()
+	case "get_autoscaler_events":
+		return s.client.GetAutoscalerEvents()
+	case "get_overscaled_workloads":
+		return s.client.GetOverscaledWorkloads()
 
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", name)
 	}
 }
 
+// dryRunResult is returned by destructive tools called without confirm=true.
+type dryRunResult struct {
+	DryRun      bool          `json:"dryRun"`
+	WouldDelete []interface{} `json:"wouldDelete"`
+	WouldSkip   []skippedRef  `json:"wouldSkip"`
+	Message     string        `json:"message"`
+}
+
+type skippedRef struct {
+	ObjectRef
+	Reason string `json:"reason"`
+}
+
+const dryRunMessage = "Dry run: nothing was deleted. Call the tool again with confirm=true to delete."
+
+// previewDeletion reports which of refs a deletion would remove without
+// removing them. The API only deletes objects its listing endpoint reports,
+// so refs missing from the listKey array of list() would be refused with
+// skipReason.
+func (s *MCPServer) previewDeletion(list func() (json.RawMessage, error), listKey string, refs []ObjectRef, skipReason string) (json.RawMessage, error) {
+	raw, err := list()
+	if err != nil {
+		return nil, err
+	}
+	var listing map[string][]map[string]interface{}
+	if err := json.Unmarshal(raw, &listing); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", listKey, err)
+	}
+	listed := make(map[ObjectRef]map[string]interface{}, len(listing[listKey]))
+	for _, entry := range listing[listKey] {
+		name, _ := entry["name"].(string)
+		namespace, _ := entry["namespace"].(string)
+		listed[ObjectRef{Name: name, Namespace: namespace}] = entry
+	}
+
+	result := dryRunResult{DryRun: true, WouldDelete: []interface{}{}, WouldSkip: []skippedRef{}, Message: dryRunMessage}
+	for _, ref := range refs {
+		if entry, ok := listed[ref]; ok {
+			result.WouldDelete = append(result.WouldDelete, entry)
+		} else {
+			result.WouldSkip = append(result.WouldSkip, skippedRef{ObjectRef: ref, Reason: skipReason})
+		}
+	}
+	return json.Marshal(result)
+}
+
+// previewChannelDeletion shows the notification channel a deletion would remove.
+func (s *MCPServer) previewChannelDeletion(id int) (json.RawMessage, error) {
+	raw, err := s.client.GetNotifications()
+	if err != nil {
+		return nil, err
+	}
+	var notifications struct {
+		Channels []map[string]interface{} `json:"channels"`
+	}
+	if err := json.Unmarshal(raw, &notifications); err != nil {
+		return nil, fmt.Errorf("parsing notifications: %w", err)
+	}
+	result := dryRunResult{DryRun: true, WouldDelete: []interface{}{}, WouldSkip: []skippedRef{}, Message: dryRunMessage}
+	for _, ch := range notifications.Channels {
+		if chID, ok := ch["id"].(float64); ok && int(chID) == id && id >= 0 {
+			result.WouldDelete = append(result.WouldDelete, ch)
+			return json.Marshal(result)
+		}
+	}
+	return nil, fmt.Errorf("notification channel %d not found or not deletable", id)
+}
+
 // writeResponse writes a JSON-RPC response to the writer as a single JSON line.
 func (s *MCPServer) writeResponse(w io.Writer, resp *Response) {
 	if resp == nil {
 		// Notifications don't get a response.
 		return
 	}
-	data, err := json.Marshal(resp)
+	s.writeMessage(w, resp)
+}
+
+// writeMessage writes a JSON-RPC message to the writer as a single JSON line.
+func (s *MCPServer) writeMessage(w io.Writer, msg interface{}) {
+	data, err := json.Marshal(msg)
 	if err != nil {
 		s.logger.Printf("ERROR: failed to marshal response: %v", err)
 		return
@@ -414,3 +844,15 @@ func (s *MCPServer) writeError(w io.Writer, id json.RawMessage, code int, messag
 	}
 	s.writeResponse(w, resp)
 }
+
+// lockedWriter serializes writes from the request loop and the resource watcher.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,255 @@
+package mcp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// apiStub serves canned responses and records the requests it received.
+type apiStub struct {
+	mu        sync.Mutex
+	responses map[string]string // "METHOD /path" -> JSON body
+	requests  []string
+	bodies    map[string]string
+	auth      []string
+}
+
+func (a *apiStub) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.requests = append(a.requests, key)
+		a.bodies[key] = string(body)
+		a.auth = append(a.auth, r.Header.Get("Authorization"))
+		resp, ok := a.responses[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	})
+}
+
+func (a *apiStub) set(key, body string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.responses[key] = body
+}
+
+func newStubServer(t *testing.T, responses map[string]string) (*MCPServer, *apiStub) {
+	t.Helper()
+	stub := &apiStub{responses: responses, bodies: map[string]string{}}
+	srv := httptest.NewServer(stub.handler())
+	t.Cleanup(srv.Close)
+	return NewMCPServer(srv.URL, "secret-token"), stub
+}
+
+// ---------------------------------------------------------------------------
+// Tool Definition Tests
+// ---------------------------------------------------------------------------
+
+func TestAllTools_SchemasAndDispatch(t *testing.T) {
+	s, _ := newStubServer(t, nil)
+	seen := map[string]bool{}
+	for _, tool := range AllTools() {
+		if seen[tool.Name] {
+			t.Errorf("duplicate tool %s", tool.Name)
+		}
+		seen[tool.Name] = true
+		for _, req := range tool.InputSchema.Required {
+			if _, ok := tool.InputSchema.Properties[req]; !ok {
+				t.Errorf("%s: required argument %s has no schema", tool.Name, req)
+			}
+		}
+		if _, err := s.executeTool(tool.Name, map[string]interface{}{}); err != nil && strings.HasPrefix(err.Error(), "unknown tool") {
+			t.Errorf("%s is listed but not dispatched", tool.Name)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Destructive Tool Tests
+// ---------------------------------------------------------------------------
+
+func TestDeletePods_DryRunByDefault(t *testing.T) {
+	s, stub := newStubServer(t, map[string]string{
+		"GET /api/v1/actions/bad-pods":     `{"pods":[{"name":"job-x","namespace":"batch","status":"Failed"}]}`,
+		"POST /api/v1/actions/delete-pods": `{"deleted":1,"errors":[]}`,
+	})
+	args := map[string]interface{}{"pods": []interface{}{
+		map[string]interface{}{"name": "job-x", "namespace": "batch"},
+		map[string]interface{}{"name": "web-1", "namespace": "shop"},
+	}}
+
+	out, err := s.executeTool("delete_pods", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var preview dryRunResult
+	if err := json.Unmarshal(out, &preview); err != nil {
+		t.Fatal(err)
+	}
+	if !preview.DryRun || len(preview.WouldDelete) != 1 || len(preview.WouldSkip) != 1 || preview.WouldSkip[0].Name != "web-1" {
+		t.Errorf("preview = %+v, want job-x deleted and web-1 skipped", preview)
+	}
+	for _, req := range stub.requests {
+		if strings.HasPrefix(req, "POST") {
+			t.Fatalf("dry run sent %s", req)
+		}
+	}
+
+	args["confirm"] = true
+	if _, err := s.executeTool("delete_pods", args); err != nil {
+		t.Fatal(err)
+	}
+	if got := stub.bodies["POST /api/v1/actions/delete-pods"]; !strings.Contains(got, `"name":"web-1"`) {
+		t.Errorf("delete body = %s, want both pods", got)
+	}
+	for _, auth := range stub.auth {
+		if auth != "Bearer secret-token" {
+			t.Errorf("Authorization = %q, want the bearer token", auth)
+		}
+	}
+}
+
+func TestDeleteTools_ValidateArguments(t *testing.T) {
+	s, stub := newStubServer(t, nil)
+	cases := []struct {
+		tool string
+		args map[string]interface{}
+	}{
+		{"delete_pdbs", map[string]interface{}{"pdbs": []interface{}{}}},
+		{"delete_pdbs", map[string]interface{}{"pdbs": []interface{}{map[string]interface{}{"name": "web-pdb"}}}},
+		{"delete_replicasets", map[string]interface{}{"replicaSets": "web-7f9c"}},
+		{"delete_notification_channel", map[string]interface{}{"id": 1.5}},
+		{"delete_pods", map[string]interface{}{"pods": []interface{}{map[string]interface{}{"name": "a", "namespace": "b"}}, "confirm": "yes"}},
+	}
+	for _, tc := range cases {
+		if _, err := s.executeTool(tc.tool, tc.args); err == nil {
+			t.Errorf("%s(%v) succeeded, want an argument error", tc.tool, tc.args)
+		}
+	}
+	if len(stub.requests) != 0 {
+		t.Errorf("invalid arguments reached the API: %v", stub.requests)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Resource and Prompt Tests
+// ---------------------------------------------------------------------------
+
+func call(t *testing.T, s *MCPServer, method string, params interface{}) *Response {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.dispatch(&Request{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method, Params: raw})
+}
+
+func TestInitialize_NegotiatesProtocolVersion(t *testing.T) {
+	s, _ := newStubServer(t, nil)
+	for requested, want := range map[string]string{
+		"2024-11-05": "2024-11-05",
+		"2025-03-26": "2025-03-26",
+		"2099-01-01": supportedProtocolVersions[0],
+	} {
+		resp := call(t, s, "initialize", InitializeParams{ProtocolVersion: requested})
+		result := resp.Result.(InitializeResult)
+		if result.ProtocolVersion != want {
+			t.Errorf("initialize(%s) version = %s, want %s", requested, result.ProtocolVersion, want)
+		}
+		if result.Capabilities.Resources == nil || !result.Capabilities.Resources.Subscribe || result.Capabilities.Prompts == nil {
+			t.Errorf("capabilities = %+v, want subscribable resources and prompts", result.Capabilities)
+		}
+	}
+}
+
+func TestResources_ReadAndSubscribe(t *testing.T) {
+	s, stub := newStubServer(t, map[string]string{
+		"GET /api/v1/cluster/summary":     `{"nodeCount":3}`,
+		"GET /api/v1/cost/summary":        `{"totalMonthlyCostUSD":900}`,
+		"GET /api/v1/cost/by-namespace":   `[]`,
+		"GET /api/v1/cost/trend":          `[]`,
+		"GET /api/v1/cost/savings":        `{"total":50}`,
+		"GET /api/v1/recommendations/r-1": `{"id":"r-1"}`,
+		"GET /api/v1/recommendations":     `[]`,
+	})
+
+	resp := call(t, s, "resources/read", ResourceParams{URI: uriCostReport})
+	if resp.Error != nil {
+		t.Fatal(resp.Error.Message)
+	}
+	var report map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(resp.Result.(ResourceReadResult).Contents[0].Text), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 4 || string(report["savings"]) != `{"total":50}` {
+		t.Errorf("cost report = %v", report)
+	}
+
+	for uri, code := range map[string]int{
+		"koptimizer://nodes":                  ErrCodeResourceNotFound,
+		recommendationURIPrefix + "r-1/extra": ErrCodeResourceNotFound,
+		"":                                    ErrCodeInvalidParams,
+	} {
+		if resp := call(t, s, "resources/read", ResourceParams{URI: uri}); resp.Error == nil || resp.Error.Code != code {
+			t.Errorf("read %q = %+v, want error %d", uri, resp, code)
+		}
+	}
+	if resp := call(t, s, "resources/read", ResourceParams{URI: recommendationURIPrefix + "r-1"}); resp.Error != nil {
+		t.Errorf("read recommendation: %s", resp.Error.Message)
+	}
+
+	for _, uri := range []string{uriClusterSummary, uriRecommendations} {
+		if resp := call(t, s, "resources/subscribe", ResourceParams{URI: uri}); resp.Error != nil {
+			t.Fatal(resp.Error.Message)
+		}
+	}
+	if changed := s.changedResources(); len(changed) != 0 {
+		t.Errorf("changed = %v before any change", changed)
+	}
+	stub.set("GET /api/v1/cluster/summary", `{"nodeCount":4}`)
+	if changed := s.changedResources(); len(changed) != 1 || changed[0] != uriClusterSummary {
+		t.Errorf("changed = %v, want only the cluster summary", changed)
+	}
+	call(t, s, "resources/unsubscribe", ResourceParams{URI: uriClusterSummary})
+	stub.set("GET /api/v1/cluster/summary", `{"nodeCount":5}`)
+	if changed := s.changedResources(); len(changed) != 0 {
+		t.Errorf("changed = %v after unsubscribing", changed)
+	}
+}
+
+func TestPrompts_Get(t *testing.T) {
+	s, _ := newStubServer(t, nil)
+	for _, p := range AllPrompts() {
+		resp := call(t, s, "prompts/get", PromptGetParams{Name: p.Name})
+		if resp.Error != nil {
+			t.Errorf("%s: %s", p.Name, resp.Error.Message)
+		}
+	}
+
+	resp := call(t, s, "prompts/get", PromptGetParams{
+		Name:      "investigate_cost_spike",
+		Arguments: map[string]string{"namespace": "shop", "since": "yesterday"},
+	})
+	text := resp.Result.(*PromptGetResult).Messages[0].Content.Text
+	if !strings.HasPrefix(text, `Cluster cost has increased in namespace "shop" since yesterday.`) {
+		t.Errorf("prompt = %q", text)
+	}
+
+	if resp := call(t, s, "prompts/get", PromptGetParams{Name: "nope"}); resp.Error == nil || resp.Error.Code != ErrCodeInvalidParams {
+		t.Errorf("unknown prompt = %+v, want invalid params", resp)
+	}
+}
@@ -267,9 +267,208 @@ func AllTools() []Tool {
 				Required: []string{"mode"},
 			},
 		},
+		{
+			Name:        "set_controller_enabled",
+			Description: "Enable or disable a KOptimizer controller at runtime. The change is persisted across restarts.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"controller": {Type: "string", Description: "The controller to toggle.", Enum: controllerNames},
+					"enabled":    {Type: "boolean", Description: "Whether the controller should run."},
+				},
+				Required: []string{"controller", "enabled"},
+			},
+		},
+		{
+			Name:        "set_auto_approve",
+			Description: "Turn auto-approval of a controller's recommendations on or off. Auto-approved recommendations are applied without a human approving them (AI Gate checks still apply).",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"controller":  {Type: "string", Description: "The controller whose recommendations are auto-approved.", Enum: []string{"rightsizer"}},
+					"autoApprove": {Type: "boolean", Description: "Whether to auto-approve its recommendations."},
+				},
+				Required: []string{"controller", "autoApprove"},
+			},
+		},
+
+		// ── Actions ─────────────────────────────────────────────────
+		{
+			Name:        "list_bad_pods",
+			Description: "List pods in a failed or stuck state (e.g. Failed, CrashLoopBackOff, OOMKilled, ImagePullBackOff) with their status, node, and age, and counts by namespace and status.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "delete_pods",
+			Description: "Delete pods that are in a failed or stuck state. Pods that are healthy or in protected namespaces are refused. Without confirm=true this is a dry run that only reports which pods would be deleted.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"pods":    objectRefsProperty("The pods to delete."),
+					"confirm": confirmProperty(),
+				},
+				Required: []string{"pods"},
+			},
+		},
+		{
+			Name:        "list_bad_replicasets",
+			Description: "List ReplicaSets that are orphaned (no owning Deployment), stale (old revisions with zero replicas), or stuck (no ready replicas), with their reason, owner, and age.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "delete_replicasets",
+			Description: "Delete orphaned, stale, or stuck ReplicaSets. Without confirm=true this is a dry run that only reports which ReplicaSets would be deleted.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"replicaSets": objectRefsProperty("The ReplicaSets to delete."),
+					"confirm":     confirmProperty(),
+				},
+				Required: []string{"replicaSets"},
+			},
+		},
+
+		// ── Scale-Down Blockers ─────────────────────────────────────
+		{
+			Name:        "get_scaledown_blockers",
+			Description: "Get what is blocking node scale-down: PodDisruptionBudgets that allow no disruptions, failed scale-down events, single-replica PDBs, and unevictable or problematic pods.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "delete_pdbs",
+			Description: "Delete PodDisruptionBudgets that block scale-down. Only PDBs that currently allow zero disruptions are deleted; others are refused. Without confirm=true this is a dry run that only reports which PDBs would be deleted.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"pdbs":    objectRefsProperty("The PodDisruptionBudgets to delete."),
+					"confirm": confirmProperty(),
+				},
+				Required: []string{"pdbs"},
+			},
+		},
+
+		// ── Notifications ───────────────────────────────────────────
+		{
+			Name:        "get_notifications",
+			Description: "Get recent alerts and the configured notification channels with their IDs. Channels with a negative ID come from the static configuration and cannot be changed. IDs are positions, so they shift after a channel is deleted.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "add_notification_channel",
+			Description: "Add a Slack or Microsoft Teams webhook as a notification channel for KOptimizer alerts.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"type": {Type: "string", Description: "The channel type.", Enum: []string{"slack", "teams"}},
+					"name": {Type: "string", Description: "A display name for the channel."},
+					"url":  {Type: "string", Description: "The incoming webhook URL."},
+				},
+				Required: []string{"type", "name", "url"},
+			},
+		},
+		{
+			Name:        "toggle_notification_channel",
+			Description: "Enable or disable a notification channel by its ID from get_notifications.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"id":      {Type: "integer", Description: "The channel ID.", Minimum: minimum(0)},
+					"enabled": {Type: "boolean", Description: "Whether the channel should receive alerts."},
+				},
+				Required: []string{"id", "enabled"},
+			},
+		},
+		{
+			Name:        "delete_notification_channel",
+			Description: "Delete a notification channel by its ID from get_notifications. Without confirm=true this is a dry run that only shows the channel that would be deleted.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"id":      {Type: "integer", Description: "The channel ID.", Minimum: minimum(0)},
+					"confirm": confirmProperty(),
+				},
+				Required: []string{"id"},
+			},
+		},
+
+		// ── Diagnostics ─────────────────────────────────────────────
+		{
+			Name:        "get_inefficiencies",
+			Description: "Get a consolidated view of cluster inefficiencies: max-pods limits, anti-affinity spread, KEDA/HPA issues, bad PDBs, bad pods, node fragmentation, bad CPU/memory ratios, and network hogs, with severities and estimated wasted cost.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "get_network_inefficiencies",
+			Description: "Get per-pod and per-node network I/O, for finding pods with heavy network traffic.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "get_helm_drift",
+			Description: "Get workloads whose live resources or replicas drifted from their Helm values in Git, with the cost impact of each drifted field.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"refresh": {Type: "boolean", Description: "Re-fetch the Helm values instead of using the results cached for up to 5 minutes."},
+				},
+			},
+		},
+		{
+			Name:        "get_idle_resources",
+			Description: "Get idle nodes, idle workloads, and orphaned PVCs with their wasted cost.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "get_autoscaler_status",
+			Description: "Get node counts (empty, cordoned by KOptimizer or externally) and a per-node scale-down analysis from the autoscaler's point of view.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "get_autoscaler_events",
+			Description: "Get recent cluster-autoscaler and Karpenter events such as scale-ups, scale-downs, and failures, together with KOptimizer's own node actions.",
+			InputSchema: emptySchema(),
+		},
+		{
+			Name:        "get_overscaled_workloads",
+			Description: "Get workloads scaled up by an HPA or KEDA ScaledObject that have very low actual CPU/memory utilization, with the root cause of each.",
+			InputSchema: emptySchema(),
+		},
 	}
 }
 
+// controllerNames are the controllers that can be toggled at runtime.
+var controllerNames = []string{
+	"costMonitor", "nodegroupMgr", "rightsizer", "workloadScaler", "gpu", "gpuReclaim",
+	"commitments", "aiGate", "podPurger", "evictor", "rebalancer",
+}
+
+// objectRefsProperty returns the schema of an array of namespaced objects.
+func objectRefsProperty(description string) Property {
+	return Property{
+		Type:        "array",
+		Description: description,
+		Items: &Property{
+			Type: "object",
+			Properties: map[string]Property{
+				"name":      {Type: "string", Description: "The object name."},
+				"namespace": {Type: "string", Description: "The object namespace."},
+			},
+			Required: []string{"name", "namespace"},
+		},
+	}
+}
+
+// confirmProperty returns the schema of the confirm argument of destructive tools.
+func confirmProperty() Property {
+	return Property{
+		Type:        "boolean",
+		Description: "Must be true to actually delete. When false or omitted, nothing is changed and the tool returns a dry-run preview.",
+	}
+}
+
+func minimum(v float64) *float64 {
+	return &v
+}
+
 // emptySchema returns an input schema with no parameters.
 func emptySchema() InputSchema {
 	return InputSchema{
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+)
+
+// cAdvisor CFS counters, exposed by the kubelet at /metrics/cadvisor.
+const (
+	cadvisorCFSPeriods   = "container_cpu_cfs_periods_total"
+	cadvisorCFSThrottled = "container_cpu_cfs_throttled_periods_total"
+)
+
+// ParseCFSThrottling extracts per-container CFS counters from kubelet
+// cAdvisor output in Prometheus text format. Containers without a CPU limit
+// have no CFS quota and export no series, so they never appear. The pause
+// container and pod-level cgroups are skipped. Results are sorted by
+// namespace, pod and container and stamped with ts.
+func ParseCFSThrottling(r io.Reader, ts time.Time) ([]pkgmetrics.CFSThrottling, error) {
+	samples := make(map[string]*pkgmetrics.CFSThrottling)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, cadvisorCFSPeriods) && !strings.HasPrefix(line, cadvisorCFSThrottled) {
+			continue
+		}
+		name, lbls, value, err := parsePromLine(line)
+		if err != nil {
+			return nil, err
+		}
+		if name != cadvisorCFSPeriods && name != cadvisorCFSThrottled {
+			continue
+		}
+
+		// Older kubelets use the *_name label variants.
+		ns := firstLabel(lbls, "namespace")
+		pod := firstLabel(lbls, "pod", "pod_name")
+		container := firstLabel(lbls, "container", "container_name")
+		if ns == "" || pod == "" || container == "" || container == "POD" {
+			continue
+		}
+
+		key := ns + "/" + pod + "/" + container
+		s, ok := samples[key]
+		if !ok {
+			s = &pkgmetrics.CFSThrottling{Namespace: ns, Pod: pod, Container: container, Timestamp: ts}
+			samples[key] = s
+		}
+		if name == cadvisorCFSPeriods {
+			s.Periods = value
+		} else {
+			s.ThrottledPeriods = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(samples))
+	for k := range samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]pkgmetrics.CFSThrottling, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, *samples[k])
+	}
+	return result, nil
+}
+
+func firstLabel(lbls map[string]string, names ...string) string {
+	for _, n := range names {
+		if v := lbls[n]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
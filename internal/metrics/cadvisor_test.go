@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+)
+
+const cadvisorSample = `# HELP container_cpu_cfs_periods_total Number of elapsed enforcement period intervals.
+# TYPE container_cpu_cfs_periods_total counter
+container_cpu_cfs_periods_total{container="api",namespace="default",pod="api-7d9f"} 1200 1700000000000
+container_cpu_cfs_periods_total{container="POD",namespace="default",pod="api-7d9f"} 1200
+container_cpu_cfs_periods_total{container="",namespace="default",pod="api-7d9f"} 1200
+container_cpu_cfs_periods_total{container_name="worker",namespace="batch",pod_name="worker-0"} 50
+# HELP container_cpu_cfs_throttled_periods_total Number of throttled period intervals.
+# TYPE container_cpu_cfs_throttled_periods_total counter
+container_cpu_cfs_throttled_periods_total{container="api",namespace="default",pod="api-7d9f"} 300 1700000000000
+container_cpu_cfs_throttled_periods_total{container_name="worker",namespace="batch",pod_name="worker-0"} 0
+container_cpu_usage_seconds_total{container="api",namespace="default",pod="api-7d9f"} 42
+`
+
+// ---------------------------------------------------------------------------
+// Parser Tests
+// ---------------------------------------------------------------------------
+
+func TestParseCFSThrottling(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	samples, err := ParseCFSThrottling(strings.NewReader(cadvisorSample), ts)
+	if err != nil {
+		t.Fatalf("ParseCFSThrottling: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 containers (pause and pod cgroup skipped), got %d: %+v", len(samples), samples)
+	}
+
+	worker, api := samples[0], samples[1]
+	if worker.Namespace != "batch" || worker.Pod != "worker-0" || worker.Container != "worker" {
+		t.Errorf("legacy labels not honoured: %+v", worker)
+	}
+	if api.Periods != 1200 || api.ThrottledPeriods != 300 {
+		t.Errorf("api counters = %.0f/%.0f, want 1200/300", api.Periods, api.ThrottledPeriods)
+	}
+	if !api.Timestamp.Equal(ts) {
+		t.Errorf("api timestamp = %v, want %v", api.Timestamp, ts)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Store Tests
+// ---------------------------------------------------------------------------
+
+func TestStore_ThrottlingWindow(t *testing.T) {
+	s := NewStore(24 * time.Hour)
+	now := time.Now()
+	sample := func(minute int, periods, throttled float64) []pkgmetrics.CFSThrottling {
+		return []pkgmetrics.CFSThrottling{{
+			Namespace: "default", Pod: "api-7d9f", Container: "api",
+			Periods: periods, ThrottledPeriods: throttled,
+			Timestamp: now.Add(time.Duration(minute-10) * time.Minute),
+		}}
+	}
+
+	s.RecordThrottling(sample(0, 1000, 100))
+	if s.GetThrottlingWindow("default", "api-7d9f", "api", time.Hour) != nil {
+		t.Fatal("the first scrape only primes the counters and must not produce a point")
+	}
+
+	s.RecordThrottling(sample(1, 1600, 100)) // 0 of 600 throttled
+	s.RecordThrottling(sample(2, 2200, 400)) // 300 of 600 throttled
+	s.RecordThrottling(sample(3, 2200, 400)) // no elapsed periods: skipped
+	s.RecordThrottling(sample(4, 100, 50))   // container restarted: counters reset, skipped
+	s.RecordThrottling(sample(5, 700, 650))  // 600 of 600 throttled
+
+	w := s.GetThrottlingWindow("default", "api-7d9f", "api", time.Hour)
+	if w == nil {
+		t.Fatal("expected a throttling window")
+	}
+	if w.DataPoints != 3 {
+		t.Errorf("DataPoints = %d, want 3", w.DataPoints)
+	}
+	if w.MaxRatio != 1 {
+		t.Errorf("MaxRatio = %.2f, want 1", w.MaxRatio)
+	}
+	if w.AvgRatio != 0.5 {
+		t.Errorf("AvgRatio = %.2f, want 0.5", w.AvgRatio)
+	}
+
+	if s.GetThrottlingWindow("default", "api-7d9f", "other", time.Hour) != nil {
+		t.Error("expected nil window for unknown container")
+	}
+}
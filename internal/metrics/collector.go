@@ -3,6 +3,7 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -14,7 +15,8 @@ import (
 
 // Collector implements MetricsCollector using K8s Metrics API.
 type Collector struct {
-	client client.Client
+	client    client.Client
+	gpuSource GPUSource
 }
 
 // NewCollector creates a new metrics Collector.
@@ -22,6 +24,12 @@ func NewCollector(c client.Client) *Collector {
 	return &Collector{client: c}
 }
 
+// SetGPUSource installs a measured GPU metrics source. Without one,
+// GetGPUMetrics estimates utilization from GPU allocation.
+func (c *Collector) SetGPUSource(src GPUSource) {
+	c.gpuSource = src
+}
+
 func (c *Collector) GetNodeMetrics(ctx context.Context) ([]pkgmetrics.NodeMetrics, error) {
 	metricsList := &metricsv1beta1.NodeMetricsList{}
 	if err := c.client.List(ctx, metricsList); err != nil {
@@ -103,6 +111,16 @@ func (c *Collector) GetPodMetricsByName(ctx context.Context, namespace, name str
 }
 
 func (c *Collector) GetGPUMetrics(ctx context.Context, nodeName string) (*pkgmetrics.GPUMetrics, error) {
+	if c.gpuSource != nil {
+		return c.gpuSource.GetGPUMetrics(ctx, nodeName)
+	}
+	return c.allocationGPUMetrics(ctx, nodeName)
+}
+
+// allocationGPUMetrics estimates GPU metrics from nvidia.com/gpu requests.
+// Results carry Source GPUSourceAllocation so callers can tell them apart
+// from measured data.
+func (c *Collector) allocationGPUMetrics(ctx context.Context, nodeName string) (*pkgmetrics.GPUMetrics, error) {
 	node := &corev1.Node{}
 	if err := c.client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
 		return nil, fmt.Errorf("getting node %s: %w", nodeName, err)
@@ -131,9 +149,8 @@ func (c *Collector) GetGPUMetrics(ctx context.Context, nodeName string) (*pkgmet
 		}
 	}
 
-	// Build per-device metrics from allocation data.
-	// Actual utilization requires DCGM exporter + Prometheus; here we estimate
-	// from allocation: each requested GPU is considered "in use".
+	// Build per-device metrics from allocation data: each requested GPU is
+	// considered "in use". Configure a GPUSource for measured utilization.
 	var gpuDevices []pkgmetrics.GPUDeviceMetrics
 	for i := int64(0); i < gpuCapacity; i++ {
 		util := float64(0)
@@ -147,7 +164,9 @@ func (c *Collector) GetGPUMetrics(ctx context.Context, nodeName string) (*pkgmet
 	}
 
 	return &pkgmetrics.GPUMetrics{
-		NodeName: nodeName,
-		GPUs:     gpuDevices,
+		NodeName:  nodeName,
+		GPUs:      gpuDevices,
+		Timestamp: time.Now(),
+		Source:    pkgmetrics.GPUSourceAllocation,
 	}, nil
 }
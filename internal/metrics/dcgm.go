@@ -0,0 +1,342 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+)
+
+// GPUSource supplies measured per-device GPU metrics for a node.
+type GPUSource interface {
+	GetGPUMetrics(ctx context.Context, nodeName string) (*pkgmetrics.GPUMetrics, error)
+}
+
+// DCGM exporter field names. SM_ACTIVE is a profiling metric (0-1 ratio)
+// that reflects real SM occupancy; GPU_UTIL (0-100) only reports whether any
+// kernel was running and is used when profiling metrics are disabled.
+const (
+	dcgmSMActive   = "DCGM_FI_PROF_SM_ACTIVE"
+	dcgmGPUUtil    = "DCGM_FI_DEV_GPU_UTIL"
+	dcgmFBUsed     = "DCGM_FI_DEV_FB_USED"     // MiB
+	dcgmFBFree     = "DCGM_FI_DEV_FB_FREE"     // MiB
+	dcgmFBReserved = "DCGM_FI_DEV_FB_RESERVED" // MiB
+	dcgmGPUTemp    = "DCGM_FI_DEV_GPU_TEMP"    // Celsius
+
+	mib = 1024 * 1024
+)
+
+// DCGMSource scrapes NVIDIA DCGM exporter metrics in Prometheus text format.
+// By default it finds the dcgm-exporter pod running on the node and scrapes
+// it directly. If an endpoint template is configured it is scraped instead;
+// templates without a {node} or {nodeIP} placeholder are treated as shared
+// endpoints and filtered by the exporter's Hostname label.
+type DCGMSource struct {
+	client     client.Client
+	httpClient *http.Client
+	namespace  string
+	selector   labels.Selector
+	port       int
+	endpoint   string
+}
+
+// NewGPUSource returns the GPU metrics source selected by cfg.GPU.MetricsSource,
+// or nil for the default allocation-based estimate.
+func NewGPUSource(c client.Client, cfg *config.Config) (GPUSource, error) {
+	switch cfg.GPU.MetricsSource {
+	case "", "allocation":
+		return nil, nil
+	case "dcgm":
+		return NewDCGMSource(c, cfg.GPU, "")
+	case "endpoint":
+		return NewDCGMSource(c, cfg.GPU, cfg.GPU.MetricsEndpoint)
+	default:
+		return nil, fmt.Errorf("unknown GPU metrics source %q", cfg.GPU.MetricsSource)
+	}
+}
+
+// NewDCGMSource creates a DCGMSource. An empty endpoint enables per-node
+// exporter pod discovery.
+func NewDCGMSource(c client.Client, cfg config.GPUConfig, endpoint string) (*DCGMSource, error) {
+	selector, err := labels.Parse(cfg.DCGMLabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing dcgm label selector %q: %w", cfg.DCGMLabelSelector, err)
+	}
+	port := cfg.DCGMPort
+	if port <= 0 {
+		port = 9400
+	}
+	timeout := cfg.MetricsScrapeTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &DCGMSource{
+		client:     c,
+		httpClient: &http.Client{Timeout: timeout},
+		namespace:  cfg.DCGMNamespace,
+		selector:   selector,
+		port:       port,
+		endpoint:   endpoint,
+	}, nil
+}
+
+func (d *DCGMSource) GetGPUMetrics(ctx context.Context, nodeName string) (*pkgmetrics.GPUMetrics, error) {
+	url, hostname, err := d.scrapeURL(ctx, nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building dcgm request: %w", err)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("scraping dcgm metrics for node %s: %w", nodeName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scraping dcgm metrics for node %s: HTTP %d", nodeName, resp.StatusCode)
+	}
+
+	devices, err := ParseDCGMMetrics(resp.Body, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("parsing dcgm metrics for node %s: %w", nodeName, err)
+	}
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("no dcgm GPU series found for node %s", nodeName)
+	}
+	return &pkgmetrics.GPUMetrics{
+		NodeName:  nodeName,
+		GPUs:      devices,
+		Timestamp: time.Now(),
+		Source:    pkgmetrics.GPUSourceDCGM,
+	}, nil
+}
+
+// scrapeURL resolves the metrics URL for a node. The returned hostname is
+// non-empty when series must be filtered by the Hostname label.
+func (d *DCGMSource) scrapeURL(ctx context.Context, nodeName string) (string, string, error) {
+	if d.endpoint != "" {
+		url := d.endpoint
+		perNode := strings.Contains(url, "{node}") || strings.Contains(url, "{nodeIP}")
+		url = strings.ReplaceAll(url, "{node}", nodeName)
+		if strings.Contains(url, "{nodeIP}") {
+			node := &corev1.Node{}
+			if err := d.client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+				return "", "", fmt.Errorf("getting node %s: %w", nodeName, err)
+			}
+			ip := nodeInternalIP(node)
+			if ip == "" {
+				return "", "", fmt.Errorf("node %s has no InternalIP", nodeName)
+			}
+			url = strings.ReplaceAll(url, "{nodeIP}", ip)
+		}
+		if perNode {
+			return url, "", nil
+		}
+		return url, nodeName, nil
+	}
+
+	podList := &corev1.PodList{}
+	if err := d.client.List(ctx, podList,
+		client.InNamespace(d.namespace),
+		client.MatchingLabelsSelector{Selector: d.selector},
+		client.MatchingFields{"spec.nodeName": nodeName},
+	); err != nil {
+		return "", "", fmt.Errorf("listing dcgm-exporter pods on node %s: %w", nodeName, err)
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" {
+			return fmt.Sprintf("http://%s:%d/metrics", pod.Status.PodIP, d.port), "", nil
+		}
+	}
+	return "", "", fmt.Errorf("no running dcgm-exporter pod on node %s", nodeName)
+}
+
+func nodeInternalIP(node *corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// dcgmDevice accumulates the series of one physical GPU.
+type dcgmDevice struct {
+	metrics     pkgmetrics.GPUDeviceMetrics
+	smActive    float64
+	hasSMActive bool
+	gpuUtil     float64
+	fbUsed      float64
+	fbFree      float64
+	fbReserved  float64
+}
+
+// ParseDCGMMetrics parses DCGM exporter output in Prometheus text format into
+// per-device metrics sorted by index. If hostname is non-empty, series with a
+// different Hostname label are ignored. MIG instance series are folded into
+// their physical GPU: utilization and temperature take the maximum, memory
+// is summed.
+func ParseDCGMMetrics(r io.Reader, hostname string) ([]pkgmetrics.GPUDeviceMetrics, error) {
+	devices := make(map[int]*dcgmDevice)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		name, lbls, value, err := parsePromLine(line)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case dcgmSMActive, dcgmGPUUtil, dcgmFBUsed, dcgmFBFree, dcgmFBReserved, dcgmGPUTemp:
+		default:
+			continue
+		}
+		if hostname != "" && lbls["Hostname"] != "" && lbls["Hostname"] != hostname {
+			continue
+		}
+		idx, err := strconv.Atoi(lbls["gpu"])
+		if err != nil {
+			continue
+		}
+		dev, ok := devices[idx]
+		if !ok {
+			dev = &dcgmDevice{metrics: pkgmetrics.GPUDeviceMetrics{
+				Index: idx,
+				UUID:  lbls["UUID"],
+				Model: lbls["modelName"],
+			}}
+			devices[idx] = dev
+		}
+
+		switch name {
+		case dcgmSMActive:
+			dev.smActive = max(dev.smActive, value*100)
+			dev.hasSMActive = true
+		case dcgmGPUUtil:
+			dev.gpuUtil = max(dev.gpuUtil, value)
+		case dcgmFBUsed:
+			dev.fbUsed += value
+		case dcgmFBFree:
+			dev.fbFree += value
+		case dcgmFBReserved:
+			dev.fbReserved += value
+		case dcgmGPUTemp:
+			dev.metrics.Temperature = max(dev.metrics.Temperature, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]pkgmetrics.GPUDeviceMetrics, 0, len(devices))
+	for _, dev := range devices {
+		m := dev.metrics
+		if dev.hasSMActive {
+			m.Utilization = dev.smActive
+		} else {
+			m.Utilization = dev.gpuUtil
+		}
+		m.MemoryUsed = int64(dev.fbUsed * mib)
+		m.MemoryTotal = int64((dev.fbUsed + dev.fbFree + dev.fbReserved) * mib)
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Index < result[j].Index })
+	return result, nil
+}
+
+// parsePromLine parses one sample line: name{k="v",...} value [timestamp].
+func parsePromLine(line string) (string, map[string]string, float64, error) {
+	var name, rest string
+	lbls := make(map[string]string)
+
+	if i := strings.IndexByte(line, '{'); i >= 0 {
+		name = line[:i]
+		var err error
+		rest, err = parsePromLabels(line[i+1:], lbls)
+		if err != nil {
+			return "", nil, 0, fmt.Errorf("metric %s: %w", name, err)
+		}
+	} else {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return "", nil, 0, fmt.Errorf("malformed sample line %q", line)
+		}
+		name, rest = fields[0], strings.Join(fields[1:], " ")
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, fmt.Errorf("metric %s: missing value", name)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("metric %s: invalid value %q", name, fields[0])
+	}
+	return strings.TrimSpace(name), lbls, value, nil
+}
+
+// parsePromLabels parses label pairs up to the closing brace and returns the
+// remainder of the line.
+func parsePromLabels(s string, out map[string]string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return "", fmt.Errorf("unterminated label set")
+		}
+		if s[0] == '}' {
+			return s[1:], nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			return "", fmt.Errorf("malformed label in %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+2:]
+
+		var val strings.Builder
+		closed := false
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					val.WriteByte('\n')
+				default:
+					val.WriteByte(s[i])
+				}
+				continue
+			}
+			if c == '"' {
+				s = s[i+1:]
+				closed = true
+				break
+			}
+			val.WriteByte(c)
+		}
+		if !closed {
+			return "", fmt.Errorf("unterminated value for label %s", key)
+		}
+		out[key] = val.String()
+	}
+}
@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+)
+
+const dcgmSample = `# HELP DCGM_FI_DEV_GPU_UTIL GPU utilization (in %).
+# TYPE DCGM_FI_DEV_GPU_UTIL gauge
+DCGM_FI_DEV_GPU_UTIL{gpu="0",UUID="GPU-aaa",device="nvidia0",modelName="NVIDIA A100-SXM4-40GB",Hostname="gpu-node-1"} 87
+DCGM_FI_DEV_GPU_UTIL{gpu="1",UUID="GPU-bbb",device="nvidia1",modelName="NVIDIA A100-SXM4-40GB",Hostname="gpu-node-1"} 0
+DCGM_FI_DEV_GPU_UTIL{gpu="0",UUID="GPU-ccc",device="nvidia0",modelName="NVIDIA A100-SXM4-40GB",Hostname="gpu-node-2"} 55
+# HELP DCGM_FI_PROF_SM_ACTIVE The ratio of cycles an SM has at least 1 warp assigned.
+# TYPE DCGM_FI_PROF_SM_ACTIVE gauge
+DCGM_FI_PROF_SM_ACTIVE{gpu="0",UUID="GPU-aaa",device="nvidia0",modelName="NVIDIA A100-SXM4-40GB",Hostname="gpu-node-1"} 0.25
+DCGM_FI_DEV_FB_USED{gpu="0",UUID="GPU-aaa",device="nvidia0",modelName="NVIDIA A100-SXM4-40GB",Hostname="gpu-node-1"} 1024
+DCGM_FI_DEV_FB_FREE{gpu="0",UUID="GPU-aaa",device="nvidia0",modelName="NVIDIA A100-SXM4-40GB",Hostname="gpu-node-1"} 3072
+DCGM_FI_DEV_GPU_TEMP{gpu="0",UUID="GPU-aaa",device="nvidia0",modelName="NVIDIA A100-SXM4-40GB",Hostname="gpu-node-1"} 61
+DCGM_FI_DEV_GPU_TEMP{gpu="1",UUID="GPU-bbb",device="nvidia1",modelName="NVIDIA A100-SXM4-40GB",Hostname="gpu-node-1"} 38
+`
+
+// ---------------------------------------------------------------------------
+// Parser Tests
+// ---------------------------------------------------------------------------
+
+func TestParseDCGMMetrics(t *testing.T) {
+	devices, err := ParseDCGMMetrics(strings.NewReader(dcgmSample), "gpu-node-1")
+	if err != nil {
+		t.Fatalf("ParseDCGMMetrics: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices for gpu-node-1, got %d", len(devices))
+	}
+
+	gpu0 := devices[0]
+	if gpu0.Index != 0 || gpu0.UUID != "GPU-aaa" || gpu0.Model != "NVIDIA A100-SXM4-40GB" {
+		t.Errorf("unexpected identity: %+v", gpu0)
+	}
+	// SM_ACTIVE takes precedence over GPU_UTIL when present.
+	if gpu0.Utilization != 25 {
+		t.Errorf("gpu0 utilization = %.1f, want 25 (from SM_ACTIVE)", gpu0.Utilization)
+	}
+	if gpu0.MemoryUsed != 1024*mib || gpu0.MemoryTotal != 4096*mib {
+		t.Errorf("gpu0 memory = %d/%d, want %d/%d", gpu0.MemoryUsed, gpu0.MemoryTotal, 1024*mib, 4096*mib)
+	}
+	if gpu0.Temperature != 61 {
+		t.Errorf("gpu0 temperature = %.0f, want 61", gpu0.Temperature)
+	}
+
+	gpu1 := devices[1]
+	if gpu1.Utilization != 0 || gpu1.Temperature != 38 {
+		t.Errorf("gpu1 = %+v, want utilization 0 and temperature 38", gpu1)
+	}
+}
+
+func TestParseDCGMMetrics_NoHostnameFilter(t *testing.T) {
+	devices, err := ParseDCGMMetrics(strings.NewReader(dcgmSample), "")
+	if err != nil {
+		t.Fatalf("ParseDCGMMetrics: %v", err)
+	}
+	// gpu-node-2's GPU 0 folds into index 0 when no filter is applied, so
+	// per-node scrapes must come from the node's own exporter.
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 device indexes, got %d", len(devices))
+	}
+}
+
+func TestParsePromLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantName  string
+		wantValue float64
+		wantLabel string
+		wantErr   bool
+	}{
+		{name: "no labels", line: "up 1", wantName: "up", wantValue: 1},
+		{name: "labels with timestamp", line: `m{gpu="3"} 42 1700000000000`, wantName: "m", wantValue: 42, wantLabel: "3"},
+		{name: "escaped quote in label", line: `m{gpu="3",x="a\"b,c"} 7`, wantName: "m", wantValue: 7, wantLabel: "3"},
+		{name: "missing value", line: `m{gpu="3"}`, wantErr: true},
+		{name: "unterminated labels", line: `m{gpu="3" 7`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, lbls, value, err := parsePromLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if name != tt.wantName || value != tt.wantValue {
+				t.Errorf("got %s=%v, want %s=%v", name, value, tt.wantName, tt.wantValue)
+			}
+			if lbls["gpu"] != tt.wantLabel {
+				t.Errorf("gpu label = %q, want %q", lbls["gpu"], tt.wantLabel)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Source Tests
+// ---------------------------------------------------------------------------
+
+func TestDCGMSource_SharedEndpointFiltersByHostname(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, dcgmSample)
+	}))
+	defer srv.Close()
+
+	src, err := NewDCGMSource(nil, config.GPUConfig{MetricsScrapeTimeout: time.Second}, srv.URL+"/metrics")
+	if err != nil {
+		t.Fatalf("NewDCGMSource: %v", err)
+	}
+	m, err := src.GetGPUMetrics(context.Background(), "gpu-node-2")
+	if err != nil {
+		t.Fatalf("GetGPUMetrics: %v", err)
+	}
+	if m.Source != pkgmetrics.GPUSourceDCGM {
+		t.Errorf("Source = %q, want %q", m.Source, pkgmetrics.GPUSourceDCGM)
+	}
+	if len(m.GPUs) != 1 || m.GPUs[0].UUID != "GPU-ccc" || m.GPUs[0].Utilization != 55 {
+		t.Errorf("unexpected devices for gpu-node-2: %+v", m.GPUs)
+	}
+}
+
+func TestDCGMSource_PerNodeEndpoint(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		fmt.Fprint(w, dcgmSample)
+	}))
+	defer srv.Close()
+
+	src, err := NewDCGMSource(nil, config.GPUConfig{}, srv.URL+"/nodes/{node}/metrics")
+	if err != nil {
+		t.Fatalf("NewDCGMSource: %v", err)
+	}
+	m, err := src.GetGPUMetrics(context.Background(), "gpu-node-1")
+	if err != nil {
+		t.Fatalf("GetGPUMetrics: %v", err)
+	}
+	if gotPath != "/nodes/gpu-node-1/metrics" {
+		t.Errorf("scraped path = %q, want /nodes/gpu-node-1/metrics", gotPath)
+	}
+	if len(m.GPUs) == 0 {
+		t.Error("expected devices from per-node endpoint")
+	}
+}
+
+func TestDCGMSource_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	src, _ := NewDCGMSource(nil, config.GPUConfig{}, srv.URL)
+	if _, err := src.GetGPUMetrics(context.Background(), "gpu-node-1"); err == nil {
+		t.Error("expected error on HTTP 503")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Store Tests
+// ---------------------------------------------------------------------------
+
+func TestStore_GPUWindow(t *testing.T) {
+	s := NewStore(24 * time.Hour)
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		util := 2.0
+		if i == 9 {
+			util = 90 // single spike
+		}
+		s.RecordGPUMetrics(pkgmetrics.GPUMetrics{
+			NodeName:  "gpu-node-1",
+			Timestamp: now.Add(time.Duration(i-10) * time.Minute),
+			GPUs: []pkgmetrics.GPUDeviceMetrics{
+				{Index: 0, Utilization: util, MemoryUsed: 100, Temperature: 40},
+				{Index: 1, Utilization: 1, MemoryUsed: 200, Temperature: 45},
+			},
+		})
+	}
+
+	w := s.GetGPUWindow("gpu-node-1", time.Hour)
+	if w == nil {
+		t.Fatal("expected a GPU window")
+	}
+	if w.DataPoints != 20 || w.Devices != 2 {
+		t.Errorf("DataPoints/Devices = %d/%d, want 20/2", w.DataPoints, w.Devices)
+	}
+	if w.MaxUtilization != 90 {
+		t.Errorf("MaxUtilization = %.1f, want 90", w.MaxUtilization)
+	}
+	if w.P50Utilization != 2 {
+		t.Errorf("P50Utilization = %.1f, want 2", w.P50Utilization)
+	}
+	if w.MaxMemoryUsed != 200 || w.MaxTemperature != 45 {
+		t.Errorf("MaxMemoryUsed/MaxTemperature = %d/%.0f, want 200/45", w.MaxMemoryUsed, w.MaxTemperature)
+	}
+
+	if s.GetGPUWindow("unknown", time.Hour) != nil {
+		t.Error("expected nil window for unknown node")
+	}
+}
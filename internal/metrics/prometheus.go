@@ -0,0 +1,568 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+)
+
+// WindowQuerier computes usage windows from an external history source.
+type WindowQuerier interface {
+	ContainerWindow(ctx context.Context, namespace, pod, container string, duration time.Duration) (*pkgmetrics.MetricsWindow, error)
+}
+
+const (
+	// containerFilter drops cAdvisor's pod-level and pause-container series.
+	containerFilter = `container!="",container!="POD"`
+	promRateWindow  = "5m"
+
+	// Prometheus rejects query_range results above 11,000 points per series.
+	promMaxPoints = 10000
+
+	backfillChunk  = 6 * time.Hour
+	windowCacheTTL = 15 * time.Minute
+	maxCachedWins  = 10000
+)
+
+var (
+	nodeLabels = []string{"node"}
+	podLabels  = []string{"namespace", "pod", "container"}
+)
+
+// PrometheusCollector implements MetricsCollector against a Prometheus-
+// compatible HTTP API (Prometheus, Thanos, Mimir, VictoriaMetrics). Usage is
+// read from the cAdvisor series metrics-server itself reports:
+// container_cpu_usage_seconds_total and container_memory_working_set_bytes.
+// Node usage is the root cgroup (id="/") grouped by the "node" label, so the
+// kubelet scrape config must attach that label, as kube-prometheus does.
+//
+// Besides live metrics it can backfill the metrics Store from query_range and
+// compute container windows directly, so percentile analysis has history
+// from the first reconcile.
+type PrometheusCollector struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	step       time.Duration
+	fallback   pkgmetrics.MetricsCollector
+
+	mu    sync.Mutex
+	cache map[string]cachedWindow
+}
+
+type cachedWindow struct {
+	window  *pkgmetrics.MetricsWindow
+	expires time.Time
+}
+
+// PromSeries is one labelled series from a query result. Instant queries
+// yield a single point per series.
+type PromSeries struct {
+	Labels map[string]string
+	Points []PromPoint
+}
+
+// PromPoint is a sample in the API's [<unix seconds>, "<value>"] encoding.
+type PromPoint struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+func (p *PromPoint) UnmarshalJSON(b []byte) error {
+	var raw [2]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("decoding sample: %w", err)
+	}
+	var ts float64
+	if err := json.Unmarshal(raw[0], &ts); err != nil {
+		return fmt.Errorf("decoding sample timestamp: %w", err)
+	}
+	var vs string
+	if err := json.Unmarshal(raw[1], &vs); err != nil {
+		return fmt.Errorf("decoding sample value: %w", err)
+	}
+	v, err := strconv.ParseFloat(vs, 64)
+	if err != nil {
+		return fmt.Errorf("parsing sample value %q: %w", vs, err)
+	}
+	p.Timestamp = time.UnixMilli(int64(math.Round(ts * 1000)))
+	p.Value = v
+	return nil
+}
+
+type promResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// NewPrometheusCollector creates a collector for cfg.PrometheusURL. GPU
+// metrics are delegated to fallback, which may be nil.
+func NewPrometheusCollector(cfg config.MetricsConfig, fallback pkgmetrics.MetricsCollector) (*PrometheusCollector, error) {
+	u, err := url.Parse(cfg.PrometheusURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid prometheus URL %q", cfg.PrometheusURL)
+	}
+	timeout := cfg.QueryTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	step := cfg.Step
+	if step <= 0 {
+		step = 60 * time.Second
+	}
+	return &PrometheusCollector{
+		baseURL:    strings.TrimRight(cfg.PrometheusURL, "/"),
+		token:      cfg.PrometheusToken,
+		httpClient: &http.Client{Timeout: timeout},
+		step:       step,
+		fallback:   fallback,
+		cache:      make(map[string]cachedWindow),
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// HTTP API
+// ---------------------------------------------------------------------------
+
+// Query runs an instant query. A zero ts evaluates at the server's time.
+func (p *PrometheusCollector) Query(ctx context.Context, query string, ts time.Time) ([]PromSeries, error) {
+	params := url.Values{"query": {query}}
+	if !ts.IsZero() {
+		params.Set("time", formatPromTime(ts))
+	}
+	return p.do(ctx, "/api/v1/query", params)
+}
+
+// QueryRange runs a range query over [start, end] at the given resolution.
+func (p *PrometheusCollector) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]PromSeries, error) {
+	if step <= 0 {
+		step = p.step
+	}
+	if points := end.Sub(start) / step; points > promMaxPoints {
+		return nil, fmt.Errorf("query_range over %s at %s resolution exceeds %d points per series", end.Sub(start), step, promMaxPoints)
+	}
+	params := url.Values{
+		"query": {query},
+		"start": {formatPromTime(start)},
+		"end":   {formatPromTime(end)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+	return p.do(ctx, "/api/v1/query_range", params)
+}
+
+func (p *PrometheusCollector) do(ctx context.Context, path string, params url.Values) ([]PromSeries, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("building prometheus request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("querying prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading prometheus response: %w", err)
+	}
+
+	// Prometheus reports query errors as JSON with a non-2xx status, so
+	// prefer its message over the bare status code.
+	var pr promResponse
+	if err := json.Unmarshal(body, &pr); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("querying prometheus: HTTP %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("decoding prometheus response: %w", err)
+	}
+	if pr.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed (%s): %s", pr.ErrorType, pr.Error)
+	}
+
+	switch pr.Data.ResultType {
+	case "vector":
+		var items []struct {
+			Metric map[string]string `json:"metric"`
+			Value  PromPoint         `json:"value"`
+		}
+		if err := json.Unmarshal(pr.Data.Result, &items); err != nil {
+			return nil, fmt.Errorf("decoding vector result: %w", err)
+		}
+		series := make([]PromSeries, 0, len(items))
+		for _, it := range items {
+			series = append(series, PromSeries{Labels: it.Metric, Points: []PromPoint{it.Value}})
+		}
+		return series, nil
+	case "matrix":
+		var items []struct {
+			Metric map[string]string `json:"metric"`
+			Values []PromPoint       `json:"values"`
+		}
+		if err := json.Unmarshal(pr.Data.Result, &items); err != nil {
+			return nil, fmt.Errorf("decoding matrix result: %w", err)
+		}
+		series := make([]PromSeries, 0, len(items))
+		for _, it := range items {
+			series = append(series, PromSeries{Labels: it.Metric, Points: it.Values})
+		}
+		return series, nil
+	default:
+		return nil, fmt.Errorf("unsupported prometheus result type %q", pr.Data.ResultType)
+	}
+}
+
+func formatPromTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
+// ---------------------------------------------------------------------------
+// Queries
+// ---------------------------------------------------------------------------
+
+func podCPUQuery(sel string) string {
+	return fmt.Sprintf(`sum by (namespace, pod, container) (rate(container_cpu_usage_seconds_total{%s}[%s]))`, sel, promRateWindow)
+}
+
+func podMemQuery(sel string) string {
+	return fmt.Sprintf(`sum by (namespace, pod, container) (container_memory_working_set_bytes{%s})`, sel)
+}
+
+func nodeCPUQuery(sel string) string {
+	return fmt.Sprintf(`sum by (node) (rate(container_cpu_usage_seconds_total{%s}[%s]))`, sel, promRateWindow)
+}
+
+func nodeMemQuery(sel string) string {
+	return fmt.Sprintf(`sum by (node) (container_memory_working_set_bytes{%s})`, sel)
+}
+
+// selector joins label matchers; values are quoted as PromQL strings.
+func selector(base string, kv ...string) string {
+	parts := []string{base}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			parts = append(parts, kv[i]+"="+strconv.Quote(kv[i+1]))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func seriesKey(lbls map[string]string, keys []string) string {
+	vals := make([]string, len(keys))
+	for i, k := range keys {
+		vals[i] = lbls[k]
+	}
+	return strings.Join(vals, "/")
+}
+
+// usage is a joined CPU/memory observation keyed by series.
+type usage struct {
+	ts       time.Time
+	cpu, mem float64
+	has      uint8 // bit 0: cpu, bit 1: mem
+}
+
+// joinUsage merges CPU (cores) and memory (bytes) series into samples per
+// key. Only timestamps present in both are kept so a missing side does not
+// skew percentiles towards zero.
+func joinUsage(cpu, mem []PromSeries, keys []string) map[string][]pkgmetrics.UsageSample {
+	byKey := make(map[string]map[int64]*usage)
+	add := func(series []PromSeries, bit uint8) {
+		for _, s := range series {
+			key := seriesKey(s.Labels, keys)
+			m, ok := byKey[key]
+			if !ok {
+				m = make(map[int64]*usage)
+				byKey[key] = m
+			}
+			for _, pt := range s.Points {
+				if math.IsNaN(pt.Value) || math.IsInf(pt.Value, 0) {
+					continue
+				}
+				ms := pt.Timestamp.UnixMilli()
+				u, ok := m[ms]
+				if !ok {
+					u = &usage{ts: pt.Timestamp}
+					m[ms] = u
+				}
+				if bit == 1 {
+					u.cpu = pt.Value
+				} else {
+					u.mem = pt.Value
+				}
+				u.has |= bit
+			}
+		}
+	}
+	add(cpu, 1)
+	add(mem, 2)
+
+	result := make(map[string][]pkgmetrics.UsageSample, len(byKey))
+	for key, m := range byKey {
+		samples := make([]pkgmetrics.UsageSample, 0, len(m))
+		for _, u := range m {
+			if u.has != 3 {
+				continue
+			}
+			samples = append(samples, pkgmetrics.UsageSample{
+				Timestamp:   u.ts,
+				CPUUsage:    int64(math.Round(u.cpu * 1000)),
+				MemoryUsage: int64(u.mem),
+			})
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+		result[key] = samples
+	}
+	return result
+}
+
+// usageInstant evaluates both queries at the same instant, so that their
+// samples share a timestamp and join.
+func (p *PrometheusCollector) usageInstant(ctx context.Context, cpuQuery, memQuery string, keys []string) (map[string][]pkgmetrics.UsageSample, error) {
+	ts := time.Now()
+	cpu, err := p.Query(ctx, cpuQuery, ts)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := p.Query(ctx, memQuery, ts)
+	if err != nil {
+		return nil, err
+	}
+	return joinUsage(cpu, mem, keys), nil
+}
+
+func (p *PrometheusCollector) usageRange(ctx context.Context, cpuQuery, memQuery string, keys []string, start, end time.Time, step time.Duration) (map[string][]pkgmetrics.UsageSample, error) {
+	cpu, err := p.QueryRange(ctx, cpuQuery, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := p.QueryRange(ctx, memQuery, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	return joinUsage(cpu, mem, keys), nil
+}
+
+// ---------------------------------------------------------------------------
+// MetricsCollector
+// ---------------------------------------------------------------------------
+
+func (p *PrometheusCollector) GetNodeMetrics(ctx context.Context) ([]pkgmetrics.NodeMetrics, error) {
+	return p.nodeMetrics(ctx, "")
+}
+
+func (p *PrometheusCollector) GetNodeMetricsByName(ctx context.Context, name string) (*pkgmetrics.NodeMetrics, error) {
+	result, err := p.nodeMetrics(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no prometheus metrics for node %s", name)
+	}
+	return &result[0], nil
+}
+
+func (p *PrometheusCollector) nodeMetrics(ctx context.Context, name string) ([]pkgmetrics.NodeMetrics, error) {
+	sel := selector(`id="/"`, "node", name)
+	byNode, err := p.usageInstant(ctx, nodeCPUQuery(sel), nodeMemQuery(sel), nodeLabels)
+	if err != nil {
+		return nil, fmt.Errorf("querying node metrics: %w", err)
+	}
+	result := make([]pkgmetrics.NodeMetrics, 0, len(byNode))
+	for node, samples := range byNode {
+		if node == "" {
+			continue
+		}
+		s := samples[len(samples)-1]
+		result = append(result, pkgmetrics.NodeMetrics{
+			Name:        node,
+			CPUUsage:    s.CPUUsage,
+			MemoryUsage: s.MemoryUsage,
+			Timestamp:   s.Timestamp,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (p *PrometheusCollector) GetPodMetrics(ctx context.Context, namespace string) ([]pkgmetrics.PodMetrics, error) {
+	return p.podMetrics(ctx, namespace, "")
+}
+
+func (p *PrometheusCollector) GetPodMetricsByName(ctx context.Context, namespace, name string) (*pkgmetrics.PodMetrics, error) {
+	result, err := p.podMetrics(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no prometheus metrics for pod %s/%s", namespace, name)
+	}
+	return &result[0], nil
+}
+
+func (p *PrometheusCollector) podMetrics(ctx context.Context, namespace, name string) ([]pkgmetrics.PodMetrics, error) {
+	sel := selector(containerFilter, "namespace", namespace, "pod", name)
+	byContainer, err := p.usageInstant(ctx, podCPUQuery(sel), podMemQuery(sel), podLabels)
+	if err != nil {
+		return nil, fmt.Errorf("querying pod metrics: %w", err)
+	}
+
+	pods := make(map[string]*pkgmetrics.PodMetrics)
+	for key, samples := range byContainer {
+		parts := strings.SplitN(key, "/", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		s := samples[len(samples)-1]
+		podKey := parts[0] + "/" + parts[1]
+		pm, ok := pods[podKey]
+		if !ok {
+			pm = &pkgmetrics.PodMetrics{Namespace: parts[0], Name: parts[1], Timestamp: s.Timestamp}
+			pods[podKey] = pm
+		}
+		pm.Containers = append(pm.Containers, pkgmetrics.ContainerMetrics{
+			Name:        parts[2],
+			CPUUsage:    s.CPUUsage,
+			MemoryUsage: s.MemoryUsage,
+		})
+	}
+
+	result := make([]pkgmetrics.PodMetrics, 0, len(pods))
+	for _, pm := range pods {
+		sort.Slice(pm.Containers, func(i, j int) bool { return pm.Containers[i].Name < pm.Containers[j].Name })
+		result = append(result, *pm)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (p *PrometheusCollector) GetGPUMetrics(ctx context.Context, nodeName string) (*pkgmetrics.GPUMetrics, error) {
+	if p.fallback == nil {
+		return nil, fmt.Errorf("no GPU metrics source for node %s", nodeName)
+	}
+	return p.fallback.GetGPUMetrics(ctx, nodeName)
+}
+
+// ---------------------------------------------------------------------------
+// History
+// ---------------------------------------------------------------------------
+
+// Backfill loads the last window of node and container usage into store,
+// oldest chunk first, at the collector's step. Points the store already has
+// are skipped, so it is safe to run on every start. It returns the number
+// of samples added; on error, samples from earlier chunks are kept.
+func (p *PrometheusCollector) Backfill(ctx context.Context, store *Store, window time.Duration) (int, error) {
+	chunk := min(backfillChunk, p.step*promMaxPoints)
+	tolerance := p.step / 2
+	end := time.Now().Truncate(p.step)
+	start := end.Add(-window)
+
+	added := 0
+	for chunkStart := start; chunkStart.Before(end); chunkStart = chunkStart.Add(chunk) {
+		// Chunks end one step short of the next chunk's start so that
+		// boundary samples are not fetched twice.
+		chunkEnd := chunkStart.Add(chunk - p.step)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+
+		nodes, err := p.usageRange(ctx, nodeCPUQuery(`id="/"`), nodeMemQuery(`id="/"`), nodeLabels, chunkStart, chunkEnd, p.step)
+		if err != nil {
+			return added, fmt.Errorf("backfilling node metrics: %w", err)
+		}
+		for node, samples := range nodes {
+			if node != "" {
+				added += store.ImportNodeSeries(node, samples, tolerance)
+			}
+		}
+
+		containers, err := p.usageRange(ctx, podCPUQuery(containerFilter), podMemQuery(containerFilter), podLabels, chunkStart, chunkEnd, p.step)
+		if err != nil {
+			return added, fmt.Errorf("backfilling pod metrics: %w", err)
+		}
+		for key, samples := range containers {
+			parts := strings.SplitN(key, "/", 3)
+			if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+				continue
+			}
+			added += store.ImportPodContainerSeries(parts[0], parts[1], parts[2], samples, tolerance)
+		}
+	}
+	return added, nil
+}
+
+// ContainerWindow computes a usage window for one container directly from
+// Prometheus. Long durations are queried at a coarser step to stay within
+// the per-series point limit. Results, including empty ones, are cached
+// briefly because callers ask for the same containers every cycle.
+func (p *PrometheusCollector) ContainerWindow(ctx context.Context, namespace, pod, container string, duration time.Duration) (*pkgmetrics.MetricsWindow, error) {
+	key := namespace + "/" + pod + "/" + container
+	cacheKey := key + "@" + duration.String()
+	now := time.Now()
+
+	p.mu.Lock()
+	if c, ok := p.cache[cacheKey]; ok && now.Before(c.expires) {
+		p.mu.Unlock()
+		return c.window, nil
+	}
+	p.mu.Unlock()
+
+	step := p.step
+	if minStep := (duration + promMaxPoints - 1) / promMaxPoints; step < minStep {
+		step = time.Duration(math.Ceil(minStep.Seconds())) * time.Second
+	}
+	sel := selector(containerFilter, "namespace", namespace, "pod", pod, "container", container)
+	byContainer, err := p.usageRange(ctx, podCPUQuery(sel), podMemQuery(sel), podLabels, now.Add(-duration), now, step)
+	if err != nil {
+		return nil, fmt.Errorf("querying window for %s: %w", key, err)
+	}
+
+	samples := byContainer[key]
+	points := make([]dataPoint, len(samples))
+	for i, s := range samples {
+		points[i] = dataPoint{Timestamp: s.Timestamp, CPUUsage: s.CPUUsage, MemoryUsage: s.MemoryUsage}
+	}
+	w := windowFromPoints(points)
+
+	p.mu.Lock()
+	if len(p.cache) >= maxCachedWins {
+		for k, c := range p.cache {
+			if now.After(c.expires) {
+				delete(p.cache, k)
+			}
+		}
+	}
+	if len(p.cache) < maxCachedWins {
+		p.cache[cacheKey] = cachedWindow{window: w, expires: now.Add(windowCacheTTL)}
+	}
+	p.mu.Unlock()
+	return w, nil
+}
@@ -71,6 +71,13 @@ var (
 		Buckets:   prometheus.DefBuckets,
 	})
 
+	// Workload scaling metrics
+	WorkloadForecastErrorPct = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "koptimizer",
+		Name:      "workload_forecast_error_pct",
+		Help:      "Mean absolute percentage error of the hourly CPU forecast over the last week of scored hours",
+	}, []string{"namespace", "kind", "name"})
+
 	// Family lock metrics
 	FamilyLockBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "koptimizer",
@@ -104,6 +111,25 @@ var (
 		Help:      "Total nodes consolidated (drained)",
 	})
 
+	// Rebalancer metrics
+	RebalanceImbalance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "koptimizer",
+		Name:      "rebalance_imbalance_ratio",
+		Help:      "Fraction of a node group's nodes that tighter packing would free",
+	}, []string{"node_group"})
+
+	RebalanceProjectedSavingsUSD = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "koptimizer",
+		Name:      "rebalance_projected_savings_monthly_usd",
+		Help:      "Projected monthly savings of the last rebalance plan",
+	})
+
+	RebalanceAchievedSavingsUSD = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "koptimizer",
+		Name:      "rebalance_achieved_savings_monthly_usd",
+		Help:      "Monthly savings achieved by the last rebalance run",
+	})
+
 	// GPU metrics
 	GPUNodesIdle = promauto.NewGauge(prometheus.GaugeOpts{
 		Namespace: "koptimizer",
@@ -0,0 +1,309 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// promStub is a minimal Prometheus HTTP API. Node queries (id="/") report
+// node-1 at 2 cores / 4GiB; container queries report default/web-0/app at
+// 0.25 cores / 256MiB. Instant queries without a time are evaluated, as by
+// Prometheus, at a millisecond of their own. Range queries return one point
+// per step.
+type promStub struct {
+	mu       sync.Mutex
+	queries  []string
+	instants int
+	ranges   int
+	auth     string
+	failWith int
+}
+
+func (s *promStub) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		query := r.Form.Get("query")
+		s.mu.Lock()
+		s.queries = append(s.queries, query)
+		s.auth = r.Header.Get("Authorization")
+		fail := s.failWith
+		s.mu.Unlock()
+
+		if fail != 0 {
+			w.WriteHeader(fail)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+			return
+		}
+
+		isCPU := strings.Contains(query, "container_cpu_usage_seconds_total")
+		var metric map[string]string
+		var value float64
+		if strings.Contains(query, `id="/"`) {
+			metric = map[string]string{"node": "node-1"}
+			value = 4 * 1024 * 1024 * 1024
+			if isCPU {
+				value = 2
+			}
+		} else {
+			metric = map[string]string{"namespace": "default", "pod": "web-0", "container": "app"}
+			value = 256 * 1024 * 1024
+			if isCPU {
+				value = 0.25
+			}
+		}
+
+		var data any
+		switch r.URL.Path {
+		case "/api/v1/query":
+			s.mu.Lock()
+			s.instants++
+			n := s.instants
+			s.mu.Unlock()
+			ts, err := strconv.ParseFloat(r.Form.Get("time"), 64)
+			if err != nil {
+				ts = float64(time.Now().UnixMilli()+int64(n)) / 1000
+			}
+			data = map[string]any{
+				"resultType": "vector",
+				"result": []any{map[string]any{
+					"metric": metric,
+					"value":  []any{ts, strconv.FormatFloat(value, 'f', -1, 64)},
+				}},
+			}
+		case "/api/v1/query_range":
+			s.mu.Lock()
+			s.ranges++
+			s.mu.Unlock()
+			start, _ := strconv.ParseFloat(r.Form.Get("start"), 64)
+			end, _ := strconv.ParseFloat(r.Form.Get("end"), 64)
+			step, _ := strconv.ParseFloat(r.Form.Get("step"), 64)
+			var values []any
+			for ts := start; ts <= end; ts += step {
+				values = append(values, []any{ts, strconv.FormatFloat(value, 'f', -1, 64)})
+			}
+			data = map[string]any{
+				"resultType": "matrix",
+				"result":     []any{map[string]any{"metric": metric, "values": values}},
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": data})
+	}
+}
+
+func newPromStub(t *testing.T) (*promStub, *PrometheusCollector) {
+	t.Helper()
+	stub := &promStub{}
+	srv := httptest.NewServer(stub.handler(t))
+	t.Cleanup(srv.Close)
+
+	pc, err := NewPrometheusCollector(config.MetricsConfig{
+		PrometheusURL:   srv.URL + "/",
+		PrometheusToken: "secret",
+		Step:            time.Minute,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewPrometheusCollector: %v", err)
+	}
+	return stub, pc
+}
+
+// ---------------------------------------------------------------------------
+// Collector Tests
+// ---------------------------------------------------------------------------
+
+func TestNewPrometheusCollector_InvalidURL(t *testing.T) {
+	for _, u := range []string{"", "prometheus:9090", "://bad"} {
+		if _, err := NewPrometheusCollector(config.MetricsConfig{PrometheusURL: u}, nil); err == nil {
+			t.Errorf("expected error for URL %q", u)
+		}
+	}
+}
+
+func TestPrometheusCollector_LiveMetrics(t *testing.T) {
+	stub, pc := newPromStub(t)
+	ctx := context.Background()
+
+	nodes, err := pc.GetNodeMetrics(ctx)
+	if err != nil {
+		t.Fatalf("GetNodeMetrics: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "node-1" || nodes[0].CPUUsage != 2000 || nodes[0].MemoryUsage != 4*1024*1024*1024 {
+		t.Errorf("unexpected node metrics: %+v", nodes)
+	}
+
+	pods, err := pc.GetPodMetrics(ctx, "default")
+	if err != nil {
+		t.Fatalf("GetPodMetrics: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "web-0" || len(pods[0].Containers) != 1 {
+		t.Fatalf("unexpected pod metrics: %+v", pods)
+	}
+	if c := pods[0].Containers[0]; c.Name != "app" || c.CPUUsage != 250 || c.MemoryUsage != 256*1024*1024 {
+		t.Errorf("unexpected container metrics: %+v", c)
+	}
+
+	if stub.auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want bearer token", stub.auth)
+	}
+	last := stub.queries[len(stub.queries)-1]
+	if !strings.Contains(last, `namespace="default"`) {
+		t.Errorf("namespace matcher missing from query %q", last)
+	}
+}
+
+func TestPrometheusCollector_QueryError(t *testing.T) {
+	stub, pc := newPromStub(t)
+	stub.failWith = http.StatusBadRequest
+
+	_, err := pc.GetNodeMetrics(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "parse error") {
+		t.Errorf("expected Prometheus error message, got %v", err)
+	}
+}
+
+func TestPrometheusCollector_QueryRangeLimit(t *testing.T) {
+	_, pc := newPromStub(t)
+	end := time.Now()
+	_, err := pc.QueryRange(context.Background(), "up", end.Add(-30*24*time.Hour), end, time.Minute)
+	if err == nil {
+		t.Error("expected error for query_range above the point limit")
+	}
+}
+
+func TestPrometheusCollector_GPUFallback(t *testing.T) {
+	_, pc := newPromStub(t)
+	if _, err := pc.GetGPUMetrics(context.Background(), "node-1"); err == nil {
+		t.Error("expected error without a fallback collector")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// History Tests
+// ---------------------------------------------------------------------------
+
+func TestPrometheusCollector_Backfill(t *testing.T) {
+	stub, pc := newPromStub(t)
+	store := NewStore(7 * 24 * time.Hour)
+
+	added, err := pc.Backfill(context.Background(), store, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	// 24h at 1m is 1440 points for each of one node and one container.
+	if added < 2*1439 || added > 2*1441 {
+		t.Errorf("added = %d, want ~%d", added, 2*1440)
+	}
+	// Four 6h chunks, two range queries (CPU, memory) for nodes and pods each.
+	if stub.ranges != 16 {
+		t.Errorf("range queries = %d, want 16", stub.ranges)
+	}
+
+	w := store.GetPodContainerWindow("default", "web-0", "app", 24*time.Hour)
+	if w == nil {
+		t.Fatal("expected backfilled container window")
+	}
+	if w.DataPoints < 1439 || w.P95CPU != 250 || w.MaxMemory != 256*1024*1024 {
+		t.Errorf("unexpected window: %+v", w)
+	}
+	if nw := store.GetNodeWindow("node-1", 24*time.Hour); nw == nil || nw.P50CPU != 2000 {
+		t.Errorf("unexpected node window: %+v", nw)
+	}
+
+	// A second backfill finds every point already present.
+	again, err := pc.Backfill(context.Background(), store, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("second Backfill: %v", err)
+	}
+	if again != 0 {
+		t.Errorf("second backfill added %d samples, want 0", again)
+	}
+}
+
+func TestPrometheusCollector_ContainerWindow(t *testing.T) {
+	stub, pc := newPromStub(t)
+	ctx := context.Background()
+
+	// Two weeks at 1m would exceed the point limit; the step is widened.
+	w, err := pc.ContainerWindow(ctx, "default", "web-0", "app", 14*24*time.Hour)
+	if err != nil {
+		t.Fatalf("ContainerWindow: %v", err)
+	}
+	if w == nil || w.P99CPU != 250 || w.P95Memory != 256*1024*1024 {
+		t.Fatalf("unexpected window: %+v", w)
+	}
+	if w.DataPoints > promMaxPoints+1 {
+		t.Errorf("DataPoints = %d, exceeds point limit", w.DataPoints)
+	}
+
+	// Repeated lookups are served from cache.
+	before := stub.ranges
+	if _, err := pc.ContainerWindow(ctx, "default", "web-0", "app", 14*24*time.Hour); err != nil {
+		t.Fatalf("cached ContainerWindow: %v", err)
+	}
+	if stub.ranges != before {
+		t.Errorf("expected cached window, got %d new range queries", stub.ranges-before)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Store Import Tests
+// ---------------------------------------------------------------------------
+
+func TestStore_ImportPodContainerSeries(t *testing.T) {
+	s := NewStore(24 * time.Hour)
+	now := time.Now().Truncate(time.Minute)
+
+	// Live sample already recorded at now-10m.
+	s.RecordPodMetrics(pkgmetrics.PodMetrics{
+		Namespace: "default",
+		Name:      "web-0",
+		Timestamp: now.Add(-10 * time.Minute),
+		Containers: []pkgmetrics.ContainerMetrics{
+			{Name: "app", CPUUsage: 900, MemoryUsage: 1},
+		},
+	})
+
+	var samples []pkgmetrics.UsageSample
+	// One sample outside retention, then one per minute for the last 20m.
+	samples = append(samples, pkgmetrics.UsageSample{Timestamp: now.Add(-48 * time.Hour), CPUUsage: 1})
+	for i := 20; i > 0; i-- {
+		samples = append(samples, pkgmetrics.UsageSample{
+			Timestamp:   now.Add(-time.Duration(i)*time.Minute + 5*time.Second),
+			CPUUsage:    100,
+			MemoryUsage: 1,
+		})
+	}
+
+	added := s.ImportPodContainerSeries("default", "web-0", "app", samples, 30*time.Second)
+	// 20 in-retention samples, minus the one within 30s of the live point.
+	if added != 19 {
+		t.Errorf("added = %d, want 19", added)
+	}
+	w := s.GetPodContainerWindow("default", "web-0", "app", time.Hour)
+	if w.DataPoints != 20 || w.MaxCPU != 900 {
+		t.Errorf("DataPoints/MaxCPU = %d/%d, want 20/900", w.DataPoints, w.MaxCPU)
+	}
+	if !w.Start.Before(w.End) {
+		t.Errorf("merged series not time-ordered: start %s end %s", w.Start, w.End)
+	}
+}
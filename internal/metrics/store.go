@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"log/slog"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,11 +20,17 @@ const maxPodSeriesKeys = 100_000
 // persistence.
 type Store struct {
 	mu         sync.RWMutex
-	nodeSeries map[string][]dataPoint // nodeName -> time series
-	podSeries  map[string][]dataPoint // namespace/name/container -> time series
+	nodeSeries map[string][]dataPoint    // nodeName -> time series
+	podSeries  map[string][]dataPoint    // namespace/name/container -> time series
+	gpuSeries  map[string][]gpuDataPoint // nodeName -> per-device samples
 	retention  time.Duration
 	db         *sql.DB
 	writer     *store.Writer
+
+	// CFS throttling ratios per namespace/name/container, derived from the
+	// cumulative counters in throttleLast.
+	throttleSeries map[string][]throttlePoint
+	throttleLast   map[string]pkgmetrics.CFSThrottling
 }
 
 type dataPoint struct {
@@ -32,12 +39,30 @@ type dataPoint struct {
 	MemoryUsage int64
 }
 
+type throttlePoint struct {
+	Timestamp time.Time
+	Ratio     float64 // throttled periods / periods over the interval, 0-1
+}
+
+type gpuDataPoint struct {
+	Timestamp   time.Time
+	Index       int
+	Utilization float64
+	MemoryUsed  int64
+	MemoryTotal int64
+	Temperature float64
+}
+
 // NewStore creates a new metrics Store (in-memory only).
 func NewStore(retention time.Duration) *Store {
 	return &Store{
 		nodeSeries: make(map[string][]dataPoint),
 		podSeries:  make(map[string][]dataPoint),
+		gpuSeries:  make(map[string][]gpuDataPoint),
 		retention:  retention,
+
+		throttleSeries: make(map[string][]throttlePoint),
+		throttleLast:   make(map[string]pkgmetrics.CFSThrottling),
 	}
 }
 
@@ -48,9 +73,13 @@ func NewStoreWithDB(retention time.Duration, db *sql.DB, writer *store.Writer) *
 	s := &Store{
 		nodeSeries: make(map[string][]dataPoint),
 		podSeries:  make(map[string][]dataPoint),
+		gpuSeries:  make(map[string][]gpuDataPoint),
 		retention:  retention,
 		db:         db,
 		writer:     writer,
+
+		throttleSeries: make(map[string][]throttlePoint),
+		throttleLast:   make(map[string]pkgmetrics.CFSThrottling),
 	}
 	if db != nil {
 		s.loadFromDB()
@@ -67,6 +96,12 @@ func (s *Store) loadFromDB() {
 
 	// Load pod metrics
 	s.loadPodMetrics(cutoff)
+
+	// Load GPU metrics
+	s.loadGPUMetrics(cutoff)
+
+	// Load CPU throttling ratios
+	s.loadThrottleMetrics(cutoff)
 }
 
 func (s *Store) loadNodeMetrics(cutoff int64) {
@@ -114,6 +149,51 @@ func (s *Store) loadPodMetrics(cutoff int64) {
 	}
 }
 
+func (s *Store) loadGPUMetrics(cutoff int64) {
+	rows, err := s.db.Query(
+		"SELECT timestamp, node_name, gpu_index, utilization, memory_used, memory_total, temperature FROM gpu_metrics WHERE timestamp >= ? ORDER BY timestamp ASC",
+		cutoff,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tsUnix int64
+		var name string
+		var dp gpuDataPoint
+		if err := rows.Scan(&tsUnix, &name, &dp.Index, &dp.Utilization, &dp.MemoryUsed, &dp.MemoryTotal, &dp.Temperature); err != nil {
+			slog.Warn("metrics: scan gpu_metrics row", "error", err)
+			continue
+		}
+		dp.Timestamp = time.Unix(tsUnix, 0)
+		s.gpuSeries[name] = append(s.gpuSeries[name], dp)
+	}
+}
+
+func (s *Store) loadThrottleMetrics(cutoff int64) {
+	rows, err := s.db.Query(
+		"SELECT timestamp, namespace, pod_name, container, ratio FROM throttle_metrics WHERE timestamp >= ? ORDER BY timestamp ASC",
+		cutoff,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tsUnix int64
+		var ns, pod, container string
+		var tp throttlePoint
+		if err := rows.Scan(&tsUnix, &ns, &pod, &container, &tp.Ratio); err != nil {
+			slog.Warn("metrics: scan throttle_metrics row", "error", err)
+			continue
+		}
+		tp.Timestamp = time.Unix(tsUnix, 0)
+		key := ns + "/" + pod + "/" + container
+		s.throttleSeries[key] = append(s.throttleSeries[key], tp)
+	}
+}
+
 // RecordNodeMetrics stores a node metrics data point.
 func (s *Store) RecordNodeMetrics(m pkgmetrics.NodeMetrics) {
 	s.mu.Lock()
@@ -196,6 +276,267 @@ func (s *Store) RecordPodMetrics(m pkgmetrics.PodMetrics) {
 	}
 }
 
+// RecordGPUMetrics stores one sample per GPU device on the node.
+func (s *Store) RecordGPUMetrics(m pkgmetrics.GPUMetrics) {
+	if len(m.GPUs) == 0 {
+		return
+	}
+	ts := m.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	points := make([]gpuDataPoint, 0, len(m.GPUs))
+	for _, g := range m.GPUs {
+		points = append(points, gpuDataPoint{
+			Timestamp:   ts,
+			Index:       g.Index,
+			Utilization: g.Utilization,
+			MemoryUsed:  g.MemoryUsed,
+			MemoryTotal: g.MemoryTotal,
+			Temperature: g.Temperature,
+		})
+	}
+	s.gpuSeries[m.NodeName] = append(s.gpuSeries[m.NodeName], points...)
+	s.evictGPU(m.NodeName)
+
+	if s.writer != nil {
+		name, tsUnix := m.NodeName, ts.Unix()
+		s.writer.Enqueue(func(db *sql.DB) {
+			for _, p := range points {
+				if _, err := db.Exec(
+					"INSERT INTO gpu_metrics (timestamp, node_name, gpu_index, utilization, memory_used, memory_total, temperature) VALUES (?, ?, ?, ?, ?, ?, ?)",
+					tsUnix, name, p.Index, p.Utilization, p.MemoryUsed, p.MemoryTotal, p.Temperature,
+				); err != nil {
+					slog.Error("metrics: insert gpu_metrics", "node", name, "error", err)
+				}
+			}
+		})
+	}
+}
+
+// RecordThrottling stores the CFS throttling ratio of each container since
+// its previous sample. The first sample of a container only primes the
+// counters; a counter that went backwards (container restart) or an interval
+// without CFS periods (idle container) records nothing.
+func (s *Store) RecordThrottling(samples []pkgmetrics.CFSThrottling) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type throttleRow struct {
+		ts             int64
+		ns, pod, cname string
+		ratio          float64
+	}
+	var rows []throttleRow
+
+	for _, c := range samples {
+		key := c.Namespace + "/" + c.Pod + "/" + c.Container
+		prev, ok := s.throttleLast[key]
+		s.throttleLast[key] = c
+		if !ok {
+			continue
+		}
+		periods := c.Periods - prev.Periods
+		throttled := c.ThrottledPeriods - prev.ThrottledPeriods
+		if periods <= 0 || throttled < 0 {
+			continue
+		}
+		ratio := min(throttled/periods, 1)
+		s.throttleSeries[key] = append(s.throttleSeries[key], throttlePoint{Timestamp: c.Timestamp, Ratio: ratio})
+		s.evictThrottle(key)
+
+		if s.writer != nil {
+			rows = append(rows, throttleRow{ts: c.Timestamp.Unix(), ns: c.Namespace, pod: c.Pod, cname: c.Container, ratio: ratio})
+		}
+	}
+
+	if s.writer != nil && len(rows) > 0 {
+		s.writer.Enqueue(func(db *sql.DB) {
+			for _, r := range rows {
+				if _, err := db.Exec(
+					"INSERT INTO throttle_metrics (timestamp, namespace, pod_name, container, ratio) VALUES (?, ?, ?, ?, ?)",
+					r.ts, r.ns, r.pod, r.cname, r.ratio,
+				); err != nil {
+					slog.Error("metrics: insert throttle_metrics", "pod", r.ns+"/"+r.pod, "error", err)
+				}
+			}
+		})
+	}
+}
+
+// ImportNodeSeries merges historical samples for a node into the store and
+// persists the ones it keeps. Samples outside the retention window, or within
+// tolerance of an existing point, are skipped so that repeated backfills do
+// not double-count. It returns the number of samples added.
+func (s *Store) ImportNodeSeries(name string, samples []pkgmetrics.UsageSample, tolerance time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged, added := s.mergeSeries(s.nodeSeries[name], samples, tolerance)
+	if len(added) == 0 {
+		return 0
+	}
+	s.nodeSeries[name] = merged
+
+	if s.writer != nil {
+		s.writer.Enqueue(func(db *sql.DB) {
+			for _, p := range added {
+				if _, err := db.Exec(
+					"INSERT INTO node_metrics (timestamp, node_name, cpu_usage, memory_usage) VALUES (?, ?, ?, ?)",
+					p.Timestamp.Unix(), name, p.CPUUsage, p.MemoryUsage,
+				); err != nil {
+					slog.Error("metrics: insert node_metrics", "node", name, "error", err)
+					return
+				}
+			}
+		})
+	}
+	return len(added)
+}
+
+// ImportPodContainerSeries merges historical samples for a pod container.
+// See ImportNodeSeries.
+func (s *Store) ImportPodContainerSeries(namespace, pod, container string, samples []pkgmetrics.UsageSample, tolerance time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := namespace + "/" + pod + "/" + container
+	merged, added := s.mergeSeries(s.podSeries[key], samples, tolerance)
+	if len(added) == 0 {
+		return 0
+	}
+	s.podSeries[key] = merged
+	if len(s.podSeries) > int(float64(maxPodSeriesKeys)*1.2) {
+		s.cleanupLocked()
+	}
+
+	if s.writer != nil {
+		s.writer.Enqueue(func(db *sql.DB) {
+			for _, p := range added {
+				if _, err := db.Exec(
+					"INSERT INTO pod_metrics (timestamp, namespace, pod_name, container, cpu_usage, memory_usage) VALUES (?, ?, ?, ?, ?, ?)",
+					p.Timestamp.Unix(), namespace, pod, container, p.CPUUsage, p.MemoryUsage,
+				); err != nil {
+					slog.Error("metrics: insert pod_metrics", "pod", namespace+"/"+pod, "error", err)
+					return
+				}
+			}
+		})
+	}
+	return len(added)
+}
+
+// mergeSeries returns existing plus the samples that fall inside retention
+// and are not within tolerance of an existing point, sorted by time, along
+// with the points that were added.
+func (s *Store) mergeSeries(existing []dataPoint, samples []pkgmetrics.UsageSample, tolerance time.Duration) ([]dataPoint, []dataPoint) {
+	cutoff := time.Now().Add(-s.retention)
+	var added []dataPoint
+	for _, smp := range samples {
+		if smp.Timestamp.Before(cutoff) {
+			continue
+		}
+		// existing is time-ordered: only the neighbours of the insertion
+		// point can be within tolerance.
+		i := sort.Search(len(existing), func(i int) bool {
+			return !existing[i].Timestamp.Before(smp.Timestamp)
+		})
+		if i < len(existing) && existing[i].Timestamp.Sub(smp.Timestamp) < tolerance {
+			continue
+		}
+		if i > 0 && smp.Timestamp.Sub(existing[i-1].Timestamp) < tolerance {
+			continue
+		}
+		added = append(added, dataPoint{
+			Timestamp:   smp.Timestamp,
+			CPUUsage:    smp.CPUUsage,
+			MemoryUsage: smp.MemoryUsage,
+		})
+	}
+	if len(added) == 0 {
+		return existing, nil
+	}
+
+	merged := make([]dataPoint, 0, len(existing)+len(added))
+	merged = append(merged, existing...)
+	merged = append(merged, added...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Timestamp.Before(merged[j].Timestamp) })
+	return merged, added
+}
+
+// GetGPUWindow returns GPU utilization percentiles for a node over the given
+// duration, computed across all device samples.
+func (s *Store) GetGPUWindow(name string, duration time.Duration) *pkgmetrics.GPUWindow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cutoff := time.Now().Add(-duration)
+	var filtered []gpuDataPoint
+	for _, p := range s.gpuSeries[name] {
+		if p.Timestamp.After(cutoff) {
+			filtered = append(filtered, p)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+
+	utils := make([]float64, len(filtered))
+	devices := make(map[int]bool)
+	w := &pkgmetrics.GPUWindow{
+		Start:      filtered[0].Timestamp,
+		End:        filtered[len(filtered)-1].Timestamp,
+		DataPoints: len(filtered),
+	}
+	for i, p := range filtered {
+		utils[i] = p.Utilization
+		devices[p.Index] = true
+		if p.MemoryUsed > w.MaxMemoryUsed {
+			w.MaxMemoryUsed = p.MemoryUsed
+		}
+		if p.Temperature > w.MaxTemperature {
+			w.MaxTemperature = p.Temperature
+		}
+	}
+	w.Devices = len(devices)
+	w.P50Utilization = percentileFloat(utils, 50)
+	w.P95Utilization = percentileFloat(utils, 95)
+	w.MaxUtilization = percentileFloat(utils, 100)
+	return w
+}
+
+// GetThrottlingWindow returns the CFS throttling summary for a pod container
+// over the given duration, or nil if it has no samples (e.g. no CPU limit).
+func (s *Store) GetThrottlingWindow(namespace, pod, container string, duration time.Duration) *pkgmetrics.ThrottlingWindow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cutoff := time.Now().Add(-duration)
+	var ratios []float64
+	w := &pkgmetrics.ThrottlingWindow{}
+	var sum float64
+	for _, p := range s.throttleSeries[namespace+"/"+pod+"/"+container] {
+		if !p.Timestamp.After(cutoff) {
+			continue
+		}
+		if len(ratios) == 0 {
+			w.Start = p.Timestamp
+		}
+		w.End = p.Timestamp
+		ratios = append(ratios, p.Ratio)
+		sum += p.Ratio
+	}
+	if len(ratios) == 0 {
+		return nil
+	}
+	w.DataPoints = len(ratios)
+	w.AvgRatio = sum / float64(len(ratios))
+	w.P95Ratio = percentileFloat(ratios, 95)
+	w.MaxRatio = percentileFloat(ratios, 100)
+	return w
+}
+
 // GetNodeWindow returns the metrics window for a node over the given duration.
 func (s *Store) GetNodeWindow(name string, duration time.Duration) *pkgmetrics.MetricsWindow {
 	s.mu.RLock()
@@ -211,6 +552,123 @@ func (s *Store) GetPodContainerWindow(namespace, pod, container string, duration
 	return s.computeWindow(s.podSeries[key], duration)
 }
 
+// GetPeakContainerWindow summarizes a container across every pod in
+// namespace whose name satisfies match, treating each pod as one run of a
+// batch workload. A short-lived pod never accumulates enough samples on its
+// own, so each run contributes its peak CPU and memory and the percentiles
+// are taken over those peaks. DataPoints counts all samples and Runs the
+// number of pods. Returns nil if no pod matched.
+func (s *Store) GetPeakContainerWindow(namespace, container string, match func(pod string) bool, duration time.Duration) *pkgmetrics.MetricsWindow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cutoff := time.Now().Add(-duration)
+	var cpuPeaks, memPeaks []int64
+	var start, end time.Time
+	samples := 0
+	for key, points := range s.podSeries {
+		parts := strings.SplitN(key, "/", 3)
+		if len(parts) != 3 || parts[0] != namespace || parts[2] != container || !match(parts[1]) {
+			continue
+		}
+		var cpuPeak, memPeak int64
+		n := 0
+		for _, p := range points {
+			if !p.Timestamp.After(cutoff) {
+				continue
+			}
+			n++
+			cpuPeak = max(cpuPeak, p.CPUUsage)
+			memPeak = max(memPeak, p.MemoryUsage)
+			if start.IsZero() || p.Timestamp.Before(start) {
+				start = p.Timestamp
+			}
+			if p.Timestamp.After(end) {
+				end = p.Timestamp
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		samples += n
+		cpuPeaks = append(cpuPeaks, cpuPeak)
+		memPeaks = append(memPeaks, memPeak)
+	}
+	if len(cpuPeaks) == 0 {
+		return nil
+	}
+
+	return &pkgmetrics.MetricsWindow{
+		Start:      start,
+		End:        end,
+		DataPoints: samples,
+		P50CPU:     percentile(cpuPeaks, 50),
+		P95CPU:     percentile(cpuPeaks, 95),
+		P99CPU:     percentile(cpuPeaks, 99),
+		MaxCPU:     maxVal(cpuPeaks),
+		P50Memory:  percentile(memPeaks, 50),
+		P95Memory:  percentile(memPeaks, 95),
+		P99Memory:  percentile(memPeaks, 99),
+		MaxMemory:  maxVal(memPeaks),
+		Runs:       len(cpuPeaks),
+	}
+}
+
+// GetWorkloadCPUSeries returns the total CPU usage of every container of
+// the pods in namespace whose name satisfies match, in buckets of step
+// ordered by time. Each container contributes its mean over the bucket,
+// weighted by its share of the bucket's samples, so a pod replaced halfway
+// through a bucket is not counted twice. Buckets without samples are
+// omitted. MemoryUsage is left at zero.
+func (s *Store) GetWorkloadCPUSeries(namespace string, match func(pod string) bool, duration, step time.Duration) []pkgmetrics.UsageSample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type containerBucket struct {
+		sum int64
+		n   int
+	}
+	cutoff := time.Now().Add(-duration)
+	buckets := map[time.Time][]containerBucket{}
+	for key, points := range s.podSeries {
+		parts := strings.SplitN(key, "/", 3)
+		if len(parts) != 3 || parts[0] != namespace || !match(parts[1]) {
+			continue
+		}
+		byBucket := map[time.Time]*containerBucket{}
+		for _, p := range points {
+			if !p.Timestamp.After(cutoff) {
+				continue
+			}
+			b := p.Timestamp.Truncate(step)
+			if byBucket[b] == nil {
+				byBucket[b] = &containerBucket{}
+			}
+			byBucket[b].sum += p.CPUUsage
+			byBucket[b].n++
+		}
+		for b, cb := range byBucket {
+			buckets[b] = append(buckets[b], *cb)
+		}
+	}
+
+	out := make([]pkgmetrics.UsageSample, 0, len(buckets))
+	for b, containers := range buckets {
+		most := 0
+		for _, cb := range containers {
+			most = max(most, cb.n)
+		}
+		// mean × n/most reduces to sum/most.
+		var total int64
+		for _, cb := range containers {
+			total += cb.sum
+		}
+		out = append(out, pkgmetrics.UsageSample{Timestamp: b, CPUUsage: total / int64(most)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Timestamp.Before(out[j].Timestamp) })
+	return out
+}
+
 func (s *Store) computeWindow(points []dataPoint, duration time.Duration) *pkgmetrics.MetricsWindow {
 	if len(points) == 0 {
 		return nil
@@ -224,6 +682,11 @@ func (s *Store) computeWindow(points []dataPoint, duration time.Duration) *pkgme
 		}
 	}
 
+	return windowFromPoints(filtered)
+}
+
+// windowFromPoints summarizes time-ordered points into a MetricsWindow.
+func windowFromPoints(filtered []dataPoint) *pkgmetrics.MetricsWindow {
 	if len(filtered) == 0 {
 		return nil
 	}
@@ -267,6 +730,38 @@ func (s *Store) evict(series map[string][]dataPoint, key string) {
 	}
 }
 
+func (s *Store) evictGPU(key string) {
+	cutoff := time.Now().Add(-s.retention)
+	points := s.gpuSeries[key]
+	i := 0
+	for i < len(points) && points[i].Timestamp.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		if i == len(points) {
+			delete(s.gpuSeries, key)
+		} else {
+			s.gpuSeries[key] = points[i:]
+		}
+	}
+}
+
+func (s *Store) evictThrottle(key string) {
+	cutoff := time.Now().Add(-s.retention)
+	points := s.throttleSeries[key]
+	i := 0
+	for i < len(points) && points[i].Timestamp.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		if i == len(points) {
+			delete(s.throttleSeries, key)
+		} else {
+			s.throttleSeries[key] = points[i:]
+		}
+	}
+}
+
 // Cleanup removes series keys that have no data points within the retention
 // window, and enforces the maxPodSeriesKeys cap to prevent unbounded memory
 // growth from churned pods. Call this periodically (e.g. hourly).
@@ -289,6 +784,21 @@ func (s *Store) cleanupLocked() {
 			delete(s.podSeries, key)
 		}
 	}
+	for key, points := range s.gpuSeries {
+		if len(points) == 0 || points[len(points)-1].Timestamp.Before(cutoff) {
+			delete(s.gpuSeries, key)
+		}
+	}
+	for key, points := range s.throttleSeries {
+		if len(points) == 0 || points[len(points)-1].Timestamp.Before(cutoff) {
+			delete(s.throttleSeries, key)
+		}
+	}
+	for key, last := range s.throttleLast {
+		if last.Timestamp.Before(cutoff) {
+			delete(s.throttleLast, key)
+		}
+	}
 
 	// Safety cap: if pod series keys still exceed the maximum after
 	// retention-based cleanup, evict the oldest entries first.
@@ -341,6 +851,20 @@ func percentile(values []int64, pct int) int64 {
 	return sorted[idx]
 }
 
+func percentileFloat(values []float64, pct int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	idx := (pct * len(sorted)) / 100
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
 func maxVal(values []int64) int64 {
 	if len(values) == 0 {
 		return 0
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	pkgmetrics "github.com/koptimizer/koptimizer/pkg/metrics"
+)
+
+func TestStore_WorkloadCPUSeries(t *testing.T) {
+	s := NewStore(24 * time.Hour)
+	hour := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+	record := func(pod string, minute int, cpu ...int64) {
+		m := pkgmetrics.PodMetrics{Namespace: "default", Name: pod, Timestamp: hour.Add(time.Duration(minute) * time.Minute)}
+		for i, c := range cpu {
+			m.Containers = append(m.Containers, pkgmetrics.ContainerMetrics{Name: []string{"app", "proxy"}[i], CPUUsage: c})
+		}
+		s.RecordPodMetrics(m)
+	}
+
+	// First hour: two pods with an app and a proxy container throughout.
+	for minute := 0; minute < 60; minute += 15 {
+		record("web-1", minute, 400, 100)
+		record("web-2", minute, 600, 100)
+	}
+	// Second hour: web-1 is replaced by web-3 halfway through.
+	for minute := 60; minute < 120; minute += 15 {
+		pod := "web-1"
+		if minute >= 90 {
+			pod = "web-3"
+		}
+		record(pod, minute, 400, 100)
+		record("web-2", minute, 600, 100)
+	}
+	record("api-1", 10, 5000)
+
+	series := s.GetWorkloadCPUSeries("default", func(pod string) bool { return strings.HasPrefix(pod, "web-") }, 24*time.Hour, time.Hour)
+	if len(series) != 2 {
+		t.Fatalf("series = %+v, want two hourly buckets", series)
+	}
+	for i, want := range []int64{1200, 1200} {
+		if !series[i].Timestamp.Equal(hour.Add(time.Duration(i) * time.Hour)) {
+			t.Errorf("bucket %d starts at %s, want %s", i, series[i].Timestamp, hour.Add(time.Duration(i)*time.Hour))
+		}
+		if series[i].CPUUsage != want {
+			t.Errorf("bucket %d CPU = %dm, want %dm", i, series[i].CPUUsage, want)
+		}
+	}
+
+	if got := s.GetWorkloadCPUSeries("other", func(string) bool { return true }, 24*time.Hour, time.Hour); len(got) != 0 {
+		t.Errorf("series for an empty namespace = %+v, want none", got)
+	}
+}
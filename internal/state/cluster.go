@@ -1,6 +1,7 @@
 package state
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -65,8 +66,14 @@ type ClusterState struct {
 	pricingWarned    map[string]bool
 	metricsWarned    bool
 	diskStatsWarned  bool
+	gpuMetricsWarned bool
+	throttleWarned   bool
 	// Kubernetes clientset for kubelet proxy calls (disk stats)
 	kubeClientset *kubernetes.Clientset
+	// Scrape cAdvisor CFS throttling counters on every refresh
+	collectThrottling bool
+	// Helm drift of workloads, for AI Gate requests
+	helmDrift HelmDriftSource
 }
 
 // NewClusterState creates a new ClusterState. If db and writer are non-nil,
@@ -111,6 +118,13 @@ func (s *ClusterState) SetRESTConfig(cfg *rest.Config) {
 	s.kubeClientset = cs
 }
 
+// SetThrottlingCollection enables scraping container CPU throttling counters
+// from each kubelet's cAdvisor endpoint into the metrics store. Requires
+// SetRESTConfig.
+func (s *ClusterState) SetThrottlingCollection(enabled bool) {
+	s.collectThrottling = enabled
+}
+
 // kubeletStatsSummary is a minimal struct for parsing kubelet /stats/summary.
 type kubeletStatsSummary struct {
 	Node struct {
@@ -226,6 +240,106 @@ func (s *ClusterState) fetchDiskStats(ctx context.Context, nodeNames []string) (
 	return diskMap, podDiskMap, podNetMap
 }
 
+// fetchThrottling scrapes cAdvisor CFS throttling counters for all nodes via
+// kubelet proxy (parallel, best-effort).
+func (s *ClusterState) fetchThrottling(ctx context.Context, nodeNames []string) []pkgmetrics.CFSThrottling {
+	var mu sync.Mutex
+	var result []pkgmetrics.CFSThrottling
+	var firstErr error
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 10) // limit concurrency
+
+	for _, name := range nodeNames {
+		wg.Add(1)
+		sem <- struct{}{} // acquire slot
+		go func(nodeName string) {
+			defer wg.Done()
+			defer func() { <-sem }() // release slot
+
+			data, err := s.kubeClientset.CoreV1().RESTClient().
+				Get().
+				Resource("nodes").
+				Name(nodeName).
+				SubResource("proxy", "metrics", "cadvisor").
+				DoRaw(ctx)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("node %s: %w", nodeName, err)
+				}
+				mu.Unlock()
+				return
+			}
+
+			samples, err := intmetrics.ParseCFSThrottling(bytes.NewReader(data), time.Now())
+			if err != nil {
+				slog.Warn("failed to parse cAdvisor metrics", "node", nodeName, "error", err)
+				return
+			}
+			mu.Lock()
+			result = append(result, samples...)
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+
+	if firstErr != nil && len(result) == 0 && !s.throttleWarned {
+		slog.Warn("kubelet cAdvisor throttling metrics unavailable, CPU recommendations will ignore throttling", "error", firstErr)
+		s.throttleWarned = true
+	} else if len(result) > 0 {
+		s.throttleWarned = false
+	}
+	return result
+}
+
+// fetchGPUMetrics collects measured GPU metrics for nodes that expose GPUs
+// (parallel, best-effort). Allocation-based estimates are discarded so they
+// never enter the history used for idle detection.
+func (s *ClusterState) fetchGPUMetrics(ctx context.Context, nodes []corev1.Node) map[string]*pkgmetrics.GPUMetrics {
+	var mu sync.Mutex
+	result := make(map[string]*pkgmetrics.GPUMetrics)
+	var firstErr error
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 10) // limit concurrency
+
+	for i := range nodes {
+		if _, _, gpuCap, _ := ExtractNodeCapacity(&nodes[i]); gpuCap == 0 {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{} // acquire slot
+		go func(nodeName string) {
+			defer wg.Done()
+			defer func() { <-sem }() // release slot
+
+			m, err := s.metrics.GetGPUMetrics(ctx, nodeName)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			if m.Source == pkgmetrics.GPUSourceAllocation {
+				return
+			}
+			result[nodeName] = m
+		}(nodes[i].Name)
+	}
+	wg.Wait()
+
+	if firstErr != nil && !s.gpuMetricsWarned {
+		slog.Warn("GPU metrics unavailable for some nodes, GPU utilization will be allocation-based", "error", firstErr)
+		s.gpuMetricsWarned = true
+	} else if firstErr == nil {
+		s.gpuMetricsWarned = false
+	}
+	return result
+}
+
 // listAllNodes fetches all nodes using pagination to avoid OOM on large clusters.
 func (s *ClusterState) listAllNodes(ctx context.Context) (*corev1.NodeList, error) {
 	result := &corev1.NodeList{}
@@ -398,6 +512,27 @@ func (s *ClusterState) Refresh(ctx context.Context) error {
 		}
 	}
 
+	// Fetch CPU throttling counters from cAdvisor (parallel, best-effort).
+	if s.kubeClientset != nil && s.collectThrottling && s.metricsStore != nil {
+		nodeNames := make([]string, len(nodeList.Items))
+		for i := range nodeList.Items {
+			nodeNames[i] = nodeList.Items[i].Name
+		}
+		throttleCtx, throttleCancel := context.WithTimeout(ctx, 15*time.Second)
+		s.metricsStore.RecordThrottling(s.fetchThrottling(throttleCtx, nodeNames))
+		throttleCancel()
+	}
+
+	// Fetch measured GPU metrics (DCGM) and record them for idle history.
+	gpuCtx, gpuCancel := context.WithTimeout(ctx, 15*time.Second)
+	gpuMetricsMap := s.fetchGPUMetrics(gpuCtx, nodeList.Items)
+	gpuCancel()
+	if s.metricsStore != nil {
+		for _, m := range gpuMetricsMap {
+			s.metricsStore.RecordGPUMetrics(*m)
+		}
+	}
+
 	// Discover autoscalers (HPAs + KEDA ScaledObjects) for workload metadata.
 	autoscalerMap := s.fetchAutoscalers(ctx)
 
@@ -489,6 +624,17 @@ func (s *ClusterState) Refresh(ctx context.Context) error {
 			ns.MemoryUsed = m.MemoryUsage
 		}
 
+		// Apply measured GPU utilization (mean across devices).
+		if gm, ok := gpuMetricsMap[node.Name]; ok && len(gm.GPUs) > 0 {
+			var total float64
+			for _, g := range gm.GPUs {
+				total += g.Utilization
+				ns.GPUMemoryUsed += g.MemoryUsed
+			}
+			ns.GPUUtilization = total / float64(len(gm.GPUs))
+			ns.GPUMeasured = true
+		}
+
 		// Apply disk stats from kubelet (best-effort).
 		if ds, ok := diskStatsMap[node.Name]; ok {
 			if ds[0] > 0 {
@@ -615,6 +761,11 @@ func (s *ClusterState) Refresh(ctx context.Context) error {
 	return nil
 }
 
+// MetricsStore returns the historical metrics store, or nil if none is configured.
+func (s *ClusterState) MetricsStore() *intmetrics.Store {
+	return s.metricsStore
+}
+
 // GetNode returns a node by name.
 func (s *ClusterState) GetNode(name string) (*NodeState, bool) {
 	s.mu.RLock()
@@ -822,7 +973,7 @@ func buildNodeGroupMapping(nodes *corev1.NodeList, groups []*cloudprovider.NodeG
 		if node.Labels == nil {
 			continue
 		}
-		poolLabel := ""
+		poolLabel, poolSource := "", ""
 		if v, ok := node.Labels["cloud.google.com/gke-nodepool"]; ok {
 			poolLabel = v
 		} else if v, ok := node.Labels["eks.amazonaws.com/nodegroup"]; ok {
@@ -830,14 +981,21 @@ func buildNodeGroupMapping(nodes *corev1.NodeList, groups []*cloudprovider.NodeG
 		} else if v, ok := node.Labels["alpha.eksctl.io/nodegroup-name"]; ok {
 			poolLabel = v
 		} else if v, ok := node.Labels["karpenter.sh/nodepool"]; ok {
-			poolLabel = v
+			poolLabel, poolSource = v, cloudprovider.NodeGroupSourceKarpenter
 		} else if v, ok := node.Labels["kubernetes.azure.com/agentpool"]; ok {
 			poolLabel = v
 		}
 		if poolLabel != "" {
+			// A NodePool may share its name with a cloud node group; prefer
+			// the group of the same source.
 			for _, ng := range groups {
-				if ng.Name == poolLabel {
+				if ng.Name != poolLabel {
+					continue
+				}
+				if _, matched := result[node.Name]; !matched || ng.Source == poolSource {
 					result[node.Name] = ng.ID
+				}
+				if ng.Source == poolSource {
 					break
 				}
 			}
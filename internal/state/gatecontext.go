@@ -0,0 +1,351 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+	"github.com/koptimizer/koptimizer/pkg/cost"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+const (
+	// gateContextWindow is how far back OOM kills and audit events count
+	// as recent.
+	gateContextWindow = 24 * time.Hour
+	maxGateWorkloads  = 10
+	maxGateEvents     = 10
+	// gateRestartThreshold is the container restart count of a workload's
+	// pods reported as a risk factor.
+	gateRestartThreshold = 5
+)
+
+// HelmDriftSource reports whether workloads drifted from their Helm values.
+type HelmDriftSource interface {
+	// DriftSummary returns the drifted fields of the workload, or "" if it
+	// has not drifted or its drift is unknown. It must not block.
+	DriftSummary(namespace, kind, name string) string
+}
+
+// SetHelmDrift makes GateRequest report the Helm drift of affected workloads.
+func (s *ClusterState) SetHelmDrift(d HelmDriftSource) {
+	s.helmDrift = d
+}
+
+// gateWorkload is a workload affected by a recommendation.
+type gateWorkload struct {
+	aigate.WorkloadContext
+	podLabels labels.Set // Labels of one of its pods, for PDB selectors
+	pods      int        // Its pods within the recommendation's scope
+}
+
+// GateRequest builds the AI Gate request for rec from the cached state: the
+// cluster and node group summaries, the workloads rec affects with their
+// PDBs, health and Helm drift, and recent audit events on its target. Risk
+// factors derived from these are appended to riskFactors. Commitments are
+// not tracked here, so ActiveCommitments is left zero.
+func (s *ClusterState) GateRequest(ctx context.Context, rec optimizer.Recommendation, riskFactors ...string) aigate.ValidationRequest {
+	now := time.Now()
+	req := aigate.ValidationRequest{
+		Action:         rec.Summary,
+		Recommendation: rec,
+		RiskFactors:    append([]string(nil), riskFactors...),
+	}
+
+	s.mu.RLock()
+	req.ClusterContext = s.clusterSummaryLocked()
+	workloads := s.affectedWorkloadsLocked(rec, now)
+	s.mu.RUnlock()
+
+	s.addPDBs(ctx, workloads)
+	for _, wl := range workloads {
+		if s.helmDrift != nil {
+			wl.HelmDrift = s.helmDrift.DriftSummary(wl.Namespace, wl.Kind, wl.Name)
+		}
+		req.Workloads = append(req.Workloads, wl.WorkloadContext)
+		req.RiskFactors = append(req.RiskFactors, workloadRiskFactors(wl.WorkloadContext)...)
+	}
+	if n := req.ClusterContext.PendingPods; n > 0 {
+		req.RiskFactors = append(req.RiskFactors, fmt.Sprintf("%d pods in the cluster are waiting to be scheduled", n))
+	}
+	req.RecentEvents = s.recentTargetEvents(rec, now)
+	return req
+}
+
+func (s *ClusterState) clusterSummaryLocked() aigate.ClusterSummary {
+	var sum aigate.ClusterSummary
+	var cpu, mem float64
+	var withCapacity int
+	for _, n := range s.nodes {
+		sum.TotalNodes++
+		sum.MonthlyCostUSD += n.HourlyCostUSD * cost.HoursPerMonth
+		if n.CPUCapacity > 0 && n.MemoryCapacity > 0 {
+			cpu += n.CPUUtilization()
+			mem += n.MemoryUtilization()
+			withCapacity++
+		}
+	}
+	if withCapacity > 0 {
+		sum.AvgCPUUtilization = cpu / float64(withCapacity)
+		sum.AvgMemoryUtilization = mem / float64(withCapacity)
+	}
+	for _, p := range s.pods {
+		if p.Pod.Status.Phase == corev1.PodPending && p.NodeName == "" {
+			sum.PendingPods++
+		}
+	}
+
+	groups := s.nodeGroups.GetAll()
+	sum.TotalNodeGroups = len(groups)
+	for _, g := range groups {
+		sum.NodeGroupSummaries = append(sum.NodeGroupSummaries, aigate.NodeGroupSummary{
+			Name:           g.Name,
+			InstanceType:   g.InstanceType,
+			CurrentCount:   g.CurrentCount,
+			MinCount:       g.MinCount,
+			MaxCount:       g.MaxCount,
+			UtilizationPct: g.CPUUtilization(),
+		})
+	}
+	sort.Slice(sum.NodeGroupSummaries, func(i, j int) bool {
+		return sum.NodeGroupSummaries[i].Name < sum.NodeGroupSummaries[j].Name
+	})
+	return sum
+}
+
+// affectedWorkloadsLocked returns the workloads rec changes: the target
+// workload, or the workloads with pods on the target node, node group or
+// namespace, most pods first. Their health counts all their pods.
+func (s *ClusterState) affectedWorkloadsLocked(rec optimizer.Recommendation, now time.Time) []*gateWorkload {
+	type key struct{ ns, kind, name string }
+	byKey := make(map[key]*gateWorkload)
+	var order []key
+	add := func(ns, kind, name string) *gateWorkload {
+		k := key{ns, kind, name}
+		if wl, ok := byKey[k]; ok {
+			return wl
+		}
+		wl := &gateWorkload{WorkloadContext: aigate.WorkloadContext{Namespace: ns, Kind: kind, Name: name}}
+		byKey[k] = wl
+		order = append(order, k)
+		return wl
+	}
+
+	// Select the pods in scope of the recommendation.
+	var inScope func(p *PodState) bool
+	switch rec.TargetKind {
+	case "Cluster", "PersistentVolumeClaim", "":
+		return nil
+	case "Node", "NodeGroup":
+		nodes := s.targetNodesLocked(rec)
+		inScope = func(p *PodState) bool { return nodes[p.NodeName] && !isDaemonSetPod(p.Pod) }
+	case "Namespace":
+		inScope = func(p *PodState) bool { return p.Namespace == rec.TargetName }
+	case "Pod":
+		p, ok := s.pods[rec.TargetNamespace+"/"+rec.TargetName]
+		if !ok {
+			return nil
+		}
+		kind, name := workloadOf(p)
+		add(p.Namespace, kind, name)
+		inScope = func(p *PodState) bool { return false }
+	default:
+		add(rec.TargetNamespace, rec.TargetKind, rec.TargetName)
+		inScope = func(p *PodState) bool { return false }
+	}
+
+	for _, p := range s.pods {
+		if p.Pod.Status.Phase == corev1.PodSucceeded || p.Pod.Status.Phase == corev1.PodFailed || !inScope(p) {
+			continue
+		}
+		kind, name := workloadOf(p)
+		add(p.Namespace, kind, name).pods++
+	}
+
+	workloads := make([]*gateWorkload, 0, len(order))
+	for _, k := range order {
+		workloads = append(workloads, byKey[k])
+	}
+	sort.SliceStable(workloads, func(i, j int) bool { return workloads[i].pods > workloads[j].pods })
+	if len(workloads) > maxGateWorkloads {
+		workloads = workloads[:maxGateWorkloads]
+	}
+	selected := make(map[key]*gateWorkload, len(workloads))
+	for _, wl := range workloads {
+		selected[key{wl.Namespace, wl.Kind, wl.Name}] = wl
+	}
+
+	for _, p := range s.pods {
+		if p.Pod.Status.Phase == corev1.PodSucceeded || p.Pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		kind, name := workloadOf(p)
+		wl, ok := selected[key{p.Namespace, kind, name}]
+		if !ok {
+			continue
+		}
+		if wl.podLabels == nil {
+			wl.podLabels = labels.Set(p.Pod.Labels)
+		}
+		wl.Replicas++
+		if p.Pod.Status.Phase == corev1.PodPending {
+			wl.PendingPods++
+		}
+		if isPodReady(p.Pod) {
+			wl.ReadyReplicas++
+		}
+		for _, cs := range p.Pod.Status.ContainerStatuses {
+			wl.Restarts += int(cs.RestartCount)
+			if t := cs.LastTerminationState.Terminated; t != nil && t.Reason == "OOMKilled" && now.Sub(t.FinishedAt.Time) <= gateContextWindow {
+				wl.RecentOOMKills++
+			}
+		}
+	}
+	return workloads
+}
+
+// targetNodesLocked returns the nodes of a Node or NodeGroup recommendation.
+func (s *ClusterState) targetNodesLocked(rec optimizer.Recommendation) map[string]bool {
+	nodes := make(map[string]bool)
+	if rec.TargetKind == "Node" {
+		name := rec.Details["nodeName"]
+		if name == "" {
+			name = rec.TargetName
+		}
+		nodes[name] = true
+		return nodes
+	}
+	groupID := rec.Details["nodeGroupID"]
+	for name, n := range s.nodes {
+		if (groupID != "" && n.NodeGroupID == groupID) || (groupID == "" && n.NodeGroupName == rec.TargetName) {
+			nodes[name] = true
+		}
+	}
+	return nodes
+}
+
+// addPDBs sets the PodDisruptionBudget covering each workload. When several
+// do, the one allowing the fewest disruptions wins.
+func (s *ClusterState) addPDBs(ctx context.Context, workloads []*gateWorkload) {
+	if s.reader == nil {
+		return
+	}
+	byNamespace := make(map[string][]*gateWorkload)
+	for _, wl := range workloads {
+		if wl.podLabels != nil {
+			byNamespace[wl.Namespace] = append(byNamespace[wl.Namespace], wl)
+		}
+	}
+	for ns, nsWorkloads := range byNamespace {
+		var pdbs policyv1.PodDisruptionBudgetList
+		if err := s.reader.List(ctx, &pdbs, client.InNamespace(ns)); err != nil {
+			slog.Warn("AI Gate context: listing PDBs failed", "namespace", ns, "error", err)
+			continue
+		}
+		for i := range pdbs.Items {
+			pdb := &pdbs.Items[i]
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil {
+				continue
+			}
+			allowed := int(pdb.Status.DisruptionsAllowed)
+			for _, wl := range nsWorkloads {
+				if selector.Matches(wl.podLabels) && (wl.PDB == "" || allowed < wl.PDBDisruptionsAllowed) {
+					wl.PDB = pdb.Name
+					wl.PDBDisruptionsAllowed = allowed
+				}
+			}
+		}
+	}
+}
+
+// recentTargetEvents returns the audit events on rec's target within
+// gateContextWindow, newest first.
+func (s *ClusterState) recentTargetEvents(rec optimizer.Recommendation, now time.Time) []string {
+	if s.AuditLog == nil || rec.TargetName == "" {
+		return nil
+	}
+	var events []string
+	for _, ev := range s.AuditLog.GetRecent(1000) {
+		if now.Sub(ev.Timestamp) > gateContextWindow {
+			break
+		}
+		parts := strings.Split(ev.Target, "/")
+		if parts[len(parts)-1] != rec.TargetName {
+			continue
+		}
+		if rec.TargetNamespace != "" && len(parts) > 1 && parts[0] != rec.TargetNamespace {
+			continue
+		}
+		events = append(events, fmt.Sprintf("%s %s by %s: %s", ev.Timestamp.UTC().Format(time.RFC3339), ev.Action, ev.User, ev.Details))
+		if len(events) == maxGateEvents {
+			break
+		}
+	}
+	return events
+}
+
+// workloadRiskFactors describes what makes disrupting wl risky.
+func workloadRiskFactors(wl aigate.WorkloadContext) []string {
+	id := fmt.Sprintf("%s %s/%s", wl.Kind, wl.Namespace, wl.Name)
+	var risks []string
+	if wl.PDB != "" && wl.PDBDisruptionsAllowed == 0 {
+		risks = append(risks, fmt.Sprintf("PodDisruptionBudget %s allows no disruptions of %s", wl.PDB, id))
+	}
+	if wl.Replicas == 1 {
+		switch wl.Kind {
+		case "DaemonSet", "Job", "CronJob", "Pod":
+		default:
+			risks = append(risks, fmt.Sprintf("%s runs a single replica", id))
+		}
+	}
+	if wl.ReadyReplicas < wl.Replicas {
+		risks = append(risks, fmt.Sprintf("%s has %d of %d pods ready", id, wl.ReadyReplicas, wl.Replicas))
+	}
+	if wl.RecentOOMKills > 0 {
+		risks = append(risks, fmt.Sprintf("%s had %d OOM kills in the last 24h", id, wl.RecentOOMKills))
+	}
+	if wl.Restarts >= gateRestartThreshold {
+		risks = append(risks, fmt.Sprintf("%s has %d container restarts", id, wl.Restarts))
+	}
+	if wl.HelmDrift != "" {
+		risks = append(risks, fmt.Sprintf("%s has drifted from its Helm values: %s", id, wl.HelmDrift))
+	}
+	return risks
+}
+
+// workloadOf returns the workload owning a pod, resolving ReplicaSets to
+// their Deployment. Unowned pods are their own workload.
+func workloadOf(p *PodState) (kind, name string) {
+	kind, name = p.OwnerKind, p.OwnerName
+	if kind == "ReplicaSet" && p.Pod != nil {
+		if hash, ok := p.Pod.Labels["pod-template-hash"]; ok && strings.HasSuffix(name, "-"+hash) {
+			kind = "Deployment"
+			name = strings.TrimSuffix(name, "-"+hash)
+		}
+	}
+	if name == "" {
+		name = p.Name
+		kind = "Pod"
+	}
+	return
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
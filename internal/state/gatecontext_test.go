@@ -0,0 +1,147 @@
+package state
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// ---------------------------------------------------------------------------
+// AI Gate Context Tests
+// ---------------------------------------------------------------------------
+
+type fakeHelmDrift map[string]string
+
+func (f fakeHelmDrift) DriftSummary(namespace, kind, name string) string {
+	return f[namespace+"/"+kind+"/"+name]
+}
+
+func gatePod(name, node, ownerKind, owner string, ready bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", Labels: map[string]string{"app": owner}},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if ownerKind != "" {
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: owner}}
+	}
+	if ownerKind == "ReplicaSet" {
+		pod.OwnerReferences[0].Name = owner + "-7f9c"
+		pod.Labels["pod-template-hash"] = "7f9c"
+	}
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}
+	return pod
+}
+
+func TestGateRequest_NodeDrain(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-pdb", Namespace: "shop"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+		Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: 0},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pdb).Build()
+	s := NewClusterState(c, nil, nil, nil, nil, nil)
+	s.SetHelmDrift(fakeHelmDrift{"shop/Deployment/web": "replicas helm=2 actual=3"})
+
+	api := gatePod("api-0", "n1", "StatefulSet", "api", true)
+	api.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		RestartCount: 6,
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			Reason:     "OOMKilled",
+			FinishedAt: metav1.NewTime(time.Now().Add(-time.Hour)),
+		}},
+	}}
+	pending := gatePod("batch-x", "", "Job", "batch", false)
+	pending.Status.Phase = corev1.PodPending
+	pods := []*corev1.Pod{
+		gatePod("web-1", "n1", "ReplicaSet", "web", true),
+		gatePod("web-2", "n1", "ReplicaSet", "web", true),
+		gatePod("web-3", "n2", "ReplicaSet", "web", false),
+		gatePod("logs-n1", "n1", "DaemonSet", "logs", true),
+		api,
+		pending,
+	}
+	for _, p := range pods {
+		s.pods[p.Namespace+"/"+p.Name] = NewPodState(p)
+	}
+	s.nodes["n1"] = &NodeState{NodeGroupID: "ng-1", CPUCapacity: 1000, CPUUsed: 600, MemoryCapacity: 100, MemoryUsed: 20, HourlyCostUSD: 0.1}
+	s.nodes["n2"] = &NodeState{NodeGroupID: "ng-1", CPUCapacity: 1000, CPUUsed: 200, MemoryCapacity: 100, MemoryUsed: 40, HourlyCostUSD: 0.1}
+	s.nodeGroups.Update([]*cloudprovider.NodeGroup{{ID: "ng-1", Name: "general", InstanceType: "m5.large", CurrentCount: 2, MinCount: 1, MaxCount: 5}},
+		[]*NodeState{s.nodes["n1"], s.nodes["n2"]})
+	s.AuditLog.Record("consolidate-node-aborted", "n1", "evictor", "pods did not reschedule")
+	s.AuditLog.Record("consolidate-node", "n2", "evictor", "drain n2")
+
+	rec := optimizer.Recommendation{
+		Summary:    "Drain underutilized node n1",
+		TargetKind: "Node",
+		TargetName: "n1",
+		Details:    map[string]string{"nodeName": "n1", "nodeGroupID": "ng-1"},
+	}
+	req := s.GateRequest(context.Background(), rec, "Node drain evicts all non-DaemonSet pods on the node")
+
+	cc := req.ClusterContext
+	if cc.TotalNodes != 2 || cc.TotalNodeGroups != 1 || cc.PendingPods != 1 || cc.AvgCPUUtilization != 40 || cc.AvgMemoryUtilization != 30 {
+		t.Errorf("cluster context = %+v", cc)
+	}
+	if len(cc.NodeGroupSummaries) != 1 || cc.NodeGroupSummaries[0].Name != "general" || cc.NodeGroupSummaries[0].UtilizationPct != 40 {
+		t.Errorf("node group summaries = %+v", cc.NodeGroupSummaries)
+	}
+
+	wantWorkloads := []aigate.WorkloadContext{
+		{Namespace: "shop", Kind: "Deployment", Name: "web", Replicas: 3, ReadyReplicas: 2,
+			PDB: "web-pdb", PDBDisruptionsAllowed: 0, HelmDrift: "replicas helm=2 actual=3"},
+		{Namespace: "shop", Kind: "StatefulSet", Name: "api", Replicas: 1, ReadyReplicas: 1, Restarts: 6, RecentOOMKills: 1},
+	}
+	if !reflect.DeepEqual(req.Workloads, wantWorkloads) {
+		t.Errorf("workloads = %+v\nwant %+v", req.Workloads, wantWorkloads)
+	}
+
+	wantRisks := []string{
+		"Node drain evicts all non-DaemonSet pods on the node",
+		"PodDisruptionBudget web-pdb allows no disruptions of Deployment shop/web",
+		"Deployment shop/web has 2 of 3 pods ready",
+		"Deployment shop/web has drifted from its Helm values: replicas helm=2 actual=3",
+		"StatefulSet shop/api runs a single replica",
+		"StatefulSet shop/api had 1 OOM kills in the last 24h",
+		"StatefulSet shop/api has 6 container restarts",
+		"1 pods in the cluster are waiting to be scheduled",
+	}
+	if !reflect.DeepEqual(req.RiskFactors, wantRisks) {
+		t.Errorf("risk factors = %q\nwant %q", req.RiskFactors, wantRisks)
+	}
+
+	if len(req.RecentEvents) != 1 || !strings.Contains(req.RecentEvents[0], "consolidate-node-aborted by evictor") {
+		t.Errorf("recent events = %q, want only the event on n1", req.RecentEvents)
+	}
+}
+
+func TestGateRequest_ClusterTarget(t *testing.T) {
+	s := NewClusterState(nil, nil, nil, nil, nil, nil)
+	s.pods["shop/web-1"] = NewPodState(gatePod("web-1", "n1", "ReplicaSet", "web", true))
+
+	req := s.GateRequest(context.Background(), optimizer.Recommendation{Summary: "Hibernate", TargetKind: "Cluster", TargetName: "cluster"})
+	if req.Action != "Hibernate" || len(req.Workloads) != 0 || len(req.RiskFactors) != 0 {
+		t.Errorf("req = %+v, want no workloads or risk factors for a cluster-wide change", req)
+	}
+}
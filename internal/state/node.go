@@ -28,6 +28,11 @@ type NodeState struct {
 	MemoryUsed int64
 	GPUsUsed   int
 
+	// Measured GPU usage (from DCGM; zero unless GPUMeasured)
+	GPUUtilization float64 // mean across devices, 0-100
+	GPUMemoryUsed  int64   // bytes, summed across devices
+	GPUMeasured    bool
+
 	// Disk (ephemeral storage)
 	DiskCapacity int64 // bytes (from node Status.Capacity ephemeral-storage)
 	DiskUsed     int64 // bytes (from kubelet stats summary)
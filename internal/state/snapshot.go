@@ -39,6 +39,8 @@ func (c *ClusterState) Snapshot() *optimizer.ClusterSnapshot {
 			MemoryUsed:      n.MemoryUsed,
 			GPUs:            n.GPUCapacity,
 			GPUsUsed:        n.GPUsUsed,
+			GPUUtilization:  n.GPUUtilization,
+			GPUMeasured:     n.GPUMeasured,
 			HourlyCostUSD:   n.HourlyCostUSD,
 			IsGPUNode:       n.IsGPUNode,
 			NodeGroup:       n.NodeGroupID,
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/koptimizer/koptimizer/pkg/aigate"
+)
+
+// AIGateStore keeps the history of AI Gate decisions with their prompts,
+// model output and real-world outcomes, for audits and replays.
+type AIGateStore struct {
+	db *sql.DB
+}
+
+// NewAIGateStore creates an AIGateStore. db may be nil (all ops become no-ops).
+func NewAIGateStore(db *sql.DB) *AIGateStore {
+	return &AIGateStore{db: db}
+}
+
+// AIGateDecisionFilter selects recorded decisions. Zero fields match all.
+type AIGateDecisionFilter struct {
+	IDs              []int64
+	Since            time.Time
+	RecommendationID string
+	Validator        string
+	Outcome          string
+	Approved         *bool
+	Limit            int // Default 100
+}
+
+const aigateDecisionColumns = `id, timestamp, recommendation_id, prompt_version, latency_ms, error,
+	request, prompt, raw_output, response, outcome, outcome_detail, outcome_at`
+
+// RecordDecision implements aigate.Recorder.
+func (s *AIGateStore) RecordDecision(d aigate.Decision) {
+	if s == nil || s.db == nil {
+		return
+	}
+	request, err := json.Marshal(d.Request)
+	if err != nil {
+		slog.Error("aigate decision: marshal request", "recommendation", d.RecommendationID, "error", err)
+		return
+	}
+	response, err := json.Marshal(d.Response)
+	if err != nil {
+		slog.Error("aigate decision: marshal response", "recommendation", d.RecommendationID, "error", err)
+		return
+	}
+	rec := d.Request.Recommendation
+	if _, err := s.db.Exec(
+		`INSERT INTO aigate_decisions (timestamp, recommendation_id, rec_type, target, validator, model, rule,
+			prompt_version, approved, confidence, latency_ms, error, request, prompt, raw_output, response)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.Timestamp.Unix(), d.RecommendationID, string(rec.Type),
+		strings.Trim(rec.TargetNamespace+"/"+rec.TargetKind+"/"+rec.TargetName, "/"),
+		d.Response.Validator, d.Response.Model, d.Response.Rule, d.PromptVersion,
+		d.Response.Approved, d.Response.Confidence, d.LatencyMs, d.Error,
+		string(request), d.Prompt, d.RawOutput, string(response),
+	); err != nil {
+		slog.Error("aigate decision: insert", "recommendation", d.RecommendationID, "error", err)
+	}
+}
+
+// RecordOutcome implements aigate.Recorder.
+func (s *AIGateStore) RecordOutcome(recommendationID, outcome, detail string, at time.Time) {
+	if s == nil || s.db == nil {
+		return
+	}
+	if _, err := s.db.Exec(
+		"UPDATE aigate_decisions SET outcome = ?, outcome_detail = ?, outcome_at = ? WHERE recommendation_id = ?",
+		outcome, detail, at.Unix(), recommendationID,
+	); err != nil {
+		slog.Error("aigate decision: record outcome", "recommendation", recommendationID, "error", err)
+	}
+}
+
+// List returns the decisions matching f, newest first.
+func (s *AIGateStore) List(f AIGateDecisionFilter) []aigate.Decision {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	var where []string
+	var args []any
+	if len(f.IDs) > 0 {
+		where = append(where, "id IN (?"+strings.Repeat(", ?", len(f.IDs)-1)+")")
+		for _, id := range f.IDs {
+			args = append(args, id)
+		}
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, f.Since.Unix())
+	}
+	if f.RecommendationID != "" {
+		where = append(where, "recommendation_id = ?")
+		args = append(args, f.RecommendationID)
+	}
+	if f.Validator != "" {
+		where = append(where, "validator = ?")
+		args = append(args, f.Validator)
+	}
+	if f.Outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, f.Outcome)
+	}
+	if f.Approved != nil {
+		where = append(where, "approved = ?")
+		args = append(args, *f.Approved)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := "SELECT " + aigateDecisionColumns + " FROM aigate_decisions"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		slog.Error("aigate decision: list", "error", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var result []aigate.Decision
+	for rows.Next() {
+		if d, ok := scanAIGateDecision(rows); ok {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// Get returns the decision with id.
+func (s *AIGateStore) Get(id int64) (aigate.Decision, bool) {
+	decisions := s.List(AIGateDecisionFilter{IDs: []int64{id}, Limit: 1})
+	if len(decisions) == 0 {
+		return aigate.Decision{}, false
+	}
+	return decisions[0], true
+}
+
+func scanAIGateDecision(rows *sql.Rows) (aigate.Decision, bool) {
+	var d aigate.Decision
+	var ts int64
+	var outcomeAt sql.NullInt64
+	var request, response string
+	if err := rows.Scan(&d.ID, &ts, &d.RecommendationID, &d.PromptVersion, &d.LatencyMs, &d.Error,
+		&request, &d.Prompt, &d.RawOutput, &response, &d.Outcome, &d.OutcomeDetail, &outcomeAt); err != nil {
+		slog.Error("aigate decision: scan", "error", err)
+		return d, false
+	}
+	if err := json.Unmarshal([]byte(request), &d.Request); err != nil {
+		slog.Error("aigate decision: unmarshal request", "id", d.ID, "error", err)
+		return d, false
+	}
+	if err := json.Unmarshal([]byte(response), &d.Response); err != nil {
+		slog.Error("aigate decision: unmarshal response", "id", d.ID, "error", err)
+		return d, false
+	}
+	d.Timestamp = time.Unix(ts, 0)
+	if outcomeAt.Valid {
+		t := time.Unix(outcomeAt.Int64, 0)
+		d.OutcomeAt = &t
+	}
+	return d, true
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"log/slog"
 	"time"
+
+	"github.com/koptimizer/koptimizer/pkg/cost"
 )
 
 // CostSnapshot represents a daily cost data point.
@@ -78,6 +80,190 @@ func (s *CostStore) RecordDailySnapshot(total float64, costByNS map[string]float
 	}
 }
 
+// Allocation buckets persisted by RecordDailyAllocation.
+const (
+	AllocationBucketNamespace = "namespace"
+	AllocationBucketShared    = "shared"
+	AllocationBucketIdle      = "idle"
+)
+
+// AllocationRecord is one row of a persisted daily cost allocation. For
+// idle rows Name is the node group ID, which stays unique when a NodePool
+// and a cloud node group share a name, and DisplayName the group's name.
+type AllocationRecord struct {
+	Date           string  `json:"date"`
+	Bucket         string  `json:"bucket"`
+	Name           string  `json:"name"`
+	DisplayName    string  `json:"displayName,omitempty"`
+	DirectCostUSD  float64 `json:"directCostUSD"`
+	SharedCostUSD  float64 `json:"sharedCostUSD"`
+	MonthlyCostUSD float64 `json:"monthlyCostUSD"`
+}
+
+// RecordDailyAllocation replaces today's allocation rows with a. Rows are
+// replaced rather than upserted so namespaces that disappeared during the
+// day do not linger and the day always reconciles with its total.
+func (s *CostStore) RecordDailyAllocation(a *cost.Allocation) {
+	if s.db == nil || a == nil {
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("cost allocation: begin tx", "error", err)
+		return
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after Commit
+
+	if _, err := tx.Exec("DELETE FROM cost_allocation WHERE date = ?", today); err != nil {
+		slog.Error("cost allocation: clear day", "error", err)
+		return
+	}
+
+	insert := func(bucket, name, displayName string, direct, shared, total float64) error {
+		_, err := tx.Exec(
+			"INSERT INTO cost_allocation (date, bucket, name, display_name, direct_cost_usd, shared_cost_usd, cost_usd) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			today, bucket, name, displayName, direct, shared, total,
+		)
+		return err
+	}
+	for _, na := range a.Namespaces {
+		if err := insert(AllocationBucketNamespace, na.Namespace, "", na.DirectCostUSD, na.SharedCostUSD, na.MonthlyCostUSD); err != nil {
+			slog.Error("cost allocation: insert namespace", "namespace", na.Namespace, "error", err)
+			return
+		}
+	}
+	for _, na := range a.Shared {
+		if err := insert(AllocationBucketShared, na.Namespace, "", na.DirectCostUSD, 0, na.MonthlyCostUSD); err != nil {
+			slog.Error("cost allocation: insert shared", "namespace", na.Namespace, "error", err)
+			return
+		}
+	}
+	for _, ia := range a.Idle {
+		id := ia.NodeGroupID
+		if id == "" {
+			id = "unassigned"
+		}
+		if err := insert(AllocationBucketIdle, id, ia.NodeGroup, ia.MonthlyCostUSD, 0, ia.MonthlyCostUSD); err != nil {
+			slog.Error("cost allocation: insert idle", "nodegroup", id, "error", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("cost allocation: commit tx", "error", err)
+	}
+}
+
+// GetAllocationHistory returns the persisted allocation rows of the last N
+// days, ordered by date, bucket and descending cost.
+func (s *CostStore) GetAllocationHistory(days int) []AllocationRecord {
+	if s.db == nil {
+		return nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	return s.queryAllocation(
+		"SELECT date, bucket, name, display_name, direct_cost_usd, shared_cost_usd, cost_usd FROM cost_allocation WHERE date >= ? ORDER BY date ASC, bucket ASC, cost_usd DESC",
+		cutoff,
+	)
+}
+
+// GetAllocationForPeriod returns the persisted allocation rows for the
+// dates in [start, end), ordered by date, bucket and name.
+func (s *CostStore) GetAllocationForPeriod(start, end time.Time) []AllocationRecord {
+	if s.db == nil {
+		return nil
+	}
+
+	return s.queryAllocation(
+		"SELECT date, bucket, name, display_name, direct_cost_usd, shared_cost_usd, cost_usd FROM cost_allocation WHERE date >= ? AND date < ? ORDER BY date ASC, bucket ASC, name ASC",
+		start.Format("2006-01-02"), end.Format("2006-01-02"),
+	)
+}
+
+func (s *CostStore) queryAllocation(query string, args ...any) []AllocationRecord {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var result []AllocationRecord
+	for rows.Next() {
+		var r AllocationRecord
+		if err := rows.Scan(&r.Date, &r.Bucket, &r.Name, &r.DisplayName, &r.DirectCostUSD, &r.SharedCostUSD, &r.MonthlyCostUSD); err != nil {
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// GetDailyTotalsForPeriod returns the recorded cluster monthly cost keyed
+// by date for the dates in [start, end).
+func (s *CostStore) GetDailyTotalsForPeriod(start, end time.Time) map[string]float64 {
+	if s.db == nil {
+		return nil
+	}
+
+	rows, err := s.db.Query(
+		"SELECT date, total_monthly_cost_usd FROM cost_snapshots WHERE date >= ? AND date < ?",
+		start.Format("2006-01-02"), end.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	result := make(map[string]float64)
+	for rows.Next() {
+		var date string
+		var total float64
+		if err := rows.Scan(&date, &total); err != nil {
+			continue
+		}
+		result[date] = total
+	}
+	return result
+}
+
+// GetNamespaceDailyForPeriod returns date -> namespace -> monthly cost for
+// the dates in [start, end).
+func (s *CostStore) GetNamespaceDailyForPeriod(start, end time.Time) map[string]map[string]float64 {
+	if s.db == nil {
+		return nil
+	}
+
+	rows, err := s.db.Query(
+		"SELECT date, namespace, cost_usd FROM cost_by_namespace WHERE date >= ? AND date < ?",
+		start.Format("2006-01-02"), end.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[string]float64)
+	for rows.Next() {
+		var date, ns string
+		var c float64
+		if err := rows.Scan(&date, &ns, &c); err != nil {
+			continue
+		}
+		if result[date] == nil {
+			result[date] = make(map[string]float64)
+		}
+		result[date][ns] = c
+	}
+	return result
+}
+
 // GetByNamespaceForPeriod returns average cost per namespace for the given date range.
 func (s *CostStore) GetByNamespaceForPeriod(start, end time.Time) map[string]float64 {
 	if s.db == nil {
@@ -12,14 +12,16 @@ import (
 
 // Config holds database configuration.
 type Config struct {
-	Path          string
-	RetentionDays int
+	Path             string
+	RetentionDays    int
+	MetricsRetention time.Duration // default 7 days
 }
 
 // DB wraps a sql.DB with retention settings.
 type DB struct {
-	db            *sql.DB
-	retentionDays int
+	db               *sql.DB
+	retentionDays    int
+	metricsRetention time.Duration
 }
 
 // RawDB returns the underlying *sql.DB for components that need direct access.
@@ -77,7 +79,12 @@ func Open(cfg Config) (*DB, error) {
 		retDays = 90
 	}
 
-	d := &DB{db: sqlDB, retentionDays: retDays}
+	metricsRet := cfg.MetricsRetention
+	if metricsRet <= 0 {
+		metricsRet = 7 * 24 * time.Hour
+	}
+
+	d := &DB{db: sqlDB, retentionDays: retDays, metricsRetention: metricsRet}
 
 	// Run cleanup at startup so old data is purged even if the pod never
 	// lives long enough for the periodic ticker to fire.
@@ -122,6 +129,18 @@ func createTables(db *sql.DB) error {
 			UNIQUE(date, nodegroup)
 		)`,
 
+		`CREATE TABLE IF NOT EXISTS cost_allocation (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			bucket TEXT NOT NULL,
+			name TEXT NOT NULL,
+			display_name TEXT NOT NULL DEFAULT '',
+			direct_cost_usd REAL NOT NULL,
+			shared_cost_usd REAL NOT NULL,
+			cost_usd REAL NOT NULL,
+			UNIQUE(date, bucket, name)
+		)`,
+
 		`CREATE TABLE IF NOT EXISTS node_metrics (
 			id INTEGER PRIMARY KEY,
 			timestamp INTEGER NOT NULL,
@@ -142,6 +161,28 @@ func createTables(db *sql.DB) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_pod_metrics_key_ts ON pod_metrics(namespace, pod_name, container, timestamp)`,
 
+		`CREATE TABLE IF NOT EXISTS gpu_metrics (
+			id INTEGER PRIMARY KEY,
+			timestamp INTEGER NOT NULL,
+			node_name TEXT NOT NULL,
+			gpu_index INTEGER NOT NULL,
+			utilization REAL NOT NULL,
+			memory_used INTEGER NOT NULL,
+			memory_total INTEGER NOT NULL,
+			temperature REAL NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_gpu_metrics_name_ts ON gpu_metrics(node_name, timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS throttle_metrics (
+			id INTEGER PRIMARY KEY,
+			timestamp INTEGER NOT NULL,
+			namespace TEXT NOT NULL,
+			pod_name TEXT NOT NULL,
+			container TEXT NOT NULL,
+			ratio REAL NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_throttle_metrics_key_ts ON throttle_metrics(namespace, pod_name, container, timestamp)`,
+
 		`CREATE TABLE IF NOT EXISTS cost_snapshots_hourly (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			datetime_hour TEXT NOT NULL UNIQUE,
@@ -160,6 +201,39 @@ func createTables(db *sql.DB) error {
 			total_monthly_cost REAL NOT NULL
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_cluster_snapshots_ts ON cluster_snapshots(timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS hub_cluster_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			cluster TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			payload TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_hub_cluster_snapshots_cluster_ts ON hub_cluster_snapshots(cluster, timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS aigate_decisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp INTEGER NOT NULL,
+			recommendation_id TEXT NOT NULL,
+			rec_type TEXT NOT NULL,
+			target TEXT NOT NULL,
+			validator TEXT NOT NULL,
+			model TEXT NOT NULL,
+			rule TEXT NOT NULL,
+			prompt_version TEXT NOT NULL,
+			approved INTEGER NOT NULL,
+			confidence REAL NOT NULL,
+			latency_ms INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			request TEXT NOT NULL,
+			prompt TEXT NOT NULL,
+			raw_output TEXT NOT NULL,
+			response TEXT NOT NULL,
+			outcome TEXT NOT NULL DEFAULT '',
+			outcome_detail TEXT NOT NULL DEFAULT '',
+			outcome_at INTEGER
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_aigate_decisions_ts ON aigate_decisions(timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_aigate_decisions_rec ON aigate_decisions(recommendation_id)`,
 	}
 
 	for _, stmt := range stmts {
@@ -167,14 +241,50 @@ func createTables(db *sql.DB) error {
 			return fmt.Errorf("executing %q: %w", stmt[:40], err)
 		}
 	}
+
+	// Columns added after a table was first created.
+	columns := []struct{ table, column, def string }{
+		{"cost_allocation", "display_name", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, c := range columns {
+		if err := addColumn(db, c.table, c.column, c.def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to an existing table unless it is already there.
+func addColumn(db *sql.DB, table, column, def string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("reading columns of %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+	rows.Close()
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def)); err != nil {
+		return fmt.Errorf("adding column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
 // Cleanup deletes audit/cost records older than retentionDays and metrics
-// older than 7 days.
+// older than metricsRetention.
 func (d *DB) Cleanup() error {
 	retentionCutoff := time.Now().AddDate(0, 0, -d.retentionDays).Format(time.RFC3339)
-	metricsCutoff := time.Now().Add(-7 * 24 * time.Hour).Unix()
+	metricsCutoff := time.Now().Add(-d.metricsRetention).Unix()
 	dateCutoff := time.Now().AddDate(0, 0, -d.retentionDays).Format("2006-01-02")
 
 	stmts := []struct {
@@ -185,10 +295,15 @@ func (d *DB) Cleanup() error {
 		{"DELETE FROM cost_snapshots WHERE date < ?", dateCutoff},
 		{"DELETE FROM cost_by_namespace WHERE date < ?", dateCutoff},
 		{"DELETE FROM cost_by_nodegroup WHERE date < ?", dateCutoff},
+		{"DELETE FROM cost_allocation WHERE date < ?", dateCutoff},
 		{"DELETE FROM node_metrics WHERE timestamp < ?", metricsCutoff},
 		{"DELETE FROM pod_metrics WHERE timestamp < ?", metricsCutoff},
+		{"DELETE FROM gpu_metrics WHERE timestamp < ?", metricsCutoff},
+		{"DELETE FROM throttle_metrics WHERE timestamp < ?", metricsCutoff},
 		{"DELETE FROM cost_snapshots_hourly WHERE datetime_hour < ?", time.Now().AddDate(0, 0, -d.retentionDays).Format("2006-01-02T15")},
 		{"DELETE FROM cluster_snapshots WHERE timestamp < ?", metricsCutoff},
+		{"DELETE FROM hub_cluster_snapshots WHERE timestamp < ?", time.Now().AddDate(0, 0, -d.retentionDays).Unix()},
+		{"DELETE FROM aigate_decisions WHERE timestamp < ?", time.Now().AddDate(0, 0, -d.retentionDays).Unix()},
 	}
 
 	for _, s := range stmts {
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// HubSnapshot is one cached poll result for a remote cluster. Payload is
+// the cluster summary as served by the remote API.
+type HubSnapshot struct {
+	Cluster   string          `json:"cluster"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// HubStore caches remote cluster summaries polled in hub mode so the fleet
+// view survives restarts and keeps per-cluster history.
+type HubStore struct {
+	db *sql.DB
+}
+
+// NewHubStore creates a HubStore. db may be nil (all ops become no-ops).
+func NewHubStore(db *sql.DB) *HubStore {
+	return &HubStore{db: db}
+}
+
+// RecordSnapshot inserts a successful poll result for cluster.
+func (s *HubStore) RecordSnapshot(cluster string, ts time.Time, payload []byte) {
+	if s == nil || s.db == nil {
+		return
+	}
+	if _, err := s.db.Exec(
+		"INSERT INTO hub_cluster_snapshots (cluster, timestamp, payload) VALUES (?, ?, ?)",
+		cluster, ts.Unix(), string(payload),
+	); err != nil {
+		slog.Error("hub snapshot: insert", "cluster", cluster, "error", err)
+	}
+}
+
+// Latest returns the most recent snapshot of every cluster, keyed by name.
+func (s *HubStore) Latest() map[string]HubSnapshot {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	rows, err := s.db.Query(
+		`SELECT cluster, timestamp, payload FROM hub_cluster_snapshots
+		 WHERE id IN (SELECT MAX(id) FROM hub_cluster_snapshots GROUP BY cluster)`,
+	)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	result := make(map[string]HubSnapshot)
+	for rows.Next() {
+		snap, ok := scanHubSnapshot(rows)
+		if !ok {
+			continue
+		}
+		result[snap.Cluster] = snap
+	}
+	return result
+}
+
+// History returns the snapshots of cluster recorded since the given time,
+// ordered by timestamp ascending.
+func (s *HubStore) History(cluster string, since time.Time) []HubSnapshot {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	rows, err := s.db.Query(
+		"SELECT cluster, timestamp, payload FROM hub_cluster_snapshots WHERE cluster = ? AND timestamp >= ? ORDER BY timestamp ASC, id ASC",
+		cluster, since.Unix(),
+	)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var result []HubSnapshot
+	for rows.Next() {
+		if snap, ok := scanHubSnapshot(rows); ok {
+			result = append(result, snap)
+		}
+	}
+	return result
+}
+
+func scanHubSnapshot(rows *sql.Rows) (HubSnapshot, bool) {
+	var snap HubSnapshot
+	var ts int64
+	var payload string
+	if err := rows.Scan(&snap.Cluster, &ts, &payload); err != nil {
+		return snap, false
+	}
+	snap.Timestamp = time.Unix(ts, 0)
+	snap.Payload = json.RawMessage(payload)
+	return snap, true
+}
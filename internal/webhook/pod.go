@@ -0,0 +1,181 @@
+// Package webhook serves koptimizer's mutating admission webhook for pods:
+// it applies rightsized and default requests at pod creation and checks
+// pods against the request policy.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/controller/rightsizer"
+)
+
+// PodMutatePath is where the pod webhook is served. The
+// MutatingWebhookConfiguration must point at it.
+const PodMutatePath = "/mutate-v1-pod"
+
+// RecommendationSource provides the latest recommended container requests
+// of a workload. The rightsizer controller implements it.
+type RecommendationSource interface {
+	RecommendedRequests(namespace, kind, name string) map[string]corev1.ResourceList
+}
+
+// PodMutator adjusts the requests of pods at creation and checks them
+// against the request policy. In order, it applies the changes recorded on
+// the owning workload under the nextRollout strategy, lowers requests to
+// the latest recommendation for opted-in workloads, and fills in default
+// requests. It only rejects pods for a policy violation with action
+// "deny"; when the owner cannot be resolved the pod is admitted unchanged.
+type PodMutator struct {
+	client  client.Client
+	config  *config.Config
+	source  RecommendationSource
+	rules   []Rule
+	decoder *admission.Decoder
+}
+
+// NewPodMutator returns a PodMutator. source may be nil when the rightsizer
+// is disabled; recommendations are then not injected.
+func NewPodMutator(c client.Client, scheme *runtime.Scheme, cfg *config.Config, source RecommendationSource) *PodMutator {
+	return &PodMutator{
+		client:  c,
+		config:  cfg,
+		source:  source,
+		rules:   PolicyRules(cfg.Webhook.Policy),
+		decoder: admission.NewDecoder(scheme),
+	}
+}
+
+// SetupWithManager registers the pod webhook on the manager's webhook
+// server, which starts it with the manager.
+func SetupWithManager(mgr ctrl.Manager, cfg *config.Config, source RecommendationSource) error {
+	mgr.GetWebhookServer().Register(PodMutatePath, &webhook.Admission{
+		Handler: NewPodMutator(mgr.GetClient(), mgr.GetScheme(), cfg, source),
+	})
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (m *PodMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+	logger := log.FromContext(ctx).WithName("pod-webhook")
+
+	pod := &corev1.Pod{}
+	if err := m.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// Pods created by controllers have no name or namespace yet, so the
+	// namespace comes from the request.
+	namespace := req.Namespace
+
+	var warnings []string
+	mutated := false
+
+	workload, kind, err := m.workload(ctx, namespace, pod)
+	if err != nil {
+		logger.V(1).Info("Resolving pod owner failed", "namespace", namespace, "error", err)
+	}
+	if workload != nil {
+		changed, err := rightsizer.ApplyPendingResources(pod, workload.GetAnnotations())
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("koptimizer: %v", err))
+		}
+		if len(changed) > 0 {
+			mutated = true
+			logger.V(1).Info("Applied pending rightsizing to new pod",
+				"namespace", namespace, "workload", workload.GetName(), "containers", changed)
+		}
+
+		if m.injects(pod) {
+			targets := m.source.RecommendedRequests(namespace, kind, workload.GetName())
+			if changed := rightsizer.InjectRequests(pod, targets); len(changed) > 0 {
+				mutated = true
+				logger.V(1).Info("Injected recommended requests into new pod",
+					"namespace", namespace, "workload", workload.GetName(), "containers", changed)
+			}
+		}
+	}
+
+	if changed := defaultRequests(pod, m.config.Webhook.DefaultCPURequest, m.config.Webhook.DefaultMemoryRequest); len(changed) > 0 {
+		mutated = true
+		warnings = append(warnings, fmt.Sprintf("koptimizer: set default requests on containers %s", strings.Join(changed, ", ")))
+	}
+
+	if violations := CheckPolicy(pod, m.rules); len(violations) > 0 {
+		if m.config.Webhook.Policy.Action == "deny" {
+			return admission.Denied("koptimizer request policy: " + strings.Join(violations, "; "))
+		}
+		for _, v := range violations {
+			warnings = append(warnings, "koptimizer request policy: "+v)
+		}
+	}
+
+	if !mutated {
+		return admission.Allowed("").WithWarnings(warnings...)
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw).WithWarnings(warnings...)
+}
+
+// injects reports whether recommended requests are injected into pod: the
+// feature is on, the rightsizer runs, and the pod's template opted in.
+func (m *PodMutator) injects(pod *corev1.Pod) bool {
+	return m.config.Webhook.InjectRecommendations && m.source != nil &&
+		pod.Annotations[rightsizer.AnnInjectRequests] == "true"
+}
+
+// workload returns the Deployment, StatefulSet or DaemonSet controlling
+// pod and its kind, or nil for pods without one. ReplicaSets resolve to
+// the Deployment that owns them.
+func (m *PodMutator) workload(ctx context.Context, namespace string, pod *corev1.Pod) (client.Object, string, error) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return nil, "", nil
+	}
+	switch ref.Kind {
+	case "ReplicaSet":
+		rs := &appsv1.ReplicaSet{}
+		if err := m.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, rs); err != nil {
+			return nil, "", fmt.Errorf("getting replicaset %s/%s: %w", namespace, ref.Name, err)
+		}
+		owner := metav1.GetControllerOf(rs)
+		if owner == nil || owner.Kind != "Deployment" {
+			return nil, "", nil
+		}
+		return m.get(ctx, &appsv1.Deployment{}, "Deployment", namespace, owner.Name)
+	case "StatefulSet":
+		return m.get(ctx, &appsv1.StatefulSet{}, ref.Kind, namespace, ref.Name)
+	case "DaemonSet":
+		return m.get(ctx, &appsv1.DaemonSet{}, ref.Kind, namespace, ref.Name)
+	default:
+		return nil, "", nil
+	}
+}
+
+func (m *PodMutator) get(ctx context.Context, obj client.Object, kind, namespace, name string) (client.Object, string, error) {
+	if err := m.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		return nil, "", fmt.Errorf("getting %s %s/%s: %w", kind, namespace, name, err)
+	}
+	return obj, kind, nil
+}
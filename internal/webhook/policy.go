@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/controller/gpu"
+)
+
+// Rule is one request policy check. Check returns a description of the
+// violation, or "" when pod complies.
+type Rule struct {
+	Name  string
+	Check func(pod *corev1.Pod) string
+}
+
+// PolicyRules returns the rules enabled by cfg.
+func PolicyRules(cfg config.WebhookPolicyConfig) []Rule {
+	var rules []Rule
+	if cfg.MaxCPULimitRatio > 0 {
+		rules = append(rules, Rule{Name: "cpu-limit-ratio", Check: limitRatioRule(corev1.ResourceCPU, cfg.MaxCPULimitRatio)})
+	}
+	if cfg.MaxMemoryLimitRatio > 0 {
+		rules = append(rules, Rule{Name: "memory-limit-ratio", Check: limitRatioRule(corev1.ResourceMemory, cfg.MaxMemoryLimitRatio)})
+	}
+	if cfg.GPUFallbackRequests {
+		rules = append(rules, Rule{Name: "gpu-fallback-requests", Check: func(pod *corev1.Pod) string {
+			if gpu.ViolatesFallbackPodPolicy(pod) {
+				return fmt.Sprintf("pods with PriorityClass %s must not list %s", pod.Spec.PriorityClassName, gpu.GPUResourceName)
+			}
+			return ""
+		}})
+	}
+	return rules
+}
+
+// CheckPolicy returns the violations of pod against rules, each prefixed
+// with the rule name.
+func CheckPolicy(pod *corev1.Pod, rules []Rule) []string {
+	var violations []string
+	for _, r := range rules {
+		if msg := r.Check(pod); msg != "" {
+			violations = append(violations, r.Name+": "+msg)
+		}
+	}
+	return violations
+}
+
+// limitRatioRule flags containers whose limit of res exceeds max times
+// their request. Containers without both are not checked.
+func limitRatioRule(res corev1.ResourceName, max float64) func(*corev1.Pod) string {
+	return func(pod *corev1.Pod) string {
+		for _, c := range pod.Spec.Containers {
+			req, hasReq := c.Resources.Requests[res]
+			limit, hasLimit := c.Resources.Limits[res]
+			if !hasReq || !hasLimit || req.IsZero() {
+				continue
+			}
+			ratio := limit.AsApproximateFloat64() / req.AsApproximateFloat64()
+			if ratio > max {
+				return fmt.Sprintf("container %s has a %s limit:request ratio of %.1f, above %.1f", c.Name, res, ratio, max)
+			}
+		}
+		return ""
+	}
+}
+
+// defaultRequests sets the CPU and memory requests of containers that have
+// neither a request nor a limit for the resource. Empty defaults are
+// skipped. It returns the names of the containers it changed.
+func defaultRequests(pod *corev1.Pod, cpu, memory string) []string {
+	defaults := corev1.ResourceList{}
+	if q, err := resource.ParseQuantity(cpu); err == nil && cpu != "" {
+		defaults[corev1.ResourceCPU] = q
+	}
+	if q, err := resource.ParseQuantity(memory); err == nil && memory != "" {
+		defaults[corev1.ResourceMemory] = q
+	}
+
+	var changed []string
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		updated := false
+		for name, def := range defaults {
+			if _, ok := c.Resources.Requests[name]; ok {
+				continue
+			}
+			// The API server defaults a missing request to the limit.
+			if _, ok := c.Resources.Limits[name]; ok {
+				continue
+			}
+			if c.Resources.Requests == nil {
+				c.Resources.Requests = corev1.ResourceList{}
+			}
+			c.Resources.Requests[name] = def
+			updated = true
+		}
+		if updated {
+			changed = append(changed, c.Name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,365 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/koptimizer/koptimizer/internal/config"
+	"github.com/koptimizer/koptimizer/internal/controller/gpu"
+	"github.com/koptimizer/koptimizer/internal/controller/rightsizer"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func controllerRef(kind, name string) metav1.OwnerReference {
+	isController := true
+	return metav1.OwnerReference{APIVersion: "apps/v1", Kind: kind, Name: name, UID: types.UID("uid-" + name), Controller: &isController}
+}
+
+func requests(cpu, mem string) corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{Requests: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(mem),
+	}}
+}
+
+// admissionRequest wraps pod in the request the API server sends for op.
+func admissionRequest(t *testing.T, op admissionv1.Operation, pod *corev1.Pod) admission.Request {
+	t.Helper()
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:       "req",
+		Operation: op,
+		Namespace: "default",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+// assertPatches checks that resp carries exactly the want operations.
+func assertPatches(t *testing.T, resp admission.Response, want []patchOp) {
+	t.Helper()
+	if len(resp.Patches) != len(want) {
+		t.Fatalf("patches = %+v, want %+v", resp.Patches, want)
+	}
+	byKey := map[string]interface{}{}
+	for _, p := range want {
+		byKey[p.op+" "+p.path] = p.value
+	}
+	for _, p := range resp.Patches {
+		if v, ok := byKey[p.Operation+" "+p.Path]; !ok || v != p.Value {
+			t.Errorf("unexpected patch %s %s %v", p.Operation, p.Path, p.Value)
+		}
+	}
+}
+
+// fakeSource serves fixed recommendations keyed by "namespace/kind/name".
+type fakeSource map[string]map[string]corev1.ResourceList
+
+func (f fakeSource) RecommendedRequests(namespace, kind, name string) map[string]corev1.ResourceList {
+	return f[namespace+"/"+kind+"/"+name]
+}
+
+// patchOp is the part of a JSON patch operation the tests compare.
+type patchOp struct {
+	op, path string
+	value    interface{}
+}
+
+// ---------------------------------------------------------------------------
+// Pending rightsizing
+// ---------------------------------------------------------------------------
+
+func TestPodMutator_PendingResources(t *testing.T) {
+	pending := `{"app":{"from":{"cpu":"1","memory":"2Gi"},"resources":{"requests":{"cpu":"500m","memory":"1Gi"}}}}`
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name: "web", Namespace: "default", UID: "uid-web",
+		Annotations: map[string]string{rightsizer.AnnPendingResources: pending},
+	}}
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name: "web-7d9f", Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{controllerRef("Deployment", "web")},
+	}}
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}}
+	scheme := testScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deploy, rs, sts).Build()
+	m := NewPodMutator(c, scheme, config.DefaultConfig(), nil)
+
+	pod := func(owner *metav1.OwnerReference, cpu string) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "web-"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: requests(cpu, "2Gi")}}},
+		}
+		if owner != nil {
+			p.OwnerReferences = []metav1.OwnerReference{*owner}
+		}
+		return p
+	}
+	rsRef := controllerRef("ReplicaSet", "web-7d9f")
+	stsRef := controllerRef("StatefulSet", "db")
+	missingRef := controllerRef("ReplicaSet", "gone")
+
+	tests := []struct {
+		name      string
+		op        admissionv1.Operation
+		pod       *corev1.Pod
+		wantPatch []patchOp
+	}{
+		{
+			name: "deployment pod gets the pending requests",
+			op:   admissionv1.Create,
+			pod:  pod(&rsRef, "1"),
+			wantPatch: []patchOp{
+				{op: "replace", path: "/spec/containers/0/resources/requests/cpu", value: "500m"},
+				{op: "replace", path: "/spec/containers/0/resources/requests/memory", value: "1Gi"},
+			},
+		},
+		{name: "template changed since", op: admissionv1.Create, pod: pod(&rsRef, "2")},
+		{name: "workload without pending change", op: admissionv1.Create, pod: pod(&stsRef, "1")},
+		{name: "bare pod", op: admissionv1.Create, pod: pod(nil, "1")},
+		{name: "owner not found", op: admissionv1.Create, pod: pod(&missingRef, "1")},
+		{name: "update", op: admissionv1.Update, pod: pod(&rsRef, "1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := m.Handle(context.Background(), admissionRequest(t, tt.op, tt.pod))
+			if !resp.Allowed {
+				t.Fatalf("pod denied: %v", resp.Result)
+			}
+			assertPatches(t, resp, tt.wantPatch)
+		})
+	}
+}
+
+func TestPodMutator_InvalidAnnotationWarns(t *testing.T) {
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{
+		Name: "agent", Namespace: "default",
+		Annotations: map[string]string{rightsizer.AnnPendingResources: "{"},
+	}}
+	scheme := testScheme(t)
+	m := NewPodMutator(fake.NewClientBuilder().WithScheme(scheme).WithObjects(ds).Build(), scheme, config.DefaultConfig(), nil)
+
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{controllerRef("DaemonSet", "agent")}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: requests("1", "1Gi")}}},
+	}
+	resp := m.Handle(context.Background(), admissionRequest(t, admissionv1.Create, p))
+	if !resp.Allowed || len(resp.Patches) != 0 {
+		t.Errorf("response = allowed %v with %d patches, want the pod admitted unchanged", resp.Allowed, len(resp.Patches))
+	}
+	if len(resp.Warnings) != 1 {
+		t.Errorf("warnings = %v, want one about the annotation", resp.Warnings)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Recommended requests
+// ---------------------------------------------------------------------------
+
+func TestPodMutator_InjectRecommendations(t *testing.T) {
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}}
+	scheme := testScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sts).Build()
+	source := fakeSource{"default/StatefulSet/db": {
+		"app": {corev1.ResourceCPU: resource.MustParse("250m"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+	}}
+
+	pod := func(optIn bool) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{controllerRef("StatefulSet", "db")}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: requests("1", "2Gi")}}},
+		}
+		if optIn {
+			p.Annotations = map[string]string{rightsizer.AnnInjectRequests: "true"}
+		}
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		source    RecommendationSource
+		pod       *corev1.Pod
+		wantPatch []patchOp
+	}{
+		{
+			name:   "opted-in pod gets the lower cpu, memory is never raised",
+			source: source,
+			pod:    pod(true),
+			wantPatch: []patchOp{
+				{op: "replace", path: "/spec/containers/0/resources/requests/cpu", value: "250m"},
+			},
+		},
+		{name: "pod not opted in", source: source, pod: pod(false)},
+		{name: "injection disabled", disabled: true, source: source, pod: pod(true)},
+		{name: "rightsizer not running", pod: pod(true)},
+		{name: "no recommendation", source: fakeSource{}, pod: pod(true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Webhook.InjectRecommendations = !tt.disabled
+			m := NewPodMutator(c, scheme, cfg, tt.source)
+			resp := m.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tt.pod))
+			if !resp.Allowed {
+				t.Fatalf("pod denied: %v", resp.Result)
+			}
+			assertPatches(t, resp, tt.wantPatch)
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Default requests
+// ---------------------------------------------------------------------------
+
+func TestPodMutator_DefaultRequests(t *testing.T) {
+	scheme := testScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	tests := []struct {
+		name      string
+		cpu, mem  string
+		resources corev1.ResourceRequirements
+		wantPatch []patchOp
+	}{
+		{
+			name: "no resources",
+			cpu:  "100m", mem: "128Mi",
+			wantPatch: []patchOp{
+				{op: "add", path: "/spec/containers/0/resources/requests", value: map[string]interface{}{"cpu": "100m", "memory": "128Mi"}},
+			},
+		},
+		{
+			name: "only a cpu request",
+			cpu:  "100m", mem: "128Mi",
+			resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+			wantPatch: []patchOp{
+				{op: "add", path: "/spec/containers/0/resources/requests/memory", value: "128Mi"},
+			},
+		},
+		{
+			name: "a memory limit stands in for the request",
+			cpu:  "100m", mem: "128Mi",
+			resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}},
+			wantPatch: []patchOp{
+				{op: "add", path: "/spec/containers/0/resources/requests", value: map[string]interface{}{"cpu": "100m"}},
+			},
+		},
+		{name: "defaults disabled"},
+		{name: "requests set", cpu: "100m", mem: "128Mi", resources: requests("1", "1Gi")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Webhook.DefaultCPURequest = tt.cpu
+			cfg.Webhook.DefaultMemoryRequest = tt.mem
+			m := NewPodMutator(c, scheme, cfg, nil)
+			p := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: tt.resources}}}}
+			resp := m.Handle(context.Background(), admissionRequest(t, admissionv1.Create, p))
+			if !resp.Allowed {
+				t.Fatalf("pod denied: %v", resp.Result)
+			}
+			if len(resp.Patches) != len(tt.wantPatch) {
+				t.Fatalf("patches = %+v, want %+v", resp.Patches, tt.wantPatch)
+			}
+			for i, want := range tt.wantPatch {
+				got := resp.Patches[i]
+				if got.Operation != want.op || got.Path != want.path || !reflect.DeepEqual(got.Value, want.value) {
+					t.Errorf("patch = %s %s %v, want %s %s %v", got.Operation, got.Path, got.Value, want.op, want.path, want.value)
+				}
+			}
+			if len(tt.wantPatch) > 0 && len(resp.Warnings) != 1 {
+				t.Errorf("warnings = %v, want one naming the defaulted containers", resp.Warnings)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Request policy
+// ---------------------------------------------------------------------------
+
+func TestPodMutator_Policy(t *testing.T) {
+	scheme := testScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	withLimits := func(cpuReq, cpuLim, memReq, memLim string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpuReq), corev1.ResourceMemory: resource.MustParse(memReq)},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpuLim), corev1.ResourceMemory: resource.MustParse(memLim)},
+		}
+	}
+	gpuZero := requests("1", "1Gi")
+	gpuZero.Requests[gpu.GPUResourceName] = resource.MustParse("0")
+
+	tests := []struct {
+		name          string
+		priorityClass string
+		resources     corev1.ResourceRequirements
+		wantViolation bool
+	}{
+		{name: "within ratios", resources: withLimits("1", "4", "1Gi", "2Gi")},
+		{name: "cpu ratio too high", resources: withLimits("100m", "2", "1Gi", "1Gi"), wantViolation: true},
+		{name: "memory ratio too high", resources: withLimits("1", "1", "256Mi", "1Gi"), wantViolation: true},
+		{name: "fallback pod listing gpus", priorityClass: gpu.GPUFallbackPriority, resources: gpuZero, wantViolation: true},
+		{name: "scavenger pod listing gpus", priorityClass: gpu.GPUScavengerPriority, resources: gpuZero, wantViolation: true},
+		{name: "regular pod listing gpus", resources: gpuZero},
+		{name: "fallback pod without gpus", priorityClass: gpu.GPUFallbackPriority, resources: requests("1", "1Gi")},
+	}
+	for _, action := range []string{"warn", "deny"} {
+		for _, tt := range tests {
+			t.Run(action+"/"+tt.name, func(t *testing.T) {
+				cfg := config.DefaultConfig()
+				cfg.Webhook.Policy.Action = action
+				cfg.Webhook.Policy.MaxCPULimitRatio = 4
+				cfg.Webhook.Policy.MaxMemoryLimitRatio = 2
+				m := NewPodMutator(c, scheme, cfg, nil)
+				p := &corev1.Pod{Spec: corev1.PodSpec{
+					PriorityClassName: tt.priorityClass,
+					Containers:        []corev1.Container{{Name: "app", Resources: tt.resources}},
+				}}
+				resp := m.Handle(context.Background(), admissionRequest(t, admissionv1.Create, p))
+
+				wantAllowed := !tt.wantViolation || action == "warn"
+				if resp.Allowed != wantAllowed {
+					t.Fatalf("allowed = %v, want %v (result %v)", resp.Allowed, wantAllowed, resp.Result)
+				}
+				wantWarnings := 0
+				if tt.wantViolation && action == "warn" {
+					wantWarnings = 1
+				}
+				if len(resp.Warnings) != wantWarnings {
+					t.Errorf("warnings = %v, want %d", resp.Warnings, wantWarnings)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package aigate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// AnthropicValidator asks Claude to review the change.
+type AnthropicValidator struct {
+	client *anthropic.Client
+	model  string
+	system string
+}
+
+// NewAnthropicValidator returns a validator using model. Without apiKey the
+// client reads ANTHROPIC_API_KEY.
+func NewAnthropicValidator(apiKey, model string) *AnthropicValidator {
+	var opts []option.RequestOption
+	if apiKey != "" {
+		opts = append(opts, option.WithAPIKey(apiKey))
+	}
+	client := anthropic.NewClient(opts...)
+	if model == "" {
+		model = DefaultModel
+	}
+	return &AnthropicValidator{client: &client, model: model, system: aiGateSystemPrompt}
+}
+
+// Name implements Validator.
+func (v *AnthropicValidator) Name() string { return ValidatorAnthropic }
+
+// Validate implements Validator.
+func (v *AnthropicValidator) Validate(ctx context.Context, req ValidationRequest) (*ValidationResponse, error) {
+	resp, err := v.client.Messages.New(ctx, anthropic.MessageNewParams{
+		Model:     anthropic.Model(v.model),
+		MaxTokens: int64(1024),
+		System: []anthropic.TextBlockParam{
+			{Text: v.system},
+		},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock(buildValidationPrompt(req))),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("calling Anthropic API: %w", err)
+	}
+	if len(resp.Content) == 0 {
+		return nil, fmt.Errorf("empty response from AI Gate")
+	}
+	result, err := parseValidationText(resp.Content[0].Text)
+	if err != nil {
+		return nil, err
+	}
+	result.Model = v.model
+	return result, nil
+}
@@ -0,0 +1,629 @@
+package aigate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a compiled rule condition. The language is a small subset
+// of CEL:
+//
+//	literals      1.5  "text"  'text'  true  false  ["a", "b"]
+//	variables     impact.nodesAffected  details.scalingType  riskFactors
+//	operators     ==  !=  <  <=  >  >=  in  &&  ||  !  -  ( )
+//	functions     number(x)  size(x)
+//	methods       s.contains(t)  s.startsWith(t)  s.endsWith(t)
+//
+// Numbers are float64. A map key that does not exist is null, which only
+// compares equal to null; "key" in map tests for it. && and || short-circuit.
+type Expression struct {
+	source string
+	root   node
+}
+
+// CompileExpression parses an expression.
+func CompileExpression(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string { return e.source }
+
+// Match evaluates the expression against vars and requires a boolean
+// result.
+func (e *Expression) Match(vars map[string]any) (bool, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression yields %s, not a boolean", typeName(v))
+	}
+	return b, nil
+}
+
+// ---------------------------------------------------------------------------
+// Lexer
+// ---------------------------------------------------------------------------
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string // operator or identifier text; unquoted string value
+	num  float64
+	pos  int
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+				i++
+			}
+			n, err := strconv.ParseFloat(s[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at offset %d", s[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: s[start:i], num: n, pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for ; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: start})
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(s) && (s[i] == '_' || unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: s[start:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "-", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, text: "end of expression", pos: len(s)}), nil
+}
+
+// ---------------------------------------------------------------------------
+// Parser
+// ---------------------------------------------------------------------------
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator op.
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected %q, got %q at offset %d", op, t.text, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	op := ""
+	switch {
+	case t.kind == tokOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+		op = t.text
+	case t.kind == tokIdent && t.text == "in":
+		op = "in"
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch {
+	case p.accept("!"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	case p.accept("-"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(".") {
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, fmt.Errorf("expected a field or method name at offset %d", name.pos)
+		}
+		if p.accept("(") {
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			if !isMethod(name.text) {
+				return nil, fmt.Errorf("unknown method %q at offset %d", name.text, name.pos)
+			}
+			n = &callNode{name: name.text, args: append([]node{n}, args...)}
+			continue
+		}
+		n = &selectNode{operand: n, field: name.text}
+	}
+	return n, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &literalNode{value: t.num}, nil
+	case tokString:
+		return &literalNode{value: t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.accept("(") {
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			if t.text != "number" && t.text != "size" || len(args) != 1 {
+				return nil, fmt.Errorf("unknown function %s/%d at offset %d", t.text, len(args), t.pos)
+			}
+			return &callNode{name: t.text, args: args}, nil
+		}
+		return &varNode{name: t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items, err := p.parseArgs("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+// parseArgs parses a comma-separated list up to and including end.
+func (p *parser) parseArgs(end string) ([]node, error) {
+	var args []node
+	if p.accept(end) {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(end) {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isMethod(name string) bool {
+	return name == "contains" || name == "startsWith" || name == "endsWith"
+}
+
+// ---------------------------------------------------------------------------
+// Evaluation
+// ---------------------------------------------------------------------------
+
+type node interface {
+	eval(vars map[string]any) (any, error)
+}
+
+type literalNode struct{ value any }
+
+func (n *literalNode) eval(map[string]any) (any, error) { return n.value, nil }
+
+type varNode struct{ name string }
+
+func (n *varNode) eval(vars map[string]any) (any, error) {
+	v, ok := vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %q", n.name)
+	}
+	return v, nil
+}
+
+type selectNode struct {
+	operand node
+	field   string
+}
+
+func (n *selectNode) eval(vars map[string]any) (any, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch m := v.(type) {
+	case map[string]any:
+		return m[n.field], nil
+	case map[string]string:
+		if s, ok := m[n.field]; ok {
+			return s, nil
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cannot select %q from %s", n.field, typeName(v))
+}
+
+type listNode struct{ items []node }
+
+func (n *listNode) eval(vars map[string]any) (any, error) {
+	out := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type notNode struct{ operand node }
+
+func (n *notNode) eval(vars map[string]any) (any, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("! needs a boolean, got %s", typeName(v))
+	}
+	return !b, nil
+}
+
+type negNode struct{ operand node }
+
+func (n *negNode) eval(vars map[string]any) (any, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("- needs a number, got %s", typeName(v))
+	}
+	return -f, nil
+}
+
+type logicalNode struct {
+	or          bool
+	left, right node
+}
+
+func (n *logicalNode) eval(vars map[string]any) (any, error) {
+	op := "&&"
+	if n.or {
+		op = "||"
+	}
+	for _, side := range []node{n.left, n.right} {
+		v, err := side.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs booleans, got %s", op, typeName(v))
+		}
+		if b == n.or {
+			return b, nil
+		}
+	}
+	return !n.or, nil
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(vars map[string]any) (any, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		switch c := r.(type) {
+		case []any:
+			for _, item := range c {
+				if equal(l, item) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]any:
+			key, ok := l.(string)
+			if !ok {
+				return nil, fmt.Errorf("in a map needs a string key, got %s", typeName(l))
+			}
+			_, found := c[key]
+			return found, nil
+		case map[string]string:
+			key, ok := l.(string)
+			if !ok {
+				return nil, fmt.Errorf("in a map needs a string key, got %s", typeName(l))
+			}
+			_, found := c[key]
+			return found, nil
+		}
+		return nil, fmt.Errorf("in needs a list or map, got %s", typeName(r))
+	}
+
+	var cmp int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number %s %s", n.op, typeName(r))
+		}
+		switch {
+		case lv < rv:
+			cmp = -1
+		case lv > rv:
+			cmp = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string %s %s", n.op, typeName(r))
+		}
+		cmp = strings.Compare(lv, rv)
+	default:
+		return nil, fmt.Errorf("cannot order %s", typeName(l))
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type callNode struct {
+	name string
+	args []node // the receiver first for methods
+}
+
+func (n *callNode) eval(vars map[string]any) (any, error) {
+	args := make([]any, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch n.name {
+	case "number":
+		switch v := args[0].(type) {
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("number(%q): not a number", v)
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("number() needs a string or number, got %s", typeName(args[0]))
+	case "size":
+		switch v := args[0].(type) {
+		case string:
+			return float64(len(v)), nil
+		case []any:
+			return float64(len(v)), nil
+		case map[string]any:
+			return float64(len(v)), nil
+		case map[string]string:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("size() needs a string, list or map, got %s", typeName(args[0]))
+	}
+
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s() takes one argument, got %d", n.name, len(args)-1)
+	}
+	s, ok1 := args[0].(string)
+	t, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s() needs strings, got %s and %s", n.name, typeName(args[0]), typeName(args[1]))
+	}
+	switch n.name {
+	case "contains":
+		return strings.Contains(s, t), nil
+	case "startsWith":
+		return strings.HasPrefix(s, t), nil
+	default:
+		return strings.HasSuffix(s, t), nil
+	}
+}
+
+func equal(a, b any) bool {
+	switch av := a.(type) {
+	case nil:
+		return b == nil
+	case float64, string, bool:
+		return a == b
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []any:
+		return "list"
+	case map[string]any, map[string]string:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -3,11 +3,10 @@ package aigate
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/koptimizer/koptimizer/pkg/optimizer"
 )
 
@@ -16,12 +15,15 @@ const (
 	DefaultTimeout = 10 * time.Second
 )
 
-// AIGate validates risky changes using Claude Sonnet before execution.
+// AIGate validates risky changes before execution. The decision comes from
+// a Validator: Claude by default, or a chain of a local rules engine and
+// model endpoints.
 type AIGate struct {
-	client  *anthropic.Client
-	model   string
-	enabled bool
-	timeout time.Duration
+	validator     Validator
+	enabled       bool
+	timeout       time.Duration
+	promptVersion string
+	recorder      Recorder
 
 	// Thresholds for triggering validation
 	CostThresholdUSD  float64 // Changes with impact > this amount require validation
@@ -38,6 +40,17 @@ type Config struct {
 	CostThresholdUSD  float64
 	ScaleThresholdPct float64
 	MaxEvictNodes     int
+
+	// Validators names the validators asked in order until one decides:
+	// ValidatorRules, ValidatorAnthropic and ValidatorOpenAI. Empty means
+	// Anthropic only.
+	Validators []string
+	Rules      []Rule
+	OpenAI     OpenAIConfig
+
+	// SystemPrompt replaces the built-in system prompt of the model
+	// validators. Decisions record it as PromptVersion.
+	SystemPrompt string
 }
 
 // NewAIGate creates a new AI Safety Gate.
@@ -46,16 +59,37 @@ func NewAIGate(cfg Config) (*AIGate, error) {
 		return &AIGate{enabled: false}, nil
 	}
 
-	var opts []option.RequestOption
-	if cfg.APIKey != "" {
-		opts = append(opts, option.WithAPIKey(cfg.APIKey))
+	names := cfg.Validators
+	if len(names) == 0 {
+		names = []string{ValidatorAnthropic}
 	}
-	client := anthropic.NewClient(opts...)
-	clientPtr := &client
-
-	model := cfg.Model
-	if model == "" {
-		model = DefaultModel
+	system := cfg.SystemPrompt
+	if system == "" {
+		system = aiGateSystemPrompt
+	}
+	var chain Chain
+	for _, name := range names {
+		switch name {
+		case ValidatorRules:
+			rules, err := NewRulesValidator(cfg.Rules)
+			if err != nil {
+				return nil, fmt.Errorf("compiling AI Gate rules: %w", err)
+			}
+			chain = append(chain, rules)
+		case ValidatorAnthropic:
+			v := NewAnthropicValidator(cfg.APIKey, cfg.Model)
+			v.system = system
+			chain = append(chain, v)
+		case ValidatorOpenAI:
+			if cfg.OpenAI.BaseURL == "" || cfg.OpenAI.Model == "" {
+				return nil, fmt.Errorf("the openai validator needs a base URL and a model")
+			}
+			v := NewOpenAIValidator(cfg.OpenAI)
+			v.system = system
+			chain = append(chain, v)
+		default:
+			return nil, fmt.Errorf("unknown AI Gate validator %q", name)
+		}
 	}
 
 	timeout := cfg.Timeout
@@ -79,52 +113,95 @@ func NewAIGate(cfg Config) (*AIGate, error) {
 	}
 
 	return &AIGate{
-		client:            clientPtr,
-		model:             model,
+		validator:         chain,
 		enabled:           true,
 		timeout:           timeout,
+		promptVersion:     PromptVersion(system),
 		CostThresholdUSD:  costThreshold,
 		ScaleThresholdPct: scaleThreshold,
 		MaxEvictNodes:     maxEvictNodes,
 	}, nil
 }
 
+// NewAIGateWithValidator creates an enabled gate deciding with v and the
+// default thresholds.
+func NewAIGateWithValidator(v Validator) *AIGate {
+	return &AIGate{
+		validator:         v,
+		enabled:           true,
+		timeout:           DefaultTimeout,
+		promptVersion:     BuiltinPromptVersion,
+		CostThresholdUSD:  500.0,
+		ScaleThresholdPct: 30.0,
+		MaxEvictNodes:     3,
+	}
+}
+
 // ValidationRequest contains all context needed for AI validation.
 type ValidationRequest struct {
-	Action         string
-	ClusterContext ClusterSummary
-	Recommendation optimizer.Recommendation
-	RiskFactors    []string
+	Action         string                   `json:"action"`
+	ClusterContext ClusterSummary           `json:"clusterContext"`
+	Recommendation optimizer.Recommendation `json:"recommendation"`
+	RiskFactors    []string                 `json:"riskFactors"`
+
+	// Workloads the change affects, with their disruption budgets and health.
+	Workloads []WorkloadContext `json:"workloads,omitempty"`
+	// RecentEvents are recent audit events on the change's target.
+	RecentEvents []string `json:"recentEvents,omitempty"`
+
+	// Time is when the change is validated, for the business-hours context.
+	// Zero means now; replays of recorded decisions keep the original time.
+	Time time.Time `json:"time"`
 }
 
 // ClusterSummary provides cluster context for the AI gate.
 type ClusterSummary struct {
-	TotalNodes           int
-	TotalNodeGroups      int
-	AvgCPUUtilization    float64
-	AvgMemoryUtilization float64
-	MonthlyCostUSD       float64
-	ActiveCommitments    int
-	NodeGroupSummaries   []NodeGroupSummary
+	TotalNodes           int                `json:"totalNodes"`
+	TotalNodeGroups      int                `json:"totalNodeGroups"`
+	AvgCPUUtilization    float64            `json:"avgCPUUtilization"`
+	AvgMemoryUtilization float64            `json:"avgMemoryUtilization"`
+	MonthlyCostUSD       float64            `json:"monthlyCostUSD"`
+	ActiveCommitments    int                `json:"activeCommitments"`
+	PendingPods          int                `json:"pendingPods"` // Pods waiting to be scheduled
+	NodeGroupSummaries   []NodeGroupSummary `json:"nodeGroupSummaries,omitempty"`
 }
 
 // NodeGroupSummary provides node group context.
 type NodeGroupSummary struct {
-	Name           string
-	InstanceType   string
-	CurrentCount   int
-	MinCount       int
-	MaxCount       int
-	UtilizationPct float64
+	Name           string  `json:"name"`
+	InstanceType   string  `json:"instanceType"`
+	CurrentCount   int     `json:"currentCount"`
+	MinCount       int     `json:"minCount"`
+	MaxCount       int     `json:"maxCount"`
+	UtilizationPct float64 `json:"utilizationPct"`
+}
+
+// WorkloadContext describes a workload affected by the change.
+type WorkloadContext struct {
+	Namespace             string `json:"namespace"`
+	Kind                  string `json:"kind"`
+	Name                  string `json:"name"`
+	Replicas              int    `json:"replicas"`
+	ReadyReplicas         int    `json:"readyReplicas"`
+	PendingPods           int    `json:"pendingPods"`
+	Restarts              int    `json:"restarts"`       // Container restarts of its current pods
+	RecentOOMKills        int    `json:"recentOOMKills"` // Containers OOM killed in the last day
+	PDB                   string `json:"pdb,omitempty"`  // PodDisruptionBudget covering its pods
+	PDBDisruptionsAllowed int    `json:"pdbDisruptionsAllowed"`
+	HelmDrift             string `json:"helmDrift,omitempty"` // Fields that differ from its Helm values
 }
 
-// ValidationResponse is the parsed response from Claude Sonnet.
+// ValidationResponse is the decision of a validator.
 type ValidationResponse struct {
 	Approved   bool     `json:"approved"`
 	Confidence float64  `json:"confidence"`
 	Reasoning  string   `json:"reasoning"`
 	Warnings   []string `json:"warnings"`
 	Suggestion string   `json:"suggestion"`
+	Validator  string   `json:"validator,omitempty"` // Validator that decided
+	Rule       string   `json:"rule,omitempty"`      // Rule that decided, for the rules validator
+	Model      string   `json:"model,omitempty"`     // Model that decided, for model validators
+	Raw        string   `json:"-"`                   // Model output the decision was parsed from
 }
 
 // RequiresValidation checks if a recommendation needs AI Gate validation.
@@ -145,10 +222,12 @@ func (g *AIGate) RequiresValidation(rec optimizer.Recommendation) bool {
 	return rec.RequiresAIGate
 }
 
-// Validate sends the change to Claude Sonnet for review.
-// If Sonnet rejects, the change becomes a recommendation (human must approve).
-// If Sonnet approves, the change proceeds automatically.
-// If the API is unreachable or the gate is nil, falls back to recommendation mode (reject).
+// Validate asks the gate's validators to review the change.
+// If a validator rejects, the change becomes a recommendation (human must approve).
+// If a validator approves, the change proceeds automatically.
+// If no validator decides, one fails or is unreachable, or the gate is nil,
+// falls back to recommendation mode (reject). Decisions of an enabled gate
+// are passed to its Recorder.
 func (g *AIGate) Validate(ctx context.Context, req ValidationRequest) (*ValidationResponse, error) {
 	if g == nil {
 		return &ValidationResponse{
@@ -167,42 +246,55 @@ func (g *AIGate) Validate(ctx context.Context, req ValidationRequest) (*Validati
 		}, nil
 	}
 
+	if req.Time.IsZero() {
+		req.Time = time.Now()
+	}
 	ctx, cancel := context.WithTimeout(ctx, g.timeout)
 	defer cancel()
 
-	prompt := buildValidationPrompt(req)
-
-	resp, err := g.client.Messages.New(ctx, anthropic.MessageNewParams{
-		Model:     anthropic.Model(g.model),
-		MaxTokens: int64(1024),
-		System: []anthropic.TextBlockParam{
-			{Text: aiGateSystemPrompt},
-		},
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
-		},
-	})
+	start := time.Now()
+	resp, err := g.validator.Validate(ctx, req)
+	latency := time.Since(start)
+	resp = decision(resp, err)
+	g.record(req, resp, err, latency)
+	return resp, nil
+}
+
+// decision returns the gate's answer for a validator's resp and err.
+func decision(resp *ValidationResponse, err error) *ValidationResponse {
+	if errors.Is(err, ErrNoDecision) {
+		return &ValidationResponse{
+			Approved:   false,
+			Confidence: 0,
+			Reasoning:  "No AI Gate validator reached a decision, requiring manual approval",
+			Warnings:   []string{"No AI Gate rule matched and no model validator is configured"},
+		}
+	}
 	if err != nil {
-		// Fallback: if API unreachable, require human approval
+		// Fallback: if a validator fails or is unreachable, require human approval
 		return &ValidationResponse{
 			Approved:   false,
 			Confidence: 0,
-			Reasoning:  fmt.Sprintf("AI Gate API error (falling back to manual approval): %v", err),
+			Reasoning:  fmt.Sprintf("AI Gate error (falling back to manual approval): %v", err),
 			Warnings:   []string{"AI Gate unavailable, requiring manual approval"},
-		}, nil
+		}
 	}
-
-	return parseValidationResponse(resp)
+	return resp
 }
 
-// parseValidationResponse extracts the structured response from Claude's output.
-func parseValidationResponse(resp *anthropic.Message) (*ValidationResponse, error) {
-	if len(resp.Content) == 0 {
-		return nil, fmt.Errorf("empty response from AI Gate")
+// parseValidationText extracts the structured response from a model's output.
+// Validator, Rule and Model are set by the gate, never by the model.
+func parseValidationText(text string) (*ValidationResponse, error) {
+	result, err := unmarshalValidation(text)
+	if err != nil {
+		return nil, err
 	}
+	result.Validator, result.Rule, result.Model = "", "", ""
+	result.Raw = text
+	return result, nil
+}
 
-	text := resp.Content[0].Text
-
+func unmarshalValidation(text string) (*ValidationResponse, error) {
 	var result ValidationResponse
 	if err := json.Unmarshal([]byte(text), &result); err != nil {
 		// Try to extract JSON from the response if it's wrapped in markdown
@@ -0,0 +1,83 @@
+package aigate
+
+import (
+	"time"
+)
+
+// Outcomes of an approved change, reported once its effect is known.
+const (
+	OutcomeSuccess    = "success"     // No regression within the watch window
+	OutcomeRolledBack = "rolled-back" // Reverted after a regression
+	OutcomeOOM        = "oom"         // Reverted after OOM kills
+)
+
+// Decision is one recorded gate decision: the request, the prompt a model
+// validator was sent, what it answered and, later, what happened.
+type Decision struct {
+	ID               int64              `json:"id"`
+	Timestamp        time.Time          `json:"timestamp"`
+	RecommendationID string             `json:"recommendationId"`
+	Request          ValidationRequest  `json:"request"`
+	Prompt           string             `json:"prompt"`
+	PromptVersion    string             `json:"promptVersion"`
+	Response         ValidationResponse `json:"response"`
+	RawOutput        string             `json:"rawOutput,omitempty"`
+	Error            string             `json:"error,omitempty"` // Validator error the gate fell back on
+	LatencyMs        int64              `json:"latencyMs"`
+	Outcome          string             `json:"outcome,omitempty"`
+	OutcomeDetail    string             `json:"outcomeDetail,omitempty"`
+	OutcomeAt        *time.Time         `json:"outcomeAt,omitempty"`
+}
+
+// Recorder persists gate decisions and their outcomes.
+type Recorder interface {
+	RecordDecision(d Decision)
+	// RecordOutcome sets the outcome of the decisions on recommendationID.
+	RecordOutcome(recommendationID, outcome, detail string, at time.Time)
+}
+
+// SetRecorder makes the gate pass every decision to r. Gates used for
+// replays have none, so replayed decisions are never recorded.
+func (g *AIGate) SetRecorder(r Recorder) {
+	if g != nil {
+		g.recorder = r
+	}
+}
+
+// PromptVersion returns the version of the system prompt the gate's model
+// validators use.
+func (g *AIGate) PromptVersion() string {
+	if g == nil {
+		return ""
+	}
+	return g.promptVersion
+}
+
+// ReportOutcome records what happened after the change of recommendationID
+// was applied. Safe to call on a nil gate or one without a Recorder.
+func (g *AIGate) ReportOutcome(recommendationID, outcome, detail string) {
+	if g == nil || g.recorder == nil || recommendationID == "" {
+		return
+	}
+	g.recorder.RecordOutcome(recommendationID, outcome, detail, time.Now())
+}
+
+func (g *AIGate) record(req ValidationRequest, resp *ValidationResponse, err error, latency time.Duration) {
+	if g.recorder == nil {
+		return
+	}
+	d := Decision{
+		Timestamp:        req.Time,
+		RecommendationID: req.Recommendation.ID,
+		Request:          req,
+		Prompt:           buildValidationPrompt(req),
+		PromptVersion:    g.promptVersion,
+		Response:         *resp,
+		RawOutput:        resp.Raw,
+		LatencyMs:        latency.Milliseconds(),
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	g.recorder.RecordDecision(d)
+}
@@ -0,0 +1,112 @@
+package aigate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAIConfig configures a validator for an OpenAI-compatible chat
+// completions endpoint, such as vLLM, Ollama or LiteLLM serving a
+// self-hosted model.
+type OpenAIConfig struct {
+	BaseURL string // API base URL, e.g. "http://vllm.ai.svc:8000/v1"
+	Model   string
+	APIKey  string // Sent as a bearer token when set
+}
+
+// OpenAIValidator asks a model behind an OpenAI-compatible API to review
+// the change, with the same prompt as the Anthropic validator.
+type OpenAIValidator struct {
+	cfg    OpenAIConfig
+	system string
+	client *http.Client
+}
+
+func NewOpenAIValidator(cfg OpenAIConfig) *OpenAIValidator {
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	return &OpenAIValidator{cfg: cfg, system: aiGateSystemPrompt, client: &http.Client{}}
+}
+
+// Name implements Validator.
+func (v *OpenAIValidator) Name() string { return ValidatorOpenAI }
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Validate implements Validator. The request deadline is the caller's.
+func (v *OpenAIValidator) Validate(ctx context.Context, req ValidationRequest) (*ValidationResponse, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: v.cfg.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: v.system},
+			{Role: "user", Content: buildValidationPrompt(req)},
+		},
+		MaxTokens:   1024,
+		Temperature: 0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling chat request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, v.cfg.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("building chat request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if v.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+v.cfg.APIKey)
+	}
+
+	resp, err := v.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("calling %s: %w", v.cfg.BaseURL, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("reading chat response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat completions returned %s: %s", resp.Status, truncate(string(raw), 200))
+	}
+
+	var chat chatResponse
+	if err := json.Unmarshal(raw, &chat); err != nil {
+		return nil, fmt.Errorf("parsing chat response: %w", err)
+	}
+	if len(chat.Choices) == 0 || chat.Choices[0].Message.Content == "" {
+		return nil, fmt.Errorf("empty response from AI Gate")
+	}
+	result, err := parseValidationText(chat.Choices[0].Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	result.Model = v.cfg.Model
+	return result, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -1,6 +1,8 @@
 package aigate
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"time"
@@ -31,6 +33,20 @@ Respond in the following JSON format:
     "suggestion": "alternative approach if rejected, empty if approved"
 }`
 
+// BuiltinPromptVersion identifies the built-in system prompt in recorded
+// decisions. Bump it whenever the prompt or buildValidationPrompt changes.
+const BuiltinPromptVersion = "builtin-v2"
+
+// PromptVersion identifies the system prompt a decision was made with:
+// BuiltinPromptVersion, or "custom-" and a hash of a replacement prompt.
+func PromptVersion(system string) string {
+	if system == "" || system == aiGateSystemPrompt {
+		return BuiltinPromptVersion
+	}
+	sum := sha256.Sum256([]byte(system))
+	return "custom-" + hex.EncodeToString(sum[:4])
+}
+
 // buildValidationPrompt constructs the prompt sent to Claude Sonnet for validation.
 func buildValidationPrompt(req ValidationRequest) string {
 	var b strings.Builder
@@ -46,6 +62,7 @@ func buildValidationPrompt(req ValidationRequest) string {
 	b.WriteString(fmt.Sprintf("- Average memory utilization: %.1f%%\n", req.ClusterContext.AvgMemoryUtilization))
 	b.WriteString(fmt.Sprintf("- Monthly cost: $%.2f\n", req.ClusterContext.MonthlyCostUSD))
 	b.WriteString(fmt.Sprintf("- Active commitments (RIs/SPs): %d\n", req.ClusterContext.ActiveCommitments))
+	b.WriteString(fmt.Sprintf("- Pending pods: %d\n", req.ClusterContext.PendingPods))
 	b.WriteString("\n")
 
 	if len(req.ClusterContext.NodeGroupSummaries) > 0 {
@@ -74,6 +91,32 @@ func buildValidationPrompt(req ValidationRequest) string {
 		b.WriteString("\n")
 	}
 
+	if len(req.Workloads) > 0 {
+		b.WriteString("### Affected Workloads\n")
+		for _, wl := range req.Workloads {
+			b.WriteString(fmt.Sprintf("- %s %s/%s: replicas=%d, ready=%d, pending=%d, restarts=%d, OOM kills (24h)=%d",
+				wl.Kind, wl.Namespace, wl.Name, wl.Replicas, wl.ReadyReplicas, wl.PendingPods, wl.Restarts, wl.RecentOOMKills))
+			if wl.PDB != "" {
+				b.WriteString(fmt.Sprintf(", PDB=%s (disruptions allowed: %d)", wl.PDB, wl.PDBDisruptionsAllowed))
+			} else {
+				b.WriteString(", no PDB")
+			}
+			if wl.HelmDrift != "" {
+				b.WriteString(fmt.Sprintf(", Helm drift: %s", wl.HelmDrift))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(req.RecentEvents) > 0 {
+		b.WriteString("### Recent Events on the Target\n")
+		for _, ev := range req.RecentEvents {
+			b.WriteString(fmt.Sprintf("- %s\n", ev))
+		}
+		b.WriteString("\n")
+	}
+
 	if len(req.RiskFactors) > 0 {
 		b.WriteString("### Risk Factors\n")
 		for _, rf := range req.RiskFactors {
@@ -87,7 +130,11 @@ func buildValidationPrompt(req ValidationRequest) string {
 	if loc == nil {
 		loc = time.UTC
 	}
-	now := time.Now().In(loc)
+	now := req.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.In(loc)
 	b.WriteString(fmt.Sprintf("### Context\n"))
 	b.WriteString(fmt.Sprintf("- Current time: %s\n", now.Format(time.RFC3339)))
 	b.WriteString(fmt.Sprintf("- Timezone: %s\n", loc.String()))
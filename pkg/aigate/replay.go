@@ -0,0 +1,84 @@
+package aigate
+
+import (
+	"context"
+)
+
+// ReplayResult puts a recorded decision next to the decision a replay gate
+// made on the same request.
+type ReplayResult struct {
+	DecisionID       int64              `json:"decisionId"`
+	RecommendationID string             `json:"recommendationId"`
+	Summary          string             `json:"summary"`
+	Outcome          string             `json:"outcome,omitempty"`
+	Original         ValidationResponse `json:"original"`
+	Replayed         ValidationResponse `json:"replayed"`
+	Changed          bool               `json:"changed"` // The approval differs
+}
+
+// ReplayReport summarizes a replay. Outcomes are only known for changes
+// that were applied, so the outcome counts compare the two gates on those.
+type ReplayReport struct {
+	PromptVersion string `json:"promptVersion"`
+	Total         int    `json:"total"`
+	Changed       int    `json:"changed"`
+	NewlyApproved int    `json:"newlyApproved"`
+	NewlyRejected int    `json:"newlyRejected"`
+
+	// Approvals of changes that were later rolled back or OOM killed.
+	OriginalApprovedFailures int `json:"originalApprovedFailures"`
+	ReplayedApprovedFailures int `json:"replayedApprovedFailures"`
+	// Rejections of changes that succeeded once a human approved them.
+	OriginalRejectedSuccesses int `json:"originalRejectedSuccesses"`
+	ReplayedRejectedSuccesses int `json:"replayedRejectedSuccesses"`
+
+	Results []ReplayResult `json:"results"`
+}
+
+// Replay asks g to decide the requests of decisions again, at their
+// original time, and diffs the answers. g should have no Recorder.
+func Replay(ctx context.Context, g *AIGate, decisions []Decision) *ReplayReport {
+	report := &ReplayReport{PromptVersion: g.PromptVersion(), Results: []ReplayResult{}}
+	for _, d := range decisions {
+		if ctx.Err() != nil {
+			break
+		}
+		replayed, _ := g.Validate(ctx, d.Request)
+		res := ReplayResult{
+			DecisionID:       d.ID,
+			RecommendationID: d.RecommendationID,
+			Summary:          d.Request.Recommendation.Summary,
+			Outcome:          d.Outcome,
+			Original:         d.Response,
+			Replayed:         *replayed,
+			Changed:          replayed.Approved != d.Response.Approved,
+		}
+		report.Total++
+		if res.Changed {
+			report.Changed++
+			if replayed.Approved {
+				report.NewlyApproved++
+			} else {
+				report.NewlyRejected++
+			}
+		}
+		switch d.Outcome {
+		case OutcomeRolledBack, OutcomeOOM:
+			if d.Response.Approved {
+				report.OriginalApprovedFailures++
+			}
+			if replayed.Approved {
+				report.ReplayedApprovedFailures++
+			}
+		case OutcomeSuccess:
+			if !d.Response.Approved {
+				report.OriginalRejectedSuccesses++
+			}
+			if !replayed.Approved {
+				report.ReplayedRejectedSuccesses++
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
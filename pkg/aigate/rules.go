@@ -0,0 +1,178 @@
+package aigate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Rule decisions.
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// Rule approves or rejects the changes its When expression matches. See
+// Expression for the language and RequestVars for the variables.
+type Rule struct {
+	Name     string
+	When     string
+	Decision string // DecisionApprove or DecisionReject
+	Reason   string
+}
+
+type compiledRule struct {
+	Rule
+	expr *Expression
+}
+
+// RulesValidator is a deterministic policy engine: the first rule whose
+// expression matches decides. It needs no network access, so clusters
+// without a model endpoint still get automated, explainable gating.
+type RulesValidator struct {
+	rules []compiledRule
+	now   func() time.Time
+}
+
+// NewRulesValidator compiles rules, in order.
+func NewRulesValidator(rules []Rule) (*RulesValidator, error) {
+	v := &RulesValidator{now: time.Now}
+	for _, r := range rules {
+		if r.Decision != DecisionApprove && r.Decision != DecisionReject {
+			return nil, fmt.Errorf("rule %q: decision must be %q or %q, got %q", r.Name, DecisionApprove, DecisionReject, r.Decision)
+		}
+		expr, err := CompileExpression(r.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		v.rules = append(v.rules, compiledRule{Rule: r, expr: expr})
+	}
+	return v, nil
+}
+
+// Name implements Validator.
+func (v *RulesValidator) Name() string { return ValidatorRules }
+
+// Validate implements Validator at req.Time, or now. It returns
+// ErrNoDecision when no rule matches, and an error when a rule cannot be
+// evaluated against req.
+func (v *RulesValidator) Validate(_ context.Context, req ValidationRequest) (*ValidationResponse, error) {
+	now := req.Time
+	if now.IsZero() {
+		now = v.now()
+	}
+	vars := RequestVars(req, now)
+	for _, r := range v.rules {
+		ok, err := r.expr.Match(vars)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		resp := &ValidationResponse{
+			Approved:   r.Decision == DecisionApprove,
+			Confidence: 1,
+			Reasoning:  fmt.Sprintf("Rule %q matched (%s)", r.Name, r.When),
+			Rule:       r.Name,
+		}
+		if r.Reason != "" {
+			resp.Reasoning += ": " + r.Reason
+		}
+		if !resp.Approved {
+			resp.Suggestion = "Approve the recommendation manually if the change is intended"
+		}
+		return resp, nil
+	}
+	return nil, ErrNoDecision
+}
+
+// RequestVars returns the variables rule expressions see for req at now:
+//
+//	action, summary, type, priority, autoExecutable,
+//	targetKind, targetName, targetNamespace, details.<key>, riskFactors,
+//	impact.monthlyCostChangeUSD, impact.nodesAffected, impact.podsAffected, impact.riskLevel,
+//	saving.monthlyUSD,
+//	cluster.totalNodes, cluster.totalNodeGroups, cluster.avgCPUUtilization,
+//	cluster.avgMemoryUtilization, cluster.monthlyCostUSD, cluster.activeCommitments,
+//	cluster.pendingPods,
+//	workloads.count, workloads.minReplicas, workloads.pdbBlocked, workloads.recentOOMKills,
+//	workloads.restarts, workloads.helmDrift,
+//	time.hour, time.weekday, time.businessHours
+//
+// Times are in Timezone, or UTC.
+func RequestVars(req ValidationRequest, now time.Time) map[string]any {
+	loc := Timezone
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	rec := req.Recommendation
+
+	details := make(map[string]any, len(rec.Details))
+	for k, v := range rec.Details {
+		details[k] = v
+	}
+	risks := make([]any, len(req.RiskFactors))
+	for i, r := range req.RiskFactors {
+		risks[i] = r
+	}
+	c := req.ClusterContext
+
+	// Workloads are aggregated: the smallest replica count, and whether any
+	// PDB allows no disruption or any workload drifted from its Helm values.
+	var minReplicas, oomKills, restarts int
+	var pdbBlocked, helmDrift bool
+	for i, wl := range req.Workloads {
+		if i == 0 || wl.Replicas < minReplicas {
+			minReplicas = wl.Replicas
+		}
+		oomKills += wl.RecentOOMKills
+		restarts += wl.Restarts
+		pdbBlocked = pdbBlocked || (wl.PDB != "" && wl.PDBDisruptionsAllowed == 0)
+		helmDrift = helmDrift || wl.HelmDrift != ""
+	}
+	return map[string]any{
+		"action":          req.Action,
+		"summary":         rec.Summary,
+		"type":            string(rec.Type),
+		"priority":        string(rec.Priority),
+		"autoExecutable":  rec.AutoExecutable,
+		"targetKind":      rec.TargetKind,
+		"targetName":      rec.TargetName,
+		"targetNamespace": rec.TargetNamespace,
+		"details":         details,
+		"riskFactors":     risks,
+		"impact": map[string]any{
+			"monthlyCostChangeUSD": rec.EstimatedImpact.MonthlyCostChangeUSD,
+			"nodesAffected":        float64(rec.EstimatedImpact.NodesAffected),
+			"podsAffected":         float64(rec.EstimatedImpact.PodsAffected),
+			"riskLevel":            rec.EstimatedImpact.RiskLevel,
+		},
+		"saving": map[string]any{
+			"monthlyUSD": rec.EstimatedSaving.MonthlySavingsUSD,
+		},
+		"cluster": map[string]any{
+			"totalNodes":           float64(c.TotalNodes),
+			"totalNodeGroups":      float64(c.TotalNodeGroups),
+			"avgCPUUtilization":    c.AvgCPUUtilization,
+			"avgMemoryUtilization": c.AvgMemoryUtilization,
+			"monthlyCostUSD":       c.MonthlyCostUSD,
+			"activeCommitments":    float64(c.ActiveCommitments),
+			"pendingPods":          float64(c.PendingPods),
+		},
+		"workloads": map[string]any{
+			"count":          float64(len(req.Workloads)),
+			"minReplicas":    float64(minReplicas),
+			"pdbBlocked":     pdbBlocked,
+			"recentOOMKills": float64(oomKills),
+			"restarts":       float64(restarts),
+			"helmDrift":      helmDrift,
+		},
+		"time": map[string]any{
+			"hour":          float64(now.Hour()),
+			"weekday":       now.Weekday().String(),
+			"businessHours": isBusinessHours(now),
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package aigate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Validator names, as used in Config.Validators.
+const (
+	ValidatorRules     = "rules"
+	ValidatorAnthropic = "anthropic"
+	ValidatorOpenAI    = "openai"
+)
+
+// ErrNoDecision is returned by a Validator that leaves the decision to the
+// next validator of a chain, like the rules validator when no rule matches.
+var ErrNoDecision = errors.New("no decision")
+
+// Validator decides whether a change may proceed without human approval.
+type Validator interface {
+	// Name identifies the validator in responses and logs.
+	Name() string
+	// Validate returns the decision on req, or ErrNoDecision to abstain.
+	Validate(ctx context.Context, req ValidationRequest) (*ValidationResponse, error)
+}
+
+// Chain asks its validators in order and returns the first decision.
+type Chain []Validator
+
+// Name implements Validator.
+func (c Chain) Name() string { return "chain" }
+
+// Validate implements Validator. An error from a validator ends the chain:
+// the gate then falls back to manual approval instead of asking a more
+// permissive validator. It returns ErrNoDecision when all abstain.
+func (c Chain) Validate(ctx context.Context, req ValidationRequest) (*ValidationResponse, error) {
+	for _, v := range c {
+		resp, err := v.Validate(ctx, req)
+		if errors.Is(err, ErrNoDecision) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s validator: %w", v.Name(), err)
+		}
+		if resp.Validator == "" {
+			resp.Validator = v.Name()
+		}
+		return resp, nil
+	}
+	return nil, ErrNoDecision
+}
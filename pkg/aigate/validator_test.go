@@ -0,0 +1,442 @@
+package aigate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koptimizer/koptimizer/pkg/optimizer"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+func scaleRequest(nodes int, costChange float64) ValidationRequest {
+	return ValidationRequest{
+		Action: "Scale node group workers down",
+		Recommendation: optimizer.Recommendation{
+			Type:       optimizer.RecommendationNodeScale,
+			TargetKind: "NodeGroup",
+			TargetName: "workers",
+			Summary:    "Scale workers from 6 to 4",
+			EstimatedImpact: optimizer.ImpactEstimate{
+				MonthlyCostChangeUSD: costChange,
+				NodesAffected:        nodes,
+				RiskLevel:            "medium",
+			},
+			Details: map[string]string{"desiredCount": "4"},
+		},
+		RiskFactors: []string{"Node group scaling"},
+	}
+}
+
+// fixedValidator returns resp and err, counting its calls.
+type fixedValidator struct {
+	name  string
+	resp  *ValidationResponse
+	err   error
+	calls int
+}
+
+func (v *fixedValidator) Name() string { return v.name }
+
+func (v *fixedValidator) Validate(context.Context, ValidationRequest) (*ValidationResponse, error) {
+	v.calls++
+	return v.resp, v.err
+}
+
+// ---------------------------------------------------------------------------
+// Expression Tests
+// ---------------------------------------------------------------------------
+
+func TestExpression_Match(t *testing.T) {
+	vars := RequestVars(scaleRequest(2, -300), time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC))
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`impact.nodesAffected == 2`, true},
+		{`impact.nodesAffected > 1 && impact.monthlyCostChangeUSD < -200`, true},
+		{`impact.monthlyCostChangeUSD >= -100 || type == "node-scale"`, true},
+		{`!(impact.riskLevel == "medium")`, false},
+		{`type in ["node-scale", "eviction"]`, true},
+		{`"Node group scaling" in riskFactors`, true},
+		{`"desiredCount" in details && number(details.desiredCount) <= 4`, true},
+		{`details.missing == null`, true},
+		{`"missing" in details`, false},
+		{`summary.contains("workers") && targetName.startsWith("work") && targetName.endsWith("ers")`, true},
+		{`size(riskFactors) == 1`, true},
+		{`time.businessHours && time.weekday == 'Wednesday' && time.hour == 10`, true},
+		{`-impact.monthlyCostChangeUSD > 250`, true},
+		// Short-circuit skips the number() of a missing key.
+		{`"newReplicas" in details && number(details.newReplicas) > 10`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := CompileExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			got, err := e.Match(vars)
+			if err != nil {
+				t.Fatalf("match: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpression_Errors(t *testing.T) {
+	compileErrors := []string{
+		``,
+		`impact.nodesAffected >`,
+		`(type == "x"`,
+		`"unterminated`,
+		`type === "x"`,
+		`unknown(type)`,
+		`summary.lower()`,
+		`type == "x" extra`,
+	}
+	for _, src := range compileErrors {
+		if _, err := CompileExpression(src); err == nil {
+			t.Errorf("CompileExpression(%q) succeeded, want an error", src)
+		}
+	}
+
+	vars := RequestVars(scaleRequest(1, 0), time.Now())
+	evalErrors := []string{
+		`nosuch == 1`,
+		`type > 1`,
+		`number(summary) > 1`,
+		`impact.nodesAffected`,
+		`impact.nodesAffected && true`,
+		`details.missing < 1`,
+	}
+	for _, src := range evalErrors {
+		e, err := CompileExpression(src)
+		if err != nil {
+			t.Fatalf("CompileExpression(%q): %v", src, err)
+		}
+		if _, err := e.Match(vars); err == nil {
+			t.Errorf("Match(%q) succeeded, want an error", src)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rules Validator Tests
+// ---------------------------------------------------------------------------
+
+func TestRulesValidator(t *testing.T) {
+	v, err := NewRulesValidator([]Rule{
+		{Name: "multi-node", When: `impact.nodesAffected > 1`, Decision: DecisionReject, Reason: "needs a human"},
+		{Name: "small-savings", When: `impact.monthlyCostChangeUSD > -500`, Decision: DecisionApprove},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	resp, err := v.Validate(ctx, scaleRequest(3, -100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Approved || resp.Rule != "multi-node" || !strings.Contains(resp.Reasoning, "needs a human") {
+		t.Errorf("resp = %+v, want a rejection by multi-node", resp)
+	}
+
+	resp, err = v.Validate(ctx, scaleRequest(1, -100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Approved || resp.Rule != "small-savings" || resp.Confidence != 1 {
+		t.Errorf("resp = %+v, want an approval by small-savings", resp)
+	}
+
+	if _, err := v.Validate(ctx, scaleRequest(1, -900)); !errors.Is(err, ErrNoDecision) {
+		t.Errorf("err = %v, want ErrNoDecision when no rule matches", err)
+	}
+}
+
+func TestRulesValidator_WorkloadContext(t *testing.T) {
+	v, err := NewRulesValidator([]Rule{
+		{Name: "pdb", When: `workloads.pdbBlocked || workloads.minReplicas < 2`, Decision: DecisionReject, Reason: "disruptive"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := scaleRequest(1, -100)
+	req.Workloads = []WorkloadContext{
+		{Namespace: "shop", Kind: "Deployment", Name: "web", Replicas: 3, ReadyReplicas: 3, PDB: "web-pdb", PDBDisruptionsAllowed: 1},
+	}
+	if _, err := v.Validate(context.Background(), req); !errors.Is(err, ErrNoDecision) {
+		t.Errorf("err = %v, want ErrNoDecision while the PDB allows a disruption", err)
+	}
+
+	req.Workloads[0].PDBDisruptionsAllowed = 0
+	resp, err := v.Validate(context.Background(), req)
+	if err != nil || resp.Approved {
+		t.Errorf("resp = %+v, err = %v; want a rejection when the PDB allows no disruption", resp, err)
+	}
+}
+
+func TestNewRulesValidator_Invalid(t *testing.T) {
+	if _, err := NewRulesValidator([]Rule{{Name: "r", When: `true`, Decision: "allow"}}); err == nil {
+		t.Error("want an error for an unknown decision")
+	}
+	if _, err := NewRulesValidator([]Rule{{Name: "r", When: `type ==`, Decision: DecisionApprove}}); err == nil {
+		t.Error("want an error for an invalid expression")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Chain and Gate Tests
+// ---------------------------------------------------------------------------
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	abstain := &fixedValidator{name: "rules", err: ErrNoDecision}
+	approve := &fixedValidator{name: "anthropic", resp: &ValidationResponse{Approved: true}}
+	resp, err := Chain{abstain, approve}.Validate(ctx, scaleRequest(1, 0))
+	if err != nil || !resp.Approved || resp.Validator != "anthropic" {
+		t.Fatalf("resp = %+v, err = %v; want the second validator's approval", resp, err)
+	}
+
+	failing := &fixedValidator{name: "openai", err: errors.New("connection refused")}
+	after := &fixedValidator{name: "anthropic", resp: &ValidationResponse{Approved: true}}
+	if _, err := (Chain{failing, after}).Validate(ctx, scaleRequest(1, 0)); err == nil || after.calls != 0 {
+		t.Errorf("err = %v, later calls = %d; a failing validator should end the chain", err, after.calls)
+	}
+
+	if _, err := (Chain{abstain}).Validate(ctx, scaleRequest(1, 0)); !errors.Is(err, ErrNoDecision) {
+		t.Errorf("err = %v, want ErrNoDecision", err)
+	}
+}
+
+func TestAIGate_FailsClosed(t *testing.T) {
+	ctx := context.Background()
+	for name, v := range map[string]Validator{
+		"no decision": &fixedValidator{name: "rules", err: ErrNoDecision},
+		"error":       &fixedValidator{name: "openai", err: errors.New("timeout")},
+	} {
+		resp, err := NewAIGateWithValidator(v).Validate(ctx, scaleRequest(1, 0))
+		if err != nil || resp.Approved {
+			t.Errorf("%s: resp = %+v, err = %v; want a rejection", name, resp, err)
+		}
+	}
+}
+
+func TestNewAIGate_Validators(t *testing.T) {
+	gate, err := NewAIGate(Config{
+		Enabled:    true,
+		Validators: []string{ValidatorRules},
+		Rules:      []Rule{{Name: "all", When: `true`, Decision: DecisionApprove}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := gate.Validate(context.Background(), scaleRequest(1, 0))
+	if !resp.Approved || resp.Validator != ValidatorRules || resp.Rule != "all" {
+		t.Errorf("resp = %+v, want approval by the rules validator", resp)
+	}
+
+	for _, cfg := range []Config{
+		{Enabled: true, Validators: []string{"bogus"}},
+		{Enabled: true, Validators: []string{ValidatorOpenAI}},
+		{Enabled: true, Validators: []string{ValidatorRules}, Rules: []Rule{{Name: "bad", When: `(`, Decision: DecisionApprove}}},
+	} {
+		if _, err := NewAIGate(cfg); err == nil {
+			t.Errorf("NewAIGate(%+v) succeeded, want an error", cfg.Validators)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// OpenAI Validator Tests
+// ---------------------------------------------------------------------------
+
+func TestOpenAIValidator(t *testing.T) {
+	var got chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content := "```json\n{\"approved\": true, \"confidence\": 0.9, \"reasoning\": \"small change\", \"validator\": \"rules\"}\n```"
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer srv.Close()
+
+	v := NewOpenAIValidator(OpenAIConfig{BaseURL: srv.URL + "/v1/", Model: "llama-3-70b", APIKey: "s3cret"})
+	resp, err := Chain{v}.Validate(context.Background(), scaleRequest(1, 0))
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !resp.Approved || resp.Confidence != 0.9 || resp.Validator != ValidatorOpenAI {
+		t.Errorf("resp = %+v, want an approval attributed to openai", resp)
+	}
+	if got.Model != "llama-3-70b" || len(got.Messages) != 2 || got.Messages[0].Role != "system" ||
+		!strings.Contains(got.Messages[1].Content, "Scale workers from 6 to 4") {
+		t.Errorf("request = %+v, want the system prompt and the change", got)
+	}
+
+	bad := NewOpenAIValidator(OpenAIConfig{BaseURL: srv.URL, Model: "m"})
+	if _, err := bad.Validate(context.Background(), scaleRequest(1, 0)); err == nil {
+		t.Error("want an error for a non-200 response")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Decision History and Replay Tests
+// ---------------------------------------------------------------------------
+
+type memRecorder struct {
+	decisions []Decision
+	outcomes  map[string]string
+}
+
+func (r *memRecorder) RecordDecision(d Decision) { r.decisions = append(r.decisions, d) }
+
+func (r *memRecorder) RecordOutcome(recID, outcome, _ string, _ time.Time) {
+	if r.outcomes == nil {
+		r.outcomes = map[string]string{}
+	}
+	r.outcomes[recID] = outcome
+}
+
+func TestAIGate_RecordsDecisions(t *testing.T) {
+	ctx := context.Background()
+	rec := &memRecorder{}
+	v := &fixedValidator{name: "anthropic", resp: &ValidationResponse{Approved: true, Model: "m", Raw: `{"approved": true}`}}
+	gate := NewAIGateWithValidator(v)
+	gate.SetRecorder(rec)
+
+	req := scaleRequest(1, -100)
+	req.Recommendation.ID = "scale-workers"
+	req.Time = time.Date(2026, 3, 7, 23, 0, 0, 0, time.UTC) // Saturday night
+	if _, err := gate.Validate(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	v.resp, v.err = nil, errors.New("timeout")
+	if _, err := gate.Validate(ctx, scaleRequest(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.decisions) != 2 {
+		t.Fatalf("decisions = %d, want 2", len(rec.decisions))
+	}
+	d := rec.decisions[0]
+	if d.RecommendationID != "scale-workers" || !d.Response.Approved || d.RawOutput != `{"approved": true}` ||
+		d.PromptVersion != BuiltinPromptVersion || !d.Timestamp.Equal(req.Time) {
+		t.Errorf("decision = %+v", d)
+	}
+	if !strings.Contains(d.Prompt, "Scale workers from 6 to 4") || !strings.Contains(d.Prompt, "Is business hours (6AM-8PM): false") {
+		t.Errorf("prompt should describe the change at the request time:\n%s", d.Prompt)
+	}
+	failed := rec.decisions[1]
+	if failed.Response.Approved || failed.Error == "" || failed.Request.Time.IsZero() {
+		t.Errorf("failed decision = %+v, want a timestamped rejection with the error", failed)
+	}
+
+	gate.ReportOutcome("scale-workers", OutcomeRolledBack, "restart spike")
+	if rec.outcomes["scale-workers"] != OutcomeRolledBack {
+		t.Errorf("outcomes = %v", rec.outcomes)
+	}
+	var nilGate *AIGate
+	nilGate.ReportOutcome("scale-workers", OutcomeSuccess, "") // must not panic
+}
+
+func TestPromptVersion(t *testing.T) {
+	if got := PromptVersion(""); got != BuiltinPromptVersion {
+		t.Errorf("PromptVersion(\"\") = %q", got)
+	}
+	custom := PromptVersion("Reject everything.")
+	if !strings.HasPrefix(custom, "custom-") || custom != PromptVersion("Reject everything.") || custom == PromptVersion("Approve everything.") {
+		t.Errorf("PromptVersion = %q, want a stable per-prompt custom version", custom)
+	}
+	gate, err := NewAIGate(Config{Enabled: true, SystemPrompt: "Reject everything."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gate.PromptVersion() != custom {
+		t.Errorf("gate prompt version = %q, want %q", gate.PromptVersion(), custom)
+	}
+}
+
+func TestBuildValidationPrompt_WorkloadContext(t *testing.T) {
+	req := scaleRequest(1, -100)
+	req.ClusterContext.PendingPods = 2
+	req.Workloads = []WorkloadContext{
+		{Namespace: "shop", Kind: "Deployment", Name: "web", Replicas: 3, ReadyReplicas: 2, RecentOOMKills: 1,
+			PDB: "web-pdb", HelmDrift: "replicas helm=2 actual=3"},
+	}
+	req.RecentEvents = []string{"2026-01-05T10:00:00Z rightsize-rollback by rightsizer-watchdog: OOM kills"}
+
+	prompt := buildValidationPrompt(req)
+	for _, want := range []string{
+		"- Pending pods: 2",
+		"- Deployment shop/web: replicas=3, ready=2, pending=0, restarts=0, OOM kills (24h)=1, PDB=web-pdb (disruptions allowed: 0), Helm drift: replicas helm=2 actual=3",
+		"### Recent Events on the Target\n- 2026-01-05T10:00:00Z rightsize-rollback",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	decision := func(id int64, nodes int, approved bool, outcome string) Decision {
+		return Decision{
+			ID:       id,
+			Request:  scaleRequest(nodes, -100),
+			Response: ValidationResponse{Approved: approved, Validator: ValidatorAnthropic},
+			Outcome:  outcome,
+		}
+	}
+	decisions := []Decision{
+		decision(1, 1, true, OutcomeSuccess),    // still approved
+		decision(2, 3, true, OutcomeRolledBack), // now rejected
+		decision(3, 1, false, OutcomeSuccess),   // now approved, and it held once a human approved it
+		decision(4, 5, false, ""),               // still rejected
+		decision(5, 2, true, OutcomeOOM),        // now rejected
+		decision(6, 1, true, OutcomeOOM),        // still approved
+	}
+	rules, err := NewRulesValidator([]Rule{
+		{Name: "single-node", When: `impact.nodesAffected <= 1`, Decision: DecisionApprove},
+		{Name: "multi-node", When: `true`, Decision: DecisionReject},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Replay(context.Background(), NewAIGateWithValidator(Chain{rules}), decisions)
+
+	want := ReplayReport{
+		PromptVersion: BuiltinPromptVersion,
+		Total:         6, Changed: 3, NewlyApproved: 1, NewlyRejected: 2,
+		OriginalApprovedFailures: 3, ReplayedApprovedFailures: 1,
+		OriginalRejectedSuccesses: 1, ReplayedRejectedSuccesses: 0,
+	}
+	got := *report
+	got.Results = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("report = %+v, want %+v", got, want)
+	}
+	if r := report.Results[1]; !r.Changed || r.Replayed.Rule != "multi-node" || r.Outcome != OutcomeRolledBack {
+		t.Errorf("result 2 = %+v, want a rejection by multi-node", r)
+	}
+}
@@ -87,6 +87,30 @@ type NodeGroup struct {
 	DiskType       string   // e.g. "pd-balanced", "hyperdisk-balanced", "gp3"
 	DiskSizeGB     int      // boot disk size in GiB
 	InstanceIDs    []string // Cloud instance IDs (e.g., EC2 instance IDs) for node matching
+
+	// Karpenter NodePools (Source == NodeGroupSourceKarpenter) have no
+	// desired count of their own: CurrentCount and DesiredCount are the live
+	// NodeClaims, MinCount is always 0 and MaxCount is derived from Limits
+	// (0 when the pool is unlimited).
+	Source              string              // "" for cloud node groups
+	AllowedFamilies     []string            // instance families permitted by the group, empty = unconstrained
+	Limits              corev1.ResourceList // NodePool spec.limits
+	DisruptionBudgets   []DisruptionBudget  // NodePool spec.disruption.budgets
+	ConsolidationPolicy string              // NodePool spec.disruption.consolidationPolicy
+}
+
+// NodeGroupSourceKarpenter marks node groups backed by a Karpenter NodePool.
+const NodeGroupSourceKarpenter = "karpenter"
+
+// DisruptionBudget limits how many nodes of a group may be voluntarily
+// disrupted at once. Nodes is a count ("2") or a percentage ("10%"). A
+// budget with a Schedule (cron) is only active for Duration after each
+// activation; Reasons restricts it to specific disruption reasons.
+type DisruptionBudget struct {
+	Nodes    string        `json:"nodes"`
+	Schedule string        `json:"schedule,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Reasons  []string      `json:"reasons,omitempty"`
 }
 
 // SpotInstanceInfo provides spot-specific pricing and reliability data.
@@ -138,6 +162,19 @@ type GPUInstanceDetector interface {
 	DetectGPUByInstanceType(instanceType string) (gpuCount int, gpuModel string)
 }
 
+// NodeRemover is implemented by providers whose node groups can remove one
+// specific node, instead of letting the group choose an instance when its
+// desired count is lowered.
+type NodeRemover interface {
+	// CanRemoveNode reports whether the node group supports targeted removal.
+	CanRemoveNode(nodeGroupID string) bool
+	// DisruptionAllowed returns an error when the group's disruption budgets
+	// do not allow another voluntary node removal right now.
+	DisruptionAllowed(ctx context.Context, nodeGroupID string) error
+	// RemoveNode removes the (already drained) node from the group.
+	RemoveNode(ctx context.Context, nodeGroupID, nodeName string) error
+}
+
 type Commitment struct {
 	ID              string
 	Type            string  // "reserved-instance", "savings-plan", "cud", "reservation"
@@ -174,5 +211,9 @@ func IsSpotNode(node *corev1.Node) bool {
 	if v, ok := node.Labels["kubernetes.azure.com/scalesetpriority"]; ok && v == "spot" {
 		return true
 	}
+	// Karpenter
+	if v, ok := node.Labels["karpenter.sh/capacity-type"]; ok && v == "spot" {
+		return true
+	}
 	return false
 }
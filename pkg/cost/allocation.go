@@ -0,0 +1,52 @@
+package cost
+
+import "time"
+
+// Shared cost strategies: how the cost of shared (platform) namespaces is
+// spread across tenant namespaces.
+const (
+	SharedProportional = "proportional" // by each tenant's direct cost
+	SharedEven         = "even"         // equally across tenants
+	SharedNone         = "none"         // reported separately, not redistributed
+)
+
+// Allocation attributes the full cost of every node to tenant namespaces,
+// shared namespaces and the idle capacity of each node group. Namespaces,
+// Shared and Idle always sum to TotalMonthlyCostUSD.
+type Allocation struct {
+	TotalMonthlyCostUSD float64               `json:"totalMonthlyCostUSD"`
+	TenantCostUSD       float64               `json:"tenantCostUSD"` // direct + redistributed shared cost
+	SharedCostUSD       float64               `json:"sharedCostUSD"` // direct cost of shared namespaces
+	IdleCostUSD         float64               `json:"idleCostUSD"`
+	SharedStrategy      string                `json:"sharedStrategy"`
+	UsageWeight         float64               `json:"usageWeight"`
+	Namespaces          []NamespaceAllocation `json:"namespaces"`
+	Shared              []NamespaceAllocation `json:"shared"`
+	Idle                []IdleAllocation      `json:"idle"`
+	Timestamp           time.Time             `json:"timestamp"`
+}
+
+// NamespaceAllocation is the monthly cost of one namespace. DirectCostUSD
+// is what its own pods consume; SharedCostUSD is its slice of the shared
+// namespaces. For a shared namespace MonthlyCostUSD is the part that was
+// not redistributed (all of it with SharedNone, none otherwise).
+type NamespaceAllocation struct {
+	Namespace      string  `json:"namespace"`
+	CPUCostUSD     float64 `json:"cpuCostUSD"`
+	MemoryCostUSD  float64 `json:"memoryCostUSD"`
+	GPUCostUSD     float64 `json:"gpuCostUSD"`
+	DirectCostUSD  float64 `json:"directCostUSD"`
+	SharedCostUSD  float64 `json:"sharedCostUSD"`
+	MonthlyCostUSD float64 `json:"monthlyCostUSD"`
+}
+
+// IdleAllocation is the monthly cost of capacity no pod is allocated in a
+// node group. Nodes without a group are reported under NodeGroupID "".
+type IdleAllocation struct {
+	NodeGroupID    string  `json:"nodeGroupId"`
+	NodeGroup      string  `json:"nodeGroup"`
+	CPUCostUSD     float64 `json:"cpuCostUSD"`
+	MemoryCostUSD  float64 `json:"memoryCostUSD"`
+	GPUCostUSD     float64 `json:"gpuCostUSD"`
+	MonthlyCostUSD float64 `json:"monthlyCostUSD"`
+}
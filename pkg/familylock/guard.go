@@ -3,6 +3,7 @@ package familylock
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/koptimizer/koptimizer/pkg/cloudprovider"
@@ -76,14 +77,27 @@ func (g *FamilyLockGuard) ValidateScaleUpCtx(ctx context.Context, nodeGroupID st
 		}
 	}
 
-	currentFamily, err := ExtractFamily(ng.InstanceType)
+	proposedFamily, err := ExtractFamily(proposedType)
 	if err != nil {
-		return fmt.Errorf("extracting current family: %w", err)
+		return fmt.Errorf("extracting proposed family: %w", err)
 	}
 
-	proposedFamily, err := ExtractFamily(proposedType)
+	// Groups that declare their permitted families (Karpenter NodePool
+	// requirements) are validated against that set rather than the type
+	// currently running.
+	if len(ng.AllowedFamilies) > 0 {
+		for _, f := range ng.AllowedFamilies {
+			if strings.EqualFold(f, proposedFamily) {
+				return nil
+			}
+		}
+		return fmt.Errorf("BLOCKED: family %s is not allowed in node group %s (allowed: %s)",
+			proposedFamily, ng.Name, strings.Join(ng.AllowedFamilies, ", "))
+	}
+
+	currentFamily, err := ExtractFamily(ng.InstanceType)
 	if err != nil {
-		return fmt.Errorf("extracting proposed family: %w", err)
+		return fmt.Errorf("extracting current family: %w", err)
 	}
 
 	if currentFamily != proposedFamily {
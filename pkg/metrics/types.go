@@ -36,20 +36,35 @@ type ContainerMetrics struct {
 	GPUUsage    float64
 }
 
+// UsageSample is one historical CPU/memory observation, e.g. a point
+// backfilled from Prometheus.
+type UsageSample struct {
+	Timestamp   time.Time
+	CPUUsage    int64 // millicores
+	MemoryUsage int64 // bytes
+}
+
+// GPU metrics sources.
+const (
+	GPUSourceAllocation = "allocation" // estimated from nvidia.com/gpu requests
+	GPUSourceDCGM       = "dcgm"       // scraped from NVIDIA DCGM exporter
+)
+
 type GPUMetrics struct {
 	NodeName  string
 	GPUs      []GPUDeviceMetrics
 	Timestamp time.Time
+	Source    string // GPUSourceAllocation or GPUSourceDCGM
 }
 
 type GPUDeviceMetrics struct {
 	Index       int
 	UUID        string
 	Model       string
-	MemoryTotal int64
-	MemoryUsed  int64
+	MemoryTotal int64   // bytes
+	MemoryUsed  int64   // bytes
 	Utilization float64 // 0-100
-	Temperature float64
+	Temperature float64 // Celsius
 }
 
 // MetricsWindow represents a time-series window of metrics data.
@@ -65,4 +80,41 @@ type MetricsWindow struct {
 	P95Memory  int64
 	P99Memory  int64
 	MaxMemory  int64
+	Runs       int // pods aggregated into a batch window; 0 for a single pod
+}
+
+// GPUWindow summarizes GPU samples for a node across all of its devices.
+type GPUWindow struct {
+	Start          time.Time
+	End            time.Time
+	DataPoints     int
+	Devices        int
+	P50Utilization float64
+	P95Utilization float64
+	MaxUtilization float64
+	MaxMemoryUsed  int64
+	MaxTemperature float64
+}
+
+// CFSThrottling is a cumulative CFS bandwidth sample for one container, as
+// exported by cAdvisor. Both counters only ever grow while the container
+// lives, so throttling over an interval is the ratio of their deltas.
+type CFSThrottling struct {
+	Namespace        string
+	Pod              string
+	Container        string
+	Periods          float64 // container_cpu_cfs_periods_total
+	ThrottledPeriods float64 // container_cpu_cfs_throttled_periods_total
+	Timestamp        time.Time
+}
+
+// ThrottlingWindow summarizes the share of CFS periods in which a container
+// was throttled, one ratio (0-1) per scrape interval.
+type ThrottlingWindow struct {
+	Start      time.Time
+	End        time.Time
+	DataPoints int
+	AvgRatio   float64
+	P95Ratio   float64
+	MaxRatio   float64
 }
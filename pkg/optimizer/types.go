@@ -60,6 +60,38 @@ type Recommendation struct {
 	CreatedAt       time.Time
 }
 
+// DetailContainers is the Recommendation.Details key holding a JSON-encoded
+// []ContainerChange for per-container rightsizing recommendations.
+const DetailContainers = "containers"
+
+// Container change actions.
+const (
+	ContainerActionResize    = "resize"
+	ContainerActionUnchanged = "unchanged"
+	ContainerActionSkipped   = "skipped"
+)
+
+// ContainerChange is the per-container diff of a rightsizing recommendation.
+// Quantities use Kubernetes notation (e.g. "250m", "512Mi").
+type ContainerChange struct {
+	Name                string `json:"name"`
+	Sidecar             bool   `json:"sidecar,omitempty"`
+	Action              string `json:"action"`
+	Reason              string `json:"reason,omitempty"`
+	CurrentCPURequest   string `json:"currentCPURequest"`
+	SuggestedCPURequest string `json:"suggestedCPURequest"`
+	CurrentMemRequest   string `json:"currentMemRequest"`
+	SuggestedMemRequest string `json:"suggestedMemRequest"`
+	P95CPU              string `json:"p95CPU,omitempty"`
+	P95Mem              string `json:"p95Mem,omitempty"`
+
+	// CPU limit changes made with the resize. An empty SuggestedCPULimit
+	// leaves the limit alone unless RemoveCPULimit is set.
+	CurrentCPULimit   string `json:"currentCPULimit,omitempty"`
+	SuggestedCPULimit string `json:"suggestedCPULimit,omitempty"`
+	RemoveCPULimit    bool   `json:"removeCPULimit,omitempty"`
+}
+
 type AIGateResult struct {
 	Approved    bool
 	Confidence  float64
@@ -102,6 +134,8 @@ type NodeInfo struct {
 	MemoryUsed      int64
 	GPUs            int
 	GPUsUsed        int
+	GPUUtilization  float64 // measured mean across devices, 0-100; valid if GPUMeasured
+	GPUMeasured     bool    // GPUUtilization comes from DCGM rather than allocation
 	HourlyCostUSD   float64
 	IsGPUNode       bool
 	NodeGroup       string